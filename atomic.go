@@ -0,0 +1,57 @@
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// AtomicWriteFile writes data to the named file, replacing it atomically.
+// The data is written to a temporary file in the same directory, synced to
+// stable storage and renamed over name, so readers observe either the old or
+// the new contents and a crash never leaves a partially written file.
+// If the file does not exist, AtomicWriteFile creates it with permissions perm
+// (before umask); otherwise the permissions of the existing file are kept.
+// The directory is synced after the rename where the backend supports it.
+func AtomicWriteFile(fsys FS, name string, data []byte, perm fs.FileMode) (err error) {
+	if info, err := fs.Stat(fsys, name); err == nil {
+		perm = info.Mode().Perm()
+	}
+	dir, base := path.Split(name)
+	var f File
+	var tmp string
+	for range 10000 {
+		tmp = tempName(dir, "."+base+".tmp", "")
+		f, err = fsys.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if !errors.Is(err, fs.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			fsys.Remove(tmp)
+		}
+	}()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	if err = fsys.Rename(tmp, name); err != nil {
+		return err
+	}
+	// the rename is complete, syncing the directory only makes it durable
+	// and is not supported by every platform
+	SyncFS(fsys, path.Dir(name))
+	return nil
+}
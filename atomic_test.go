@@ -0,0 +1,54 @@
+package wfs_test
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestAtomicWriteFile(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dir/config": &fstest.MapFile{Data: []byte("old"), Mode: 0600},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			name := filepath.Join(base, "dir/config")
+			if err := wfs.AtomicWriteFile(fsys, name, []byte("new"), 0644); err != nil {
+				t.Fatalf("AtomicWriteFile failed: %v", err)
+			}
+			b, err := fs.ReadFile(fsys, name)
+			if err != nil || string(b) != "new" {
+				t.Errorf("expected 'new', got %q err: %v", b, err)
+			}
+			info, err := fs.Stat(fsys, name)
+			if err != nil || info.Mode().Perm() != 0600 {
+				t.Errorf("expected existing permissions to be kept, got %v err: %v", info.Mode(), err)
+			}
+
+			created := filepath.Join(base, "dir/created")
+			if err := wfs.AtomicWriteFile(fsys, created, []byte("data"), 0640); err != nil {
+				t.Fatalf("AtomicWriteFile failed: %v", err)
+			}
+			if b, err := fs.ReadFile(fsys, created); err != nil || string(b) != "data" {
+				t.Errorf("expected 'data', got %q err: %v", b, err)
+			}
+
+			// no temporary files are left behind
+			entries, err := fs.ReadDir(fsys, filepath.Join(base, "dir"))
+			if err != nil || len(entries) != 2 {
+				t.Errorf("expected only the written files, got %v err: %v", entries, err)
+			}
+			if err := wfs.AtomicWriteFile(fsys, filepath.Join(base, "missing/file"), nil, 0644); err == nil {
+				t.Errorf("expected AtomicWriteFile to fail in a missing directory")
+			}
+		})
+	}
+}
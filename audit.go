@@ -0,0 +1,216 @@
+package wfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditRecord is the record of a mutation written by [Audit].
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Principal string    `json:"principal,omitempty"`
+	Op        string    `json:"op"`                 // operation, named like in [FaultRule]
+	Path      string    `json:"path"`               // path of the file, or new path of rename, link and symlink
+	OldPath   string    `json:"old_path,omitempty"` // old path of rename and link, or target of symlink
+	Size      int64     `json:"size,omitempty"`     // bytes written, or size of truncate
+	Error     string    `json:"error,omitempty"`    // error of the mutation, empty if it succeeded
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx that carries principal, the identity
+// mutations are attributed to by [AuditFS.WithContext].
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom returns the principal carried by ctx, if any.
+func PrincipalFrom(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
+
+// AuditFS is a file system that writes an audit record of every mutation.
+type AuditFS interface {
+	FS
+
+	// WithContext returns a view of the file system whose records are
+	// attributed to the principal of ctx, see [WithPrincipal].
+	// Records of all views are written to the same writer.
+	WithContext(ctx context.Context) FS
+}
+
+// Audit returns an AuditFS that writes an [AuditRecord] for every mutation
+// of fsys, whether it succeeds or not, to w as a line of JSON. Each record is
+// written with a single call to w, so w may be a file opened with
+// [os.O_APPEND] on another file system.
+//
+// Files opened for writing are recorded when they are opened and the bytes
+// written to them when they are closed. If a record cannot be written the
+// mutation returns the error, even though it was performed.
+func Audit(fsys FS, w io.Writer) AuditFS {
+	return &auditFs{fsys: fsys, log: &auditLog{w: w}}
+}
+
+// auditLog serializes the records written by the views of an AuditFS.
+type auditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type auditFs struct {
+	fsys      FS
+	log       *auditLog
+	principal string
+}
+
+func (f *auditFs) WithContext(ctx context.Context) FS {
+	principal, _ := PrincipalFrom(ctx)
+	return &auditFs{fsys: f.fsys, log: f.log, principal: principal}
+}
+
+// record writes r with the result err and returns err joined with the error
+// writing the record.
+func (f *auditFs) record(r AuditRecord, err error) error {
+	r.Time = time.Now()
+	r.Principal = f.principal
+	if err != nil {
+		r.Error = err.Error()
+	}
+	b, merr := json.Marshal(r)
+	if merr != nil {
+		return errors.Join(err, merr)
+	}
+	b = append(b, '\n')
+	f.log.mu.Lock()
+	defer f.log.mu.Unlock()
+	_, werr := f.log.w.Write(b)
+	return errors.Join(err, werr)
+}
+
+func (f *auditFs) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+// Stat implements [fs.StatFS] for auditFs.
+func (f *auditFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+func (f *auditFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	file, err := f.fsys.OpenFile(name, flag, perm)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		return file, err
+	}
+	rerr := f.record(AuditRecord{Op: "open", Path: name}, err)
+	if err != nil {
+		return nil, rerr
+	}
+	if rerr != nil {
+		file.Close()
+		return nil, rerr
+	}
+	return &auditFile{File: file, fs: f}, nil
+}
+
+func (f *auditFs) Rename(oldpath, newpath string) error {
+	return f.record(AuditRecord{Op: "rename", Path: newpath, OldPath: oldpath}, f.fsys.Rename(oldpath, newpath))
+}
+
+func (f *auditFs) Remove(name string) error {
+	return f.record(AuditRecord{Op: "remove", Path: name}, f.fsys.Remove(name))
+}
+
+func (f *auditFs) RemoveAll(path string) error {
+	return f.record(AuditRecord{Op: "RemoveAll", Path: path}, f.fsys.RemoveAll(path))
+}
+
+func (f *auditFs) Mkdir(name string, perm fs.FileMode) error {
+	return f.record(AuditRecord{Op: "mkdir", Path: name}, f.fsys.Mkdir(name, perm))
+}
+
+func (f *auditFs) MkdirAll(path string, perm fs.FileMode) error {
+	return f.record(AuditRecord{Op: "MkdirAll", Path: path}, f.fsys.MkdirAll(path, perm))
+}
+
+// Symlink implements [SymlinkFS] for auditFs.
+func (f *auditFs) Symlink(oldname, newname string) error {
+	return f.record(AuditRecord{Op: "symlink", Path: newname, OldPath: oldname}, Symlink(f.fsys, oldname, newname))
+}
+
+// Readlink implements [SymlinkFS] for auditFs.
+func (f *auditFs) Readlink(name string) (string, error) {
+	return Readlink(f.fsys, name)
+}
+
+// Lstat implements [SymlinkFS] for auditFs.
+func (f *auditFs) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(f.fsys, name)
+}
+
+// Link implements [LinkFS] for auditFs.
+func (f *auditFs) Link(oldname, newname string) error {
+	return f.record(AuditRecord{Op: "link", Path: newname, OldPath: oldname}, Link(f.fsys, oldname, newname))
+}
+
+// Chmod implements [MetaFS] for auditFs.
+func (f *auditFs) Chmod(name string, mode fs.FileMode) error {
+	return f.record(AuditRecord{Op: "chmod", Path: name}, Chmod(f.fsys, name, mode))
+}
+
+// Chown implements [MetaFS] for auditFs.
+func (f *auditFs) Chown(name string, uid, gid int) error {
+	return f.record(AuditRecord{Op: "chown", Path: name}, Chown(f.fsys, name, uid, gid))
+}
+
+// Chtimes implements [MetaFS] for auditFs.
+func (f *auditFs) Chtimes(name string, atime, mtime time.Time) error {
+	return f.record(AuditRecord{Op: "chtimes", Path: name}, Chtimes(f.fsys, name, atime, mtime))
+}
+
+// auditFile counts the bytes written to a file to record them on close.
+type auditFile struct {
+	File
+	fs      *auditFs
+	written int64
+}
+
+func (f *auditFile) Write(b []byte) (int, error) {
+	n, err := f.File.Write(b)
+	f.written += int64(n)
+	return n, err
+}
+
+func (f *auditFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *auditFile) WriteAt(b []byte, off int64) (int, error) {
+	n, err := f.File.WriteAt(b, off)
+	f.written += int64(n)
+	return n, err
+}
+
+func (f *auditFile) Truncate(size int64) error {
+	return f.fs.record(AuditRecord{Op: "truncate", Path: f.Name(), Size: size}, f.File.Truncate(size))
+}
+
+func (f *auditFile) Close() error {
+	err := f.File.Close()
+	if f.written == 0 && err == nil {
+		return nil
+	}
+	op := "write"
+	if f.written == 0 {
+		op = "close"
+	}
+	written := f.written
+	f.written = 0
+	return f.fs.record(AuditRecord{Op: op, Path: f.Name(), Size: written}, err)
+}
@@ -0,0 +1,103 @@
+package wfs_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestAudit(t *testing.T) {
+	logs := wfs.Mem()
+	w, err := logs.OpenFile("audit.log", os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	afs := wfs.Audit(wfs.Mem(), w)
+	fsys := afs.WithContext(wfs.WithPrincipal(context.Background(), "alice"))
+
+	if err := wfs.WriteFile(fsys, "file", []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, fsys, "file", "hello")
+	if err := fsys.Rename("file", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := afs.Remove("missing"); err == nil {
+		t.Fatal("expected remove to fail")
+	}
+
+	r, err := logs.Open("audit.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var records []wfs.AuditRecord
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var record wfs.AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Time.IsZero() {
+			t.Errorf("expected record time to be set")
+		}
+		records = append(records, record)
+	}
+
+	expected := []wfs.AuditRecord{
+		{Principal: "alice", Op: "open", Path: "file"},
+		{Principal: "alice", Op: "write", Path: "file", Size: 5},
+		{Principal: "alice", Op: "rename", Path: "renamed", OldPath: "file"},
+		{Op: "remove", Path: "missing"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, got %d: %+v", len(expected), len(records), records)
+	}
+	for i, record := range records {
+		failed := record.Error != ""
+		record.Time, record.Error = expected[i].Time, ""
+		if record != expected[i] {
+			t.Errorf("expected record %+v, got %+v", expected[i], record)
+		}
+		if failed != (i == 3) {
+			t.Errorf("unexpected result of record %d: %+v", i, records[i])
+		}
+	}
+}
+
+func TestAuditFailedOpen(t *testing.T) {
+	logs := wfs.Mem()
+	w, err := logs.OpenFile("audit.log", os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	fsys := wfs.Audit(wfs.Mem(), w)
+
+	f, err := fsys.OpenFile("missing/file", os.O_WRONLY|os.O_CREATE, 0644)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected open to fail with ErrNotExist, got %v", err)
+	}
+	if f != nil {
+		t.Errorf("expected no file on a failed open, got %#v", f)
+	}
+
+	data, err := fs.ReadFile(logs, "audit.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record wfs.AuditRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Op != "open" || record.Path != "missing/file" || record.Error == "" {
+		t.Errorf("expected failed open to be recorded, got %+v", record)
+	}
+}
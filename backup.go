@@ -0,0 +1,72 @@
+package wfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/fs"
+	"path"
+)
+
+// Backup copies the tree rooted at root in src into the directory dir of dst,
+// creating an incremental snapshot in the style of rsnapshot.
+//
+// If prev names a previous snapshot directory in dst, files that are unchanged
+// since that snapshot are hard linked to it when dst implements [LinkFS], and
+// copied otherwise. A file is unchanged if it has the same size and contents.
+// If prev is empty a full backup is made.
+func Backup(dst FS, dir string, src fs.FS, root string, prev string) error {
+	linker, canLink := dst.(LinkFS)
+	return fs.WalkDir(src, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel := relPath(root, name)
+		target := path.Join(dir, rel)
+		if d.IsDir() {
+			if name == root {
+				target = dir
+			}
+			return dst.MkdirAll(target, info.Mode().Perm())
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if prev != "" {
+			prevName := path.Join(prev, rel)
+			same, err := sameContent(src, name, info, dst, prevName)
+			if err != nil {
+				return err
+			}
+			if same && canLink && linker.Link(prevName, target) == nil {
+				return nil
+			}
+			if same {
+				return copyFile(dst, target, dst, prevName, info.Mode().Perm())
+			}
+		}
+		return copyFile(dst, target, src, name, info.Mode().Perm())
+	})
+}
+
+// sameContent reports whether the file b in fsysB has the same size and
+// contents as the file a in fsysA described by info.
+func sameContent(fsysA fs.FS, a string, info fs.FileInfo, fsysB fs.FS, b string) (bool, error) {
+	binfo, err := fs.Stat(fsysB, b)
+	if err != nil || !binfo.Mode().IsRegular() || binfo.Size() != info.Size() {
+		return false, nil
+	}
+	suma, err := HashFile(fsysA, a, sha256.New)
+	if err != nil {
+		return false, err
+	}
+	sumb, err := HashFile(fsysB, b, sha256.New)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(suma, sumb), nil
+}
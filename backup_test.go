@@ -0,0 +1,64 @@
+package wfs_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestBackup(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"data/same":        &fstest.MapFile{Data: []byte("same")},
+				"data/nested/edit": &fstest.MapFile{Data: []byte("before")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			data := filepath.Join(base, "data")
+			snap1 := filepath.Join(base, "snap1")
+			snap2 := filepath.Join(base, "snap2")
+			if err := wfs.Backup(fsys, snap1, fsys, data, ""); err != nil {
+				t.Fatalf("Backup failed: %v", err)
+			}
+			if err := wfs.WriteFile(fsys, filepath.Join(data, "nested", "edit"), []byte("after"), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			if err := wfs.Backup(fsys, snap2, fsys, data, snap1); err != nil {
+				t.Fatalf("Backup failed: %v", err)
+			}
+
+			expected := map[string]string{
+				filepath.Join(snap1, "same"):           "same",
+				filepath.Join(snap1, "nested", "edit"): "before",
+				filepath.Join(snap2, "same"):           "same",
+				filepath.Join(snap2, "nested", "edit"): "after",
+			}
+			for name, want := range expected {
+				b, err := fs.ReadFile(fsys, name)
+				if err != nil || string(b) != want {
+					t.Errorf("expected %q to contain %q, got %q err: %v", name, want, b, err)
+				}
+			}
+
+			// unchanged files are hard linked to the previous snapshot
+			f, err := fsys.OpenFile(filepath.Join(snap2, "same"), os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatalf("OpenFile failed: %v", err)
+			}
+			f.WriteAt([]byte("l"), 0)
+			f.Close()
+			b, err := fs.ReadFile(fsys, filepath.Join(snap1, "same"))
+			if err != nil || string(b) != "lame" {
+				t.Errorf("expected previous snapshot to share linked file, got %q err: %v", b, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,468 @@
+package wfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+)
+
+// ConflictAction is the action taken for a path changed on both sides
+// since the last [Bisync].
+type ConflictAction int
+
+const (
+	// ConflictUseA replaces the version of b with the version of a.
+	ConflictUseA ConflictAction = iota + 1
+
+	// ConflictUseB replaces the version of a with the version of b.
+	ConflictUseB
+
+	// ConflictKeepBoth keeps the version of a and copies the version of b
+	// to both sides under a name with a ".conflict" suffix. A deleted file
+	// loses to the file that was modified.
+	ConflictKeepBoth
+
+	// ConflictSkip leaves both versions in place, the path is reported as
+	// a conflict again by the next sync.
+	ConflictSkip
+)
+
+// ConflictPolicy decides the action for a file named name that was changed
+// both in a and in b since the last sync. a and b describe the current
+// versions and are nil for a deleted file.
+// Returning an error aborts the sync.
+type ConflictPolicy func(name string, a, b fs.FileInfo) (ConflictAction, error)
+
+// PolicyNewestWins is a ConflictPolicy that keeps the most recently modified
+// version, a modified file wins over its deletion.
+func PolicyNewestWins(name string, a, b fs.FileInfo) (ConflictAction, error) {
+	if b == nil || (a != nil && a.ModTime().After(b.ModTime())) {
+		return ConflictUseA, nil
+	}
+	return ConflictUseB, nil
+}
+
+// PolicyKeepBoth is a ConflictPolicy that always keeps both versions.
+func PolicyKeepBoth(name string, a, b fs.FileInfo) (ConflictAction, error) {
+	return ConflictKeepBoth, nil
+}
+
+// BisyncSide is a side of a [Bisync].
+type BisyncSide int
+
+const (
+	SideA BisyncSide = iota // the first file system passed to Bisync
+	SideB                   // the second file system passed to Bisync
+)
+
+// String returns "a" or "b".
+func (s BisyncSide) String() string {
+	if s == SideA {
+		return "a"
+	}
+	return "b"
+}
+
+// BisyncStats summarizes the changes made by [Bisync] to both sides.
+type BisyncStats struct {
+	SyncStats
+
+	// Conflicts is the number of paths changed on both sides.
+	Conflicts int
+}
+
+// BisyncOptions configures [Bisync].
+// A nil *BisyncOptions is equivalent to the zero value.
+type BisyncOptions struct {
+	// State names the file in a recording both trees as they were last
+	// synced, ".wfs-bisync.json" if empty. The state file is excluded
+	// from the sync.
+	State string
+
+	// Exclude skips files and directories matching any pattern, as in
+	// [CopyOptions].
+	Exclude []string
+
+	// Conflict resolves paths changed on both sides, [PolicyKeepBoth]
+	// if nil.
+	Conflict ConflictPolicy
+
+	// DryRun reports the actions without making any changes.
+	DryRun bool
+
+	// Progress, if set, is called after each action with the side changed.
+	Progress func(side BisyncSide, action SyncAction)
+}
+
+// Bisync synchronizes the trees of a and b in both directions, such as a
+// local working directory with a remote backend. Regular files and
+// directories created, modified or deleted on one side since the last sync
+// are copied or deleted on the other side. Changes are detected by comparing
+// sizes and modification times with the state recorded by the last sync.
+//
+// Paths changed on both sides are resolved by the conflict policy unless
+// both sides have equal content. On the first sync every path present on
+// both sides with different content is a conflict. A directory deleted on
+// one side is only deleted on the other side once it is empty, so files
+// created in it on the other side are kept. Symbolic links are skipped.
+func Bisync(a, b FS, opts *BisyncOptions) (BisyncStats, error) {
+	s := &bisyncer{fsys: [2]FS{a, b}, next: make(map[string]bisyncRecord)}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.State == "" {
+		s.opts.State = ".wfs-bisync.json"
+	}
+	if s.opts.Conflict == nil {
+		s.opts.Conflict = PolicyKeepBoth
+	}
+	for _, pattern := range s.opts.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return BisyncStats{}, err
+		}
+	}
+	if err := s.loadState(); err != nil {
+		return BisyncStats{}, err
+	}
+	var current [2]map[string]fs.FileInfo
+	for i, fsys := range s.fsys {
+		var err error
+		if current[i], err = s.list(fsys); err != nil {
+			return BisyncStats{}, err
+		}
+	}
+	names := slices.Concat(
+		slices.Collect(maps.Keys(current[SideA])),
+		slices.Collect(maps.Keys(current[SideB])),
+		slices.Collect(maps.Keys(s.prev)),
+	)
+	slices.Sort(names)
+	for _, name := range slices.Compact(names) {
+		if s.excluded(name) {
+			continue
+		}
+		if err := s.syncPath(name, current[SideA][name], current[SideB][name]); err != nil {
+			return s.stats, err
+		}
+	}
+	// directories are removed after their contents
+	for _, r := range slices.Backward(s.rmdirs) {
+		if err := s.removeDir(r.side, r.name); err != nil {
+			return s.stats, err
+		}
+	}
+	if s.opts.DryRun {
+		return s.stats, nil
+	}
+	return s.stats, s.saveState()
+}
+
+type bisyncer struct {
+	fsys  [2]FS
+	opts  BisyncOptions
+	stats BisyncStats
+
+	// records of the previous and of this sync
+	prev, next map[string]bisyncRecord
+	// directories deleted on one side, removed on the other side last
+	rmdirs []bisyncDir
+}
+
+type bisyncDir struct {
+	side BisyncSide
+	name string
+}
+
+// bisyncState is the state file recorded by [Bisync].
+type bisyncState struct {
+	Version int                     `json:"version"`
+	Files   map[string]bisyncRecord `json:"files"`
+}
+
+// bisyncRecord describes a path present on both sides as it was last synced.
+type bisyncRecord [2]syncEntry
+
+// entryOf returns the entry describing info.
+func entryOf(info fs.FileInfo) syncEntry {
+	if info.IsDir() {
+		return syncEntry{Dir: true}
+	}
+	return syncEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+}
+
+func (s *bisyncer) excluded(name string) bool {
+	return name == s.opts.State || matchAny(s.opts.Exclude, name)
+}
+
+// list returns the regular files and directories of fsys.
+func (s *bisyncer) list(fsys FS) (map[string]fs.FileInfo, error) {
+	infos := make(map[string]fs.FileInfo)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		if s.excluded(name) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		infos[name] = info
+		return nil
+	})
+	return infos, err
+}
+
+// changed reports whether the path described by info on side changed since
+// the last sync.
+func (s *bisyncer) changed(side BisyncSide, name string, info fs.FileInfo) bool {
+	prev, ok := s.prev[name]
+	if info == nil || !ok {
+		return (info == nil) != !ok
+	}
+	return entryOf(info) != prev[side]
+}
+
+func (s *bisyncer) syncPath(name string, a, b fs.FileInfo) error {
+	changedA, changedB := s.changed(SideA, name, a), s.changed(SideB, name, b)
+	switch {
+	case !changedA && !changedB:
+		s.keep(name)
+		return nil
+	case !changedB:
+		return s.copy(SideB, name, a)
+	case !changedA:
+		return s.copy(SideA, name, b)
+	}
+
+	same, err := s.same(name, a, b)
+	if err != nil {
+		return err
+	}
+	if same {
+		if a != nil && !s.opts.DryRun {
+			s.next[name] = bisyncRecord{entryOf(a), entryOf(b)}
+		}
+		return nil
+	}
+	s.stats.Conflicts++
+	if (a != nil && a.IsDir()) || (b != nil && b.IsDir()) {
+		// a directory replaced by a file on the other side is left to the user
+		s.keep(name)
+		return nil
+	}
+	action, err := s.opts.Conflict(name, a, b)
+	if err != nil {
+		return err
+	}
+	switch action {
+	case ConflictUseA:
+		return s.copy(SideB, name, a)
+	case ConflictUseB:
+		return s.copy(SideA, name, b)
+	case ConflictKeepBoth:
+		if a == nil {
+			return s.copy(SideA, name, b)
+		}
+		if b == nil {
+			return s.copy(SideB, name, a)
+		}
+		conflict, err := suffixedName(name, ".conflict", s.fsys[SideA], s.fsys[SideB])
+		if err != nil {
+			return err
+		}
+		if err := s.do(SideB, SyncCreate, conflict, 0, func() error {
+			return s.fsys[SideB].Rename(name, conflict)
+		}); err != nil {
+			return err
+		}
+		if err := s.copy(SideA, conflict, b); err != nil {
+			return err
+		}
+		return s.copy(SideB, name, a)
+	case ConflictSkip:
+		s.keep(name)
+		return nil
+	}
+	return fmt.Errorf("wfs: invalid conflict action %d for %s", action, name)
+}
+
+// same reports whether a and b have equal content.
+func (s *bisyncer) same(name string, a, b fs.FileInfo) (bool, error) {
+	if a == nil || b == nil {
+		return a == b, nil
+	}
+	if a.IsDir() || b.IsDir() {
+		return a.IsDir() && b.IsDir(), nil
+	}
+	if a.Size() != b.Size() {
+		return false, nil
+	}
+	suma, err := HashFile(s.fsys[SideA], name, sha256.New)
+	if err != nil {
+		return false, err
+	}
+	sumb, err := HashFile(s.fsys[SideB], name, sha256.New)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(suma, sumb), nil
+}
+
+// copy makes the path on side match info from the other side, deleting it
+// if info is nil.
+func (s *bisyncer) copy(side BisyncSide, name string, info fs.FileInfo) error {
+	dst, src := s.fsys[side], s.fsys[1-side]
+	if info == nil {
+		existing, err := Lstat(dst, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if existing.IsDir() {
+			s.rmdirs = append(s.rmdirs, bisyncDir{side, name})
+			return nil
+		}
+		return s.do(side, SyncDelete, name, 0, func() error { return dst.Remove(name) })
+	}
+
+	op := SyncCreate
+	existing, err := Lstat(dst, name)
+	if err == nil {
+		op = SyncUpdate
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if info.IsDir() {
+		if existing != nil && existing.IsDir() {
+			s.record(name)
+			return nil
+		}
+		return s.do(side, op, name, 0, func() error {
+			if existing != nil {
+				if err := dst.Remove(name); err != nil {
+					return err
+				}
+			}
+			if err := dst.MkdirAll(name, 0777); err != nil {
+				return err
+			}
+			return copyMeta(dst, name, info)
+		})
+	}
+	return s.do(side, op, name, info.Size(), func() error {
+		if err := mkdirParent(dst, name); err != nil {
+			return err
+		}
+		if existing != nil && !existing.Mode().IsRegular() {
+			if err := dst.RemoveAll(name); err != nil {
+				return err
+			}
+		}
+		if err := copyFile(dst, name, src, name, info.Mode().Perm()); err != nil {
+			return err
+		}
+		return copyMeta(dst, name, info)
+	})
+}
+
+// removeDir removes a directory deleted on the other side if it is empty.
+func (s *bisyncer) removeDir(side BisyncSide, name string) error {
+	entries, err := fs.ReadDir(s.fsys[side], name)
+	if err != nil || len(entries) > 0 {
+		// files added to the directory were copied to the other side,
+		// creating it again
+		return err
+	}
+	delete(s.next, name)
+	return s.do(side, SyncDelete, name, 0, func() error { return s.fsys[side].Remove(name) })
+}
+
+// do reports the action on side and performs fn unless this is a dry run.
+// The path is recorded in the next state once both sides match.
+func (s *bisyncer) do(side BisyncSide, op SyncOp, name string, size int64, fn func() error) error {
+	if !s.opts.DryRun {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	switch op {
+	case SyncCreate:
+		s.stats.Created++
+	case SyncUpdate:
+		s.stats.Updated++
+	case SyncDelete:
+		s.stats.Deleted++
+	}
+	s.stats.Bytes += size
+	if s.opts.Progress != nil {
+		s.opts.Progress(side, SyncAction{Op: op, Path: name, Size: size})
+	}
+	if op != SyncDelete {
+		s.record(name)
+	}
+	return nil
+}
+
+// record records the current state of a path present on both sides.
+func (s *bisyncer) record(name string) {
+	if s.opts.DryRun {
+		return
+	}
+	var r bisyncRecord
+	for i, fsys := range s.fsys {
+		info, err := Lstat(fsys, name)
+		if err != nil {
+			delete(s.next, name)
+			return
+		}
+		r[i] = entryOf(info)
+	}
+	s.next[name] = r
+}
+
+// keep keeps the previous record of an unchanged or unresolved path.
+func (s *bisyncer) keep(name string) {
+	if r, ok := s.prev[name]; ok {
+		s.next[name] = r
+	}
+}
+
+func (s *bisyncer) loadState() error {
+	data, err := fs.ReadFile(s.fsys[SideA], s.opts.State)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state bisyncState
+	if err := json.Unmarshal(data, &state); err != nil || state.Version != 1 {
+		return &fs.PathError{Op: "sync", Path: s.opts.State, Err: errBadSyncState}
+	}
+	s.prev = state.Files
+	return nil
+}
+
+func (s *bisyncer) saveState() error {
+	data, err := json.Marshal(bisyncState{Version: 1, Files: s.next})
+	if err != nil {
+		return err
+	}
+	return AtomicWriteFile(s.fsys[SideA], s.opts.State, data, 0644)
+}
@@ -0,0 +1,128 @@
+package wfs_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestBisync(t *testing.T) {
+	local, remote := wfs.Mem(), wfs.Mem()
+	local.MkdirAll("docs", 0755)
+	wfs.WriteFile(local, "docs/a.txt", []byte("a"), 0644)
+	wfs.WriteFile(remote, "b.txt", []byte("b"), 0644)
+
+	stats, err := wfs.Bisync(local, remote, nil)
+	if err != nil {
+		t.Fatalf("Bisync failed: %v", err)
+	}
+	if stats.Created != 3 || stats.Conflicts != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	assertEntries(t, local, ".", ".wfs-bisync.json", "b.txt", "docs")
+	assertEntries(t, remote, ".", "b.txt", "docs")
+	assertContent(t, remote, "docs/a.txt", "a")
+
+	// changes on either side are copied to the other
+	later := time.Now().Add(time.Hour)
+	wfs.WriteFile(local, "docs/a.txt", []byte("a2"), 0644)
+	remote.Remove("b.txt")
+	remote.MkdirAll("new", 0755)
+	wfs.WriteFile(remote, "new/c.txt", []byte("c"), 0644)
+	wfs.Chtimes(remote, "new/c.txt", later, later)
+
+	var actions []string
+	opts := &wfs.BisyncOptions{
+		Progress: func(side wfs.BisyncSide, a wfs.SyncAction) {
+			actions = append(actions, side.String()+" "+a.Op.String()+" "+a.Path)
+		},
+	}
+	if _, err := wfs.Bisync(local, remote, opts); err != nil {
+		t.Fatalf("Bisync failed: %v", err)
+	}
+	expected := "[a delete b.txt b update docs/a.txt a create new a create new/c.txt]"
+	if got := fmt.Sprint(actions); got != expected {
+		t.Errorf("expected actions %s, got %s", expected, got)
+	}
+	assertContent(t, remote, "docs/a.txt", "a2")
+	assertContent(t, local, "new/c.txt", "c")
+	assertEntries(t, local, ".", ".wfs-bisync.json", "docs", "new")
+
+	// nothing changed since the last sync
+	actions = nil
+	if stats, err := wfs.Bisync(local, remote, opts); err != nil || len(actions) != 0 || stats.Conflicts != 0 {
+		t.Errorf("expected no actions, got %v %+v err: %v", actions, stats, err)
+	}
+
+	// a directory deleted on one side keeps the files added on the other
+	local.RemoveAll("new")
+	wfs.WriteFile(remote, "new/d.txt", []byte("d"), 0644)
+	if _, err := wfs.Bisync(local, remote, nil); err != nil {
+		t.Fatalf("Bisync failed: %v", err)
+	}
+	assertEntries(t, local, "new", "d.txt")
+	assertEntries(t, remote, "new", "d.txt")
+}
+
+func TestBisyncConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy wfs.ConflictPolicy
+		local  []string
+		remote []string
+	}{
+		{"KeepBoth", nil, []string{".wfs-bisync.json", "f.conflict.txt", "f.txt"}, []string{"f.conflict.txt", "f.txt"}},
+		{"NewestWins", wfs.PolicyNewestWins, []string{".wfs-bisync.json", "f.txt"}, []string{"f.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, remote := wfs.Mem(), wfs.Mem()
+			wfs.WriteFile(local, "f.txt", []byte("v1"), 0644)
+			if _, err := wfs.Bisync(local, remote, nil); err != nil {
+				t.Fatalf("Bisync failed: %v", err)
+			}
+			later := time.Now().Add(time.Hour)
+			wfs.WriteFile(local, "f.txt", []byte("local"), 0644)
+			wfs.WriteFile(remote, "f.txt", []byte("remote"), 0644)
+			wfs.Chtimes(remote, "f.txt", later, later)
+
+			stats, err := wfs.Bisync(local, remote, &wfs.BisyncOptions{Conflict: tt.policy})
+			if err != nil || stats.Conflicts != 1 {
+				t.Fatalf("unexpected stats %+v err: %v", stats, err)
+			}
+			assertEntries(t, local, ".", tt.local...)
+			assertEntries(t, remote, ".", tt.remote...)
+			if tt.policy == nil {
+				assertContent(t, local, "f.txt", "local")
+				assertContent(t, local, "f.conflict.txt", "remote")
+			} else {
+				assertContent(t, local, "f.txt", "remote")
+			}
+			content, _ := fs.ReadFile(local, "f.txt")
+			assertContent(t, remote, "f.txt", string(content))
+
+			// the resolution is recorded
+			if stats, err := wfs.Bisync(local, remote, &wfs.BisyncOptions{Conflict: tt.policy}); err != nil || stats.Conflicts != 0 {
+				t.Errorf("unexpected stats %+v err: %v", stats, err)
+			}
+		})
+	}
+
+	// a callback can abort the sync
+	local, remote := wfs.Mem(), wfs.Mem()
+	wfs.WriteFile(local, "f.txt", []byte("local"), 0644)
+	wfs.WriteFile(remote, "f.txt", []byte("remote"), 0644)
+	errAbort := errors.New("abort")
+	_, err := wfs.Bisync(local, remote, &wfs.BisyncOptions{
+		Conflict: func(name string, a, b fs.FileInfo) (wfs.ConflictAction, error) {
+			return 0, errAbort
+		},
+	})
+	if !errors.Is(err, errAbort) {
+		t.Errorf("expected the callback error, got %v", err)
+	}
+}
@@ -0,0 +1,421 @@
+package wfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+)
+
+// emptyHash is the SHA-256 digest of empty content, its blob always exists.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// blobStore keeps immutable blobs named by the SHA-256 digest of their
+// content in the blobs directory of a store, and the temporary copies of
+// files open for writing in its tmp directory.
+type blobStore struct {
+	store FS
+	gc    sync.RWMutex // held for writing while collecting and for reading while blobs are stored
+
+	mu    sync.Mutex
+	temps map[string]bool // the temporary copies of open files
+}
+
+func newBlobStore(store FS) (*blobStore, error) {
+	for _, dir := range []string{"blobs", "tmp", path.Dir(blobPath(emptyHash))} {
+		if err := store.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	if err := WriteFile(store, blobPath(emptyHash), nil, 0644); err != nil {
+		return nil, err
+	}
+	return &blobStore{store: store, temps: make(map[string]bool)}, nil
+}
+
+// blobPath returns the name of the blob with the hex digest hash, blobs are
+// spread over directories named by the first byte of their digest.
+func blobPath(hash string) string {
+	return "blobs/" + hash[:2] + "/" + hash
+}
+
+// link moves the complete temporary file name to the blob hash, so that
+// blobs never appear partially written.
+func (b *blobStore) link(name, hash string) error {
+	blob := blobPath(hash)
+	err := b.store.MkdirAll(path.Dir(blob), 0755)
+	if err == nil {
+		err = b.store.Rename(name, blob)
+	}
+	if err != nil {
+		b.store.Remove(name)
+	}
+	return err
+}
+
+// put stores data as a blob unless it exists and returns its digest.
+func (b *blobStore) put(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if exists, err := Exists(b.store, blobPath(hash)); err != nil || exists {
+		return hash, err
+	}
+	tmp, err := CreateTemp(b.store, "tmp", "blob*")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.Write(data)
+	if err1 := tmp.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		b.store.Remove(tmp.Name())
+		return "", err
+	}
+	return hash, b.link(tmp.Name(), hash)
+}
+
+// putFile stores the content of the temporary file tmp as a blob and returns
+// its digest and size. The file is moved to the blobs unless keep is true, in
+// which case it is copied.
+func (b *blobStore) putFile(tmp File, keep bool) (string, int64, error) {
+	info, err := tmp.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	content := io.NewSectionReader(tmp, 0, info.Size())
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", 0, err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	if exists, err := Exists(b.store, blobPath(hash)); err != nil || exists {
+		return hash, info.Size(), err
+	}
+	name := tmp.Name()
+	if keep {
+		c, err := CreateTemp(b.store, "tmp", "blob*")
+		if err != nil {
+			return "", 0, err
+		}
+		name = c.Name()
+		content.Seek(0, io.SeekStart)
+		_, err = io.Copy(c, content)
+		if err1 := c.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+		if err != nil {
+			b.store.Remove(name)
+			return "", 0, err
+		}
+	} else if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+	return hash, info.Size(), b.link(name, hash)
+}
+
+// tempCopy returns a temporary copy of the content of r positioned at its
+// start, or an empty temporary file if r is nil.
+func (b *blobStore) tempCopy(r io.Reader) (File, error) {
+	tmp, err := CreateTemp(b.store, "tmp", "file*")
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		_, err = io.Copy(tmp, r)
+		if err == nil {
+			_, err = tmp.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			tmp.Close()
+			b.store.Remove(tmp.Name())
+			return nil, err
+		}
+	}
+	b.mu.Lock()
+	b.temps[tmp.Name()] = true
+	b.mu.Unlock()
+	return tmp, nil
+}
+
+// release closes and removes the temporary copy tmp, unless it was already
+// moved to the blobs.
+func (b *blobStore) release(tmp File) {
+	tmp.Close()
+	b.store.Remove(tmp.Name())
+	b.mu.Lock()
+	delete(b.temps, tmp.Name())
+	b.mu.Unlock()
+}
+
+// collect removes the blobs that are not in refs and the temporary files of
+// files that are not open. Callers must hold gc for writing.
+func (b *blobStore) collect(refs map[string]bool) (GCStats, error) {
+	var stats GCStats
+	shards, err := fs.ReadDir(b.store, "blobs")
+	if err != nil {
+		return stats, err
+	}
+	for _, shard := range shards {
+		dir := path.Join("blobs", shard.Name())
+		blobs, err := fs.ReadDir(b.store, dir)
+		if err != nil {
+			return stats, err
+		}
+		removed := 0
+		for _, blob := range blobs {
+			if refs[blob.Name()] || blob.Name() == emptyHash {
+				continue
+			}
+			info, err := blob.Info()
+			if err != nil {
+				return stats, err
+			}
+			if err := b.store.Remove(path.Join(dir, blob.Name())); err != nil {
+				return stats, err
+			}
+			stats.Blobs++
+			stats.Bytes += info.Size()
+			removed++
+		}
+		if removed == len(blobs) {
+			b.store.Remove(dir)
+		}
+	}
+
+	temps, err := fs.ReadDir(b.store, "tmp")
+	if err != nil {
+		return stats, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, tmp := range temps {
+		if name := path.Join("tmp", tmp.Name()); !b.temps[name] {
+			if err := b.store.Remove(name); err != nil {
+				return stats, err
+			}
+		}
+	}
+	return stats, nil
+}
+
+// stagedFile is an open file reading its content from File, or writing to a
+// temporary copy of its content in File that is committed when the file is
+// synced or closed.
+type stagedFile struct {
+	File
+	blobs  *blobStore
+	name   string
+	flag   int
+	info   fs.FileInfo // reported with the size of File
+	commit func(tmp File, keep bool) error
+	mu     sync.Mutex
+	dirty  bool
+	closed bool
+}
+
+// check returns an error if the file is closed or is missing the access mode of flag.
+func (f *stagedFile) check(op string, flag int) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	switch flag {
+	case os.O_RDONLY:
+		if f.flag&os.O_WRONLY != 0 {
+			return &fs.PathError{Op: op, Path: f.name, Err: errBadFile}
+		}
+	case os.O_WRONLY:
+		if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+			return &fs.PathError{Op: op, Path: f.name, Err: errBadFile}
+		}
+		f.dirty = true
+	}
+	return nil
+}
+
+func (f *stagedFile) Name() string {
+	return f.name
+}
+
+func (f *stagedFile) Stat() (fs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("stat", -1); err != nil {
+		return nil, err
+	}
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, fixErr(err, f.name)
+	}
+	return sizedInfo{f.info, path.Base(f.name), info.Size()}, nil
+}
+
+func (f *stagedFile) Read(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read", os.O_RDONLY); err != nil {
+		return 0, err
+	}
+	n, err := f.File.Read(b)
+	return n, fixErr(err, f.name)
+}
+
+func (f *stagedFile) ReadAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read", os.O_RDONLY); err != nil {
+		return 0, err
+	}
+	n, err := f.File.ReadAt(b, off)
+	return n, fixErr(err, f.name)
+}
+
+func (f *stagedFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("seek", -1); err != nil {
+		return 0, err
+	}
+	n, err := f.File.Seek(offset, whence)
+	return n, fixErr(err, f.name)
+}
+
+func (f *stagedFile) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("write", os.O_WRONLY); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		if _, err := f.File.Seek(0, io.SeekEnd); err != nil {
+			return 0, fixErr(err, f.name)
+		}
+	}
+	n, err := f.File.Write(b)
+	return n, fixErr(err, f.name)
+}
+
+func (f *stagedFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *stagedFile) WriteAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("write", os.O_WRONLY); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		return 0, errors.New("invalid use of WriteAt on file opened with O_APPEND")
+	}
+	n, err := f.File.WriteAt(b, off)
+	return n, fixErr(err, f.name)
+}
+
+func (f *stagedFile) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("truncate", os.O_WRONLY); err != nil {
+		return err
+	}
+	return fixErr(f.File.Truncate(size), f.name)
+}
+
+func (f *stagedFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: ErrNotDir}
+}
+
+func (f *stagedFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("sync", -1); err != nil {
+		return err
+	}
+	if !f.dirty {
+		return nil
+	}
+	if err := f.commit(f.File, true); err != nil {
+		return fixErr(err, f.name)
+	}
+	f.dirty = false
+	return nil
+}
+
+func (f *stagedFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return fixErr(f.File.Close(), f.name)
+	}
+	defer f.blobs.release(f.File)
+	if !f.dirty {
+		return nil
+	}
+	return fixErr(f.commit(f.File, false), f.name)
+}
+
+// sizedInfo reports the size of the content of files.
+type sizedInfo struct {
+	fs.FileInfo
+	name string
+	size int64
+}
+
+func (i sizedInfo) Name() string { return i.name }
+func (i sizedInfo) Size() int64  { return i.size }
+
+// sizedEntry reports the size of the content of the file name of a store.
+type sizedEntry struct {
+	fs.DirEntry
+	name string
+	size func(name string) (int64, error)
+}
+
+func (e sizedEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil || !info.Mode().IsRegular() {
+		return info, err
+	}
+	size, err := e.size(e.name)
+	if err != nil {
+		return nil, err
+	}
+	return sizedInfo{info, info.Name(), size}, nil
+}
+
+func (e sizedEntry) String() string { return fs.FormatDirEntry(e) }
+
+// sizedDir is the directory dir of a store whose entries report the size of
+// the content of files.
+type sizedDir struct {
+	File
+	name string
+	dir  string
+	size func(name string) (int64, error)
+}
+
+func (d *sizedDir) Name() string { return d.name }
+
+func (d *sizedDir) Stat() (fs.FileInfo, error) {
+	info, err := d.File.Stat()
+	if err != nil {
+		return nil, fixErr(err, d.name)
+	}
+	return sizedInfo{info, path.Base(d.name), info.Size()}, nil
+}
+
+func (d *sizedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := readDir(d.File, n)
+	for i, e := range entries {
+		entries[i] = sizedEntry{e, path.Join(d.dir, e.Name()), d.size}
+	}
+	return entries, fixErr(err, d.name)
+}
@@ -0,0 +1,649 @@
+// Package bolt provides a writable file system stored in a bbolt database.
+//
+// Every operation runs in a bbolt transaction, so the file system is never
+// left partially modified by a crash, and a single database file holds the
+// whole tree without touching the layout of the host file system.
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eriicafes/wfs"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	pathsBucket  = []byte("paths")  // parent directory, NUL and name to inode
+	nodesBucket  = []byte("nodes")  // inode to node
+	chunksBucket = []byte("chunks") // inode and chunk index to contents
+)
+
+const (
+	rootIno   = 1
+	chunkSize = 64 << 10
+)
+
+var errBadFile = errors.New("bad file descriptor")
+
+// Sys is the backend-specific metadata of files in an [FS], see [wfs.SysInfo].
+type Sys struct {
+	// Inode uniquely identifies the file within its file system.
+	Inode uint64
+	// Uid and Gid are the owner of the file as set by Chown.
+	Uid, Gid int
+}
+
+// FS is a writable file system stored in a bbolt database.
+//
+// Paths are keys mapped to inodes, and file contents are stored in chunks of
+// 64 KiB so that writes only rewrite the chunks they change. Writes are
+// committed when they return, Sync does nothing more. A file removed while it
+// is open can no longer be read or written through its open handles.
+type FS struct {
+	db *bbolt.DB
+}
+
+// Open opens the file system stored in the bbolt database at path, creating
+// the database with mode 0600 if necessary.
+func Open(path string) (*FS, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	f, err := New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// New returns the file system stored in db, creating its buckets and root
+// directory if necessary. Closing the FS closes db.
+func New(db *bbolt.DB) (*FS, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{pathsBucket, nodesBucket, chunksBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		t := newTxn(tx)
+		if _, ok := t.node(rootIno); ok {
+			return nil
+		}
+		ino, err := t.nodes.NextSequence()
+		if err != nil {
+			return err
+		}
+		return t.putNode(ino, node{mode: fs.ModeDir | 0777, modTime: time.Now()})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &FS{db: db}, nil
+}
+
+// Close closes the database.
+func (f *FS) Close() error {
+	return f.db.Close()
+}
+
+// node is the metadata of a file or directory.
+type node struct {
+	mode     fs.FileMode
+	modTime  time.Time
+	size     int64
+	uid, gid int
+}
+
+func (n node) encode() []byte {
+	b := make([]byte, 36)
+	binary.BigEndian.PutUint32(b[0:], uint32(n.mode))
+	binary.BigEndian.PutUint64(b[4:], uint64(n.modTime.UnixNano()))
+	binary.BigEndian.PutUint64(b[12:], uint64(n.size))
+	binary.BigEndian.PutUint64(b[20:], uint64(int64(n.uid)))
+	binary.BigEndian.PutUint64(b[28:], uint64(int64(n.gid)))
+	return b
+}
+
+func decodeNode(b []byte) node {
+	return node{
+		mode:    fs.FileMode(binary.BigEndian.Uint32(b[0:])),
+		modTime: time.Unix(0, int64(binary.BigEndian.Uint64(b[4:]))),
+		size:    int64(binary.BigEndian.Uint64(b[12:])),
+		uid:     int(int64(binary.BigEndian.Uint64(b[20:]))),
+		gid:     int(int64(binary.BigEndian.Uint64(b[28:]))),
+	}
+}
+
+func (n node) info(name string, ino uint64) fs.FileInfo {
+	return &fileInfo{name: name, node: n, sys: &Sys{Inode: ino, Uid: n.uid, Gid: n.gid}}
+}
+
+// fileInfo is a point-in-time description of a node.
+type fileInfo struct {
+	name string
+	node node
+	sys  *Sys
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.node.size }
+func (i *fileInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i *fileInfo) ModTime() time.Time { return i.node.modTime }
+func (i *fileInfo) IsDir() bool        { return i.node.mode.IsDir() }
+func (i *fileInfo) Sys() any           { return i.sys }
+
+// pathKey returns the key of name in the paths bucket, keys of the entries of
+// a directory share the prefix returned by dirPrefix.
+func pathKey(name string) []byte {
+	return append(dirPrefix(path.Dir(name)), path.Base(name)...)
+}
+
+func dirPrefix(dir string) []byte {
+	return []byte(dir + "\x00")
+}
+
+func inoKey(ino uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, ino)
+}
+
+func chunkKey(ino uint64, i int64) []byte {
+	return binary.BigEndian.AppendUint64(inoKey(ino), uint64(i))
+}
+
+// txn provides the operations on the tree within a transaction.
+type txn struct {
+	paths, nodes, chunks *bbolt.Bucket
+}
+
+func newTxn(tx *bbolt.Tx) txn {
+	return txn{tx.Bucket(pathsBucket), tx.Bucket(nodesBucket), tx.Bucket(chunksBucket)}
+}
+
+func (t txn) node(ino uint64) (node, bool) {
+	b := t.nodes.Get(inoKey(ino))
+	if b == nil {
+		return node{}, false
+	}
+	return decodeNode(b), true
+}
+
+func (t txn) putNode(ino uint64, n node) error {
+	return t.nodes.Put(inoKey(ino), n.encode())
+}
+
+// lookup returns the inode and node of name.
+func (t txn) lookup(name string) (uint64, node, error) {
+	if name == "." {
+		n, _ := t.node(rootIno)
+		return rootIno, n, nil
+	}
+	v := t.paths.Get(pathKey(name))
+	if v == nil {
+		if _, parent, err := t.lookup(path.Dir(name)); err != nil {
+			return 0, node{}, err
+		} else if !parent.mode.IsDir() {
+			return 0, node{}, wfs.ErrNotDir
+		}
+		return 0, node{}, fs.ErrNotExist
+	}
+	ino := binary.BigEndian.Uint64(v)
+	n, ok := t.node(ino)
+	if !ok {
+		return 0, node{}, fs.ErrNotExist
+	}
+	return ino, n, nil
+}
+
+// lookupDir returns the inode of the directory name.
+func (t txn) lookupDir(name string) (uint64, error) {
+	ino, n, err := t.lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	if !n.mode.IsDir() {
+		return 0, wfs.ErrNotDir
+	}
+	return ino, nil
+}
+
+// touch updates the modification time of the directory name.
+func (t txn) touch(name string) error {
+	ino, n, err := t.lookup(name)
+	if err != nil {
+		return err
+	}
+	n.modTime = time.Now()
+	return t.putNode(ino, n)
+}
+
+// create creates a node named name, whose parent directory must exist.
+func (t txn) create(name string, mode fs.FileMode) (uint64, node, error) {
+	if _, err := t.lookupDir(path.Dir(name)); err != nil {
+		return 0, node{}, err
+	}
+	ino, err := t.nodes.NextSequence()
+	if err != nil {
+		return 0, node{}, err
+	}
+	n := node{mode: mode, modTime: time.Now()}
+	if err := t.putNode(ino, n); err != nil {
+		return 0, node{}, err
+	}
+	if err := t.paths.Put(pathKey(name), inoKey(ino)); err != nil {
+		return 0, node{}, err
+	}
+	return ino, n, t.touch(path.Dir(name))
+}
+
+// keys returns the keys starting with prefix in b.
+func keys(b *bbolt.Bucket, prefix []byte) [][]byte {
+	var keys [][]byte
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, slices.Clone(k))
+	}
+	return keys
+}
+
+// descendants returns the keys of the entries in the tree below the
+// directory name.
+func (t txn) descendants(name string) [][]byte {
+	return append(keys(t.paths, dirPrefix(name)), keys(t.paths, []byte(name+"/"))...)
+}
+
+// entries returns the entries of the directory name sorted by name.
+func (t txn) entries(name string) []fs.DirEntry {
+	var entries []fs.DirEntry
+	prefix := dirPrefix(name)
+	c := t.paths.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		ino := binary.BigEndian.Uint64(v)
+		if n, ok := t.node(ino); ok {
+			entries = append(entries, fs.FileInfoToDirEntry(n.info(string(k[len(prefix):]), ino)))
+		}
+	}
+	return entries
+}
+
+// removeNode removes a node and its contents.
+func (t txn) removeNode(ino uint64) error {
+	for _, k := range keys(t.chunks, inoKey(ino)) {
+		if err := t.chunks.Delete(k); err != nil {
+			return err
+		}
+	}
+	return t.nodes.Delete(inoKey(ino))
+}
+
+// unlink removes the entry key and its node.
+func (t txn) unlink(key []byte) error {
+	if v := t.paths.Get(key); v != nil {
+		if err := t.removeNode(binary.BigEndian.Uint64(v)); err != nil {
+			return err
+		}
+	}
+	return t.paths.Delete(key)
+}
+
+func (t txn) readAt(ino uint64, n node, b []byte, off int64) int {
+	if off >= n.size {
+		return 0
+	}
+	b = b[:min(int64(len(b)), n.size-off)]
+	for done := 0; done < len(b); {
+		pos := off + int64(done)
+		i, start := pos/chunkSize, pos%chunkSize
+		part := b[done:min(len(b), done+int(chunkSize-start))]
+		chunk := t.chunks.Get(chunkKey(ino, i))
+		copied := 0
+		if start < int64(len(chunk)) {
+			copied = copy(part, chunk[start:])
+		}
+		// holes read as zeros
+		clear(part[copied:])
+		done += len(part)
+	}
+	return len(b)
+}
+
+func (t txn) writeAt(ino uint64, n *node, b []byte, off int64) error {
+	for done := 0; done < len(b); {
+		pos := off + int64(done)
+		i, start := pos/chunkSize, pos%chunkSize
+		part := b[done:min(len(b), done+int(chunkSize-start))]
+		key := chunkKey(ino, i)
+		old := t.chunks.Get(key)
+		chunk := make([]byte, max(len(old), int(start)+len(part)))
+		copy(chunk, old)
+		copy(chunk[start:], part)
+		if err := t.chunks.Put(key, chunk); err != nil {
+			return err
+		}
+		done += len(part)
+	}
+	n.size = max(n.size, off+int64(len(b)))
+	n.modTime = time.Now()
+	return t.putNode(ino, *n)
+}
+
+func (t txn) truncate(ino uint64, n *node, size int64) error {
+	if size < n.size {
+		last := size / chunkSize
+		for _, k := range keys(t.chunks, inoKey(ino)) {
+			if i := int64(binary.BigEndian.Uint64(k[8:])); i > last || i == last && size%chunkSize == 0 {
+				if err := t.chunks.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		key := chunkKey(ino, last)
+		if chunk := t.chunks.Get(key); int64(len(chunk)) > size%chunkSize {
+			if err := t.chunks.Put(key, slices.Clone(chunk[:size%chunkSize])); err != nil {
+				return err
+			}
+		}
+	}
+	n.size = size
+	n.modTime = time.Now()
+	return t.putNode(ino, *n)
+}
+
+// update runs fn in a read-write transaction.
+func (f *FS) update(fn func(t txn) error) error {
+	return f.db.Update(func(tx *bbolt.Tx) error { return fn(newTxn(tx)) })
+}
+
+// view runs fn in a read-only transaction.
+func (f *FS) view(fn func(t txn) error) error {
+	return f.db.View(func(tx *bbolt.Tx) error { return fn(newTxn(tx)) })
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for FS.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	var info fs.FileInfo
+	err := f.view(func(t txn) error {
+		ino, n, err := t.lookup(name)
+		info = n.info(path.Base(name), ino)
+		return err
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// ReadDir implements [fs.ReadDirFS] for FS.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	var entries []fs.DirEntry
+	err := f.view(func(t txn) error {
+		if _, err := t.lookupDir(name); err != nil {
+			return err
+		}
+		entries = t.entries(name)
+		return nil
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+func (f *FS) OpenFile(name string, flag int, perm fs.FileMode) (wfs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	var ino uint64
+	var n node
+	op := f.view
+	if flag&(os.O_CREATE|os.O_TRUNC) != 0 {
+		op = f.update
+	}
+	err := op(func(t txn) error {
+		var err error
+		ino, n, err = t.lookup(name)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && flag&os.O_CREATE != 0:
+			ino, n, err = t.create(name, perm&fs.ModePerm)
+			return err
+		case err != nil:
+			return err
+		case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+			return fs.ErrExist
+		case n.mode.IsDir() && flag&(os.O_WRONLY|os.O_RDWR) != 0:
+			return wfs.ErrIsDir
+		case flag&os.O_TRUNC != 0 && !n.mode.IsDir():
+			return t.truncate(ino, &n, 0)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &file{fs: f, ino: ino, name: name, flag: flag, dir: n.mode.IsDir()}, nil
+}
+
+func (f *FS) Rename(oldpath, newpath string) error {
+	if !fs.ValidPath(oldpath) || !fs.ValidPath(newpath) || oldpath == "." || newpath == "." {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	err := f.update(func(t txn) error {
+		ino, n, err := t.lookup(oldpath)
+		if err != nil {
+			return err
+		}
+		if _, err := t.lookupDir(path.Dir(newpath)); err != nil {
+			return err
+		}
+		if oldpath == newpath {
+			return nil
+		}
+		// a directory cannot be moved into itself
+		if n.mode.IsDir() && strings.HasPrefix(newpath, oldpath+"/") {
+			return fs.ErrInvalid
+		}
+		if _, target, err := t.lookup(newpath); err == nil {
+			if target.mode.IsDir() {
+				return fs.ErrExist
+			}
+			if n.mode.IsDir() {
+				return wfs.ErrNotDir
+			}
+			if err := t.unlink(pathKey(newpath)); err != nil {
+				return err
+			}
+		}
+		if n.mode.IsDir() {
+			// the keys of the entries below a directory start with its path
+			for _, k := range t.descendants(oldpath) {
+				v := slices.Clone(t.paths.Get(k))
+				if err := t.paths.Delete(k); err != nil {
+					return err
+				}
+				if err := t.paths.Put(append([]byte(newpath), k[len(oldpath):]...), v); err != nil {
+					return err
+				}
+			}
+		}
+		if err := t.paths.Delete(pathKey(oldpath)); err != nil {
+			return err
+		}
+		if err := t.paths.Put(pathKey(newpath), inoKey(ino)); err != nil {
+			return err
+		}
+		if err := t.touch(path.Dir(oldpath)); err != nil {
+			return err
+		}
+		return t.touch(path.Dir(newpath))
+	})
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	return nil
+}
+
+func (f *FS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	err := f.update(func(t txn) error {
+		_, n, err := t.lookup(name)
+		if err != nil {
+			return err
+		}
+		if n.mode.IsDir() && len(keys(t.paths, dirPrefix(name))) > 0 {
+			return wfs.ErrNotEmpty
+		}
+		if err := t.unlink(pathKey(name)); err != nil {
+			return err
+		}
+		return t.touch(path.Dir(name))
+	})
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *FS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "RemoveAll", Path: name, Err: fs.ErrInvalid}
+	}
+	err := f.update(func(t txn) error {
+		_, n, err := t.lookup(name)
+		if err != nil {
+			// nothing to remove
+			return nil
+		}
+		if n.mode.IsDir() {
+			for _, k := range t.descendants(name) {
+				if err := t.unlink(k); err != nil {
+					return err
+				}
+			}
+		}
+		if err := t.unlink(pathKey(name)); err != nil {
+			return err
+		}
+		return t.touch(path.Dir(name))
+	})
+	if err != nil {
+		return &fs.PathError{Op: "RemoveAll", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *FS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	err := f.update(func(t txn) error {
+		if _, _, err := t.lookup(name); err == nil {
+			return fs.ErrExist
+		}
+		_, _, err := t.create(name, fs.ModeDir|perm&fs.ModePerm)
+		return err
+	})
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *FS) MkdirAll(path string, perm fs.FileMode) error {
+	if !fs.ValidPath(path) {
+		return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrInvalid}
+	}
+	if path == "." {
+		return nil
+	}
+	err := f.update(func(t txn) error {
+		elems := strings.Split(path, "/")
+		for i := range elems {
+			name := strings.Join(elems[:i+1], "/")
+			_, n, err := t.lookup(name)
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				if _, _, err := t.create(name, fs.ModeDir|perm&fs.ModePerm); err != nil {
+					return err
+				}
+			case err != nil:
+				return err
+			case !n.mode.IsDir():
+				return wfs.ErrNotDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: path, Err: err}
+	}
+	return nil
+}
+
+// meta changes the node of the named file.
+func (f *FS) meta(op, name string, change func(n *node)) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	err := f.update(func(t txn) error {
+		ino, n, err := t.lookup(name)
+		if err != nil {
+			return err
+		}
+		change(&n)
+		return t.putNode(ino, n)
+	})
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
+
+// Chmod implements [wfs.MetaFS] for FS.
+func (f *FS) Chmod(name string, mode fs.FileMode) error {
+	const mask = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+	return f.meta("chmod", name, func(n *node) {
+		n.mode = n.mode&^mask | mode&mask
+	})
+}
+
+// Chown implements [wfs.MetaFS] for FS.
+func (f *FS) Chown(name string, uid, gid int) error {
+	return f.meta("chown", name, func(n *node) {
+		if uid != -1 {
+			n.uid = uid
+		}
+		if gid != -1 {
+			n.gid = gid
+		}
+	})
+}
+
+// Chtimes implements [wfs.MetaFS] for FS.
+func (f *FS) Chtimes(name string, atime, mtime time.Time) error {
+	return f.meta("chtimes", name, func(n *node) {
+		if !mtime.IsZero() {
+			n.modTime = mtime
+		}
+	})
+}
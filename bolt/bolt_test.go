@@ -0,0 +1,225 @@
+package bolt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/bolt"
+	"github.com/eriicafes/wfs/wfsbench"
+	"github.com/eriicafes/wfs/wfstest"
+)
+
+func openFS(t testing.TB) (*bolt.FS, string) {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "fs.db")
+	fsys, err := bolt.Open(name)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return fsys, name
+}
+
+func assertContent(t *testing.T, fsys fs.FS, name, expected string) {
+	t.Helper()
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil || string(data) != expected {
+		t.Errorf("expected %q to contain %q, got %q err: %v", name, expected, data, err)
+	}
+}
+
+func assertEntries(t *testing.T, fsys fs.FS, dir string, expected ...string) {
+	t.Helper()
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		t.Fatalf("failed to read %q: %v", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %q to contain %v, got %v", dir, expected, names)
+	}
+}
+
+func TestFS(t *testing.T) {
+	fsys, _ := openFS(t)
+	defer fsys.Close()
+
+	if err := fsys.MkdirAll("a/b/c", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "a/b/file.txt", []byte("hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "top.txt", []byte("top"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := fstest.TestFS(fsys, "a/b/file.txt", "a/b/c", "top.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fsys.OpenFile("top.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+	if _, err := fsys.OpenFile("missing/file", os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if _, err := fsys.OpenFile("top.txt/file", os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("expected ErrNotDir, got %v", err)
+	}
+	if _, err := fsys.OpenFile("a", os.O_WRONLY, 0); !errors.Is(err, wfs.ErrIsDir) {
+		t.Errorf("expected ErrIsDir, got %v", err)
+	}
+	if err := fsys.Remove("a/b"); !errors.Is(err, wfs.ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty, got %v", err)
+	}
+	if err := fsys.Mkdir("a", 0755); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+	if _, err := fsys.Stat("../escape"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got %v", err)
+	}
+
+	// renaming a directory moves its tree
+	if err := fsys.Rename("a/b", "moved"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	assertEntries(t, fsys, ".", "a", "moved", "top.txt")
+	assertEntries(t, fsys, "moved", "c", "file.txt")
+	assertContent(t, fsys, "moved/file.txt", "hello")
+	if err := fsys.Rename("moved", "moved/c/inside"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected ErrInvalid moving a directory into itself, got %v", err)
+	}
+	if err := fsys.Rename("top.txt", "moved/file.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	assertContent(t, fsys, "moved/file.txt", "top")
+
+	if err := fsys.RemoveAll("moved"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if err := fsys.RemoveAll("missing/file"); err != nil {
+		t.Errorf("expected RemoveAll of a missing path to succeed, got %v", err)
+	}
+	assertEntries(t, fsys, ".", "a")
+	assertEntries(t, fsys, "a")
+}
+
+func TestFile(t *testing.T) {
+	fsys, name := openFS(t)
+
+	// contents span several chunks
+	large := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	f, err := fsys.OpenFile("large", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if _, err := f.Write(large); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := f.WriteAt([]byte("XYZ"), 65535); err != nil {
+		t.Fatalf("WriteAt failed: %v", err)
+	}
+	copy(large[65535:], "XYZ")
+	buf := make([]byte, 10)
+	if n, err := f.ReadAt(buf, 65530); err != nil || !bytes.Equal(buf[:n], large[65530:65540]) {
+		t.Errorf("expected %q, got %q err: %v", large[65530:65540], buf[:n], err)
+	}
+	if err := f.Truncate(70000); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	// extending a truncated file reads zeros
+	if err := f.Truncate(70010); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	if n, err := f.ReadAt(buf, 70000); err != nil || !bytes.Equal(buf[:n], make([]byte, 10)) {
+		t.Errorf("expected zeros, got %q err: %v", buf[:n], err)
+	}
+	if off, err := f.Seek(0, io.SeekEnd); err != nil || off != 70010 {
+		t.Errorf("expected offset 70010, got %d err: %v", off, err)
+	}
+	f.Close()
+	if _, err := f.Write([]byte("x")); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	f, _ = fsys.OpenFile("large", os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte("end"))
+	f.Close()
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := wfs.Chtimes(fsys, "large", time.Time{}, mtime); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	if err := wfs.Chmod(fsys, "large", 0600); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	fsys.Close()
+
+	// the file system persists in the database
+	fsys, err = bolt.Open(name)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer fsys.Close()
+	data, err := fs.ReadFile(fsys, "large")
+	if err != nil || len(data) != 70013 || !bytes.Equal(data[:70000], large[:70000]) || string(data[70010:]) != "end" {
+		t.Errorf("unexpected contents of size %d err: %v", len(data), err)
+	}
+	info, err := fsys.Stat("large")
+	if err != nil || info.Mode() != 0600 || !info.ModTime().Equal(mtime) {
+		t.Errorf("expected mode 0600 and mtime %v, got %v %v err: %v", mtime, info.Mode(), info.ModTime(), err)
+	}
+	if sys, ok := wfs.SysInfo[*bolt.Sys](info); !ok || sys.Inode == 0 {
+		t.Errorf("expected bolt.Sys, got %v", info.Sys())
+	}
+}
+
+func TestOpenURL(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "fs.db")
+	if _, err := wfs.OpenURL(t.Context(), "bolt://host/fs.db"); err == nil {
+		t.Errorf("expected a URL with a host to fail")
+	}
+	fsys, err := wfs.OpenURL(t.Context(), "bolt:///"+strings.TrimPrefix(filepath.ToSlash(name), "/"))
+	if err != nil {
+		t.Fatalf("OpenURL failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "file.txt", []byte("hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := fsys.(io.Closer).Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	reopened, err := bolt.Open(name)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer reopened.Close()
+	assertContent(t, reopened, "file.txt", "hello")
+}
+
+func TestConformance(t *testing.T) {
+	wfstest.TestFS(t, func() wfs.FS {
+		fsys, _ := openFS(t)
+		t.Cleanup(func() { fsys.Close() })
+		return fsys
+	}, wfstest.Concurrent())
+}
+
+func BenchmarkBolt(b *testing.B) {
+	wfsbench.Run(b, func() wfs.FS {
+		fsys, _ := openFS(b)
+		b.Cleanup(func() { fsys.Close() })
+		return fsys
+	})
+}
@@ -0,0 +1,279 @@
+package bolt
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/eriicafes/wfs"
+)
+
+// file is an open handle to a node of an FS.
+type file struct {
+	fs   *FS
+	ino  uint64
+	name string
+	flag int
+	dir  bool
+
+	mu      sync.Mutex
+	offset  int64
+	closed  bool
+	entries []fs.DirEntry // directory listing captured on the first ReadDir
+}
+
+// check returns an error if the file is closed or is missing the access mode of flag.
+func (f *file) check(op string, flag int) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.dir && op != "readdir" && op != "seek" {
+		return &fs.PathError{Op: op, Path: f.name, Err: wfs.ErrIsDir}
+	}
+	switch flag {
+	case os.O_RDONLY:
+		if f.flag&os.O_WRONLY != 0 {
+			return &fs.PathError{Op: op, Path: f.name, Err: errBadFile}
+		}
+	case os.O_WRONLY:
+		if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+			return &fs.PathError{Op: op, Path: f.name, Err: errBadFile}
+		}
+	}
+	return nil
+}
+
+// node returns the node of the file within t.
+func (f *file) node(op string, t txn) (node, error) {
+	n, ok := t.node(f.ino)
+	if !ok {
+		return node{}, &fs.PathError{Op: op, Path: f.name, Err: fs.ErrNotExist}
+	}
+	return n, nil
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	var info fs.FileInfo
+	err := f.fs.view(func(t txn) error {
+		n, err := f.node("stat", t)
+		info = n.info(path.Base(f.name), f.ino)
+		return err
+	})
+	return info, err
+}
+
+func (f *file) Read(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read", os.O_RDONLY); err != nil {
+		return 0, err
+	}
+	n, err := f.readAt(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *file) ReadAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read", os.O_RDONLY); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.name, Err: errors.New("negative offset")}
+	}
+	n, err := f.readAt(b, off)
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *file) readAt(b []byte, off int64) (int, error) {
+	var read int
+	err := f.fs.view(func(t txn) error {
+		n, err := f.node("read", t)
+		if err != nil {
+			return err
+		}
+		if off >= n.size && len(b) > 0 {
+			return io.EOF
+		}
+		read = t.readAt(f.ino, n, b, off)
+		return nil
+	})
+	return read, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("seek", -1); err != nil {
+		return 0, err
+	}
+	if f.dir {
+		if offset != 0 || whence != io.SeekStart {
+			return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+		}
+		f.entries = nil
+		return 0, nil
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		err := f.fs.view(func(t txn) error {
+			n, err := f.node("seek", t)
+			offset += n.size
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+	case io.SeekStart:
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *file) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("write", os.O_WRONLY); err != nil {
+		return 0, err
+	}
+	err := f.fs.update(func(t txn) error {
+		n, err := f.node("write", t)
+		if err != nil {
+			return err
+		}
+		if f.flag&os.O_APPEND != 0 {
+			f.offset = n.size
+		}
+		return t.writeAt(f.ino, &n, b, f.offset)
+	})
+	if err != nil {
+		return 0, err
+	}
+	f.offset += int64(len(b))
+	return len(b), nil
+}
+
+func (f *file) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *file) WriteAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("write", os.O_WRONLY); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		return 0, errors.New("invalid use of WriteAt on file opened with O_APPEND")
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "writeat", Path: f.name, Err: errors.New("negative offset")}
+	}
+	err := f.fs.update(func(t txn) error {
+		n, err := f.node("write", t)
+		if err != nil {
+			return err
+		}
+		return t.writeAt(f.ino, &n, b, off)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (f *file) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.dir || f.flag&(os.O_WRONLY|os.O_RDWR) == 0 || size < 0 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
+	}
+	return f.fs.update(func(t txn) error {
+		n, err := f.node("truncate", t)
+		if err != nil {
+			return err
+		}
+		return t.truncate(f.ino, &n, size)
+	})
+}
+
+// Sync does nothing as writes are committed when they return.
+func (f *file) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "sync", Path: f.name, Err: fs.ErrClosed}
+	}
+	return nil
+}
+
+// ReadDir implements [fs.ReadDirFile] for directories.
+// The listing is captured on the first call, so the directory may be
+// modified while it is being read.
+func (f *file) ReadDir(count int) ([]fs.DirEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("readdir", -1); err != nil {
+		return nil, err
+	}
+	if !f.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: wfs.ErrNotDir}
+	}
+	if f.entries == nil {
+		err := f.fs.view(func(t txn) error {
+			if _, err := f.node("readdir", t); err != nil {
+				return err
+			}
+			f.entries = t.entries(f.name)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	entries := f.entries
+	if count > 0 && len(entries) > count {
+		entries = entries[:count]
+	}
+	f.entries = f.entries[len(entries):]
+	if count > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	return entries, nil
+}
+
+func (f *file) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,12 @@
+module github.com/eriicafes/wfs/bolt
+
+go 1.24.0
+
+replace github.com/eriicafes/wfs => ../
+
+require (
+	github.com/eriicafes/wfs v1.0.0
+	go.etcd.io/bbolt v1.4.3
+)
+
+require golang.org/x/sys v0.40.0 // indirect
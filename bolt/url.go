@@ -0,0 +1,27 @@
+package bolt
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/eriicafes/wfs"
+)
+
+func init() {
+	wfs.RegisterScheme("bolt", openURL)
+}
+
+// openURL opens URLs of the form "bolt:///path/to/db" for [wfs.OpenURL]
+// with [Open]. The returned file system must be closed by asserting it to
+// [io.Closer].
+func openURL(ctx context.Context, u *url.URL) (wfs.FS, error) {
+	name := u.Path
+	if u.Opaque != "" {
+		name = u.Opaque
+	}
+	if u.Host != "" || name == "" {
+		return nil, errors.New("bolt: URL must be of the form bolt:///path/to/db")
+	}
+	return Open(name)
+}
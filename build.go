@@ -0,0 +1,415 @@
+package wfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config describes a file system as a backend and a stack of wrappers, it
+// is usually decoded from JSON:
+//
+//	{
+//		"backend": "file:///srv/data",
+//		"wrappers": [
+//			{"type": "sub", "options": {"dir": "public"}},
+//			{"type": "timeout", "options": {"timeout": "5s"}},
+//			{"type": "logging"}
+//		]
+//	}
+type Config struct {
+	// Backend is the URL of the backend opened with [OpenURL].
+	Backend string `json:"backend"`
+
+	// Wrappers are applied to the backend in order, so the last wrapper
+	// is the first to handle operations.
+	Wrappers []WrapperConfig `json:"wrappers,omitempty"`
+}
+
+// WrapperConfig describes a wrapper of a [Config].
+type WrapperConfig struct {
+	// Type is the name of the wrapper registered with [RegisterWrapper].
+	Type string `json:"type"`
+
+	// Options are the options of the wrapper as a JSON object.
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// Wrapper wraps fsys with the options of a [WrapperConfig], see
+// [RegisterWrapper]. options is nil if the wrapper has no options.
+type Wrapper func(fsys FS, options json.RawMessage) (FS, error)
+
+var (
+	wrappersMu sync.RWMutex
+	wrappers   = map[string]Wrapper{
+		"filter":     wrapFilter,
+		"latency":    wrapLatency,
+		"logging":    wrapLogging,
+		"readonly":   wrapReadOnly,
+		"sub":        wrapSub,
+		"timeout":    wrapTimeout,
+		"tombstones": wrapTombstones,
+		"trash":      wrapTrash,
+	}
+)
+
+// RegisterWrapper makes a wrapper available to [Build] for the wrappers of
+// a [Config] with the type name, such as wrappers for tracing or quotas
+// specific to an application. If RegisterWrapper is called twice for the
+// same name or if wrapper is nil, it panics.
+func RegisterWrapper(name string, wrapper Wrapper) {
+	wrappersMu.Lock()
+	defer wrappersMu.Unlock()
+	if wrapper == nil {
+		panic("wfs: RegisterWrapper wrapper is nil")
+	}
+	if _, dup := wrappers[name]; dup {
+		panic("wfs: RegisterWrapper called twice for wrapper " + name)
+	}
+	wrappers[name] = wrapper
+}
+
+// Wrappers returns a sorted list of the wrappers registered with
+// [RegisterWrapper].
+func Wrappers() []string {
+	wrappersMu.RLock()
+	defer wrappersMu.RUnlock()
+	list := make([]string, 0, len(wrappers))
+	for name := range wrappers {
+		list = append(list, name)
+	}
+	slices.Sort(list)
+	return list
+}
+
+// ReloadFS is a file system built from a [Config] whose configuration can
+// be changed while it is in use, see [Build].
+type ReloadFS interface {
+	FS
+
+	// Reload builds the file system described by config and atomically
+	// replaces the current one. Operations started before Reload returns
+	// may use either file system, and files keep using the file system
+	// they were opened with. The backend is reused if its URL did not
+	// change. If config is invalid, the current file system is kept and
+	// an error is returned.
+	Reload(ctx context.Context, config Config) error
+
+	// Config returns the configuration of the current file system.
+	Config() Config
+
+	// Close closes the backends opened by Build and Reload that implement
+	// [io.Closer].
+	Close() error
+}
+
+// Build opens the backend of config with [OpenURL] and applies its wrappers,
+// returning a stable file system handle whose configuration can be changed
+// with Reload, so that wrappers such as logging can be enabled without
+// restarting the application.
+//
+// The built in wrappers and their options are:
+//
+//   - "filter" with "allow" and "deny" lists of patterns, see [Filter]
+//   - "latency" with a "latency" and a "jitter" duration, see [WithLatency]
+//     and [UniformLatency]
+//   - "logging" logging to [slog.Default], see [WithLogging]
+//   - "readonly", see [ReadOnly]
+//   - "sub" with a "dir", see [Sub]
+//   - "timeout" with a "timeout" duration, see [WithTimeout]
+//   - "tombstones", see [WithTombstones]
+//   - "trash" with a "dir", see [WithTrash]
+//
+// Durations are strings accepted by [time.ParseDuration]. Other wrappers
+// are added with [RegisterWrapper].
+func Build(ctx context.Context, config Config) (ReloadFS, error) {
+	f := &reloadFs{}
+	if err := f.Reload(ctx, config); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// reloadState is a file system built from a configuration.
+type reloadState struct {
+	config  Config
+	backend FS
+	fsys    FS
+}
+
+type reloadFs struct {
+	state atomic.Pointer[reloadState]
+
+	mu       sync.Mutex // serializes Reload and Close
+	backends []FS       // every backend opened, to be closed
+}
+
+func (f *reloadFs) Reload(ctx context.Context, config Config) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cur := f.state.Load()
+	var backend FS
+	if cur != nil && cur.config.Backend == config.Backend {
+		backend = cur.backend
+	} else {
+		var err error
+		if backend, err = OpenURL(ctx, config.Backend); err != nil {
+			return err
+		}
+	}
+	fsys, err := wrap(backend, config.Wrappers)
+	if err != nil {
+		if cur == nil || backend != cur.backend {
+			closeFS(backend)
+		}
+		return err
+	}
+	if cur == nil || backend != cur.backend {
+		f.backends = append(f.backends, backend)
+	}
+	config.Wrappers = slices.Clone(config.Wrappers)
+	f.state.Store(&reloadState{config: config, backend: backend, fsys: fsys})
+	return nil
+}
+
+// wrap applies the wrappers of a configuration to fsys.
+func wrap(fsys FS, configs []WrapperConfig) (FS, error) {
+	for _, c := range configs {
+		wrappersMu.RLock()
+		wrapper, ok := wrappers[c.Type]
+		wrappersMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("wfs: unknown wrapper %q (forgotten import?)", c.Type)
+		}
+		var err error
+		if fsys, err = wrapper(fsys, c.Options); err != nil {
+			return nil, fmt.Errorf("wfs: wrapper %q: %w", c.Type, err)
+		}
+	}
+	return fsys, nil
+}
+
+// closeFS closes fsys if it implements [io.Closer].
+func closeFS(fsys FS) error {
+	if c, ok := fsys.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (f *reloadFs) Config() Config {
+	config := f.state.Load().config
+	config.Wrappers = slices.Clone(config.Wrappers)
+	return config
+}
+
+func (f *reloadFs) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	for _, backend := range f.backends {
+		errs = append(errs, closeFS(backend))
+	}
+	f.backends = nil
+	return errors.Join(errs...)
+}
+
+// fsys returns the current file system.
+func (f *reloadFs) fsys() FS {
+	return f.state.Load().fsys
+}
+
+func (f *reloadFs) Open(name string) (fs.File, error) {
+	return f.fsys().Open(name)
+}
+
+// Stat implements [fs.StatFS] for reloadFs.
+func (f *reloadFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys(), name)
+}
+
+// ReadDir implements [fs.ReadDirFS] for reloadFs.
+func (f *reloadFs) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(f.fsys(), name)
+}
+
+func (f *reloadFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return f.fsys().OpenFile(name, flag, perm)
+}
+
+func (f *reloadFs) Rename(oldpath, newpath string) error {
+	return f.fsys().Rename(oldpath, newpath)
+}
+
+func (f *reloadFs) Remove(name string) error {
+	return f.fsys().Remove(name)
+}
+
+func (f *reloadFs) RemoveAll(path string) error {
+	return f.fsys().RemoveAll(path)
+}
+
+func (f *reloadFs) Mkdir(name string, perm fs.FileMode) error {
+	return f.fsys().Mkdir(name, perm)
+}
+
+func (f *reloadFs) MkdirAll(path string, perm fs.FileMode) error {
+	return f.fsys().MkdirAll(path, perm)
+}
+
+// Symlink implements [SymlinkFS] for reloadFs.
+func (f *reloadFs) Symlink(oldname, newname string) error {
+	return Symlink(f.fsys(), oldname, newname)
+}
+
+// Readlink implements [SymlinkFS] for reloadFs.
+func (f *reloadFs) Readlink(name string) (string, error) {
+	return Readlink(f.fsys(), name)
+}
+
+// Lstat implements [SymlinkFS] for reloadFs.
+func (f *reloadFs) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(f.fsys(), name)
+}
+
+// Link implements [LinkFS] for reloadFs.
+func (f *reloadFs) Link(oldname, newname string) error {
+	return Link(f.fsys(), oldname, newname)
+}
+
+// Chmod implements [MetaFS] for reloadFs.
+func (f *reloadFs) Chmod(name string, mode fs.FileMode) error {
+	return Chmod(f.fsys(), name, mode)
+}
+
+// Chown implements [MetaFS] for reloadFs.
+func (f *reloadFs) Chown(name string, uid, gid int) error {
+	return Chown(f.fsys(), name, uid, gid)
+}
+
+// Chtimes implements [MetaFS] for reloadFs.
+func (f *reloadFs) Chtimes(name string, atime, mtime time.Time) error {
+	return Chtimes(f.fsys(), name, atime, mtime)
+}
+
+// decodeOptions decodes the options of a wrapper into v, rejecting
+// unknown options.
+func decodeOptions(options json.RawMessage, v any) error {
+	if len(options) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(options))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// duration is a [time.Duration] decoded from a string such as "5s".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+func wrapFilter(fsys FS, options json.RawMessage) (FS, error) {
+	var o struct {
+		Allow []string `json:"allow"`
+		Deny  []string `json:"deny"`
+	}
+	if err := decodeOptions(options, &o); err != nil {
+		return nil, err
+	}
+	// report malformed patterns now instead of on every operation
+	if _, err := splitPatterns(slices.Concat(o.Allow, o.Deny)); err != nil {
+		return nil, err
+	}
+	return Filter(fsys, o.Allow, o.Deny), nil
+}
+
+func wrapLatency(fsys FS, options json.RawMessage) (FS, error) {
+	var o struct {
+		Latency duration `json:"latency"`
+		Jitter  duration `json:"jitter"`
+	}
+	if err := decodeOptions(options, &o); err != nil {
+		return nil, err
+	}
+	return WithLatency(fsys, UniformLatency(time.Duration(o.Latency), time.Duration(o.Jitter))), nil
+}
+
+func wrapLogging(fsys FS, options json.RawMessage) (FS, error) {
+	if err := decodeOptions(options, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return WithLogging(fsys, slog.Default()), nil
+}
+
+func wrapReadOnly(fsys FS, options json.RawMessage) (FS, error) {
+	if err := decodeOptions(options, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return ReadOnly(fsys), nil
+}
+
+func wrapSub(fsys FS, options json.RawMessage) (FS, error) {
+	var o struct {
+		Dir string `json:"dir"`
+	}
+	if err := decodeOptions(options, &o); err != nil {
+		return nil, err
+	}
+	if o.Dir == "" {
+		return nil, errors.New("missing dir")
+	}
+	return Sub(fsys, o.Dir)
+}
+
+func wrapTimeout(fsys FS, options json.RawMessage) (FS, error) {
+	var o struct {
+		Timeout duration `json:"timeout"`
+	}
+	if err := decodeOptions(options, &o); err != nil {
+		return nil, err
+	}
+	if o.Timeout <= 0 {
+		return nil, errors.New("missing timeout")
+	}
+	return WithTimeout(fsys, time.Duration(o.Timeout)), nil
+}
+
+func wrapTombstones(fsys FS, options json.RawMessage) (FS, error) {
+	if err := decodeOptions(options, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return WithTombstones(fsys), nil
+}
+
+func wrapTrash(fsys FS, options json.RawMessage) (FS, error) {
+	var o struct {
+		Dir string `json:"dir"`
+	}
+	if err := decodeOptions(options, &o); err != nil {
+		return nil, err
+	}
+	if o.Dir == "" {
+		return nil, errors.New("missing dir")
+	}
+	return WithTrash(fsys, o.Dir), nil
+}
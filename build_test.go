@@ -0,0 +1,146 @@
+package wfs_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestBuild(t *testing.T) {
+	var config wfs.Config
+	err := json.Unmarshal([]byte(`{
+		"backend": "mem://",
+		"wrappers": [{"type": "timeout", "options": {"timeout": "5s"}}]
+	}`), &config)
+	if err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	fsys, err := wfs.Build(t.Context(), config)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	defer fsys.Close()
+	if err := wfs.WriteFile(fsys, "file", []byte("hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	// the backend is kept when only the wrappers change
+	config.Wrappers = append(config.Wrappers, wfs.WrapperConfig{Type: "readonly"})
+	if err := fsys.Reload(t.Context(), config); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if b, err := fs.ReadFile(fsys, "file"); err != nil || string(b) != "hello" {
+		t.Errorf("expected file to remain after Reload, got %q err: %v", b, err)
+	}
+	if err := wfs.WriteFile(fsys, "file", nil, 0644); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected write to fail with ErrPermission, got %v", err)
+	}
+	if got := fsys.Config(); len(got.Wrappers) != 2 || got.Wrappers[1].Type != "readonly" {
+		t.Errorf("expected the reloaded config, got %+v", got)
+	}
+
+	// invalid configs keep the current file system
+	for _, wrapper := range []wfs.WrapperConfig{
+		{Type: "missing"},
+		{Type: "timeout"},
+		{Type: "timeout", Options: json.RawMessage(`{"timeout": "soon"}`)},
+		{Type: "sub", Options: json.RawMessage(`{"directory": "dir"}`)},
+		{Type: "filter", Options: json.RawMessage(`{"allow": ["["]}`)},
+	} {
+		if err := fsys.Reload(t.Context(), wfs.Config{Backend: "mem://", Wrappers: []wfs.WrapperConfig{wrapper}}); err == nil {
+			t.Errorf("expected Reload to fail for wrapper %s %s", wrapper.Type, wrapper.Options)
+		}
+	}
+	if err := fsys.Reload(t.Context(), wfs.Config{Backend: "missing://"}); err == nil {
+		t.Errorf("expected Reload to fail for an unknown backend")
+	}
+	if _, err := fs.Stat(fsys, "file"); err != nil {
+		t.Errorf("expected the current file system to be kept, got %v", err)
+	}
+
+	// a new backend replaces the current one
+	if err := fsys.Reload(t.Context(), wfs.Config{Backend: "file:" + t.TempDir()}); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if _, err := fs.Stat(fsys, "file"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected an empty backend, got %v", err)
+	}
+}
+
+func TestBuildConcurrentReload(t *testing.T) {
+	fsys, err := wfs.Build(t.Context(), wfs.Config{Backend: "mem://"})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	defer fsys.Close()
+	if err := wfs.WriteFile(fsys, "file", []byte("hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if _, err := fs.ReadFile(fsys, "file"); err != nil {
+					t.Errorf("ReadFile failed during Reload: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for i := range 100 {
+		config := wfs.Config{Backend: "mem://"}
+		if i%2 == 0 {
+			config.Wrappers = []wfs.WrapperConfig{{Type: "readonly"}}
+		}
+		if err := fsys.Reload(t.Context(), config); err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestRegisterWrapper(t *testing.T) {
+	var options []string
+	wfs.RegisterWrapper("test-prefix", func(fsys wfs.FS, raw json.RawMessage) (wfs.FS, error) {
+		var o struct{ Dir string }
+		if err := json.Unmarshal(raw, &o); err != nil {
+			return nil, err
+		}
+		options = append(options, o.Dir)
+		return wfs.Sub(fsys, o.Dir)
+	})
+	if !slices.Contains(wfs.Wrappers(), "test-prefix") {
+		t.Errorf("expected registered wrapper in %v", wfs.Wrappers())
+	}
+	root := t.TempDir()
+	if err := wfs.Dir(root).MkdirAll("data", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	fsys, err := wfs.Build(t.Context(), wfs.Config{
+		Backend:  "file:" + root,
+		Wrappers: []wfs.WrapperConfig{{Type: "test-prefix", Options: json.RawMessage(`{"dir": "data"}`)}},
+	})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "file", nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := fs.Stat(wfs.Dir(root), "data/file"); err != nil || !slices.Equal(options, []string{"data"}) {
+		t.Errorf("expected file written by the registered wrapper, got options %v err: %v", options, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected RegisterWrapper to panic for a duplicate name")
+		}
+	}()
+	wfs.RegisterWrapper("readonly", func(fsys wfs.FS, _ json.RawMessage) (wfs.FS, error) { return fsys, nil })
+}
@@ -0,0 +1,297 @@
+package wfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var errBadIndex = errors.New("invalid index entry")
+
+// CASFS is a content-addressable file system storing the content of files
+// as blobs named by their SHA-256 digest, so that files with equal content
+// share a single blob.
+type CASFS interface {
+	FS
+
+	// Hash implements [HashFS] for SHA-256 digests, which are read from the
+	// index without reading the content of the file.
+	Hash(name string, h func() hash.Hash) ([]byte, error)
+
+	// GC removes the blobs that no file refers to, along with the temporary
+	// files left by interrupted writes.
+	GC() (GCStats, error)
+}
+
+// GCStats describes the blobs removed by [CASFS.GC].
+type GCStats struct {
+	// Blobs is the number of blobs removed.
+	Blobs int
+
+	// Bytes is the total size of the blobs removed.
+	Bytes int64
+}
+
+// CAS returns a content-addressable file system storing its files in store.
+// The tree of files and directories is kept in store/index, where every file
+// holds the digest and size of its content, and the content is kept as
+// immutable blobs in store/blobs, so that copies of a file only take the
+// space of their index entry.
+//
+// Files opened for writing are written to a temporary copy in store/tmp,
+// which is stored as a blob and recorded in the index when the file is
+// synced or closed. Blobs are never removed when files are removed or
+// overwritten, call GC to reclaim their space. GC must not run while files
+// are written by another process using the same store.
+func CAS(store FS) (CASFS, error) {
+	if err := store.MkdirAll("index", 0755); err != nil {
+		return nil, err
+	}
+	blobs, err := newBlobStore(store)
+	if err != nil {
+		return nil, err
+	}
+	return &casFs{store: store, blobs: blobs}, nil
+}
+
+type casFs struct {
+	store FS
+	blobs *blobStore
+}
+
+// casRef is the index entry of a file.
+type casRef struct {
+	hash string
+	size int64
+}
+
+func indexPath(name string) string {
+	if name == "." {
+		return "index"
+	}
+	return "index/" + name
+}
+
+// readRef reads the index entry stored in the file iname of the store. Empty
+// entries are files being created and refer to empty content.
+func (f *casFs) readRef(iname, name string) (casRef, error) {
+	data, err := fs.ReadFile(f.store, iname)
+	if err != nil {
+		return casRef{}, fixErr(err, name)
+	}
+	if len(data) == 0 {
+		return casRef{emptyHash, 0}, nil
+	}
+	sum, size, ok := strings.Cut(strings.TrimSuffix(string(data), "\n"), " ")
+	n, err := strconv.ParseInt(size, 10, 64)
+	if _, err1 := hex.DecodeString(sum); !ok || err != nil || err1 != nil || len(sum) != 2*sha256.Size {
+		return casRef{}, &fs.PathError{Op: "open", Path: name, Err: errBadIndex}
+	}
+	return casRef{sum, n}, nil
+}
+
+func (r casRef) String() string {
+	return r.hash + " " + strconv.FormatInt(r.size, 10) + "\n"
+}
+
+func (f *casFs) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for casFs.
+func (f *casFs) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := fs.Stat(f.store, indexPath(name))
+	if err != nil {
+		return nil, fixErr(err, name)
+	}
+	size := info.Size()
+	if !info.IsDir() {
+		ref, err := f.readRef(indexPath(name), name)
+		if err != nil {
+			return nil, err
+		}
+		size = ref.size
+	}
+	return sizedInfo{info, path.Base(name), size}, nil
+}
+
+// Hash implements [HashFS] for casFs.
+func (f *casFs) Hash(name string, h func() hash.Hash) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "hash", Path: name, Err: fs.ErrInvalid}
+	}
+	// only SHA-256 digests empty content like this
+	if sum := sha256.Sum256(nil); !bytes.Equal(h().Sum(nil), sum[:]) {
+		return nil, &fs.PathError{Op: "hash", Path: name, Err: errors.ErrUnsupported}
+	}
+	info, err := fs.Stat(f.store, indexPath(name))
+	if err != nil {
+		return nil, fixErr(err, name)
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "hash", Path: name, Err: ErrIsDir}
+	}
+	ref, err := f.readRef(indexPath(name), name)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(ref.hash)
+}
+
+func (f *casFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	iname := indexPath(name)
+	if flag&os.O_CREATE != 0 {
+		// an empty index entry is created first, so that the file exists
+		// and O_EXCL is handled by the store
+		idx, err := f.store.OpenFile(iname, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if err == nil {
+			err = idx.Close()
+		} else if flag&os.O_EXCL == 0 && errors.Is(err, fs.ErrExist) {
+			err = nil
+		}
+		if err != nil {
+			return nil, fixErr(err, name)
+		}
+	}
+	info, err := fs.Stat(f.store, iname)
+	if err != nil {
+		return nil, fixErr(err, name)
+	}
+	write := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	if info.IsDir() {
+		if write {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: ErrIsDir}
+		}
+		dir, err := f.store.OpenFile(iname, os.O_RDONLY, 0)
+		if err != nil {
+			return nil, fixErr(err, name)
+		}
+		return &sizedDir{File: dir, name: name, dir: iname, size: f.size}, nil
+	}
+	ref, err := f.readRef(iname, name)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := f.store.OpenFile(blobPath(ref.hash), os.O_RDONLY, 0)
+	if err != nil {
+		return nil, fixErr(err, name)
+	}
+	file := &stagedFile{File: blob, blobs: f.blobs, name: name, flag: flag, info: info}
+	if !write {
+		return file, nil
+	}
+	defer blob.Close()
+	var content io.Reader
+	if flag&os.O_TRUNC == 0 {
+		content = blob
+	}
+	if file.File, err = f.blobs.tempCopy(content); err != nil {
+		return nil, fixErr(err, name)
+	}
+	file.dirty = flag&os.O_TRUNC != 0 && ref.size > 0
+	file.commit = func(tmp File, keep bool) error { return f.commit(name, tmp, keep) }
+	return file, nil
+}
+
+// commit stores the content of the temporary copy tmp of the named file as a
+// blob and records it in the index.
+func (f *casFs) commit(name string, tmp File, keep bool) error {
+	f.blobs.gc.RLock()
+	defer f.blobs.gc.RUnlock()
+	hash, size, err := f.blobs.putFile(tmp, keep)
+	if err != nil {
+		return err
+	}
+	idx, err := f.store.OpenFile(indexPath(name), os.O_WRONLY|os.O_TRUNC, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		// the file was removed or renamed while open
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(idx, casRef{hash, size}.String())
+	if err1 := idx.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// size returns the size of the content of the file iname of the store.
+func (f *casFs) size(iname string) (int64, error) {
+	ref, err := f.readRef(iname, iname)
+	return ref.size, err
+}
+
+func (f *casFs) Rename(oldpath, newpath string) error {
+	if !fs.ValidPath(oldpath) || !fs.ValidPath(newpath) || oldpath == "." || newpath == "." {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	if err := f.store.Rename(indexPath(oldpath), indexPath(newpath)); err != nil {
+		if le, ok := err.(*os.LinkError); ok {
+			return &os.LinkError{Op: le.Op, Old: oldpath, New: newpath, Err: le.Err}
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *casFs) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	return fixErr(f.store.Remove(indexPath(name)), name)
+}
+
+func (f *casFs) RemoveAll(path string) error {
+	if !fs.ValidPath(path) || path == "." {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: fs.ErrInvalid}
+	}
+	return fixErr(f.store.RemoveAll(indexPath(path)), path)
+}
+
+func (f *casFs) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return fixErr(f.store.Mkdir(indexPath(name), perm), name)
+}
+
+func (f *casFs) MkdirAll(path string, perm fs.FileMode) error {
+	if !fs.ValidPath(path) {
+		return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrInvalid}
+	}
+	return fixErr(f.store.MkdirAll(indexPath(path), perm), path)
+}
+
+func (f *casFs) GC() (GCStats, error) {
+	f.blobs.gc.Lock()
+	defer f.blobs.gc.Unlock()
+	refs := make(map[string]bool)
+	err := fs.WalkDir(f.store, "index", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		ref, err := f.readRef(name, name)
+		refs[ref.hash] = true
+		return err
+	})
+	if err != nil {
+		return GCStats{}, err
+	}
+	return f.blobs.collect(refs)
+}
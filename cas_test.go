@@ -0,0 +1,165 @@
+package wfs_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+// countBlobs returns the number of blobs in the store of a CAS file system.
+func countBlobs(t *testing.T, store wfs.FS) int {
+	t.Helper()
+	n := 0
+	fs.WalkDir(store, "blobs", func(name string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			n++
+		}
+		return err
+	})
+	return n
+}
+
+func TestCAS(t *testing.T) {
+	store := wfs.Mem()
+	fsys, err := wfs.CAS(store)
+	if err != nil {
+		t.Fatalf("CAS failed: %v", err)
+	}
+	if err := fsys.MkdirAll("docs/old", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	for name, content := range map[string]string{
+		"docs/a.txt":     "shared content",
+		"docs/b.txt":     "shared content",
+		"docs/old/c.txt": "other content",
+		"empty.txt":      "",
+	} {
+		if err := wfs.WriteFile(fsys, name, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	assertContent(t, fsys, "docs/a.txt", "shared content")
+	assertEntries(t, fsys, "docs", "a.txt", "b.txt", "old")
+	if err := fstest.TestFS(fsys, "docs/a.txt", "docs/b.txt", "docs/old/c.txt", "empty.txt"); err != nil {
+		t.Fatal(err)
+	}
+	// equal content is stored once, with the blob of empty content
+	if n := countBlobs(t, store); n != 3 {
+		t.Errorf("expected 3 blobs, got %d", n)
+	}
+
+	sum, err := wfs.HashFile(fsys, "docs/b.txt", sha256.New)
+	if expected := sha256.Sum256([]byte("shared content")); err != nil || string(sum) != string(expected[:]) {
+		t.Errorf("unexpected digest %x err: %v", sum, err)
+	}
+	if _, err := fsys.Hash("docs/b.txt", md5.New); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for MD5, got %v", err)
+	}
+
+	// blobs are kept until collected
+	if err := wfs.WriteFile(fsys, "docs/a.txt", []byte("changed"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := fsys.RemoveAll("docs/old"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if n := countBlobs(t, store); n != 4 {
+		t.Errorf("expected 4 blobs before GC, got %d", n)
+	}
+	stats, err := fsys.GC()
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if stats.Blobs != 1 || stats.Bytes != int64(len("other content")) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	assertContent(t, fsys, "docs/a.txt", "changed")
+	assertContent(t, fsys, "docs/b.txt", "shared content")
+
+	// the index and blobs persist in the store
+	reopened, err := wfs.CAS(store)
+	if err != nil {
+		t.Fatalf("CAS failed: %v", err)
+	}
+	assertContent(t, reopened, "docs/b.txt", "shared content")
+	if stats, err := reopened.GC(); err != nil || stats.Blobs != 0 {
+		t.Errorf("expected no blobs to collect, got %+v err: %v", stats, err)
+	}
+}
+
+func TestCASOpenFile(t *testing.T) {
+	store := wfs.Mem()
+	fsys, err := wfs.CAS(store)
+	if err != nil {
+		t.Fatalf("CAS failed: %v", err)
+	}
+	f, err := fsys.OpenFile("log", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if _, err := fsys.OpenFile("log", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+	// the content is stored when the file is synced
+	io.WriteString(f, "hello world")
+	assertContent(t, fsys, "log", "")
+	if err := f.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	assertContent(t, fsys, "log", "hello world")
+	f.WriteAt([]byte("HELLO"), 0)
+	if info, err := f.Stat(); err != nil || info.Size() != 11 || info.Name() != "log" {
+		t.Errorf("unexpected info %v err: %v", info, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	assertContent(t, fsys, "log", "HELLO world")
+
+	f, err = fsys.OpenFile("log", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	io.WriteString(f, "!")
+	if _, err := f.Read(make([]byte, 1)); err == nil {
+		t.Error("expected Read of a write-only file to fail")
+	}
+	f.Close()
+	assertContent(t, fsys, "log", "HELLO world!")
+
+	r, err := fsys.Open("log")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := r.(wfs.File).Write([]byte("x")); err == nil {
+		t.Error("expected Write of a read-only file to fail")
+	}
+	r.Close()
+
+	// temporary copies left behind are collected, those of open files are kept
+	w, err := fsys.OpenFile("log", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if err := wfs.WriteFile(store, "tmp/stale", []byte("x"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := fsys.GC(); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	entries, _ := fs.ReadDir(store, "tmp")
+	if len(entries) != 1 {
+		t.Errorf("expected only the open file to remain in tmp, got %v", entries)
+	}
+	io.WriteString(w, "bye")
+	w.Close()
+	assertContent(t, fsys, "log", "byeLO world!")
+	assertEntries(t, store, "tmp")
+}
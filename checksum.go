@@ -0,0 +1,380 @@
+package wfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChecksumFS is a file system that records the SHA-256 digest of every file
+// written through it in a [Manifest].
+type ChecksumFS interface {
+	FS
+
+	// Hash implements [HashFS] for SHA-256 digests, which are served from
+	// the manifest without reading the content of the file.
+	Hash(name string, h func() hash.Hash) ([]byte, error)
+
+	// Manifest returns the recorded manifest of the file system.
+	Manifest() Manifest
+
+	// Verify checks the file system against the recorded manifest, as with
+	// [VerifyManifest]. The manifest file itself is never reported.
+	Verify() (ManifestResult, error)
+}
+
+// WithChecksums returns a file system that maintains the checksums of the
+// files in fsys as they are written, renamed and removed, so that the tree
+// can be verified later or after it has been copied elsewhere.
+//
+// The checksums are kept in the named manifest file of fsys, encoded as
+// [ManifestJSON] and replaced atomically after every change. If the manifest
+// does not exist it is built from the current contents of fsys. The manifest
+// file can be read but not written through the returned file system.
+//
+// Files written sequentially from the start, as with [WriteFile], are hashed
+// as they are written; other files are hashed again when they are closed.
+// Changes made directly to fsys are not recorded and are reported by Verify.
+func WithChecksums(fsys FS, manifest string) (ChecksumFS, error) {
+	if !fs.ValidPath(manifest) || manifest == "." {
+		return nil, &fs.PathError{Op: "open", Path: manifest, Err: fs.ErrInvalid}
+	}
+	f := &checksumFs{fsys: fsys, manifest: manifest, entries: make(map[string]ManifestEntry)}
+	file, err := fsys.Open(manifest)
+	if errors.Is(err, fs.ErrNotExist) {
+		m, err := BuildManifest(fsys, ".")
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range m {
+			if e.Path != manifest {
+				f.entries[e.Path] = e
+			}
+		}
+		if err := f.save(); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	m, err := ReadManifest(file, ManifestJSON)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: manifest, Err: err}
+	}
+	for _, e := range m {
+		f.entries[e.Path] = e
+	}
+	return f, nil
+}
+
+type checksumFs struct {
+	fsys     FS
+	manifest string
+
+	mu      sync.Mutex
+	entries map[string]ManifestEntry
+}
+
+// save writes the manifest, the caller must hold f.mu.
+func (f *checksumFs) save() error {
+	var buf bytes.Buffer
+	if err := f.snapshot().Encode(&buf, ManifestJSON); err != nil {
+		return err
+	}
+	return AtomicWriteFile(f.fsys, f.manifest, buf.Bytes(), 0644)
+}
+
+// snapshot returns the recorded manifest, the caller must hold f.mu.
+func (f *checksumFs) snapshot() Manifest {
+	m := slices.Collect(maps.Values(f.entries))
+	slices.SortFunc(m, func(a, b ManifestEntry) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return m
+}
+
+// update applies fn to the recorded entries and saves the manifest.
+func (f *checksumFs) update(fn func(entries map[string]ManifestEntry)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn(f.entries)
+	return f.save()
+}
+
+// record records the digest of the named file. The file is hashed again
+// unless sum is the digest of size bytes and the file still has that size.
+func (f *checksumFs) record(name string, sum []byte, size int64) error {
+	info, err := fs.Stat(f.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		// the file was removed or renamed while open
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if sum == nil || info.Size() != size {
+		if sum, err = HashFile(f.fsys, name, sha256.New); err != nil {
+			return err
+		}
+	}
+	e := ManifestEntry{Path: name, Size: info.Size(), SHA256: hex.EncodeToString(sum)}
+	return f.update(func(entries map[string]ManifestEntry) {
+		entries[name] = e
+	})
+}
+
+// protected reports whether name is the manifest or a directory containing it.
+func (f *checksumFs) protected(name string) bool {
+	return name == f.manifest || name == "." || strings.HasPrefix(f.manifest, name+"/")
+}
+
+// forget removes the entries of name and the files below it.
+func forget(entries map[string]ManifestEntry, name string) {
+	delete(entries, name)
+	for p := range entries {
+		if name == "." || strings.HasPrefix(p, name+"/") {
+			delete(entries, p)
+		}
+	}
+}
+
+func (f *checksumFs) Manifest() Manifest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.snapshot()
+}
+
+func (f *checksumFs) Verify() (ManifestResult, error) {
+	result, err := VerifyManifest(f.fsys, ".", f.Manifest())
+	// the manifest and its temporary copies are not part of the tree
+	dir, base := path.Split(f.manifest)
+	result.Extra = slices.DeleteFunc(result.Extra, func(name string) bool {
+		return name == f.manifest || strings.HasPrefix(name, dir+"."+base+".tmp")
+	})
+	return result, err
+}
+
+func (f *checksumFs) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+// Stat implements [fs.StatFS] for checksumFs.
+func (f *checksumFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+// Hash implements [HashFS] for checksumFs.
+func (f *checksumFs) Hash(name string, h func() hash.Hash) ([]byte, error) {
+	// only SHA-256 digests empty content like this
+	if sum := sha256.Sum256(nil); bytes.Equal(h().Sum(nil), sum[:]) {
+		f.mu.Lock()
+		e, ok := f.entries[name]
+		f.mu.Unlock()
+		if ok {
+			return hex.DecodeString(e.SHA256)
+		}
+	}
+	return HashFile(f.fsys, name, h)
+}
+
+func (f *checksumFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0
+	if !write {
+		return f.fsys.OpenFile(name, flag, perm)
+	}
+	if name == f.manifest {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	file, err := f.fsys.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		return file, nil
+	}
+	cf := &checksumFile{File: file, fs: f, name: name, dirty: flag&(os.O_CREATE|os.O_TRUNC) != 0}
+	if info.Size() == 0 {
+		// writes start at the beginning of the file until it is read or seeked
+		cf.hash = sha256.New()
+	}
+	return cf, nil
+}
+
+func (f *checksumFs) Rename(oldpath, newpath string) error {
+	if f.protected(oldpath) || f.protected(newpath) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrPermission}
+	}
+	if err := f.fsys.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	return f.update(func(entries map[string]ManifestEntry) {
+		moved := make(map[string]ManifestEntry)
+		for p, e := range entries {
+			if p == oldpath || strings.HasPrefix(p, oldpath+"/") {
+				e.Path = newpath + strings.TrimPrefix(p, oldpath)
+				moved[e.Path] = e
+			}
+		}
+		forget(entries, oldpath)
+		forget(entries, newpath)
+		maps.Copy(entries, moved)
+	})
+}
+
+func (f *checksumFs) Remove(name string) error {
+	if name == f.manifest {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	if err := f.fsys.Remove(name); err != nil {
+		return err
+	}
+	return f.update(func(entries map[string]ManifestEntry) {
+		delete(entries, name)
+	})
+}
+
+func (f *checksumFs) RemoveAll(path string) error {
+	if f.protected(path) {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: fs.ErrPermission}
+	}
+	if err := f.fsys.RemoveAll(path); err != nil {
+		return err
+	}
+	return f.update(func(entries map[string]ManifestEntry) {
+		forget(entries, path)
+	})
+}
+
+func (f *checksumFs) Mkdir(name string, perm fs.FileMode) error {
+	return f.fsys.Mkdir(name, perm)
+}
+
+func (f *checksumFs) MkdirAll(path string, perm fs.FileMode) error {
+	return f.fsys.MkdirAll(path, perm)
+}
+
+// Symlink implements [SymlinkFS] for checksumFs.
+func (f *checksumFs) Symlink(oldname, newname string) error {
+	return Symlink(f.fsys, oldname, newname)
+}
+
+// Readlink implements [SymlinkFS] for checksumFs.
+func (f *checksumFs) Readlink(name string) (string, error) {
+	return Readlink(f.fsys, name)
+}
+
+// Lstat implements [SymlinkFS] for checksumFs.
+func (f *checksumFs) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(f.fsys, name)
+}
+
+// Chmod implements [MetaFS] for checksumFs.
+func (f *checksumFs) Chmod(name string, mode fs.FileMode) error {
+	return Chmod(f.fsys, name, mode)
+}
+
+// Chown implements [MetaFS] for checksumFs.
+func (f *checksumFs) Chown(name string, uid, gid int) error {
+	return Chown(f.fsys, name, uid, gid)
+}
+
+// Chtimes implements [MetaFS] for checksumFs.
+func (f *checksumFs) Chtimes(name string, atime, mtime time.Time) error {
+	return Chtimes(f.fsys, name, atime, mtime)
+}
+
+// checksumFile records the digest of the file when it is synced or closed
+// after being modified. While the file is written sequentially from the
+// start its digest is computed from the written bytes.
+type checksumFile struct {
+	File
+	fs    *checksumFs
+	name  string
+	hash  hash.Hash
+	size  int64
+	dirty bool
+}
+
+func (f *checksumFile) Read(b []byte) (int, error) {
+	f.hash = nil
+	return f.File.Read(b)
+}
+
+func (f *checksumFile) Seek(offset int64, whence int) (int64, error) {
+	f.hash = nil
+	return f.File.Seek(offset, whence)
+}
+
+func (f *checksumFile) Write(b []byte) (int, error) {
+	n, err := f.File.Write(b)
+	if n > 0 {
+		f.dirty = true
+		if f.hash != nil {
+			f.hash.Write(b[:n])
+			f.size += int64(n)
+		}
+	}
+	return n, err
+}
+
+func (f *checksumFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *checksumFile) WriteAt(b []byte, off int64) (int, error) {
+	f.hash = nil
+	f.dirty = true
+	return f.File.WriteAt(b, off)
+}
+
+func (f *checksumFile) Truncate(size int64) error {
+	f.hash = nil
+	f.dirty = true
+	return f.File.Truncate(size)
+}
+
+// record records the digest of the file if it was modified.
+func (f *checksumFile) record() error {
+	if !f.dirty {
+		return nil
+	}
+	f.dirty = false
+	var sum []byte
+	if f.hash != nil {
+		sum = f.hash.Sum(nil)
+	}
+	return f.fs.record(f.name, sum, f.size)
+}
+
+func (f *checksumFile) Sync() error {
+	if err := f.File.Sync(); err != nil {
+		return err
+	}
+	return f.record()
+}
+
+func (f *checksumFile) Close() error {
+	err := f.File.Close()
+	if err == nil {
+		err = f.record()
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package wfs_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestWithChecksums(t *testing.T) {
+	mem := wfs.Mem()
+	if err := wfs.WriteFile(mem, "existing.txt", []byte("written before"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	fsys, err := wfs.WithChecksums(mem, "SHA256SUMS.json")
+	if err != nil {
+		t.Fatalf("WithChecksums failed: %v", err)
+	}
+	if err := fsys.MkdirAll("docs", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "docs/a.txt", []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	f, err := fsys.OpenFile("docs/b.txt", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	io.WriteString(f, "hello world")
+	f.WriteAt([]byte("HELLO"), 0)
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := fsys.Rename("docs", "notes"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	paths := func() []string {
+		var paths []string
+		for _, e := range fsys.Manifest() {
+			paths = append(paths, e.Path)
+		}
+		return paths
+	}
+	if got, expected := paths(), []string{"existing.txt", "notes/a.txt", "notes/b.txt"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected entries %v, got %v", expected, got)
+	}
+	sum, err := wfs.HashFile(fsys, "notes/b.txt", sha256.New)
+	if expected := sha256.Sum256([]byte("HELLO world")); err != nil || string(sum) != string(expected[:]) {
+		t.Errorf("unexpected digest %x err: %v", sum, err)
+	}
+	if result, err := fsys.Verify(); err != nil || !result.OK() {
+		t.Errorf("unexpected result %+v err: %v", result, err)
+	}
+
+	// the manifest is persisted and verifies the tree without the wrapper
+	data, err := fs.ReadFile(mem, "SHA256SUMS.json")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	m, err := wfs.ReadManifest(bytes.NewReader(data), wfs.ManifestJSON)
+	if err != nil || !reflect.DeepEqual(m, fsys.Manifest()) {
+		t.Errorf("unexpected manifest %v err: %v", m, err)
+	}
+	reopened, err := wfs.WithChecksums(mem, "SHA256SUMS.json")
+	if err != nil || !reflect.DeepEqual(reopened.Manifest(), m) {
+		t.Errorf("unexpected manifest %v err: %v", reopened.Manifest(), err)
+	}
+
+	// changes made behind the wrapper are reported
+	wfs.WriteFile(mem, "notes/a.txt", []byte("tampered"), 0644)
+	wfs.WriteFile(mem, "extra.txt", nil, 0644)
+	mem.Remove("existing.txt")
+	result, err := fsys.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	expected := wfs.ManifestResult{Missing: []string{"existing.txt"}, Extra: []string{"extra.txt"}, Corrupt: []string{"notes/a.txt"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+
+	if err := fsys.RemoveAll("notes"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if got, expected := paths(), []string{"existing.txt"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected entries %v, got %v", expected, got)
+	}
+	if err := wfs.WriteFile(fsys, "SHA256SUMS.json", nil, 0644); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected ErrPermission writing the manifest, got %v", err)
+	}
+	if err := fsys.Remove("SHA256SUMS.json"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected ErrPermission removing the manifest, got %v", err)
+	}
+}
+
+func TestWithChecksumsAppend(t *testing.T) {
+	fsys, err := wfs.WithChecksums(wfs.Mem(), "sums.json")
+	if err != nil {
+		t.Fatalf("WithChecksums failed: %v", err)
+	}
+	for _, s := range []string{"first\n", "second\n"} {
+		f, err := fsys.OpenFile("log", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatalf("OpenFile failed: %v", err)
+		}
+		io.WriteString(f, s)
+		if err := f.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+	sum := sha256.Sum256([]byte("first\nsecond\n"))
+	if m := fsys.Manifest(); len(m) != 1 || m[0].Size != 13 || m[0].SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected manifest %v", m)
+	}
+}
@@ -0,0 +1,134 @@
+package wfs
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Coalesce returns a channel of higher-level change events produced by
+// merging bursts of events received from events.
+//
+// An event is held back until no other event has been received for the same
+// path within the debounce window. Events held for the same path are merged:
+// repeated writes become a single event with the accumulated size, a chmod
+// does not change the op of an earlier event, a create followed by a remove is
+// dropped entirely, and a file created and then renamed over another path
+// (the pattern of atomic writes) is reported as a single [OpWrite] of the new
+// path.
+//
+// The returned channel is closed after events is closed and all pending events
+// have been delivered.
+func Coalesce(events <-chan Event, window time.Duration) <-chan Event {
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		c := coalescer{pending: make(map[string]*pendingEvent)}
+		timer := time.NewTimer(window)
+		timer.Stop()
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					for _, p := range c.flush(time.Time{}) {
+						out <- p
+					}
+					return
+				}
+				c.add(e, time.Now().Add(window))
+				if next, ok := c.next(); ok {
+					timer.Reset(time.Until(next))
+				}
+			case now := <-timer.C:
+				for _, p := range c.flush(now) {
+					out <- p
+				}
+				if next, ok := c.next(); ok {
+					timer.Reset(time.Until(next))
+				}
+			}
+		}
+	}()
+	return out
+}
+
+type pendingEvent struct {
+	Event
+	seq      uint64
+	deadline time.Time
+}
+
+type coalescer struct {
+	pending map[string]*pendingEvent
+	seq     uint64
+}
+
+// add merges e with the pending event for its path.
+func (c *coalescer) add(e Event, deadline time.Time) {
+	if e.Op == OpRename {
+		if old, ok := c.pending[e.OldPath]; ok {
+			delete(c.pending, e.OldPath)
+			e.Size += old.Size
+			if old.Op == OpCreate {
+				e = Event{Op: OpWrite, Path: e.Path, Size: e.Size, Actor: e.Actor, Time: e.Time}
+			}
+		}
+	}
+
+	c.seq++
+	p, ok := c.pending[e.Path]
+	if !ok {
+		c.pending[e.Path] = &pendingEvent{Event: e, seq: c.seq, deadline: deadline}
+		return
+	}
+	size := p.Size + e.Size
+	switch {
+	case (e.Op == OpWrite || e.Op == OpChmod) && p.Op != OpRemove:
+		// keep the original op of the burst
+	case e.Op == OpCreate && p.Op == OpRemove:
+		p.Op = OpWrite
+	case e.Op == OpRemove && p.Op == OpCreate:
+		// the file never existed outside the burst
+		delete(c.pending, e.Path)
+		return
+	default:
+		p.Op = e.Op
+		p.OldPath = e.OldPath
+	}
+	p.Size = size
+	p.Actor = e.Actor
+	p.Time = e.Time
+	p.seq = c.seq
+	p.deadline = deadline
+}
+
+// flush removes and returns the pending events due at now in the order they
+// were last updated. All pending events are returned if now is zero.
+func (c *coalescer) flush(now time.Time) []Event {
+	var due []*pendingEvent
+	for path, p := range c.pending {
+		if now.IsZero() || !p.deadline.After(now) {
+			due = append(due, p)
+			delete(c.pending, path)
+		}
+	}
+	slices.SortFunc(due, func(a, b *pendingEvent) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	events := make([]Event, len(due))
+	for i, p := range due {
+		events[i] = p.Event
+	}
+	return events
+}
+
+// next returns the earliest deadline of the pending events.
+func (c *coalescer) next() (time.Time, bool) {
+	var next time.Time
+	for _, p := range c.pending {
+		if next.IsZero() || p.deadline.Before(next) {
+			next = p.deadline
+		}
+	}
+	return next, !next.IsZero()
+}
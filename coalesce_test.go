@@ -0,0 +1,60 @@
+package wfs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestCoalesce(t *testing.T) {
+	events := make(chan wfs.Event)
+	out := wfs.Coalesce(events, 10*time.Millisecond)
+
+	go func() {
+		// write storm
+		events <- wfs.Event{Op: wfs.OpWrite, Path: "log", Size: 2}
+		events <- wfs.Event{Op: wfs.OpWrite, Path: "log", Size: 3}
+		// atomic write
+		events <- wfs.Event{Op: wfs.OpCreate, Path: "config.tmp"}
+		events <- wfs.Event{Op: wfs.OpWrite, Path: "config.tmp", Size: 4}
+		events <- wfs.Event{Op: wfs.OpRename, Path: "config", OldPath: "config.tmp"}
+		// short-lived file
+		events <- wfs.Event{Op: wfs.OpCreate, Path: "scratch"}
+		events <- wfs.Event{Op: wfs.OpRemove, Path: "scratch"}
+		close(events)
+	}()
+
+	expected := []wfs.Event{
+		{Op: wfs.OpWrite, Path: "log", Size: 5},
+		{Op: wfs.OpWrite, Path: "config", Size: 4},
+	}
+	var got []wfs.Event
+	for e := range out {
+		got = append(got, e)
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %+v", len(expected), len(got), got)
+	}
+	for i, want := range expected {
+		if got[i] != want {
+			t.Errorf("expected event %+v, got %+v", want, got[i])
+		}
+	}
+}
+
+func TestCoalesceWindow(t *testing.T) {
+	events := make(chan wfs.Event)
+	out := wfs.Coalesce(events, 10*time.Millisecond)
+	defer close(events)
+
+	events <- wfs.Event{Op: wfs.OpWrite, Path: "file", Size: 1}
+	select {
+	case e := <-out:
+		if e.Path != "file" || e.Size != 1 {
+			t.Errorf("unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected event after debounce window")
+	}
+}
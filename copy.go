@@ -0,0 +1,338 @@
+package wfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SymlinkPolicy is how [CopyFS] handles symbolic links in the source.
+type SymlinkPolicy int
+
+const (
+	// SymlinkRecreate recreates symbolic links with the same destination,
+	// it requires the source and destination to implement [SymlinkFS].
+	SymlinkRecreate SymlinkPolicy = iota
+
+	// SymlinkFollow copies the files and directories symbolic links refer to.
+	SymlinkFollow
+
+	// SymlinkSkip does not copy symbolic links.
+	SymlinkSkip
+)
+
+// OverwritePolicy is how the copy helpers handle files that already exist
+// at the destination. Existing directories are always merged.
+type OverwritePolicy int
+
+const (
+	// OverwriteReplace replaces existing files.
+	OverwriteReplace OverwritePolicy = iota
+
+	// OverwriteSkip keeps existing files and does not copy them.
+	OverwriteSkip
+
+	// OverwriteError stops the copy with an error wrapping [fs.ErrExist].
+	OverwriteError
+)
+
+// CopyOptions configures [CopyFile] and [CopyFS].
+// A nil *CopyOptions is equivalent to the zero value.
+//
+// Patterns are matched with [path.Match] against the slash-separated path
+// relative to the source root, or against the base name of the path
+// if the pattern does not contain a slash.
+type CopyOptions struct {
+	// Include limits the copy to files matching at least one pattern, and
+	// directories are then only created for included files. A directory that
+	// matches a pattern is included with all its contents.
+	// If Include is empty every file is included.
+	Include []string
+
+	// Exclude skips files and directories matching any pattern,
+	// taking precedence over Include.
+	Exclude []string
+
+	// Symlinks is how symbolic links are copied.
+	Symlinks SymlinkPolicy
+
+	// Overwrite is how existing files are handled.
+	Overwrite OverwritePolicy
+
+	// Progress, if set, is called after each regular file is copied
+	// with its slash-separated path relative to the source root
+	// and its size in bytes.
+	Progress func(name string, size int64)
+}
+
+// matchAny reports whether rel matches any of patterns.
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CopyFile copies the file srcName in src to dstName in dst, creating or
+// truncating it. The mode and modification time of the source file are
+// preserved when dst implements [MetaFS].
+// Only the Overwrite and Progress fields of opts apply to CopyFile.
+func CopyFile(dst FS, dstName string, src fs.FS, srcName string, opts *CopyOptions) error {
+	c, err := newCopier(dst, src, opts)
+	if err != nil {
+		return err
+	}
+	info, err := fs.Stat(src, srcName)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return &fs.PathError{Op: "copy", Path: srcName, Err: ErrIsDir}
+	}
+	return c.copyFile(dstName, srcName, srcName, info)
+}
+
+// CopyFS copies the file system src into the directory dstDir of dst,
+// creating dstDir if necessary. Directories, regular files and symbolic links
+// are copied as configured by opts. Modes and modification times are
+// preserved when dst implements [MetaFS].
+//
+// src may be any [fs.FS], such as an [embed.FS] or the result of [os.DirFS].
+func CopyFS(dst FS, dstDir string, src fs.FS, opts *CopyOptions) error {
+	c, err := newCopier(dst, src, opts)
+	if err != nil {
+		return err
+	}
+	return c.copyAll(dstDir, ".")
+}
+
+// copyTree copies the tree rooted at root in src to dstDir in dst,
+// root may also name a single file.
+func copyTree(dst FS, dstDir string, src fs.FS, root string) error {
+	c, err := newCopier(dst, src, nil)
+	if err != nil {
+		return err
+	}
+	return c.copyAll(dstDir, root)
+}
+
+type copier struct {
+	dst  FS
+	src  fs.FS
+	opts CopyOptions
+
+	// directories whose metadata is applied after their contents are
+	// copied, so that read-only directories can be filled
+	dirs []copiedDir
+}
+
+type copiedDir struct {
+	name string
+	info fs.FileInfo
+}
+
+func newCopier(dst FS, src fs.FS, opts *CopyOptions) (*copier, error) {
+	c := &copier{dst: dst, src: src}
+	if opts != nil {
+		c.opts = *opts
+	}
+	for _, pattern := range slices.Concat(c.opts.Include, c.opts.Exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *copier) copyAll(dstDir, root string) error {
+	if err := c.walk(dstDir, root, ".", 0); err != nil {
+		return err
+	}
+	for _, d := range slices.Backward(c.dirs) {
+		// directories without included files are not created
+		if err := copyMeta(c.dst, d.name, d.info); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// walk copies the tree rooted at root to dstDir, rel is the path of root
+// relative to the source root and links the number of followed links.
+func (c *copier) walk(dstDir, root, rel string, links int) error {
+	return fs.WalkDir(c.src, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target, nameRel := dstDir, rel
+		if name != root {
+			target = path.Join(dstDir, relPath(root, name))
+			nameRel = path.Join(rel, relPath(root, name))
+		}
+		if nameRel != "." && matchAny(c.opts.Exclude, nameRel) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		included := len(c.opts.Include) == 0 || c.includes(nameRel)
+
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			switch c.opts.Symlinks {
+			case SymlinkSkip:
+				return nil
+			case SymlinkFollow:
+				if links >= maxSymlinks {
+					return &fs.PathError{Op: "copy", Path: name, Err: errLoop}
+				}
+				return c.walk(target, name, nameRel, links+1)
+			}
+			if !included {
+				return nil
+			}
+			if err := c.mkdirParent(target); err != nil {
+				return err
+			}
+			return c.copySymlink(target, name)
+		case d.IsDir():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			c.dirs = append(c.dirs, copiedDir{target, info})
+			if !included {
+				return nil
+			}
+			return c.dst.MkdirAll(target, 0777)
+		case d.Type().IsRegular():
+			if !included {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if err := c.mkdirParent(target); err != nil {
+				return err
+			}
+			return c.copyFile(target, name, nameRel, info)
+		}
+		return &fs.PathError{Op: "copy", Path: name, Err: fs.ErrInvalid}
+	})
+}
+
+// includes reports whether rel or one of its parent directories
+// matches an Include pattern.
+func (c *copier) includes(rel string) bool {
+	for ; rel != "."; rel = path.Dir(rel) {
+		if matchAny(c.opts.Include, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// mkdirParent creates the parent directories of target when directories
+// are only created for included files.
+func (c *copier) mkdirParent(target string) error {
+	if len(c.opts.Include) == 0 {
+		return nil
+	}
+	return mkdirParent(c.dst, target)
+}
+
+// overwrite reports whether target should be written according to the
+// overwrite policy, an existing symbolic link is removed before it is replaced.
+func (c *copier) overwrite(target string) (bool, error) {
+	info, err := Lstat(c.dst, target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	switch c.opts.Overwrite {
+	case OverwriteSkip:
+		return false, nil
+	case OverwriteError:
+		return false, &fs.PathError{Op: "copy", Path: target, Err: fs.ErrExist}
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return true, c.dst.Remove(target)
+	}
+	return true, nil
+}
+
+func (c *copier) copyFile(target, name, rel string, info fs.FileInfo) error {
+	if ok, err := c.overwrite(target); !ok || err != nil {
+		return err
+	}
+	if err := copyFile(c.dst, target, c.src, name, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := copyMeta(c.dst, target, info); err != nil {
+		return err
+	}
+	if c.opts.Progress != nil {
+		c.opts.Progress(rel, info.Size())
+	}
+	return nil
+}
+
+func (c *copier) copySymlink(target, name string) error {
+	dest, err := Readlink(c.src, name)
+	if err != nil {
+		return err
+	}
+	if ok, err := c.overwrite(target); !ok || err != nil {
+		return err
+	}
+	if err := c.dst.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return Symlink(c.dst, dest, target)
+}
+
+// copyMeta applies the mode and modification time of info to name in dst
+// where dst supports it.
+func copyMeta(dst FS, name string, info fs.FileInfo) error {
+	err := Chmod(dst, name, info.Mode()&chmodMask)
+	if err == nil && !info.ModTime().IsZero() {
+		err = Chtimes(dst, name, time.Time{}, info.ModTime())
+	}
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	return err
+}
+
+// copyFile copies the contents of the file srcName in src to dstName in dst,
+// creating or truncating it with mode perm.
+func copyFile(dst FileFS, dstName string, src fs.FS, srcName string, perm fs.FileMode) error {
+	r, err := src.Open(srcName)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := dst.OpenFile(dstName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	if err1 := w.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
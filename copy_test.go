@@ -0,0 +1,204 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestCopyFile(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	src := fstest.MapFS{
+		"file": &fstest.MapFile{Data: []byte("Hello"), Mode: 0600, ModTime: mtime},
+		"dir":  &fstest.MapFile{Mode: fs.ModeDir | 0755},
+	}
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			name := filepath.Join(base, "copy")
+			if err := wfs.CopyFile(fsys, name, src, "file", nil); err != nil {
+				t.Fatalf("CopyFile failed: %v", err)
+			}
+			b, err := fs.ReadFile(fsys, name)
+			if err != nil || string(b) != "Hello" {
+				t.Errorf("expected 'Hello', got %q err: %v", b, err)
+			}
+			info, err := fs.Stat(fsys, name)
+			if err != nil || info.Mode() != 0600 || !info.ModTime().Equal(mtime) {
+				t.Errorf("expected mode and modification time to be preserved, got %v err: %v", info, err)
+			}
+			if err := wfs.CopyFile(fsys, name, src, "dir", nil); err == nil {
+				t.Errorf("expected CopyFile to fail for a directory")
+			}
+		})
+	}
+}
+
+func TestCopyFS(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	src := fstest.MapFS{
+		"a.txt":           &fstest.MapFile{Data: []byte("a"), ModTime: mtime},
+		"dir/b.txt":       &fstest.MapFile{Data: []byte("b"), Mode: 0600},
+		"dir/empty":       &fstest.MapFile{Mode: fs.ModeDir | 0755},
+		"readonly":        &fstest.MapFile{Mode: fs.ModeDir | 0555, ModTime: mtime},
+		"readonly/c.txt":  &fstest.MapFile{Data: []byte("c"), Mode: 0444},
+		"dir/nested/d.md": &fstest.MapFile{Data: []byte("d")},
+	}
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"out/a.txt": &fstest.MapFile{Data: []byte("old")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer func() {
+				// allow the copied read-only directory to be cleaned up
+				wfs.Chmod(fsys, filepath.Join(base, "out/readonly"), 0755)
+				cleanup()
+			}()
+
+			out := filepath.Join(base, "out")
+			if err := wfs.CopyFS(fsys, out, src, nil); err != nil {
+				t.Fatalf("CopyFS failed: %v", err)
+			}
+			for name, file := range src {
+				info, err := fs.Stat(fsys, filepath.Join(out, name))
+				if err != nil {
+					t.Errorf("expected %q to be copied: %v", name, err)
+					continue
+				}
+				if info.IsDir() != file.Mode.IsDir() || file.Mode.Perm() != 0 && info.Mode().Perm() != file.Mode.Perm() {
+					t.Errorf("expected %q to have mode %v, got %v", name, file.Mode, info.Mode())
+				}
+				if !file.ModTime.IsZero() && !info.ModTime().Equal(file.ModTime) {
+					t.Errorf("expected %q to have modification time %v, got %v", name, file.ModTime, info.ModTime())
+				}
+				if !file.Mode.IsDir() {
+					b, err := fs.ReadFile(fsys, filepath.Join(out, name))
+					if err != nil || string(b) != string(file.Data) {
+						t.Errorf("expected %q to contain %q, got %q err: %v", name, file.Data, b, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCopyFSOptions(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"main.go":            &fstest.MapFile{Data: []byte("main")},
+				"README.md":          &fstest.MapFile{Data: []byte("readme")},
+				"pkg/util.go":        &fstest.MapFile{Data: []byte("util")},
+				"pkg/util_test.go":   &fstest.MapFile{Data: []byte("test")},
+				"vendor/dep/dep.go":  &fstest.MapFile{Data: []byte("dep")},
+				"static/css/app.css": &fstest.MapFile{Data: []byte("css")},
+				"docs/guide.md":      &fstest.MapFile{Data: []byte("guide")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			if err := wfs.Symlink(fsys, "docs", filepath.Join(base, "link")); err != nil {
+				t.Fatalf("failed to create symlink: %v", err)
+			}
+			src := fsys
+			if base != "" {
+				if src, err = wfs.Sub(fsys, filepath.ToSlash(base)); err != nil {
+					t.Fatalf("failed to create sub file system: %v", err)
+				}
+			}
+
+			// filters
+			dst := wfs.Mem()
+			var progress []string
+			err = wfs.CopyFS(dst, "out", src, &wfs.CopyOptions{
+				Include:  []string{"*.go", "static"},
+				Exclude:  []string{"*_test.go", "vendor"},
+				Symlinks: wfs.SymlinkSkip,
+				Progress: func(name string, size int64) {
+					progress = append(progress, name)
+				},
+			})
+			if err != nil {
+				t.Fatalf("CopyFS failed: %v", err)
+			}
+			var copied []string
+			fs.WalkDir(dst, "out", func(name string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					copied = append(copied, name)
+				}
+				return err
+			})
+			expected := []string{"out/main.go", "out/pkg/util.go", "out/static/css/app.css"}
+			if !slices.Equal(copied, expected) {
+				t.Errorf("expected %v to be copied, got %v", expected, copied)
+			}
+			slices.Sort(progress)
+			if !slices.Equal(progress, []string{"main.go", "pkg/util.go", "static/css/app.css"}) {
+				t.Errorf("unexpected progress %v", progress)
+			}
+			if ok, _ := wfs.Exists(dst, "out/docs"); ok {
+				t.Errorf("expected directories without included files not to be created")
+			}
+
+			// symlinks
+			dst = wfs.Mem()
+			if err := wfs.CopyFS(dst, ".", src, &wfs.CopyOptions{Include: []string{"link"}}); err != nil {
+				t.Fatalf("CopyFS failed: %v", err)
+			}
+			if target, err := wfs.Readlink(dst, "link"); err != nil || target != "docs" {
+				t.Errorf("expected symlink to be recreated, got %q err: %v", target, err)
+			}
+			dst = wfs.Mem()
+			if err := wfs.CopyFS(dst, ".", src, &wfs.CopyOptions{Include: []string{"link"}, Symlinks: wfs.SymlinkFollow}); err != nil {
+				t.Fatalf("CopyFS failed: %v", err)
+			}
+			if info, err := wfs.Lstat(dst, "link"); err != nil || !info.IsDir() {
+				t.Errorf("expected symlink to be followed, got %v err: %v", info, err)
+			}
+			if b, err := fs.ReadFile(dst, "link/guide.md"); err != nil || string(b) != "guide" {
+				t.Errorf("expected 'guide', got %q err: %v", b, err)
+			}
+
+			// overwrite
+			dst = wfs.Mem()
+			wfs.WriteFile(dst, "README.md", []byte("existing"), 0644)
+			opts := &wfs.CopyOptions{Exclude: []string{"link"}, Overwrite: wfs.OverwriteSkip}
+			if err := wfs.CopyFS(dst, ".", src, opts); err != nil {
+				t.Fatalf("CopyFS failed: %v", err)
+			}
+			if b, _ := fs.ReadFile(dst, "README.md"); string(b) != "existing" {
+				t.Errorf("expected existing file to be kept, got %q", b)
+			}
+			opts.Overwrite = wfs.OverwriteError
+			if err := wfs.CopyFS(dst, ".", src, opts); !errors.Is(err, fs.ErrExist) {
+				t.Errorf("expected ErrExist, got %v", err)
+			}
+			opts.Overwrite = wfs.OverwriteReplace
+			if err := wfs.CopyFile(dst, "README.md", src, "README.md", opts); err != nil {
+				t.Fatalf("CopyFile failed: %v", err)
+			}
+			if b, _ := fs.ReadFile(dst, "README.md"); string(b) != "readme" {
+				t.Errorf("expected existing file to be replaced, got %q", b)
+			}
+
+			if err := wfs.CopyFS(dst, ".", src, &wfs.CopyOptions{Include: []string{"["}}); err == nil {
+				t.Errorf("expected CopyFS to fail for a bad pattern")
+			}
+		})
+	}
+}
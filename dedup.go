@@ -0,0 +1,535 @@
+package wfs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dedupMagic starts the manifests of deduplicated files, followed by the
+// size of the file and a line with the digest and size of every chunk.
+const dedupMagic = "\x00wfsdedup "
+
+var errBadManifest = errors.New("invalid chunk manifest")
+
+// DedupFS is a file system storing the content of its files as deduplicated
+// chunks.
+type DedupFS interface {
+	FS
+
+	// Stats walks the file system and reports how much space deduplication
+	// saves.
+	Stats() (DedupStats, error)
+
+	// GC removes the chunks that no file refers to, along with the temporary
+	// files left by interrupted writes.
+	GC() (GCStats, error)
+}
+
+// DedupStats describes the files of a [DedupFS].
+type DedupStats struct {
+	// Files is the number of files stored as chunks.
+	Files int
+
+	// Bytes is the total size of the files.
+	Bytes int64
+
+	// Chunks is the number of unique chunks the files refer to.
+	Chunks int
+
+	// StoredBytes is the total size of the unique chunks.
+	StoredBytes int64
+}
+
+// Ratio returns the deduplication ratio, the size of the files divided by
+// the size of their unique chunks, or 1 if no chunks are stored.
+func (s DedupStats) Ratio() float64 {
+	if s.StoredBytes == 0 {
+		return 1
+	}
+	return float64(s.Bytes) / float64(s.StoredBytes)
+}
+
+// DedupOption configures the file system returned by [Dedup].
+type DedupOption func(*dedupFs)
+
+// FixedChunks splits files into chunks of size bytes. Fixed chunks are
+// cheap to compute but inserting or removing bytes shifts every following
+// chunk, so they suit files modified in place such as disk images.
+func FixedChunks(size int) DedupOption {
+	if size <= 0 {
+		panic("wfs: FixedChunks size must be positive")
+	}
+	return func(f *dedupFs) { f.chunker = chunker{max: size} }
+}
+
+// ContentChunks splits files into chunks of min to max bytes, averaging avg
+// bytes, at boundaries chosen by a rolling hash of the content, so that
+// inserting or removing bytes only changes the chunks around them. It panics
+// unless 0 < min < avg < max.
+func ContentChunks(min, avg, max int) DedupOption {
+	if min <= 0 || min >= avg || avg >= max {
+		panic("wfs: ContentChunks requires 0 < min < avg < max")
+	}
+	return func(f *dedupFs) { f.chunker = chunker{min: min, max: max, threshold: math.MaxUint64 / uint64(avg-min)} }
+}
+
+type dedupFs struct {
+	fsys    FS
+	blobs   *blobStore
+	chunker chunker
+}
+
+// Dedup returns a file system storing the content of the files of fsys as
+// chunks in store, where every unique chunk is stored once as a blob named
+// by its SHA-256 digest. Files in fsys are replaced by manifests listing
+// their chunks, so many similar copies of a file, as written by backups,
+// only take the space of the chunks that differ. Files of fsys that are not
+// manifests are read as they are and replaced by manifests when written.
+//
+// Files are split with [ContentChunks] of 16 KiB to 256 KiB averaging
+// 64 KiB unless another chunking option is given. Files opened for writing
+// are written to a temporary copy in store/tmp and chunked when the file is
+// synced or closed. Chunks are never removed when files are removed or
+// overwritten, call GC to reclaim their space. store must not be inside
+// fsys, and GC must not run while files are written by another process
+// using the same store.
+func Dedup(fsys FS, store FS, opts ...DedupOption) (DedupFS, error) {
+	blobs, err := newBlobStore(store)
+	if err != nil {
+		return nil, err
+	}
+	f := &dedupFs{fsys: fsys, blobs: blobs}
+	ContentChunks(16<<10, 64<<10, 256<<10)(f)
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f, nil
+}
+
+// chunker splits content at fixed offsets of max bytes if threshold is
+// zero, and with a gear hash otherwise, cutting after the first byte past
+// min where the hash is below threshold.
+type chunker struct {
+	min, max  int
+	threshold uint64
+}
+
+// gear maps bytes to the random values mixed into the rolling hash. It is
+// generated with splitmix64 from a fixed seed, so that chunk boundaries
+// never change.
+var gear = func() (t [256]uint64) {
+	x := uint64(0)
+	for i := range t {
+		x += 0x9e3779b97f4a7c15
+		z := (x ^ x>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		t[i] = z ^ z>>31
+	}
+	return t
+}()
+
+// cut returns the length of the chunk starting data, where data holds max
+// bytes unless it ends the content.
+func (c chunker) cut(data []byte) int {
+	n := min(len(data), c.max)
+	if c.threshold == 0 || n <= c.min {
+		return n
+	}
+	var h uint64
+	for i := c.min; i < n; i++ {
+		h = h<<1 + gear[data[i]]
+		if h < c.threshold {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// split calls fn with the chunks of the content of r. The chunk is only
+// valid until fn returns.
+func (c chunker) split(r io.Reader, fn func(chunk []byte) error) error {
+	br := bufio.NewReaderSize(r, c.max)
+	for {
+		data, err := br.Peek(c.max)
+		if len(data) == 0 {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		n := c.cut(data)
+		if err := fn(data[:n]); err != nil {
+			return err
+		}
+		br.Discard(n)
+	}
+}
+
+// dedupChunk is a chunk of a file starting at off.
+type dedupChunk struct {
+	hash string
+	off  int64
+	size int64
+}
+
+// readManifest returns the chunks and size of the named file, reporting
+// whether the file is a manifest.
+func (f *dedupFs) readManifest(name string) ([]dedupChunk, int64, bool, error) {
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer file.Close()
+	// plain files are only read up to the length of the magic
+	data := make([]byte, len(dedupMagic))
+	n, err := io.ReadFull(file, data)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, 0, false, err
+	}
+	if string(data[:n]) != dedupMagic {
+		info, err := file.Stat()
+		if err != nil {
+			return nil, 0, false, err
+		}
+		return nil, info.Size(), false, nil
+	}
+	data, err = io.ReadAll(file)
+	if err != nil {
+		return nil, 0, true, err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	size, err := strconv.ParseInt(lines[0], 10, 64)
+	if err != nil {
+		return nil, 0, true, &fs.PathError{Op: "open", Path: name, Err: errBadManifest}
+	}
+	chunks := make([]dedupChunk, 0, len(lines)-1)
+	var off int64
+	for _, line := range lines[1:] {
+		hash, s, ok := strings.Cut(line, " ")
+		n, err := strconv.ParseInt(s, 10, 64)
+		if _, err1 := hex.DecodeString(hash); !ok || err != nil || err1 != nil || len(hash) != 64 || n <= 0 {
+			return nil, 0, true, &fs.PathError{Op: "open", Path: name, Err: errBadManifest}
+		}
+		chunks = append(chunks, dedupChunk{hash, off, n})
+		off += n
+	}
+	if off != size {
+		return nil, 0, true, &fs.PathError{Op: "open", Path: name, Err: errBadManifest}
+	}
+	return chunks, size, true, nil
+}
+
+// size returns the size of the content of the named file, only reading the
+// first line of manifests.
+func (f *dedupFs) size(name string) (int64, error) {
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	head := make([]byte, len(dedupMagic)+20)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, err
+	}
+	if !bytes.HasPrefix(head[:n], []byte(dedupMagic)) {
+		info, err := file.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	s, _, _ := strings.Cut(string(head[len(dedupMagic):n]), "\n")
+	size, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, &fs.PathError{Op: "stat", Path: name, Err: errBadManifest}
+	}
+	return size, nil
+}
+
+func (f *dedupFs) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for dedupFs.
+func (f *dedupFs) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(f.fsys, name)
+	if err != nil || !info.Mode().IsRegular() {
+		return info, err
+	}
+	size, err := f.size(name)
+	if err != nil {
+		return nil, err
+	}
+	return sizedInfo{info, info.Name(), size}, nil
+}
+
+func (f *dedupFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if flag&os.O_CREATE != 0 {
+		file, err := f.fsys.OpenFile(name, os.O_WRONLY|flag&(os.O_CREATE|os.O_EXCL), perm)
+		if err != nil {
+			return nil, err
+		}
+		file.Close()
+	}
+	info, err := fs.Stat(f.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	write := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	if info.IsDir() {
+		if write {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: ErrIsDir}
+		}
+		dir, err := f.fsys.OpenFile(name, os.O_RDONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &sizedDir{File: dir, name: name, dir: name, size: f.size}, nil
+	}
+	chunks, size, ok, err := f.readManifest(name)
+	if err != nil {
+		return nil, err
+	}
+	var content File
+	if ok {
+		content = &chunkReader{blobs: f.blobs, name: name, info: info, chunks: chunks, size: size, cached: -1}
+	} else if content, err = f.fsys.OpenFile(name, os.O_RDONLY, 0); err != nil {
+		return nil, err
+	}
+	file := &stagedFile{File: content, blobs: f.blobs, name: name, flag: flag, info: info}
+	if !write {
+		return file, nil
+	}
+	defer content.Close()
+	var r io.Reader
+	if flag&os.O_TRUNC == 0 {
+		r = content
+	}
+	if file.File, err = f.blobs.tempCopy(r); err != nil {
+		return nil, fixErr(err, name)
+	}
+	file.dirty = flag&os.O_TRUNC != 0 && size > 0
+	file.commit = func(tmp File, keep bool) error { return f.commit(name, tmp) }
+	return file, nil
+}
+
+// commit stores the chunks of the temporary copy tmp of the named file and
+// replaces the file with their manifest.
+func (f *dedupFs) commit(name string, tmp File) error {
+	f.blobs.gc.RLock()
+	defer f.blobs.gc.RUnlock()
+	info, err := tmp.Stat()
+	if err != nil {
+		return err
+	}
+	var manifest bytes.Buffer
+	fmt.Fprintf(&manifest, "%s%d\n", dedupMagic, info.Size())
+	err = f.chunker.split(io.NewSectionReader(tmp, 0, info.Size()), func(chunk []byte) error {
+		hash, err := f.blobs.put(chunk)
+		fmt.Fprintf(&manifest, "%s %d\n", hash, len(chunk))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	file, err := f.fsys.OpenFile(name, os.O_WRONLY|os.O_TRUNC, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		// the file was removed or renamed while open
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(manifest.Bytes())
+	if err1 := file.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+func (f *dedupFs) Rename(oldpath, newpath string) error {
+	return f.fsys.Rename(oldpath, newpath)
+}
+
+func (f *dedupFs) Remove(name string) error {
+	return f.fsys.Remove(name)
+}
+
+func (f *dedupFs) RemoveAll(path string) error {
+	return f.fsys.RemoveAll(path)
+}
+
+func (f *dedupFs) Mkdir(name string, perm fs.FileMode) error {
+	return f.fsys.Mkdir(name, perm)
+}
+
+func (f *dedupFs) MkdirAll(path string, perm fs.FileMode) error {
+	return f.fsys.MkdirAll(path, perm)
+}
+
+// walkManifests calls fn with the chunks and size of every manifest in fsys.
+func (f *dedupFs) walkManifests(fn func(chunks []dedupChunk, size int64)) error {
+	return fs.WalkDir(f.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		chunks, size, ok, err := f.readManifest(name)
+		if ok && err == nil {
+			fn(chunks, size)
+		}
+		return err
+	})
+}
+
+func (f *dedupFs) Stats() (DedupStats, error) {
+	var stats DedupStats
+	seen := make(map[string]bool)
+	err := f.walkManifests(func(chunks []dedupChunk, size int64) {
+		stats.Files++
+		stats.Bytes += size
+		for _, c := range chunks {
+			if !seen[c.hash] {
+				seen[c.hash] = true
+				stats.Chunks++
+				stats.StoredBytes += c.size
+			}
+		}
+	})
+	return stats, err
+}
+
+func (f *dedupFs) GC() (GCStats, error) {
+	f.blobs.gc.Lock()
+	defer f.blobs.gc.Unlock()
+	refs := make(map[string]bool)
+	err := f.walkManifests(func(chunks []dedupChunk, size int64) {
+		for _, c := range chunks {
+			refs[c.hash] = true
+		}
+	})
+	if err != nil {
+		return GCStats{}, err
+	}
+	return f.blobs.collect(refs)
+}
+
+// chunkReader reads the content of a file from its chunks. The last chunk
+// read is cached, so that reads smaller than a chunk only load it once.
+type chunkReader struct {
+	blobs  *blobStore
+	name   string
+	info   fs.FileInfo
+	chunks []dedupChunk
+	size   int64
+	offset int64
+	cached int
+	chunk  []byte
+}
+
+// load returns the content of chunk i.
+func (r *chunkReader) load(i int) ([]byte, error) {
+	if i == r.cached {
+		return r.chunk, nil
+	}
+	c := r.chunks[i]
+	data, err := fs.ReadFile(r.blobs.store, blobPath(c.hash))
+	if err != nil {
+		return nil, fixErr(err, r.name)
+	}
+	if int64(len(data)) != c.size {
+		return nil, &fs.PathError{Op: "read", Path: r.name, Err: errBadManifest}
+	}
+	r.cached, r.chunk = i, data
+	return data, nil
+}
+
+func (r *chunkReader) readAt(b []byte, off int64) (int, error) {
+	n := 0
+	for n < len(b) && off+int64(n) < r.size {
+		pos := off + int64(n)
+		i := sort.Search(len(r.chunks), func(i int) bool { return r.chunks[i].off+r.chunks[i].size > pos })
+		data, err := r.load(i)
+		if err != nil {
+			return n, err
+		}
+		n += copy(b[n:], data[pos-r.chunks[i].off:])
+	}
+	return n, nil
+}
+
+func (r *chunkReader) Read(b []byte) (int, error) {
+	n, err := r.readAt(b, r.offset)
+	r.offset += int64(n)
+	if err == nil && n == 0 && len(b) > 0 {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *chunkReader) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: r.name, Err: errors.New("negative offset")}
+	}
+	n, err := r.readAt(b, off)
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *chunkReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	case io.SeekStart:
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: r.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: r.name, Err: fs.ErrInvalid}
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *chunkReader) Stat() (fs.FileInfo, error) {
+	return sizedInfo{r.info, path.Base(r.name), r.size}, nil
+}
+
+func (r *chunkReader) Write(b []byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: r.name, Err: errBadFile}
+}
+
+func (r *chunkReader) WriteString(s string) (int, error) {
+	return r.Write([]byte(s))
+}
+
+func (r *chunkReader) WriteAt(b []byte, off int64) (int, error) {
+	return r.Write(b)
+}
+
+func (r *chunkReader) Truncate(size int64) error {
+	return &fs.PathError{Op: "truncate", Path: r.name, Err: fs.ErrInvalid}
+}
+
+func (r *chunkReader) ReadDir(n int) ([]fs.DirEntry, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: r.name, Err: ErrNotDir}
+}
+
+func (r *chunkReader) Sync() error  { return nil }
+func (r *chunkReader) Name() string { return r.name }
+func (r *chunkReader) Close() error { return nil }
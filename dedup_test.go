@@ -0,0 +1,110 @@
+package wfs_test
+
+import (
+	"bytes"
+	"io/fs"
+	"math/rand"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestDedup(t *testing.T) {
+	mem, store := wfs.Mem(), wfs.Mem()
+	if err := wfs.WriteFile(mem, "plain.txt", []byte("written before"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	fsys, err := wfs.Dedup(mem, store)
+	if err != nil {
+		t.Fatalf("Dedup failed: %v", err)
+	}
+
+	// a backup and a copy of it with a few bytes inserted in the middle
+	base := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(base)
+	edited := append(append(bytes.Clone(base[:500000]), "inserted"...), base[500000:]...)
+	if err := fsys.MkdirAll("backups", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	for name, content := range map[string][]byte{
+		"backups/monday.bak":  base,
+		"backups/tuesday.bak": edited,
+		"backups/empty.bak":   nil,
+	} {
+		if err := wfs.WriteFile(fsys, name, content, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	assertContent(t, fsys, "backups/tuesday.bak", string(edited))
+	assertContent(t, fsys, "plain.txt", "written before")
+	assertEntries(t, fsys, "backups", "empty.bak", "monday.bak", "tuesday.bak")
+	if info, err := fs.Stat(fsys, "backups/tuesday.bak"); err != nil || info.Size() != int64(len(edited)) {
+		t.Errorf("unexpected info %v err: %v", info, err)
+	}
+	if err := fstest.TestFS(fsys, "plain.txt", "backups/monday.bak", "backups/tuesday.bak", "backups/empty.bak"); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := fsys.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Files != 3 || stats.Bytes != int64(len(base)+len(edited)) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	// only the chunks around the insertion differ
+	if stats.StoredBytes > int64(len(base))+300<<10 || stats.Ratio() < 1.5 {
+		t.Errorf("expected the copies to share most chunks, got %+v ratio %.2f", stats, stats.Ratio())
+	}
+
+	if err := fsys.Remove("backups/monday.bak"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	gc, err := fsys.GC()
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if gc.Blobs == 0 || gc.Bytes >= 300<<10 {
+		t.Errorf("expected only the chunks of the removed backup to be collected, got %+v", gc)
+	}
+	assertContent(t, fsys, "backups/tuesday.bak", string(edited))
+}
+
+func TestDedupFixedChunks(t *testing.T) {
+	mem := wfs.Mem()
+	fsys, err := wfs.Dedup(mem, wfs.Mem(), wfs.FixedChunks(4096))
+	if err != nil {
+		t.Fatalf("Dedup failed: %v", err)
+	}
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	if err := wfs.WriteFile(fsys, "a", content, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// files written in place only store the chunks that changed
+	f, err := fsys.OpenFile("a", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.WriteAt([]byte("XXXX"), 5000)
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	copy(content[5000:], "XXXX")
+	assertContent(t, fsys, "a", string(content))
+	stats, err := fsys.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	// the other three chunks of the file are equal
+	if stats.Chunks != 2 || stats.StoredBytes != 8192 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// the underlying file only holds the manifest
+	if info, err := fs.Stat(mem, "a"); err != nil || info.Size() >= 4096 {
+		t.Errorf("expected a manifest, got %v err: %v", info, err)
+	}
+}
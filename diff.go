@@ -0,0 +1,147 @@
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+)
+
+// ChangeKind is the kind of a [Change].
+type ChangeKind int
+
+const (
+	// ChangeAdded is a path present in b but not in a.
+	ChangeAdded ChangeKind = iota + 1
+
+	// ChangeRemoved is a path present in a but not in b.
+	ChangeRemoved
+
+	// ChangeModified is a path present in both trees that differs.
+	ChangeModified
+)
+
+// String returns the name of the change, such as "added".
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change is a difference between two trees reported by [Diff].
+type Change struct {
+	Kind ChangeKind
+	Path string      // slash-separated path relative to the root
+	A    fs.FileInfo // the entry in a, nil if added
+	B    fs.FileInfo // the entry in b, nil if removed
+}
+
+// String returns a description of the change, such as "modified a/b.txt".
+func (c Change) String() string {
+	return c.Kind.String() + " " + c.Path
+}
+
+// DiffOptions configures [Diff].
+// A nil *DiffOptions is equivalent to the zero value.
+type DiffOptions struct {
+	// Hash compares regular files of the same size by their SHA-256 digest
+	// instead of their modification time, so files rewritten with the same
+	// content are not modified.
+	Hash bool
+}
+
+// Diff compares the trees rooted at root in a and b and returns the paths
+// added, removed and modified from a to b, sorted by path. Every path of an
+// added or removed directory is reported.
+//
+// Entries of different types, permissions or symbolic link destinations are
+// modified, as are regular files of different sizes or modification times,
+// or contents with [DiffOptions.Hash]. Modification times of directories are
+// ignored. A missing root is an empty tree.
+func Diff(a, b fs.FS, root string, opts *DiffOptions) ([]Change, error) {
+	var o DiffOptions
+	if opts != nil {
+		o = *opts
+	}
+	infosA, err := diffList(a, root)
+	if err != nil {
+		return nil, err
+	}
+	infosB, err := diffList(b, root)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	names := slices.Concat(slices.Collect(maps.Keys(infosA)), slices.Collect(maps.Keys(infosB)))
+	slices.Sort(names)
+	for _, rel := range slices.Compact(names) {
+		ia, ib := infosA[rel], infosB[rel]
+		switch {
+		case ia == nil:
+			changes = append(changes, Change{ChangeAdded, rel, nil, ib})
+		case ib == nil:
+			changes = append(changes, Change{ChangeRemoved, rel, ia, nil})
+		default:
+			modified, err := diffModified(a, b, path.Join(root, rel), ia, ib, o)
+			if err != nil {
+				return nil, err
+			}
+			if modified {
+				changes = append(changes, Change{ChangeModified, rel, ia, ib})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// diffList returns the entries of the tree rooted at root keyed by their
+// path relative to root, without root itself.
+func diffList(fsys fs.FS, root string) (map[string]fs.FileInfo, error) {
+	infos := make(map[string]fs.FileInfo)
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if name == root && errors.Is(err, fs.ErrNotExist) {
+			return fs.SkipAll
+		}
+		if err != nil || name == root {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		infos[relPath(root, name)] = info
+		return nil
+	})
+	return infos, err
+}
+
+// diffModified reports whether the entry name differs between a and b.
+func diffModified(a, b fs.FS, name string, ia, ib fs.FileInfo, o DiffOptions) (bool, error) {
+	if ia.Mode() != ib.Mode() {
+		return true, nil
+	}
+	switch {
+	case ia.Mode()&fs.ModeSymlink != 0:
+		da, err := Readlink(a, name)
+		if err != nil {
+			return false, err
+		}
+		db, err := Readlink(b, name)
+		return da != db, err
+	case !ia.Mode().IsRegular():
+		return false, nil
+	case ia.Size() != ib.Size():
+		return true, nil
+	case !o.Hash:
+		return !ia.ModTime().Equal(ib.ModTime()), nil
+	}
+	same, err := sameContent(a, name, ia, b, name)
+	return !same, err
+}
@@ -0,0 +1,65 @@
+package wfs_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestDiff(t *testing.T) {
+	a := wfs.Mem()
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.MkdirAll("site/old", 0755)
+	for name, content := range map[string]string{
+		"site/index.html":   "home",
+		"site/about.html":   "about",
+		"site/style.css":    "body{}",
+		"site/old/page.txt": "old",
+		"outside.txt":       "ignored",
+	} {
+		wfs.WriteFile(a, name, []byte(content), 0644)
+		wfs.Chtimes(a, name, mtime, mtime)
+	}
+	b := wfs.Mem()
+	if err := wfs.CopyFS(b, ".", a, nil); err != nil {
+		t.Fatalf("CopyFS failed: %v", err)
+	}
+
+	wfs.WriteFile(b, "site/index.html", []byte("new home"), 0644)
+	// rewritten with the same content
+	wfs.WriteFile(b, "site/about.html", []byte("about"), 0644)
+	wfs.Chmod(b, "site/style.css", 0600)
+	b.RemoveAll("site/old")
+	wfs.WriteFile(b, "site/new.html", nil, 0644)
+	wfs.WriteFile(b, "outside.txt", []byte("changed"), 0644)
+
+	tests := []struct {
+		name     string
+		opts     *wfs.DiffOptions
+		expected string
+	}{
+		{"ModTime", nil, "[modified about.html modified index.html added new.html removed old removed old/page.txt modified style.css]"},
+		{"Hash", &wfs.DiffOptions{Hash: true}, "[modified index.html added new.html removed old removed old/page.txt modified style.css]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := wfs.Diff(a, b, "site", tt.opts)
+			if err != nil {
+				t.Fatalf("Diff failed: %v", err)
+			}
+			if got := fmt.Sprint(changes); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	if changes, err := wfs.Diff(a, a, ".", nil); err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes, got %v err: %v", changes, err)
+	}
+	changes, err := wfs.Diff(wfs.Mem(), a, "site/old", nil)
+	if got, expected := fmt.Sprint(changes), "[added page.txt]"; err != nil || got != expected {
+		t.Errorf("expected %s, got %s err: %v", expected, got, err)
+	}
+}
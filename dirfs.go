@@ -0,0 +1,278 @@
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type dirFs string
+
+// Dir returns a writable file system for the tree of files rooted at
+// the directory root.
+//
+// Like [os.DirFS], names are slash-separated paths relative to root as
+// accepted by [fs.ValidPath], so code written against DirFS is portable
+// between the OS and in-memory backends.
+func Dir(root string) FS {
+	return dirFs(root)
+}
+
+// join returns the OS path of name in dir.
+func (dir dirFs) join(op, name string) (string, error) {
+	if dir == "" {
+		return "", errors.New("wfs: Dir with empty root")
+	}
+	local, err := filepath.Localize(name)
+	if err != nil || !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return string(dir), nil
+	}
+	return string(dir) + string(os.PathSeparator) + local, nil
+}
+
+// joinChild is like join but rejects the root directory itself.
+func (dir dirFs) joinChild(op, name string) (string, error) {
+	if name == "." {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return dir.join(op, name)
+}
+
+// pathErr replaces the OS path reported by err with name
+// and annotates it with the portable errors of this package.
+func pathErr(err error, name string) error {
+	if pe, ok := err.(*fs.PathError); ok {
+		pe.Path = name
+	}
+	return normErr(err)
+}
+
+func (dir dirFs) Open(name string) (fs.File, error) {
+	full, err := dir.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		return nil, pathErr(err, name)
+	}
+	return &osFile{f, name}, nil
+}
+
+// Stat implements [fs.StatFS] for dirFs.
+func (dir dirFs) Stat(name string) (fs.FileInfo, error) {
+	full, err := dir.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return nil, pathErr(err, name)
+	}
+	return info, nil
+}
+
+func (dir dirFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	full, err := dir.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(full, flag, perm)
+	if err != nil {
+		return nil, pathErr(err, name)
+	}
+	return &osFile{f, name}, nil
+}
+
+func (dir dirFs) Rename(oldpath, newpath string) error {
+	oldfull, err1 := dir.joinChild("rename", oldpath)
+	newfull, err2 := dir.joinChild("rename", newpath)
+	if err1 != nil || err2 != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	if err := os.Rename(oldfull, newfull); err != nil {
+		if le, ok := err.(*os.LinkError); ok {
+			le.Old, le.New = oldpath, newpath
+		}
+		return normErr(err)
+	}
+	return nil
+}
+
+func (dir dirFs) Remove(name string) error {
+	full, err := dir.joinChild("remove", name)
+	if err != nil {
+		return err
+	}
+	return pathErr(os.Remove(full), name)
+}
+
+func (dir dirFs) RemoveAll(path string) error {
+	full, err := dir.joinChild("RemoveAll", path)
+	if err != nil {
+		return err
+	}
+	return pathErr(os.RemoveAll(full), path)
+}
+
+func (dir dirFs) Mkdir(name string, perm fs.FileMode) error {
+	full, err := dir.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return pathErr(os.Mkdir(full, perm), name)
+}
+
+func (dir dirFs) MkdirAll(path string, perm fs.FileMode) error {
+	full, err := dir.join("mkdir", path)
+	if err != nil {
+		return err
+	}
+	return pathErr(os.MkdirAll(full, perm), path)
+}
+
+// Symlink creates newname as a symbolic link to oldname.
+// The destination oldname is stored as given and is resolved
+// relative to the directory of newname.
+func (dir dirFs) Symlink(oldname, newname string) error {
+	full, err := dir.joinChild("symlink", newname)
+	if err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	if err := os.Symlink(filepath.FromSlash(oldname), full); err != nil {
+		if le, ok := err.(*os.LinkError); ok {
+			le.New = newname
+		}
+		return normErr(err)
+	}
+	return nil
+}
+
+func (dir dirFs) Readlink(name string) (string, error) {
+	full, err := dir.join("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	target, err := os.Readlink(full)
+	if err != nil {
+		return "", pathErr(err, name)
+	}
+	return filepath.ToSlash(target), nil
+}
+
+func (dir dirFs) Lstat(name string) (fs.FileInfo, error) {
+	full, err := dir.join("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(full)
+	if err != nil {
+		return nil, pathErr(err, name)
+	}
+	return info, nil
+}
+
+func (dir dirFs) Link(oldname, newname string) error {
+	oldfull, err1 := dir.joinChild("link", oldname)
+	newfull, err2 := dir.joinChild("link", newname)
+	if err1 != nil || err2 != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	if err := os.Link(oldfull, newfull); err != nil {
+		if le, ok := err.(*os.LinkError); ok {
+			le.Old, le.New = oldname, newname
+		}
+		return normErr(err)
+	}
+	return nil
+}
+
+func (dir dirFs) Chmod(name string, mode fs.FileMode) error {
+	full, err := dir.join("chmod", name)
+	if err != nil {
+		return err
+	}
+	return pathErr(os.Chmod(full, mode), name)
+}
+
+func (dir dirFs) Chown(name string, uid, gid int) error {
+	full, err := dir.join("chown", name)
+	if err != nil {
+		return err
+	}
+	return pathErr(os.Chown(full, uid, gid), name)
+}
+
+func (dir dirFs) Chtimes(name string, atime, mtime time.Time) error {
+	full, err := dir.join("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return pathErr(os.Chtimes(full, atime, mtime), name)
+}
+
+func (dir dirFs) Lock(name string) (Unlocker, error) {
+	return dir.lock("lock", name, true, true)
+}
+
+func (dir dirFs) RLock(name string) (Unlocker, error) {
+	return dir.lock("rlock", name, false, true)
+}
+
+func (dir dirFs) TryLock(name string) (Unlocker, error) {
+	return dir.lock("lock", name, true, false)
+}
+
+func (dir dirFs) lock(op, name string, exclusive, block bool) (Unlocker, error) {
+	full, err := dir.join(op, name)
+	if err != nil {
+		return nil, err
+	}
+	return lockOSFile(op, name, func(flag int) (*os.File, error) {
+		f, err := os.OpenFile(full, flag, 0666)
+		if err != nil {
+			return nil, pathErr(err, name)
+		}
+		return f, nil
+	}, exclusive, block)
+}
+
+// ReadDir implements [fs.ReadDirFS] for dirFs.
+func (dir dirFs) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := dir.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(full)
+	if err != nil {
+		return nil, pathErr(err, name)
+	}
+	return entries, nil
+}
+
+// ReadFile implements [fs.ReadFileFS] for dirFs.
+func (dir dirFs) ReadFile(name string) ([]byte, error) {
+	full, err := dir.join("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(full)
+	if err != nil {
+		return nil, pathErr(err, name)
+	}
+	return b, nil
+}
+
+// Sub implements [fs.SubFS] for dirFs, the returned file system is writable.
+func (dir dirFs) Sub(name string) (fs.FS, error) {
+	full, err := dir.join("sub", name)
+	if err != nil {
+		return nil, err
+	}
+	return dirFs(full), nil
+}
@@ -0,0 +1,50 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	fsys := wfs.Dir(dir)
+	if err := fsys.MkdirAll("a/b", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "a/b/file", []byte("Hello"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	b, err := os.ReadFile(dir + "/a/b/file")
+	if err != nil || string(b) != "Hello" {
+		t.Errorf("expected file in root directory, got %q err: %v", b, err)
+	}
+
+	for _, name := range []string{"/etc/passwd", "../file", "a/../../file"} {
+		if _, err := fsys.OpenFile(name, os.O_RDONLY, 0); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("OpenFile %q: expected ErrInvalid, got %v", name, err)
+		}
+	}
+	if err := fsys.RemoveAll("."); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("RemoveAll of root: expected ErrInvalid, got %v", err)
+	}
+
+	for _, name := range []string{".", "a/b/file"} {
+		f, err := fsys.Open(name)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		if n := f.(interface{ Name() string }).Name(); n != name {
+			t.Errorf("expected file name %q, got %q", name, n)
+		}
+		f.Close()
+	}
+
+	var pe *fs.PathError
+	if _, err := fsys.Open("missing"); !errors.As(err, &pe) || pe.Path != "missing" {
+		t.Errorf("expected error path 'missing', got %v", err)
+	}
+}
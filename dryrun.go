@@ -0,0 +1,274 @@
+package wfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DryRunOp is a mutation recorded by [DryRun].
+type DryRunOp struct {
+	Op      string      // operation, named like in [FaultRule]
+	Path    string      // path of the file, or new path of rename, link and symlink
+	OldPath string      // old path of rename and link, or target of symlink
+	Flag    int         // flags of open
+	Mode    fs.FileMode // permissions of open, mkdir and MkdirAll, or mode of chmod
+	Size    int64       // bytes written by write, or size of truncate
+	Offset  int64       // offset of WriteAt, or -1 for writes at the file offset
+	UID     int         // user id of chown
+	GID     int         // group id of chown
+	Atime   time.Time   // access time of chtimes
+	Mtime   time.Time   // modification time of chtimes
+}
+
+// String returns a description of the operation, such as "rename a -> b".
+func (o DryRunOp) String() string {
+	switch o.Op {
+	case "open":
+		return fmt.Sprintf("open %s %s %v", o.Path, flagString(o.Flag), o.Mode)
+	case "write":
+		if o.Offset >= 0 {
+			return fmt.Sprintf("write %s %d bytes at %d", o.Path, o.Size, o.Offset)
+		}
+		return fmt.Sprintf("write %s %d bytes", o.Path, o.Size)
+	case "truncate":
+		return fmt.Sprintf("truncate %s %d", o.Path, o.Size)
+	case "rename", "link", "symlink":
+		return fmt.Sprintf("%s %s -> %s", o.Op, o.OldPath, o.Path)
+	case "mkdir", "MkdirAll", "chmod":
+		return fmt.Sprintf("%s %s %v", o.Op, o.Path, o.Mode)
+	case "chown":
+		return fmt.Sprintf("chown %s %d:%d", o.Path, o.UID, o.GID)
+	case "chtimes":
+		return fmt.Sprintf("chtimes %s %s", o.Path, o.Mtime.Format(time.RFC3339))
+	}
+	return o.Op + " " + o.Path
+}
+
+// OpLog is the log of the mutations recorded by [DryRun].
+// It is safe for concurrent use.
+type OpLog struct {
+	mu  sync.Mutex
+	ops []DryRunOp
+}
+
+func (l *OpLog) add(op DryRunOp) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ops = append(l.ops, op)
+}
+
+// Ops returns the recorded operations in order.
+func (l *OpLog) Ops() []DryRunOp {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]DryRunOp(nil), l.ops...)
+}
+
+// String returns the descriptions of the recorded operations, one per line.
+func (l *OpLog) String() string {
+	var b strings.Builder
+	for _, op := range l.Ops() {
+		b.WriteString(op.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// DryRun returns a file system that reads from fsys and records every
+// mutation into the returned OpLog instead of performing it.
+//
+// Mutations always succeed and are not visible to later reads, so a file
+// written through the file system reads as it is in fsys, or as an empty file
+// if it does not exist or is opened with [os.O_TRUNC].
+func DryRun(fsys fs.FS) (FS, *OpLog) {
+	log := &OpLog{}
+	return &dryRunFs{fsys, log}, log
+}
+
+type dryRunFs struct {
+	fsys fs.FS
+	log  *OpLog
+}
+
+func (f *dryRunFs) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+// Stat implements [fs.StatFS] for dryRunFs.
+func (f *dryRunFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+func (f *dryRunFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		file, err := f.fsys.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		return &dryRunFile{file: file, name: name, perm: perm, log: f.log}, nil
+	}
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f.log.add(DryRunOp{Op: "open", Path: name, Flag: flag, Mode: perm})
+	df := &dryRunFile{name: name, perm: perm, log: f.log}
+	if flag&os.O_TRUNC == 0 {
+		// reads see the current contents, if any
+		if file, err := f.fsys.Open(name); err == nil {
+			df.file = file
+		}
+	}
+	return df, nil
+}
+
+func (f *dryRunFs) Rename(oldpath, newpath string) error {
+	f.log.add(DryRunOp{Op: "rename", Path: newpath, OldPath: oldpath})
+	return nil
+}
+
+func (f *dryRunFs) Remove(name string) error {
+	f.log.add(DryRunOp{Op: "remove", Path: name})
+	return nil
+}
+
+func (f *dryRunFs) RemoveAll(path string) error {
+	f.log.add(DryRunOp{Op: "RemoveAll", Path: path})
+	return nil
+}
+
+func (f *dryRunFs) Mkdir(name string, perm fs.FileMode) error {
+	f.log.add(DryRunOp{Op: "mkdir", Path: name, Mode: perm})
+	return nil
+}
+
+func (f *dryRunFs) MkdirAll(path string, perm fs.FileMode) error {
+	f.log.add(DryRunOp{Op: "MkdirAll", Path: path, Mode: perm})
+	return nil
+}
+
+// Symlink implements [SymlinkFS] for dryRunFs.
+func (f *dryRunFs) Symlink(oldname, newname string) error {
+	f.log.add(DryRunOp{Op: "symlink", Path: newname, OldPath: oldname})
+	return nil
+}
+
+// Readlink implements [SymlinkFS] for dryRunFs.
+func (f *dryRunFs) Readlink(name string) (string, error) {
+	return Readlink(f.fsys, name)
+}
+
+// Lstat implements [SymlinkFS] for dryRunFs.
+func (f *dryRunFs) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(f.fsys, name)
+}
+
+// Link implements [LinkFS] for dryRunFs.
+func (f *dryRunFs) Link(oldname, newname string) error {
+	f.log.add(DryRunOp{Op: "link", Path: newname, OldPath: oldname})
+	return nil
+}
+
+// Chmod implements [MetaFS] for dryRunFs.
+func (f *dryRunFs) Chmod(name string, mode fs.FileMode) error {
+	f.log.add(DryRunOp{Op: "chmod", Path: name, Mode: mode})
+	return nil
+}
+
+// Chown implements [MetaFS] for dryRunFs.
+func (f *dryRunFs) Chown(name string, uid, gid int) error {
+	f.log.add(DryRunOp{Op: "chown", Path: name, UID: uid, GID: gid})
+	return nil
+}
+
+// Chtimes implements [MetaFS] for dryRunFs.
+func (f *dryRunFs) Chtimes(name string, atime, mtime time.Time) error {
+	f.log.add(DryRunOp{Op: "chtimes", Path: name, Atime: atime, Mtime: mtime})
+	return nil
+}
+
+// dryRunFile reads from the file of the underlying file system, if any,
+// and records writes.
+type dryRunFile struct {
+	file fs.File
+	name string
+	perm fs.FileMode
+	log  *OpLog
+}
+
+func (f *dryRunFile) Name() string { return f.name }
+
+func (f *dryRunFile) Stat() (fs.FileInfo, error) {
+	if f.file == nil {
+		return &memFileInfo{name: path.Base(f.name), mode: f.perm & fs.ModePerm, modTime: time.Now()}, nil
+	}
+	return f.file.Stat()
+}
+
+func (f *dryRunFile) Read(b []byte) (int, error) {
+	if f.file == nil {
+		return 0, io.EOF
+	}
+	return f.file.Read(b)
+}
+
+func (f *dryRunFile) ReadAt(b []byte, off int64) (int, error) {
+	if f.file == nil {
+		return 0, io.EOF
+	}
+	if ra, ok := f.file.(io.ReaderAt); ok {
+		return ra.ReadAt(b, off)
+	}
+	return 0, &fs.PathError{Op: "read", Path: f.name, Err: errors.ErrUnsupported}
+}
+
+func (f *dryRunFile) Seek(offset int64, whence int) (int64, error) {
+	if f.file == nil {
+		return 0, nil
+	}
+	if s, ok := f.file.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+	return 0, &fs.PathError{Op: "seek", Path: f.name, Err: errors.ErrUnsupported}
+}
+
+func (f *dryRunFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d, ok := f.file.(fs.ReadDirFile); ok {
+		return d.ReadDir(n)
+	}
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: ErrNotDir}
+}
+
+func (f *dryRunFile) Write(b []byte) (int, error) {
+	f.log.add(DryRunOp{Op: "write", Path: f.name, Size: int64(len(b)), Offset: -1})
+	return len(b), nil
+}
+
+func (f *dryRunFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *dryRunFile) WriteAt(b []byte, off int64) (int, error) {
+	f.log.add(DryRunOp{Op: "write", Path: f.name, Size: int64(len(b)), Offset: off})
+	return len(b), nil
+}
+
+func (f *dryRunFile) Truncate(size int64) error {
+	f.log.add(DryRunOp{Op: "truncate", Path: f.name, Size: size})
+	return nil
+}
+
+func (f *dryRunFile) Sync() error { return nil }
+
+func (f *dryRunFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,63 @@
+package wfs_test
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestDryRun(t *testing.T) {
+	mem := wfs.Mem()
+	if err := wfs.WriteFile(mem, "config", []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fsys, log := wfs.DryRun(mem)
+
+	// reads hit the underlying file system
+	assertContent(t, fsys, "config", "old")
+
+	if err := fsys.MkdirAll("releases/v2", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := wfs.WriteFile(fsys, "releases/v2/app", []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Rename("config", "config.bak"); err != nil {
+		t.Fatal(err)
+	}
+	f, err := fsys.OpenFile("config", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// files opened for writing read the current contents
+	if b, err := io.ReadAll(f); string(b) != "old" || err != nil {
+		t.Errorf("expected to read %q, got %q, %v", "old", b, err)
+	}
+	if _, err := f.WriteAt([]byte("new"), 0); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := fsys.Remove("config"); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is applied
+	assertEntries(t, mem, ".", "config")
+	assertContent(t, mem, "config", "old")
+
+	expected := "MkdirAll releases/v2 -rwxr-xr-x\n" +
+		"open releases/v2/app O_WRONLY|O_CREATE|O_TRUNC -rwxr-xr-x\n" +
+		"write releases/v2/app 6 bytes\n" +
+		"rename config -> config.bak\n" +
+		"open config O_RDWR ----------\n" +
+		"write config 3 bytes at 0\n" +
+		"remove config\n"
+	if log.String() != expected {
+		t.Errorf("expected log:\n%s\ngot:\n%s", expected, log.String())
+	}
+	if ops := log.Ops(); len(ops) != 7 || ops[3].OldPath != "config" || ops[3].Path != "config.bak" {
+		t.Errorf("unexpected ops %v", ops)
+	}
+}
@@ -0,0 +1,775 @@
+package wfs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ErrDecrypt is returned when the content or name of an encrypted file
+// cannot be decrypted, because it was modified, truncated or encrypted with
+// another key.
+var ErrDecrypt = errors.New("decryption failed")
+
+var (
+	errKeySize   = errors.New("encryption key must be 32 bytes")
+	errKeyID     = errors.New("encryption key ID longer than 32 bytes")
+	errBadHeader = errors.New("not an encrypted file")
+)
+
+// Layout of encrypted files: a header of encHeader bytes holding the
+// magic "wfse", the format version, the length of the key ID, the key ID
+// padded to encMaxKeyID bytes and the random file ID, followed by the
+// content in chunks of encChunkSize bytes. Every chunk is stored as a random
+// nonce, the sealed chunk and its tag, so the size of the content is always
+// known from the size of the file.
+const (
+	encMagic     = "wfse"
+	encVersion   = 1
+	encMaxKeyID  = 32
+	encIDSize    = 16
+	encHeader    = 4 + 2 + encMaxKeyID + encIDSize
+	encChunkSize = 64 << 10
+	encOverhead  = 12 + 16
+)
+
+// KeyProvider provides the 32-byte AES-256 keys of an [Encrypted] file
+// system. Keys are identified by an ID of at most 32 bytes that is stored in
+// the header of every encrypted file, so that keys can be rotated while
+// files encrypted with previous keys remain readable.
+type KeyProvider interface {
+	// CurrentKey returns the key new files are encrypted with and its ID.
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the key with the ID read from an encrypted file.
+	Key(id string) ([]byte, error)
+}
+
+type keyRing struct {
+	current string
+	keys    map[string][]byte
+}
+
+// StaticKey returns a key provider with the single key, which has the empty
+// ID.
+func StaticKey(key []byte) KeyProvider {
+	return Keys("", map[string][]byte{"": key})
+}
+
+// Keys returns a key provider encrypting new files with the key with the ID
+// current and decrypting files with any of keys.
+func Keys(current string, keys map[string][]byte) KeyProvider {
+	return keyRing{current, keys}
+}
+
+func (k keyRing) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.current)
+	return k.current, key, err
+}
+
+func (k keyRing) Key(id string) ([]byte, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", id)
+	}
+	return key, nil
+}
+
+// EncryptOption configures the file system returned by [Encrypted].
+type EncryptOption func(*encryptedFs)
+
+// EncryptNames also encrypts the names of files and directories with the key
+// with the ID id. Every path element is encrypted separately and
+// deterministically, so the structure of the tree and equal names remain
+// visible. Names are not re-encrypted when keys rotate, so the key must
+// remain available as long as the file system is used. Encrypted names are
+// about 1.6 times as long as the name plus 45 bytes, which limits names to
+// about 130 bytes on backends limiting names to 255 bytes.
+func EncryptNames(id string) EncryptOption {
+	return func(f *encryptedFs) { f.nameKeyID, f.encNames = id, true }
+}
+
+type encryptedFs struct {
+	fsys      FS
+	keys      KeyProvider
+	encNames  bool
+	nameKeyID string
+	names     cipher.AEAD
+	nameMAC   []byte
+	nameErr   error
+}
+
+// Encrypted returns a file system encrypting the content of files stored in
+// fsys with AES-256-GCM, so that files can be kept on storage that is not
+// trusted. Content is encrypted in chunks of 64 KiB with keys derived for
+// every file, so files can be read and written at any offset while only
+// whole chunks are rewritten, and modified, reordered or truncated chunks
+// fail to read with [ErrDecrypt]. Sizes are reported without the overhead of
+// encryption, which is 54 bytes per file plus 28 bytes per chunk.
+//
+// Files are encrypted with the current key of keys and decrypted with the
+// key they were encrypted with. Names are only encrypted with the
+// [EncryptNames] option. Empty files of fsys read as empty files, other
+// files not written by Encrypted fail to open.
+//
+// Open files keep track of their size, so files should not be written by
+// multiple open files at the same time.
+func Encrypted(fsys FS, keys KeyProvider, opts ...EncryptOption) FS {
+	f := &encryptedFs{fsys: fsys, keys: keys}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.encNames {
+		f.names, f.nameMAC, f.nameErr = nameCipher(keys, f.nameKeyID)
+	}
+	return f
+}
+
+// nameCipher derives the cipher and the MAC key names are encrypted with.
+func nameCipher(keys KeyProvider, id string) (cipher.AEAD, []byte, error) {
+	key, err := keys.Key(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(key) != 32 {
+		return nil, nil, errKeySize
+	}
+	derived, err := hkdf.Key(sha256.New, key, nil, "wfs encrypted names", 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(derived[:32])
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, derived[32:], nil
+}
+
+// fileCipher derives the cipher of the file with the ID id from key.
+func fileCipher(key, id []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errKeySize
+	}
+	derived, err := hkdf.Key(sha256.New, key, id, "wfs encrypted file", 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// nameEncoding encodes encrypted names with lowercase letters and digits
+// only, so they are valid names on case-insensitive backends.
+var nameEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// encPath returns the name in fsys of the file name.
+func (f *encryptedFs) encPath(name string) (string, error) {
+	if !f.encNames || name == "." || !fs.ValidPath(name) {
+		return name, nil
+	}
+	if f.nameErr != nil {
+		return "", f.nameErr
+	}
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		// the nonce is derived from the name, like a synthetic IV,
+		// so that equal names are encrypted to the same name
+		mac := hmac.New(sha256.New, f.nameMAC)
+		mac.Write([]byte(elem))
+		nonce := mac.Sum(nil)[:f.names.NonceSize()]
+		elems[i] = nameEncoding.EncodeToString(f.names.Seal(nonce, nonce, []byte(elem), nil))
+	}
+	return strings.Join(elems, "/"), nil
+}
+
+// decName returns the name of the file stored in fsys under the base name
+// name, reporting whether it could be decrypted.
+func (f *encryptedFs) decName(name string) (string, bool) {
+	if !f.encNames {
+		return name, true
+	}
+	if f.nameErr != nil {
+		return "", false
+	}
+	data, err := nameEncoding.DecodeString(name)
+	if err != nil || len(data) < f.names.NonceSize() {
+		return "", false
+	}
+	plain, err := f.names.Open(nil, data[:f.names.NonceSize()], data[f.names.NonceSize():], nil)
+	if err != nil {
+		return "", false
+	}
+	return string(plain), true
+}
+
+// baseName returns the name reported by the file info of the file name.
+func (f *encryptedFs) baseName(info fs.FileInfo, name string) string {
+	if !f.encNames || name == "." {
+		return info.Name()
+	}
+	return path.Base(name)
+}
+
+// fixErr replaces the name in fsys in the error of a file operation with
+// name.
+func fixErr(err error, name string) error {
+	if pe, ok := err.(*fs.PathError); ok {
+		return &fs.PathError{Op: pe.Op, Path: name, Err: pe.Err}
+	}
+	return err
+}
+
+func (f *encryptedFs) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for encryptedFs.
+func (f *encryptedFs) Stat(name string) (fs.FileInfo, error) {
+	ename, err := f.encPath(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	info, err := fs.Stat(f.fsys, ename)
+	if err != nil {
+		return nil, fixErr(err, name)
+	}
+	return newEncryptedInfo(info, f.baseName(info, name)), nil
+}
+
+func (f *encryptedFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	ename, err := f.encPath(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	// files are always opened for reading and writing as writes rewrite
+	// whole chunks, appending and truncating are done by the open file
+	write := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	uflag := flag &^ (os.O_WRONLY | os.O_APPEND | os.O_TRUNC)
+	if write {
+		uflag |= os.O_RDWR
+	}
+	file, err := f.fsys.OpenFile(ename, uflag, perm)
+	if err != nil {
+		return nil, fixErr(err, name)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fixErr(err, name)
+	}
+	if info.IsDir() {
+		return &encryptedDir{File: file, fs: f, name: name}, nil
+	}
+	ef := &encryptedFile{f: file, fs: f, name: name, flag: flag, cached: -1}
+	switch {
+	case write && (info.Size() == 0 || flag&os.O_TRUNC != 0):
+		err = ef.init(info.Size())
+	case info.Size() > 0:
+		err = ef.readHeader(info.Size())
+	}
+	if err != nil {
+		file.Close()
+		return nil, fixErr(err, name)
+	}
+	return ef, nil
+}
+
+func (f *encryptedFs) Rename(oldpath, newpath string) error {
+	eold, err := f.encPath(oldpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	enew, err := f.encPath(newpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	if err := f.fsys.Rename(eold, enew); err != nil {
+		if le, ok := err.(*os.LinkError); ok {
+			return &os.LinkError{Op: le.Op, Old: oldpath, New: newpath, Err: le.Err}
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *encryptedFs) Remove(name string) error {
+	ename, err := f.encPath(name)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return fixErr(f.fsys.Remove(ename), name)
+}
+
+func (f *encryptedFs) RemoveAll(path string) error {
+	ename, err := f.encPath(path)
+	if err != nil {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: err}
+	}
+	return fixErr(f.fsys.RemoveAll(ename), path)
+}
+
+func (f *encryptedFs) Mkdir(name string, perm fs.FileMode) error {
+	ename, err := f.encPath(name)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return fixErr(f.fsys.Mkdir(ename, perm), name)
+}
+
+func (f *encryptedFs) MkdirAll(path string, perm fs.FileMode) error {
+	ename, err := f.encPath(path)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: path, Err: err}
+	}
+	return fixErr(f.fsys.MkdirAll(ename, perm), path)
+}
+
+// encryptedSize returns the size of the content of an encrypted file of
+// size bytes.
+func encryptedSize(size int64) int64 {
+	body := size - int64(encHeader)
+	if body <= 0 {
+		return 0
+	}
+	chunks := (body + encChunkSize + encOverhead - 1) / (encChunkSize + encOverhead)
+	return max(body-chunks*encOverhead, 0)
+}
+
+// encryptedChunks returns the number of chunks of content of size bytes.
+// Empty files have a single empty chunk.
+func encryptedChunks(size int64) int64 {
+	return max((size+encChunkSize-1)/encChunkSize, 1)
+}
+
+// encryptedInfo reports the size of the content of encrypted files.
+type encryptedInfo struct {
+	fs.FileInfo
+	name string
+	size int64
+}
+
+func newEncryptedInfo(info fs.FileInfo, name string) encryptedInfo {
+	size := info.Size()
+	if info.Mode().IsRegular() {
+		size = encryptedSize(size)
+	}
+	return encryptedInfo{info, name, size}
+}
+
+func (i encryptedInfo) Name() string { return i.name }
+func (i encryptedInfo) Size() int64  { return i.size }
+
+type encryptedEntry struct {
+	fs.DirEntry
+	name string
+}
+
+func (e encryptedEntry) Name() string { return e.name }
+
+func (e encryptedEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return newEncryptedInfo(info, e.name), nil
+}
+
+func (e encryptedEntry) String() string { return fs.FormatDirEntry(e) }
+
+// encryptedDir decrypts the entries of a directory, omitting entries whose
+// name cannot be decrypted.
+type encryptedDir struct {
+	File
+	fs   *encryptedFs
+	name string
+}
+
+func (d *encryptedDir) Name() string { return d.name }
+
+func (d *encryptedDir) Stat() (fs.FileInfo, error) {
+	info, err := d.File.Stat()
+	if err != nil {
+		return nil, fixErr(err, d.name)
+	}
+	return newEncryptedInfo(info, d.fs.baseName(info, d.name)), nil
+}
+
+func (d *encryptedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	for {
+		batch, err := readDir(d.File, n)
+		for _, e := range batch {
+			if name, ok := d.fs.decName(e.Name()); ok {
+				entries = append(entries, encryptedEntry{e, name})
+			}
+		}
+		// keep reading until n entries are decrypted
+		if err != nil || n <= 0 || len(entries) > 0 {
+			if err == io.EOF && len(entries) > 0 {
+				err = nil
+			}
+			return entries, fixErr(err, d.name)
+		}
+	}
+}
+
+// encryptedFile encrypts the content of an open file. The last decrypted
+// chunk is cached, so that reads smaller than a chunk only decrypt it once.
+type encryptedFile struct {
+	f      File
+	fs     *encryptedFs
+	name   string
+	flag   int
+	mu     sync.Mutex
+	aead   cipher.AEAD
+	id     []byte
+	size   int64
+	offset int64
+	closed bool
+	cached int64
+	chunk  []byte
+}
+
+// init writes the header of a new file with the current key, replacing the
+// content of size bytes of the file.
+func (f *encryptedFile) init(size int64) error {
+	keyID, key, err := f.fs.keys.CurrentKey()
+	if err != nil {
+		return &fs.PathError{Op: "open", Path: f.name, Err: err}
+	}
+	if len(keyID) > encMaxKeyID {
+		return &fs.PathError{Op: "open", Path: f.name, Err: errKeyID}
+	}
+	id := make([]byte, encIDSize)
+	rand.Read(id)
+	aead, err := fileCipher(key, id)
+	if err != nil {
+		return &fs.PathError{Op: "open", Path: f.name, Err: err}
+	}
+	header := make([]byte, encHeader)
+	copy(header, encMagic)
+	header[len(encMagic)] = encVersion
+	header[len(encMagic)+1] = byte(len(keyID))
+	copy(header[len(encMagic)+2:], keyID)
+	copy(header[encHeader-encIDSize:], id)
+	if size > 0 {
+		if err := f.f.Truncate(0); err != nil {
+			return err
+		}
+	}
+	if _, err := f.f.WriteAt(header, 0); err != nil {
+		return err
+	}
+	f.aead, f.id, f.size = aead, id, 0
+	return f.writeChunk(0, nil, true)
+}
+
+// readHeader reads the header of the existing file of size bytes.
+func (f *encryptedFile) readHeader(size int64) error {
+	header := make([]byte, encHeader)
+	if _, err := f.f.ReadAt(header, 0); err != nil && err != io.EOF {
+		return err
+	}
+	n := int(header[len(encMagic)+1])
+	if string(header[:len(encMagic)]) != encMagic || header[len(encMagic)] != encVersion || n > encMaxKeyID || size < int64(encHeader+encOverhead) {
+		return &fs.PathError{Op: "open", Path: f.name, Err: errBadHeader}
+	}
+	key, err := f.fs.keys.Key(string(header[len(encMagic)+2 : len(encMagic)+2+n]))
+	if err != nil {
+		return &fs.PathError{Op: "open", Path: f.name, Err: err}
+	}
+	f.id = header[encHeader-encIDSize:]
+	f.aead, err = fileCipher(key, f.id)
+	if err != nil {
+		return &fs.PathError{Op: "open", Path: f.name, Err: err}
+	}
+	f.size = encryptedSize(size)
+	return nil
+}
+
+// additionalData binds a chunk to its file and position, and marks the last
+// chunk so that truncated files fail to decrypt.
+func (f *encryptedFile) additionalData(i int64, last bool) []byte {
+	ad := make([]byte, 0, encIDSize+9)
+	ad = append(ad, f.id...)
+	ad = binary.BigEndian.AppendUint64(ad, uint64(i))
+	if last {
+		return append(ad, 1)
+	}
+	return append(ad, 0)
+}
+
+// readChunk returns the content of chunk i.
+func (f *encryptedFile) readChunk(i int64) ([]byte, error) {
+	if i == f.cached {
+		return f.chunk, nil
+	}
+	n := encryptedChunks(f.size)
+	buf := make([]byte, min(encChunkSize, f.size-i*encChunkSize)+encOverhead)
+	if _, err := f.f.ReadAt(buf, encHeader+i*(encChunkSize+encOverhead)); err != nil {
+		if err == io.EOF {
+			err = &fs.PathError{Op: "read", Path: f.name, Err: ErrDecrypt}
+		}
+		return nil, err
+	}
+	nonce := buf[:f.aead.NonceSize()]
+	chunk, err := f.aead.Open(buf[len(nonce):len(nonce)], nonce, buf[len(nonce):], f.additionalData(i, i == n-1))
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: f.name, Err: ErrDecrypt}
+	}
+	f.cached, f.chunk = i, chunk
+	return chunk, nil
+}
+
+// writeChunk encrypts chunk i with a new random nonce.
+func (f *encryptedFile) writeChunk(i int64, chunk []byte, last bool) error {
+	f.cached = -1
+	buf := make([]byte, f.aead.NonceSize(), f.aead.NonceSize()+len(chunk)+f.aead.Overhead())
+	rand.Read(buf)
+	buf = f.aead.Seal(buf, buf, chunk, f.additionalData(i, last))
+	_, err := f.f.WriteAt(buf, encHeader+i*(encChunkSize+encOverhead))
+	return err
+}
+
+// check returns an error if the file is closed or is missing the access mode of flag.
+func (f *encryptedFile) check(op string, flag int) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	switch flag {
+	case os.O_RDONLY:
+		if f.flag&os.O_WRONLY != 0 {
+			return &fs.PathError{Op: op, Path: f.name, Err: errBadFile}
+		}
+	case os.O_WRONLY:
+		if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+			return &fs.PathError{Op: op, Path: f.name, Err: errBadFile}
+		}
+	}
+	return nil
+}
+
+func (f *encryptedFile) Name() string {
+	return f.name
+}
+
+func (f *encryptedFile) Stat() (fs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("stat", -1); err != nil {
+		return nil, err
+	}
+	info, err := f.f.Stat()
+	if err != nil {
+		return nil, fixErr(err, f.name)
+	}
+	return encryptedInfo{info, f.fs.baseName(info, f.name), f.size}, nil
+}
+
+func (f *encryptedFile) Read(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read", os.O_RDONLY); err != nil {
+		return 0, err
+	}
+	n, err := f.readAt(b, f.offset)
+	f.offset += int64(n)
+	if err == nil && n == 0 && len(b) > 0 {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *encryptedFile) ReadAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read", os.O_RDONLY); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.name, Err: errors.New("negative offset")}
+	}
+	n, err := f.readAt(b, off)
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *encryptedFile) readAt(b []byte, off int64) (int, error) {
+	n := 0
+	for n < len(b) && off+int64(n) < f.size {
+		pos := off + int64(n)
+		chunk, err := f.readChunk(pos / encChunkSize)
+		if err != nil {
+			return n, fixErr(err, f.name)
+		}
+		n += copy(b[n:], chunk[pos%encChunkSize:])
+	}
+	return n, nil
+}
+
+func (f *encryptedFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("seek", -1); err != nil {
+		return 0, err
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	case io.SeekStart:
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *encryptedFile) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("write", os.O_WRONLY); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = f.size
+	}
+	n, err := f.writeAt(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *encryptedFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *encryptedFile) WriteAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("write", os.O_WRONLY); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		return 0, errors.New("invalid use of WriteAt on file opened with O_APPEND")
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "writeat", Path: f.name, Err: errors.New("negative offset")}
+	}
+	return f.writeAt(b, off)
+}
+
+// writeAt rewrites the chunks overlapping b, the chunks filled with zeros
+// when writing past the end and the previous last chunk when the file grows
+// by chunks.
+func (f *encryptedFile) writeAt(b []byte, off int64) (int, error) {
+	end := off + int64(len(b))
+	size := max(f.size, end)
+	if size == f.size && len(b) == 0 {
+		return 0, nil
+	}
+	oldChunks, chunks := encryptedChunks(f.size), encryptedChunks(size)
+	first := min(off, f.size) / encChunkSize
+	if chunks > oldChunks {
+		first = min(first, oldChunks-1)
+	}
+	last := (end - 1) / encChunkSize
+	if size > f.size {
+		last = chunks - 1
+	}
+	for i := first; i <= last; i++ {
+		start := i * encChunkSize
+		chunk := make([]byte, min(encChunkSize, size-start))
+		if i < oldChunks {
+			old, err := f.readChunk(i)
+			if err != nil {
+				return 0, fixErr(err, f.name)
+			}
+			copy(chunk, old)
+		}
+		if off < start+int64(len(chunk)) && end > start {
+			copy(chunk[max(off-start, 0):], b[max(start-off, 0):])
+		}
+		if err := f.writeChunk(i, chunk, i == chunks-1); err != nil {
+			return 0, fixErr(err, f.name)
+		}
+	}
+	f.size = size
+	return len(b), nil
+}
+
+func (f *encryptedFile) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 || size < 0 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if size >= f.size {
+		_, err := f.writeAt(nil, size)
+		return err
+	}
+	chunks := encryptedChunks(size)
+	chunk, err := f.readChunk(chunks - 1)
+	if err != nil {
+		return fixErr(err, f.name)
+	}
+	if err := f.writeChunk(chunks-1, chunk[:size-(chunks-1)*encChunkSize], true); err != nil {
+		return fixErr(err, f.name)
+	}
+	if err := f.f.Truncate(encHeader + chunks*encOverhead + size); err != nil {
+		return fixErr(err, f.name)
+	}
+	f.size = size
+	return nil
+}
+
+func (f *encryptedFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: ErrNotDir}
+}
+
+func (f *encryptedFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("sync", -1); err != nil {
+		return err
+	}
+	return fixErr(f.f.Sync(), f.name)
+}
+
+func (f *encryptedFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return fixErr(f.f.Close(), f.name)
+}
@@ -0,0 +1,209 @@
+package wfs_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestEncrypted(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	for _, tt := range []struct {
+		name string
+		opts []wfs.EncryptOption
+	}{
+		{"content", nil},
+		{"names", []wfs.EncryptOption{wfs.EncryptNames("")}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := wfs.Mem()
+			fsys := wfs.Encrypted(mem, wfs.StaticKey(key), tt.opts...)
+			if err := fsys.MkdirAll("docs/private", 0755); err != nil {
+				t.Fatalf("MkdirAll failed: %v", err)
+			}
+			big := strings.Repeat("0123456789", 20000)
+			for name, content := range map[string]string{
+				"docs/report.txt":      "confidential report",
+				"docs/private/big.txt": big,
+				"empty.txt":            "",
+			} {
+				if err := wfs.WriteFile(fsys, name, []byte(content), 0644); err != nil {
+					t.Fatalf("WriteFile failed: %v", err)
+				}
+			}
+			assertContent(t, fsys, "docs/report.txt", "confidential report")
+			assertContent(t, fsys, "docs/private/big.txt", big)
+			assertEntries(t, fsys, "docs", "private", "report.txt")
+			if info, err := fs.Stat(fsys, "docs/private/big.txt"); err != nil || info.Size() != int64(len(big)) || info.Name() != "big.txt" {
+				t.Errorf("unexpected info %v err: %v", info, err)
+			}
+			if err := fstest.TestFS(fsys, "docs/report.txt", "docs/private/big.txt", "empty.txt"); err != nil {
+				t.Fatal(err)
+			}
+
+			// the underlying file system only holds ciphertext
+			fs.WalkDir(mem, ".", func(name string, d fs.DirEntry, err error) error {
+				if tt.opts != nil && strings.ContainsAny(name, "._") && name != "." {
+					t.Errorf("unexpected name %s in the underlying file system", name)
+				}
+				if d.Type().IsRegular() {
+					data, _ := fs.ReadFile(mem, name)
+					if bytes.Contains(data, []byte("confidential")) || bytes.Contains(data, []byte("0123456789")) {
+						t.Errorf("found plaintext in %s", name)
+					}
+				}
+				return nil
+			})
+
+			if err := fsys.Rename("docs/report.txt", "docs/private/report.txt"); err != nil {
+				t.Fatalf("Rename failed: %v", err)
+			}
+			assertContent(t, fsys, "docs/private/report.txt", "confidential report")
+			if err := fsys.Remove("docs/private"); !errors.Is(err, wfs.ErrNotEmpty) {
+				t.Errorf("expected ErrNotEmpty, got %v", err)
+			}
+			_, err := fs.Stat(fsys, "docs/report.txt")
+			var pe *fs.PathError
+			if !errors.As(err, &pe) || pe.Path != "docs/report.txt" || !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected ErrNotExist with the plaintext name, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEncryptedRandomAccess(t *testing.T) {
+	fsys := wfs.Encrypted(wfs.Mem(), wfs.StaticKey(bytes.Repeat([]byte{2}, 32)))
+	f, err := fsys.OpenFile("data", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer f.Close()
+
+	// mirror every write in a plain slice across chunk boundaries
+	var expected []byte
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		off := rng.Int63n(300 << 10)
+		data := make([]byte, rng.Intn(100<<10))
+		rng.Read(data)
+		if i%10 == 9 {
+			if err := f.Truncate(off); err != nil {
+				t.Fatalf("Truncate failed: %v", err)
+			}
+			expected = append(expected, make([]byte, max(0, int(off)-len(expected)))...)[:off]
+			continue
+		}
+		if _, err := f.WriteAt(data, off); err != nil {
+			t.Fatalf("WriteAt failed: %v", err)
+		}
+		if end := int(off) + len(data); end > len(expected) {
+			expected = append(expected, make([]byte, end-len(expected))...)
+		}
+		copy(expected[off:], data)
+	}
+	info, err := f.Stat()
+	if err != nil || info.Size() != int64(len(expected)) {
+		t.Fatalf("expected size %d, got %v err: %v", len(expected), info, err)
+	}
+	got := make([]byte, len(expected))
+	if _, err := f.ReadAt(got, 0); err != nil {
+		t.Fatalf("ReadAt failed: %v", err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Error("content does not match the written data")
+	}
+	if info, err := fs.Stat(fsys, "data"); err != nil || info.Size() != int64(len(expected)) {
+		t.Errorf("expected size %d, got %v err: %v", len(expected), info, err)
+	}
+}
+
+func TestEncryptedAppend(t *testing.T) {
+	fsys := wfs.Encrypted(wfs.Mem(), wfs.StaticKey(bytes.Repeat([]byte{3}, 32)))
+	for _, s := range []string{"one ", "two ", "three"} {
+		f, err := fsys.OpenFile("log", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatalf("OpenFile failed: %v", err)
+		}
+		if _, err := io.WriteString(f, s); err != nil {
+			t.Fatalf("WriteString failed: %v", err)
+		}
+		if _, err := f.Read(make([]byte, 1)); err == nil {
+			t.Error("expected Read of a write-only file to fail")
+		}
+		f.Close()
+	}
+	assertContent(t, fsys, "log", "one two three")
+}
+
+func TestEncryptedTampering(t *testing.T) {
+	mem := wfs.Mem()
+	fsys := wfs.Encrypted(mem, wfs.StaticKey(bytes.Repeat([]byte{4}, 32)))
+	content := strings.Repeat("x", 100<<10)
+	if err := wfs.WriteFile(fsys, "doc", []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	data, _ := fs.ReadFile(mem, "doc")
+
+	flipped := bytes.Clone(data)
+	flipped[len(flipped)-1] ^= 1
+	wfs.WriteFile(mem, "doc", flipped, 0644)
+	if _, err := fs.ReadFile(fsys, "doc"); !errors.Is(err, wfs.ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt for a modified file, got %v", err)
+	}
+
+	// dropping the last chunk leaves a chunk that is not marked as the last
+	wfs.WriteFile(mem, "doc", data[:54+64<<10+28], 0644)
+	if _, err := fs.ReadFile(fsys, "doc"); !errors.Is(err, wfs.ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt for a truncated file, got %v", err)
+	}
+
+	wfs.WriteFile(mem, "doc", data, 0644)
+	other := wfs.Encrypted(mem, wfs.StaticKey(bytes.Repeat([]byte{5}, 32)))
+	if _, err := fs.ReadFile(other, "doc"); !errors.Is(err, wfs.ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt with another key, got %v", err)
+	}
+	wfs.WriteFile(mem, "plain", []byte("not encrypted"), 0644)
+	if _, err := fsys.Open("plain"); err == nil {
+		t.Error("expected Open of a file that is not encrypted to fail")
+	}
+}
+
+func TestEncryptedKeyRotation(t *testing.T) {
+	mem := wfs.Mem()
+	oldKey, newKey := bytes.Repeat([]byte{6}, 32), bytes.Repeat([]byte{7}, 32)
+	fsys := wfs.Encrypted(mem, wfs.Keys("2025", map[string][]byte{"2025": oldKey}))
+	if err := wfs.WriteFile(fsys, "old", []byte("old content"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	rotated := wfs.Encrypted(mem, wfs.Keys("2026", map[string][]byte{"2025": oldKey, "2026": newKey}))
+	if err := wfs.WriteFile(rotated, "new", []byte("new content"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	assertContent(t, rotated, "old", "old content")
+	assertContent(t, rotated, "new", "new content")
+
+	retired := wfs.Encrypted(mem, wfs.Keys("2026", map[string][]byte{"2026": newKey}))
+	assertContent(t, retired, "new", "new content")
+	if _, err := fs.ReadFile(retired, "old"); err == nil {
+		t.Error("expected ReadFile of a file encrypted with a retired key to fail")
+	}
+
+	// rewriting a file encrypts it with the current key
+	f, err := rotated.OpenFile("old", os.O_RDWR|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	io.WriteString(f, "rewritten")
+	f.Close()
+	assertContent(t, retired, "old", "rewritten")
+}
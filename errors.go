@@ -0,0 +1,55 @@
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// Errors returned by the file systems of this package in addition to the
+// errors of [io/fs], so that they can be tested with [errors.Is] on every
+// backend. The in-memory file systems return them directly and the errors of
+// the OS file systems and of the files they open are annotated with them,
+// while still matching the underlying platform error. Platform errors for
+// invalid arguments are annotated with [fs.ErrInvalid].
+var (
+	ErrNotEmpty = errors.New("directory not empty")
+	ErrIsDir    = errors.New("is a directory")
+	ErrNotDir   = errors.New("not a directory")
+	ErrLocked   = errors.New("file is locked")
+)
+
+var (
+	errBadFile = errors.New("bad file descriptor")
+	errLoop    = errors.New("too many levels of symbolic links")
+	errXDev    = errors.New("invalid cross-device link")
+)
+
+// portableError annotates a platform error with the portable error
+// it corresponds to.
+type portableError struct {
+	err      error
+	portable error
+}
+
+func (e *portableError) Error() string   { return e.err.Error() }
+func (e *portableError) Unwrap() []error { return []error{e.err, e.portable} }
+
+// normErr annotates the platform error wrapped by err if it is
+// a [*fs.PathError] or an [*os.LinkError].
+func normErr(err error) error {
+	switch e := err.(type) {
+	case *fs.PathError:
+		e.Err = portable(e.Err)
+	case *os.LinkError:
+		e.Err = portable(e.Err)
+	}
+	return err
+}
+
+func portable(err error) error {
+	if p := portableErrno(err); p != nil {
+		return &portableError{err, p}
+	}
+	return err
+}
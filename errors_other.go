@@ -0,0 +1,8 @@
+//go:build !unix && !wasip1 && !js && !windows
+
+package wfs
+
+// portableErrno returns the portable error corresponding to err, if any.
+func portableErrno(err error) error {
+	return nil
+}
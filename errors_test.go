@@ -0,0 +1,70 @@
+package wfs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestErrors(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dir/file": &fstest.MapFile{Data: []byte("Hello")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			dir := filepath.Join(base, "dir")
+			file := filepath.Join(base, "dir/file")
+			_, errIsDir := fsys.OpenFile(dir, os.O_WRONLY, 0)
+			_, errNotDir := fsys.OpenFile(filepath.Join(file, "child"), os.O_WRONLY|os.O_CREATE, 0644)
+			_, errNotExist := fsys.OpenFile(filepath.Join(base, "missing"), os.O_RDONLY, 0)
+
+			// errors of file methods match too
+			d, err := fsys.OpenFile(dir, os.O_RDONLY, 0)
+			if err != nil {
+				t.Fatalf("failed to open directory: %v", err)
+			}
+			defer d.Close()
+			_, errReadDir := d.Read(make([]byte, 1))
+			f, err := fsys.OpenFile(file, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer f.Close()
+			_, errReadFile := f.(fs.ReadDirFile).ReadDir(-1)
+			_, errSeek := f.Seek(-1, io.SeekStart)
+			errTruncate := f.Truncate(-1)
+
+			tests := []struct {
+				op     string
+				err    error
+				target error
+			}{
+				{"Remove non-empty directory", fsys.Remove(dir), wfs.ErrNotEmpty},
+				{"Mkdir existing directory", fsys.Mkdir(dir, 0755), fs.ErrExist},
+				{"OpenFile directory for writing", errIsDir, wfs.ErrIsDir},
+				{"OpenFile under a file", errNotDir, wfs.ErrNotDir},
+				{"OpenFile missing file", errNotExist, fs.ErrNotExist},
+				{"Read directory", errReadDir, wfs.ErrIsDir},
+				{"ReadDir file", errReadFile, wfs.ErrNotDir},
+				{"Seek to a negative offset", errSeek, fs.ErrInvalid},
+				{"Truncate to a negative size", errTruncate, fs.ErrInvalid},
+			}
+			for _, tc := range tests {
+				if !errors.Is(tc.err, tc.target) {
+					t.Errorf("%s: expected %v, got %v", tc.op, tc.target, tc.err)
+				}
+			}
+		})
+	}
+}
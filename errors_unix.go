@@ -0,0 +1,25 @@
+//go:build unix || wasip1 || js
+
+package wfs
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// portableErrno returns the portable error corresponding to err, if any.
+func portableErrno(err error) error {
+	switch err {
+	case syscall.ENOTEMPTY:
+		return ErrNotEmpty
+	case syscall.EISDIR:
+		return ErrIsDir
+	case syscall.ENOTDIR:
+		return ErrNotDir
+	case syscall.EXDEV:
+		return errXDev
+	case syscall.EINVAL:
+		return fs.ErrInvalid
+	}
+	return nil
+}
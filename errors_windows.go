@@ -0,0 +1,26 @@
+package wfs
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// errorNotSameDevice is ERROR_NOT_SAME_DEVICE, which is not defined by [syscall].
+const errorNotSameDevice syscall.Errno = 17
+
+// portableErrno returns the portable error corresponding to err, if any.
+func portableErrno(err error) error {
+	switch err {
+	case syscall.ERROR_DIR_NOT_EMPTY, syscall.ENOTEMPTY:
+		return ErrNotEmpty
+	case syscall.EISDIR:
+		return ErrIsDir
+	case syscall.ENOTDIR:
+		return ErrNotDir
+	case errorNotSameDevice, syscall.EXDEV:
+		return errXDev
+	case syscall.EINVAL:
+		return fs.ErrInvalid
+	}
+	return nil
+}
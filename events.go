@@ -0,0 +1,303 @@
+package wfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Op describes a mutation of a file system.
+type Op uint32
+
+const (
+	OpCreate Op = 1 << iota // a file or directory was created
+	OpWrite                 // file contents were written or truncated
+	OpRename                // a file or directory was renamed
+	OpRemove                // a file or directory was removed
+	OpChmod                 // the mode, owner or times of a file were changed
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpCreate:
+		return "create"
+	case OpWrite:
+		return "write"
+	case OpRename:
+		return "rename"
+	case OpRemove:
+		return "remove"
+	case OpChmod:
+		return "chmod"
+	}
+	return "unknown"
+}
+
+// Event is a structured record of a mutation applied to a file system.
+type Event struct {
+	Op Op
+
+	// Path is the name of the affected file, for renames it is the new name.
+	Path string
+
+	// OldPath is the previous name of a renamed file.
+	OldPath string
+
+	// Size is the change in file size caused by the mutation.
+	// It is negative when a write truncates or a remove deletes a file.
+	Size int64
+
+	// Actor identifies who performed the mutation, see [EventFS.As].
+	Actor string
+
+	// Time is when the mutation completed.
+	Time time.Time
+}
+
+// EventFS is a file system that reports its mutations as events.
+type EventFS interface {
+	FS
+
+	// Events returns the channel on which events are delivered.
+	// Mutations block until their event is received once the
+	// channel buffer is full.
+	Events() <-chan Event
+
+	// As returns a view of the file system whose events are
+	// attributed to actor. Events of all views are delivered on the
+	// same channel.
+	As(actor string) FS
+}
+
+// Instrument returns an EventFS that emits an [Event] for every successful
+// create, write, rename, remove and chmod applied to fsys.
+// Events are buffered up to buffer events.
+//
+// Events are produced from calls made through the returned file system,
+// changes made directly to fsys are not observed.
+func Instrument(fsys FS, buffer int) EventFS {
+	events := make(chan Event, buffer)
+	return &instrumentFs{&eventFs{fsys: fsys, sink: func(e Event) { events <- e }}, events}
+}
+
+type instrumentFs struct {
+	*eventFs
+	events chan Event
+}
+
+func (f *instrumentFs) Events() <-chan Event {
+	return f.events
+}
+
+func (f *instrumentFs) As(actor string) FS {
+	return &eventFs{fsys: f.fsys, sink: f.sink, actor: actor}
+}
+
+// eventFs passes an event for every mutation applied to fsys to sink.
+type eventFs struct {
+	fsys  FS
+	sink  func(Event)
+	actor string
+}
+
+func (f *eventFs) emit(e Event) {
+	e.Actor = f.actor
+	e.Time = time.Now()
+	f.sink(e)
+}
+
+// size returns the size of the named file or -1 if it does not exist.
+func (f *eventFs) size(name string) int64 {
+	info, err := fs.Stat(f.fsys, name)
+	if err != nil {
+		return -1
+	}
+	if info.IsDir() {
+		return 0
+	}
+	return info.Size()
+}
+
+func (f *eventFs) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+func (f *eventFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+func (f *eventFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	size := int64(-1)
+	if flag&(os.O_CREATE|os.O_TRUNC) != 0 {
+		size = f.size(name)
+	}
+	file, err := f.fsys.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if flag&os.O_CREATE != 0 && size < 0 {
+		f.emit(Event{Op: OpCreate, Path: name})
+		size = 0
+	} else if flag&os.O_TRUNC != 0 && size > 0 {
+		f.emit(Event{Op: OpWrite, Path: name, Size: -size})
+		size = 0
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return file, nil
+	}
+	if size < 0 {
+		size = f.size(name)
+	}
+	return &eventFile{File: file, fsys: f, size: size}, nil
+}
+
+func (f *eventFs) Rename(oldpath, newpath string) error {
+	if err := f.fsys.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	f.emit(Event{Op: OpRename, Path: newpath, OldPath: oldpath})
+	return nil
+}
+
+func (f *eventFs) Remove(name string) error {
+	size := f.size(name)
+	if err := f.fsys.Remove(name); err != nil {
+		return err
+	}
+	f.emit(Event{Op: OpRemove, Path: name, Size: -size})
+	return nil
+}
+
+func (f *eventFs) RemoveAll(path string) error {
+	size := f.size(path)
+	if err := f.fsys.RemoveAll(path); err != nil {
+		return err
+	}
+	// RemoveAll succeeds for missing paths, only report actual removals
+	if size >= 0 {
+		f.emit(Event{Op: OpRemove, Path: path, Size: -size})
+	}
+	return nil
+}
+
+func (f *eventFs) Mkdir(name string, perm fs.FileMode) error {
+	if err := f.fsys.Mkdir(name, perm); err != nil {
+		return err
+	}
+	f.emit(Event{Op: OpCreate, Path: name})
+	return nil
+}
+
+func (f *eventFs) MkdirAll(path string, perm fs.FileMode) error {
+	exists := f.size(path) >= 0
+	if err := f.fsys.MkdirAll(path, perm); err != nil {
+		return err
+	}
+	if !exists {
+		f.emit(Event{Op: OpCreate, Path: path})
+	}
+	return nil
+}
+
+// Symlink implements [SymlinkFS] for eventFs.
+func (f *eventFs) Symlink(oldname, newname string) error {
+	if err := Symlink(f.fsys, oldname, newname); err != nil {
+		return err
+	}
+	f.emit(Event{Op: OpCreate, Path: newname})
+	return nil
+}
+
+// Readlink implements [SymlinkFS] for eventFs.
+func (f *eventFs) Readlink(name string) (string, error) {
+	return Readlink(f.fsys, name)
+}
+
+// Lstat implements [SymlinkFS] for eventFs.
+func (f *eventFs) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(f.fsys, name)
+}
+
+// Link implements [LinkFS] for eventFs.
+func (f *eventFs) Link(oldname, newname string) error {
+	if err := Link(f.fsys, oldname, newname); err != nil {
+		return err
+	}
+	f.emit(Event{Op: OpCreate, Path: newname, Size: f.size(newname)})
+	return nil
+}
+
+// Chmod implements [MetaFS] for eventFs.
+func (f *eventFs) Chmod(name string, mode fs.FileMode) error {
+	if err := Chmod(f.fsys, name, mode); err != nil {
+		return err
+	}
+	f.emit(Event{Op: OpChmod, Path: name})
+	return nil
+}
+
+// Chown implements [MetaFS] for eventFs.
+func (f *eventFs) Chown(name string, uid, gid int) error {
+	if err := Chown(f.fsys, name, uid, gid); err != nil {
+		return err
+	}
+	f.emit(Event{Op: OpChmod, Path: name})
+	return nil
+}
+
+// Chtimes implements [MetaFS] for eventFs.
+func (f *eventFs) Chtimes(name string, atime, mtime time.Time) error {
+	if err := Chtimes(f.fsys, name, atime, mtime); err != nil {
+		return err
+	}
+	f.emit(Event{Op: OpChmod, Path: name})
+	return nil
+}
+
+// eventFile tracks the size of a writable file to report size deltas.
+type eventFile struct {
+	File
+	fsys *eventFs
+	size int64
+}
+
+// grow records a write of n bytes ending at end.
+func (f *eventFile) grow(end int64, n int) {
+	if n == 0 {
+		return
+	}
+	var delta int64
+	if end > f.size {
+		delta = end - f.size
+		f.size = end
+	}
+	f.fsys.emit(Event{Op: OpWrite, Path: f.Name(), Size: delta})
+}
+
+func (f *eventFile) Write(b []byte) (n int, err error) {
+	n, err = f.File.Write(b)
+	pos, _ := f.File.Seek(0, io.SeekCurrent)
+	f.grow(pos, n)
+	return
+}
+
+func (f *eventFile) WriteString(s string) (n int, err error) {
+	return f.Write([]byte(s))
+}
+
+func (f *eventFile) WriteAt(b []byte, off int64) (n int, err error) {
+	n, err = f.File.WriteAt(b, off)
+	f.grow(off+int64(n), n)
+	return
+}
+
+func (f *eventFile) Truncate(size int64) error {
+	if err := f.File.Truncate(size); err != nil {
+		return err
+	}
+	delta := size - f.size
+	f.size = size
+	f.fsys.emit(Event{Op: OpWrite, Path: f.Name(), Size: delta})
+	return nil
+}
@@ -0,0 +1,57 @@
+package wfs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestInstrument(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"oldfile": &fstest.MapFile{Data: []byte("Hello")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			efs := wfs.Instrument(fsys, 10)
+			filePath := filepath.Join(base, "testfile")
+			if err := wfs.WriteFile(efs.As("tester"), filePath, []byte("Hello"), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			oldPath := filepath.Join(base, "oldfile")
+			if err := efs.Rename(filePath, oldPath); err != nil {
+				t.Fatalf("Rename failed: %v", err)
+			}
+			if err := efs.Remove(oldPath); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+			if _, err := efs.OpenFile(oldPath, os.O_RDONLY, 0); err == nil {
+				t.Fatalf("OpenFile should fail for removed file")
+			}
+
+			expected := []wfs.Event{
+				{Op: wfs.OpCreate, Path: filePath, Actor: "tester"},
+				{Op: wfs.OpWrite, Path: filePath, Size: 5, Actor: "tester"},
+				{Op: wfs.OpRename, Path: oldPath, OldPath: filePath},
+				{Op: wfs.OpRemove, Path: oldPath, Size: -5},
+			}
+			if len(efs.Events()) != len(expected) {
+				t.Fatalf("expected %d events, got %d", len(expected), len(efs.Events()))
+			}
+			for _, want := range expected {
+				got := <-efs.Events()
+				got.Time = want.Time
+				if got != want {
+					t.Errorf("expected event %+v, got %+v", want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package wfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+var errNoBackend = errors.New("no healthy backend")
+
+// HealthProbe checks a backend of a [FailoverFS], returning an error if it is
+// not healthy.
+type HealthProbe func(fsys FS) error
+
+// StatProbe is a HealthProbe that stats the root directory of the backend.
+func StatProbe(fsys FS) error {
+	_, err := fs.Stat(fsys, ".")
+	return err
+}
+
+// FailoverFS is a file system that routes operations to the first healthy
+// of several backends.
+type FailoverFS interface {
+	FS
+
+	// Active returns the index of the backend operations are routed to,
+	// or -1 if no backend is healthy.
+	Active() int
+
+	// Probe checks every unhealthy backend with probe and marks the backends
+	// that pass as healthy again, so that operations fail back to them.
+	// It is typically called periodically.
+	Probe(probe HealthProbe)
+}
+
+// Failover returns a FailoverFS that routes every operation to the first
+// healthy backend. The backends are expected to serve the same files, such as
+// mounts of the same network share.
+//
+// A backend becomes unhealthy when an operation on it fails with a transport
+// error and the operation is retried on the next healthy backend. Every error
+// that does not match one of the errors of [io/fs], [ErrNotEmpty], [ErrIsDir],
+// [ErrNotDir], [errors.ErrUnsupported] or [io.EOF] is a transport error.
+// Unhealthy backends are only used again after they pass a [FailoverFS.Probe].
+// Operations fail with the last transport error when every backend failed,
+// and with an error reporting that no backend is healthy if none was tried.
+//
+// Files remain on the backend they were opened on.
+func Failover(backends ...FS) FailoverFS {
+	return &failoverFs{backends: backends, unhealthy: make([]bool, len(backends))}
+}
+
+type failoverFs struct {
+	backends []FS
+
+	mu        sync.Mutex
+	unhealthy []bool
+}
+
+// isTransportError reports whether err is caused by the backend
+// rather than by the operation.
+func isTransportError(err error) bool {
+	for _, target := range []error{
+		fs.ErrInvalid, fs.ErrPermission, fs.ErrExist, fs.ErrNotExist, fs.ErrClosed,
+		ErrNotEmpty, ErrIsDir, ErrNotDir, errors.ErrUnsupported, io.EOF,
+	} {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *failoverFs) Active() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, unhealthy := range f.unhealthy {
+		if !unhealthy {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *failoverFs) Probe(probe HealthProbe) {
+	for i, backend := range f.backends {
+		f.mu.Lock()
+		unhealthy := f.unhealthy[i]
+		f.mu.Unlock()
+		if unhealthy && probe(backend) == nil {
+			f.mu.Lock()
+			f.unhealthy[i] = false
+			f.mu.Unlock()
+		}
+	}
+}
+
+func (f *failoverFs) healthy(i int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.unhealthy[i]
+}
+
+func (f *failoverFs) fail(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unhealthy[i] = true
+}
+
+// failover calls fn with each healthy backend until it does not return
+// a transport error. If no backend is healthy it returns noBackend.
+func failover[T any](f *failoverFs, noBackend error, fn func(fsys FS) (T, error)) (T, error) {
+	var v T
+	err := noBackend
+	for i, backend := range f.backends {
+		if !f.healthy(i) {
+			continue
+		}
+		v, err = fn(backend)
+		if err == nil || !isTransportError(err) {
+			return v, err
+		}
+		f.fail(i)
+	}
+	return v, err
+}
+
+// failoverErr is like failover for functions that only return an error.
+func failoverErr(f *failoverFs, noBackend error, fn func(fsys FS) error) error {
+	_, err := failover(f, noBackend, func(fsys FS) (struct{}, error) { return struct{}{}, fn(fsys) })
+	return err
+}
+
+func noBackendPathErr(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: errNoBackend}
+}
+
+func noBackendLinkErr(op, oldname, newname string) error {
+	return &os.LinkError{Op: op, Old: oldname, New: newname, Err: errNoBackend}
+}
+
+func (f *failoverFs) Open(name string) (fs.File, error) {
+	return failover(f, noBackendPathErr("open", name), func(fsys FS) (fs.File, error) {
+		return fsys.Open(name)
+	})
+}
+
+// Stat implements [fs.StatFS] for failoverFs.
+func (f *failoverFs) Stat(name string) (fs.FileInfo, error) {
+	return failover(f, noBackendPathErr("stat", name), func(fsys FS) (fs.FileInfo, error) {
+		return fs.Stat(fsys, name)
+	})
+}
+
+func (f *failoverFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return failover(f, noBackendPathErr("open", name), func(fsys FS) (File, error) {
+		return fsys.OpenFile(name, flag, perm)
+	})
+}
+
+func (f *failoverFs) Rename(oldpath, newpath string) error {
+	return failoverErr(f, noBackendLinkErr("rename", oldpath, newpath), func(fsys FS) error {
+		return fsys.Rename(oldpath, newpath)
+	})
+}
+
+func (f *failoverFs) Remove(name string) error {
+	return failoverErr(f, noBackendPathErr("remove", name), func(fsys FS) error {
+		return fsys.Remove(name)
+	})
+}
+
+func (f *failoverFs) RemoveAll(path string) error {
+	return failoverErr(f, noBackendPathErr("RemoveAll", path), func(fsys FS) error {
+		return fsys.RemoveAll(path)
+	})
+}
+
+func (f *failoverFs) Mkdir(name string, perm fs.FileMode) error {
+	return failoverErr(f, noBackendPathErr("mkdir", name), func(fsys FS) error {
+		return fsys.Mkdir(name, perm)
+	})
+}
+
+func (f *failoverFs) MkdirAll(path string, perm fs.FileMode) error {
+	return failoverErr(f, noBackendPathErr("mkdir", path), func(fsys FS) error {
+		return fsys.MkdirAll(path, perm)
+	})
+}
+
+// Symlink implements [SymlinkFS] for failoverFs.
+func (f *failoverFs) Symlink(oldname, newname string) error {
+	return failoverErr(f, noBackendLinkErr("symlink", oldname, newname), func(fsys FS) error {
+		return Symlink(fsys, oldname, newname)
+	})
+}
+
+// Readlink implements [SymlinkFS] for failoverFs.
+func (f *failoverFs) Readlink(name string) (string, error) {
+	return failover(f, noBackendPathErr("readlink", name), func(fsys FS) (string, error) {
+		return Readlink(fsys, name)
+	})
+}
+
+// Lstat implements [SymlinkFS] for failoverFs.
+func (f *failoverFs) Lstat(name string) (fs.FileInfo, error) {
+	return failover(f, noBackendPathErr("lstat", name), func(fsys FS) (fs.FileInfo, error) {
+		return Lstat(fsys, name)
+	})
+}
+
+// Link implements [LinkFS] for failoverFs.
+func (f *failoverFs) Link(oldname, newname string) error {
+	return failoverErr(f, noBackendLinkErr("link", oldname, newname), func(fsys FS) error {
+		return Link(fsys, oldname, newname)
+	})
+}
+
+// Chmod implements [MetaFS] for failoverFs.
+func (f *failoverFs) Chmod(name string, mode fs.FileMode) error {
+	return failoverErr(f, noBackendPathErr("chmod", name), func(fsys FS) error {
+		return Chmod(fsys, name, mode)
+	})
+}
+
+// Chown implements [MetaFS] for failoverFs.
+func (f *failoverFs) Chown(name string, uid, gid int) error {
+	return failoverErr(f, noBackendPathErr("chown", name), func(fsys FS) error {
+		return Chown(fsys, name, uid, gid)
+	})
+}
+
+// Chtimes implements [MetaFS] for failoverFs.
+func (f *failoverFs) Chtimes(name string, atime, mtime time.Time) error {
+	return failoverErr(f, noBackendPathErr("chtimes", name), func(fsys FS) error {
+		return Chtimes(fsys, name, atime, mtime)
+	})
+}
@@ -0,0 +1,88 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+var errTransport = errors.New("connection reset")
+
+// flakyFS fails every operation with a transport error while down is set.
+type flakyFS struct {
+	wfs.FS
+	down *atomic.Bool
+}
+
+func (f flakyFS) Open(name string) (fs.File, error) {
+	if f.down.Load() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errTransport}
+	}
+	return f.FS.Open(name)
+}
+
+func (f flakyFS) Stat(name string) (fs.FileInfo, error) {
+	if f.down.Load() {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: errTransport}
+	}
+	return fs.Stat(f.FS, name)
+}
+
+func (f flakyFS) Mkdir(name string, perm fs.FileMode) error {
+	if f.down.Load() {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: errTransport}
+	}
+	return f.FS.Mkdir(name, perm)
+}
+
+func TestFailover(t *testing.T) {
+	primary, secondary := wfs.Mem(), wfs.Mem()
+	wfs.WriteFile(primary, "file", []byte("primary"), 0644)
+	wfs.WriteFile(secondary, "file", []byte("secondary"), 0644)
+	var down atomic.Bool
+	fsys := wfs.Failover(flakyFS{primary, &down}, secondary)
+
+	assertContent(t, fsys, "file", "primary")
+	if fsys.Active() != 0 {
+		t.Errorf("expected primary to be active, got %d", fsys.Active())
+	}
+
+	// non-transport errors do not fail over
+	if err := fsys.Mkdir("file", 0755); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+	if fsys.Active() != 0 {
+		t.Errorf("expected primary to be active, got %d", fsys.Active())
+	}
+
+	down.Store(true)
+	assertContent(t, fsys, "file", "secondary")
+	if fsys.Active() != 1 {
+		t.Errorf("expected secondary to be active, got %d", fsys.Active())
+	}
+
+	// unhealthy backends are used again once they pass a probe
+	fsys.Probe(wfs.StatProbe)
+	if fsys.Active() != 1 {
+		t.Errorf("expected secondary to be active, got %d", fsys.Active())
+	}
+	down.Store(false)
+	fsys.Probe(wfs.StatProbe)
+	if fsys.Active() != 0 {
+		t.Errorf("expected primary to be active, got %d", fsys.Active())
+	}
+	assertContent(t, fsys, "file", "primary")
+
+	// every backend failed
+	down.Store(true)
+	fsys = wfs.Failover(flakyFS{primary, &down})
+	if _, err := fs.Stat(fsys, "file"); !errors.Is(err, errTransport) {
+		t.Errorf("expected transport error, got %v", err)
+	}
+	if _, err := fs.Stat(fsys, "file"); err == nil || fsys.Active() != -1 {
+		t.Errorf("expected no healthy backend, got %v", err)
+	}
+}
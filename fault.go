@@ -0,0 +1,365 @@
+package wfs
+
+import (
+	"cmp"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+var errFault = errors.New("injected fault")
+
+// FaultRule describes a fault injected by [Faulty].
+//
+// Operations are named like the Op of the errors they return: "open", "stat",
+// "lstat", "readlink", "rename", "remove", "RemoveAll", "mkdir", "MkdirAll",
+// "symlink", "link", "chmod", "chown" and "chtimes" on the file system, and
+// "read", "write", "seek", "truncate", "sync", "readdir" and "close" on files.
+// ReadAt and WriteAt are "read" and "write" operations.
+type FaultRule struct {
+	// Op is the operation to inject the fault into.
+	// If empty the fault is injected into every operation.
+	Op string
+
+	// Path is a [path.Match] pattern the file system path of the operation
+	// must match, against its base name if the pattern does not contain
+	// a slash. Rename and Link match either path.
+	// If empty every path matches.
+	Path string
+
+	// Nth injects the fault only into the nth matching operation, counting
+	// from 1. If zero the fault is injected into every matching operation.
+	Nth int
+
+	// After lets reads and writes of a file transfer After bytes in total
+	// before the fault is injected. The read or write that crosses the limit
+	// is cut short at the limit and returns the error of the fault.
+	// Other operations never match a rule with a positive After.
+	After int64
+
+	// Err is the error returned by the operation. If nil, reads return
+	// [io.EOF], writes return [io.ErrShortWrite] and other operations return
+	// an error reporting an injected fault.
+	Err error
+}
+
+// Faulty returns a file system that injects the faults described by rules
+// into the operations of fsys and of the files it opens, to test how errors
+// are handled. Rules are checked in order and the first rule that injects
+// a fault applies. Operations without a fault are passed to fsys.
+//
+// For example, the following rule fails the third write to a database file
+// with ENOSPC:
+//
+//	wfs.Faulty(fsys, wfs.FaultRule{Op: "write", Path: "*.db", Nth: 3, Err: syscall.ENOSPC})
+func Faulty(fsys FS, rules ...FaultRule) FS {
+	f := &faultyFs{fsys: fsys, counts: make([]int, len(rules))}
+	f.rules = append(f.rules, rules...)
+	return f
+}
+
+type faultyFs struct {
+	fsys  FS
+	rules []FaultRule
+
+	mu     sync.Mutex
+	counts []int // matching operations per rule
+}
+
+// fault returns the rule that injects a fault into op on names, or nil.
+// For reads and writes, done is the number of bytes already transferred by the
+// file and n the size of the operation.
+func (f *faultyFs) fault(op string, done, n int64, names ...string) *FaultRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.rules {
+		r := &f.rules[i]
+		if r.Op != "" && r.Op != op {
+			continue
+		}
+		if r.After > 0 && done+n <= r.After {
+			continue
+		}
+		if r.Path != "" && !matchAny([]string{r.Path}, names[0]) &&
+			(len(names) == 1 || !matchAny([]string{r.Path}, names[1])) {
+			continue
+		}
+		f.counts[i]++
+		if r.Nth == 0 || r.Nth == f.counts[i] {
+			return r
+		}
+	}
+	return nil
+}
+
+func (f *faultyFs) pathErr(op, name string) error {
+	if r := f.fault(op, 0, 0, name); r != nil {
+		return &fs.PathError{Op: op, Path: name, Err: cmp.Or(r.Err, errFault)}
+	}
+	return nil
+}
+
+func (f *faultyFs) linkErr(op, oldname, newname string) error {
+	if r := f.fault(op, 0, 0, oldname, newname); r != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: cmp.Or(r.Err, errFault)}
+	}
+	return nil
+}
+
+func (f *faultyFs) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for faultyFs.
+func (f *faultyFs) Stat(name string) (fs.FileInfo, error) {
+	if err := f.pathErr("stat", name); err != nil {
+		return nil, err
+	}
+	return fs.Stat(f.fsys, name)
+}
+
+func (f *faultyFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if err := f.pathErr("open", name); err != nil {
+		return nil, err
+	}
+	file, err := f.fsys.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &faultyFile{File: file, fs: f, name: name}, nil
+}
+
+func (f *faultyFs) Rename(oldpath, newpath string) error {
+	if err := f.linkErr("rename", oldpath, newpath); err != nil {
+		return err
+	}
+	return f.fsys.Rename(oldpath, newpath)
+}
+
+func (f *faultyFs) Remove(name string) error {
+	if err := f.pathErr("remove", name); err != nil {
+		return err
+	}
+	return f.fsys.Remove(name)
+}
+
+func (f *faultyFs) RemoveAll(path string) error {
+	if err := f.pathErr("RemoveAll", path); err != nil {
+		return err
+	}
+	return f.fsys.RemoveAll(path)
+}
+
+func (f *faultyFs) Mkdir(name string, perm fs.FileMode) error {
+	if err := f.pathErr("mkdir", name); err != nil {
+		return err
+	}
+	return f.fsys.Mkdir(name, perm)
+}
+
+func (f *faultyFs) MkdirAll(path string, perm fs.FileMode) error {
+	if err := f.pathErr("MkdirAll", path); err != nil {
+		return err
+	}
+	return f.fsys.MkdirAll(path, perm)
+}
+
+// Symlink implements [SymlinkFS] for faultyFs.
+func (f *faultyFs) Symlink(oldname, newname string) error {
+	if err := f.pathErr("symlink", newname); err != nil {
+		return err
+	}
+	return Symlink(f.fsys, oldname, newname)
+}
+
+// Readlink implements [SymlinkFS] for faultyFs.
+func (f *faultyFs) Readlink(name string) (string, error) {
+	if err := f.pathErr("readlink", name); err != nil {
+		return "", err
+	}
+	return Readlink(f.fsys, name)
+}
+
+// Lstat implements [SymlinkFS] for faultyFs.
+func (f *faultyFs) Lstat(name string) (fs.FileInfo, error) {
+	if err := f.pathErr("lstat", name); err != nil {
+		return nil, err
+	}
+	return Lstat(f.fsys, name)
+}
+
+// Link implements [LinkFS] for faultyFs.
+func (f *faultyFs) Link(oldname, newname string) error {
+	if err := f.linkErr("link", oldname, newname); err != nil {
+		return err
+	}
+	return Link(f.fsys, oldname, newname)
+}
+
+// Chmod implements [MetaFS] for faultyFs.
+func (f *faultyFs) Chmod(name string, mode fs.FileMode) error {
+	if err := f.pathErr("chmod", name); err != nil {
+		return err
+	}
+	return Chmod(f.fsys, name, mode)
+}
+
+// Chown implements [MetaFS] for faultyFs.
+func (f *faultyFs) Chown(name string, uid, gid int) error {
+	if err := f.pathErr("chown", name); err != nil {
+		return err
+	}
+	return Chown(f.fsys, name, uid, gid)
+}
+
+// Chtimes implements [MetaFS] for faultyFs.
+func (f *faultyFs) Chtimes(name string, atime, mtime time.Time) error {
+	if err := f.pathErr("chtimes", name); err != nil {
+		return err
+	}
+	return Chtimes(f.fsys, name, atime, mtime)
+}
+
+type faultyFile struct {
+	File
+	fs      *faultyFs
+	name    string
+	read    int64
+	written int64
+}
+
+// limit returns the number of bytes of b that can be transferred before the
+// fault of r is injected.
+func limit(r *FaultRule, done int64, b []byte) []byte {
+	if r.After <= done {
+		return b[:0]
+	}
+	return b[:min(int64(len(b)), r.After-done)]
+}
+
+func (f *faultyFile) ioErr(r *FaultRule, op string, def error) error {
+	if r.Err == nil {
+		return def
+	}
+	return &fs.PathError{Op: op, Path: f.name, Err: r.Err}
+}
+
+func (f *faultyFile) Read(b []byte) (int, error) {
+	r := f.fs.fault("read", f.read, int64(len(b)), f.name)
+	if r == nil {
+		n, err := f.File.Read(b)
+		f.read += int64(n)
+		return n, err
+	}
+	var n int
+	if b := limit(r, f.read, b); len(b) > 0 {
+		var err error
+		n, err = io.ReadFull(f.File, b)
+		f.read += int64(n)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return n, err
+		}
+	}
+	return n, f.ioErr(r, "read", io.EOF)
+}
+
+func (f *faultyFile) ReadAt(b []byte, off int64) (int, error) {
+	r := f.fs.fault("read", f.read, int64(len(b)), f.name)
+	if r == nil {
+		n, err := f.File.ReadAt(b, off)
+		f.read += int64(n)
+		return n, err
+	}
+	var n int
+	if b := limit(r, f.read, b); len(b) > 0 {
+		var err error
+		n, err = f.File.ReadAt(b, off)
+		f.read += int64(n)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, f.ioErr(r, "read", io.EOF)
+}
+
+func (f *faultyFile) Write(b []byte) (int, error) {
+	r := f.fs.fault("write", f.written, int64(len(b)), f.name)
+	if r == nil {
+		n, err := f.File.Write(b)
+		f.written += int64(n)
+		return n, err
+	}
+	var n int
+	if b := limit(r, f.written, b); len(b) > 0 {
+		var err error
+		n, err = f.File.Write(b)
+		f.written += int64(n)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, f.ioErr(r, "write", io.ErrShortWrite)
+}
+
+func (f *faultyFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *faultyFile) WriteAt(b []byte, off int64) (int, error) {
+	r := f.fs.fault("write", f.written, int64(len(b)), f.name)
+	if r == nil {
+		n, err := f.File.WriteAt(b, off)
+		f.written += int64(n)
+		return n, err
+	}
+	var n int
+	if b := limit(r, f.written, b); len(b) > 0 {
+		var err error
+		n, err = f.File.WriteAt(b, off)
+		f.written += int64(n)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, f.ioErr(r, "write", io.ErrShortWrite)
+}
+
+func (f *faultyFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.fs.pathErr("seek", f.name); err != nil {
+		return 0, err
+	}
+	return f.File.Seek(offset, whence)
+}
+
+func (f *faultyFile) Truncate(size int64) error {
+	if err := f.fs.pathErr("truncate", f.name); err != nil {
+		return err
+	}
+	return f.File.Truncate(size)
+}
+
+func (f *faultyFile) Sync() error {
+	if err := f.fs.pathErr("sync", f.name); err != nil {
+		return err
+	}
+	return f.File.Sync()
+}
+
+func (f *faultyFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if err := f.fs.pathErr("readdir", f.name); err != nil {
+		return nil, err
+	}
+	return readDir(f.File, n)
+}
+
+func (f *faultyFile) Close() error {
+	if err := f.fs.pathErr("close", f.name); err != nil {
+		// the file is closed regardless so that tests do not leak it
+		f.File.Close()
+		return err
+	}
+	return f.File.Close()
+}
@@ -0,0 +1,88 @@
+package wfs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+// errNoSpace stands in for a platform error such as ENOSPC, which is not
+// defined on every platform.
+var errNoSpace = errors.New("no space left on device")
+
+func TestFaulty(t *testing.T) {
+	t.Run("nth operation", func(t *testing.T) {
+		fsys := wfs.Faulty(wfs.Mem(), wfs.FaultRule{Op: "write", Path: "*.db", Nth: 3, Err: errNoSpace})
+		if err := fsys.Mkdir("data", 0755); err != nil {
+			t.Fatal(err)
+		}
+		f, err := wfs.Create(fsys, "data/app.db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		for i := 1; i <= 4; i++ {
+			_, err := f.Write([]byte("x"))
+			if i == 3 {
+				var pe *fs.PathError
+				if !errors.As(err, &pe) || pe.Op != "write" || !errors.Is(err, errNoSpace) {
+					t.Errorf("expected ENOSPC path error on write %d, got %v", i, err)
+				}
+			} else if err != nil {
+				t.Errorf("write %d: %v", i, err)
+			}
+		}
+		// other paths are not affected
+		if err := wfs.WriteFile(fsys, "data/log", []byte("x"), 0644); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("operations", func(t *testing.T) {
+		fsys := wfs.Faulty(wfs.Mem(),
+			wfs.FaultRule{Op: "remove", Err: fs.ErrPermission},
+			wfs.FaultRule{Op: "rename", Path: "dst"},
+		)
+		if err := wfs.WriteFile(fsys, "src", []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.Remove("src"); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("expected ErrPermission, got %v", err)
+		}
+		if err := fsys.Rename("src", "dst"); err == nil {
+			t.Error("expected rename to fail")
+		}
+		if err := fsys.Rename("src", "other"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("after bytes", func(t *testing.T) {
+		fsys := wfs.Faulty(wfs.Mem(),
+			wfs.FaultRule{Op: "read", After: 3},
+			wfs.FaultRule{Op: "write", After: 4},
+		)
+		f, err := wfs.Create(fsys, "file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := f.Write([]byte("hello"))
+		if n != 4 || err != io.ErrShortWrite {
+			t.Errorf("expected short write of 4 bytes, got %d, %v", n, err)
+		}
+		f.Close()
+
+		rf, err := fsys.Open("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rf.Close()
+		b, err := io.ReadAll(rf)
+		if string(b) != "hel" || err != nil {
+			t.Errorf("expected to read %q, got %q, %v", "hel", b, err)
+		}
+	})
+}
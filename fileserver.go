@@ -0,0 +1,206 @@
+package wfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ServerOption configures the handler returned by [FileServer].
+type ServerOption func(*fileServer)
+
+// Policy guards the requests of a [FileServer] with fn, which is called with
+// every request and the name of the file it targets before the request is
+// served, or with the name of every uploaded file for multipart uploads.
+// Requests are rejected with 403 Forbidden and the message of the error if
+// fn returns an error.
+func Policy(fn func(r *http.Request, name string) error) ServerOption {
+	return func(s *fileServer) { s.policy = fn }
+}
+
+// FileServer returns a handler serving fsys as a minimal file drop service:
+//
+//   - GET and HEAD serve files with range requests and list directories, as
+//     [http.FileServer] does with [HTTPFS].
+//   - PUT writes the request body to the file, replying 201 Created if the
+//     file was created and 204 No Content if it was replaced.
+//   - POST uploads the files of a multipart/form-data body into the
+//     directory under names sanitized with [SanitizeFilename], replacing
+//     existing files, and replies 201 Created with the names of the files
+//     written, one per line.
+//   - DELETE removes the file or empty directory, replying 204 No Content.
+//   - MKCOL creates the directory, replying 201 Created.
+//
+// Names are taken from the cleaned URL path relative to the root of fsys,
+// use [http.StripPrefix] to serve fsys under a prefix. Parent directories
+// must exist for every request that creates a file. Errors are replied with
+// the status code they correspond to, such as 404 Not Found for files that
+// do not exist and 409 Conflict for files in the way.
+//
+// Without a [Policy] option the handler is read-only: PUT, POST, DELETE and
+// MKCOL are replied with 405 Method Not Allowed. Mutations are served once a
+// Policy decides which of them to allow.
+func FileServer(fsys FS, opts ...ServerOption) http.Handler {
+	s := &fileServer{fsys: fsys, files: http.FileServer(HTTPFS(fsys))}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+type fileServer struct {
+	fsys   FS
+	files  http.Handler
+	policy func(r *http.Request, name string) error
+}
+
+// statusCodes maps errors to the status codes they are replied with. The
+// errors of this package come first as platform errors such as ENOTEMPTY
+// also match errors of [io/fs].
+var statusCodes = []struct {
+	err    error
+	status int
+}{
+	{ErrNotEmpty, http.StatusConflict},
+	{ErrIsDir, http.StatusConflict},
+	{ErrNotDir, http.StatusConflict},
+	{fs.ErrNotExist, http.StatusNotFound},
+	{fs.ErrExist, http.StatusConflict},
+	{fs.ErrPermission, http.StatusForbidden},
+	{fs.ErrInvalid, http.StatusBadRequest},
+}
+
+// httpError replies to the request with the status code err corresponds to.
+func httpError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	for _, c := range statusCodes {
+		if errors.Is(err, c.err) {
+			status = c.status
+			break
+		}
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// allow returns whether the policy allows the request for the named file,
+// replying to the request if it does not. Without a policy only reads are
+// allowed.
+func (s *fileServer) allow(w http.ResponseWriter, r *http.Request, name string) bool {
+	if s.policy == nil {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return true
+		}
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := s.policy(r, name); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if s.allow(w, r, name) {
+			s.files.ServeHTTP(w, r)
+		}
+	case http.MethodPut:
+		if s.allow(w, r, name) {
+			s.put(w, r, name)
+		}
+	case http.MethodPost:
+		if s.policy == nil && !s.allow(w, r, name) {
+			return
+		}
+		s.upload(w, r, name)
+	case http.MethodDelete:
+		if !s.allow(w, r, name) {
+			return
+		}
+		if err := s.fsys.Remove(name); err != nil {
+			httpError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "MKCOL":
+		if !s.allow(w, r, name) {
+			return
+		}
+		if err := s.fsys.Mkdir(name, 0755); err != nil {
+			httpError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST, DELETE, MKCOL")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// put writes the request body to the named file.
+func (s *fileServer) put(w http.ResponseWriter, r *http.Request, name string) {
+	exists, err := Exists(s.fsys, name)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	if _, err := WriteReader(s.fsys, name, r.Body, 0644); err != nil {
+		httpError(w, err)
+		return
+	}
+	if exists {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// upload writes the files of a multipart body into the directory dir. Parts
+// are written as they are read, so the files before a failing part are kept.
+func (s *fileServer) upload(w http.ResponseWriter, r *http.Request, dir string) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var names []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if part.FormName() == "" || part.FileName() == "" {
+			// form fields are not files
+			continue
+		}
+		name := path.Join(dir, SanitizeFilename(part.FileName()))
+		if !s.allow(w, r, name) {
+			return
+		}
+		if _, err := WriteReader(s.fsys, name, part, 0644); err != nil {
+			httpError(w, err)
+			return
+		}
+		names = append(names, name)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	for _, name := range names {
+		fmt.Fprintln(w, name)
+	}
+}
@@ -0,0 +1,146 @@
+package wfs_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestFileServer(t *testing.T) {
+	fsys := wfs.Mem()
+	srv := httptest.NewServer(wfs.FileServer(fsys, wfs.Policy(func(r *http.Request, name string) error {
+		if r.Method != http.MethodGet && strings.HasPrefix(name, "readonly") {
+			return errors.New("read-only directory")
+		}
+		return nil
+	})))
+	defer srv.Close()
+	do := func(method, target string, body io.Reader, contentType string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+target, body)
+		if err != nil {
+			t.Fatalf("NewRequest failed: %v", err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, target, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	if code, _ := do("MKCOL", "/uploads", nil, ""); code != http.StatusCreated {
+		t.Errorf("expected MKCOL to reply 201, got %d", code)
+	}
+	if code, _ := do("MKCOL", "/uploads", nil, ""); code != http.StatusConflict {
+		t.Errorf("expected MKCOL of an existing directory to reply 409, got %d", code)
+	}
+	if code, _ := do("PUT", "/uploads/a.txt", strings.NewReader("0123456789"), ""); code != http.StatusCreated {
+		t.Errorf("expected PUT to reply 201, got %d", code)
+	}
+	if code, _ := do("PUT", "/uploads/a.txt", strings.NewReader("abcdefghij"), ""); code != http.StatusNoContent {
+		t.Errorf("expected PUT of an existing file to reply 204, got %d", code)
+	}
+	if code, _ := do("PUT", "/missing/a.txt", strings.NewReader("data"), ""); code != http.StatusNotFound {
+		t.Errorf("expected PUT into a missing directory to reply 404, got %d", code)
+	}
+	assertContent(t, fsys, "uploads/a.txt", "abcdefghij")
+
+	// range requests
+	req, _ := http.NewRequest("GET", srv.URL+"/uploads/a.txt", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(data) != "cde" {
+		t.Errorf("expected 206 with %q, got %d with %q", "cde", resp.StatusCode, data)
+	}
+
+	// multipart uploads keep only the base of file names
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("comment", "ignored")
+	fw, _ := mw.CreateFormFile("file", "../../b.txt")
+	fw.Write([]byte("bee"))
+	fw, _ = mw.CreateFormFile("file", `C:\Users\me\c.txt`)
+	fw.Write([]byte("sea"))
+	mw.Close()
+	code, text := do("POST", "/uploads/", &body, mw.FormDataContentType())
+	if code != http.StatusCreated || text != "uploads/b.txt\nuploads/c.txt\n" {
+		t.Errorf("expected 201 with the uploaded names, got %d with %q", code, text)
+	}
+	assertContent(t, fsys, "uploads/b.txt", "bee")
+	assertContent(t, fsys, "uploads/c.txt", "sea")
+	if code, _ := do("POST", "/uploads/", strings.NewReader("data"), "text/plain"); code != http.StatusBadRequest {
+		t.Errorf("expected POST without a multipart body to reply 400, got %d", code)
+	}
+
+	if code, _ := do("DELETE", "/uploads", nil, ""); code != http.StatusConflict {
+		t.Errorf("expected DELETE of a non-empty directory to reply 409, got %d", code)
+	}
+	if code, _ := do("DELETE", "/uploads/a.txt", nil, ""); code != http.StatusNoContent {
+		t.Errorf("expected DELETE to reply 204, got %d", code)
+	}
+	assertEntries(t, fsys, "uploads", "b.txt", "c.txt")
+
+	// the policy guards every method
+	if err := fsys.Mkdir("readonly", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if code, _ := do("PUT", "/readonly/a.txt", strings.NewReader("data"), ""); code != http.StatusForbidden {
+		t.Errorf("expected the policy to reject PUT with 403, got %d", code)
+	}
+	if code, text := do("GET", "/readonly/", nil, ""); code != http.StatusOK {
+		t.Errorf("expected the policy to allow GET, got %d with %q", code, text)
+	}
+	if code, _ := do("PATCH", "/uploads/b.txt", nil, ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected PATCH to reply 405, got %d", code)
+	}
+}
+
+func TestFileServerReadOnly(t *testing.T) {
+	fsys := wfs.Mem()
+	if err := wfs.WriteFile(fsys, "file", []byte("data"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	srv := httptest.NewServer(wfs.FileServer(fsys))
+	defer srv.Close()
+
+	for _, method := range []string{"PUT", "POST", "DELETE", "MKCOL"} {
+		req, err := http.NewRequest(method, srv.URL+"/file", strings.NewReader("new"))
+		if err != nil {
+			t.Fatalf("NewRequest failed: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s failed: %v", method, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("expected %s without a policy to reply 405, got %d", method, resp.StatusCode)
+		}
+	}
+	resp, err := http.Get(srv.URL + "/file")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected GET without a policy to reply 200, got %d", resp.StatusCode)
+	}
+	assertContent(t, fsys, "file", "data")
+}
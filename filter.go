@@ -0,0 +1,300 @@
+package wfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Filter returns a file system that restricts access to the paths of fsys
+// matching at least one of the allow patterns and none of the deny patterns.
+// Patterns use the syntax of [Glob], so "uploads/**" allows uploads and
+// everything below it. A path also matches a pattern if one of its parent
+// directories does. If allow is empty every path not denied is allowed.
+//
+// Paths that are not allowed are hidden: operations on them fail with
+// [fs.ErrNotExist] and they are omitted from directory listings. The parent
+// directories of allowed paths can be read to reach them, but mutating them
+// fails with [fs.ErrPermission]. RemoveAll leaves hidden entries in place and
+// renaming a directory moves them along with it. Symbolic links are resolved
+// by fsys and may reach hidden files.
+//
+// If a pattern is malformed every operation fails with [path.ErrBadPattern].
+func Filter(fsys FS, allow, deny []string) FS {
+	f := &filterFs{fsys: fsys}
+	f.allow, f.err = splitPatterns(allow)
+	if f.err == nil {
+		f.deny, f.err = splitPatterns(deny)
+	}
+	return f
+}
+
+func splitPatterns(patterns []string) ([][]string, error) {
+	var split [][]string
+	for _, pattern := range patterns {
+		elems, err := splitPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		split = append(split, elems...)
+	}
+	return split, nil
+}
+
+type filterFs struct {
+	fsys  FS
+	allow [][]string
+	deny  [][]string
+	err   error // malformed pattern
+}
+
+func splitPath(name string) []string {
+	if name == "." {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+// matches reports whether elems or one of its parent directories match one
+// of patterns.
+func matches(patterns [][]string, elems []string) bool {
+	for _, pattern := range patterns {
+		for i := len(elems); i >= 0; i-- {
+			if matchElems(pattern, elems[:i]) {
+				return true
+			}
+			if i == 1 {
+				break // the root is only matched when it is named
+			}
+		}
+	}
+	return false
+}
+
+// visible reports whether the path elements are allowed.
+func (f *filterFs) visible(elems []string) bool {
+	if matches(f.deny, elems) {
+		return false
+	}
+	return len(f.allow) == 0 || matches(f.allow, elems)
+}
+
+// traversable reports whether the path elements are those of a visible path
+// or of a directory that may contain one.
+func (f *filterFs) traversable(elems []string) bool {
+	if f.visible(elems) {
+		return true
+	}
+	if matches(f.deny, elems) {
+		return false
+	}
+	for _, pattern := range f.allow {
+		if matchPrefix(pattern, elems) {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns an error if name cannot be accessed, or mutated if mutate
+// is set.
+func (f *filterFs) check(op, name string, mutate bool) error {
+	elems := splitPath(name)
+	switch {
+	case f.err != nil:
+		return &fs.PathError{Op: op, Path: name, Err: f.err}
+	case f.visible(elems):
+		return nil
+	case !f.traversable(elems):
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case mutate:
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+// checkLink is like check for operations on two paths.
+func (f *filterFs) checkLink(op, oldname, newname string) error {
+	for _, name := range []string{oldname, newname} {
+		if err := f.check(op, name, true); err != nil {
+			return &os.LinkError{Op: op, Old: oldname, New: newname, Err: err.(*fs.PathError).Err}
+		}
+	}
+	return nil
+}
+
+func (f *filterFs) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for filterFs.
+func (f *filterFs) Stat(name string) (fs.FileInfo, error) {
+	if err := f.check("stat", name, false); err != nil {
+		return nil, err
+	}
+	return fs.Stat(f.fsys, name)
+}
+
+func (f *filterFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	mutate := flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0
+	if err := f.check("open", name, mutate); err != nil {
+		return nil, err
+	}
+	file, err := f.fsys.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &filterFile{File: file, fs: f, name: name}, nil
+}
+
+func (f *filterFs) Rename(oldpath, newpath string) error {
+	if err := f.checkLink("rename", oldpath, newpath); err != nil {
+		return err
+	}
+	return f.fsys.Rename(oldpath, newpath)
+}
+
+func (f *filterFs) Remove(name string) error {
+	if err := f.check("remove", name, true); err != nil {
+		return err
+	}
+	return f.fsys.Remove(name)
+}
+
+func (f *filterFs) RemoveAll(path string) error {
+	if err := f.check("RemoveAll", path, true); err != nil {
+		return err
+	}
+	if len(f.deny) == 0 {
+		// everything below an allowed path is allowed
+		return f.fsys.RemoveAll(path)
+	}
+	return f.removeAll(path)
+}
+
+// removeAll removes path and the visible entries it contains.
+func (f *filterFs) removeAll(name string) error {
+	info, err := Lstat(f.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := fs.ReadDir(f.fsys, name)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			child := path.Join(name, e.Name())
+			if !f.visible(splitPath(child)) {
+				continue
+			}
+			if err := f.removeAll(child); err != nil {
+				return err
+			}
+		}
+	}
+	return f.fsys.Remove(name)
+}
+
+func (f *filterFs) Mkdir(name string, perm fs.FileMode) error {
+	if err := f.check("mkdir", name, true); err != nil {
+		return err
+	}
+	return f.fsys.Mkdir(name, perm)
+}
+
+func (f *filterFs) MkdirAll(path string, perm fs.FileMode) error {
+	if err := f.check("mkdir", path, true); err != nil {
+		return err
+	}
+	return f.fsys.MkdirAll(path, perm)
+}
+
+// Symlink implements [SymlinkFS] for filterFs.
+func (f *filterFs) Symlink(oldname, newname string) error {
+	if err := f.check("symlink", newname, true); err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err.(*fs.PathError).Err}
+	}
+	return Symlink(f.fsys, oldname, newname)
+}
+
+// Readlink implements [SymlinkFS] for filterFs.
+func (f *filterFs) Readlink(name string) (string, error) {
+	if err := f.check("readlink", name, false); err != nil {
+		return "", err
+	}
+	return Readlink(f.fsys, name)
+}
+
+// Lstat implements [SymlinkFS] for filterFs.
+func (f *filterFs) Lstat(name string) (fs.FileInfo, error) {
+	if err := f.check("lstat", name, false); err != nil {
+		return nil, err
+	}
+	return Lstat(f.fsys, name)
+}
+
+// Link implements [LinkFS] for filterFs.
+func (f *filterFs) Link(oldname, newname string) error {
+	if err := f.checkLink("link", oldname, newname); err != nil {
+		return err
+	}
+	return Link(f.fsys, oldname, newname)
+}
+
+// Chmod implements [MetaFS] for filterFs.
+func (f *filterFs) Chmod(name string, mode fs.FileMode) error {
+	if err := f.check("chmod", name, true); err != nil {
+		return err
+	}
+	return Chmod(f.fsys, name, mode)
+}
+
+// Chown implements [MetaFS] for filterFs.
+func (f *filterFs) Chown(name string, uid, gid int) error {
+	if err := f.check("chown", name, true); err != nil {
+		return err
+	}
+	return Chown(f.fsys, name, uid, gid)
+}
+
+// Chtimes implements [MetaFS] for filterFs.
+func (f *filterFs) Chtimes(name string, atime, mtime time.Time) error {
+	if err := f.check("chtimes", name, true); err != nil {
+		return err
+	}
+	return Chtimes(f.fsys, name, atime, mtime)
+}
+
+// filterFile omits hidden entries from directory listings.
+type filterFile struct {
+	File
+	fs   *filterFs
+	name string
+}
+
+func (f *filterFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	for {
+		batch, err := readDir(f.File, n)
+		for _, e := range batch {
+			if f.fs.traversable(splitPath(path.Join(f.name, e.Name()))) {
+				entries = append(entries, e)
+			}
+		}
+		// keep reading until n visible entries are found
+		if err != nil || n <= 0 || len(entries) > 0 {
+			if err == io.EOF && len(entries) > 0 {
+				err = nil
+			}
+			return entries, err
+		}
+	}
+}
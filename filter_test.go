@@ -0,0 +1,92 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestFilter(t *testing.T) {
+	newFS := func(t *testing.T) wfs.FS {
+		mem := wfs.Mem()
+		for name, content := range map[string]string{
+			"uploads/a":       "a",
+			"uploads/tmp/b":   "b",
+			"uploads/img/c":   "c",
+			"secret":          "s",
+			"private/uploads": "p",
+		} {
+			if err := mem.MkdirAll(path.Dir(name), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := wfs.WriteFile(mem, name, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return mem
+	}
+
+	t.Run("visibility", func(t *testing.T) {
+		mem := newFS(t)
+		fsys := wfs.Filter(mem, []string{"uploads/**"}, []string{"**/tmp"})
+		assertEntries(t, fsys, ".", "uploads")
+		assertEntries(t, fsys, "uploads", "a", "img")
+		assertContent(t, fsys, "uploads/img/c", "c")
+		for _, name := range []string{"secret", "private", "uploads/tmp", "uploads/tmp/b"} {
+			if _, err := fs.Stat(fsys, name); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected %s to be hidden, got %v", name, err)
+			}
+		}
+		if err := fstest.TestFS(fsys, "uploads/a", "uploads/img/c"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("mutations", func(t *testing.T) {
+		mem := newFS(t)
+		fsys := wfs.Filter(mem, []string{"uploads/**"}, []string{"**/tmp"})
+		if err := wfs.WriteFile(fsys, "uploads/new", []byte("new"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := wfs.WriteFile(fsys, "secret", []byte("x"), 0644); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected ErrNotExist, got %v", err)
+		}
+		if err := fsys.Mkdir("other", 0755); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected ErrNotExist, got %v", err)
+		}
+		if err := fsys.Rename("uploads/a", "secret"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected ErrNotExist, got %v", err)
+		}
+		if err := fsys.Rename("uploads/a", "uploads/img/a"); err != nil {
+			t.Error(err)
+		}
+		assertContent(t, mem, "secret", "s")
+
+		// hidden entries are left in place
+		if err := fsys.RemoveAll("uploads"); !errors.Is(err, wfs.ErrNotEmpty) {
+			t.Errorf("expected ErrNotEmpty, got %v", err)
+		}
+		assertEntries(t, mem, "uploads", "tmp")
+		assertContent(t, mem, "uploads/tmp/b", "b")
+	})
+
+	t.Run("parent directories", func(t *testing.T) {
+		mem := newFS(t)
+		fsys := wfs.Filter(mem, []string{"uploads/img"}, nil)
+		assertEntries(t, fsys, "uploads", "img")
+		if err := fsys.Remove("uploads"); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("expected ErrPermission, got %v", err)
+		}
+	})
+
+	t.Run("bad pattern", func(t *testing.T) {
+		fsys := wfs.Filter(newFS(t), []string{"uploads/["}, nil)
+		if _, err := fs.Stat(fsys, "uploads/a"); !errors.Is(err, path.ErrBadPattern) {
+			t.Errorf("expected ErrBadPattern, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package wfs
+
+import (
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Glob returns the names of all files in fsys matching pattern or nil if
+// there is no matching file. In addition to the syntax of [path.Match],
+// a "**" path element matches zero or more directories and braces expand
+// to alternatives, so "src/**/*.{css,scss}" matches every CSS and SCSS file
+// below src. Symbolic links to directories are not followed by "**".
+//
+// Like [fs.Glob], Glob ignores file system errors such as I/O errors reading
+// directories. The only possible returned error is [path.ErrBadPattern],
+// reporting that the pattern is malformed. The names are returned in
+// lexical order.
+func Glob(fsys fs.FS, pattern string) ([]string, error) {
+	patterns, err := splitPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, elems := range patterns {
+		if !slices.Contains(elems, "**") {
+			// fs.Glob uses the file system's own Glob if it has one
+			m, err := fs.Glob(fsys, strings.Join(elems, "/"))
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, m...)
+			continue
+		}
+		dir := "."
+		if elems[0] == "" {
+			dir, elems = "/", elems[1:]
+		}
+		matches = globElems(fsys, dir, elems, matches)
+	}
+	slices.Sort(matches)
+	return slices.Compact(matches), nil
+}
+
+// globElems appends the names below dir matching the pattern elements elems.
+func globElems(fsys fs.FS, dir string, elems []string, matches []string) []string {
+	if len(elems) == 0 {
+		if dir != "." {
+			matches = append(matches, dir)
+		}
+		return matches
+	}
+	elem, rest := elems[0], elems[1:]
+	if elem == "**" {
+		matches = globElems(fsys, dir, rest, matches)
+		entries, _ := fs.ReadDir(fsys, dir)
+		for _, e := range entries {
+			name := path.Join(dir, e.Name())
+			if e.IsDir() {
+				matches = globElems(fsys, name, elems, matches)
+			} else if len(rest) == 0 {
+				matches = append(matches, name)
+			}
+		}
+		return matches
+	}
+	if !hasMeta(elem) {
+		name := path.Join(dir, elem)
+		if _, err := fs.Stat(fsys, name); err != nil {
+			return matches
+		}
+		return globElems(fsys, name, rest, matches)
+	}
+	entries, _ := fs.ReadDir(fsys, dir)
+	for _, e := range entries {
+		if ok, _ := path.Match(elem, e.Name()); ok {
+			matches = globElems(fsys, path.Join(dir, e.Name()), rest, matches)
+		}
+	}
+	return matches
+}
+
+// hasMeta reports whether elem contains any of the magic characters
+// recognized by [path.Match].
+func hasMeta(elem string) bool {
+	return strings.ContainsAny(elem, `*?[\`)
+}
+
+// expandBraces returns the patterns obtained by expanding the comma-separated
+// alternatives of every brace group in pattern. Braces may be nested and are
+// matched literally when escaped with a backslash or unmatched.
+func expandBraces(pattern string) ([]string, error) {
+	start, depth := -1, 0
+	var commas []int
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			if depth == 0 {
+				// an unmatched closing brace is literal
+				continue
+			}
+			if depth--; depth > 0 {
+				continue
+			}
+			var patterns []string
+			bounds := append(append([]int{start}, commas...), i)
+			for j := range len(bounds) - 1 {
+				alt := pattern[:start] + pattern[bounds[j]+1:bounds[j+1]] + pattern[i+1:]
+				expanded, err := expandBraces(alt)
+				if err != nil {
+					return nil, err
+				}
+				patterns = append(patterns, expanded...)
+			}
+			return patterns, nil
+		}
+	}
+	if depth != 0 {
+		return nil, path.ErrBadPattern
+	}
+	return []string{pattern}, nil
+}
+
+// splitPattern returns the path elements of the brace expansions of pattern,
+// checking that it is well formed.
+func splitPattern(pattern string) ([][]string, error) {
+	patterns, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var split [][]string
+	for _, pattern := range patterns {
+		elems := strings.Split(pattern, "/")
+		for _, elem := range elems {
+			if _, err := path.Match(elem, ""); err != nil {
+				return nil, err
+			}
+		}
+		split = append(split, elems)
+	}
+	return split, nil
+}
+
+// matchElems reports whether the path elements name match the pattern
+// elements of a pattern split by splitPattern.
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := range len(name) + 1 {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchPrefix reports whether the pattern elements may match the path
+// elements dir or a path below dir.
+func matchPrefix(pattern, dir []string) bool {
+	for len(dir) > 0 {
+		if len(pattern) == 0 {
+			return false
+		}
+		if pattern[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(pattern[0], dir[0]); !ok {
+			return false
+		}
+		pattern, dir = pattern[1:], dir[1:]
+	}
+	return true
+}
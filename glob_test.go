@@ -0,0 +1,77 @@
+package wfs_test
+
+import (
+	"path"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestGlob(t *testing.T) {
+	files := func() fstest.MapFS {
+		return fstest.MapFS{
+			"index.html":             &fstest.MapFile{},
+			"src/app.css":            &fstest.MapFile{},
+			"src/app.go":             &fstest.MapFile{},
+			"src/theme/dark.css":     &fstest.MapFile{},
+			"src/theme/light.scss":   &fstest.MapFile{},
+			"src/theme/icons/a.svg":  &fstest.MapFile{},
+			"vendor/lib/lib.css":     &fstest.MapFile{},
+			"vendor/lib/src/x.css":   &fstest.MapFile{},
+			"docs/{braces}.md":       &fstest.MapFile{},
+			"docs/guide/intro.md":    &fstest.MapFile{},
+			"docs/guide/advanced.md": &fstest.MapFile{},
+		}
+	}
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"*.html", []string{"index.html"}},
+		{"src/**/*.css", []string{"src/app.css", "src/theme/dark.css"}},
+		{"**/*.css", []string{"src/app.css", "src/theme/dark.css", "vendor/lib/lib.css", "vendor/lib/src/x.css"}},
+		{"src/**/*.{css,scss}", []string{"src/app.css", "src/theme/dark.css", "src/theme/light.scss"}},
+		{"{src,vendor/lib}/*.css", []string{"src/app.css", "vendor/lib/lib.css"}},
+		{"**/src/*.css", []string{"src/app.css", "vendor/lib/src/x.css"}},
+		{"src/theme/**", []string{"src/theme", "src/theme/dark.css", "src/theme/icons", "src/theme/icons/a.svg", "src/theme/light.scss"}},
+		{"docs/**/{intro,adv*}.md", []string{"docs/guide/advanced.md", "docs/guide/intro.md"}},
+		{"docs/\\{braces}.md", []string{"docs/{braces}.md"}},
+		{"docs/braces}.md", nil},
+		{"{src,src/theme}/*.css", []string{"src/app.css", "src/theme/dark.css"}},
+		{"missing/**/*.css", nil},
+	}
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(files())
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			base = filepath.ToSlash(base)
+			for _, test := range tests {
+				matches, err := wfs.Glob(fsys, path.Join(base, test.pattern))
+				if err != nil {
+					t.Errorf("Glob(%q) failed: %v", test.pattern, err)
+					continue
+				}
+				var expected []string
+				for _, name := range test.expected {
+					expected = append(expected, path.Join(base, name))
+				}
+				if !slices.Equal(matches, expected) {
+					t.Errorf("Glob(%q): expected %v, got %v", test.pattern, expected, matches)
+				}
+			}
+		})
+	}
+
+	for _, pattern := range []string{"src/{a,b", "src/**/[", "{[}"} {
+		if _, err := wfs.Glob(wfs.Map(files()), pattern); err == nil {
+			t.Errorf("Glob(%q): expected ErrBadPattern", pattern)
+		}
+	}
+}
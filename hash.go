@@ -0,0 +1,80 @@
+package wfs
+
+import (
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// HashFS is the interface implemented by a file system that can serve
+// precomputed content digests, such as an object store etag or the address
+// of a content-addressed blob.
+type HashFS interface {
+	fs.FS
+
+	// Hash returns the digest of the named file as computed by a hash.Hash
+	// returned from h. If no precomputed digest compatible with h is
+	// available, it returns an error that wraps [errors.ErrUnsupported].
+	Hash(name string, h func() hash.Hash) ([]byte, error)
+}
+
+// HashFile returns the digest of the named file as computed by a hash.Hash
+// returned from h.
+//
+// If fsys implements [HashFS] and has a compatible precomputed digest it is
+// returned, otherwise the file contents are streamed through the hash.
+func HashFile(fsys fs.FS, name string, h func() hash.Hash) ([]byte, error) {
+	if fsys, ok := fsys.(HashFS); ok {
+		sum, err := fsys.Hash(name, h)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return sum, err
+		}
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hh := h()
+	if _, err := io.Copy(hh, f); err != nil {
+		return nil, err
+	}
+	return hh.Sum(nil), nil
+}
+
+// HashTree returns the digests of all regular files in the tree rooted at root,
+// keyed by their slash-separated path relative to root.
+// Each digest is computed as with [HashFile].
+func HashTree(fsys fs.FS, root string, h func() hash.Hash) (map[string][]byte, error) {
+	sums := make(map[string][]byte)
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		sum, err := HashFile(fsys, name, h)
+		if err != nil {
+			return err
+		}
+		sums[relPath(root, name)] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// relPath returns name relative to root, where name is root or a descendant of root.
+func relPath(root, name string) string {
+	if name == root {
+		return path.Base(name)
+	}
+	if root == "." {
+		return name
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(name, root), "/")
+}
@@ -0,0 +1,105 @@
+package wfs_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+type precomputedFS struct {
+	fstest.MapFS
+	calls int
+}
+
+func (f *precomputedFS) Hash(name string, h func() hash.Hash) ([]byte, error) {
+	f.calls++
+	if name == "precomputed" {
+		return []byte("etag"), nil
+	}
+	return nil, errors.ErrUnsupported
+}
+
+func TestHashFile(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"testfile": &fstest.MapFile{Data: []byte("Hello, World!")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			sum, err := wfs.HashFile(fsys, filepath.Join(base, "testfile"), sha256.New)
+			if err != nil {
+				t.Fatalf("HashFile failed: %v", err)
+			}
+			if hex.EncodeToString(sum) != sha256Hex("Hello, World!") {
+				t.Errorf("unexpected digest %x", sum)
+			}
+		})
+	}
+}
+
+func TestHashFilePrecomputed(t *testing.T) {
+	fsys := &precomputedFS{MapFS: fstest.MapFS{
+		"precomputed": &fstest.MapFile{Data: []byte("Hello")},
+		"streamed":    &fstest.MapFile{Data: []byte("Hello")},
+	}}
+
+	sum, err := wfs.HashFile(fsys, "precomputed", sha256.New)
+	if err != nil || string(sum) != "etag" {
+		t.Errorf("expected precomputed digest, got %q err: %v", sum, err)
+	}
+	sum, err = wfs.HashFile(fsys, "streamed", sha256.New)
+	if err != nil || hex.EncodeToString(sum) != sha256Hex("Hello") {
+		t.Errorf("expected streamed digest, got %x err: %v", sum, err)
+	}
+	if fsys.calls != 2 {
+		t.Errorf("expected 2 calls to Hash, got %d", fsys.calls)
+	}
+}
+
+func TestHashTree(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"root/file":        &fstest.MapFile{Data: []byte("file")},
+				"root/nested/file": &fstest.MapFile{Data: []byte("nested")},
+				"other":            &fstest.MapFile{Data: []byte("other")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			sums, err := wfs.HashTree(fsys, filepath.Join(base, "root"), sha256.New)
+			if err != nil {
+				t.Fatalf("HashTree failed: %v", err)
+			}
+			expected := map[string]string{
+				"file":        sha256Hex("file"),
+				"nested/file": sha256Hex("nested"),
+			}
+			if len(sums) != len(expected) {
+				t.Fatalf("expected %d digests, got %d", len(expected), len(sums))
+			}
+			for name, want := range expected {
+				if got := hex.EncodeToString(sums[name]); got != want {
+					t.Errorf("expected digest of %q to be %s, got %s", name, want, got)
+				}
+			}
+		})
+	}
+}
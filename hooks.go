@@ -0,0 +1,229 @@
+package wfs
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+// HookOp describes an operation intercepted by [WithHooks].
+type HookOp struct {
+	Op      string      // operation, named like in [FaultRule]
+	Path    string      // path of the file, or new path of rename, link and symlink
+	OldPath string      // old path of rename and link, or target of symlink
+	Flag    int         // flags of open
+	Mode    fs.FileMode // permissions of open, mkdir and MkdirAll, or mode of chmod
+	Size    int64       // bytes read or written, set before After is called, or size of truncate
+}
+
+// Hooks are the callbacks of [WithHooks]. Either may be nil.
+type Hooks struct {
+	// Before is called before each operation. It may rewrite the paths of the
+	// operation, and if it returns an error the operation is not performed
+	// and returns the error.
+	Before func(op *HookOp) error
+
+	// After is called after each operation that was performed with the error
+	// it returned, and the operation returns the error returned by After.
+	After func(op *HookOp, err error) error
+}
+
+// WithHooks returns a file system that calls hooks around every operation on
+// fsys and on the files it opens. Hooks can rewrite paths, veto operations and
+// observe their results, and wrappers can be composed by nesting WithHooks.
+//
+// Paths of file operations such as "read" and "write" are the names the files
+// were opened with and rewriting them has no effect.
+func WithHooks(fsys FS, hooks Hooks) FS {
+	return &hookFs{fsys, hooks}
+}
+
+type hookFs struct {
+	fsys  FS
+	hooks Hooks
+}
+
+// hook calls fn between the hooks of f. fn reads the paths of op,
+// after they are rewritten by Before.
+func hook[T any](f *hookFs, op *HookOp, fn func() (T, error)) (T, error) {
+	if f.hooks.Before != nil {
+		if err := f.hooks.Before(op); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+	v, err := fn()
+	if f.hooks.After != nil {
+		err = f.hooks.After(op, err)
+	}
+	return v, err
+}
+
+// hookErr is like hook for functions that only return an error.
+func hookErr(f *hookFs, op *HookOp, fn func() error) error {
+	_, err := hook(f, op, func() (struct{}, error) { return struct{}{}, fn() })
+	return err
+}
+
+func (f *hookFs) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for hookFs.
+func (f *hookFs) Stat(name string) (fs.FileInfo, error) {
+	op := &HookOp{Op: "stat", Path: name}
+	return hook(f, op, func() (fs.FileInfo, error) { return fs.Stat(f.fsys, op.Path) })
+}
+
+func (f *hookFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	op := &HookOp{Op: "open", Path: name, Flag: flag, Mode: perm}
+	var opened File
+	file, err := hook(f, op, func() (File, error) {
+		file, err := f.fsys.OpenFile(op.Path, flag, perm)
+		opened = file
+		return file, err
+	})
+	if err != nil {
+		// After may fail an open that succeeded
+		if opened != nil {
+			opened.Close()
+		}
+		return nil, err
+	}
+	return &hookFile{File: file, fs: f, name: name}, nil
+}
+
+func (f *hookFs) Rename(oldpath, newpath string) error {
+	op := &HookOp{Op: "rename", Path: newpath, OldPath: oldpath}
+	return hookErr(f, op, func() error { return f.fsys.Rename(op.OldPath, op.Path) })
+}
+
+func (f *hookFs) Remove(name string) error {
+	op := &HookOp{Op: "remove", Path: name}
+	return hookErr(f, op, func() error { return f.fsys.Remove(op.Path) })
+}
+
+func (f *hookFs) RemoveAll(path string) error {
+	op := &HookOp{Op: "RemoveAll", Path: path}
+	return hookErr(f, op, func() error { return f.fsys.RemoveAll(op.Path) })
+}
+
+func (f *hookFs) Mkdir(name string, perm fs.FileMode) error {
+	op := &HookOp{Op: "mkdir", Path: name, Mode: perm}
+	return hookErr(f, op, func() error { return f.fsys.Mkdir(op.Path, perm) })
+}
+
+func (f *hookFs) MkdirAll(path string, perm fs.FileMode) error {
+	op := &HookOp{Op: "MkdirAll", Path: path, Mode: perm}
+	return hookErr(f, op, func() error { return f.fsys.MkdirAll(op.Path, perm) })
+}
+
+// Symlink implements [SymlinkFS] for hookFs.
+func (f *hookFs) Symlink(oldname, newname string) error {
+	op := &HookOp{Op: "symlink", Path: newname, OldPath: oldname}
+	return hookErr(f, op, func() error { return Symlink(f.fsys, op.OldPath, op.Path) })
+}
+
+// Readlink implements [SymlinkFS] for hookFs.
+func (f *hookFs) Readlink(name string) (string, error) {
+	op := &HookOp{Op: "readlink", Path: name}
+	return hook(f, op, func() (string, error) { return Readlink(f.fsys, op.Path) })
+}
+
+// Lstat implements [SymlinkFS] for hookFs.
+func (f *hookFs) Lstat(name string) (fs.FileInfo, error) {
+	op := &HookOp{Op: "lstat", Path: name}
+	return hook(f, op, func() (fs.FileInfo, error) { return Lstat(f.fsys, op.Path) })
+}
+
+// Link implements [LinkFS] for hookFs.
+func (f *hookFs) Link(oldname, newname string) error {
+	op := &HookOp{Op: "link", Path: newname, OldPath: oldname}
+	return hookErr(f, op, func() error { return Link(f.fsys, op.OldPath, op.Path) })
+}
+
+// Chmod implements [MetaFS] for hookFs.
+func (f *hookFs) Chmod(name string, mode fs.FileMode) error {
+	op := &HookOp{Op: "chmod", Path: name, Mode: mode}
+	return hookErr(f, op, func() error { return Chmod(f.fsys, op.Path, mode) })
+}
+
+// Chown implements [MetaFS] for hookFs.
+func (f *hookFs) Chown(name string, uid, gid int) error {
+	op := &HookOp{Op: "chown", Path: name}
+	return hookErr(f, op, func() error { return Chown(f.fsys, op.Path, uid, gid) })
+}
+
+// Chtimes implements [MetaFS] for hookFs.
+func (f *hookFs) Chtimes(name string, atime, mtime time.Time) error {
+	op := &HookOp{Op: "chtimes", Path: name}
+	return hookErr(f, op, func() error { return Chtimes(f.fsys, op.Path, atime, mtime) })
+}
+
+type hookFile struct {
+	File
+	fs   *hookFs
+	name string
+}
+
+// Name returns the name the file was opened with,
+// before it was rewritten by the hooks.
+func (f *hookFile) Name() string { return f.name }
+
+func (f *hookFile) Read(b []byte) (int, error) {
+	op := &HookOp{Op: "read", Path: f.name}
+	return hook(f.fs, op, func() (int, error) {
+		n, err := f.File.Read(b)
+		op.Size = int64(n)
+		return n, err
+	})
+}
+
+func (f *hookFile) ReadAt(b []byte, off int64) (int, error) {
+	op := &HookOp{Op: "read", Path: f.name}
+	return hook(f.fs, op, func() (int, error) {
+		n, err := f.File.ReadAt(b, off)
+		op.Size = int64(n)
+		return n, err
+	})
+}
+
+func (f *hookFile) Write(b []byte) (int, error) {
+	op := &HookOp{Op: "write", Path: f.name}
+	return hook(f.fs, op, func() (int, error) {
+		n, err := f.File.Write(b)
+		op.Size = int64(n)
+		return n, err
+	})
+}
+
+func (f *hookFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *hookFile) WriteAt(b []byte, off int64) (int, error) {
+	op := &HookOp{Op: "write", Path: f.name}
+	return hook(f.fs, op, func() (int, error) {
+		n, err := f.File.WriteAt(b, off)
+		op.Size = int64(n)
+		return n, err
+	})
+}
+
+func (f *hookFile) Truncate(size int64) error {
+	return hookErr(f.fs, &HookOp{Op: "truncate", Path: f.name, Size: size}, func() error {
+		return f.File.Truncate(size)
+	})
+}
+
+func (f *hookFile) Sync() error {
+	return hookErr(f.fs, &HookOp{Op: "sync", Path: f.name}, f.File.Sync)
+}
+
+func (f *hookFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return readDir(f.File, n)
+}
+
+func (f *hookFile) Close() error {
+	return hookErr(f.fs, &HookOp{Op: "close", Path: f.name}, f.File.Close)
+}
@@ -0,0 +1,67 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestWithHooks(t *testing.T) {
+	mem := wfs.Mem()
+	if err := mem.Mkdir("tenant", 0755); err != nil {
+		t.Fatal(err)
+	}
+	var observed []string
+	fsys := wfs.WithHooks(mem, wfs.Hooks{
+		Before: func(op *wfs.HookOp) error {
+			if op.Op == "remove" {
+				return &fs.PathError{Op: op.Op, Path: op.Path, Err: fs.ErrPermission}
+			}
+			// rewrite every path into the tenant directory
+			op.Path = path.Join("tenant", op.Path)
+			if op.OldPath != "" {
+				op.OldPath = path.Join("tenant", op.OldPath)
+			}
+			return nil
+		},
+		After: func(op *wfs.HookOp, err error) error {
+			if op.Op == "write" {
+				observed = append(observed, op.Path, strconv.FormatInt(op.Size, 10))
+			}
+			if op.Op == "rename" && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		},
+	})
+
+	if err := wfs.WriteFile(fsys, "file", []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, mem, "tenant/file", "hello")
+	assertContent(t, fsys, "file", "hello")
+	if !slices.Equal(observed, []string{"tenant/file", "5"}) {
+		t.Errorf("expected write of 5 bytes to be observed, got %v", observed)
+	}
+
+	if err := fsys.Rename("file", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	assertEntries(t, mem, "tenant", "renamed")
+
+	// After can rewrite errors
+	if err := fsys.Rename("missing", "other"); err != nil {
+		t.Errorf("expected error to be suppressed, got %v", err)
+	}
+
+	// Before can veto operations
+	if err := fsys.Remove("renamed"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected ErrPermission, got %v", err)
+	}
+	assertEntries(t, mem, "tenant", "renamed")
+}
@@ -0,0 +1,105 @@
+package wfs
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// HTTPOption configures the file system returned by [HTTPFS].
+type HTTPOption func(*httpFs)
+
+// NoDirListing disables directory listings. Directories without an index
+// file fail to open with an error wrapping [fs.ErrPermission], which
+// [http.FileServer] responds to with 403 Forbidden.
+func NoDirListing() HTTPOption {
+	return func(f *httpFs) { f.noList = true }
+}
+
+// IndexFiles sets the names of the files served for a directory, in order
+// of preference, instead of index.html.
+func IndexFiles(names ...string) HTTPOption {
+	return func(f *httpFs) { f.index = names }
+}
+
+// HTTPErrors maps the errors of opening files with fn before they are
+// returned to [http.FileServer], which responds with 404 Not Found to errors
+// wrapping [fs.ErrNotExist], 403 Forbidden to errors wrapping
+// [fs.ErrPermission] and 500 Internal Server Error otherwise. For example,
+// mapping permission errors to [fs.ErrNotExist] hides files that cannot be
+// read.
+func HTTPErrors(fn func(err error) error) HTTPOption {
+	return func(f *httpFs) { f.mapErr = fn }
+}
+
+// HTTPFS returns an [http.FileSystem] serving fsys, to be used with
+// [http.FileServer]. Like [http.FS], it requires the files of fsys to
+// implement [io.Seeker] and directories to implement [fs.ReadDirFile],
+// which the files of every FS do.
+//
+// Without options, HTTPFS behaves like http.FS: directories are listed
+// unless they contain an index.html file.
+func HTTPFS(fsys fs.FS, opts ...HTTPOption) http.FileSystem {
+	f := &httpFs{fs: http.FS(fsys), fsys: fsys, index: []string{"index.html"}}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+type httpFs struct {
+	fs     http.FileSystem
+	fsys   fs.FS
+	noList bool
+	index  []string
+	mapErr func(error) error
+}
+
+func (f *httpFs) Open(name string) (http.File, error) {
+	file, err := f.open(name)
+	if err != nil && f.mapErr != nil {
+		err = f.mapErr(err)
+	}
+	return file, err
+}
+
+func (f *httpFs) open(name string) (http.File, error) {
+	// http.FileServer serves the index of a directory by opening index.html
+	// in it, which is redirected to the index file configured
+	if path.Base(name) == "index.html" {
+		dir := path.Dir(name)
+		index, ok := f.indexFile(dir)
+		if !ok {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		name = path.Join(dir, index)
+	}
+	file, err := f.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if f.noList {
+		info, err := file.Stat()
+		if err == nil && info.IsDir() {
+			if _, ok := f.indexFile(name); !ok {
+				file.Close()
+				return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+			}
+		}
+	}
+	return file, nil
+}
+
+// indexFile returns the name of the first index file that exists in the
+// directory dir.
+func (f *httpFs) indexFile(dir string) (string, bool) {
+	dir = strings.TrimPrefix(path.Clean("/"+dir), "/")
+	for _, index := range f.index {
+		info, err := fs.Stat(f.fsys, path.Join(dir, index))
+		if err == nil && !info.IsDir() {
+			return index, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,62 @@
+package wfs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestHTTPFS(t *testing.T) {
+	fsys := wfs.Map(fstest.MapFS{
+		"index.html":     &fstest.MapFile{Data: []byte("root index")},
+		"docs/index.htm": &fstest.MapFile{Data: []byte("docs index")},
+		"docs/page.txt":  &fstest.MapFile{Data: []byte("page")},
+		"assets/app.js":  &fstest.MapFile{Data: []byte("app")},
+	})
+	get := func(t *testing.T, h http.Handler, target string) (int, string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		body, _ := io.ReadAll(rec.Body)
+		return rec.Code, string(body)
+	}
+
+	tests := []struct {
+		name   string
+		opts   []wfs.HTTPOption
+		target string
+		code   int
+		body   string
+	}{
+		{"file", nil, "/docs/page.txt", 200, "page"},
+		{"index", nil, "/", 200, "root index"},
+		{"listing", nil, "/assets/", 200, "app.js"},
+		{"missing", nil, "/missing", 404, ""},
+		{"no listing", []wfs.HTTPOption{wfs.NoDirListing()}, "/assets/", 403, ""},
+		{"no listing with index", []wfs.HTTPOption{wfs.NoDirListing()}, "/", 200, "root index"},
+		{"index files", []wfs.HTTPOption{wfs.IndexFiles("index.htm", "index.html")}, "/docs/", 200, "docs index"},
+		{"index files fallback", []wfs.HTTPOption{wfs.IndexFiles("index.htm", "index.html")}, "/", 200, "root index"},
+		{"index files replace index.html", []wfs.HTTPOption{wfs.IndexFiles("index.htm")}, "/", 200, "docs/"},
+		{"error mapping", []wfs.HTTPOption{wfs.NoDirListing(), wfs.HTTPErrors(func(err error) error {
+			if errors.Is(err, fs.ErrPermission) {
+				return fs.ErrNotExist
+			}
+			return err
+		})}, "/assets/", 404, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := get(t, http.FileServer(wfs.HTTPFS(fsys, tt.opts...)), tt.target)
+			if code != tt.code || !strings.Contains(body, tt.body) {
+				t.Errorf("expected %d with %q, got %d with %q", tt.code, tt.body, code, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package wfs
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ErrJournalTruncated is returned by [JournalFS.Changes] when entries after
+// the requested sequence number are no longer retained.
+var ErrJournalTruncated = errors.New("journal truncated")
+
+// JournalEntry is a mutation recorded by a [JournalFS].
+type JournalEntry struct {
+	// Seq is the sequence number of the entry, sequence numbers start at 1
+	// and increase by one with every entry.
+	Seq uint64
+	Event
+}
+
+// JournalFS is a file system that records its mutations in an ordered journal.
+type JournalFS interface {
+	FS
+
+	// Changes returns the entries recorded after sequence number since in
+	// order, since is 0 to replay the journal from the start. It returns
+	// [ErrJournalTruncated] if some of those entries are no longer retained,
+	// after which the consumer must resynchronize from scratch.
+	Changes(since uint64) ([]JournalEntry, error)
+
+	// Seq returns the sequence number of the last entry, or 0 if nothing
+	// has been recorded.
+	Seq() uint64
+}
+
+// Journal returns a JournalFS that records the same mutations as [Instrument]
+// with a sequence number, so consumers can replay the changes they missed
+// from the last sequence number they processed. If limit is positive only the
+// last limit entries are retained.
+//
+// Entries are recorded from calls made through the returned file system,
+// changes made directly to fsys are not observed.
+func Journal(fsys FS, limit int) JournalFS {
+	f := &journalFs{limit: limit}
+	f.eventFs = &eventFs{fsys: fsys, sink: f.record}
+	return f
+}
+
+type journalFs struct {
+	*eventFs
+	limit int
+
+	mu      sync.Mutex
+	seq     uint64
+	entries []JournalEntry
+}
+
+func (f *journalFs) record(e Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	f.entries = append(f.entries, JournalEntry{f.seq, e})
+	if f.limit > 0 && len(f.entries) > f.limit {
+		f.entries = f.entries[len(f.entries)-f.limit:]
+	}
+}
+
+func (f *journalFs) Seq() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.seq
+}
+
+func (f *journalFs) Changes(since uint64) ([]JournalEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if since >= f.seq {
+		return nil, nil
+	}
+	// sequence numbers of retained entries are contiguous
+	first := f.entries[0].Seq
+	if first > since+1 {
+		return nil, ErrJournalTruncated
+	}
+	return slices.Clone(f.entries[since+1-first:]), nil
+}
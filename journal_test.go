@@ -0,0 +1,81 @@
+package wfs_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestJournal(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			jfs := wfs.Journal(fsys, 0)
+			dir := filepath.Join(base, "dir")
+			file := filepath.Join(dir, "file")
+			if err := jfs.Mkdir(dir, 0755); err != nil {
+				t.Fatalf("Mkdir failed: %v", err)
+			}
+			if err := wfs.WriteFile(jfs, file, []byte("Hello"), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			if err := wfs.Chmod(jfs, file, 0600); err != nil {
+				t.Fatalf("Chmod failed: %v", err)
+			}
+			if err := jfs.RemoveAll(dir); err != nil {
+				t.Fatalf("RemoveAll failed: %v", err)
+			}
+
+			expected := []wfs.JournalEntry{
+				{Seq: 1, Event: wfs.Event{Op: wfs.OpCreate, Path: dir}},
+				{Seq: 2, Event: wfs.Event{Op: wfs.OpCreate, Path: file}},
+				{Seq: 3, Event: wfs.Event{Op: wfs.OpWrite, Path: file, Size: 5}},
+				{Seq: 4, Event: wfs.Event{Op: wfs.OpChmod, Path: file}},
+				{Seq: 5, Event: wfs.Event{Op: wfs.OpRemove, Path: dir}},
+			}
+			if seq := jfs.Seq(); seq != 5 {
+				t.Errorf("expected sequence number 5, got %d", seq)
+			}
+			for _, since := range []uint64{0, 2, 5} {
+				entries, err := jfs.Changes(since)
+				if err != nil {
+					t.Fatalf("Changes(%d) failed: %v", since, err)
+				}
+				want := expected[since:]
+				if len(entries) != len(want) {
+					t.Fatalf("expected %d entries since %d, got %d", len(want), since, len(entries))
+				}
+				for i, e := range entries {
+					e.Time = want[i].Time
+					if e != want[i] {
+						t.Errorf("expected entry %+v, got %+v", want[i], e)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestJournalLimit(t *testing.T) {
+	jfs := wfs.Journal(wfs.Mem(), 2)
+	for _, name := range []string{"a", "b", "c"} {
+		if err := jfs.Mkdir(name, 0755); err != nil {
+			t.Fatalf("Mkdir failed: %v", err)
+		}
+	}
+	if _, err := jfs.Changes(0); !errors.Is(err, wfs.ErrJournalTruncated) {
+		t.Errorf("expected ErrJournalTruncated, got %v", err)
+	}
+	entries, err := jfs.Changes(1)
+	if err != nil || len(entries) != 2 || entries[0].Path != "b" || entries[1].Path != "c" {
+		t.Errorf("expected entries for b and c, got %+v err: %v", entries, err)
+	}
+}
@@ -0,0 +1,212 @@
+package wfs
+
+import (
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"time"
+)
+
+// LatencyFunc returns the latency to inject into an operation of a file system
+// wrapped with [WithLatency]. Operations are named like in [FaultRule].
+// It may be called concurrently.
+type LatencyFunc func(op string) time.Duration
+
+// UniformLatency returns a LatencyFunc that delays every operation by d plus
+// a random jitter uniformly distributed between 0 and jitter.
+func UniformLatency(d, jitter time.Duration) LatencyFunc {
+	return func(string) time.Duration {
+		if jitter <= 0 {
+			return d
+		}
+		return d + rand.N(jitter)
+	}
+}
+
+// NormalLatency returns a LatencyFunc that delays every operation by a random
+// duration normally distributed with the given mean and standard deviation.
+// Negative samples are not delayed.
+func NormalLatency(mean, stddev time.Duration) LatencyFunc {
+	return func(string) time.Duration {
+		return max(0, mean+time.Duration(rand.NormFloat64()*float64(stddev)))
+	}
+}
+
+// OpLatency returns a LatencyFunc that delays each operation with the
+// LatencyFunc of its name in ops, or with the LatencyFunc of the empty name if
+// there is none. Operations without a LatencyFunc or with a nil one are not
+// delayed.
+func OpLatency(ops map[string]LatencyFunc) LatencyFunc {
+	return func(op string) time.Duration {
+		fn, ok := ops[op]
+		if !ok {
+			fn = ops[""]
+		}
+		if fn == nil {
+			return 0
+		}
+		return fn(op)
+	}
+}
+
+// WithLatency returns a file system that delays every operation on fsys and on
+// the files it opens by the duration returned by latency before performing it,
+// to simulate a slow file system such as a network mount. A nil latency does
+// not delay operations.
+func WithLatency(fsys FS, latency LatencyFunc) FS {
+	return &latencyFs{fsys, latency}
+}
+
+type latencyFs struct {
+	fsys    FS
+	latency LatencyFunc
+}
+
+func (f *latencyFs) delay(op string) {
+	if f.latency == nil {
+		return
+	}
+	if d := f.latency(op); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+func (f *latencyFs) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for latencyFs.
+func (f *latencyFs) Stat(name string) (fs.FileInfo, error) {
+	f.delay("stat")
+	return fs.Stat(f.fsys, name)
+}
+
+func (f *latencyFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	f.delay("open")
+	file, err := f.fsys.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &latencyFile{file, f}, nil
+}
+
+func (f *latencyFs) Rename(oldpath, newpath string) error {
+	f.delay("rename")
+	return f.fsys.Rename(oldpath, newpath)
+}
+
+func (f *latencyFs) Remove(name string) error {
+	f.delay("remove")
+	return f.fsys.Remove(name)
+}
+
+func (f *latencyFs) RemoveAll(path string) error {
+	f.delay("RemoveAll")
+	return f.fsys.RemoveAll(path)
+}
+
+func (f *latencyFs) Mkdir(name string, perm fs.FileMode) error {
+	f.delay("mkdir")
+	return f.fsys.Mkdir(name, perm)
+}
+
+func (f *latencyFs) MkdirAll(path string, perm fs.FileMode) error {
+	f.delay("MkdirAll")
+	return f.fsys.MkdirAll(path, perm)
+}
+
+// Symlink implements [SymlinkFS] for latencyFs.
+func (f *latencyFs) Symlink(oldname, newname string) error {
+	f.delay("symlink")
+	return Symlink(f.fsys, oldname, newname)
+}
+
+// Readlink implements [SymlinkFS] for latencyFs.
+func (f *latencyFs) Readlink(name string) (string, error) {
+	f.delay("readlink")
+	return Readlink(f.fsys, name)
+}
+
+// Lstat implements [SymlinkFS] for latencyFs.
+func (f *latencyFs) Lstat(name string) (fs.FileInfo, error) {
+	f.delay("lstat")
+	return Lstat(f.fsys, name)
+}
+
+// Link implements [LinkFS] for latencyFs.
+func (f *latencyFs) Link(oldname, newname string) error {
+	f.delay("link")
+	return Link(f.fsys, oldname, newname)
+}
+
+// Chmod implements [MetaFS] for latencyFs.
+func (f *latencyFs) Chmod(name string, mode fs.FileMode) error {
+	f.delay("chmod")
+	return Chmod(f.fsys, name, mode)
+}
+
+// Chown implements [MetaFS] for latencyFs.
+func (f *latencyFs) Chown(name string, uid, gid int) error {
+	f.delay("chown")
+	return Chown(f.fsys, name, uid, gid)
+}
+
+// Chtimes implements [MetaFS] for latencyFs.
+func (f *latencyFs) Chtimes(name string, atime, mtime time.Time) error {
+	f.delay("chtimes")
+	return Chtimes(f.fsys, name, atime, mtime)
+}
+
+type latencyFile struct {
+	File
+	fs *latencyFs
+}
+
+func (f *latencyFile) Read(b []byte) (int, error) {
+	f.fs.delay("read")
+	return f.File.Read(b)
+}
+
+func (f *latencyFile) ReadAt(b []byte, off int64) (int, error) {
+	f.fs.delay("read")
+	return f.File.ReadAt(b, off)
+}
+
+func (f *latencyFile) Write(b []byte) (int, error) {
+	f.fs.delay("write")
+	return f.File.Write(b)
+}
+
+func (f *latencyFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *latencyFile) WriteAt(b []byte, off int64) (int, error) {
+	f.fs.delay("write")
+	return f.File.WriteAt(b, off)
+}
+
+func (f *latencyFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.delay("seek")
+	return f.File.Seek(offset, whence)
+}
+
+func (f *latencyFile) Truncate(size int64) error {
+	f.fs.delay("truncate")
+	return f.File.Truncate(size)
+}
+
+func (f *latencyFile) Sync() error {
+	f.fs.delay("sync")
+	return f.File.Sync()
+}
+
+func (f *latencyFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.fs.delay("readdir")
+	return readDir(f.File, n)
+}
+
+func (f *latencyFile) Close() error {
+	f.fs.delay("close")
+	return f.File.Close()
+}
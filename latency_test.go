@@ -0,0 +1,72 @@
+package wfs_test
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestWithLatency(t *testing.T) {
+	var mu sync.Mutex
+	var ops []string
+	record := func(op string) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		ops = append(ops, op)
+		return 0
+	}
+	fsys := wfs.WithLatency(wfs.Mem(), record)
+	if err := wfs.WriteFile(fsys, "file", []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ops, []string{"open", "write", "close"}) {
+		t.Errorf("expected open, write and close to be delayed, got %v", ops)
+	}
+
+	fsys = wfs.WithLatency(wfs.Mem(), wfs.OpLatency(map[string]wfs.LatencyFunc{
+		"mkdir": wfs.UniformLatency(20*time.Millisecond, 10*time.Millisecond),
+	}))
+	start := time.Now()
+	if err := fsys.Mkdir("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("expected mkdir to take at least 20ms, took %v", d)
+	}
+	start = time.Now()
+	if err := fsys.Remove("dir"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d >= 20*time.Millisecond {
+		t.Errorf("expected remove not to be delayed, took %v", d)
+	}
+}
+
+func TestLatencyDistributions(t *testing.T) {
+	uniform := wfs.UniformLatency(10*time.Millisecond, 5*time.Millisecond)
+	normal := wfs.NormalLatency(10*time.Millisecond, 100*time.Millisecond)
+	for range 100 {
+		if d := uniform("read"); d < 10*time.Millisecond || d >= 15*time.Millisecond {
+			t.Fatalf("uniform latency %v out of range", d)
+		}
+		if d := normal("read"); d < 0 {
+			t.Fatalf("normal latency %v is negative", d)
+		}
+	}
+}
+
+func TestNilLatency(t *testing.T) {
+	fsys := wfs.WithLatency(wfs.Mem(), nil)
+	if err := wfs.WriteFile(fsys, "file", []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, fsys, "file", "data")
+
+	latency := wfs.OpLatency(map[string]wfs.LatencyFunc{"read": nil})
+	if d := latency("read"); d != 0 {
+		t.Errorf("expected no latency for a nil LatencyFunc, got %v", d)
+	}
+}
@@ -0,0 +1,163 @@
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const leaseSuffix = ".lease"
+
+// ExpiryFS is the interface implemented by a file system that expires files
+// natively, such as an object store with lifecycle rules. Expired files are
+// removed by the file system itself instead of [ReapLeases].
+type ExpiryFS interface {
+	FS
+
+	// SetExpiry sets the time at which the named file expires. If files
+	// cannot expire natively, it returns an error that wraps
+	// [errors.ErrUnsupported].
+	SetExpiry(name string, t time.Time) error
+
+	// Expiry returns the time at which the named file expires, or an error
+	// wrapping [fs.ErrNotExist] if it does not expire.
+	Expiry(name string) (time.Time, error)
+}
+
+// leaseName returns the name of the file recording the expiry of name.
+func leaseName(name string) string {
+	dir, base := path.Split(name)
+	return dir + "." + base + leaseSuffix
+}
+
+// CreateLease creates or truncates the named file like [Create] and sets it
+// to expire after ttl. Expired files are removed by [ReapLeases].
+//
+// If fsys implements [ExpiryFS] the expiry is set natively, otherwise it is
+// recorded in a hidden file next to the named file, "dir/.name.lease".
+func CreateLease(fsys FS, name string, ttl time.Duration) (File, error) {
+	f, err := Create(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := setExpiry(fsys, name, time.Now().Add(ttl)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// RenewLease sets the named file to expire after ttl from now.
+// The file must exist.
+func RenewLease(fsys FS, name string, ttl time.Duration) error {
+	if _, err := Lstat(fsys, name); err != nil {
+		return err
+	}
+	return setExpiry(fsys, name, time.Now().Add(ttl))
+}
+
+// LeaseExpiry returns the time at which the named file expires, or an error
+// wrapping [fs.ErrNotExist] if it is not a lease.
+func LeaseExpiry(fsys FS, name string) (time.Time, error) {
+	if efs, ok := fsys.(ExpiryFS); ok {
+		t, err := efs.Expiry(name)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return t, err
+		}
+	}
+	b, err := fs.ReadFile(fsys, leaseName(name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return time.Time{}, &fs.PathError{Op: "lease", Path: name, Err: fs.ErrNotExist}
+		}
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(b)))
+	if err != nil {
+		return time.Time{}, &fs.PathError{Op: "lease", Path: name, Err: err}
+	}
+	return t, nil
+}
+
+func setExpiry(fsys FS, name string, t time.Time) error {
+	if efs, ok := fsys.(ExpiryFS); ok {
+		err := efs.SetExpiry(name, t)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+	}
+	return AtomicWriteFile(fsys, leaseName(name), []byte(t.UTC().Format(time.RFC3339Nano)+"\n"), 0644)
+}
+
+// ReapLeases removes the expired leases in the tree rooted at root and
+// returns their names in lexical order. Lease records whose file no longer
+// exists are removed as well. Removal errors are joined and do not stop
+// the reaper.
+func ReapLeases(fsys FS, root string) ([]string, error) {
+	now := time.Now()
+	var reaped []string
+	var errs []error
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		base := d.Name()
+		if d.IsDir() || !strings.HasPrefix(base, ".") || !strings.HasSuffix(base, leaseSuffix) || len(base) <= len(leaseSuffix)+1 {
+			return nil
+		}
+		target := path.Join(path.Dir(name), strings.TrimSuffix(base[1:], leaseSuffix))
+		if _, err := Lstat(fsys, target); err == nil {
+			expiry, err := LeaseExpiry(fsys, target)
+			if err != nil || expiry.After(now) {
+				// the lease is unexpired or unreadable, keep it
+				return nil
+			}
+			if err := fsys.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+				return nil
+			}
+			reaped = append(reaped, target)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+			return nil
+		}
+		if err := fsys.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	slices.Sort(reaped)
+	return reaped, errors.Join(append(errs, err)...)
+}
+
+// StartReaper calls [ReapLeases] every interval in the background until the
+// returned stop function is called. Errors are ignored, files that could not
+// be removed are retried on the next run. Stop waits for a running reap to
+// complete.
+func StartReaper(fsys FS, root string, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ReapLeases(fsys, root)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		wg.Wait()
+	}
+}
@@ -0,0 +1,101 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestLeases(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"cache/keep": &fstest.MapFile{Data: []byte("not a lease")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			root := filepath.Join(base, "cache")
+			expired := filepath.Join(root, "expired")
+			renewed := filepath.Join(root, "renewed")
+			live := filepath.Join(root, "sub/live")
+
+			for _, name := range []string{expired, renewed} {
+				f, err := wfs.CreateLease(fsys, name, -time.Second)
+				if err != nil {
+					t.Fatalf("failed to create lease: %v", err)
+				}
+				f.Write([]byte("Hello"))
+				f.Close()
+			}
+			if err := fsys.MkdirAll(filepath.Join(root, "sub"), 0755); err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			f, err := wfs.CreateLease(fsys, live, time.Hour)
+			if err != nil {
+				t.Fatalf("failed to create lease: %v", err)
+			}
+			f.Close()
+			if err := wfs.RenewLease(fsys, renewed, time.Hour); err != nil {
+				t.Fatalf("failed to renew lease: %v", err)
+			}
+			if err := wfs.RenewLease(fsys, filepath.Join(root, "missing"), time.Hour); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected renewing a missing file to fail with ErrNotExist, got %v", err)
+			}
+
+			expiry, err := wfs.LeaseExpiry(fsys, live)
+			if err != nil || time.Until(expiry) < 59*time.Minute {
+				t.Errorf("expected live lease to expire in an hour, got %v err: %v", expiry, err)
+			}
+			if _, err := wfs.LeaseExpiry(fsys, filepath.Join(root, "keep")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected ErrNotExist for a file without lease, got %v", err)
+			}
+
+			reaped, err := wfs.ReapLeases(fsys, root)
+			if err != nil {
+				t.Fatalf("failed to reap leases: %v", err)
+			}
+			if !slices.Equal(reaped, []string{expired}) {
+				t.Errorf("expected %v to be reaped, got %v", []string{expired}, reaped)
+			}
+			assertEntries(t, fsys, root, ".renewed.lease", "keep", "renewed", "sub")
+			assertContent(t, fsys, renewed, "Hello")
+
+			// lease records of removed files are cleaned up
+			if err := fsys.Remove(live); err != nil {
+				t.Fatalf("failed to remove file: %v", err)
+			}
+			if _, err := wfs.ReapLeases(fsys, root); err != nil {
+				t.Fatalf("failed to reap leases: %v", err)
+			}
+			assertEntries(t, fsys, filepath.Join(root, "sub"))
+		})
+	}
+}
+
+func TestStartReaper(t *testing.T) {
+	fsys := wfs.Mem()
+	f, err := wfs.CreateLease(fsys, "tmp", 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create lease: %v", err)
+	}
+	f.Close()
+	stop := wfs.StartReaper(fsys, ".", 5*time.Millisecond)
+	defer stop()
+	for range 100 {
+		if exists, _ := wfs.Exists(fsys, "tmp"); !exists {
+			stop()
+			assertEntries(t, fsys, ".")
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("expected reaper to remove expired file")
+}
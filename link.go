@@ -0,0 +1,26 @@
+package wfs
+
+import (
+	"errors"
+	"os"
+)
+
+// LinkFS is the interface implemented by a file system
+// that supports hard links.
+type LinkFS interface {
+	FS
+
+	// Link creates newname as a hard link to the oldname file.
+	// If there is an error, it will be of type [*os.LinkError].
+	Link(oldname, newname string) error
+}
+
+// Link creates newname as a hard link to the oldname file in fsys.
+// If fsys does not implement [LinkFS], Link returns an error
+// that wraps [errors.ErrUnsupported].
+func Link(fsys FS, oldname, newname string) error {
+	if fsys, ok := fsys.(LinkFS); ok {
+		return fsys.Link(oldname, newname)
+	}
+	return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: errors.ErrUnsupported}
+}
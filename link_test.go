@@ -0,0 +1,53 @@
+package wfs_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestLink(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"file": &fstest.MapFile{Data: []byte("Hello")},
+				"dir":  &fstest.MapFile{Mode: fs.ModeDir | 0755},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			file := filepath.Join(base, "file")
+			link := filepath.Join(base, "link")
+			if err := wfs.Link(fsys, file, link); err != nil {
+				t.Fatalf("Link failed: %v", err)
+			}
+			if err := wfs.Link(fsys, file, link); err == nil {
+				t.Errorf("expected Link to fail for existing name")
+			}
+			if err := wfs.Link(fsys, filepath.Join(base, "dir"), filepath.Join(base, "dirlink")); err == nil {
+				t.Errorf("expected Link to fail for directory")
+			}
+
+			f, err := fsys.OpenFile(link, os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatalf("OpenFile failed: %v", err)
+			}
+			f.WriteAt([]byte("J"), 0)
+			f.Close()
+
+			if err := fsys.Remove(file); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+			b, err := fs.ReadFile(fsys, link)
+			if err != nil || string(b) != "Jello" {
+				t.Errorf("expected link to contain 'Jello', got %q err: %v", b, err)
+			}
+		})
+	}
+}
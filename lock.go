@@ -0,0 +1,191 @@
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// LockFS is the interface implemented by a file system that supports
+// advisory whole-file locks. Locks only exclude other locks, they do not
+// prevent reading or writing the file.
+//
+// The OS backends lock files with flock on Unix and LockFileEx on Windows, so
+// locks coordinate processes as well as goroutines. The in-memory backends
+// lock files within the process.
+type LockFS interface {
+	FS
+
+	// Lock acquires an exclusive lock on the named file, waiting until no
+	// other lock is held on it. The file is created if it does not exist.
+	// If there is an error, it will be of type [*fs.PathError].
+	Lock(name string) (Unlocker, error)
+
+	// RLock acquires a shared lock on the named file, waiting until no
+	// exclusive lock is held on it. The file is created if it does not exist.
+	// If there is an error, it will be of type [*fs.PathError].
+	RLock(name string) (Unlocker, error)
+
+	// TryLock is like Lock but fails with an error wrapping [ErrLocked]
+	// instead of waiting if another lock is held on the file.
+	TryLock(name string) (Unlocker, error)
+}
+
+// Unlocker releases a lock acquired from a [LockFS].
+type Unlocker interface {
+	// Unlock releases the lock. Unlocking a released lock returns an error
+	// wrapping [fs.ErrClosed].
+	Unlock() error
+}
+
+// Lock acquires an exclusive lock on the named file in fsys, see [LockFS].
+// If fsys does not implement LockFS, Lock returns an error
+// that wraps [errors.ErrUnsupported].
+func Lock(fsys FS, name string) (Unlocker, error) {
+	if fsys, ok := fsys.(LockFS); ok {
+		return fsys.Lock(name)
+	}
+	return nil, &fs.PathError{Op: "lock", Path: name, Err: errors.ErrUnsupported}
+}
+
+// RLock acquires a shared lock on the named file in fsys, see [LockFS].
+// If fsys does not implement LockFS, RLock returns an error
+// that wraps [errors.ErrUnsupported].
+func RLock(fsys FS, name string) (Unlocker, error) {
+	if fsys, ok := fsys.(LockFS); ok {
+		return fsys.RLock(name)
+	}
+	return nil, &fs.PathError{Op: "rlock", Path: name, Err: errors.ErrUnsupported}
+}
+
+// TryLock tries to acquire an exclusive lock on the named file in fsys
+// without waiting, see [LockFS]. If fsys does not implement LockFS, TryLock
+// returns an error that wraps [errors.ErrUnsupported].
+func TryLock(fsys FS, name string) (Unlocker, error) {
+	if fsys, ok := fsys.(LockFS); ok {
+		return fsys.TryLock(name)
+	}
+	return nil, &fs.PathError{Op: "lock", Path: name, Err: errors.ErrUnsupported}
+}
+
+// WithLock calls fn while holding an exclusive lock on the named file in fsys
+// and returns the error of fn joined with the error releasing the lock.
+func WithLock(fsys FS, name string, fn func() error) error {
+	l, err := Lock(fsys, name)
+	if err != nil {
+		return err
+	}
+	err = fn()
+	return errors.Join(err, l.Unlock())
+}
+
+// lockTable holds the locks of an in-memory file system, keyed by the
+// identity of the locked file. The zero value is ready to use.
+type lockTable struct {
+	mu    sync.Mutex
+	cond  sync.Cond
+	locks map[any]*lockState
+}
+
+type lockState struct {
+	readers int
+	writer  bool
+}
+
+func (t *lockTable) lock(op, name string, key any, exclusive, block bool) (Unlocker, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.locks == nil {
+		t.locks = make(map[any]*lockState)
+		t.cond.L = &t.mu
+	}
+	for {
+		s := t.locks[key]
+		if s == nil {
+			s = &lockState{}
+			t.locks[key] = s
+		}
+		if !s.writer && (!exclusive || s.readers == 0) {
+			if exclusive {
+				s.writer = true
+			} else {
+				s.readers++
+			}
+			return &tableLock{table: t, key: key, name: name, exclusive: exclusive}, nil
+		}
+		if !block {
+			return nil, &fs.PathError{Op: op, Path: name, Err: ErrLocked}
+		}
+		t.cond.Wait()
+	}
+}
+
+// tableLock is a lock held in a lockTable.
+type tableLock struct {
+	table     *lockTable
+	key       any
+	name      string
+	exclusive bool
+	released  bool
+}
+
+func (l *tableLock) Unlock() error {
+	t := l.table
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if l.released {
+		return &fs.PathError{Op: "unlock", Path: l.name, Err: fs.ErrClosed}
+	}
+	l.released = true
+	s := t.locks[l.key]
+	if l.exclusive {
+		s.writer = false
+	} else {
+		s.readers--
+	}
+	if !s.writer && s.readers == 0 {
+		delete(t.locks, l.key)
+	}
+	t.cond.Broadcast()
+	return nil
+}
+
+// lockOSFile opens a file with open, creating it if possible, and locks it.
+// Errors returned by open are returned unchanged.
+func lockOSFile(op, name string, open func(flag int) (*os.File, error), exclusive, block bool) (Unlocker, error) {
+	f, err := open(os.O_RDWR | os.O_CREATE)
+	if errors.Is(err, fs.ErrPermission) || errors.Is(err, ErrIsDir) {
+		f, err = open(os.O_RDONLY)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f, exclusive, block); err != nil {
+		f.Close()
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return &osLock{f: f, name: name}, nil
+}
+
+// osLock is a lock held on an open OS file, closing the file releases it.
+type osLock struct {
+	f        *os.File
+	name     string
+	released bool
+}
+
+func (l *osLock) Unlock() error {
+	if l.released {
+		return &fs.PathError{Op: "unlock", Path: l.name, Err: fs.ErrClosed}
+	}
+	l.released = true
+	if err := funlock(l.f); err != nil {
+		l.f.Close()
+		return &fs.PathError{Op: "unlock", Path: l.name, Err: err}
+	}
+	if err := l.f.Close(); err != nil {
+		return pathErr(err, l.name)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package wfs
+
+import (
+	"errors"
+	"os"
+)
+
+func flock(f *os.File, exclusive, block bool) error {
+	return errors.ErrUnsupported
+}
+
+func funlock(f *os.File) error {
+	return errors.ErrUnsupported
+}
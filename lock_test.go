@@ -0,0 +1,87 @@
+package wfs_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestLock(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dir/file": &fstest.MapFile{Data: []byte("Hello"), Mode: 0644},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			name := filepath.Join(base, "dir/file")
+			l, err := wfs.Lock(fsys, name)
+			if err != nil {
+				t.Fatalf("Lock failed: %v", err)
+			}
+			if _, err := wfs.TryLock(fsys, name); !errors.Is(err, wfs.ErrLocked) {
+				t.Errorf("expected ErrLocked, got %v", err)
+			}
+
+			// shared locks wait for the exclusive lock to be released
+			locked := make(chan wfs.Unlocker)
+			go func() {
+				r, err := wfs.RLock(fsys, name)
+				if err != nil {
+					t.Errorf("RLock failed: %v", err)
+				}
+				locked <- r
+			}()
+			select {
+			case <-locked:
+				t.Fatal("expected RLock to wait")
+			case <-time.After(50 * time.Millisecond):
+			}
+			if err := l.Unlock(); err != nil {
+				t.Fatalf("Unlock failed: %v", err)
+			}
+			r1 := <-locked
+			if r1 == nil {
+				t.FailNow()
+			}
+			r2, err := wfs.RLock(fsys, name)
+			if err != nil {
+				t.Fatalf("expected shared locks to be compatible: %v", err)
+			}
+			if _, err := wfs.TryLock(fsys, name); !errors.Is(err, wfs.ErrLocked) {
+				t.Errorf("expected ErrLocked, got %v", err)
+			}
+			r1.Unlock()
+			r2.Unlock()
+			if err := r2.Unlock(); err == nil {
+				t.Error("expected unlocking twice to fail")
+			}
+
+			// WithLock creates missing files
+			calls := 0
+			err = wfs.WithLock(fsys, filepath.Join(base, "lockfile"), func() error {
+				calls++
+				_, err := wfs.TryLock(fsys, filepath.Join(base, "lockfile"))
+				if !errors.Is(err, wfs.ErrLocked) {
+					t.Errorf("expected ErrLocked, got %v", err)
+				}
+				return nil
+			})
+			if err != nil || calls != 1 {
+				t.Errorf("WithLock failed: %v", err)
+			}
+			l, err = wfs.TryLock(fsys, filepath.Join(base, "lockfile"))
+			if err != nil {
+				t.Fatalf("expected lock to be released: %v", err)
+			}
+			l.Unlock()
+		})
+	}
+}
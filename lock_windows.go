@@ -0,0 +1,52 @@
+//go:build windows
+
+package wfs
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32      = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = modkernel32.NewProc("LockFileEx")
+	procUnlockFileEx = modkernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+
+	errorLockViolation syscall.Errno = 33
+)
+
+// flock locks the whole of f with LockFileEx, returning an error wrapping
+// ErrLocked if the lock is held and block is false.
+func flock(f *os.File, exclusive, block bool) error {
+	var flags uintptr
+	if exclusive {
+		flags |= lockfileExclusiveLock
+	}
+	if !block {
+		flags |= lockfileFailImmediately
+	}
+	ol := new(syscall.Overlapped)
+	r, _, err := procLockFileEx.Call(f.Fd(), flags, 0, uintptr(^uint32(0)), uintptr(^uint32(0)), uintptr(unsafe.Pointer(ol)))
+	if r != 0 {
+		return nil
+	}
+	if err == errorLockViolation {
+		return &portableError{err, ErrLocked}
+	}
+	return err
+}
+
+func funlock(f *os.File) error {
+	ol := new(syscall.Overlapped)
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, uintptr(^uint32(0)), uintptr(^uint32(0)), uintptr(unsafe.Pointer(ol)))
+	if r != 0 {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,270 @@
+package wfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// WithLogging returns a file system that logs every operation on fsys and on
+// the files it opens to logger, with the path, flags, byte counts, duration and
+// error of the operation.
+//
+// Mutations of the file system are logged at [slog.LevelInfo], reads and
+// individual file reads and writes at [slog.LevelDebug], and operations that
+// fail with an error other than [fs.ErrNotExist] at [slog.LevelWarn].
+// Closing a file that was written logs the total bytes written at
+// slog.LevelInfo.
+func WithLogging(fsys FS, logger *slog.Logger) FS {
+	return &logFs{fsys, logger}
+}
+
+type logFs struct {
+	fsys   FS
+	logger *slog.Logger
+}
+
+// log logs op at level, or at warning level if it failed.
+func (f *logFs) log(level slog.Level, op string, start time.Time, err error, attrs ...slog.Attr) {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		level = slog.LevelWarn
+	}
+	ctx := context.Background()
+	if !f.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	f.logger.LogAttrs(ctx, level, op, attrs...)
+}
+
+// flagString formats the flags of OpenFile.
+func flagString(flag int) string {
+	var flags []string
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		flags = append(flags, "O_RDONLY")
+	case os.O_WRONLY:
+		flags = append(flags, "O_WRONLY")
+	case os.O_RDWR:
+		flags = append(flags, "O_RDWR")
+	}
+	for _, f := range []struct {
+		flag int
+		name string
+	}{
+		{os.O_APPEND, "O_APPEND"},
+		{os.O_CREATE, "O_CREATE"},
+		{os.O_EXCL, "O_EXCL"},
+		{os.O_SYNC, "O_SYNC"},
+		{os.O_TRUNC, "O_TRUNC"},
+	} {
+		if flag&f.flag != 0 {
+			flags = append(flags, f.name)
+		}
+	}
+	return strings.Join(flags, "|")
+}
+
+func (f *logFs) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for logFs.
+func (f *logFs) Stat(name string) (fs.FileInfo, error) {
+	start := time.Now()
+	info, err := fs.Stat(f.fsys, name)
+	f.log(slog.LevelDebug, "stat", start, err, slog.String("path", name))
+	return info, err
+}
+
+func (f *logFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	start := time.Now()
+	file, err := f.fsys.OpenFile(name, flag, perm)
+	level := slog.LevelDebug
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		level = slog.LevelInfo
+	}
+	f.log(level, "open", start, err, slog.String("path", name), slog.String("flags", flagString(flag)), slog.Any("perm", perm))
+	if err != nil {
+		return nil, err
+	}
+	return &logFile{File: file, fs: f, name: name}, nil
+}
+
+func (f *logFs) Rename(oldpath, newpath string) error {
+	start := time.Now()
+	err := f.fsys.Rename(oldpath, newpath)
+	f.log(slog.LevelInfo, "rename", start, err, slog.String("path", newpath), slog.String("old_path", oldpath))
+	return err
+}
+
+func (f *logFs) Remove(name string) error {
+	start := time.Now()
+	err := f.fsys.Remove(name)
+	f.log(slog.LevelInfo, "remove", start, err, slog.String("path", name))
+	return err
+}
+
+func (f *logFs) RemoveAll(path string) error {
+	start := time.Now()
+	err := f.fsys.RemoveAll(path)
+	f.log(slog.LevelInfo, "RemoveAll", start, err, slog.String("path", path))
+	return err
+}
+
+func (f *logFs) Mkdir(name string, perm fs.FileMode) error {
+	start := time.Now()
+	err := f.fsys.Mkdir(name, perm)
+	f.log(slog.LevelInfo, "mkdir", start, err, slog.String("path", name), slog.Any("perm", perm))
+	return err
+}
+
+func (f *logFs) MkdirAll(path string, perm fs.FileMode) error {
+	start := time.Now()
+	err := f.fsys.MkdirAll(path, perm)
+	f.log(slog.LevelInfo, "MkdirAll", start, err, slog.String("path", path), slog.Any("perm", perm))
+	return err
+}
+
+// Symlink implements [SymlinkFS] for logFs.
+func (f *logFs) Symlink(oldname, newname string) error {
+	start := time.Now()
+	err := Symlink(f.fsys, oldname, newname)
+	f.log(slog.LevelInfo, "symlink", start, err, slog.String("path", newname), slog.String("target", oldname))
+	return err
+}
+
+// Readlink implements [SymlinkFS] for logFs.
+func (f *logFs) Readlink(name string) (string, error) {
+	start := time.Now()
+	target, err := Readlink(f.fsys, name)
+	f.log(slog.LevelDebug, "readlink", start, err, slog.String("path", name))
+	return target, err
+}
+
+// Lstat implements [SymlinkFS] for logFs.
+func (f *logFs) Lstat(name string) (fs.FileInfo, error) {
+	start := time.Now()
+	info, err := Lstat(f.fsys, name)
+	f.log(slog.LevelDebug, "lstat", start, err, slog.String("path", name))
+	return info, err
+}
+
+// Link implements [LinkFS] for logFs.
+func (f *logFs) Link(oldname, newname string) error {
+	start := time.Now()
+	err := Link(f.fsys, oldname, newname)
+	f.log(slog.LevelInfo, "link", start, err, slog.String("path", newname), slog.String("old_path", oldname))
+	return err
+}
+
+// Chmod implements [MetaFS] for logFs.
+func (f *logFs) Chmod(name string, mode fs.FileMode) error {
+	start := time.Now()
+	err := Chmod(f.fsys, name, mode)
+	f.log(slog.LevelInfo, "chmod", start, err, slog.String("path", name), slog.Any("mode", mode))
+	return err
+}
+
+// Chown implements [MetaFS] for logFs.
+func (f *logFs) Chown(name string, uid, gid int) error {
+	start := time.Now()
+	err := Chown(f.fsys, name, uid, gid)
+	f.log(slog.LevelInfo, "chown", start, err, slog.String("path", name), slog.Int("uid", uid), slog.Int("gid", gid))
+	return err
+}
+
+// Chtimes implements [MetaFS] for logFs.
+func (f *logFs) Chtimes(name string, atime, mtime time.Time) error {
+	start := time.Now()
+	err := Chtimes(f.fsys, name, atime, mtime)
+	f.log(slog.LevelInfo, "chtimes", start, err, slog.String("path", name), slog.Time("mtime", mtime))
+	return err
+}
+
+type logFile struct {
+	File
+	fs      *logFs
+	name    string
+	written int64
+}
+
+func (f *logFile) Read(b []byte) (int, error) {
+	start := time.Now()
+	n, err := f.File.Read(b)
+	f.fs.log(slog.LevelDebug, "read", start, ignoreEOF(err), slog.String("path", f.name), slog.Int("bytes", n))
+	return n, err
+}
+
+func (f *logFile) ReadAt(b []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := f.File.ReadAt(b, off)
+	f.fs.log(slog.LevelDebug, "read", start, ignoreEOF(err), slog.String("path", f.name), slog.Int("bytes", n), slog.Int64("offset", off))
+	return n, err
+}
+
+func (f *logFile) Write(b []byte) (int, error) {
+	start := time.Now()
+	n, err := f.File.Write(b)
+	f.written += int64(n)
+	f.fs.log(slog.LevelDebug, "write", start, err, slog.String("path", f.name), slog.Int("bytes", n))
+	return n, err
+}
+
+func (f *logFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *logFile) WriteAt(b []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := f.File.WriteAt(b, off)
+	f.written += int64(n)
+	f.fs.log(slog.LevelDebug, "write", start, err, slog.String("path", f.name), slog.Int("bytes", n), slog.Int64("offset", off))
+	return n, err
+}
+
+func (f *logFile) Truncate(size int64) error {
+	start := time.Now()
+	err := f.File.Truncate(size)
+	f.fs.log(slog.LevelInfo, "truncate", start, err, slog.String("path", f.name), slog.Int64("size", size))
+	return err
+}
+
+func (f *logFile) Sync() error {
+	start := time.Now()
+	err := f.File.Sync()
+	f.fs.log(slog.LevelDebug, "sync", start, err, slog.String("path", f.name))
+	return err
+}
+
+func (f *logFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return readDir(f.File, n)
+}
+
+func (f *logFile) Close() error {
+	start := time.Now()
+	err := f.File.Close()
+	level := slog.LevelDebug
+	if f.written > 0 {
+		level = slog.LevelInfo
+	}
+	f.fs.log(level, "close", start, err, slog.String("path", f.name), slog.Int64("bytes_written", f.written))
+	return err
+}
+
+// ignoreEOF returns nil if err is io.EOF so that reads to the end of a file
+// are not logged as failures.
+func ignoreEOF(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package wfs_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestWithLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	fsys := wfs.WithLogging(wfs.Mem(), logger)
+
+	if err := wfs.WriteFile(fsys, "file", []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, fsys, "file", "hello")
+	if err := fsys.Rename("file", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Remove("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if err := fsys.Mkdir("renamed", 0755); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for _, expected := range []string{
+		`level=INFO msg=open path=file flags=O_WRONLY|O_CREATE|O_TRUNC perm=-rw-r--r--`,
+		`level=DEBUG msg=write path=file bytes=5`,
+		`level=INFO msg=close path=file bytes_written=5`,
+		`level=DEBUG msg=read path=file bytes=5`,
+		`level=INFO msg=rename path=renamed old_path=file`,
+		`level=INFO msg=remove path=missing`,
+		`level=WARN msg=mkdir path=renamed`,
+	} {
+		found := false
+		for _, line := range lines {
+			if strings.Contains(line, expected) && strings.Contains(line, "duration=") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected log line containing %q, got:\n%s", expected, buf.String())
+		}
+	}
+
+	// disabled levels are not logged
+	buf.Reset()
+	logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	fsys = wfs.WithLogging(wfs.Mem(), logger)
+	if err := wfs.WriteFile(fsys, "file", []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no logs, got:\n%s", buf.String())
+	}
+}
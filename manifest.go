@@ -0,0 +1,206 @@
+package wfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+)
+
+// ManifestFormat is the encoding of a written manifest.
+type ManifestFormat int
+
+const (
+	// ManifestSHA256SUMS encodes a manifest in the format of the sha256sum
+	// tool, one "<digest>  <path>" line per file.
+	ManifestSHA256SUMS ManifestFormat = iota
+
+	// ManifestJSON encodes a manifest as a JSON array of entries.
+	ManifestJSON
+)
+
+// Manifest lists the files of a tree with their sizes and digests,
+// sorted by path.
+type Manifest []ManifestEntry
+
+// ManifestEntry describes a single file in a [Manifest].
+type ManifestEntry struct {
+	// Path is the slash-separated path of the file relative to the manifest root.
+	Path string `json:"path"`
+
+	// Size is the size of the file in bytes, or -1 if unknown.
+	Size int64 `json:"size"`
+
+	// SHA256 is the hex-encoded SHA-256 digest of the file contents.
+	SHA256 string `json:"sha256"`
+}
+
+// BuildManifest returns the manifest of all regular files in the tree rooted at root.
+func BuildManifest(fsys fs.FS, root string) (Manifest, error) {
+	var m Manifest
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sum, err := HashFile(fsys, name, sha256.New)
+		if err != nil {
+			return err
+		}
+		m = append(m, ManifestEntry{
+			Path:   relPath(root, name),
+			Size:   info.Size(),
+			SHA256: hex.EncodeToString(sum),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(m, func(a, b ManifestEntry) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return m, nil
+}
+
+// WriteManifest writes the manifest of the tree rooted at root to w
+// encoded in format.
+func WriteManifest(fsys fs.FS, root string, w io.Writer, format ManifestFormat) error {
+	m, err := BuildManifest(fsys, root)
+	if err != nil {
+		return err
+	}
+	return m.Encode(w, format)
+}
+
+// Encode writes the manifest to w encoded in format.
+func (m Manifest) Encode(w io.Writer, format ManifestFormat) error {
+	switch format {
+	case ManifestSHA256SUMS:
+		for _, e := range m {
+			if _, err := fmt.Fprintf(w, "%s  %s\n", e.SHA256, e.Path); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ManifestJSON:
+		if m == nil {
+			m = Manifest{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	}
+	return fmt.Errorf("wfs: unknown manifest format %d", format)
+}
+
+// ReadManifest reads a manifest encoded in format from r.
+// Entries read from the [ManifestSHA256SUMS] format have an unknown size.
+func ReadManifest(r io.Reader, format ManifestFormat) (Manifest, error) {
+	var m Manifest
+	switch format {
+	case ManifestSHA256SUMS:
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		for i, line := range strings.Split(string(b), "\n") {
+			if line == "" {
+				continue
+			}
+			sum, name, ok := strings.Cut(line, " ")
+			if !ok || len(name) < 2 || (name[0] != ' ' && name[0] != '*') {
+				return nil, fmt.Errorf("wfs: invalid manifest line %d", i+1)
+			}
+			m = append(m, ManifestEntry{Path: name[1:], Size: -1, SHA256: sum})
+		}
+	case ManifestJSON:
+		if err := json.NewDecoder(r).Decode(&m); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("wfs: unknown manifest format %d", format)
+	}
+	slices.SortFunc(m, func(a, b ManifestEntry) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return m, nil
+}
+
+// ManifestResult reports the differences between a tree and its manifest.
+// All paths are relative to the manifest root.
+type ManifestResult struct {
+	// Missing lists files in the manifest that do not exist in the tree.
+	Missing []string
+
+	// Extra lists files in the tree that are not in the manifest.
+	Extra []string
+
+	// Corrupt lists files whose size or digest does not match the manifest.
+	Corrupt []string
+}
+
+// OK reports whether the tree matches the manifest.
+func (r ManifestResult) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Corrupt) == 0
+}
+
+// VerifyManifest checks the tree rooted at root against m, reporting
+// missing, extra and corrupt files. File contents are only hashed when
+// their size matches the manifest.
+func VerifyManifest(fsys fs.FS, root string, m Manifest) (ManifestResult, error) {
+	var result ManifestResult
+	entries := make(map[string]ManifestEntry, len(m))
+	for _, e := range m {
+		entries[e.Path] = e
+	}
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		// a missing root reports every entry as missing
+		if name == root && errors.Is(err, fs.ErrNotExist) {
+			return fs.SkipAll
+		}
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel := relPath(root, name)
+		e, ok := entries[rel]
+		if !ok {
+			result.Extra = append(result.Extra, rel)
+			return nil
+		}
+		delete(entries, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if e.Size >= 0 && e.Size != info.Size() {
+			result.Corrupt = append(result.Corrupt, rel)
+			return nil
+		}
+		sum, err := HashFile(fsys, name, sha256.New)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(hex.EncodeToString(sum), e.SHA256) {
+			result.Corrupt = append(result.Corrupt, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	for name := range entries {
+		result.Missing = append(result.Missing, name)
+	}
+	slices.Sort(result.Missing)
+	return result, nil
+}
@@ -0,0 +1,109 @@
+package wfs_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestWriteManifest(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dist/b.txt":     &fstest.MapFile{Data: []byte("b")},
+				"dist/dir/a.txt": &fstest.MapFile{Data: []byte("aa")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			root := filepath.Join(base, "dist")
+
+			var buf bytes.Buffer
+			if err := wfs.WriteManifest(fsys, root, &buf, wfs.ManifestSHA256SUMS); err != nil {
+				t.Fatalf("WriteManifest failed: %v", err)
+			}
+			expected := sha256Hex("b") + "  b.txt\n" + sha256Hex("aa") + "  dir/a.txt\n"
+			if buf.String() != expected {
+				t.Errorf("expected %q, got %q", expected, buf.String())
+			}
+
+			buf.Reset()
+			if err := wfs.WriteManifest(fsys, root, &buf, wfs.ManifestJSON); err != nil {
+				t.Fatalf("WriteManifest failed: %v", err)
+			}
+			var m wfs.Manifest
+			if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+				t.Fatalf("failed to decode manifest: %v", err)
+			}
+			want := wfs.Manifest{
+				{Path: "b.txt", Size: 1, SHA256: sha256Hex("b")},
+				{Path: "dir/a.txt", Size: 2, SHA256: sha256Hex("aa")},
+			}
+			if len(m) != len(want) {
+				t.Fatalf("expected %d entries, got %d", len(want), len(m))
+			}
+			for i := range want {
+				if m[i] != want[i] {
+					t.Errorf("expected entry %+v, got %+v", want[i], m[i])
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dist/ok.txt":      &fstest.MapFile{Data: []byte("ok")},
+				"dist/size.txt":    &fstest.MapFile{Data: []byte("resized")},
+				"dist/content.txt": &fstest.MapFile{Data: []byte("changed")},
+				"dist/extra.txt":   &fstest.MapFile{Data: []byte("extra")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			manifest := sha256Hex("content") + "  content.txt\n" +
+				sha256Hex("missing") + "  missing.txt\n" +
+				sha256Hex("ok") + "  ok.txt\n"
+			m, err := wfs.ReadManifest(bytes.NewBufferString(manifest), wfs.ManifestSHA256SUMS)
+			if err != nil {
+				t.Fatalf("ReadManifest failed: %v", err)
+			}
+			m = append(m, wfs.ManifestEntry{Path: "size.txt", Size: 4, SHA256: sha256Hex("size")})
+
+			result, err := wfs.VerifyManifest(fsys, filepath.Join(base, "dist"), m)
+			if err != nil {
+				t.Fatalf("VerifyManifest failed: %v", err)
+			}
+			if result.OK() {
+				t.Fatalf("expected verification to fail")
+			}
+			if len(result.Missing) != 1 || result.Missing[0] != "missing.txt" {
+				t.Errorf("expected missing [missing.txt], got %v", result.Missing)
+			}
+			if len(result.Extra) != 1 || result.Extra[0] != "extra.txt" {
+				t.Errorf("expected extra [extra.txt], got %v", result.Extra)
+			}
+			if len(result.Corrupt) != 2 || result.Corrupt[0] != "content.txt" || result.Corrupt[1] != "size.txt" {
+				t.Errorf("expected corrupt [content.txt size.txt], got %v", result.Corrupt)
+			}
+
+			m, err = wfs.BuildManifest(fsys, filepath.Join(base, "dist"))
+			if err != nil {
+				t.Fatalf("BuildManifest failed: %v", err)
+			}
+			result, err = wfs.VerifyManifest(fsys, filepath.Join(base, "dist"), m)
+			if err != nil || !result.OK() {
+				t.Errorf("expected verification to pass, got %+v err: %v", result, err)
+			}
+		})
+	}
+}
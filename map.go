@@ -1,33 +1,348 @@
 package wfs
 
 import (
-	"bytes"
 	"errors"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"path"
 	"strings"
-	"syscall"
 	"testing/fstest"
 	"time"
 )
 
-// mapFs mirrors os filesystem using [fstest.MapFS] and a [bytes.Reader].
-type mapFs struct{ fstest.MapFS }
+// mapFs mirrors os filesystem using [fstest.MapFS], open files read and write
+// the data of their [fstest.MapFile] in place.
+type mapFs struct {
+	fstest.MapFS
+	strict bool
+	locks  lockTable
+}
+
+// MapOption configures the file system returned by [Map].
+type MapOption func(*mapFs)
+
+// StrictPerms enforces the owner permission bits of files and directories.
+// OpenFile requires read or write permission on the file, and creating a file,
+// Mkdir and Remove require write and execute permission on the parent directory.
+// Directories that only exist implicitly are treated as having mode 0777.
+func StrictPerms() MapOption {
+	return func(f *mapFs) { f.strict = true }
+}
 
 // Map returns a writeable file system from an existing [fstest.MapFS].
-func Map(fs fstest.MapFS) FS {
-	return &mapFs{fs}
+// Names are slash-separated paths as accepted by [fs.ValidPath].
+// Like the map it is built on, the file system and its open files are not
+// safe for concurrent use by multiple goroutines.
+func Map(fs fstest.MapFS, opts ...MapOption) FS {
+	f := &mapFs{MapFS: fs}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// checkPerm returns an error if the strict file system does not grant all
+// owner permission bits in perm on target.
+func (f *mapFs) checkPerm(op, name, target string, perm fs.FileMode) error {
+	if !f.strict {
+		return nil
+	}
+	if resolved, err := f.resolve(target, true); err == nil {
+		target = resolved
+	}
+	mode := fs.ModePerm
+	if file, ok := f.MapFS[target]; ok {
+		mode = file.Mode.Perm()
+	}
+	if mode&perm != perm {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+// resolve returns name with all symbolic links resolved.
+// The final component is only resolved if follow is true.
+func (f *mapFs) resolve(name string, follow bool) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", fs.ErrInvalid
+	}
+	if name == "." {
+		return name, nil
+	}
+	elems := strings.Split(name, "/")
+	resolved := "."
+	links := 0
+	for i := 0; i < len(elems); i++ {
+		next := path.Join(resolved, elems[i])
+		file, ok := f.MapFS[next]
+		last := i == len(elems)-1
+		if ok && file.Mode&fs.ModeSymlink != 0 && (!last || follow) {
+			links++
+			if links > maxSymlinks {
+				return "", errLoop
+			}
+			dest := string(file.Data)
+			if !path.IsAbs(dest) {
+				dest = path.Join(resolved, dest)
+			}
+			target := strings.TrimPrefix(path.Join("/", dest, path.Join(elems[i+1:]...)), "/")
+			if target == "" {
+				target = "."
+			}
+			elems = strings.Split(target, "/")
+			resolved, i = ".", -1
+			continue
+		}
+		if ok && !last && file.Mode&fs.ModeType == 0 {
+			// a regular file has no entries
+			return "", ErrNotDir
+		}
+		resolved = next
+	}
+	if links == 0 {
+		// leave names without symbolic links untouched
+		return name, nil
+	}
+	return resolved, nil
+}
+
+func (f *mapFs) Open(name string) (fs.File, error) {
+	resolved, err := f.resolve(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f.MapFS.Open(resolved)
+}
+
+// Stat implements [fs.StatFS] for mapFs.
+func (f *mapFs) Stat(name string) (fs.FileInfo, error) {
+	resolved, err := f.resolve(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return f.MapFS.Stat(resolved)
+}
+
+func (f *mapFs) Symlink(oldname, newname string) error {
+	if !fs.ValidPath(newname) || newname == "." {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	resolved, err := f.resolveNew(newname)
+	if err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	if _, err := f.Lstat(resolved); err == nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	f.MapFS[resolved] = &fstest.MapFile{
+		Data:    []byte(oldname),
+		Mode:    fs.ModeSymlink | 0777,
+		ModTime: time.Now(),
+	}
+	return nil
+}
+
+// Link creates newname as a hard link sharing the [fstest.MapFile] of oldname.
+func (f *mapFs) Link(oldname, newname string) error {
+	if !fs.ValidPath(newname) || newname == "." {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	resolved, err := f.resolve(oldname, false)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+	info, err := f.Lstat(resolved)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	if info.IsDir() {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrPermission}
+	}
+	target, err := f.resolveNew(newname)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+	if _, err := f.Lstat(target); err == nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	f.MapFS[target] = f.MapFS[resolved]
+	return nil
+}
+
+// resolveNew returns name with the symbolic links of its parent directories
+// resolved, for a new entry created at name. Like OpenFile, the parent
+// directory must exist either as an explicit entry or implicitly through
+// the files it contains.
+func (f *mapFs) resolveNew(name string) (string, error) {
+	resolved, err := f.resolve(name, false)
+	if err != nil {
+		return "", err
+	}
+	if dir := path.Dir(resolved); dir != "." {
+		info, err := f.MapFS.Stat(dir)
+		if err != nil {
+			return "", fs.ErrNotExist
+		}
+		if !info.IsDir() {
+			return "", ErrNotDir
+		}
+	}
+	return resolved, nil
+}
+
+func (f *mapFs) Readlink(name string) (string, error) {
+	resolved, err := f.resolve(name, false)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	file, ok := f.MapFS[resolved]
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	if file.Mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return string(file.Data), nil
+}
+
+func (f *mapFs) Lstat(name string) (fs.FileInfo, error) {
+	resolved, err := f.resolve(name, false)
+	if err != nil {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: err}
+	}
+	if file, ok := f.MapFS[resolved]; ok && file.Mode&fs.ModeSymlink != 0 {
+		return &memFileInfo{
+			name:    path.Base(resolved),
+			size:    int64(len(file.Data)),
+			mode:    file.Mode,
+			modTime: file.ModTime,
+			sys:     file.Sys,
+		}, nil
+	}
+	return f.MapFS.Stat(resolved)
+}
+
+func (f *mapFs) Chmod(name string, mode fs.FileMode) error {
+	return f.meta("chmod", name, func(file *fstest.MapFile) {
+		file.Mode = file.Mode&^chmodMask | mode&chmodMask
+	})
+}
+
+// Chown is not supported by mapFs as [fstest.MapFile] has no owner,
+// it returns an error wrapping [errors.ErrUnsupported].
+func (f *mapFs) Chown(name string, uid, gid int) error {
+	if _, err := f.Stat(name); err != nil {
+		return &fs.PathError{Op: "chown", Path: name, Err: fs.ErrNotExist}
+	}
+	return &fs.PathError{Op: "chown", Path: name, Err: errors.ErrUnsupported}
+}
+
+// Chtimes changes the modification time of the named file,
+// access times are not recorded by mapFs.
+func (f *mapFs) Chtimes(name string, atime, mtime time.Time) error {
+	return f.meta("chtimes", name, func(file *fstest.MapFile) {
+		if !mtime.IsZero() {
+			file.ModTime = mtime
+		}
+	})
+}
+
+// Lock implements [LockFS] for mapFs.
+func (f *mapFs) Lock(name string) (Unlocker, error) {
+	return f.lock("lock", name, true, true)
+}
+
+// RLock implements [LockFS] for mapFs.
+func (f *mapFs) RLock(name string) (Unlocker, error) {
+	return f.lock("rlock", name, false, true)
+}
+
+// TryLock implements [LockFS] for mapFs.
+func (f *mapFs) TryLock(name string) (Unlocker, error) {
+	return f.lock("lock", name, true, false)
+}
+
+// lock locks the map entry of the named file, so the lock follows the file
+// when it is renamed. Directories that only exist implicitly are locked by name.
+func (f *mapFs) lock(op, name string, exclusive, block bool) (Unlocker, error) {
+	file, err := f.OpenFile(name, os.O_RDONLY|os.O_CREATE, 0666)
+	if err != nil {
+		if pe, ok := err.(*fs.PathError); ok {
+			pe.Op = op
+		}
+		return nil, err
+	}
+	file.Close()
+	var key any = file.(*mapFsFile).mfile
+	if key == (*fstest.MapFile)(nil) {
+		resolved, _ := f.resolve(name, true)
+		key = resolved
+	}
+	return f.locks.lock(op, name, key, exclusive, block)
+}
+
+// meta applies change to the map entry of the named file following symbolic links.
+// Directories that only exist implicitly are added as explicit entries.
+func (f *mapFs) meta(op, name string, change func(file *fstest.MapFile)) error {
+	resolved, err := f.resolve(name, true)
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	info, err := f.MapFS.Stat(resolved)
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	file, ok := f.MapFS[resolved]
+	if !ok {
+		file = &fstest.MapFile{Mode: info.Mode(), ModTime: info.ModTime()}
+		f.MapFS[resolved] = file
+	}
+	change(file)
+	return nil
 }
 
 func (f *mapFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
-	file, err := f.Open(name)
+	resolved, err := f.resolve(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	file, err := f.MapFS.Open(resolved)
 	// create file if it does not exist and os.0_CREATE flag is present
 	if errors.Is(err, fs.ErrNotExist) && flag&os.O_CREATE != 0 {
+		// the parent directory must exist, either as an explicit entry
+		// or implicitly through the files it contains
+		if dir := path.Dir(resolved); dir != "." {
+			info, err := f.MapFS.Stat(dir)
+			if err != nil {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+			}
+			if !info.IsDir() {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: ErrNotDir}
+			}
+		}
+		if err := f.checkPerm("open", name, path.Dir(resolved), 0300); err != nil {
+			return nil, err
+		}
 		// use perm only when creating new files
-		f.MapFS[name] = &fstest.MapFile{Mode: perm}
-		file, err = f.Open(name)
+		f.MapFS[resolved] = &fstest.MapFile{Mode: perm, ModTime: time.Now()}
+		file, err = f.MapFS.Open(resolved)
+	} else if err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	} else if err == nil {
+		var need fs.FileMode
+		if flag&(os.O_WRONLY|os.O_RDWR) != os.O_WRONLY {
+			need |= 0400
+		}
+		if flag&(os.O_WRONLY|os.O_RDWR) != 0 || flag&os.O_TRUNC != 0 {
+			need |= 0200
+		}
+		if err := f.checkPerm("open", name, resolved, need); err != nil {
+			file.Close()
+			return nil, err
+		}
 	}
 	if err != nil {
 		return nil, err
@@ -36,32 +351,42 @@ func (f *mapFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error)
 	if err != nil {
 		return nil, err
 	}
-	// return an error if write flags are used to open a directory
-	if info.IsDir() && flag&(os.O_WRONLY|os.O_RDWR) != 0 {
-		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	// return an error if write flags are used to open a directory,
+	// like open(2) directories are not created or truncated either
+	if info.IsDir() && flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: ErrIsDir}
 	}
-	// read file contents into bytes reader
-	b, _ := io.ReadAll(file)
 	mfile := &mapFsFile{
-		File:   file,
-		mfile:  f.MapFS[name],
-		name:   name,
-		flag:   flag,
-		perm:   info.Mode(),
-		reader: bytes.NewReader(b),
+		File:  file,
+		mfile: f.MapFS[resolved],
+		name:  name,
+		flag:  flag,
+		perm:  info.Mode(),
 	}
-	// truncate file if O_TRUNC flag is present
-	if flag&os.O_TRUNC != 0 {
-		mfile.Truncate(0)
-	}
-	// move file cursor to end if O_APPEND flag is present
-	if flag&os.O_APPEND != 0 {
-		mfile.Seek(0, io.SeekEnd)
+	// truncate file if O_TRUNC flag is present, regardless of the access mode
+	if flag&os.O_TRUNC != 0 && len(mfile.mfile.Data) > 0 {
+		mfile.mfile.Data = nil
+		mfile.mfile.ModTime = time.Now()
 	}
 	return mfile, nil
 }
 
 func (f *mapFs) Rename(oldpath, newpath string) error {
+	if !fs.ValidPath(oldpath) || !fs.ValidPath(newpath) || oldpath == "." || newpath == "." {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	// like rename(2), both parent directories are looked up before the entries
+	for _, dir := range []string{path.Dir(oldpath), path.Dir(newpath)} {
+		info, err := f.Stat(dir)
+		if err != nil {
+			if pe, ok := err.(*fs.PathError); ok {
+				err = pe.Err
+			}
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+		} else if !info.IsDir() {
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: ErrNotDir}
+		}
+	}
 	oldinfo, err := f.Stat(oldpath)
 	if err != nil {
 		if pe, ok := err.(*fs.PathError); ok {
@@ -69,63 +394,94 @@ func (f *mapFs) Rename(oldpath, newpath string) error {
 		}
 		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
 	}
-	if oldpath == newpath {
-		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EEXIST}
+	// like os.Rename, a file renamed to itself is left as is
+	// while a directory already exists
+	if oldpath == newpath && !oldinfo.IsDir() {
+		return nil
 	}
 	// return an error if newpath is a directory
 	newinfo, err := f.Stat(newpath)
 	if err == nil && newinfo.IsDir() {
-		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EEXIST}
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrExist}
 	}
 
-	// check if new parent directory exists
-	dir, _ := path.Split(newpath)
-	if dir != "" {
-		dirinfo, err := f.Stat(dir)
-		if err != nil {
-			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.ENOENT}
-		} else if !dirinfo.IsDir() {
-			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.ENOTDIR}
-		}
+	// a directory cannot be moved below itself
+	if oldinfo.IsDir() && strings.HasPrefix(newpath, oldpath+"/") {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	// a directory cannot replace a file
+	if newinfo != nil && oldinfo.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: ErrNotDir}
 	}
 
-	movepath := true
-	if oldinfo.IsDir() {
-		// for a directory move each file that exists under oldpath
-		for name := range f.MapFS {
-			if strings.HasPrefix(name, oldpath) {
-				newname := strings.Replace(name, oldpath, newpath, 1)
-				f.MapFS[newname] = f.MapFS[name]
-				delete(f.MapFS, name)
-				movepath = false
-			}
+	f.keepParent(oldpath)
+	// move oldpath and every entry under it, matching whole path components
+	// an empty directory will exist explicitly as a map entry in [fstest.MapFS]
+	var names []string
+	for name := range f.MapFS {
+		if name == oldpath || oldinfo.IsDir() && strings.HasPrefix(name, oldpath+"/") {
+			names = append(names, name)
 		}
 	}
-	// movepath remains true if oldpath is a file or an empty directory
-	// an empty directory will exist explicitly as a map entry in [fstest.MapFS]
-	if movepath {
-		f.MapFS[newpath] = f.MapFS[oldpath]
-		delete(f.MapFS, oldpath)
+	moved := make(map[string]*fstest.MapFile, len(names))
+	for _, name := range names {
+		moved[newpath+strings.TrimPrefix(name, oldpath)] = f.MapFS[name]
+		delete(f.MapFS, name)
 	}
+	maps.Copy(f.MapFS, moved)
 	return nil
 }
 
+// keepParent adds an explicit entry for the parent directory of name if it
+// only exists implicitly, so that the directory outlives its last entry.
+func (f *mapFs) keepParent(name string) {
+	dir := path.Dir(name)
+	if dir == "." {
+		return
+	}
+	if _, ok := f.MapFS[dir]; ok {
+		return
+	}
+	if info, err := f.MapFS.Stat(dir); err == nil && info.IsDir() {
+		f.MapFS[dir] = &fstest.MapFile{Mode: info.Mode(), ModTime: info.ModTime()}
+	}
+}
+
 func (f *mapFs) Remove(name string) error {
-	_, ok := f.MapFS[name]
-	if !ok {
-		return &fs.PathError{Op: "remove", Path: "name", Err: syscall.ENOENT}
+	if name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
 	}
-	entries, _ := fs.ReadDir(f, name)
-	if len(entries) > 0 {
-		return &fs.PathError{Op: "remove", Path: "name", Err: syscall.ENOTEMPTY}
+	// directories may exist implicitly through the files they contain
+	info, err := f.Lstat(name)
+	if err != nil {
+		if pe, ok := err.(*fs.PathError); ok {
+			pe.Op, pe.Path = "remove", name
+		}
+		return err
+	}
+	if err := f.checkPerm("remove", name, path.Dir(name), 0300); err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if entries, _ := f.MapFS.ReadDir(name); len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: ErrNotEmpty}
+		}
 	}
+	f.keepParent(name)
 	delete(f.MapFS, name)
 	return nil
 }
 
 func (f *mapFs) RemoveAll(path string) error {
+	if !fs.ValidPath(path) || path == "." {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: fs.ErrInvalid}
+	}
+	if _, err := f.resolve(path, false); err != nil {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: err}
+	}
+	f.keepParent(path)
 	for name := range f.MapFS {
-		if strings.HasPrefix(name, path) {
+		if name == path || strings.HasPrefix(name, path+"/") {
 			delete(f.MapFS, name)
 		}
 	}
@@ -133,136 +489,246 @@ func (f *mapFs) RemoveAll(path string) error {
 }
 
 func (f *mapFs) Mkdir(name string, perm fs.FileMode) error {
-	dir, _ := path.Split(name)
-	if dir != "" {
+	if !fs.ValidPath(name) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, err := f.Lstat(name); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	dir := path.Dir(name)
+	if dir != "." {
 		info, err := f.Stat(dir)
 		if err != nil {
-			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOENT}
+			if pe, ok := err.(*fs.PathError); ok {
+				err = pe.Err
+			}
+			return &os.PathError{Op: "mkdir", Path: name, Err: err}
 		}
 		if !info.IsDir() {
-			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+			return &os.PathError{Op: "mkdir", Path: name, Err: ErrNotDir}
 		}
 	}
+	if err := f.checkPerm("mkdir", name, dir, 0300); err != nil {
+		return err
+	}
 	f.MapFS[name] = &fstest.MapFile{
-		Mode:    perm,
+		Mode:    fs.ModeDir | perm,
 		ModTime: time.Now(),
 	}
 	return nil
 }
 
 func (f *mapFs) MkdirAll(name string, perm fs.FileMode) error {
-	dir, _ := path.Split(name)
-	info, err := f.Stat(dir)
-	if err != nil {
-		f.MapFS[name] = &fstest.MapFile{
-			Mode:    perm,
-			ModTime: time.Now(),
-		}
+	if !fs.ValidPath(name) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
 		return nil
 	}
-	if !info.IsDir() {
-		return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+	// create an explicit entry for each missing path component
+	elems := strings.Split(name, "/")
+	for i := range elems {
+		dir := strings.Join(elems[:i+1], "/")
+		info, err := f.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return &os.PathError{Op: "mkdir", Path: name, Err: ErrNotDir}
+			}
+			continue
+		}
+		resolved, err := f.resolve(dir, true)
+		if err != nil {
+			return &os.PathError{Op: "mkdir", Path: name, Err: err}
+		}
+		f.MapFS[resolved] = &fstest.MapFile{
+			Mode:    fs.ModeDir | perm,
+			ModTime: time.Now(),
+		}
 	}
 	return nil
 }
 
+// mapFsFile is an open file of a mapFs.
+// All handles of a file share its [fstest.MapFile], so writes made through
+// one handle are visible to the others. Like an unlinked file on Unix, a handle
+// keeps using its MapFile after the name is removed and the data is released
+// once the last handle is closed.
 type mapFsFile struct {
 	fs.File
 	mfile  *fstest.MapFile
 	name   string
 	flag   int
 	perm   fs.FileMode
-	reader *bytes.Reader
+	offset int64
+	closed bool
+}
+
+// checkClosed returns an error if the file has been closed.
+func (f *mapFsFile) checkClosed(op string) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	return nil
+}
+
+func (f *mapFsFile) Close() error {
+	if err := f.checkClosed("close"); err != nil {
+		return err
+	}
+	f.closed = true
+	// drop the reference so the data of a removed file can be released
+	f.mfile = nil
+	return f.File.Close()
 }
 
 func (f *mapFsFile) Name() string {
 	return f.name
 }
 
+// Stat describes the current contents of the file, reflecting writes
+// made since it was opened.
+func (f *mapFsFile) Stat() (fs.FileInfo, error) {
+	if err := f.checkClosed("stat"); err != nil {
+		return nil, err
+	}
+	if f.mfile == nil || f.perm.IsDir() {
+		return f.File.Stat()
+	}
+	return &memFileInfo{
+		name:    path.Base(f.name),
+		size:    int64(len(f.mfile.Data)),
+		mode:    f.mfile.Mode,
+		modTime: f.mfile.ModTime,
+		sys:     f.mfile.Sys,
+	}, nil
+}
+
 func (f *mapFsFile) Read(b []byte) (n int, err error) {
+	if err := f.checkClosed("read"); err != nil {
+		return 0, err
+	}
 	if f.perm.IsDir() {
-		return 0, &fs.PathError{Op: "read", Path: f.name, Err: syscall.EISDIR}
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: ErrIsDir}
 	}
-	if f.flag&(os.O_RDONLY|os.O_RDWR) == 0 && f.flag != 0 {
-		return 0, &fs.PathError{Op: "read", Path: f.name, Err: syscall.EBADF}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == os.O_WRONLY {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errBadFile}
 	}
 
-	return f.reader.Read(b)
+	if f.offset >= int64(len(f.mfile.Data)) {
+		return 0, io.EOF
+	}
+	n = copy(b, f.mfile.Data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
 }
 
 func (f *mapFsFile) ReadAt(b []byte, off int64) (n int, err error) {
+	if err := f.checkClosed("read"); err != nil {
+		return 0, err
+	}
 	if f.perm.IsDir() {
-		return 0, &fs.PathError{Op: "read", Path: f.name, Err: syscall.EISDIR}
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: ErrIsDir}
 	}
-	if f.flag&(os.O_RDONLY|os.O_RDWR) == 0 && f.flag != 0 {
-		return 0, &fs.PathError{Op: "read", Path: f.name, Err: syscall.EBADF}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == os.O_WRONLY {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errBadFile}
 	}
 
-	if off < 0 || off > int64(f.reader.Size()) {
+	if off < 0 {
 		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
 	}
-	return f.reader.ReadAt(b, off)
+	if off >= int64(len(f.mfile.Data)) {
+		return 0, io.EOF
+	}
+	n = copy(b, f.mfile.Data[off:])
+	if n < len(b) {
+		err = io.EOF
+	}
+	return n, err
 }
 
 func (f *mapFsFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.checkClosed("seek"); err != nil {
+		return 0, err
+	}
 	if f.perm.IsDir() {
-		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: syscall.EISDIR}
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: ErrIsDir}
 	}
 
-	n, err := f.reader.Seek(offset, whence)
-	if err != nil {
-		err = &fs.PathError{Op: "seek", Path: f.name, Err: err}
-	}
-	return n, err
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.mfile.Data))
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
 }
 
 func (f *mapFsFile) Write(b []byte) (n int, err error) {
+	if err := f.checkClosed("write"); err != nil {
+		return 0, err
+	}
 	if f.perm.IsDir() || f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
-		return 0, &fs.PathError{Op: "write", Path: f.name, Err: syscall.EBADF}
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: errBadFile}
 	}
 
-	pos, _ := f.Seek(0, io.SeekCurrent)
-	end := int(pos) + len(b)
-	// expand the slice if necessary
-	if end > len(f.mfile.Data) {
-		f.mfile.Data = append(f.mfile.Data, make([]byte, end-len(f.mfile.Data))...)
+	// writes to a file opened with O_APPEND always go to the current end
+	// which may have been moved by another handle
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.mfile.Data))
 	}
-	n = copy(f.mfile.Data[pos:], b)
-	f.reset()
+	n = f.writeAt(b, f.offset)
 	// move cursor based on amount written
-	f.reader.Seek(int64(n), io.SeekCurrent)
-	return
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *mapFsFile) WriteString(s string) (n int, err error) {
+	return f.Write([]byte(s))
 }
 
 func (f *mapFsFile) WriteAt(b []byte, off int64) (n int, err error) {
+	if err := f.checkClosed("write"); err != nil {
+		return 0, err
+	}
 	if f.flag&os.O_APPEND != 0 {
 		return 0, errors.New("invalid use of WriteAt on file opened with O_APPEND")
 	}
 	if f.perm.IsDir() || f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
-		return 0, &fs.PathError{Op: "write", Path: f.name, Err: syscall.EBADF}
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: errBadFile}
 	}
 
 	if off < 0 {
 		err = &fs.PathError{Op: "writeat", Path: f.name, Err: errors.New("negative offset")}
 		return
 	}
+	return f.writeAt(b, off), nil
+}
+
+// writeAt writes b to the shared data at off, growing it if necessary.
+func (f *mapFsFile) writeAt(b []byte, off int64) int {
 	end := int(off) + len(b)
 	// expand the slice if necessary
 	if end > len(f.mfile.Data) {
 		f.mfile.Data = append(f.mfile.Data, make([]byte, end-len(f.mfile.Data))...)
 	}
-	n = copy(f.mfile.Data[off:], b)
-	f.reset()
-	return
+	n := copy(f.mfile.Data[off:], b)
+	f.mfile.ModTime = time.Now()
+	return n
 }
 
 func (f *mapFsFile) Truncate(size int64) error {
-	if f.perm.IsDir() || f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
-		return &fs.PathError{Op: "truncate", Path: f.name, Err: syscall.EINVAL}
+	if err := f.checkClosed("truncate"); err != nil {
+		return err
 	}
-
-	if size < 0 {
-		return nil
+	if f.perm.IsDir() || f.flag&(os.O_WRONLY|os.O_RDWR) == 0 || size < 0 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
 	}
 	curr := int64(len(f.mfile.Data))
 	if size > curr {
@@ -271,13 +737,57 @@ func (f *mapFsFile) Truncate(size int64) error {
 	} else {
 		f.mfile.Data = f.mfile.Data[:size]
 	}
-	f.reset()
+	f.mfile.ModTime = time.Now()
 	return nil
 }
 
-// reset updates the reader bytes reference while maintaining the cursor position.
-func (f *mapFsFile) reset() {
-	pos, _ := f.reader.Seek(0, io.SeekCurrent)
-	f.reader.Reset(f.mfile.Data)
-	f.reader.Seek(pos, io.SeekStart)
+func (f *mapFsFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if err := f.checkClosed("readdir"); err != nil {
+		return nil, err
+	}
+	if dir, ok := f.File.(fs.ReadDirFile); ok && f.perm.IsDir() {
+		return dir.ReadDir(n)
+	}
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: ErrNotDir}
+}
+
+// Sync does nothing as mapFs has no stable storage.
+func (f *mapFsFile) Sync() error {
+	return f.checkClosed("sync")
+}
+
+// ReadDir implements [fs.ReadDirFS] for mapFs following symbolic links.
+func (f *mapFs) ReadDir(name string) ([]fs.DirEntry, error) {
+	resolved, err := f.resolve(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if file, ok := f.MapFS[resolved]; ok && !file.Mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrNotDir}
+	}
+	return f.MapFS.ReadDir(resolved)
+}
+
+// ReadFile implements [fs.ReadFileFS] for mapFs following symbolic links.
+func (f *mapFs) ReadFile(name string) ([]byte, error) {
+	resolved, err := f.resolve(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	if info, err := f.MapFS.Stat(resolved); err == nil && info.IsDir() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: ErrIsDir}
+	}
+	return f.MapFS.ReadFile(resolved)
+}
+
+// Glob implements [fs.GlobFS] for mapFs.
+func (f *mapFs) Glob(pattern string) ([]string, error) {
+	// hide Glob so that fs.Glob matches using ReadDir
+	// and directories are resolved through symbolic links
+	return fs.Glob(struct{ fs.ReadDirFS }{f}, pattern)
+}
+
+// Sub implements [fs.SubFS] for mapFs, the returned file system is writable.
+func (f *mapFs) Sub(dir string) (fs.FS, error) {
+	return Sub(f, dir)
 }
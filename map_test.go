@@ -1,51 +1,141 @@
 package wfs_test
 
 import (
+	"bytes"
+	"errors"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"testing/fstest"
+	"testing/iotest"
+	"time"
 
 	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/wfstest"
 )
 
-var fileSystems = []struct {
+type fileSystem struct {
 	name string
 	fsys func(fstest.MapFS) (fs wfs.FS, base string, cleanup func(), err error)
-}{
+}
+
+var fileSystems = []fileSystem{
 	{"OS FS", func(fsys fstest.MapFS) (wfs.FS, string, func(), error) {
 		dir, err := os.MkdirTemp("", "testdata")
 		if err != nil {
 			return nil, "", nil, err
 		}
-
-		for name, file := range fsys {
-			name = filepath.Join(dir, name)
-			if file.Mode == 0 {
-				file.Mode = os.ModePerm
-			}
-			if file.Mode.IsDir() {
-				err = os.MkdirAll(name, file.Mode)
-			} else {
-				err = os.MkdirAll(filepath.Dir(name), 0755)
-				if err != nil {
-					break
-				}
-				err = os.WriteFile(name, file.Data, file.Mode)
-			}
-			if err != nil {
-				break
-			}
+		cleanup := func() { os.RemoveAll(dir) }
+		return wfs.OS(), dir, cleanup, wfstest.Write(wfs.Dir(dir), fsys)
+	}},
+	{"Dir FS", func(fsys fstest.MapFS) (wfs.FS, string, func(), error) {
+		dir, err := os.MkdirTemp("", "testdata")
+		if err != nil {
+			return nil, "", nil, err
 		}
-
 		cleanup := func() { os.RemoveAll(dir) }
-		return wfs.OS(), dir, cleanup, err
+		return wfs.Dir(dir), "", cleanup, wfstest.Write(wfs.Dir(dir), fsys)
 	}},
 	{"Map FS", func(fsys fstest.MapFS) (wfs.FS, string, func(), error) {
 		return wfs.Map(fsys), "", func() {}, nil
 	}},
+	{"Mem FS", func(fsys fstest.MapFS) (wfs.FS, string, func(), error) {
+		mfs := wfs.Mem()
+		return mfs, "", func() {}, wfstest.Write(mfs, fsys)
+	}},
+}
+
+func TestMapStrictPerms(t *testing.T) {
+	fsys := wfs.Map(fstest.MapFS{
+		"readonly":       &fstest.MapFile{Data: []byte("Hello"), Mode: 0444},
+		"writeonly":      &fstest.MapFile{Mode: 0200},
+		"lockeddir":      &fstest.MapFile{Mode: fs.ModeDir | 0555},
+		"lockeddir/file": &fstest.MapFile{Mode: 0644},
+		"opendir/file":   &fstest.MapFile{Mode: 0644},
+	}, wfs.StrictPerms())
+
+	if _, err := fsys.OpenFile("readonly", os.O_WRONLY, 0); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected write open of read-only file to fail with ErrPermission, got %v", err)
+	}
+	if _, err := fsys.OpenFile("readonly", os.O_RDONLY|os.O_TRUNC, 0); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected truncate of read-only file to fail with ErrPermission, got %v", err)
+	}
+	if b, err := fs.ReadFile(fsys, "readonly"); err != nil || string(b) != "Hello" {
+		t.Errorf("expected read of read-only file to succeed, got %q err: %v", b, err)
+	}
+	if _, err := fsys.OpenFile("writeonly", os.O_RDONLY, 0); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected read open of write-only file to fail with ErrPermission, got %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "writeonly", []byte("Hello"), 0); err != nil {
+		t.Errorf("expected write of write-only file to succeed, got %v", err)
+	}
+
+	for op, err := range map[string]error{
+		"Create": wfs.WriteFile(fsys, "lockeddir/new", nil, 0644),
+		"Mkdir":  fsys.Mkdir("lockeddir/dir", 0755),
+		"Remove": fsys.Remove("lockeddir/file"),
+	} {
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("%s: expected ErrPermission in read-only directory, got %v", op, err)
+		}
+	}
+	// implicit directories are writable
+	if err := wfs.WriteFile(fsys, "opendir/new", nil, 0644); err != nil {
+		t.Errorf("expected create in implicit directory to succeed, got %v", err)
+	}
+	if err := fsys.Mkdir("opendir/dir", 0755); err != nil {
+		t.Errorf("expected Mkdir in implicit directory to succeed, got %v", err)
+	}
+
+	// permissions are ignored by default
+	fsys = wfs.Map(fstest.MapFS{"readonly": &fstest.MapFile{Mode: 0444}})
+	if err := wfs.WriteFile(fsys, "readonly", nil, 0); err != nil {
+		t.Errorf("expected write to succeed without StrictPerms, got %v", err)
+	}
+}
+
+func TestInvalidNames(t *testing.T) {
+	for _, tt := range fileSystems {
+		if tt.name == "OS FS" {
+			// the OS file system accepts native paths
+			continue
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, _, cleanup, err := tt.fsys(fstest.MapFS{
+				"dir/file": &fstest.MapFile{Data: []byte("Hello")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			for _, name := range []string{"", "/dir/file", "dir/../dir/file", "dir//file", "./dir", "dir/"} {
+				_, errOpen := fsys.OpenFile(name, os.O_RDONLY, 0)
+				_, errStat := fs.Stat(fsys, name)
+				errs := map[string]error{
+					"OpenFile":  errOpen,
+					"Stat":      errStat,
+					"Rename":    fsys.Rename(name, "new"),
+					"Remove":    fsys.Remove(name),
+					"RemoveAll": fsys.RemoveAll(name),
+					"Mkdir":     fsys.Mkdir(name, 0755),
+					"MkdirAll":  fsys.MkdirAll(name, 0755),
+				}
+				for op, err := range errs {
+					if !errors.Is(err, fs.ErrInvalid) {
+						t.Errorf("%s(%q): expected ErrInvalid, got %v", op, name, err)
+					}
+				}
+			}
+			if b, err := fs.ReadFile(fsys, "dir/file"); err != nil || string(b) != "Hello" {
+				t.Errorf("expected file to be unchanged, got %q err: %v", b, err)
+			}
+		})
+	}
 }
 
 func TestFileReadAt(t *testing.T) {
@@ -178,6 +268,292 @@ func TestFileTruncate(t *testing.T) {
 	}
 }
 
+func TestIOFS(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dir/a.txt":     &fstest.MapFile{Data: []byte("a")},
+				"dir/b.txt":     &fstest.MapFile{Data: []byte("b")},
+				"dir/sub/c.txt": &fstest.MapFile{Data: []byte("c")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			if _, ok := fsys.(fs.ReadDirFS); !ok {
+				t.Errorf("expected fs.ReadDirFS")
+			}
+			if _, ok := fsys.(fs.ReadFileFS); !ok {
+				t.Errorf("expected fs.ReadFileFS")
+			}
+			if _, ok := fsys.(fs.SubFS); !ok {
+				t.Errorf("expected fs.SubFS")
+			}
+
+			dir := filepath.Join(base, "dir")
+			entries, err := fs.ReadDir(fsys, dir)
+			if err != nil || len(entries) != 3 || entries[0].Name() != "a.txt" || !entries[2].IsDir() {
+				t.Errorf("unexpected ReadDir result %v err: %v", entries, err)
+			}
+			if _, err := fs.ReadDir(fsys, filepath.Join(dir, "a.txt")); err == nil {
+				t.Errorf("expected ReadDir to fail for a file")
+			}
+			b, err := fs.ReadFile(fsys, filepath.Join(dir, "a.txt"))
+			if err != nil || string(b) != "a" {
+				t.Errorf("expected 'a', got %q err: %v", b, err)
+			}
+			if _, err := fs.ReadFile(fsys, filepath.Join(base, "missing")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected ErrNotExist, got %v", err)
+			}
+			matches, err := fs.Glob(fsys, filepath.Join(dir, "*.txt"))
+			if want := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")}; err != nil || !slices.Equal(matches, want) {
+				t.Errorf("expected matches %v, got %v err: %v", want, matches, err)
+			}
+
+			// sub file systems remain writable
+			sub, err := fsys.(fs.SubFS).Sub(dir)
+			if err != nil {
+				t.Fatalf("Sub failed: %v", err)
+			}
+			wsub, ok := sub.(wfs.FS)
+			if !ok {
+				t.Fatalf("expected Sub to return a wfs.FS, got %T", sub)
+			}
+			if err := wfs.WriteFile(wsub, "sub/d.txt", []byte("d"), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			b, err = fs.ReadFile(fsys, filepath.Join(dir, "sub/d.txt"))
+			if err != nil || string(b) != "d" {
+				t.Errorf("expected 'd', got %q err: %v", b, err)
+			}
+		})
+	}
+}
+
+func TestFileReadDir(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dir/a":     &fstest.MapFile{},
+				"dir/b/c":   &fstest.MapFile{},
+				"dir/empty": &fstest.MapFile{Mode: fs.ModeDir | 0755},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			file, err := fsys.OpenFile(filepath.Join(base, "dir"), os.O_RDONLY, 0)
+			if err != nil {
+				t.Fatalf("failed to open directory: %v", err)
+			}
+			defer file.Close()
+			f, ok := file.(fs.ReadDirFile)
+			if !ok {
+				t.Fatalf("expected directory to implement fs.ReadDirFile")
+			}
+
+			var names []string
+			for {
+				entries, err := f.ReadDir(1)
+				for _, e := range entries {
+					names = append(names, e.Name())
+				}
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadDir failed: %v", err)
+				}
+			}
+			slices.Sort(names)
+			if want := []string{"a", "b", "empty"}; !slices.Equal(names, want) {
+				t.Errorf("expected entries %v, got %v", want, names)
+			}
+
+			file, err = fsys.OpenFile(filepath.Join(base, "dir/a"), os.O_RDONLY, 0)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer file.Close()
+			if _, err := file.(fs.ReadDirFile).ReadDir(-1); err == nil {
+				t.Errorf("expected ReadDir to fail for a file")
+			}
+		})
+	}
+}
+
+func TestFileStat(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"testfile": &fstest.MapFile{Data: []byte("Hello"), ModTime: mtime},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			f, err := fsys.OpenFile(filepath.Join(base, "testfile"), os.O_RDWR, 0)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer f.Close()
+
+			if _, err := f.WriteAt([]byte(", World!"), 5); err != nil {
+				t.Fatalf("WriteAt failed: %v", err)
+			}
+			info, err := f.Stat()
+			if err != nil || info.Size() != 13 || !info.ModTime().After(mtime) {
+				t.Errorf("expected size 13 and a newer modification time after write, got %d %v err: %v", info.Size(), info.ModTime(), err)
+			}
+			if err := f.Truncate(2); err != nil {
+				t.Fatalf("Truncate failed: %v", err)
+			}
+			info, err = f.Stat()
+			if err != nil || info.Size() != 2 || info.Name() != "testfile" {
+				t.Errorf("expected size 2 after truncate, got %d err: %v", info.Size(), err)
+			}
+		})
+	}
+}
+
+func TestFileSharedHandles(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"testfile": &fstest.MapFile{Data: []byte("Hello")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			filePath := filepath.Join(base, "testfile")
+			w, err := fsys.OpenFile(filePath, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer w.Close()
+			r, err := fsys.OpenFile(filePath, os.O_RDONLY, 0)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer r.Close()
+			a, err := fsys.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer a.Close()
+
+			if _, err := w.WriteAt([]byte("J"), 0); err != nil {
+				t.Fatalf("WriteAt failed: %v", err)
+			}
+			if _, err := w.WriteAt([]byte(", World"), 5); err != nil {
+				t.Fatalf("WriteAt failed: %v", err)
+			}
+			if _, err := a.Write([]byte("!")); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			b, err := io.ReadAll(r)
+			if err != nil || string(b) != "Jello, World!" {
+				t.Errorf("expected other handle to read 'Jello, World!', got %q err: %v", b, err)
+			}
+		})
+	}
+}
+
+func TestFileUnlink(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"testfile": &fstest.MapFile{Data: []byte("Hello")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			filePath := filepath.Join(base, "testfile")
+			f, err := fsys.OpenFile(filePath, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			if err := fsys.Remove(filePath); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+
+			// the open handle keeps its data while the name is gone
+			if _, err := f.WriteAt([]byte("J"), 0); err != nil {
+				t.Errorf("WriteAt after Remove failed: %v", err)
+			}
+			b := make([]byte, 5)
+			if _, err := f.ReadAt(b, 0); err != nil || string(b) != "Jello" {
+				t.Errorf("expected 'Jello', got %q err: %v", b, err)
+			}
+			if _, err := fs.Stat(fsys, filePath); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected removed name not to exist, got %v", err)
+			}
+
+			// a new file with the same name is independent of the handle
+			if err := wfs.WriteFile(fsys, filePath, []byte("New"), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			if _, err := f.WriteAt([]byte("H"), 0); err != nil {
+				t.Errorf("WriteAt failed: %v", err)
+			}
+			if b, err := fs.ReadFile(fsys, filePath); err != nil || string(b) != "New" {
+				t.Errorf("expected 'New', got %q err: %v", b, err)
+			}
+
+			if err := f.Close(); err != nil {
+				t.Errorf("Close failed: %v", err)
+			}
+			if _, err := f.Write([]byte("x")); err == nil {
+				t.Errorf("expected Write after Close to fail")
+			}
+		})
+	}
+}
+
+func TestFileSync(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dir/testfile": &fstest.MapFile{},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			filePath := filepath.Join(base, "dir/testfile")
+			f, err := fsys.OpenFile(filePath, os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			f.Write([]byte("Hello"))
+			if err := f.Sync(); err != nil {
+				t.Errorf("Sync failed: %v", err)
+			}
+			f.Close()
+
+			newPath := filepath.Join(base, "dir/renamed")
+			if err := fsys.Rename(filePath, newPath); err != nil {
+				t.Fatalf("Rename failed: %v", err)
+			}
+			if err := wfs.SyncFS(fsys, filepath.Join(base, "dir")); err != nil {
+				t.Errorf("SyncFS failed: %v", err)
+			}
+			if err := wfs.SyncFS(fsys, filepath.Join(base, "missing")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected ErrNotExist, got %v", err)
+			}
+		})
+	}
+}
+
 func TestFileName(t *testing.T) {
 	for _, tt := range fileSystems {
 		t.Run(tt.name, func(t *testing.T) {
@@ -290,13 +666,86 @@ func TestOpenFile(t *testing.T) {
 	}
 }
 
+func TestOpenFileParent(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dir/testfile": &fstest.MapFile{Data: []byte("Hello")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			missing := filepath.Join(base, "missing/newfile")
+			if _, err := fsys.OpenFile(missing, os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected create under missing directory to fail with ErrNotExist, got %v", err)
+			}
+			if _, err := fs.Stat(fsys, missing); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected file not to be created, got %v", err)
+			}
+			underFile := filepath.Join(base, "dir/testfile/newfile")
+			if _, err := fsys.OpenFile(underFile, os.O_WRONLY|os.O_CREATE, 0644); err == nil {
+				t.Errorf("expected create under a file to fail")
+			}
+
+			// links are created under existing directories only
+			target := filepath.Join(base, "dir/testfile")
+			if err := wfs.Symlink(fsys, "testfile", missing); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected Symlink under missing directory to fail with ErrNotExist, got %v", err)
+			}
+			if err := wfs.Symlink(fsys, "testfile", underFile); err == nil {
+				t.Errorf("expected Symlink under a file to fail")
+			}
+			if err := wfs.Link(fsys, target, missing); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected Link under missing directory to fail with ErrNotExist, got %v", err)
+			}
+			if err := wfs.Link(fsys, target, underFile); err == nil {
+				t.Errorf("expected Link under a file to fail")
+			}
+			if _, err := fs.Stat(fsys, filepath.Join(base, "missing")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected missing directory not to be created, got %v", err)
+			}
+
+			// the implicit parent directory outlives its last file
+			if err := fsys.Remove(filepath.Join(base, "dir/testfile")); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+			info, err := fs.Stat(fsys, filepath.Join(base, "dir"))
+			if err != nil || !info.IsDir() {
+				t.Errorf("expected directory to remain after removing its last file, got %v err: %v", info, err)
+			}
+			if err := wfs.WriteFile(fsys, filepath.Join(base, "dir/newfile"), nil, 0644); err != nil {
+				t.Errorf("expected create in remaining directory to succeed, got %v", err)
+			}
+
+			// links are created in the target of a symbolic link to a directory
+			if err := wfs.Symlink(fsys, "dir", filepath.Join(base, "dirlink")); err != nil {
+				t.Fatalf("Symlink failed: %v", err)
+			}
+			if err := wfs.Symlink(fsys, "newfile", filepath.Join(base, "dirlink/symlink")); err != nil {
+				t.Errorf("Symlink through a symbolic link failed: %v", err)
+			}
+			if err := wfs.Link(fsys, filepath.Join(base, "dir/newfile"), filepath.Join(base, "dirlink/hardlink")); err != nil {
+				t.Errorf("Link through a symbolic link failed: %v", err)
+			}
+			for _, name := range []string{"dir/symlink", "dir/hardlink"} {
+				if _, err := fs.Stat(fsys, filepath.Join(base, name)); err != nil {
+					t.Errorf("expected %s to be created, got %v", name, err)
+				}
+			}
+		})
+	}
+}
+
 func TestRename(t *testing.T) {
 	for _, tt := range fileSystems {
 		t.Run(tt.name, func(t *testing.T) {
 			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
-				"oldname":        &fstest.MapFile{},
-				"oldnested/file": &fstest.MapFile{},
-				"oldemptydir":    &fstest.MapFile{Mode: fs.ModeDir | 0755},
+				"oldname":         &fstest.MapFile{},
+				"oldnested/file":  &fstest.MapFile{},
+				"oldemptydir":     &fstest.MapFile{Mode: fs.ModeDir | 0755},
+				"oldnested2/file": &fstest.MapFile{},
 			})
 			if err != nil {
 				t.Fatalf("failed to create file system: %v", err)
@@ -336,10 +785,102 @@ func TestRename(t *testing.T) {
 			if _, err := fs.Stat(fsys, oldFilePath); err == nil {
 				t.Errorf("Original dir file should no longer exist")
 			}
+
+			// sibling with a shared prefix is not moved
+			siblingPath := filepath.Join(base, "oldnested2", "file")
+			if _, err := fs.Stat(fsys, siblingPath); err != nil {
+				t.Errorf("Sibling dir file should not be moved: %v", err)
+			}
+			if _, err := fs.Stat(fsys, filepath.Join(base, "newnested2")); err == nil {
+				t.Errorf("Sibling dir should not be renamed")
+			}
 		})
 	}
 }
 
+func TestMapRenameErrors(t *testing.T) {
+	fsys := wfs.Map(fstest.MapFS{
+		"dir/sub/file": &fstest.MapFile{},
+		"file":         &fstest.MapFile{},
+	})
+	for _, tt := range []struct {
+		oldpath, newpath string
+		err              error
+	}{
+		{"dir", "dir", fs.ErrExist},
+		{"dir", "dir/sub", fs.ErrExist},
+		{"dir", "dir/new", fs.ErrInvalid},
+		{"dir", "file", wfs.ErrNotDir},
+		{"missing", "file/new", wfs.ErrNotDir},
+		{"missing", "nodir/new", fs.ErrNotExist},
+	} {
+		if err := fsys.Rename(tt.oldpath, tt.newpath); !errors.Is(err, tt.err) {
+			t.Errorf("Rename %q %q: expected %v, got %v", tt.oldpath, tt.newpath, tt.err, err)
+		}
+	}
+	// like os.Rename, a file renamed to itself is left as is
+	if err := fsys.Rename("file", "file"); err != nil {
+		t.Errorf("Rename of a file to itself failed: %v", err)
+	}
+	if err := fsys.Remove("."); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Remove of the root: expected ErrInvalid, got %v", err)
+	}
+}
+
+func TestMapErrors(t *testing.T) {
+	fsys := wfs.Map(fstest.MapFS{
+		"dir/file": &fstest.MapFile{Data: []byte("data")},
+		"file":     &fstest.MapFile{Data: []byte("data")},
+	})
+	// paths below a regular file fail with ErrNotDir
+	if _, err := fs.Stat(fsys, "file/child"); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("Stat below a file: expected ErrNotDir, got %v", err)
+	}
+	if err := fsys.Mkdir("file/child", 0755); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("Mkdir below a file: expected ErrNotDir, got %v", err)
+	}
+	if err := fsys.RemoveAll("file/child"); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("RemoveAll below a file: expected ErrNotDir, got %v", err)
+	}
+	if _, err := fs.ReadDir(fsys, "file"); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("ReadDir of a file: expected ErrNotDir, got %v", err)
+	}
+	if _, err := fs.ReadFile(fsys, "dir"); !errors.Is(err, wfs.ErrIsDir) {
+		t.Errorf("ReadFile of a directory: expected ErrIsDir, got %v", err)
+	}
+	// like open(2), directories are not created or truncated
+	for _, flag := range []int{os.O_RDONLY | os.O_CREATE, os.O_RDONLY | os.O_TRUNC} {
+		if _, err := fsys.OpenFile("dir", flag, 0755); !errors.Is(err, wfs.ErrIsDir) {
+			t.Errorf("OpenFile dir %#x: expected ErrIsDir, got %v", flag, err)
+		}
+	}
+
+	// O_TRUNC truncates regardless of the access mode
+	f, err := fsys.OpenFile("file", os.O_RDONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Size() != 0 {
+		t.Errorf("expected file to be truncated, got %v err: %v", info, err)
+	}
+	if n, err := f.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("Read: expected io.EOF, got %d %v", n, err)
+	}
+	if n, err := f.ReadAt(make([]byte, 1), 4); n != 0 || err != io.EOF {
+		t.Errorf("ReadAt past the end: expected io.EOF, got %d %v", n, err)
+	}
+
+	w, err := fsys.OpenFile("dir/file", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer w.Close()
+	if err := w.Truncate(-1); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Truncate to a negative size: expected ErrInvalid, got %v", err)
+	}
+}
+
 func TestRemove(t *testing.T) {
 	for _, tt := range fileSystems {
 		t.Run(tt.name, func(t *testing.T) {
@@ -391,6 +932,8 @@ func TestRemoveAll(t *testing.T) {
 			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
 				"dir/file":        &fstest.MapFile{},
 				"dir/nested/file": &fstest.MapFile{},
+				"dir2/file":       &fstest.MapFile{},
+				"dirfile":         &fstest.MapFile{},
 			})
 			if err != nil {
 				t.Fatalf("failed to create file system: %v", err)
@@ -417,6 +960,13 @@ func TestRemoveAll(t *testing.T) {
 			if _, err := fs.Stat(fsys, nestedDirFilePath); err == nil {
 				t.Errorf("Stat should fail for removed nested directory file")
 			}
+
+			// siblings with a shared prefix are not removed
+			for _, name := range []string{"dir2/file", "dirfile"} {
+				if _, err := fs.Stat(fsys, filepath.Join(base, name)); err != nil {
+					t.Errorf("Stat failed for sibling %q: %v", name, err)
+				}
+			}
 		})
 	}
 }
@@ -464,6 +1014,24 @@ func TestMkdirAll(t *testing.T) {
 			if _, err := fs.Stat(fsys, dirPath); err != nil {
 				t.Errorf("Stat failed for created directory structure: %v", err)
 			}
+
+			parentPath := filepath.Join(base, "parent")
+			info, err := fs.Stat(fsys, parentPath)
+			if err != nil || !info.IsDir() {
+				t.Errorf("expected intermediate directory to be created, got %v err: %v", info, err)
+			}
+			if err := fsys.Mkdir(parentPath, 0755); !errors.Is(err, fs.ErrExist) {
+				t.Errorf("expected Mkdir of intermediate directory to fail with ErrExist, got %v", err)
+			}
+			if err := fsys.Remove(parentPath); err == nil {
+				t.Errorf("expected Remove of non-empty intermediate directory to fail")
+			}
+			if err := fsys.Remove(dirPath); err != nil {
+				t.Errorf("Remove failed for created directory: %v", err)
+			}
+			if err := fsys.Remove(parentPath); err != nil {
+				t.Errorf("Remove failed for intermediate directory: %v", err)
+			}
 		})
 	}
 }
@@ -495,6 +1063,48 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestCreateAll(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"file": &fstest.MapFile{Data: []byte("Hello")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			filePath := filepath.Join(base, "a/b/c/testfile")
+			f, err := wfs.CreateAll(fsys, filePath, 0600)
+			if err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+			if _, err := io.WriteString(f, "data"); err != nil {
+				t.Errorf("failed to write file: %v", err)
+			}
+			f.Close()
+			info, err := fs.Stat(fsys, filePath)
+			if err != nil || info.Size() != 4 || info.Mode().Perm() != 0600 {
+				t.Errorf("expected file with mode 0600, got %v err: %v", info, err)
+			}
+
+			// truncate file
+			f, err = wfs.CreateAll(fsys, filePath, 0600)
+			if err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+			f.Close()
+			if b, err := fs.ReadFile(fsys, filePath); err != nil || len(b) != 0 {
+				t.Errorf("expected file to be truncated, got %q err: %v", b, err)
+			}
+
+			if _, err := wfs.CreateAll(fsys, filepath.Join(base, "file/testfile"), 0600); err == nil {
+				t.Errorf("expected CreateAll to fail below a file")
+			}
+		})
+	}
+}
+
 func TestWriteFile(t *testing.T) {
 	for _, tt := range fileSystems {
 		t.Run(tt.name, func(t *testing.T) {
@@ -529,3 +1139,136 @@ func TestWriteFile(t *testing.T) {
 		})
 	}
 }
+
+func TestAppendFile(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			filePath := filepath.Join(base, "testfile")
+			// create file
+			if err := wfs.AppendFile(fsys, filePath, []byte("Hello"), 0644); err != nil {
+				t.Fatalf("failed to append file: %v", err)
+			}
+			// append to file
+			if err := wfs.AppendFile(fsys, filePath, []byte(", World"), 0644); err != nil {
+				t.Fatalf("failed to append file: %v", err)
+			}
+			f, err := fsys.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			if n, err := io.WriteString(f, "!"); err != nil || n != 1 {
+				t.Errorf("WriteString failed: %d %v", n, err)
+			}
+			f.Close()
+
+			b, err := fs.ReadFile(fsys, filePath)
+			if err != nil || string(b) != "Hello, World!" {
+				t.Errorf("expected 'Hello, World!', got %q err: %v", b, err)
+			}
+		})
+	}
+}
+
+func TestWriteReader(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"testfile": &fstest.MapFile{Data: []byte("previous contents")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			filePath := filepath.Join(base, "testfile")
+			data := bytes.Repeat([]byte("0123456789"), 10000)
+			n, err := wfs.WriteReader(fsys, filePath, bytes.NewReader(data), 0644)
+			if err != nil || n != int64(len(data)) {
+				t.Fatalf("WriteReader failed: %d %v", n, err)
+			}
+			b, err := fs.ReadFile(fsys, filePath)
+			if err != nil || !bytes.Equal(b, data) {
+				t.Errorf("expected %d bytes to be written, got %d err: %v", len(data), len(b), err)
+			}
+
+			// errors from the reader are returned
+			readErr := errors.New("read failed")
+			_, err = wfs.WriteReader(fsys, filePath, io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(readErr)), 0644)
+			if !errors.Is(err, readErr) {
+				t.Errorf("expected reader error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestExistsIsDir(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dir/file": &fstest.MapFile{Data: []byte("Hello")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			tests := []struct {
+				name   string
+				exists bool
+				isDir  bool
+			}{
+				{"dir", true, true},
+				{"dir/file", true, false},
+				{"missing", false, false},
+				{"dir/missing", false, false},
+			}
+			for _, test := range tests {
+				name := filepath.Join(base, test.name)
+				if exists, err := wfs.Exists(fsys, name); err != nil || exists != test.exists {
+					t.Errorf("Exists(%q): expected %v, got %v err: %v", test.name, test.exists, exists, err)
+				}
+				if isDir, err := wfs.IsDir(fsys, name); err != nil || isDir != test.isDir {
+					t.Errorf("IsDir(%q): expected %v, got %v err: %v", test.name, test.isDir, isDir, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTouch(t *testing.T) {
+	past := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"file": &fstest.MapFile{Data: []byte("Hello"), ModTime: past},
+				"dir":  &fstest.MapFile{Mode: fs.ModeDir | 0755, ModTime: past},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			for _, name := range []string{"file", "dir", "created"} {
+				if err := wfs.Touch(fsys, filepath.Join(base, name)); err != nil {
+					t.Fatalf("Touch(%q) failed: %v", name, err)
+				}
+				info, err := fs.Stat(fsys, filepath.Join(base, name))
+				if err != nil || !info.ModTime().After(past) {
+					t.Errorf("expected %q to have a recent modification time, got %v err: %v", name, info, err)
+				}
+			}
+			if b, err := fs.ReadFile(fsys, filepath.Join(base, "file")); err != nil || string(b) != "Hello" {
+				t.Errorf("expected contents to be unchanged, got %q err: %v", b, err)
+			}
+			if b, err := fs.ReadFile(fsys, filepath.Join(base, "created")); err != nil || len(b) != 0 {
+				t.Errorf("expected an empty file, got %q err: %v", b, err)
+			}
+		})
+	}
+}
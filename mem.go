@@ -0,0 +1,870 @@
+package wfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemSys is the backend-specific metadata of files in the in-memory
+// file system returned by [Mem], see [SysInfo].
+type MemSys struct {
+	// Inode uniquely identifies the file within its file system.
+	Inode uint64
+	// Uid and Gid are the owner of the file as set by Chown.
+	Uid, Gid int
+}
+
+// memFs is an in-memory file system made of a tree of directory and file nodes.
+type memFs struct {
+	mu        sync.RWMutex
+	root      *memNode
+	ino       uint64
+	chunkSize int64
+	locks     lockTable
+	watchers  []*watcher
+}
+
+// memNode is a file or directory in a memFs.
+type memNode struct {
+	ino      uint64
+	mode     fs.FileMode
+	modTime  time.Time
+	uid, gid int
+	data     memData
+	children map[string]*memNode
+}
+
+// MemFS is the in-memory file system returned by [Mem].
+type MemFS interface {
+	FS
+
+	// Snapshot encodes the state of every file and directory, so that it
+	// can be restored later or in another MemFS.
+	Snapshot() ([]byte, error)
+
+	// Restore replaces the contents of the file system with a snapshot.
+	// Files that are open keep referring to the replaced contents.
+	Restore(snapshot []byte) error
+
+	// Clone returns an independent copy of the file system.
+	Clone() MemFS
+}
+
+// Mem returns an empty in-memory writable file system.
+//
+// Unlike [Map], Mem keeps an explicit tree of directories and files,
+// directories must exist before entries can be created in them and
+// open files share the same underlying node.
+// Names are slash-separated paths as accepted by [fs.ValidPath].
+func Mem(opts ...MemOption) MemFS {
+	f := &memFs{chunkSize: defaultChunkSize}
+	for _, opt := range opts {
+		opt(f)
+	}
+	f.root = f.newNode(fs.ModeDir | 0777)
+	return f
+}
+
+// MemOption configures the file system returned by [Mem].
+type MemOption func(*memFs)
+
+// ChunkSize sets the size of the chunks file contents are stored in.
+// Appending to a file never copies existing chunks and truncating a file
+// releases whole chunks. The default chunk size is 64 KiB.
+func ChunkSize(size int) MemOption {
+	return func(f *memFs) {
+		if size > 0 {
+			f.chunkSize = int64(size)
+		}
+	}
+}
+
+func (f *memFs) newNode(mode fs.FileMode) *memNode {
+	f.ino++
+	n := &memNode{ino: f.ino, mode: mode, modTime: time.Now()}
+	n.data.chunkSize = f.chunkSize
+	if mode.IsDir() {
+		n.children = make(map[string]*memNode)
+	}
+	return n
+}
+
+// maxSymlinks is the maximum number of symbolic links followed when resolving a name.
+const maxSymlinks = 40
+
+// walk resolves name to its node and the resolved path of the node.
+// Symbolic links are followed in intermediate components, and in the final
+// component if follow is true. If only the final component is missing,
+// walk returns fs.ErrNotExist with the resolved path of the missing entry.
+func (f *memFs) walk(name string, follow bool) (*memNode, string, error) {
+	if name == "." {
+		return f.root, ".", nil
+	}
+	n, resolved := f.root, "."
+	elems := strings.Split(name, "/")
+	links := 0
+	for i := 0; i < len(elems); i++ {
+		if !n.mode.IsDir() {
+			return nil, "", ErrNotDir
+		}
+		elem := elems[i]
+		last := i == len(elems)-1
+		child, ok := n.children[elem]
+		if !ok {
+			if last {
+				return nil, path.Join(resolved, elem), fs.ErrNotExist
+			}
+			return nil, "", fs.ErrNotExist
+		}
+		if child.mode&fs.ModeSymlink != 0 && (!last || follow) {
+			links++
+			if links > maxSymlinks {
+				return nil, "", errLoop
+			}
+			// resolve the target from the root, ".." never escapes the root
+			dest := string(child.data.Bytes())
+			if !path.IsAbs(dest) {
+				dest = path.Join(resolved, dest)
+			}
+			target := path.Join("/", dest, path.Join(elems[i+1:]...))
+			target = strings.TrimPrefix(target, "/")
+			if target == "" {
+				target = "."
+			}
+			if target == "." {
+				elems = nil
+			} else {
+				elems = strings.Split(target, "/")
+			}
+			n, resolved, i = f.root, ".", -1
+			continue
+		}
+		n, resolved = child, path.Join(resolved, elem)
+	}
+	return n, resolved, nil
+}
+
+// lookup returns the node of the named file, following symbolic links.
+func (f *memFs) lookup(name string) (*memNode, error) {
+	n, _, err := f.walk(name, true)
+	return n, err
+}
+
+// lookupParent returns the directory node containing name and the base name.
+// The final component of name is not resolved.
+func (f *memFs) lookupParent(name string) (*memNode, string, error) {
+	dir, elem := path.Split(name)
+	parent, err := f.lookup(path.Clean(dir))
+	if err != nil {
+		return nil, "", err
+	}
+	if !parent.mode.IsDir() {
+		return nil, "", ErrNotDir
+	}
+	return parent, elem, nil
+}
+
+func (f *memFs) Open(name string) (fs.File, error) {
+	file, err := f.openFile("open", name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (f *memFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	file, err := f.openFile("open", name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (f *memFs) openFile(op, name string, flag int, perm fs.FileMode) (*memFile, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, resolved, err := f.walk(name, true)
+	if errors.Is(err, fs.ErrNotExist) && resolved != "" && flag&os.O_CREATE != 0 {
+		// create the missing file, or the missing destination of a symbolic link
+		parent, elem, err := f.lookupParent(resolved)
+		if err != nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		n = f.newNode(perm & fs.ModePerm)
+		parent.children[elem] = n
+		parent.modTime = n.modTime
+		f.emit(Event{Op: OpCreate, Path: resolved})
+	} else if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	} else if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	// like open(2), directories are not created or truncated
+	if n.mode.IsDir() && (writable || flag&(os.O_CREATE|os.O_TRUNC) != 0) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: ErrIsDir}
+	}
+	// like open(2) on Linux, O_TRUNC truncates regardless of the access mode
+	if flag&os.O_TRUNC != 0 && n.data.Len() > 0 {
+		f.emit(Event{Op: OpWrite, Path: name, Size: -n.data.Len()})
+		n.data.Truncate(0)
+		n.modTime = time.Now()
+	}
+	return &memFile{fsys: f, node: n, name: name, flag: flag}, nil
+}
+
+// Stat implements [fs.StatFS] for memFs.
+func (f *memFs) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	n, err := f.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return n.info(path.Base(name)), nil
+}
+
+func (f *memFs) Rename(oldpath, newpath string) error {
+	if !fs.ValidPath(oldpath) || !fs.ValidPath(newpath) || oldpath == "." || newpath == "." {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	oldparent, oldelem, err := f.lookupParent(oldpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	newparent, newelem, err := f.lookupParent(newpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	n, ok := oldparent.children[oldelem]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	// like os.Rename, a directory cannot be renamed to itself
+	if oldpath == newpath && !n.mode.IsDir() {
+		return nil
+	}
+	target, ok := newparent.children[newelem]
+	if ok && target.mode.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrExist}
+	}
+	// a directory cannot be moved into itself
+	if n.mode.IsDir() && strings.HasPrefix(newpath, oldpath+"/") {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	if ok && n.mode.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: ErrNotDir}
+	}
+
+	delete(oldparent.children, oldelem)
+	newparent.children[newelem] = n
+	now := time.Now()
+	oldparent.modTime = now
+	newparent.modTime = now
+	f.emit(Event{Op: OpRename, Path: newpath, OldPath: oldpath})
+	return nil
+}
+
+func (f *memFs) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parent, elem, err := f.lookupParent(name)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	n, ok := parent.children[elem]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if n.mode.IsDir() && len(n.children) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: ErrNotEmpty}
+	}
+	delete(parent.children, elem)
+	parent.modTime = time.Now()
+	f.emit(Event{Op: OpRemove, Path: name, Size: -n.size()})
+	return nil
+}
+
+func (f *memFs) RemoveAll(path string) error {
+	if !fs.ValidPath(path) || path == "." {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: fs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parent, elem, err := f.lookupParent(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// nothing to remove when the parent does not exist
+		return nil
+	} else if err != nil {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: err}
+	}
+	if n, ok := parent.children[elem]; ok {
+		delete(parent.children, elem)
+		parent.modTime = time.Now()
+		f.emit(Event{Op: OpRemove, Path: path, Size: -n.size()})
+	}
+	return nil
+}
+
+func (f *memFs) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if name == "." {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	parent, elem, err := f.lookupParent(name)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if _, ok := parent.children[elem]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	n := f.newNode(fs.ModeDir | perm&fs.ModePerm)
+	parent.children[elem] = n
+	parent.modTime = n.modTime
+	f.emit(Event{Op: OpCreate, Path: name})
+	return nil
+}
+
+func (f *memFs) MkdirAll(path string, perm fs.FileMode) error {
+	if !fs.ValidPath(path) {
+		return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if path == "." {
+		return nil
+	}
+	elems := strings.Split(path, "/")
+	for i := range elems {
+		n, resolved, err := f.walk(strings.Join(elems[:i+1], "/"), true)
+		if errors.Is(err, fs.ErrNotExist) && resolved != "" {
+			parent, elem, err := f.lookupParent(resolved)
+			if err != nil {
+				return &fs.PathError{Op: "mkdir", Path: path, Err: err}
+			}
+			n = f.newNode(fs.ModeDir | perm&fs.ModePerm)
+			parent.children[elem] = n
+			parent.modTime = n.modTime
+			f.emit(Event{Op: OpCreate, Path: resolved})
+		} else if err != nil {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: err}
+		}
+		if !n.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: ErrNotDir}
+		}
+	}
+	return nil
+}
+
+func (f *memFs) Symlink(oldname, newname string) error {
+	if !fs.ValidPath(newname) || newname == "." {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parent, elem, err := f.lookupParent(newname)
+	if err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	if _, ok := parent.children[elem]; ok {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	n := f.newNode(fs.ModeSymlink | 0777)
+	n.data.WriteAt([]byte(oldname), 0)
+	parent.children[elem] = n
+	parent.modTime = n.modTime
+	f.emit(Event{Op: OpCreate, Path: newname})
+	return nil
+}
+
+// Link creates newname as a hard link sharing the node of oldname.
+func (f *memFs) Link(oldname, newname string) error {
+	if !fs.ValidPath(oldname) || !fs.ValidPath(newname) || newname == "." {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, _, err := f.walk(oldname, false)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+	if n.mode.IsDir() {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrPermission}
+	}
+	parent, elem, err := f.lookupParent(newname)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+	if _, ok := parent.children[elem]; ok {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	parent.children[elem] = n
+	parent.modTime = time.Now()
+	f.emit(Event{Op: OpCreate, Path: newname, Size: n.size()})
+	return nil
+}
+
+func (f *memFs) Readlink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	n, _, err := f.walk(name, false)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	if n.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return string(n.data.Bytes()), nil
+}
+
+func (f *memFs) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	n, _, err := f.walk(name, false)
+	if err != nil {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: err}
+	}
+	return n.info(path.Base(name)), nil
+}
+
+func (f *memFs) Chmod(name string, mode fs.FileMode) error {
+	return f.meta("chmod", name, func(n *memNode) {
+		n.mode = n.mode&^chmodMask | mode&chmodMask
+	})
+}
+
+func (f *memFs) Chown(name string, uid, gid int) error {
+	return f.meta("chown", name, func(n *memNode) {
+		if uid != -1 {
+			n.uid = uid
+		}
+		if gid != -1 {
+			n.gid = gid
+		}
+	})
+}
+
+// Chtimes changes the modification time of the named file,
+// access times are not recorded by memFs.
+func (f *memFs) Chtimes(name string, atime, mtime time.Time) error {
+	return f.meta("chtimes", name, func(n *memNode) {
+		if !mtime.IsZero() {
+			n.modTime = mtime
+		}
+	})
+}
+
+// Lock implements [LockFS] for memFs.
+func (f *memFs) Lock(name string) (Unlocker, error) {
+	return f.lock("lock", name, true, true)
+}
+
+// RLock implements [LockFS] for memFs.
+func (f *memFs) RLock(name string) (Unlocker, error) {
+	return f.lock("rlock", name, false, true)
+}
+
+// TryLock implements [LockFS] for memFs.
+func (f *memFs) TryLock(name string) (Unlocker, error) {
+	return f.lock("lock", name, true, false)
+}
+
+// lock locks the node of the named file, so the lock follows the file
+// when it is renamed.
+func (f *memFs) lock(op, name string, exclusive, block bool) (Unlocker, error) {
+	file, err := f.openFile(op, name, os.O_RDONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	return f.locks.lock(op, name, file.node, exclusive, block)
+}
+
+// Watch implements [WatchFS] for memFs, events are generated as changes
+// are made.
+func (f *memFs) Watch(name string, opts WatchOptions) (<-chan Event, func(), error) {
+	if !fs.ValidPath(name) {
+		return nil, nil, &fs.PathError{Op: "watch", Path: name, Err: fs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, _, err := f.walk(name, false); err != nil {
+		return nil, nil, &fs.PathError{Op: "watch", Path: name, Err: err}
+	}
+	w, events := newWatcher(name, opts.Recursive)
+	f.watchers = append(f.watchers, w)
+	stop := func() {
+		f.mu.Lock()
+		f.watchers = slices.DeleteFunc(f.watchers, func(v *watcher) bool { return v == w })
+		f.mu.Unlock()
+		w.stop()
+	}
+	return events, stop, nil
+}
+
+// emit sends e to the watchers it matches, f.mu must be held.
+func (f *memFs) emit(e Event) {
+	if len(f.watchers) == 0 {
+		return
+	}
+	e.Time = time.Now()
+	for _, w := range f.watchers {
+		if w.matches(e) {
+			w.send(e)
+		}
+	}
+}
+
+// meta applies change to the node of the named file following symbolic links.
+func (f *memFs) meta(op, name string, change func(n *memNode)) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.lookup(name)
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	change(n)
+	f.emit(Event{Op: OpChmod, Path: name})
+	return nil
+}
+
+// size returns the size of a regular file node, or 0 otherwise.
+func (n *memNode) size() int64 {
+	if !n.mode.IsRegular() {
+		return 0
+	}
+	return n.data.Len()
+}
+
+func (n *memNode) info(name string) fs.FileInfo {
+	return &memFileInfo{
+		name:    name,
+		size:    n.data.Len(),
+		mode:    n.mode,
+		modTime: n.modTime,
+		sys:     &MemSys{Inode: n.ino, Uid: n.uid, Gid: n.gid},
+	}
+}
+
+// entries returns the children of a directory node sorted by name.
+func (n *memNode) entries() []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for name, child := range n.children {
+		entries = append(entries, fs.FileInfoToDirEntry(child.info(name)))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries
+}
+
+// memFileInfo is a point-in-time description of a memNode.
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	sys     any
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() any           { return i.sys }
+
+// memFile is an open handle to a memNode.
+type memFile struct {
+	fsys    *memFs
+	node    *memNode
+	name    string
+	flag    int
+	offset  int64
+	closed  bool
+	entries []fs.DirEntry // directory listing captured on the first ReadDir
+}
+
+// check returns an error if the file is closed or is missing the access mode of flag.
+func (f *memFile) check(op string, flag int) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	switch flag {
+	case os.O_RDONLY:
+		if f.flag&os.O_WRONLY != 0 {
+			return &fs.PathError{Op: op, Path: f.name, Err: errBadFile}
+		}
+	case os.O_WRONLY:
+		if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+			return &fs.PathError{Op: op, Path: f.name, Err: errBadFile}
+		}
+	}
+	if f.node.mode.IsDir() && op != "readdir" && op != "seek" {
+		return &fs.PathError{Op: op, Path: f.name, Err: ErrIsDir}
+	}
+	return nil
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	f.fsys.mu.RLock()
+	defer f.fsys.mu.RUnlock()
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	return f.node.info(path.Base(f.name)), nil
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("read", os.O_RDONLY); err != nil {
+		return 0, err
+	}
+	n, err := f.readAt(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) ReadAt(b []byte, off int64) (int, error) {
+	f.fsys.mu.RLock()
+	defer f.fsys.mu.RUnlock()
+	if err := f.check("read", os.O_RDONLY); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.name, Err: errors.New("negative offset")}
+	}
+	n, err := f.readAt(b, off)
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *memFile) readAt(b []byte, off int64) (int, error) {
+	if off >= f.node.data.Len() {
+		if len(b) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	return f.node.data.ReadAt(b, off), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("seek", -1); err != nil {
+		return 0, err
+	}
+	if f.node.mode.IsDir() {
+		if offset != 0 || whence != io.SeekStart {
+			return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+		}
+		f.entries = nil
+		return 0, nil
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.node.data.Len()
+	case io.SeekStart:
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("write", os.O_WRONLY); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = f.node.data.Len()
+	}
+	n := f.writeAt(b, f.offset)
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *memFile) WriteAt(b []byte, off int64) (int, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("write", os.O_WRONLY); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		return 0, errors.New("invalid use of WriteAt on file opened with O_APPEND")
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "writeat", Path: f.name, Err: errors.New("negative offset")}
+	}
+	return f.writeAt(b, off), nil
+}
+
+func (f *memFile) writeAt(b []byte, off int64) int {
+	size := f.node.data.Len()
+	n := f.node.data.WriteAt(b, off)
+	f.node.modTime = time.Now()
+	if n > 0 {
+		f.fsys.emit(Event{Op: OpWrite, Path: f.name, Size: f.node.data.Len() - size})
+	}
+	return n
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.node.mode.IsDir() || f.flag&(os.O_WRONLY|os.O_RDWR) == 0 || size < 0 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
+	}
+	old := f.node.data.Len()
+	f.node.data.Truncate(size)
+	f.node.modTime = time.Now()
+	f.fsys.emit(Event{Op: OpWrite, Path: f.name, Size: size - old})
+	return nil
+}
+
+// Sync does nothing as memFs has no stable storage.
+func (f *memFile) Sync() error {
+	f.fsys.mu.RLock()
+	defer f.fsys.mu.RUnlock()
+	if f.closed {
+		return &fs.PathError{Op: "sync", Path: f.name, Err: fs.ErrClosed}
+	}
+	return nil
+}
+
+// ReadDir implements [fs.ReadDirFile] for directories.
+// The listing is captured on the first call, so the directory may be
+// modified while it is being read.
+func (f *memFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if err := f.check("readdir", -1); err != nil {
+		return nil, err
+	}
+	if !f.node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: ErrNotDir}
+	}
+	if f.entries == nil {
+		f.entries = f.node.entries()
+	}
+	entries := f.entries
+	if count > 0 && len(entries) > count {
+		entries = entries[:count]
+	}
+	f.entries = f.entries[len(entries):]
+	if count > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	return entries, nil
+}
+
+func (f *memFile) Close() error {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+// ReadDir implements [fs.ReadDirFS] for memFs.
+func (f *memFs) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	n, err := f.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if !n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrNotDir}
+	}
+	return n.entries(), nil
+}
+
+// ReadFile implements [fs.ReadFileFS] for memFs.
+func (f *memFs) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	n, err := f.lookup(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	if n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: ErrIsDir}
+	}
+	return n.data.Bytes(), nil
+}
+
+// Sub implements [fs.SubFS] for memFs, the returned file system is writable.
+func (f *memFs) Sub(dir string) (fs.FS, error) {
+	return Sub(f, dir)
+}
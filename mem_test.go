@@ -0,0 +1,247 @@
+package wfs_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestMemFS(t *testing.T) {
+	fsys := wfs.Mem()
+	if err := fsys.MkdirAll("dir/nested", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	for _, name := range []string{"file", "dir/file", "dir/nested/file"} {
+		if err := wfs.WriteFile(fsys, name, []byte(name), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	if err := fstest.TestFS(fsys, "file", "dir/file", "dir/nested/file"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemParentDirectory(t *testing.T) {
+	fsys := wfs.Mem()
+	if f, err := fsys.OpenFile("missing/file", os.O_WRONLY|os.O_CREATE, 0644); f != nil || err == nil {
+		t.Errorf("expected a nil file and an error, got %#v %v", f, err)
+	}
+	if f, err := fsys.Open("missing"); f != nil || err == nil {
+		t.Errorf("expected a nil file and an error, got %#v %v", f, err)
+	}
+	if err := wfs.WriteFile(fsys, "missing/file", nil, 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected create under missing directory to fail with ErrNotExist, got %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "file", nil, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "file/child", nil, 0644); err == nil {
+		t.Errorf("expected create under a file to fail")
+	}
+	if err := fsys.MkdirAll("file/dir", 0755); err == nil {
+		t.Errorf("expected MkdirAll through a file to fail")
+	}
+
+	if err := fsys.MkdirAll("a/b/c", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	for _, name := range []string{"a", "a/b", "a/b/c"} {
+		info, err := fs.Stat(fsys, name)
+		if err != nil || !info.IsDir() {
+			t.Errorf("expected %q to be a directory, got %v err: %v", name, info, err)
+		}
+	}
+	if err := fsys.Remove("a/b"); err == nil {
+		t.Errorf("expected Remove of non-empty intermediate directory to fail")
+	}
+	if err := fsys.Remove("a/b/c"); err != nil {
+		t.Errorf("Remove of empty directory failed: %v", err)
+	}
+}
+
+func TestMemFollowsOpen(t *testing.T) {
+	fsys := wfs.Mem()
+	if err := fsys.MkdirAll("dir/sub", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "file", []byte("data"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// like open(2), directories are not created or truncated
+	for _, flag := range []int{os.O_RDONLY | os.O_CREATE, os.O_RDONLY | os.O_TRUNC} {
+		if _, err := fsys.OpenFile("dir", flag, 0755); !errors.Is(err, wfs.ErrIsDir) {
+			t.Errorf("OpenFile dir %#x: expected ErrIsDir, got %v", flag, err)
+		}
+	}
+	// O_TRUNC truncates regardless of the access mode
+	f, err := fsys.OpenFile("file", os.O_RDONLY|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.Close()
+	if info, err := fs.Stat(fsys, "file"); err != nil || info.Size() != 0 {
+		t.Errorf("expected file to be truncated, got %v err: %v", info, err)
+	}
+
+	// errors of the file on a directory are reported before ErrIsDir
+	d, err := fsys.OpenFile("dir", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer d.Close()
+	if _, err := d.Write([]byte("x")); errors.Is(err, wfs.ErrIsDir) {
+		t.Errorf("expected write to a read-only directory handle not to fail with ErrIsDir")
+	}
+
+	for _, tt := range []struct {
+		oldpath, newpath string
+		err              error
+	}{
+		{"dir", "dir", fs.ErrExist},
+		{"dir", "dir/sub", fs.ErrExist},
+		{"dir", "dir/new", fs.ErrInvalid},
+		{"dir", "file", wfs.ErrNotDir},
+		{"missing", "file/new", wfs.ErrNotDir},
+	} {
+		if err := fsys.Rename(tt.oldpath, tt.newpath); !errors.Is(err, tt.err) {
+			t.Errorf("Rename %q %q: expected %v, got %v", tt.oldpath, tt.newpath, tt.err, err)
+		}
+	}
+	if err := fsys.Rename("file", "file"); err != nil {
+		t.Errorf("Rename of a file to itself failed: %v", err)
+	}
+	if err := fsys.RemoveAll("file/child"); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("RemoveAll below a file: expected ErrNotDir, got %v", err)
+	}
+	if err := fsys.RemoveAll("missing/child"); err != nil {
+		t.Errorf("RemoveAll below a missing directory failed: %v", err)
+	}
+}
+
+func TestMemReadDirMutation(t *testing.T) {
+	fsys := wfs.Mem()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := wfs.WriteFile(fsys, name, nil, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	dir, err := fsys.Open(".")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dir.Close()
+	rd := dir.(fs.ReadDirFile)
+
+	var names []string
+	for {
+		entries, err := rd.ReadDir(1)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadDir failed: %v", err)
+		}
+		names = append(names, entries[0].Name())
+		// remove every entry while iterating
+		if err := fsys.Remove(entries[0].Name()); err != nil {
+			t.Fatalf("Remove failed: %v", err)
+		}
+	}
+	if len(names) != 3 {
+		t.Errorf("expected to list 3 entries, got %v", names)
+	}
+}
+
+func TestMemSharedHandles(t *testing.T) {
+	fsys := wfs.Mem()
+	w, err := fsys.OpenFile("file", os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer w.Close()
+	r, err := fsys.OpenFile("file", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer r.Close()
+
+	io.WriteString(w, "Hello")
+	b, err := io.ReadAll(r)
+	if err != nil || string(b) != "Hello" {
+		t.Errorf("expected reader to see 'Hello', got %q err: %v", b, err)
+	}
+
+	info, err := r.Stat()
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	sys, ok := wfs.SysInfo[*wfs.MemSys](info)
+	if !ok || sys.Inode == 0 {
+		t.Errorf("expected MemSys with inode, got %v", info.Sys())
+	}
+}
+
+func TestMemChunks(t *testing.T) {
+	fsys := wfs.Mem(wfs.ChunkSize(4))
+	f, err := wfs.Create(fsys, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// compare every operation against a plain byte slice
+	var want []byte
+	pos := 0
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := range 500 {
+		switch r.IntN(3) {
+		case 0:
+			off := r.IntN(len(want) + 8)
+			b := make([]byte, r.IntN(10))
+			for j := range b {
+				b[j] = byte('a' + r.IntN(26))
+			}
+			if _, err := f.WriteAt(b, int64(off)); err != nil {
+				t.Fatalf("WriteAt failed: %v", err)
+			}
+			if end := off + len(b); end > len(want) {
+				want = append(want, make([]byte, end-len(want))...)
+			}
+			copy(want[off:], b)
+		case 1:
+			size := r.IntN(len(want) + 8)
+			if err := f.Truncate(int64(size)); err != nil {
+				t.Fatalf("Truncate failed: %v", err)
+			}
+			if size > len(want) {
+				want = append(want, make([]byte, size-len(want))...)
+			}
+			want = want[:size]
+		case 2:
+			if _, err := f.Write([]byte("xyz")); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if end := pos + 3; end > len(want) {
+				want = append(want, make([]byte, end-len(want))...)
+			}
+			copy(want[pos:], "xyz")
+			pos += 3
+		}
+		got, err := fs.ReadFile(fsys, "file")
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("step %d: expected %q, got %q err: %v", i, want, got, err)
+		}
+		if info, _ := f.Stat(); info.Size() != int64(len(want)) {
+			t.Fatalf("step %d: expected size %d, got %d", i, len(want), info.Size())
+		}
+	}
+}
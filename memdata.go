@@ -0,0 +1,110 @@
+package wfs
+
+import "slices"
+
+// defaultChunkSize is the chunk size used by [Mem] unless [ChunkSize] is given.
+const defaultChunkSize = 64 << 10
+
+// memData is the contents of a file in a memFs stored as a list of chunks,
+// so that appending never copies existing data and truncating only drops
+// whole chunks.
+//
+// Chunk i holds the bytes in [i*chunkSize, (i+1)*chunkSize). A chunk may be
+// shorter than chunkSize or nil, the missing bytes up to size read as zeros.
+type memData struct {
+	chunkSize int64
+	chunks    [][]byte
+	size      int64
+}
+
+// Len returns the size of the data.
+func (d *memData) Len() int64 {
+	return d.size
+}
+
+// ReadAt copies the data at off into b and returns the number of bytes copied.
+func (d *memData) ReadAt(b []byte, off int64) int {
+	if off >= d.size {
+		return 0
+	}
+	b = b[:min(int64(len(b)), d.size-off)]
+	for n := 0; n < len(b); {
+		i, o := (off+int64(n))/d.chunkSize, (off+int64(n))%d.chunkSize
+		m := min(int64(len(b)-n), d.chunkSize-o)
+		chunk := d.chunks[i]
+		copied := 0
+		if o < int64(len(chunk)) {
+			copied = copy(b[n:n+int(m)], chunk[o:])
+		}
+		clear(b[n+copied : n+int(m)])
+		n += int(m)
+	}
+	return len(b)
+}
+
+// WriteAt copies b into the data at off, growing it if necessary.
+func (d *memData) WriteAt(b []byte, off int64) int {
+	if end := off + int64(len(b)); end > d.size {
+		d.grow(end)
+	}
+	for n := 0; n < len(b); {
+		i, o := (off+int64(n))/d.chunkSize, (off+int64(n))%d.chunkSize
+		m := min(int64(len(b)-n), d.chunkSize-o)
+		chunk := d.chunks[i]
+		if end := o + m; end > int64(len(chunk)) {
+			// extend the chunk with zeros up to the end of the write
+			chunk = append(chunk, make([]byte, end-int64(len(chunk)))...)
+			d.chunks[i] = chunk
+		}
+		copy(chunk[o:o+m], b[n:])
+		n += int(m)
+	}
+	return len(b)
+}
+
+// Truncate changes the size of the data, growing reads zeros.
+func (d *memData) Truncate(size int64) {
+	if size >= d.size {
+		d.grow(size)
+		return
+	}
+	n := (size + d.chunkSize - 1) / d.chunkSize
+	clear(d.chunks[n:])
+	d.chunks = d.chunks[:n]
+	if n > 0 {
+		// drop the bytes of the last chunk beyond size
+		// so that growing the data again reads zeros
+		last := size - (n-1)*d.chunkSize
+		if chunk := d.chunks[n-1]; int64(len(chunk)) > last {
+			d.chunks[n-1] = chunk[:last]
+		}
+	}
+	d.size = size
+}
+
+// grow extends the data to size with chunks that read as zeros.
+func (d *memData) grow(size int64) {
+	n := (size + d.chunkSize - 1) / d.chunkSize
+	for int64(len(d.chunks)) < n {
+		d.chunks = append(d.chunks, nil)
+	}
+	d.size = size
+}
+
+// Bytes returns a copy of the data.
+func (d *memData) Bytes() []byte {
+	b := make([]byte, d.size)
+	d.ReadAt(b, 0)
+	return b
+}
+
+// clone returns a copy of the data that shares no chunks with d.
+func (d *memData) clone() memData {
+	c := memData{chunkSize: d.chunkSize, chunks: make([][]byte, len(d.chunks)), size: d.size}
+	for i, chunk := range d.chunks {
+		if chunk != nil {
+			c.chunks[i] = slices.Clone(chunk)
+		}
+	}
+	return c
+}
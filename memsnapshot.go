@@ -0,0 +1,145 @@
+package wfs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// memSnapshotVersion is the version of the snapshot encoding.
+const memSnapshotVersion = 1
+
+var errBadSnapshot = errors.New("invalid snapshot")
+
+// memSnapshot is the encoded state of a memFs. Nodes are stored once so that
+// hard links are preserved, the root is node 0.
+type memSnapshot struct {
+	Version int
+	Ino     uint64
+	Nodes   []memSnapshotNode
+}
+
+type memSnapshotNode struct {
+	Ino      uint64
+	Mode     fs.FileMode
+	ModTime  time.Time
+	Uid, Gid int
+	Data     []byte
+	Children map[string]int
+}
+
+// Snapshot implements [MemFS] for memFs.
+func (f *memFs) Snapshot() ([]byte, error) {
+	f.mu.RLock()
+	snapshot := memSnapshot{Version: memSnapshotVersion, Ino: f.ino}
+	index := make(map[*memNode]int)
+	var add func(n *memNode) int
+	add = func(n *memNode) int {
+		if i, ok := index[n]; ok {
+			return i
+		}
+		i := len(snapshot.Nodes)
+		index[n] = i
+		snapshot.Nodes = append(snapshot.Nodes, memSnapshotNode{
+			Ino: n.ino, Mode: n.mode, ModTime: n.modTime, Uid: n.uid, Gid: n.gid, Data: n.data.Bytes(),
+		})
+		if n.children != nil {
+			children := make(map[string]int, len(n.children))
+			for name, child := range n.children {
+				children[name] = add(child)
+			}
+			snapshot.Nodes[i].Children = children
+		}
+		return i
+	}
+	add(f.root)
+	f.mu.RUnlock()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snapshot); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeMemSnapshot decodes a snapshot and checks that its nodes form a tree.
+func decodeMemSnapshot(data []byte) (*memSnapshot, error) {
+	var snapshot memSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snapshot); err != nil {
+		return nil, errors.Join(errBadSnapshot, err)
+	}
+	if snapshot.Version != memSnapshotVersion || len(snapshot.Nodes) == 0 || !snapshot.Nodes[0].Mode.IsDir() {
+		return nil, errBadSnapshot
+	}
+	linked := make([]bool, len(snapshot.Nodes))
+	for _, sn := range snapshot.Nodes {
+		for name, child := range sn.Children {
+			if !sn.Mode.IsDir() || child <= 0 || child >= len(snapshot.Nodes) ||
+				!fs.ValidPath(name) || name == "." || strings.Contains(name, "/") {
+				return nil, errBadSnapshot
+			}
+			// directories have a single parent, which rules out cycles
+			if snapshot.Nodes[child].Mode.IsDir() {
+				if linked[child] {
+					return nil, errBadSnapshot
+				}
+				linked[child] = true
+			}
+		}
+	}
+	return &snapshot, nil
+}
+
+// Restore implements [MemFS] for memFs.
+func (f *memFs) Restore(data []byte) error {
+	snapshot, err := decodeMemSnapshot(data)
+	if err != nil {
+		return err
+	}
+	nodes := make([]*memNode, len(snapshot.Nodes))
+	for i, sn := range snapshot.Nodes {
+		n := &memNode{ino: sn.Ino, mode: sn.Mode, modTime: sn.ModTime, uid: sn.Uid, gid: sn.Gid}
+		n.data.chunkSize = f.chunkSize
+		n.data.WriteAt(sn.Data, 0)
+		if sn.Mode.IsDir() {
+			n.children = make(map[string]*memNode, len(sn.Children))
+		}
+		nodes[i] = n
+	}
+	for i, sn := range snapshot.Nodes {
+		for name, child := range sn.Children {
+			nodes[i].children[name] = nodes[child]
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.root = nodes[0]
+	f.ino = snapshot.Ino
+	return nil
+}
+
+// Clone implements [MemFS] for memFs.
+func (f *memFs) Clone() MemFS {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	clones := make(map[*memNode]*memNode)
+	var clone func(n *memNode) *memNode
+	clone = func(n *memNode) *memNode {
+		if c, ok := clones[n]; ok {
+			return c
+		}
+		c := &memNode{ino: n.ino, mode: n.mode, modTime: n.modTime, uid: n.uid, gid: n.gid, data: n.data.clone()}
+		clones[n] = c
+		if n.children != nil {
+			c.children = make(map[string]*memNode, len(n.children))
+			for name, child := range n.children {
+				c.children[name] = clone(child)
+			}
+		}
+		return c
+	}
+	return &memFs{root: clone(f.root), ino: f.ino, chunkSize: f.chunkSize}
+}
@@ -0,0 +1,64 @@
+package wfs_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestMemSnapshot(t *testing.T) {
+	fsys := wfs.Mem(wfs.ChunkSize(4))
+	if err := fsys.MkdirAll("dir/sub", 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "dir/file", []byte("Hello, World!"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := wfs.Link(fsys, "dir/file", "dir/sub/link"); err != nil {
+		t.Fatalf("failed to link file: %v", err)
+	}
+	if err := wfs.Symlink(fsys, "../file", "dir/sub/symlink"); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	snapshot, err := fsys.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	clone := fsys.Clone()
+
+	if err := fsys.RemoveAll("dir"); err != nil {
+		t.Fatalf("failed to remove directory: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "other", nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	restored := wfs.Mem()
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if err := fsys.Restore(snapshot); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	for name, m := range map[string]wfs.MemFS{"restored": restored, "reset": fsys, "clone": clone} {
+		t.Run(name, func(t *testing.T) {
+			assertEntries(t, m, ".", "dir")
+			assertEntries(t, m, "dir", "file", "sub")
+			assertContent(t, m, "dir/sub/symlink", "Hello, World!")
+			info, err := fs.Stat(m, "dir/file")
+			if err != nil || info.Mode() != 0600 {
+				t.Errorf("expected mode 0600, got %v err: %v", info.Mode(), err)
+			}
+			// hard links still share their contents
+			if err := wfs.WriteFile(m, "dir/sub/link", []byte("Bye"), 0600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			assertContent(t, m, "dir/file", "Bye")
+		})
+	}
+
+	if err := fsys.Restore([]byte("not a snapshot")); err == nil {
+		t.Errorf("expected Restore to fail for invalid data")
+	}
+}
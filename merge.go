@@ -0,0 +1,228 @@
+package wfs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"maps"
+	"path"
+	"slices"
+)
+
+// Snapshot is the encoded state of a [MemFS], as returned by
+// [MemFS.Snapshot] and accepted by [MemFS.Restore].
+type Snapshot []byte
+
+// Conflict is a path changed differently in ours and in theirs by [Merge].
+type Conflict struct {
+	Path string
+
+	// Err is the error returned by the content merger of the file, or nil
+	// if no content merger applies to the changes.
+	Err error
+}
+
+// ContentMerger merges the contents of a regular file named name that was
+// changed differently in ours and in theirs. base is nil if the file is not
+// a regular file in base. Returning an error reports the file as a conflict.
+type ContentMerger func(name string, base, ours, theirs []byte) ([]byte, error)
+
+// MergeOption configures [Merge].
+type MergeOption func(*mergeOptions)
+
+type mergeOptions struct {
+	patterns []string
+	mergers  []ContentMerger
+}
+
+// MergeContent merges the regular files whose base name matches pattern,
+// as reported by [path.Match], with merger. Mergers are tried in the order
+// they are given.
+func MergeContent(pattern string, merger ContentMerger) MergeOption {
+	return func(o *mergeOptions) {
+		o.patterns = append(o.patterns, pattern)
+		o.mergers = append(o.mergers, merger)
+	}
+}
+
+// merger returns the content merger of the named file, or nil.
+func (o *mergeOptions) merger(name string) ContentMerger {
+	for i, pattern := range o.patterns {
+		if ok, _ := path.Match(pattern, path.Base(name)); ok {
+			return o.mergers[i]
+		}
+	}
+	return nil
+}
+
+// Merge performs a three-way merge of the snapshots ours and theirs, which
+// both derive from base, and returns the merged snapshot and the paths that
+// could not be merged.
+//
+// Files are compared by mode, owner and contents, ignoring modification
+// times. A path changed on one side only takes the version of that side, and
+// a directory on both sides is merged entry by entry. A regular file changed
+// differently on both sides is merged by the first content merger of its
+// name, every other path changed differently on both sides is a conflict,
+// including a directory removed on one side and changed below on the other.
+// The merged snapshot keeps the version of ours for conflicts.
+//
+// Hard links are preserved when all names of a file come from the same
+// side, and inode numbers are reassigned. If a snapshot cannot be decoded,
+// Merge returns a nil snapshot and a single conflict for "." with the error.
+func Merge(base, ours, theirs Snapshot, opts ...MergeOption) (Snapshot, []Conflict) {
+	m := &merger{copied: make(map[*memSnapshotNode]int)}
+	for _, opt := range opts {
+		opt(&m.opts)
+	}
+	var err error
+	for _, s := range []struct {
+		data Snapshot
+		dst  **memSnapshot
+	}{{base, &m.base}, {ours, &m.ours}, {theirs, &m.theirs}} {
+		if *s.dst, err = decodeMemSnapshot(s.data); err != nil {
+			return nil, []Conflict{{Path: ".", Err: err}}
+		}
+	}
+
+	m.out.Version = memSnapshotVersion
+	m.merge(".", &m.base.Nodes[0], &m.ours.Nodes[0], &m.theirs.Nodes[0])
+	for i := range m.out.Nodes {
+		m.out.Nodes[i].Ino = uint64(i + 1)
+	}
+	m.out.Ino = uint64(len(m.out.Nodes))
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(m.out); err != nil {
+		return nil, []Conflict{{Path: ".", Err: err}}
+	}
+	return buf.Bytes(), m.conflicts
+}
+
+// merger builds the merged snapshot of a [Merge].
+type merger struct {
+	opts               mergeOptions
+	base, ours, theirs *memSnapshot
+	out                memSnapshot
+	copied             map[*memSnapshotNode]int // the nodes copied from ours or theirs
+	conflicts          []Conflict
+}
+
+// merge adds the merge of the nodes named name to the merged snapshot and
+// returns its index, or -1 if it is removed. Missing nodes are nil.
+func (m *merger) merge(name string, b, o, t *memSnapshotNode) int {
+	if o != nil && t != nil && o.Mode.IsDir() && t.Mode.IsDir() {
+		n := *o
+		if sameMeta(b, o) {
+			n = *t
+		}
+		n.Children = make(map[string]int)
+		i := m.add(n)
+		names := make(map[string]bool)
+		for _, dir := range []*memSnapshotNode{b, o, t} {
+			if dir != nil {
+				for child := range dir.Children {
+					names[child] = true
+				}
+			}
+		}
+		for _, child := range slices.Sorted(maps.Keys(names)) {
+			j := m.merge(path.Join(name, child), m.base.child(b, child), m.ours.child(o, child), m.theirs.child(t, child))
+			if j >= 0 {
+				m.out.Nodes[i].Children[child] = j
+			}
+		}
+		return i
+	}
+
+	switch {
+	case sameTree(m.ours, o, m.theirs, t), sameTree(m.base, b, m.theirs, t):
+		return m.copy(m.ours, o)
+	case sameTree(m.base, b, m.ours, o):
+		return m.copy(m.theirs, t)
+	}
+	if o != nil && t != nil && o.Mode.IsRegular() && t.Mode.IsRegular() {
+		if merge := m.opts.merger(name); merge != nil {
+			var baseData []byte
+			if b != nil && b.Mode.IsRegular() {
+				baseData = b.Data
+			}
+			data, err := merge(name, baseData, o.Data, t.Data)
+			if err == nil {
+				n := *o
+				n.Data = data
+				if t.ModTime.After(n.ModTime) {
+					n.ModTime = t.ModTime
+				}
+				return m.add(n)
+			}
+			m.conflicts = append(m.conflicts, Conflict{Path: name, Err: err})
+			return m.copy(m.ours, o)
+		}
+	}
+	m.conflicts = append(m.conflicts, Conflict{Path: name})
+	return m.copy(m.ours, o)
+}
+
+// add appends n to the merged snapshot and returns its index.
+func (m *merger) add(n memSnapshotNode) int {
+	m.out.Nodes = append(m.out.Nodes, n)
+	return len(m.out.Nodes) - 1
+}
+
+// copy adds n of s and the nodes below it to the merged snapshot unless
+// they were added already and returns its index, or -1 if n is nil.
+func (m *merger) copy(s *memSnapshot, n *memSnapshotNode) int {
+	if n == nil {
+		return -1
+	}
+	if i, ok := m.copied[n]; ok {
+		return i
+	}
+	c := *n
+	c.Children = nil
+	i := m.add(c)
+	m.copied[n] = i
+	if n.Mode.IsDir() {
+		children := make(map[string]int, len(n.Children))
+		for name, j := range n.Children {
+			children[name] = m.copy(s, &s.Nodes[j])
+		}
+		m.out.Nodes[i].Children = children
+	}
+	return i
+}
+
+// child returns the entry name of the directory dir of s, or nil.
+func (s *memSnapshot) child(dir *memSnapshotNode, name string) *memSnapshotNode {
+	if dir == nil {
+		return nil
+	}
+	i, ok := dir.Children[name]
+	if !ok {
+		return nil
+	}
+	return &s.Nodes[i]
+}
+
+// sameMeta reports whether a and b have the same mode and owner.
+func sameMeta(a, b *memSnapshotNode) bool {
+	return a != nil && b != nil && a.Mode == b.Mode && a.Uid == b.Uid && a.Gid == b.Gid
+}
+
+// sameTree reports whether the node a of as and the node b of bs have the
+// same metadata and contents, and for directories the same entries.
+func sameTree(as *memSnapshot, a *memSnapshotNode, bs *memSnapshot, b *memSnapshotNode) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !sameMeta(a, b) || !bytes.Equal(a.Data, b.Data) || len(a.Children) != len(b.Children) {
+		return false
+	}
+	for name, i := range a.Children {
+		j, ok := b.Children[name]
+		if !ok || !sameTree(as, &as.Nodes[i], bs, &bs.Nodes[j]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package wfs_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/wfstest"
+)
+
+func TestMerge(t *testing.T) {
+	base := wfs.Mem()
+	if err := wfstest.Write(base, fstest.MapFS{
+		"changed":   &fstest.MapFile{Data: []byte("base")},
+		"removed":   &fstest.MapFile{Data: []byte("base")},
+		"both":      &fstest.MapFile{Data: []byte("base")},
+		"notes.txt": &fstest.MapFile{Data: []byte("base\n")},
+		"bad.txt":   &fstest.MapFile{Data: []byte("base\n")},
+		"dir/file":  &fstest.MapFile{Data: []byte("base")},
+		"gone/file": &fstest.MapFile{Data: []byte("base")},
+		"same/file": &fstest.MapFile{Data: []byte("base")},
+	}); err != nil {
+		t.Fatalf("failed to write base: %v", err)
+	}
+	ours, theirs := base.Clone(), base.Clone()
+
+	wfs.WriteFile(ours, "changed", []byte("ours"), 0644)
+	wfs.WriteFile(ours, "both", []byte("ours"), 0644)
+	wfs.AppendFile(ours, "notes.txt", []byte("ours\n"), 0644)
+	wfs.AppendFile(ours, "bad.txt", []byte("ours\n"), 0644)
+	wfs.WriteFile(ours, "dir/ours", []byte("ours"), 0644)
+	ours.RemoveAll("gone")
+	wfs.WriteFile(ours, "same/new", []byte("new"), 0644)
+
+	theirs.Remove("removed")
+	wfs.WriteFile(theirs, "both", []byte("theirs"), 0644)
+	wfs.AppendFile(theirs, "notes.txt", []byte("theirs\n"), 0644)
+	wfs.AppendFile(theirs, "bad.txt", []byte("theirs\n"), 0644)
+	wfs.WriteFile(theirs, "dir/theirs", []byte("theirs"), 0644)
+	wfs.WriteFile(theirs, "gone/file", []byte("theirs"), 0644)
+	wfs.WriteFile(theirs, "same/new", []byte("new"), 0644)
+
+	snapshot := func(m wfs.MemFS) wfs.Snapshot {
+		s, err := m.Snapshot()
+		if err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		return s
+	}
+	errAppend := errors.New("cannot merge")
+	appendLines := func(name string, base, ours, theirs []byte) ([]byte, error) {
+		if name == "bad.txt" {
+			return nil, errAppend
+		}
+		return append(slices.Clip(ours), bytes.TrimPrefix(theirs, base)...), nil
+	}
+	merged, conflicts := wfs.Merge(snapshot(base), snapshot(ours), snapshot(theirs), wfs.MergeContent("*.txt", appendLines))
+
+	expected := []wfs.Conflict{{Path: "bad.txt", Err: errAppend}, {Path: "both"}, {Path: "gone"}}
+	if !slices.Equal(conflicts, expected) {
+		t.Errorf("expected conflicts %v, got %v", expected, conflicts)
+	}
+	m := wfs.Mem()
+	if err := m.Restore(merged); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	assertEntries(t, m, ".", "bad.txt", "both", "changed", "dir", "notes.txt", "same")
+	assertContent(t, m, "changed", "ours")
+	assertContent(t, m, "both", "ours")
+	assertContent(t, m, "notes.txt", "base\nours\ntheirs\n")
+	assertContent(t, m, "bad.txt", "base\nours\n")
+	assertEntries(t, m, "dir", "file", "ours", "theirs")
+	assertEntries(t, m, "same", "file", "new")
+	if _, err := fs.Stat(m, "removed"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected removed file to be removed, got %v", err)
+	}
+
+	if _, conflicts := wfs.Merge(snapshot(base), []byte("not a snapshot"), snapshot(theirs)); len(conflicts) != 1 || conflicts[0].Path != "." || conflicts[0].Err == nil {
+		t.Errorf("expected a conflict for an invalid snapshot, got %v", conflicts)
+	}
+}
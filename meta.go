@@ -0,0 +1,62 @@
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// MetaFS is the interface implemented by a file system
+// that can change file metadata.
+type MetaFS interface {
+	FS
+
+	// Chmod changes the mode of the named file to mode.
+	// If the file is a symbolic link, it changes the mode of the link's target.
+	// If there is an error, it will be of type [*fs.PathError].
+	Chmod(name string, mode fs.FileMode) error
+
+	// Chown changes the numeric uid and gid of the named file.
+	// If the file is a symbolic link, it changes the uid and gid of the link's target.
+	// A uid or gid of -1 means to not change that value.
+	// If there is an error, it will be of type [*fs.PathError].
+	Chown(name string, uid, gid int) error
+
+	// Chtimes changes the access and modification times of the named file.
+	// A zero [time.Time] value will leave the corresponding file time unchanged.
+	// If there is an error, it will be of type [*fs.PathError].
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// chmodMask is the set of mode bits that can be changed by Chmod.
+const chmodMask = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// Chmod changes the mode of the named file in fsys.
+// If fsys does not implement [MetaFS], Chmod returns an error
+// that wraps [errors.ErrUnsupported].
+func Chmod(fsys FS, name string, mode fs.FileMode) error {
+	if fsys, ok := fsys.(MetaFS); ok {
+		return fsys.Chmod(name, mode)
+	}
+	return &fs.PathError{Op: "chmod", Path: name, Err: errors.ErrUnsupported}
+}
+
+// Chown changes the numeric uid and gid of the named file in fsys.
+// If fsys does not implement [MetaFS], Chown returns an error
+// that wraps [errors.ErrUnsupported].
+func Chown(fsys FS, name string, uid, gid int) error {
+	if fsys, ok := fsys.(MetaFS); ok {
+		return fsys.Chown(name, uid, gid)
+	}
+	return &fs.PathError{Op: "chown", Path: name, Err: errors.ErrUnsupported}
+}
+
+// Chtimes changes the access and modification times of the named file in fsys.
+// If fsys does not implement [MetaFS], Chtimes returns an error
+// that wraps [errors.ErrUnsupported].
+func Chtimes(fsys FS, name string, atime, mtime time.Time) error {
+	if fsys, ok := fsys.(MetaFS); ok {
+		return fsys.Chtimes(name, atime, mtime)
+	}
+	return &fs.PathError{Op: "chtimes", Path: name, Err: errors.ErrUnsupported}
+}
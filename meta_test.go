@@ -0,0 +1,91 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestMeta(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dir/file": &fstest.MapFile{Data: []byte("Hello"), Mode: 0644},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			file := filepath.Join(base, "dir/file")
+			if err := wfs.Chmod(fsys, file, 0600); err != nil {
+				t.Fatalf("Chmod failed: %v", err)
+			}
+			info, err := fs.Stat(fsys, file)
+			if err != nil || info.Mode() != 0600 {
+				t.Errorf("expected mode 0600, got %v err: %v", info.Mode(), err)
+			}
+
+			mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+			if err := wfs.Chtimes(fsys, file, mtime, mtime); err != nil {
+				t.Fatalf("Chtimes failed: %v", err)
+			}
+			info, err = fs.Stat(fsys, file)
+			if err != nil || !info.ModTime().Equal(mtime) {
+				t.Errorf("expected modification time %v, got %v err: %v", mtime, info.ModTime(), err)
+			}
+			dir := filepath.Join(base, "dir")
+			if err := wfs.Chtimes(fsys, dir, time.Time{}, mtime); err != nil {
+				t.Fatalf("Chtimes failed for directory: %v", err)
+			}
+			info, err = fs.Stat(fsys, dir)
+			if err != nil || !info.IsDir() || !info.ModTime().Equal(mtime) {
+				t.Errorf("expected directory modification time %v, got %v err: %v", mtime, info.ModTime(), err)
+			}
+
+			err = wfs.Chown(fsys, file, os.Getuid(), os.Getgid())
+			if tt.name == "Map FS" {
+				if !errors.Is(err, errors.ErrUnsupported) {
+					t.Errorf("expected ErrUnsupported, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Chown failed: %v", err)
+			}
+
+			missing := filepath.Join(base, "missing")
+			for op, err := range map[string]error{
+				"Chmod":   wfs.Chmod(fsys, missing, 0600),
+				"Chown":   wfs.Chown(fsys, missing, -1, -1),
+				"Chtimes": wfs.Chtimes(fsys, missing, mtime, mtime),
+			} {
+				if !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("%s: expected ErrNotExist, got %v", op, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMemChown(t *testing.T) {
+	fsys := wfs.Mem()
+	if err := wfs.WriteFile(fsys, "file", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := wfs.Chown(fsys, "file", 1000, 100); err != nil {
+		t.Fatalf("Chown failed: %v", err)
+	}
+	if err := wfs.Chown(fsys, "file", -1, 200); err != nil {
+		t.Fatalf("Chown failed: %v", err)
+	}
+	info, _ := fs.Stat(fsys, "file")
+	sys, ok := wfs.SysInfo[*wfs.MemSys](info)
+	if !ok || sys.Uid != 1000 || sys.Gid != 200 {
+		t.Errorf("expected owner 1000:200, got %+v", sys)
+	}
+}
@@ -0,0 +1,373 @@
+package wfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MetricsSink receives the metrics of a file system wrapped with [WithMetrics].
+// Its methods may be called concurrently.
+type MetricsSink interface {
+	// ObserveOperation records a completed operation, such as "open" or
+	// "write", with its duration and the error it returned.
+	ObserveOperation(op string, d time.Duration, err error)
+
+	// AddBytesRead records n bytes read from files.
+	AddBytesRead(n int64)
+
+	// AddBytesWritten records n bytes written to files.
+	AddBytesWritten(n int64)
+}
+
+// WithMetrics returns a file system that reports every operation on fsys and
+// on the files it opens to sink, along with the bytes read and written.
+// Reads that reach the end of a file are not reported as errors.
+func WithMetrics(fsys FS, sink MetricsSink) FS {
+	return &metricsFs{fsys, sink}
+}
+
+type metricsFs struct {
+	fsys FS
+	sink MetricsSink
+}
+
+func (f *metricsFs) observe(op string, start time.Time, err error) {
+	f.sink.ObserveOperation(op, time.Since(start), err)
+}
+
+func (f *metricsFs) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for metricsFs.
+func (f *metricsFs) Stat(name string) (fs.FileInfo, error) {
+	start := time.Now()
+	info, err := fs.Stat(f.fsys, name)
+	f.observe("stat", start, err)
+	return info, err
+}
+
+func (f *metricsFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	start := time.Now()
+	file, err := f.fsys.OpenFile(name, flag, perm)
+	f.observe("open", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &metricsFile{file, f}, nil
+}
+
+func (f *metricsFs) Rename(oldpath, newpath string) error {
+	start := time.Now()
+	err := f.fsys.Rename(oldpath, newpath)
+	f.observe("rename", start, err)
+	return err
+}
+
+func (f *metricsFs) Remove(name string) error {
+	start := time.Now()
+	err := f.fsys.Remove(name)
+	f.observe("remove", start, err)
+	return err
+}
+
+func (f *metricsFs) RemoveAll(path string) error {
+	start := time.Now()
+	err := f.fsys.RemoveAll(path)
+	f.observe("RemoveAll", start, err)
+	return err
+}
+
+func (f *metricsFs) Mkdir(name string, perm fs.FileMode) error {
+	start := time.Now()
+	err := f.fsys.Mkdir(name, perm)
+	f.observe("mkdir", start, err)
+	return err
+}
+
+func (f *metricsFs) MkdirAll(path string, perm fs.FileMode) error {
+	start := time.Now()
+	err := f.fsys.MkdirAll(path, perm)
+	f.observe("MkdirAll", start, err)
+	return err
+}
+
+// Symlink implements [SymlinkFS] for metricsFs.
+func (f *metricsFs) Symlink(oldname, newname string) error {
+	start := time.Now()
+	err := Symlink(f.fsys, oldname, newname)
+	f.observe("symlink", start, err)
+	return err
+}
+
+// Readlink implements [SymlinkFS] for metricsFs.
+func (f *metricsFs) Readlink(name string) (string, error) {
+	start := time.Now()
+	target, err := Readlink(f.fsys, name)
+	f.observe("readlink", start, err)
+	return target, err
+}
+
+// Lstat implements [SymlinkFS] for metricsFs.
+func (f *metricsFs) Lstat(name string) (fs.FileInfo, error) {
+	start := time.Now()
+	info, err := Lstat(f.fsys, name)
+	f.observe("lstat", start, err)
+	return info, err
+}
+
+// Link implements [LinkFS] for metricsFs.
+func (f *metricsFs) Link(oldname, newname string) error {
+	start := time.Now()
+	err := Link(f.fsys, oldname, newname)
+	f.observe("link", start, err)
+	return err
+}
+
+// Chmod implements [MetaFS] for metricsFs.
+func (f *metricsFs) Chmod(name string, mode fs.FileMode) error {
+	start := time.Now()
+	err := Chmod(f.fsys, name, mode)
+	f.observe("chmod", start, err)
+	return err
+}
+
+// Chown implements [MetaFS] for metricsFs.
+func (f *metricsFs) Chown(name string, uid, gid int) error {
+	start := time.Now()
+	err := Chown(f.fsys, name, uid, gid)
+	f.observe("chown", start, err)
+	return err
+}
+
+// Chtimes implements [MetaFS] for metricsFs.
+func (f *metricsFs) Chtimes(name string, atime, mtime time.Time) error {
+	start := time.Now()
+	err := Chtimes(f.fsys, name, atime, mtime)
+	f.observe("chtimes", start, err)
+	return err
+}
+
+type metricsFile struct {
+	File
+	fs *metricsFs
+}
+
+func (f *metricsFile) Read(b []byte) (int, error) {
+	start := time.Now()
+	n, err := f.File.Read(b)
+	f.fs.observe("read", start, ignoreEOF(err))
+	f.fs.sink.AddBytesRead(int64(n))
+	return n, err
+}
+
+func (f *metricsFile) ReadAt(b []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := f.File.ReadAt(b, off)
+	f.fs.observe("read", start, ignoreEOF(err))
+	f.fs.sink.AddBytesRead(int64(n))
+	return n, err
+}
+
+func (f *metricsFile) Write(b []byte) (int, error) {
+	start := time.Now()
+	n, err := f.File.Write(b)
+	f.fs.observe("write", start, err)
+	f.fs.sink.AddBytesWritten(int64(n))
+	return n, err
+}
+
+func (f *metricsFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *metricsFile) WriteAt(b []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := f.File.WriteAt(b, off)
+	f.fs.observe("write", start, err)
+	f.fs.sink.AddBytesWritten(int64(n))
+	return n, err
+}
+
+func (f *metricsFile) Truncate(size int64) error {
+	start := time.Now()
+	err := f.File.Truncate(size)
+	f.fs.observe("truncate", start, err)
+	return err
+}
+
+func (f *metricsFile) Sync() error {
+	start := time.Now()
+	err := f.File.Sync()
+	f.fs.observe("sync", start, err)
+	return err
+}
+
+func (f *metricsFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return readDir(f.File, n)
+}
+
+func (f *metricsFile) Close() error {
+	start := time.Now()
+	err := f.File.Close()
+	f.fs.observe("close", start, err)
+	return err
+}
+
+// DefaultBuckets are the latency histogram buckets of a [PrometheusSink]
+// in seconds, they match the default buckets of the Prometheus client.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusSink is a [MetricsSink] that exposes its metrics in the
+// Prometheus text exposition format. It serves the metrics over HTTP so it can
+// be mounted as a scrape endpoint, or written with WriteTo to be combined with
+// the output of a Prometheus registry.
+//
+// The metrics are prefixed with the namespace of the sink:
+//
+//	<namespace>_operations_total{op}               counter
+//	<namespace>_operation_errors_total{op}         counter
+//	<namespace>_operation_duration_seconds{op}     histogram
+//	<namespace>_read_bytes_total                   counter
+//	<namespace>_written_bytes_total                counter
+type PrometheusSink struct {
+	namespace string
+	buckets   []float64
+
+	mu      sync.Mutex
+	ops     map[string]*opMetrics
+	read    int64
+	written int64
+}
+
+type opMetrics struct {
+	count   uint64
+	errors  uint64
+	sum     float64
+	buckets []uint64 // cumulative counts, one per bucket
+}
+
+// NewPrometheusSink returns a PrometheusSink with the given metric namespace
+// and latency histogram buckets in seconds. If buckets is nil [DefaultBuckets]
+// are used.
+func NewPrometheusSink(namespace string, buckets []float64) *PrometheusSink {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	return &PrometheusSink{namespace: namespace, buckets: buckets, ops: make(map[string]*opMetrics)}
+}
+
+// ObserveOperation implements [MetricsSink] for PrometheusSink.
+func (s *PrometheusSink) ObserveOperation(op string, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.ops[op]
+	if !ok {
+		m = &opMetrics{buckets: make([]uint64, len(s.buckets))}
+		s.ops[op] = m
+	}
+	m.count++
+	if err != nil {
+		m.errors++
+	}
+	secs := d.Seconds()
+	m.sum += secs
+	for i, le := range s.buckets {
+		if secs <= le {
+			m.buckets[i]++
+		}
+	}
+}
+
+// AddBytesRead implements [MetricsSink] for PrometheusSink.
+func (s *PrometheusSink) AddBytesRead(n int64) {
+	s.mu.Lock()
+	s.read += n
+	s.mu.Unlock()
+}
+
+// AddBytesWritten implements [MetricsSink] for PrometheusSink.
+func (s *PrometheusSink) AddBytesWritten(n int64) {
+	s.mu.Lock()
+	s.written += n
+	s.mu.Unlock()
+}
+
+// WriteTo writes the metrics to w in the Prometheus text exposition format.
+func (s *PrometheusSink) WriteTo(w io.Writer) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ops := make([]string, 0, len(s.ops))
+	for op := range s.ops {
+		ops = append(ops, op)
+	}
+	slices.Sort(ops)
+
+	cw := &countWriter{w: w}
+	ns := s.namespace
+	fmt.Fprintf(cw, "# HELP %s_operations_total Number of file system operations.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %s_operations_total counter\n", ns)
+	for _, op := range ops {
+		fmt.Fprintf(cw, "%s_operations_total{op=%q} %d\n", ns, op, s.ops[op].count)
+	}
+	fmt.Fprintf(cw, "# HELP %s_operation_errors_total Number of file system operations that failed.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %s_operation_errors_total counter\n", ns)
+	for _, op := range ops {
+		fmt.Fprintf(cw, "%s_operation_errors_total{op=%q} %d\n", ns, op, s.ops[op].errors)
+	}
+	fmt.Fprintf(cw, "# HELP %s_operation_duration_seconds Duration of file system operations.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %s_operation_duration_seconds histogram\n", ns)
+	for _, op := range ops {
+		m := s.ops[op]
+		for i, le := range s.buckets {
+			fmt.Fprintf(cw, "%s_operation_duration_seconds_bucket{op=%q,le=%q} %d\n", ns, op, formatFloat(le), m.buckets[i])
+		}
+		fmt.Fprintf(cw, "%s_operation_duration_seconds_bucket{op=%q,le=\"+Inf\"} %d\n", ns, op, m.count)
+		fmt.Fprintf(cw, "%s_operation_duration_seconds_sum{op=%q} %s\n", ns, op, formatFloat(m.sum))
+		fmt.Fprintf(cw, "%s_operation_duration_seconds_count{op=%q} %d\n", ns, op, m.count)
+	}
+	fmt.Fprintf(cw, "# HELP %s_read_bytes_total Number of bytes read from files.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %s_read_bytes_total counter\n", ns)
+	fmt.Fprintf(cw, "%s_read_bytes_total %d\n", ns, s.read)
+	fmt.Fprintf(cw, "# HELP %s_written_bytes_total Number of bytes written to files.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %s_written_bytes_total counter\n", ns)
+	fmt.Fprintf(cw, "%s_written_bytes_total %d\n", ns, s.written)
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (s *PrometheusSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.WriteTo(w)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// countWriter counts the bytes written to w and keeps the first error,
+// after which writes are discarded.
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *countWriter) Write(b []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
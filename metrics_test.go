@@ -0,0 +1,50 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestWithMetrics(t *testing.T) {
+	sink := wfs.NewPrometheusSink("wfs", []float64{1, 0.5})
+	fsys := wfs.WithMetrics(wfs.Mem(), sink)
+
+	if err := wfs.WriteFile(fsys, "file", []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, fsys, "file", "hello")
+	if err := fsys.Remove("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	sink.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected text content type, got %q", ct)
+	}
+	out := rec.Body.String()
+	for _, expected := range []string{
+		"# TYPE wfs_operations_total counter\n",
+		`wfs_operations_total{op="open"} 2` + "\n",
+		`wfs_operations_total{op="remove"} 1` + "\n",
+		`wfs_operation_errors_total{op="open"} 0` + "\n",
+		`wfs_operation_errors_total{op="read"} 0` + "\n",
+		`wfs_operation_errors_total{op="remove"} 1` + "\n",
+		"# TYPE wfs_operation_duration_seconds histogram\n",
+		`wfs_operation_duration_seconds_bucket{op="remove",le="0.5"} 1` + "\n",
+		`wfs_operation_duration_seconds_bucket{op="remove",le="1"} 1` + "\n",
+		`wfs_operation_duration_seconds_bucket{op="remove",le="+Inf"} 1` + "\n",
+		`wfs_operation_duration_seconds_count{op="remove"} 1` + "\n",
+		"wfs_read_bytes_total 5\n",
+		"wfs_written_bytes_total 5\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
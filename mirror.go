@@ -0,0 +1,279 @@
+package wfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ReplicaError records a failed operation on a replica of a [MirrorFS].
+type ReplicaError struct {
+	Replica int // index of the replica
+	Err     error
+}
+
+func (e *ReplicaError) Error() string {
+	return "replica " + strconv.Itoa(e.Replica) + ": " + e.Err.Error()
+}
+
+func (e *ReplicaError) Unwrap() error { return e.Err }
+
+// ReplicaPolicy decides how a failed operation on a replica is handled.
+// It is called with the index of the replica and the error, and the error it
+// returns is returned by the operation. A replica that failed stops receiving
+// the writes of the file it failed on.
+type ReplicaPolicy func(replica int, err error) error
+
+// ReplicaFail is a ReplicaPolicy that fails the operation with a [*ReplicaError].
+func ReplicaFail(replica int, err error) error {
+	return &ReplicaError{replica, err}
+}
+
+// ReplicaIgnore is a ReplicaPolicy that ignores replica failures.
+func ReplicaIgnore(replica int, err error) error {
+	return nil
+}
+
+// MirrorFS is a file system that replicates mutations to other file systems.
+type MirrorFS interface {
+	FS
+
+	// WithPolicy returns a view of the file system that handles replica
+	// failures with policy.
+	WithPolicy(policy ReplicaPolicy) MirrorFS
+}
+
+// Mirror returns a MirrorFS that serves reads from primary and applies every
+// mutation to primary and then to each replica in order. Files opened for
+// writing are opened on every file system and writes, truncates and seeks are
+// replicated. A mutation that fails on primary is not applied to the replicas.
+//
+// Replica failures are handled with [ReplicaFail] unless another policy is
+// set with [MirrorFS.WithPolicy], all replicas are attempted and the errors
+// returned by the policy are joined. Since primary has already been changed
+// when a replica fails, the file systems diverge until they are repaired.
+func Mirror(primary FS, replicas ...FS) MirrorFS {
+	return &mirrorFs{primary: primary, replicas: replicas, policy: ReplicaFail}
+}
+
+type mirrorFs struct {
+	primary  FS
+	replicas []FS
+	policy   ReplicaPolicy
+}
+
+func (f *mirrorFs) WithPolicy(policy ReplicaPolicy) MirrorFS {
+	return &mirrorFs{primary: f.primary, replicas: f.replicas, policy: policy}
+}
+
+// apply calls fn on primary and, if it succeeds, on each replica.
+func (f *mirrorFs) apply(fn func(fsys FS) error) error {
+	if err := fn(f.primary); err != nil {
+		return err
+	}
+	var errs []error
+	for i, replica := range f.replicas {
+		if err := fn(replica); err != nil {
+			errs = append(errs, f.policy(i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f *mirrorFs) Open(name string) (fs.File, error) {
+	return f.primary.Open(name)
+}
+
+// Stat implements [fs.StatFS] for mirrorFs.
+func (f *mirrorFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.primary, name)
+}
+
+func (f *mirrorFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	file, err := f.primary.OpenFile(name, flag, perm)
+	if err != nil || flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		return file, err
+	}
+	mf := &mirrorFile{File: file, policy: f.policy}
+	var errs []error
+	for i, replica := range f.replicas {
+		rf, err := replica.OpenFile(name, flag, perm)
+		if err != nil {
+			errs = append(errs, f.policy(i, err))
+			continue
+		}
+		mf.replicas = append(mf.replicas, replicaFile{i, rf})
+	}
+	if err := errors.Join(errs...); err != nil {
+		mf.Close()
+		return nil, err
+	}
+	return mf, nil
+}
+
+func (f *mirrorFs) Rename(oldpath, newpath string) error {
+	return f.apply(func(fsys FS) error { return fsys.Rename(oldpath, newpath) })
+}
+
+func (f *mirrorFs) Remove(name string) error {
+	return f.apply(func(fsys FS) error { return fsys.Remove(name) })
+}
+
+func (f *mirrorFs) RemoveAll(path string) error {
+	return f.apply(func(fsys FS) error { return fsys.RemoveAll(path) })
+}
+
+func (f *mirrorFs) Mkdir(name string, perm fs.FileMode) error {
+	return f.apply(func(fsys FS) error { return fsys.Mkdir(name, perm) })
+}
+
+func (f *mirrorFs) MkdirAll(path string, perm fs.FileMode) error {
+	return f.apply(func(fsys FS) error { return fsys.MkdirAll(path, perm) })
+}
+
+// Symlink implements [SymlinkFS] for mirrorFs.
+func (f *mirrorFs) Symlink(oldname, newname string) error {
+	return f.apply(func(fsys FS) error { return Symlink(fsys, oldname, newname) })
+}
+
+// Readlink implements [SymlinkFS] for mirrorFs.
+func (f *mirrorFs) Readlink(name string) (string, error) {
+	return Readlink(f.primary, name)
+}
+
+// Lstat implements [SymlinkFS] for mirrorFs.
+func (f *mirrorFs) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(f.primary, name)
+}
+
+// Link implements [LinkFS] for mirrorFs.
+func (f *mirrorFs) Link(oldname, newname string) error {
+	return f.apply(func(fsys FS) error { return Link(fsys, oldname, newname) })
+}
+
+// Chmod implements [MetaFS] for mirrorFs.
+func (f *mirrorFs) Chmod(name string, mode fs.FileMode) error {
+	return f.apply(func(fsys FS) error { return Chmod(fsys, name, mode) })
+}
+
+// Chown implements [MetaFS] for mirrorFs.
+func (f *mirrorFs) Chown(name string, uid, gid int) error {
+	return f.apply(func(fsys FS) error { return Chown(fsys, name, uid, gid) })
+}
+
+// Chtimes implements [MetaFS] for mirrorFs.
+func (f *mirrorFs) Chtimes(name string, atime, mtime time.Time) error {
+	return f.apply(func(fsys FS) error { return Chtimes(fsys, name, atime, mtime) })
+}
+
+type replicaFile struct {
+	replica int
+	File
+}
+
+// mirrorFile is a file of the primary whose writes are replicated.
+type mirrorFile struct {
+	File
+	replicas []replicaFile
+	policy   ReplicaPolicy
+}
+
+// each calls fn on each replica file, replicas that fail are dropped.
+func (f *mirrorFile) each(fn func(file File) error) error {
+	var errs []error
+	replicas := f.replicas[:0]
+	for _, rf := range f.replicas {
+		if err := fn(rf.File); err != nil {
+			rf.Close()
+			errs = append(errs, f.policy(rf.replica, err))
+			continue
+		}
+		replicas = append(replicas, rf)
+	}
+	f.replicas = replicas
+	return errors.Join(errs...)
+}
+
+func (f *mirrorFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	if n > 0 {
+		// keep the offsets of the replicas in step
+		if err := f.each(func(file File) error {
+			_, err := file.Seek(int64(n), io.SeekCurrent)
+			return err
+		}); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+func (f *mirrorFile) Write(b []byte) (int, error) {
+	n, err := f.File.Write(b)
+	if n > 0 {
+		if err := f.each(func(file File) error {
+			_, err := file.Write(b[:n])
+			return err
+		}); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+func (f *mirrorFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *mirrorFile) WriteAt(b []byte, off int64) (int, error) {
+	n, err := f.File.WriteAt(b, off)
+	if n > 0 {
+		if err := f.each(func(file File) error {
+			_, err := file.WriteAt(b[:n], off)
+			return err
+		}); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+func (f *mirrorFile) Seek(offset int64, whence int) (int64, error) {
+	ret, err := f.File.Seek(offset, whence)
+	if err != nil {
+		return ret, err
+	}
+	// replicas seek to the absolute offset in case their sizes differ
+	return ret, f.each(func(file File) error {
+		_, err := file.Seek(ret, io.SeekStart)
+		return err
+	})
+}
+
+func (f *mirrorFile) Truncate(size int64) error {
+	if err := f.File.Truncate(size); err != nil {
+		return err
+	}
+	return f.each(func(file File) error { return file.Truncate(size) })
+}
+
+func (f *mirrorFile) Sync() error {
+	if err := f.File.Sync(); err != nil {
+		return err
+	}
+	return f.each(func(file File) error { return file.Sync() })
+}
+
+func (f *mirrorFile) Close() error {
+	errs := []error{f.File.Close()}
+	for _, rf := range f.replicas {
+		if err := rf.Close(); err != nil {
+			errs = append(errs, f.policy(rf.replica, err))
+		}
+	}
+	f.replicas = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,92 @@
+package wfs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestMirror(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			replica, base, cleanup, err := tt.fsys(fstest.MapFS{})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			if base != "" {
+				if replica, err = wfs.Sub(replica, filepath.ToSlash(base)); err != nil {
+					t.Fatalf("failed to create sub file system: %v", err)
+				}
+			}
+			primary := wfs.Mem()
+			fsys := wfs.Mirror(primary, replica, wfs.Map(fstest.MapFS{}))
+
+			if err := fsys.MkdirAll("dir/sub", 0755); err != nil {
+				t.Fatalf("MkdirAll failed: %v", err)
+			}
+			f, err := fsys.OpenFile("dir/file", os.O_RDWR|os.O_CREATE, 0644)
+			if err != nil {
+				t.Fatalf("OpenFile failed: %v", err)
+			}
+			io.WriteString(f, "Hello, World")
+			f.Seek(0, io.SeekStart)
+			f.Read(make([]byte, 7))
+			io.WriteString(f, "Go")
+			f.WriteAt([]byte("!"), 12)
+			if err := f.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			if err := fsys.Rename("dir/file", "dir/sub/file"); err != nil {
+				t.Fatalf("Rename failed: %v", err)
+			}
+			for _, fsys := range []fs.FS{primary, replica} {
+				assertContent(t, fsys, "dir/sub/file", "Hello, Gorld!")
+			}
+
+			// mutations that fail on the primary are not replicated
+			wfs.WriteFile(primary, "primary-only", nil, 0644)
+			if err := fsys.Mkdir("primary-only", 0755); !errors.Is(err, fs.ErrExist) {
+				t.Errorf("expected ErrExist, got %v", err)
+			}
+			if _, err := fs.Stat(replica, "primary-only"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected mkdir not to be replicated, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMirrorPolicy(t *testing.T) {
+	primary, replica := wfs.Mem(), wfs.Mem()
+	fsys := wfs.Mirror(primary, wfs.ReadOnly(replica), replica)
+
+	err := wfs.WriteFile(fsys, "file", []byte("data"), 0644)
+	var replicaErr *wfs.ReplicaError
+	if !errors.As(err, &replicaErr) || replicaErr.Replica != 0 || !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected replica error, got %v", err)
+	}
+
+	var failed []int
+	fsys = fsys.WithPolicy(func(replica int, err error) error {
+		failed = append(failed, replica)
+		return nil
+	})
+	if err := wfs.WriteFile(fsys, "file", []byte("data"), 0644); err != nil {
+		t.Errorf("expected replica error to be ignored, got %v", err)
+	}
+	if err := fsys.Remove("file"); err != nil {
+		t.Errorf("expected replica error to be ignored, got %v", err)
+	}
+	if len(failed) != 2 || failed[0] != 0 || failed[1] != 0 {
+		t.Errorf("expected failures of replica 0, got %v", failed)
+	}
+	if _, err := fs.Stat(replica, "file"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected healthy replica to be updated, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+)
+
+// Move moves the file or directory srcPath in src to dstPath in dst.
+//
+// If src and dst are the same file system Move renames srcPath to dstPath.
+// Otherwise, or if the rename fails because the paths are on different
+// devices, srcPath is copied to dstPath as by [CopyFS] and then removed.
+// If the copy fails srcPath is left in place and dstPath may be partially
+// written.
+func Move(dst FS, dstPath string, src FS, srcPath string) error {
+	if sameFS(dst, src) {
+		err := src.Rename(srcPath, dstPath)
+		if !errors.Is(err, errXDev) {
+			return err
+		}
+	}
+	info, err := Lstat(src, srcPath)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		dest, err := Readlink(src, srcPath)
+		if err != nil {
+			return err
+		}
+		if err := dst.Remove(dstPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		err = Symlink(dst, dest, dstPath)
+	} else {
+		err = copyTree(dst, dstPath, src, srcPath)
+	}
+	if err != nil {
+		return err
+	}
+	return src.RemoveAll(srcPath)
+}
+
+// sameFS reports whether a and b are the same file system.
+func sameFS(a, b FS) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
@@ -0,0 +1,62 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestMove(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"file":         &fstest.MapFile{Data: []byte("Hello")},
+				"dir/a.txt":    &fstest.MapFile{Data: []byte("a")},
+				"dir/sub/b.md": &fstest.MapFile{Data: []byte("b")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			// same file system
+			if err := wfs.Move(fsys, filepath.Join(base, "moved"), fsys, filepath.Join(base, "file")); err != nil {
+				t.Fatalf("Move failed: %v", err)
+			}
+			assertMoved(t, fsys, filepath.Join(base, "file"), fsys, filepath.Join(base, "moved"), "Hello")
+
+			// to another file system
+			mem := wfs.Mem()
+			if err := wfs.Move(mem, "dir", fsys, filepath.Join(base, "dir")); err != nil {
+				t.Fatalf("Move failed: %v", err)
+			}
+			assertMoved(t, fsys, filepath.Join(base, "dir", "a.txt"), mem, "dir/a.txt", "a")
+			assertMoved(t, fsys, filepath.Join(base, "dir"), mem, "dir/sub/b.md", "b")
+
+			// from another file system
+			if err := wfs.Move(fsys, filepath.Join(base, "back"), mem, "dir"); err != nil {
+				t.Fatalf("Move failed: %v", err)
+			}
+			assertMoved(t, mem, "dir", fsys, filepath.Join(base, "back", "sub", "b.md"), "b")
+
+			if err := wfs.Move(mem, "missing", fsys, filepath.Join(base, "missing")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected ErrNotExist, got %v", err)
+			}
+		})
+	}
+}
+
+func assertMoved(t *testing.T, src fs.FS, srcName string, dst fs.FS, dstName, data string) {
+	t.Helper()
+	if _, err := fs.Stat(src, srcName); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected %q to be removed, got %v", srcName, err)
+	}
+	b, err := fs.ReadFile(dst, dstName)
+	if err != nil || string(b) != data {
+		t.Errorf("expected %q to contain %q, got %q err: %v", dstName, data, b, err)
+	}
+}
@@ -0,0 +1,228 @@
+package wfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrTooLarge is returned by [SaveMultipart] when an upload exceeds one of
+// its limits.
+var ErrTooLarge = errors.New("upload too large")
+
+// CollisionPolicy is how [SaveMultipart] handles uploaded files whose name
+// already exists.
+type CollisionPolicy int
+
+const (
+	// CollisionRename saves the file under the first unused name of the form
+	// "name.N.ext", starting from 1.
+	CollisionRename CollisionPolicy = iota
+
+	// CollisionReplace replaces the existing file.
+	CollisionReplace
+
+	// CollisionError stops with an error wrapping [fs.ErrExist].
+	CollisionError
+)
+
+// SavedFile describes a file saved by [SaveMultipart].
+type SavedFile struct {
+	// Field is the name of the form field the file was uploaded with.
+	Field string
+
+	// Filename is the file name sent by the client.
+	Filename string
+
+	// Name is the name the file was saved to in the file system.
+	Name string
+
+	// Size is the size of the file in bytes.
+	Size int64
+
+	// ContentType is the content type sent by the client.
+	ContentType string
+}
+
+// MultipartOption configures [SaveMultipart].
+type MultipartOption func(*multipartConfig)
+
+type multipartConfig struct {
+	maxFileSize  int64
+	maxTotalSize int64
+	maxFiles     int
+	fields       []string
+	collision    CollisionPolicy
+	perm         fs.FileMode
+}
+
+// MaxFileSize limits the size of every uploaded file to n bytes.
+func MaxFileSize(n int64) MultipartOption {
+	return func(c *multipartConfig) { c.maxFileSize = n }
+}
+
+// MaxTotalSize limits the combined size of the uploaded files to n bytes.
+func MaxTotalSize(n int64) MultipartOption {
+	return func(c *multipartConfig) { c.maxTotalSize = n }
+}
+
+// MaxFiles limits the number of uploaded files to n.
+func MaxFiles(n int) MultipartOption {
+	return func(c *multipartConfig) { c.maxFiles = n }
+}
+
+// FormFields only saves the files uploaded with the named form fields,
+// instead of the files of every field.
+func FormFields(names ...string) MultipartOption {
+	return func(c *multipartConfig) { c.fields = names }
+}
+
+// OnCollision sets how files whose name already exists are handled. The
+// default is [CollisionRename].
+func OnCollision(policy CollisionPolicy) MultipartOption {
+	return func(c *multipartConfig) { c.collision = policy }
+}
+
+// UploadPerm sets the permission bits files are created with (before
+// umask). The default is 0644.
+func UploadPerm(perm fs.FileMode) MultipartOption {
+	return func(c *multipartConfig) { c.perm = perm & fs.ModePerm }
+}
+
+// SaveMultipart streams the files of the multipart/form-data body of r into
+// the directory dir of fsys, which must exist, and returns the files saved
+// in the order they were uploaded.
+//
+// Files are saved under the sanitized base of the file name sent by the
+// client: path elements, control characters, characters reserved on Windows
+// and leading dots are removed and names are limited to 255 bytes. Form
+// values that are not files are skipped, so they are not available from r
+// afterwards.
+//
+// Files exceeding a size limit are removed and SaveMultipart stops with an
+// error wrapping [ErrTooLarge]. On error, the files saved before are kept and
+// returned with the error so that callers can remove them.
+func SaveMultipart(fsys FS, dir string, r *http.Request, opts ...MultipartOption) ([]SavedFile, error) {
+	c := &multipartConfig{perm: 0644}
+	for _, opt := range opts {
+		opt(c)
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	var saved []SavedFile
+	var total int64
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return saved, nil
+		}
+		if err != nil {
+			return saved, err
+		}
+		if part.FileName() == "" || c.fields != nil && !slices.Contains(c.fields, part.FormName()) {
+			continue
+		}
+		if c.maxFiles > 0 && len(saved) == c.maxFiles {
+			return saved, fmt.Errorf("%w: more than %d files", ErrTooLarge, c.maxFiles)
+		}
+		limit := int64(-1)
+		if c.maxFileSize > 0 {
+			limit = c.maxFileSize
+		}
+		if c.maxTotalSize > 0 && (limit < 0 || c.maxTotalSize-total < limit) {
+			limit = c.maxTotalSize - total
+		}
+		file := SavedFile{
+			Field:       part.FormName(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+		}
+		file.Name, file.Size, err = c.save(fsys, path.Join(dir, SanitizeFilename(part.FileName())), part, limit)
+		if err != nil {
+			return saved, err
+		}
+		total += file.Size
+		saved = append(saved, file)
+	}
+}
+
+// save writes the contents of r to the file name, or the name chosen by the
+// collision policy, failing if more than limit bytes are read from r.
+func (c *multipartConfig) save(fsys FS, name string, r io.Reader, limit int64) (string, int64, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if c.collision == CollisionReplace {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	candidate := name
+	var f File
+	for i := 1; ; i++ {
+		var err error
+		f, err = fsys.OpenFile(candidate, flag, c.perm)
+		if err == nil {
+			break
+		}
+		if c.collision != CollisionRename || !errors.Is(err, fs.ErrExist) {
+			return "", 0, err
+		}
+		candidate = fmt.Sprintf("%s.%d%s", stem, i, ext)
+	}
+	if limit >= 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	n, err := io.Copy(f, r)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err == nil && limit >= 0 && n > limit {
+		err = &fs.PathError{Op: "savemultipart", Path: candidate, Err: ErrTooLarge}
+	}
+	if err != nil {
+		fsys.Remove(candidate)
+		return "", 0, err
+	}
+	return candidate, n, nil
+}
+
+// reservedChars are the characters that cannot be used in file names on
+// Windows, in addition to control characters.
+const reservedChars = `<>:"/\|?*`
+
+// SanitizeFilename returns a file name that is safe to create in a single
+// directory from a file name sent by a client, as done by [SaveMultipart].
+// Only the base of the name is kept, treating backslashes as separators,
+// control characters and characters reserved on Windows are replaced with
+// underscores, leading dots and surrounding spaces are removed and the name
+// is truncated to 255 bytes. Names left empty become "file".
+func SanitizeFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(reservedChars, r) || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	name = strings.TrimSpace(name)
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" {
+		return "file"
+	}
+	return name
+}
@@ -0,0 +1,114 @@
+package wfs_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+// newUpload returns a multipart body uploading files, given as pairs of file
+// names and contents, with the form field "file".
+func newUpload(t *testing.T, files ...string) (body *bytes.Buffer, contentType string) {
+	t.Helper()
+	body = &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField("title", "not a file")
+	for i := 0; i < len(files); i += 2 {
+		w, err := mw.CreateFormFile("file", files[i])
+		if err != nil {
+			t.Fatalf("CreateFormFile failed: %v", err)
+		}
+		w.Write([]byte(files[i+1]))
+	}
+	mw.Close()
+	return body, mw.FormDataContentType()
+}
+
+func TestSaveMultipart(t *testing.T) {
+	save := func(fsys wfs.FS, opts []wfs.MultipartOption, files ...string) ([]wfs.SavedFile, error) {
+		body, contentType := newUpload(t, files...)
+		r := httptest.NewRequest("POST", "/upload", body)
+		r.Header.Set("Content-Type", contentType)
+		return wfs.SaveMultipart(fsys, "uploads", r, opts...)
+	}
+	fsys := wfs.Mem()
+	if err := fsys.Mkdir("uploads", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+
+	saved, err := save(fsys, nil, "a.txt", "first", `..\..\.secret`, "hidden", "a.txt", "second")
+	if err != nil {
+		t.Fatalf("SaveMultipart failed: %v", err)
+	}
+	var names []string
+	for _, f := range saved {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, " ") != "uploads/a.txt uploads/secret uploads/a.1.txt" {
+		t.Errorf("unexpected names %v", names)
+	}
+	if f := saved[0]; f.Field != "file" || f.Filename != "a.txt" || f.Size != 5 || f.ContentType != "application/octet-stream" {
+		t.Errorf("unexpected saved file %+v", f)
+	}
+	assertContent(t, fsys, "uploads/a.txt", "first")
+	assertContent(t, fsys, "uploads/a.1.txt", "second")
+
+	// collision policies
+	if _, err := save(fsys, []wfs.MultipartOption{wfs.OnCollision(wfs.CollisionReplace)}, "a.txt", "replaced"); err != nil {
+		t.Fatalf("SaveMultipart failed: %v", err)
+	}
+	assertContent(t, fsys, "uploads/a.txt", "replaced")
+	if _, err := save(fsys, []wfs.MultipartOption{wfs.OnCollision(wfs.CollisionError)}, "a.txt", "x"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+
+	// size limits remove the file exceeding them and keep the files before
+	saved, err = save(fsys, []wfs.MultipartOption{wfs.MaxFileSize(4)}, "ok.txt", "1234", "big.txt", "12345")
+	if !errors.Is(err, wfs.ErrTooLarge) || len(saved) != 1 {
+		t.Errorf("expected ErrTooLarge after one file, got %v err: %v", saved, err)
+	}
+	if _, err := fs.Stat(fsys, "uploads/big.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the file exceeding the limit to be removed, got %v", err)
+	}
+	saved, err = save(fsys, []wfs.MultipartOption{wfs.MaxTotalSize(6), wfs.OnCollision(wfs.CollisionReplace)}, "b.txt", "123", "c.txt", "1234")
+	if !errors.Is(err, wfs.ErrTooLarge) || len(saved) != 1 {
+		t.Errorf("expected ErrTooLarge after one file, got %v err: %v", saved, err)
+	}
+	if _, err := save(fsys, []wfs.MultipartOption{wfs.MaxFiles(1), wfs.OnCollision(wfs.CollisionReplace)}, "b.txt", "1", "c.txt", "2"); !errors.Is(err, wfs.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if saved, err := save(fsys, []wfs.MultipartOption{wfs.FormFields("avatar")}, "d.txt", "1"); err != nil || len(saved) != 0 {
+		t.Errorf("expected files of other fields to be skipped, got %v err: %v", saved, err)
+	}
+	if _, err := save(wfs.Mem(), nil, "a.txt", "1"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist for a missing directory, got %v", err)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name, expected string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\me\photo.jpg`, "photo.jpg"},
+		{"..", "file"},
+		{".env", "env"},
+		{"  spaced .txt ", "spaced .txt"},
+		{"a:b*c?.txt", "a_b_c_.txt"},
+		{"new\nline.txt", "new_line.txt"},
+		{"", "file"},
+		{strings.Repeat("é", 200), strings.Repeat("é", 127)},
+	}
+	for _, tt := range tests {
+		if got := wfs.SanitizeFilename(tt.name); got != tt.expected {
+			t.Errorf("SanitizeFilename(%q) = %q, expected %q", tt.name, got, tt.expected)
+		}
+	}
+}
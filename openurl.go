@@ -0,0 +1,111 @@
+package wfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// URLOpener opens the file system identified by a URL, see [RegisterScheme].
+type URLOpener func(ctx context.Context, u *url.URL) (FS, error)
+
+var (
+	schemesMu sync.RWMutex
+	schemes   = map[string]URLOpener{
+		"file": openFileURL,
+		"mem":  openMemURL,
+	}
+)
+
+// RegisterScheme makes a file system driver available to [OpenURL] for URLs
+// with the scheme. Drivers usually register themselves in an init function
+// of their package, so that importing the package for its side effects
+// enables the scheme. If RegisterScheme is called twice for the same scheme
+// or if opener is nil, it panics.
+func RegisterScheme(scheme string, opener URLOpener) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	scheme = strings.ToLower(scheme)
+	if opener == nil {
+		panic("wfs: RegisterScheme opener is nil")
+	}
+	if _, dup := schemes[scheme]; dup {
+		panic("wfs: RegisterScheme called twice for scheme " + scheme)
+	}
+	schemes[scheme] = opener
+}
+
+// Schemes returns a sorted list of the schemes registered with
+// [RegisterScheme].
+func Schemes() []string {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+	list := make([]string, 0, len(schemes))
+	for scheme := range schemes {
+		list = append(list, scheme)
+	}
+	slices.Sort(list)
+	return list
+}
+
+// OpenURL opens the file system identified by rawURL with the driver
+// registered for its scheme, so that the storage backend of an application
+// can be chosen with a configuration string.
+//
+// The schemes file and mem are always available: "file:///path/to/dir"
+// opens the existing directory with [Dir], also accepting relative paths as
+// "file:path/to/dir", and "mem://" returns a new empty [Mem] file system on
+// every call. Other schemes are registered by driver packages, such as
+// "s3://bucket/prefix" by the s3 package and "bolt:///path/to/db" by the
+// bolt package.
+func OpenURL(ctx context.Context, rawURL string) (FS, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	schemesMu.RLock()
+	opener, ok := schemes[u.Scheme]
+	schemesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("wfs: unknown URL scheme %q (forgotten import?)", u.Scheme)
+	}
+	return opener(ctx, u)
+}
+
+func openFileURL(ctx context.Context, u *url.URL) (FS, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("wfs: file URL with remote host %q", u.Host)
+	}
+	name := u.Path
+	if u.Opaque != "" {
+		name = u.Opaque
+	}
+	if name == "" {
+		return nil, errors.New("wfs: file URL without path")
+	}
+	// file:///C:/dir names the Windows path C:/dir
+	if runtime.GOOS == "windows" && len(name) > 2 && name[0] == '/' && name[2] == ':' {
+		name = name[1:]
+	}
+	name = filepath.FromSlash(name)
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrNotDir}
+	}
+	return Dir(name), nil
+}
+
+func openMemURL(ctx context.Context, u *url.URL) (FS, error) {
+	return Mem(), nil
+}
@@ -0,0 +1,70 @@
+package wfs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestOpenURL(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := wfs.OpenURL(t.Context(), "file://remote/dir"); err == nil {
+		t.Errorf("expected a file URL with a remote host to fail")
+	}
+	fsys, err := wfs.OpenURL(t.Context(), "file:///"+strings.TrimPrefix(filepath.ToSlash(dir), "/"))
+	if err != nil {
+		t.Fatalf("OpenURL failed: %v", err)
+	}
+	assertContent(t, fsys, "file.txt", "hello")
+	if _, err := wfs.OpenURL(t.Context(), "file:///"+strings.TrimPrefix(filepath.ToSlash(dir), "/")+"/file.txt"); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("expected ErrNotDir, got %v", err)
+	}
+	if _, err := wfs.OpenURL(t.Context(), "file:///"+strings.TrimPrefix(filepath.ToSlash(dir), "/")+"/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+
+	mem, err := wfs.OpenURL(t.Context(), "mem://")
+	if err != nil {
+		t.Fatalf("OpenURL failed: %v", err)
+	}
+	assertEntries(t, mem, ".")
+
+	if _, err := wfs.OpenURL(t.Context(), "sftp://host/dir"); err == nil || !strings.Contains(err.Error(), "forgotten import") {
+		t.Errorf("expected an unknown scheme error, got %v", err)
+	}
+}
+
+func TestRegisterScheme(t *testing.T) {
+	var opened *url.URL
+	wfs.RegisterScheme("Test-Scheme", func(ctx context.Context, u *url.URL) (wfs.FS, error) {
+		opened = u
+		return wfs.Mem(), nil
+	})
+	if !slices.Contains(wfs.Schemes(), "test-scheme") {
+		t.Errorf("expected test-scheme in %v", wfs.Schemes())
+	}
+	if _, err := wfs.OpenURL(t.Context(), "TEST-SCHEME://host/dir?opt=1"); err != nil {
+		t.Fatalf("OpenURL failed: %v", err)
+	}
+	if opened == nil || opened.Host != "host" || opened.Path != "/dir" || opened.Query().Get("opt") != "1" {
+		t.Errorf("unexpected URL passed to the opener %v", opened)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected registering a scheme twice to panic")
+		}
+	}()
+	wfs.RegisterScheme("file", func(ctx context.Context, u *url.URL) (wfs.FS, error) { return nil, nil })
+}
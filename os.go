@@ -1,8 +1,11 @@
 package wfs
 
 import (
+	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 type osFs struct{}
@@ -13,34 +16,188 @@ func OS() FS {
 }
 
 func (osFs) Open(name string) (fs.File, error) {
-	return os.Open(name)
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, normErr(err)
+	}
+	return &osFile{f, name}, nil
 }
 
 func (osFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
-	return os.OpenFile(name, flag, perm)
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, normErr(err)
+	}
+	return &osFile{f, name}, nil
 }
 
 // Stat implements [fs.StatFS] for osFS.
 func (osFs) Stat(name string) (fs.FileInfo, error) {
-	return os.Stat(name)
+	info, err := os.Stat(name)
+	return info, normErr(err)
 }
 
 func (osFs) Rename(oldpath, newpath string) error {
-	return os.Rename(oldpath, newpath)
+	return normErr(os.Rename(oldpath, newpath))
 }
 
 func (osFs) Remove(name string) error {
-	return os.Remove(name)
+	return normErr(os.Remove(name))
 }
 
 func (osFs) RemoveAll(path string) error {
-	return os.RemoveAll(path)
+	return normErr(os.RemoveAll(path))
 }
 
 func (osFs) Mkdir(name string, perm fs.FileMode) error {
-	return os.Mkdir(name, perm)
+	return normErr(os.Mkdir(name, perm))
 }
 
 func (osFs) MkdirAll(path string, perm fs.FileMode) error {
-	return os.MkdirAll(path, perm)
+	return normErr(os.MkdirAll(path, perm))
+}
+
+func (osFs) Symlink(oldname, newname string) error {
+	return normErr(os.Symlink(oldname, newname))
+}
+
+func (osFs) Readlink(name string) (string, error) {
+	target, err := os.Readlink(name)
+	return target, normErr(err)
+}
+
+func (osFs) Lstat(name string) (fs.FileInfo, error) {
+	info, err := os.Lstat(name)
+	return info, normErr(err)
+}
+
+func (osFs) Link(oldname, newname string) error {
+	return normErr(os.Link(oldname, newname))
+}
+
+func (osFs) Chmod(name string, mode fs.FileMode) error {
+	return normErr(os.Chmod(name, mode))
+}
+
+func (osFs) Chown(name string, uid, gid int) error {
+	return normErr(os.Chown(name, uid, gid))
+}
+
+func (osFs) Chtimes(name string, atime, mtime time.Time) error {
+	return normErr(os.Chtimes(name, atime, mtime))
+}
+
+func (f osFs) Lock(name string) (Unlocker, error) {
+	return f.lock("lock", name, true, true)
+}
+
+func (f osFs) RLock(name string) (Unlocker, error) {
+	return f.lock("rlock", name, false, true)
+}
+
+func (f osFs) TryLock(name string) (Unlocker, error) {
+	return f.lock("lock", name, true, false)
+}
+
+func (osFs) lock(op, name string, exclusive, block bool) (Unlocker, error) {
+	return lockOSFile(op, name, func(flag int) (*os.File, error) {
+		f, err := os.OpenFile(name, flag, 0666)
+		return f, normErr(err)
+	}, exclusive, block)
+}
+
+// ReadDir implements [fs.ReadDirFS] for osFs.
+func (osFs) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := os.ReadDir(name)
+	return entries, normErr(err)
+}
+
+// ReadFile implements [fs.ReadFileFS] for osFs.
+func (osFs) ReadFile(name string) ([]byte, error) {
+	b, err := os.ReadFile(name)
+	return b, normErr(err)
+}
+
+// Glob implements [fs.GlobFS] for osFs.
+func (osFs) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+// Sub implements [fs.SubFS] for osFs, the returned file system is writable.
+func (f osFs) Sub(dir string) (fs.FS, error) {
+	return Sub(f, dir)
+}
+
+// osFile is a file of the OS file systems. It reports the name it was opened
+// with, and the errors of its methods are annotated with the portable errors
+// of this package like the errors of the file systems.
+type osFile struct {
+	*os.File
+	name string
+}
+
+func (f *osFile) Name() string {
+	return f.name
+}
+
+func (f *osFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	return n, pathErr(err, f.name)
+}
+
+func (f *osFile) ReadAt(b []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(b, off)
+	return n, pathErr(err, f.name)
+}
+
+func (f *osFile) ReadFrom(r io.Reader) (int64, error) {
+	n, err := f.File.ReadFrom(r)
+	return n, pathErr(err, f.name)
+}
+
+func (f *osFile) Write(b []byte) (int, error) {
+	n, err := f.File.Write(b)
+	return n, pathErr(err, f.name)
+}
+
+func (f *osFile) WriteString(s string) (int, error) {
+	n, err := f.File.WriteString(s)
+	return n, pathErr(err, f.name)
+}
+
+func (f *osFile) WriteAt(b []byte, off int64) (int, error) {
+	n, err := f.File.WriteAt(b, off)
+	return n, pathErr(err, f.name)
+}
+
+func (f *osFile) WriteTo(w io.Writer) (int64, error) {
+	n, err := f.File.WriteTo(w)
+	return n, pathErr(err, f.name)
+}
+
+func (f *osFile) Seek(offset int64, whence int) (int64, error) {
+	off, err := f.File.Seek(offset, whence)
+	return off, pathErr(err, f.name)
+}
+
+func (f *osFile) Truncate(size int64) error {
+	return pathErr(f.File.Truncate(size), f.name)
+}
+
+func (f *osFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := f.File.ReadDir(n)
+	return entries, pathErr(err, f.name)
+}
+
+func (f *osFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	return info, pathErr(err, f.name)
+}
+
+func (f *osFile) Sync() error {
+	return pathErr(f.File.Sync(), f.name)
+}
+
+func (f *osFile) Close() error {
+	return pathErr(f.File.Close(), f.name)
 }
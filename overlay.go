@@ -0,0 +1,772 @@
+package wfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// whiteoutPrefix prefixes the name of a marker file in the upper layer
+	// that hides the file of the same name in the lower layer.
+	whiteoutPrefix = ".wh."
+
+	// opaqueMarker marks an upper directory whose lower contents are hidden.
+	opaqueMarker = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+type overlayFs struct {
+	upper FS
+	lower fs.FS
+}
+
+// Overlay returns a file system that layers the writable file system upper
+// over the read-only file system lower.
+//
+// Files are read from upper if they exist there and from lower otherwise, and
+// directories list the entries of both layers. Every mutation is applied to
+// upper: a file of lower is copied up to upper with its parent directories
+// before it is opened for writing, renamed or has its metadata changed, and
+// removing a file of lower records a whiteout in upper that hides it.
+// Whiteouts are empty files named ".wh.<name>", so names starting with ".wh."
+// are reserved and rejected with [fs.ErrInvalid].
+//
+// Names must be valid according to [fs.ValidPath].
+func Overlay(upper FS, lower fs.FS) OverlayFS {
+	return &overlayFs{upper, lower}
+}
+
+// OverlayFS is a file system returned by [Overlay] whose changes can be
+// written down into its lower layer, such as edits kept in memory that are
+// saved to disk.
+//
+// Promote and Flush require the lower layer to implement [FS], otherwise
+// they fail with [errors.ErrUnsupported]. The merged view is unchanged by
+// both: files removed through the overlay are removed from lower, the
+// files and directories of upper replace those of lower, and they are
+// removed from upper once they are written to lower.
+type OverlayFS interface {
+	FS
+
+	// Promote writes the changes made to the named file or directory tree
+	// down into lower, with the parent directories that lower is missing.
+	// The name may be a file removed through the overlay.
+	Promote(name string) error
+
+	// Flush promotes every change, leaving upper empty.
+	Flush() error
+}
+
+// checkName returns an error if name is not valid or is reserved for whiteouts.
+func checkName(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if strings.HasPrefix(elem, whiteoutPrefix) {
+			return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+		}
+	}
+	return nil
+}
+
+func whiteout(name string) string {
+	return path.Join(path.Dir(name), whiteoutPrefix+path.Base(name))
+}
+
+func lexists(fsys fs.FS, name string) bool {
+	_, err := Lstat(fsys, name)
+	return err == nil
+}
+
+// lowerVisible reports whether name in lower is visible through upper,
+// that is neither name nor one of its parents is hidden by a whiteout,
+// an opaque directory or a file of upper.
+func (f *overlayFs) lowerVisible(name string) bool {
+	if name == "." {
+		return true
+	}
+	elems := strings.Split(name, "/")
+	for i := range elems {
+		p := strings.Join(elems[:i+1], "/")
+		if lexists(f.upper, whiteout(p)) {
+			return false
+		}
+		if i == len(elems)-1 {
+			break
+		}
+		info, err := Lstat(f.upper, p)
+		if err == nil && (!info.IsDir() || lexists(f.upper, path.Join(p, opaqueMarker))) {
+			return false
+		}
+	}
+	return true
+}
+
+// stat returns the file info of name in the merged view
+// and reports whether it exists in upper. Errors other than
+// [fs.ErrNotExist] from upper are returned without looking up lower.
+func (f *overlayFs) stat(name string) (fs.FileInfo, bool, error) {
+	info, err := fs.Stat(f.upper, name)
+	if err == nil {
+		return info, true, nil
+	}
+	if pe, ok := err.(*fs.PathError); ok {
+		err = pe.Err
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, err
+	}
+	if !f.lowerVisible(name) {
+		return nil, false, fs.ErrNotExist
+	}
+	info, err = fs.Stat(f.lower, name)
+	if err != nil {
+		if pe, ok := err.(*fs.PathError); ok {
+			err = pe.Err
+		}
+		return nil, false, err
+	}
+	return info, false, nil
+}
+
+// inLower reports whether name exists in lower and is visible through upper.
+func (f *overlayFs) inLower(name string) bool {
+	return f.lowerVisible(name) && lexists(f.lower, name)
+}
+
+// copyUp copies name and its parent directories from lower to upper
+// unless they already exist in upper.
+func (f *overlayFs) copyUp(name string) error {
+	if name == "." || lexists(f.upper, name) {
+		return nil
+	}
+	if err := f.copyUp(path.Dir(name)); err != nil {
+		return err
+	}
+	info, err := Lstat(f.lower, name)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.IsDir():
+		err = f.upper.Mkdir(name, info.Mode().Perm())
+	case info.Mode()&fs.ModeSymlink != 0:
+		var dest string
+		if dest, err = Readlink(f.lower, name); err == nil {
+			return Symlink(f.upper, dest, name)
+		}
+	default:
+		err = copyFile(f.upper, name, f.lower, name, info.Mode().Perm())
+	}
+	if err != nil {
+		return err
+	}
+	return copyMeta(f.upper, name, info)
+}
+
+// copyUpAll copies the tree rooted at name from lower to upper.
+func (f *overlayFs) copyUpAll(name string) error {
+	if err := f.copyUp(name); err != nil {
+		return err
+	}
+	info, err := Lstat(f.upper, name)
+	if err != nil || !info.IsDir() {
+		return err
+	}
+	entries, err := f.readDir(name)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := f.copyUpAll(path.Join(name, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDir returns the merged entries of the directory name sorted by name.
+func (f *overlayFs) readDir(name string) ([]fs.DirEntry, error) {
+	info, inUpper, err := f.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, ErrNotDir
+	}
+	var entries []fs.DirEntry
+	hidden := make(map[string]bool)
+	opaque := false
+	if inUpper {
+		upper, err := fs.ReadDir(f.upper, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range upper {
+			switch {
+			case e.Name() == opaqueMarker:
+				opaque = true
+			case strings.HasPrefix(e.Name(), whiteoutPrefix):
+				hidden[strings.TrimPrefix(e.Name(), whiteoutPrefix)] = true
+			default:
+				hidden[e.Name()] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+	if !opaque && f.lowerVisible(name) {
+		lower, _ := fs.ReadDir(f.lower, name)
+		for _, e := range lower {
+			if !hidden[e.Name()] {
+				entries = append(entries, e)
+			}
+		}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+// removeWhiteout removes the whiteout of name and reports whether it existed.
+func (f *overlayFs) removeWhiteout(name string) (bool, error) {
+	err := f.upper.Remove(whiteout(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// addWhiteout hides name of lower, copying up its parent directory
+// to hold the whiteout.
+func (f *overlayFs) addWhiteout(name string) error {
+	if err := f.copyUp(path.Dir(name)); err != nil {
+		return err
+	}
+	return WriteFile(f.upper, whiteout(name), nil, 0666)
+}
+
+// createParent checks that the parent of name is a directory
+// and copies it up to upper.
+func (f *overlayFs) createParent(name string) error {
+	dir := path.Dir(name)
+	info, _, err := f.stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return ErrNotDir
+	}
+	return f.copyUp(dir)
+}
+
+func (f *overlayFs) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for overlayFs.
+func (f *overlayFs) Stat(name string) (fs.FileInfo, error) {
+	if err := checkName("stat", name); err != nil {
+		return nil, err
+	}
+	info, _, err := f.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// ReadDir implements [fs.ReadDirFS] for overlayFs.
+func (f *overlayFs) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := checkName("readdir", name); err != nil {
+		return nil, err
+	}
+	entries, err := f.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+func (f *overlayFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if err := checkName("open", name); err != nil {
+		return nil, err
+	}
+	info, inUpper, err := f.stat(name)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		var file File
+		if inUpper {
+			file, err = f.upper.OpenFile(name, flag, perm)
+		} else {
+			file, err = openLower(f.lower, name)
+		}
+		if err != nil || !info.IsDir() {
+			return file, err
+		}
+		entries, err := f.readDir(name)
+		if err != nil {
+			file.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &overlayDir{File: file, entries: entries}, nil
+	}
+
+	switch {
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case err == nil && !inUpper:
+		err = f.copyUp(name)
+	case err != nil && flag&os.O_CREATE != 0:
+		if err = f.createParent(name); err == nil {
+			_, err = f.removeWhiteout(name)
+		}
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f.upper.OpenFile(name, flag, perm)
+}
+
+func (f *overlayFs) Rename(oldpath, newpath string) error {
+	if checkName("rename", oldpath) != nil || checkName("rename", newpath) != nil || oldpath == "." || newpath == "." {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	err := f.rename(oldpath, newpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	return nil
+}
+
+func (f *overlayFs) rename(oldpath, newpath string) error {
+	info, _, err := f.stat(oldpath)
+	if err != nil {
+		return err
+	}
+	if oldpath == newpath {
+		return nil
+	}
+	if err := f.createParent(newpath); err != nil {
+		return err
+	}
+	if target, _, err := f.stat(newpath); err == nil && target.IsDir() {
+		if !info.IsDir() {
+			return ErrIsDir
+		}
+		entries, err := f.readDir(newpath)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return ErrNotEmpty
+		}
+		// the empty target directory is replaced as a whole
+		if err := f.upper.RemoveAll(newpath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	} else if err == nil && info.IsDir() {
+		return ErrNotDir
+	}
+	oldLower, newLower := f.inLower(oldpath), f.inLower(newpath)
+	if err := f.copyUpAll(oldpath); err != nil {
+		return err
+	}
+	hadWhiteout, err := f.removeWhiteout(newpath)
+	if err != nil {
+		return err
+	}
+	if err := f.upper.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	if info.IsDir() && (newLower || hadWhiteout) {
+		if err := WriteFile(f.upper, path.Join(newpath, opaqueMarker), nil, 0666); err != nil {
+			return err
+		}
+	}
+	if oldLower {
+		return f.addWhiteout(oldpath)
+	}
+	return nil
+}
+
+func (f *overlayFs) Remove(name string) error {
+	if err := checkName("remove", name); err != nil {
+		return err
+	}
+	if err := f.remove(name); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *overlayFs) remove(name string) error {
+	info, inUpper, err := f.stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := f.readDir(name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return ErrNotEmpty
+		}
+	}
+	lower := f.inLower(name)
+	if inUpper {
+		// an empty directory may still contain whiteouts
+		if err := f.upper.RemoveAll(name); err != nil {
+			return err
+		}
+	}
+	if lower {
+		return f.addWhiteout(name)
+	}
+	return nil
+}
+
+func (f *overlayFs) RemoveAll(path string) error {
+	if err := checkName("RemoveAll", path); err != nil {
+		return err
+	}
+	if path == "." {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: fs.ErrInvalid}
+	}
+	if _, _, err := f.stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: err}
+	}
+	lower := f.inLower(path)
+	if err := f.upper.RemoveAll(path); err != nil {
+		return err
+	}
+	if lower {
+		if err := f.addWhiteout(path); err != nil {
+			return &fs.PathError{Op: "RemoveAll", Path: path, Err: err}
+		}
+	}
+	return nil
+}
+
+func (f *overlayFs) Mkdir(name string, perm fs.FileMode) error {
+	if err := checkName("mkdir", name); err != nil {
+		return err
+	}
+	if err := f.mkdir(name, perm); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *overlayFs) mkdir(name string, perm fs.FileMode) error {
+	if _, _, err := f.stat(name); err == nil {
+		return fs.ErrExist
+	}
+	if err := f.createParent(name); err != nil {
+		return err
+	}
+	hadWhiteout, err := f.removeWhiteout(name)
+	if err != nil {
+		return err
+	}
+	if err := f.upper.Mkdir(name, perm); err != nil {
+		return err
+	}
+	if hadWhiteout {
+		// the removed lower directory must not reappear
+		return WriteFile(f.upper, path.Join(name, opaqueMarker), nil, 0666)
+	}
+	return nil
+}
+
+func (f *overlayFs) MkdirAll(path string, perm fs.FileMode) error {
+	if err := checkName("mkdir", path); err != nil {
+		return err
+	}
+	if path == "." {
+		return nil
+	}
+	elems := strings.Split(path, "/")
+	for i := range elems {
+		dir := strings.Join(elems[:i+1], "/")
+		info, _, err := f.stat(dir)
+		if err == nil && !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: ErrNotDir}
+		}
+		if err == nil {
+			continue
+		}
+		if err := f.mkdir(dir, perm); err != nil {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: err}
+		}
+	}
+	return nil
+}
+
+// meta copies name up to upper before its metadata is changed by fn.
+func (f *overlayFs) meta(op, name string, fn func() error) error {
+	if err := checkName(op, name); err != nil {
+		return err
+	}
+	if _, _, err := f.stat(name); err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if err := f.copyUp(name); err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return fn()
+}
+
+// Chmod implements [MetaFS] for overlayFs.
+func (f *overlayFs) Chmod(name string, mode fs.FileMode) error {
+	return f.meta("chmod", name, func() error { return Chmod(f.upper, name, mode) })
+}
+
+// Chown implements [MetaFS] for overlayFs.
+func (f *overlayFs) Chown(name string, uid, gid int) error {
+	return f.meta("chown", name, func() error { return Chown(f.upper, name, uid, gid) })
+}
+
+// Chtimes implements [MetaFS] for overlayFs.
+func (f *overlayFs) Chtimes(name string, atime, mtime time.Time) error {
+	return f.meta("chtimes", name, func() error { return Chtimes(f.upper, name, atime, mtime) })
+}
+
+func (f *overlayFs) Promote(name string) error {
+	if err := checkName("promote", name); err != nil {
+		return err
+	}
+	if err := f.promote(name); err != nil {
+		return &fs.PathError{Op: "promote", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *overlayFs) Flush() error {
+	if err := f.promote("."); err != nil {
+		return &fs.PathError{Op: "flush", Path: ".", Err: err}
+	}
+	return nil
+}
+
+func (f *overlayFs) promote(name string) error {
+	lower, ok := f.lower.(FS)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if _, _, err := f.stat(name); err != nil && !lexists(f.upper, whiteout(name)) {
+		return err
+	}
+	if err := f.promoteParents(lower, path.Dir(name)); err != nil {
+		return err
+	}
+	return f.promoteTree(lower, name)
+}
+
+// promoteParents creates the directories of upper from dir up to the root
+// that are missing in lower.
+func (f *overlayFs) promoteParents(lower FS, dir string) error {
+	if dir == "." {
+		return nil
+	}
+	if err := f.promoteParents(lower, path.Dir(dir)); err != nil {
+		return err
+	}
+	info, err := Lstat(f.upper, dir)
+	if err != nil {
+		// the directory is only in lower
+		return nil
+	}
+	return promoteDir(lower, dir, info)
+}
+
+// promoteDir replaces name in lower with a directory unless it is one.
+func promoteDir(lower FS, name string, info fs.FileInfo) error {
+	linfo, err := Lstat(lower, name)
+	switch {
+	case err == nil && linfo.IsDir():
+		return nil
+	case err == nil:
+		if err := lower.Remove(name); err != nil {
+			return err
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	if err := lower.Mkdir(name, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return copyMeta(lower, name, info)
+}
+
+// promoteTree writes name of upper and the files below it down into lower,
+// and removes them and the whiteouts applied to lower from upper.
+func (f *overlayFs) promoteTree(lower FS, name string) error {
+	if name != "." && lexists(f.upper, whiteout(name)) {
+		if err := lower.RemoveAll(name); err != nil {
+			return err
+		}
+		return f.upper.Remove(whiteout(name))
+	}
+	info, err := Lstat(f.upper, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		// unchanged file of lower
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if linfo, err := Lstat(lower, name); err == nil && !(linfo.Mode().IsRegular() && info.Mode().IsRegular()) {
+			// a regular file is replaced in place, anything else is removed
+			// so that a symbolic link of lower is not followed
+			if err := lower.RemoveAll(name); err != nil {
+				return err
+			}
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			dest, err := Readlink(f.upper, name)
+			if err == nil {
+				err = Symlink(lower, dest, name)
+			}
+			if err != nil {
+				return err
+			}
+		} else {
+			if err := copyFile(lower, name, f.upper, name, info.Mode().Perm()); err != nil {
+				return err
+			}
+			if err := copyMeta(lower, name, info); err != nil {
+				return err
+			}
+		}
+		return f.upper.Remove(name)
+	}
+
+	if name != "." {
+		if err := promoteDir(lower, name, info); err != nil {
+			return err
+		}
+	}
+	if marker := path.Join(name, opaqueMarker); lexists(f.upper, marker) {
+		// the entries of lower are hidden by the directory of upper
+		entries, err := fs.ReadDir(lower, name)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := lower.RemoveAll(path.Join(name, e.Name())); err != nil {
+				return err
+			}
+		}
+		if err := f.upper.Remove(marker); err != nil {
+			return err
+		}
+	}
+	entries, err := fs.ReadDir(f.upper, name)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		child := strings.TrimPrefix(e.Name(), whiteoutPrefix)
+		if err := f.promoteTree(lower, path.Join(name, child)); err != nil {
+			return err
+		}
+	}
+	if name == "." {
+		return nil
+	}
+	if err := copyMeta(lower, name, info); err != nil {
+		return err
+	}
+	return f.upper.Remove(name)
+}
+
+// overlayDir lists the merged entries of a directory.
+type overlayDir struct {
+	File
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *overlayDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.entries[d.offset:]
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
+
+// lowerFile adapts a file of a read-only file system to [File].
+type lowerFile struct {
+	fs.File
+	name string
+}
+
+func openLower(fsys fs.FS, name string) (File, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &lowerFile{f, name}, nil
+}
+
+func (f *lowerFile) Name() string {
+	return f.name
+}
+
+func (f *lowerFile) Seek(offset int64, whence int) (int64, error) {
+	if s, ok := f.File.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+	return 0, &fs.PathError{Op: "seek", Path: f.name, Err: errors.ErrUnsupported}
+}
+
+func (f *lowerFile) ReadAt(b []byte, off int64) (int, error) {
+	if r, ok := f.File.(io.ReaderAt); ok {
+		return r.ReadAt(b, off)
+	}
+	return 0, &fs.PathError{Op: "read", Path: f.name, Err: errors.ErrUnsupported}
+}
+
+func (f *lowerFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d, ok := f.File.(fs.ReadDirFile); ok {
+		return d.ReadDir(n)
+	}
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: ErrNotDir}
+}
+
+func (f *lowerFile) Write(b []byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: errBadFile}
+}
+
+func (f *lowerFile) WriteString(s string) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: errBadFile}
+}
+
+func (f *lowerFile) WriteAt(b []byte, off int64) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: errBadFile}
+}
+
+func (f *lowerFile) Truncate(size int64) error {
+	return &fs.PathError{Op: "truncate", Path: f.name, Err: errBadFile}
+}
+
+func (f *lowerFile) Sync() error {
+	return nil
+}
@@ -0,0 +1,230 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/wfstest"
+)
+
+func TestOverlay(t *testing.T) {
+	lower := fstest.MapFS{
+		"config.json":        &fstest.MapFile{Data: []byte("default"), Mode: 0644},
+		"themes/light.css":   &fstest.MapFile{Data: []byte("light")},
+		"themes/dark.css":    &fstest.MapFile{Data: []byte("dark")},
+		"plugins/a/main.js":  &fstest.MapFile{Data: []byte("a")},
+		"plugins/b/main.js":  &fstest.MapFile{Data: []byte("b")},
+		"readonly/file.txt":  &fstest.MapFile{Data: []byte("file")},
+		"readonly/other.txt": &fstest.MapFile{Data: []byte("other")},
+	}
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			upper, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"themes/custom.css": &fstest.MapFile{Data: []byte("custom")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			if base != "" {
+				if upper, err = wfs.Sub(upper, filepath.ToSlash(base)); err != nil {
+					t.Fatalf("failed to create sub file system: %v", err)
+				}
+			}
+			fsys := wfs.Overlay(upper, lower)
+
+			// reads fall through and directories are merged
+			assertContent(t, fsys, "config.json", "default")
+			assertEntries(t, fsys, "themes", "custom.css", "dark.css", "light.css")
+
+			// writes copy up
+			if err := wfs.AppendFile(fsys, "config.json", []byte("+user"), 0644); err != nil {
+				t.Fatalf("AppendFile failed: %v", err)
+			}
+			assertContent(t, fsys, "config.json", "default+user")
+			assertContent(t, upper, "config.json", "default+user")
+			assertContent(t, lower, "config.json", "default")
+
+			// removals are recorded as whiteouts
+			if err := fsys.Remove("themes/dark.css"); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+			assertEntries(t, fsys, "themes", "custom.css", "light.css")
+			if _, err := fs.Stat(fsys, "themes/dark.css"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected removed file to be hidden, got %v", err)
+			}
+			if err := wfs.WriteFile(fsys, "themes/dark.css", []byte("new dark"), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			assertContent(t, fsys, "themes/dark.css", "new dark")
+			if err := fsys.Remove("plugins"); !errors.Is(err, wfs.ErrNotEmpty) {
+				t.Errorf("expected ErrNotEmpty, got %v", err)
+			}
+
+			// recreated directories do not show removed lower contents
+			if err := fsys.RemoveAll("plugins"); err != nil {
+				t.Fatalf("RemoveAll failed: %v", err)
+			}
+			if err := fsys.MkdirAll("plugins/b", 0755); err != nil {
+				t.Fatalf("MkdirAll failed: %v", err)
+			}
+			assertEntries(t, fsys, "plugins", "b")
+			assertEntries(t, fsys, "plugins/b")
+
+			// renames copy up whole trees
+			if err := fsys.Rename("readonly", "moved"); err != nil {
+				t.Fatalf("Rename failed: %v", err)
+			}
+			assertEntries(t, fsys, ".", "config.json", "moved", "plugins", "themes")
+			assertEntries(t, fsys, "moved", "file.txt", "other.txt")
+			assertContent(t, fsys, "moved/other.txt", "other")
+			if err := fsys.Rename("moved/file.txt", "themes/light.css"); err != nil {
+				t.Fatalf("Rename failed: %v", err)
+			}
+			assertContent(t, fsys, "themes/light.css", "file")
+
+			// metadata changes copy up
+			if err := wfs.Chmod(fsys, "themes/custom.css", 0600); err != nil {
+				t.Fatalf("Chmod failed: %v", err)
+			}
+
+			if _, err := fsys.OpenFile("config.json", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644); !errors.Is(err, fs.ErrExist) {
+				t.Errorf("expected ErrExist, got %v", err)
+			}
+			if err := wfs.WriteFile(fsys, ".wh.secret", nil, 0644); !errors.Is(err, fs.ErrInvalid) {
+				t.Errorf("expected whiteout names to be rejected, got %v", err)
+			}
+			if err := fstest.TestFS(fsys, "config.json", "moved/other.txt", "themes/dark.css", "plugins/b"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestOverlayLowerParents(t *testing.T) {
+	lower := fstest.MapFS{
+		"a/b/file":  &fstest.MapFile{Data: []byte("file")},
+		"a/b/other": &fstest.MapFile{Data: []byte("other")},
+		"c/d/e":     &fstest.MapFile{Data: []byte("e")},
+		"c/f":       &fstest.MapFile{Data: []byte("f")},
+	}
+	upper := wfs.Mem()
+	fsys := wfs.Overlay(upper, lower)
+
+	// the parents of a removed file are copied up to hold its whiteout
+	if err := fsys.Remove("a/b/file"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := fs.Stat(fsys, "a/b/file"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected removed file to be hidden, got %v", err)
+	}
+	assertEntries(t, fsys, "a/b", "other")
+	if err := fsys.RemoveAll("c/d"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	assertEntries(t, fsys, "c", "f")
+	assertEntries(t, lower, "c", "d", "f")
+
+	// errors of upper other than ErrNotExist are not hidden by lower
+	if err := wfs.WriteFile(upper, "a/b/other", nil, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := fs.Stat(fsys, "a/b/other/x"); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("expected ErrNotDir below a file, got %v", err)
+	}
+	if err := fsys.RemoveAll("a/b/other/x"); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("expected RemoveAll below a file to fail with ErrNotDir, got %v", err)
+	}
+}
+
+func TestOverlayPromote(t *testing.T) {
+	lower := wfs.Mem()
+	if err := wfstest.Write(lower, fstest.MapFS{
+		"file":       &fstest.MapFile{Data: []byte("lower")},
+		"dir/old":    &fstest.MapFile{Data: []byte("old")},
+		"dir/keep":   &fstest.MapFile{Data: []byte("keep")},
+		"replaced/x": &fstest.MapFile{Data: []byte("x")},
+		"other":      &fstest.MapFile{Data: []byte("other")},
+	}); err != nil {
+		t.Fatalf("failed to write lower: %v", err)
+	}
+	upper := wfs.Mem()
+	fsys := wfs.Overlay(upper, lower)
+
+	wfs.WriteFile(fsys, "file", []byte("upper"), 0644)
+	fsys.Remove("dir/old")
+	fsys.Mkdir("dir/new", 0755)
+	wfs.WriteFile(fsys, "dir/new/file", []byte("new"), 0644)
+	fsys.RemoveAll("replaced")
+	fsys.Mkdir("replaced", 0755)
+	wfs.WriteFile(fsys, "replaced/y", []byte("y"), 0644)
+
+	// promoting a directory leaves the other changes in upper
+	if err := fsys.Promote("dir"); err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+	assertEntries(t, lower, "dir", "keep", "new")
+	assertContent(t, lower, "dir/new/file", "new")
+	assertContent(t, lower, "file", "lower")
+	assertEntries(t, upper, ".", "file", "replaced")
+	assertEntries(t, fsys, "dir", "keep", "new")
+
+	// a removed file is promoted by removing it from lower
+	fsys.Remove("other")
+	if err := fsys.Promote("other"); err != nil {
+		t.Fatalf("Promote of a removed file failed: %v", err)
+	}
+	if _, err := fs.Stat(lower, "other"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected removed file to be removed from lower, got %v", err)
+	}
+	if err := fsys.Promote("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected Promote of a missing file to fail with ErrNotExist, got %v", err)
+	}
+
+	// the lower entries of a replaced directory are removed
+	if err := fsys.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	assertEntries(t, upper, ".")
+	assertEntries(t, lower, ".", "dir", "file", "replaced")
+	assertEntries(t, lower, "replaced", "y")
+	assertContent(t, lower, "file", "upper")
+	assertEntries(t, fsys, "replaced", "y")
+
+	// the lower layer must be writable
+	ro := wfs.Overlay(wfs.Mem(), fstest.MapFS{})
+	wfs.WriteFile(ro, "file", nil, 0644)
+	if err := ro.Flush(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected Flush into a read-only lower to fail with ErrUnsupported, got %v", err)
+	}
+}
+
+func assertContent(t *testing.T, fsys fs.FS, name, expected string) {
+	t.Helper()
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil || string(b) != expected {
+		t.Errorf("expected %q to contain %q, got %q err: %v", name, expected, b, err)
+	}
+}
+
+func assertEntries(t *testing.T, fsys fs.FS, dir string, expected ...string) {
+	t.Helper()
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		t.Errorf("failed to read %q: %v", dir, err)
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %q to contain %v, got %v", dir, expected, names)
+	}
+}
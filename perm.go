@@ -0,0 +1,180 @@
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"time"
+)
+
+// PermOption configures the file system returned by [WithPerms].
+type PermOption func(*permFs)
+
+// Umask clears the permission bits in mask from the mode of created files
+// and directories.
+func Umask(mask fs.FileMode) PermOption {
+	return func(f *permFs) { f.umask = mask & fs.ModePerm }
+}
+
+// DefaultPerm creates files with the permission bits file and directories
+// with the permission bits dir, instead of the permissions passed to OpenFile,
+// Mkdir and MkdirAll. The umask still applies.
+func DefaultPerm(file, dir fs.FileMode) PermOption {
+	return func(f *permFs) {
+		f.filePerm = file & fs.ModePerm
+		f.dirPerm = dir & fs.ModePerm
+		f.defaults = true
+	}
+}
+
+// WithPerms returns a file system that gives files and directories created
+// in fsys the same permissions on every backend. The permissions are computed
+// from the options and applied with [Chmod] after creation, so the umask of
+// the process does not affect them. Backends that do not implement [MetaFS]
+// keep the permissions they create files with.
+//
+// Without options, created files and directories get exactly the permissions
+// they are created with.
+func WithPerms(fsys FS, opts ...PermOption) FS {
+	f := &permFs{fsys: fsys}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+type permFs struct {
+	fsys     FS
+	umask    fs.FileMode
+	defaults bool
+	filePerm fs.FileMode
+	dirPerm  fs.FileMode
+}
+
+// perm returns the permission bits of a created file or directory
+// requested with perm.
+func (f *permFs) perm(perm fs.FileMode, dir bool) fs.FileMode {
+	if f.defaults {
+		perm = f.filePerm
+		if dir {
+			perm = f.dirPerm
+		}
+	}
+	return perm & fs.ModePerm &^ f.umask
+}
+
+// chmod sets the mode of a created file, backends without MetaFS are ignored.
+func (f *permFs) chmod(name string, mode fs.FileMode) error {
+	if err := Chmod(f.fsys, name, mode); !errors.Is(err, errors.ErrUnsupported) {
+		return err
+	}
+	return nil
+}
+
+func (f *permFs) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+// Stat implements [fs.StatFS] for permFs.
+func (f *permFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+func (f *permFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if flag&os.O_CREATE == 0 {
+		return f.fsys.OpenFile(name, flag, perm)
+	}
+	_, err := Lstat(f.fsys, name)
+	created := errors.Is(err, fs.ErrNotExist)
+	mode := f.perm(perm, false)
+	file, err := f.fsys.OpenFile(name, flag, mode)
+	if err != nil || !created {
+		return file, err
+	}
+	if err := f.chmod(name, mode); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func (f *permFs) Rename(oldpath, newpath string) error {
+	return f.fsys.Rename(oldpath, newpath)
+}
+
+func (f *permFs) Remove(name string) error {
+	return f.fsys.Remove(name)
+}
+
+func (f *permFs) RemoveAll(path string) error {
+	return f.fsys.RemoveAll(path)
+}
+
+func (f *permFs) Mkdir(name string, perm fs.FileMode) error {
+	mode := f.perm(perm, true)
+	if err := f.fsys.Mkdir(name, mode); err != nil {
+		return err
+	}
+	return f.chmod(name, mode)
+}
+
+// MkdirAll creates the missing directories one at a time
+// to set the permissions of each.
+func (f *permFs) MkdirAll(name string, perm fs.FileMode) error {
+	info, err := fs.Stat(f.fsys, name)
+	if err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		return &fs.PathError{Op: "mkdir", Path: name, Err: ErrNotDir}
+	}
+	if dir := path.Dir(name); dir != name {
+		if err := f.MkdirAll(dir, perm); err != nil {
+			return err
+		}
+	}
+	err = f.Mkdir(name, perm)
+	if errors.Is(err, fs.ErrExist) {
+		// created concurrently, or name is the root of an absolute path
+		if info, serr := fs.Stat(f.fsys, name); serr == nil && info.IsDir() {
+			return nil
+		}
+	}
+	return err
+}
+
+// Symlink implements [SymlinkFS] for permFs.
+func (f *permFs) Symlink(oldname, newname string) error {
+	return Symlink(f.fsys, oldname, newname)
+}
+
+// Readlink implements [SymlinkFS] for permFs.
+func (f *permFs) Readlink(name string) (string, error) {
+	return Readlink(f.fsys, name)
+}
+
+// Lstat implements [SymlinkFS] for permFs.
+func (f *permFs) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(f.fsys, name)
+}
+
+// Link implements [LinkFS] for permFs.
+func (f *permFs) Link(oldname, newname string) error {
+	return Link(f.fsys, oldname, newname)
+}
+
+// Chmod implements [MetaFS] for permFs.
+func (f *permFs) Chmod(name string, mode fs.FileMode) error {
+	return Chmod(f.fsys, name, mode)
+}
+
+// Chown implements [MetaFS] for permFs.
+func (f *permFs) Chown(name string, uid, gid int) error {
+	return Chown(f.fsys, name, uid, gid)
+}
+
+// Chtimes implements [MetaFS] for permFs.
+func (f *permFs) Chtimes(name string, atime, mtime time.Time) error {
+	return Chtimes(f.fsys, name, atime, mtime)
+}
@@ -0,0 +1,64 @@
+package wfs_test
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestWithPerms(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"existing": &fstest.MapFile{Data: []byte("Hello"), Mode: 0600},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			assertMode := func(t *testing.T, fsys wfs.FS, name string, expected fs.FileMode) {
+				t.Helper()
+				info, err := fs.Stat(fsys, name)
+				if err != nil || info.Mode().Perm() != expected {
+					t.Errorf("expected %s to have mode %v, got %v err: %v", name, expected, info.Mode().Perm(), err)
+				}
+			}
+
+			pfs := wfs.WithPerms(fsys, wfs.Umask(0o027))
+			file := filepath.Join(base, "file")
+			if err := wfs.WriteFile(pfs, file, []byte("Hello"), 0666); err != nil {
+				t.Fatal(err)
+			}
+			assertMode(t, fsys, file, 0640)
+			dir := filepath.Join(base, "a/b")
+			if err := pfs.MkdirAll(dir, 0777); err != nil {
+				t.Fatal(err)
+			}
+			assertMode(t, fsys, filepath.Join(base, "a"), 0750)
+			assertMode(t, fsys, dir, 0750)
+
+			// existing files keep their mode
+			existing := filepath.Join(base, "existing")
+			if err := wfs.WriteFile(pfs, existing, []byte("Hi"), 0666); err != nil {
+				t.Fatal(err)
+			}
+			assertMode(t, fsys, existing, 0600)
+
+			pfs = wfs.WithPerms(fsys, wfs.DefaultPerm(0o664, 0o775), wfs.Umask(0o002))
+			file = filepath.Join(base, "a/file")
+			if err := wfs.WriteFile(pfs, file, []byte("Hello"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			assertMode(t, fsys, file, 0664)
+			dir = filepath.Join(base, "a/c")
+			if err := pfs.Mkdir(dir, 0700); err != nil {
+				t.Fatal(err)
+			}
+			assertMode(t, fsys, dir, 0775)
+		})
+	}
+}
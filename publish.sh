@@ -9,12 +9,34 @@ echo "Publishing version v$NEW_VERSION"
 # Check if tag already exists
 if git rev-parse "v$NEW_VERSION" >/dev/null 2>&1; then
   echo "Tag v$NEW_VERSION already exists, skipping tag creation"
-else
-  # Create tag
-  git tag -a v$NEW_VERSION -m "Release v$NEW_VERSION"
-  echo "✓ Created tag v$NEW_VERSION"
+  exit 0
+fi
+
+# Commit and tag as the actions bot unless an identity is configured
+git config user.name >/dev/null || git config user.name "github-actions[bot]"
+git config user.email >/dev/null || git config user.email "41898282+github-actions[bot]@users.noreply.github.com"
 
-  # Push the tag to remote
-  git push origin v$NEW_VERSION
-  echo "✓ Pushed tag v$NEW_VERSION to remote"
+# Require the new version from the nested modules, which only use the
+# replace directive to the parent directory for local development
+for mod in */go.mod; do
+  (cd "$(dirname "$mod")" && go mod edit -require="github.com/eriicafes/wfs@v$NEW_VERSION")
+done
+if ! git diff --quiet; then
+  git commit -am "Require wfs v$NEW_VERSION in nested modules"
+  git push origin HEAD
+  echo "✓ Required wfs v$NEW_VERSION in nested modules"
 fi
+
+# Create tags, nested modules are tagged with their directory as prefix
+TAGS="v$NEW_VERSION"
+for mod in */go.mod; do
+  TAGS="$TAGS $(dirname "$mod")/v$NEW_VERSION"
+done
+for tag in $TAGS; do
+  git tag -a "$tag" -m "Release $tag"
+  echo "✓ Created tag $tag"
+done
+
+# Push the tags to remote
+git push origin $TAGS
+echo "✓ Pushed tags $TAGS to remote"
@@ -0,0 +1,81 @@
+package wfs
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+type readOnlyFs struct{ fsys FS }
+
+// ReadOnly returns a file system that passes reads through to fsys and
+// rejects every mutation with an error wrapping [fs.ErrPermission].
+// OpenFile fails if any of the [os.O_WRONLY], [os.O_RDWR], [os.O_APPEND],
+// [os.O_CREATE] or [os.O_TRUNC] flags are passed.
+func ReadOnly(fsys FS) FS {
+	return readOnlyFs{fsys}
+}
+
+func (f readOnlyFs) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+// Stat implements [fs.StatFS] for readOnlyFs.
+func (f readOnlyFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+func (f readOnlyFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return f.fsys.OpenFile(name, flag, perm)
+}
+
+func (f readOnlyFs) Rename(oldpath, newpath string) error {
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrPermission}
+}
+
+func (f readOnlyFs) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+}
+
+func (f readOnlyFs) RemoveAll(path string) error {
+	return &fs.PathError{Op: "RemoveAll", Path: path, Err: fs.ErrPermission}
+}
+
+func (f readOnlyFs) Mkdir(name string, perm fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrPermission}
+}
+
+func (f readOnlyFs) MkdirAll(path string, perm fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrPermission}
+}
+
+func (f readOnlyFs) Symlink(oldname, newname string) error {
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.ErrPermission}
+}
+
+func (f readOnlyFs) Readlink(name string) (string, error) {
+	return Readlink(f.fsys, name)
+}
+
+func (f readOnlyFs) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(f.fsys, name)
+}
+
+func (f readOnlyFs) Link(oldname, newname string) error {
+	return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrPermission}
+}
+
+func (f readOnlyFs) Chmod(name string, mode fs.FileMode) error {
+	return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrPermission}
+}
+
+func (f readOnlyFs) Chown(name string, uid, gid int) error {
+	return &fs.PathError{Op: "chown", Path: name, Err: fs.ErrPermission}
+}
+
+func (f readOnlyFs) Chtimes(name string, atime, mtime time.Time) error {
+	return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrPermission}
+}
@@ -0,0 +1,60 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestReadOnly(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"testfile": &fstest.MapFile{Data: []byte("Hello")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			rofs := wfs.ReadOnly(fsys)
+			filePath := filepath.Join(base, "testfile")
+			b, err := fs.ReadFile(rofs, filePath)
+			if err != nil || string(b) != "Hello" {
+				t.Errorf("expected to read 'Hello', got %q err: %v", b, err)
+			}
+			f, err := rofs.OpenFile(filePath, os.O_RDONLY, 0)
+			if err != nil {
+				t.Fatalf("OpenFile should succeed for reads: %v", err)
+			}
+			f.Close()
+
+			newPath := filepath.Join(base, "newfile")
+			dirPath := filepath.Join(base, "dir")
+			errs := map[string]error{
+				"WriteFile": wfs.WriteFile(rofs, filePath, nil, 0644),
+				"Rename":    rofs.Rename(filePath, newPath),
+				"Remove":    rofs.Remove(filePath),
+				"RemoveAll": rofs.RemoveAll(filePath),
+				"Mkdir":     rofs.Mkdir(dirPath, 0755),
+				"MkdirAll":  rofs.MkdirAll(dirPath, 0755),
+				"Chmod":     wfs.Chmod(rofs, filePath, 0600),
+				"Chtimes":   wfs.Chtimes(rofs, filePath, time.Time{}, time.Now()),
+			}
+			for op, err := range errs {
+				if !errors.Is(err, fs.ErrPermission) {
+					t.Errorf("%s: expected ErrPermission, got %v", op, err)
+				}
+			}
+			if b, err := fs.ReadFile(fsys, filePath); err != nil || string(b) != "Hello" {
+				t.Errorf("expected file to be unchanged, got %q err: %v", b, err)
+			}
+		})
+	}
+}
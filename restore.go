@@ -0,0 +1,158 @@
+package wfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// RestoreAction is the action taken for a file that already exists
+// at the restore destination.
+type RestoreAction int
+
+const (
+	// RestoreOverwrite replaces the existing file with the backup.
+	RestoreOverwrite RestoreAction = iota + 1
+
+	// RestoreSkip keeps the existing file and does not restore the backup.
+	RestoreSkip
+
+	// RestoreKeepBoth keeps the existing file and restores the backup
+	// next to it under a name with a ".restored" suffix.
+	RestoreKeepBoth
+)
+
+// RestoreStrategy decides the action for a file named name that exists
+// both at the restore destination, described by current, and in the backup.
+// Returning an error aborts the restore.
+type RestoreStrategy func(name string, current, backup fs.FileInfo) (RestoreAction, error)
+
+// StrategyOverwrite is a RestoreStrategy that always overwrites existing files.
+func StrategyOverwrite(name string, current, backup fs.FileInfo) (RestoreAction, error) {
+	return RestoreOverwrite, nil
+}
+
+// StrategyKeepNewer is a RestoreStrategy that overwrites existing files
+// only when the backup is newer.
+func StrategyKeepNewer(name string, current, backup fs.FileInfo) (RestoreAction, error) {
+	if current.ModTime().After(backup.ModTime()) {
+		return RestoreSkip, nil
+	}
+	return RestoreOverwrite, nil
+}
+
+// StrategyKeepBoth is a RestoreStrategy that always keeps existing files
+// and restores the backup under a suffixed name.
+func StrategyKeepBoth(name string, current, backup fs.FileInfo) (RestoreAction, error) {
+	return RestoreKeepBoth, nil
+}
+
+// RestoreSummary reports the actions taken by [Restore].
+// All paths are relative to the restore destination.
+type RestoreSummary struct {
+	// Created lists files that did not exist and were restored.
+	Created []string
+
+	// Overwritten lists existing files replaced by the backup.
+	Overwritten []string
+
+	// Skipped lists existing files that were kept.
+	Skipped []string
+
+	// KeptBoth lists the suffixed names backups were restored to
+	// next to existing files.
+	KeptBoth []string
+}
+
+// Restore copies the tree rooted at root in backup into the directory dir of dst.
+// Files that do not exist in dst are created, and files that already exist
+// are resolved by strategy.
+func Restore(dst FS, dir string, backup fs.FS, root string, strategy RestoreStrategy) (RestoreSummary, error) {
+	var summary RestoreSummary
+	err := fs.WalkDir(backup, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel := relPath(root, name)
+		target := path.Join(dir, rel)
+		if d.IsDir() {
+			if name == root {
+				target = dir
+			}
+			return dst.MkdirAll(target, info.Mode().Perm())
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		current, err := fs.Stat(dst, target)
+		if errors.Is(err, fs.ErrNotExist) {
+			summary.Created = append(summary.Created, rel)
+			return copyFile(dst, target, backup, name, info.Mode().Perm())
+		}
+		if err != nil {
+			return err
+		}
+		action, err := strategy(rel, current, info)
+		if err != nil {
+			return err
+		}
+		switch action {
+		case RestoreOverwrite:
+			summary.Overwritten = append(summary.Overwritten, rel)
+			return copyFile(dst, target, backup, name, info.Mode().Perm())
+		case RestoreSkip:
+			summary.Skipped = append(summary.Skipped, rel)
+			return nil
+		case RestoreKeepBoth:
+			target, err = restoredName(dst, target)
+			if err != nil {
+				return err
+			}
+			summary.KeptBoth = append(summary.KeptBoth, relPath(dir, target))
+			return copyFile(dst, target, backup, name, info.Mode().Perm())
+		}
+		return fmt.Errorf("wfs: invalid restore action %d for %s", action, rel)
+	})
+	return summary, err
+}
+
+// restoredName returns the first unused name of the form "name.restored.ext"
+// or "name.restored.N.ext".
+func restoredName(fsys fs.FS, name string) (string, error) {
+	return suffixedName(name, ".restored", fsys)
+}
+
+// suffixedName returns the first name of the form "name<suffix>.ext" or
+// "name<suffix>.N.ext" that is unused in all of fsyss.
+func suffixedName(name, suffix string, fsyss ...fs.FS) (string, error) {
+	ext := path.Ext(name)
+	if strings.HasPrefix(path.Base(name), ".") && path.Base(name) == ext {
+		ext = ""
+	}
+	stem := strings.TrimSuffix(name, ext) + suffix
+	candidate := stem + ext
+	for i := 2; ; i++ {
+		used := false
+		for _, fsys := range fsyss {
+			_, err := fs.Stat(fsys, candidate)
+			if err == nil {
+				used = true
+				break
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+		}
+		if !used {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s.%d%s", stem, i, ext)
+	}
+}
@@ -0,0 +1,90 @@
+package wfs_test
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestRestore(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name     string
+		strategy wfs.RestoreStrategy
+		summary  wfs.RestoreSummary
+		files    map[string]string
+	}{
+		{
+			name:     "Overwrite",
+			strategy: wfs.StrategyOverwrite,
+			summary:  wfs.RestoreSummary{Created: []string{"new"}, Overwritten: []string{"edited", "newer"}},
+			files:    map[string]string{"new": "new", "edited": "backup", "newer": "backup"},
+		},
+		{
+			name:     "KeepNewer",
+			strategy: wfs.StrategyKeepNewer,
+			summary:  wfs.RestoreSummary{Created: []string{"new"}, Overwritten: []string{"edited"}, Skipped: []string{"newer"}},
+			files:    map[string]string{"new": "new", "edited": "backup", "newer": "current"},
+		},
+		{
+			name:     "KeepBoth",
+			strategy: wfs.StrategyKeepBoth,
+			summary:  wfs.RestoreSummary{Created: []string{"new"}, KeptBoth: []string{"edited.restored", "newer.restored"}},
+			files:    map[string]string{"edited": "current", "edited.restored": "backup", "newer.restored": "backup"},
+		},
+		{
+			name: "Callback",
+			strategy: func(name string, current, backup fs.FileInfo) (wfs.RestoreAction, error) {
+				if name == "edited" {
+					return wfs.RestoreSkip, nil
+				}
+				return wfs.RestoreOverwrite, nil
+			},
+			summary: wfs.RestoreSummary{Created: []string{"new"}, Overwritten: []string{"newer"}, Skipped: []string{"edited"}},
+			files:   map[string]string{"edited": "current", "newer": "backup"},
+		},
+	}
+
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, tc := range tests {
+				t.Run(tc.name, func(t *testing.T) {
+					fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+						"backup/new":    &fstest.MapFile{Data: []byte("new"), ModTime: old},
+						"backup/edited": &fstest.MapFile{Data: []byte("backup"), ModTime: old},
+						"backup/newer":  &fstest.MapFile{Data: []byte("backup"), ModTime: old},
+						"live/edited":   &fstest.MapFile{Data: []byte("current"), ModTime: old.Add(-time.Hour)},
+						"live/newer":    &fstest.MapFile{Data: []byte("current"), ModTime: time.Now()},
+					})
+					if err != nil {
+						t.Fatalf("failed to create file system: %v", err)
+					}
+					defer cleanup()
+
+					live := filepath.Join(base, "live")
+					summary, err := wfs.Restore(fsys, live, fsys, filepath.Join(base, "backup"), tc.strategy)
+					if err != nil {
+						t.Fatalf("Restore failed: %v", err)
+					}
+					if !slices.Equal(summary.Created, tc.summary.Created) ||
+						!slices.Equal(summary.Overwritten, tc.summary.Overwritten) ||
+						!slices.Equal(summary.Skipped, tc.summary.Skipped) ||
+						!slices.Equal(summary.KeptBoth, tc.summary.KeptBoth) {
+						t.Errorf("expected summary %+v, got %+v", tc.summary, summary)
+					}
+					for name, want := range tc.files {
+						b, err := fs.ReadFile(fsys, filepath.Join(live, name))
+						if err != nil || string(b) != want {
+							t.Errorf("expected %q to contain %q, got %q err: %v", name, want, b, err)
+						}
+					}
+				})
+			}
+		})
+	}
+}
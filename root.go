@@ -0,0 +1,255 @@
+package wfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Root is a writable file system rooted at a directory of the OS file system
+// that is safe to use with untrusted names.
+//
+// Names must be valid according to [fs.ValidPath], so ".." elements and
+// absolute paths are refused. Every other path component is resolved relative
+// to the root directory using [os.Root], so symbolic links that escape the
+// root cannot be followed.
+//
+// Rename, Symlink, Readlink, Link, Chmod, Chown and Chtimes use the methods
+// of [os.Root] added in Go 1.25, so they are as safe as opening a file. When
+// built with an earlier Go version they return [errors.ErrUnsupported] as they
+// could not be done without a window in which a path component may be changed
+// into an escaping symbolic link. Symbolic links may be created with any
+// destination, but links that escape the root are never followed.
+type Root struct {
+	root *os.Root
+}
+
+// OpenRoot opens the named directory for use as a [Root].
+func OpenRoot(dir string) (*Root, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Root{root}, nil
+}
+
+// Name returns the name of the directory presented to OpenRoot.
+func (r *Root) Name() string {
+	return r.root.Name()
+}
+
+// Close closes the root directory.
+// It does not close files opened from the root.
+func (r *Root) Close() error {
+	return r.root.Close()
+}
+
+func validName(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+func (r *Root) Open(name string) (fs.File, error) {
+	if err := validName("open", name); err != nil {
+		return nil, err
+	}
+	f, err := r.root.Open(name)
+	if err != nil {
+		return nil, normErr(err)
+	}
+	return &osFile{f, name}, nil
+}
+
+// Stat implements [fs.StatFS] for Root.
+func (r *Root) Stat(name string) (fs.FileInfo, error) {
+	if err := validName("stat", name); err != nil {
+		return nil, err
+	}
+	info, err := r.root.Stat(name)
+	return info, normErr(err)
+}
+
+// ReadDir implements [fs.ReadDirFS] for Root.
+func (r *Root) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := r.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrNotDir}
+	}
+	entries, err := dir.ReadDir(-1)
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, err
+}
+
+// ReadFile implements [fs.ReadFileFS] for Root.
+func (r *Root) ReadFile(name string) ([]byte, error) {
+	f, err := r.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// Sub implements [fs.SubFS] for Root, the returned file system is writable
+// and remains confined to the root.
+func (r *Root) Sub(dir string) (fs.FS, error) {
+	return Sub(r, dir)
+}
+
+func (r *Root) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if err := validName("open", name); err != nil {
+		return nil, err
+	}
+	f, err := r.root.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, normErr(err)
+	}
+	return &osFile{f, name}, nil
+}
+
+// checkDir reports an error if dir does not resolve to a directory inside the root.
+func (r *Root) checkDir(dir string) error {
+	info, err := r.root.Stat(dir)
+	if err != nil {
+		if pe, ok := err.(*fs.PathError); ok {
+			return portable(pe.Err)
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return ErrNotDir
+	}
+	return nil
+}
+
+func (r *Root) Remove(name string) error {
+	if err := validName("remove", name); err != nil {
+		return err
+	}
+	if name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	return normErr(r.root.Remove(name))
+}
+
+func (r *Root) RemoveAll(name string) error {
+	if err := validName("RemoveAll", name); err != nil {
+		return err
+	}
+	if name == "." {
+		return &fs.PathError{Op: "RemoveAll", Path: name, Err: fs.ErrInvalid}
+	}
+	err := r.removeAll(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return normErr(err)
+}
+
+func (r *Root) removeAll(name string) error {
+	info, err := r.root.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		dir, err := r.root.Open(name)
+		if err != nil {
+			return err
+		}
+		for {
+			entries, err := dir.ReadDir(64)
+			for _, e := range entries {
+				if err := r.removeAll(name + "/" + e.Name()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					dir.Close()
+					return err
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				dir.Close()
+				return err
+			}
+		}
+		dir.Close()
+	}
+	return r.root.Remove(name)
+}
+
+func (r *Root) Mkdir(name string, perm fs.FileMode) error {
+	if err := validName("mkdir", name); err != nil {
+		return err
+	}
+	return normErr(r.root.Mkdir(name, perm))
+}
+
+func (r *Root) MkdirAll(name string, perm fs.FileMode) error {
+	if err := validName("mkdir", name); err != nil {
+		return err
+	}
+	if name == "." {
+		return nil
+	}
+	elems := strings.Split(name, "/")
+	for i := range elems {
+		dir := strings.Join(elems[:i+1], "/")
+		err := r.root.Mkdir(dir, perm)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return normErr(err)
+		}
+		if err := r.checkDir(dir); err != nil {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+		}
+	}
+	return nil
+}
+
+// Lstat implements [SymlinkFS] for Root.
+func (r *Root) Lstat(name string) (fs.FileInfo, error) {
+	if err := validName("lstat", name); err != nil {
+		return nil, err
+	}
+	info, err := r.root.Lstat(name)
+	return info, normErr(err)
+}
+
+// Lock implements [LockFS] for Root.
+func (r *Root) Lock(name string) (Unlocker, error) {
+	return r.lock("lock", name, true, true)
+}
+
+// RLock implements [LockFS] for Root.
+func (r *Root) RLock(name string) (Unlocker, error) {
+	return r.lock("rlock", name, false, true)
+}
+
+// TryLock implements [LockFS] for Root.
+func (r *Root) TryLock(name string) (Unlocker, error) {
+	return r.lock("lock", name, true, false)
+}
+
+func (r *Root) lock(op, name string, exclusive, block bool) (Unlocker, error) {
+	if err := validName(op, name); err != nil {
+		return nil, err
+	}
+	return lockOSFile(op, name, func(flag int) (*os.File, error) {
+		f, err := r.root.OpenFile(name, flag, 0666)
+		return f, normErr(err)
+	}, exclusive, block)
+}
@@ -0,0 +1,46 @@
+//go:build !go1.25
+
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// The methods of os.Root that these operations need were added in Go 1.25.
+
+func (r *Root) Rename(oldpath, newpath string) error {
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errors.ErrUnsupported}
+}
+
+// Symlink implements [SymlinkFS] for Root.
+func (r *Root) Symlink(oldname, newname string) error {
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: errors.ErrUnsupported}
+}
+
+// Readlink implements [SymlinkFS] for Root.
+func (r *Root) Readlink(name string) (string, error) {
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: errors.ErrUnsupported}
+}
+
+// Link implements [LinkFS] for Root.
+func (r *Root) Link(oldname, newname string) error {
+	return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: errors.ErrUnsupported}
+}
+
+// Chmod implements [MetaFS] for Root.
+func (r *Root) Chmod(name string, mode fs.FileMode) error {
+	return &fs.PathError{Op: "chmod", Path: name, Err: errors.ErrUnsupported}
+}
+
+// Chown implements [MetaFS] for Root.
+func (r *Root) Chown(name string, uid, gid int) error {
+	return &fs.PathError{Op: "chown", Path: name, Err: errors.ErrUnsupported}
+}
+
+// Chtimes implements [MetaFS] for Root.
+func (r *Root) Chtimes(name string, atime, mtime time.Time) error {
+	return &fs.PathError{Op: "chtimes", Path: name, Err: errors.ErrUnsupported}
+}
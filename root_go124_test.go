@@ -0,0 +1,27 @@
+//go:build !go1.25
+
+package wfs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestRootUnsupported(t *testing.T) {
+	root, err := wfs.OpenRoot(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenRoot failed: %v", err)
+	}
+	defer root.Close()
+	if err := wfs.WriteFile(root, "file", nil, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := root.Rename("file", "new"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Rename: expected ErrUnsupported, got %v", err)
+	}
+	if err := root.Chmod("file", 0600); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Chmod: expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+//go:build go1.25
+
+package wfs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func (r *Root) Rename(oldpath, newpath string) error {
+	if !fs.ValidPath(oldpath) || !fs.ValidPath(newpath) || oldpath == "." || newpath == "." {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	return normErr(r.root.Rename(oldpath, newpath))
+}
+
+// Symlink implements [SymlinkFS] for Root.
+func (r *Root) Symlink(oldname, newname string) error {
+	if !fs.ValidPath(newname) || newname == "." {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	return normErr(r.root.Symlink(filepath.FromSlash(oldname), newname))
+}
+
+// Readlink implements [SymlinkFS] for Root.
+func (r *Root) Readlink(name string) (string, error) {
+	if err := validName("readlink", name); err != nil {
+		return "", err
+	}
+	target, err := r.root.Readlink(name)
+	if err != nil {
+		return "", normErr(err)
+	}
+	return filepath.ToSlash(target), nil
+}
+
+// Link implements [LinkFS] for Root.
+func (r *Root) Link(oldname, newname string) error {
+	if !fs.ValidPath(oldname) || !fs.ValidPath(newname) || oldname == "." || newname == "." {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	return normErr(r.root.Link(oldname, newname))
+}
+
+// Chmod implements [MetaFS] for Root.
+func (r *Root) Chmod(name string, mode fs.FileMode) error {
+	if err := validName("chmod", name); err != nil {
+		return err
+	}
+	return normErr(r.root.Chmod(name, mode))
+}
+
+// Chown implements [MetaFS] for Root.
+func (r *Root) Chown(name string, uid, gid int) error {
+	if err := validName("chown", name); err != nil {
+		return err
+	}
+	return normErr(r.root.Chown(name, uid, gid))
+}
+
+// Chtimes implements [MetaFS] for Root.
+func (r *Root) Chtimes(name string, atime, mtime time.Time) error {
+	if err := validName("chtimes", name); err != nil {
+		return err
+	}
+	return normErr(r.root.Chtimes(name, atime, mtime))
+}
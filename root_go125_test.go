@@ -0,0 +1,35 @@
+//go:build go1.25
+
+package wfs_test
+
+import (
+	"os"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/wfstest"
+)
+
+// Root supports every operation of the other file systems only from Go 1.25.
+func init() {
+	fileSystems = append(fileSystems, fileSystem{"Root FS", func(fsys fstest.MapFS) (wfs.FS, string, func(), error) {
+		dir, err := os.MkdirTemp("", "testdata")
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if err := wfstest.Write(wfs.Dir(dir), fsys); err != nil {
+			os.RemoveAll(dir)
+			return nil, "", nil, err
+		}
+		root, err := wfs.OpenRoot(dir)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, "", nil, err
+		}
+		cleanup := func() {
+			root.Close()
+			os.RemoveAll(dir)
+		}
+		return root, "", cleanup, nil
+	}})
+}
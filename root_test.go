@@ -0,0 +1,60 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestRootEscape(t *testing.T) {
+	outside := t.TempDir()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	root, err := wfs.OpenRoot(dir)
+	if err != nil {
+		t.Fatalf("OpenRoot failed: %v", err)
+	}
+	defer root.Close()
+
+	for _, name := range []string{"../secret", "/secret", "a/../../secret"} {
+		if _, err := root.OpenFile(name, os.O_RDONLY, 0); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("OpenFile %q: expected ErrInvalid, got %v", name, err)
+		}
+	}
+	if err := root.Remove("."); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Remove of the root: expected ErrInvalid, got %v", err)
+	}
+	if _, err := root.OpenFile("escape/secret", os.O_RDONLY, 0); err == nil {
+		t.Errorf("expected OpenFile through escaping symlink to fail")
+	}
+	if err := wfs.WriteFile(root, "escape/new", nil, 0644); err == nil {
+		t.Errorf("expected WriteFile through escaping symlink to fail")
+	}
+	if err := root.MkdirAll("escape/dir", 0755); err == nil {
+		t.Errorf("expected MkdirAll through escaping symlink to fail")
+	}
+	if err := wfs.WriteFile(root, "file", nil, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := root.Rename("file", "escape/file"); err == nil {
+		t.Errorf("expected Rename through escaping symlink to fail")
+	}
+
+	// removing the link must not remove the target
+	if err := root.RemoveAll("escape"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "secret")); err != nil {
+		t.Errorf("expected file outside root to remain: %v", err)
+	}
+}
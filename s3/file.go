@@ -0,0 +1,342 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/eriicafes/wfs"
+)
+
+// file is a file opened for reading, or a directory.
+type file struct {
+	fs   *FS
+	name string
+	info *fileInfo
+
+	mu      sync.Mutex
+	offset  int64
+	body    io.ReadCloser // response body of a GET from bodyOff
+	bodyOff int64
+	closed  bool
+	entries []fs.DirEntry // directory listing captured on the first ReadDir
+}
+
+// check returns an error if the file is closed, or is a directory and op
+// is not a directory operation.
+func (f *file) check(op string) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.info.dir && op != "readdir" && op != "seek" {
+		return &fs.PathError{Op: op, Path: f.name, Err: wfs.ErrIsDir}
+	}
+	return nil
+}
+
+// get returns the body of a GET of the object from off, limited to n bytes
+// if n is positive.
+func (f *file) get(off, n int64) (io.ReadCloser, error) {
+	rng := fmt.Sprintf("bytes=%d-", off)
+	if n > 0 {
+		rng += fmt.Sprint(off + n - 1)
+	}
+	out, err := f.fs.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: &f.fs.bucket,
+		Key:    aws.String(f.fs.key(f.name)),
+		Range:  &rng,
+	})
+	if err != nil {
+		if isNotFound(err) {
+			err = fs.ErrNotExist
+		}
+		return nil, &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	return out.Body, nil
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	return f.info, nil
+}
+
+func (f *file) Read(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read"); err != nil {
+		return 0, err
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if f.offset >= f.info.size {
+		return 0, io.EOF
+	}
+	// sequential reads continue the same response
+	if f.body == nil || f.bodyOff != f.offset {
+		if f.body != nil {
+			f.body.Close()
+		}
+		body, err := f.get(f.offset, 0)
+		if err != nil {
+			return 0, err
+		}
+		f.body, f.bodyOff = body, f.offset
+	}
+	n, err := f.body.Read(b)
+	f.offset += int64(n)
+	f.bodyOff = f.offset
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *file) ReadAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read"); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.name, Err: errors.New("negative offset")}
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if off >= f.info.size {
+		return 0, io.EOF
+	}
+	body, err := f.get(off, int64(len(b)))
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, b)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("seek"); err != nil {
+		return 0, err
+	}
+	if f.info.dir {
+		if offset != 0 || whence != io.SeekStart {
+			return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+		}
+		f.entries = nil
+		return 0, nil
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	case io.SeekStart:
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *file) Write(b []byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: errBadFile}
+}
+
+func (f *file) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *file) WriteAt(b []byte, off int64) (int, error) {
+	return f.Write(b)
+}
+
+func (f *file) Truncate(size int64) error {
+	return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
+}
+
+// Sync does nothing as the file is not written.
+func (f *file) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "sync", Path: f.name, Err: fs.ErrClosed}
+	}
+	return nil
+}
+
+// ReadDir implements [fs.ReadDirFile] for directories.
+// The listing is captured on the first call, so the directory may be
+// modified while it is being read.
+func (f *file) ReadDir(count int) ([]fs.DirEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("readdir"); err != nil {
+		return nil, err
+	}
+	if !f.info.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: wfs.ErrNotDir}
+	}
+	if f.entries == nil {
+		entries, err := f.fs.readDir(context.Background(), f.name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: err}
+		}
+		f.entries = entries
+	}
+	entries := f.entries
+	if count > 0 && len(entries) > count {
+		entries = entries[:count]
+	}
+	f.entries = f.entries[len(entries):]
+	if count > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	return entries, nil
+}
+
+func (f *file) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
+
+// writer is a file opened for writing, buffered in memory until it is
+// uploaded by Sync or Close.
+type writer struct {
+	wfs.File
+	fs   *FS
+	name string
+	buf  wfs.FS
+
+	mu    sync.Mutex
+	dirty bool
+}
+
+// bufName is the name of the buffered contents of a writer.
+const bufName = "data"
+
+// openWriter opens name for writing, loading the contents of the object
+// if load is set.
+func (f *FS) openWriter(ctx context.Context, name string, flag int, load bool) (*writer, error) {
+	buf := wfs.Mem()
+	if load {
+		out, err := f.client.GetObject(ctx, &s3.GetObjectInput{Bucket: &f.bucket, Key: aws.String(f.key(name))})
+		if err != nil {
+			return nil, err
+		}
+		_, err = wfs.WriteReader(buf, bufName, out.Body, 0644)
+		out.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	file, err := buf.OpenFile(bufName, flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND)|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	// a truncated object is replaced even if it is not written
+	return &writer{File: file, fs: f, name: name, buf: buf, dirty: flag&os.O_TRUNC != 0}, nil
+}
+
+func (w *writer) Name() string {
+	return w.name
+}
+
+func (w *writer) Stat() (fs.FileInfo, error) {
+	info, err := w.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{name: path.Base(w.name), size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	w.dirty = true
+	w.mu.Unlock()
+	return w.File.Write(b)
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *writer) WriteAt(b []byte, off int64) (int, error) {
+	w.mu.Lock()
+	w.dirty = true
+	w.mu.Unlock()
+	return w.File.WriteAt(b, off)
+}
+
+func (w *writer) Truncate(size int64) error {
+	w.mu.Lock()
+	w.dirty = true
+	w.mu.Unlock()
+	return w.File.Truncate(size)
+}
+
+// Sync uploads the contents of the file if it was modified.
+func (w *writer) Sync() error {
+	if err := w.File.Sync(); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.dirty {
+		return nil
+	}
+	r, err := w.buf.OpenFile(bufName, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	info, err := r.Stat()
+	if err != nil {
+		return err
+	}
+	if err := w.fs.upload(context.Background(), w.fs.key(w.name), r, info.Size()); err != nil {
+		return &fs.PathError{Op: "sync", Path: w.name, Err: err}
+	}
+	w.dirty = false
+	return nil
+}
+
+func (w *writer) Close() error {
+	err := w.Sync()
+	if err1 := w.File.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
@@ -0,0 +1,631 @@
+// Package s3 provides a writable file system stored in an Amazon S3 bucket or
+// an S3 compatible object store.
+//
+// The file system serves the ETags of objects as MD5 digests through
+// [wfs.HashFS] and disk usage from listings through [wfs.UsageFS]. It does
+// not implement [wfs.ExpiryFS], as S3 lifecycle rules are configured for a
+// whole bucket and expire objects by the day, so leases are recorded in
+// lease files.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/eriicafes/wfs"
+)
+
+// partSize is the size of the parts of multipart uploads, files up to this
+// size are uploaded with a single request.
+const partSize = 8 << 20
+
+var errBadFile = errors.New("bad file descriptor")
+
+// Client is the subset of the S3 API used by [FS], it is implemented by
+// [s3.Client].
+type Client interface {
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// FS is a writable file system stored under a prefix of an S3 bucket.
+//
+// Files are objects named by the prefix and their path. Directories are
+// zero-byte objects named with a trailing slash, and also exist implicitly
+// as the common prefix of the objects they contain. Files have mode 0644
+// and directories 0755.
+//
+// Files opened for reading are read with ranged GET requests. Files opened
+// for writing are buffered in memory, starting with the contents of the
+// object unless it is truncated, and uploaded when they are synced or
+// closed, with a multipart upload if they are larger than 8 MiB. Created
+// files are uploaded empty when they are opened so that they exist.
+//
+// Rename copies and deletes objects, so renaming a directory is not atomic
+// and takes time proportional to its contents. Checks such as [os.O_EXCL]
+// and empty directories on Remove are not atomic either.
+//
+// The info of objects returned by Stat and ReadDir carries an [S3Info].
+type FS struct {
+	client Client
+	bucket string
+	prefix string
+}
+
+// New returns an FS storing files in bucket under prefix, an empty prefix
+// stores files at the root of the bucket.
+func New(client Client, bucket, prefix string) *FS {
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+	return &FS{client: client, bucket: bucket, prefix: prefix}
+}
+
+// key returns the key of the object of the file name.
+func (f *FS) key(name string) string {
+	return f.prefix + name
+}
+
+// dirKey returns the prefix of the objects in the directory name, which is
+// also the key of its marker object.
+func (f *FS) dirKey(name string) string {
+	if name == "." {
+		return f.prefix
+	}
+	return f.prefix + name + "/"
+}
+
+// isNotFound reports whether err is an error of a missing object.
+func isNotFound(err error) bool {
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		switch ae.ErrorCode() {
+		case "NotFound", "NoSuchKey":
+			return true
+		}
+	}
+	return false
+}
+
+// S3Info is the backend-specific metadata of objects in an [FS], see
+// [wfs.SysInfo]. Directories have no metadata.
+type S3Info struct {
+	// ETag is the entity tag of the object without quotes.
+	ETag string
+	// StorageClass is the storage class of the object, such as STANDARD.
+	StorageClass string
+	// VersionID is the version of the object in a versioned bucket, it is
+	// empty for the entries of ReadDir as listings do not report versions.
+	VersionID string
+}
+
+// fileInfo describes an object or a directory.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+	sys     *S3Info
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.dir }
+
+func (i *fileInfo) Sys() any {
+	if i.sys == nil {
+		return nil
+	}
+	return i.sys
+}
+
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// stat returns the info of name without wrapping errors.
+func (f *FS) stat(ctx context.Context, name string) (*fileInfo, error) {
+	if name == "." {
+		return &fileInfo{name: ".", dir: true}, nil
+	}
+	out, err := f.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &f.bucket, Key: aws.String(f.key(name))})
+	if err == nil {
+		return &fileInfo{
+			name:    path.Base(name),
+			size:    aws.ToInt64(out.ContentLength),
+			modTime: aws.ToTime(out.LastModified),
+			sys:     newS3Info(out.ETag, string(out.StorageClass), out.VersionId),
+		}, nil
+	}
+	if !isNotFound(err) {
+		return nil, err
+	}
+	list, err := f.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  &f.bucket,
+		Prefix:  aws.String(f.dirKey(name)),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Contents) == 0 {
+		return nil, fs.ErrNotExist
+	}
+	// prefixes have no modification time, even with a marker object
+	return &fileInfo{name: path.Base(name), dir: true}, nil
+}
+
+// newS3Info returns the metadata of an object. HeadObject omits the storage
+// class of objects in the STANDARD class, which listings report.
+func newS3Info(etag *string, class string, version *string) *S3Info {
+	if class == "" {
+		class = string(types.StorageClassStandard)
+	}
+	return &S3Info{ETag: strings.Trim(aws.ToString(etag), `"`), StorageClass: class, VersionID: aws.ToString(version)}
+}
+
+// statDir checks that name is a directory.
+func (f *FS) statDir(ctx context.Context, name string) error {
+	info, err := f.stat(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !info.dir {
+		return wfs.ErrNotDir
+	}
+	return nil
+}
+
+// list calls fn with the objects whose keys start with prefix, and with the
+// common prefixes of the objects if delimiter is set.
+func (f *FS) list(ctx context.Context, prefix string, delimiter bool, fn func(obj *types.Object, dir string)) error {
+	in := &s3.ListObjectsV2Input{Bucket: &f.bucket, Prefix: &prefix}
+	if delimiter {
+		in.Delimiter = aws.String("/")
+	}
+	for {
+		out, err := f.client.ListObjectsV2(ctx, in)
+		if err != nil {
+			return err
+		}
+		for _, p := range out.CommonPrefixes {
+			fn(nil, aws.ToString(p.Prefix))
+		}
+		for i := range out.Contents {
+			fn(&out.Contents[i], "")
+		}
+		if !aws.ToBool(out.IsTruncated) {
+			return nil
+		}
+		in.ContinuationToken = out.NextContinuationToken
+	}
+}
+
+// readDir returns the entries of the directory name sorted by name.
+func (f *FS) readDir(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	if err := f.statDir(ctx, name); err != nil {
+		return nil, err
+	}
+	prefix := f.dirKey(name)
+	var entries []fs.DirEntry
+	err := f.list(ctx, prefix, true, func(obj *types.Object, dir string) {
+		if obj == nil {
+			name := strings.TrimSuffix(strings.TrimPrefix(dir, prefix), "/")
+			entries = append(entries, fs.FileInfoToDirEntry(&fileInfo{name: name, dir: true}))
+			return
+		}
+		// the marker of the directory itself is not an entry
+		if name := strings.TrimPrefix(aws.ToString(obj.Key), prefix); name != "" {
+			entries = append(entries, fs.FileInfoToDirEntry(&fileInfo{
+				name:    name,
+				size:    aws.ToInt64(obj.Size),
+				modTime: aws.ToTime(obj.LastModified),
+				sys:     newS3Info(obj.ETag, string(obj.StorageClass), nil),
+			}))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+// keys returns the keys of every object in the tree of the directory name.
+func (f *FS) keys(ctx context.Context, name string) ([]string, error) {
+	var keys []string
+	err := f.list(ctx, f.dirKey(name), false, func(obj *types.Object, _ string) {
+		keys = append(keys, aws.ToString(obj.Key))
+	})
+	return keys, err
+}
+
+// deleteKeys deletes the objects named by keys.
+func (f *FS) deleteKeys(ctx context.Context, keys []string) error {
+	for batch := range slices.Chunk(keys, 1000) {
+		objects := make([]types.ObjectIdentifier, len(batch))
+		for i, key := range batch {
+			objects[i] = types.ObjectIdentifier{Key: aws.String(key)}
+		}
+		out, err := f.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: &f.bucket,
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return errors.New(aws.ToString(e.Key) + ": " + aws.ToString(e.Message))
+		}
+	}
+	return nil
+}
+
+// copySource returns the CopySource of the object key.
+func (f *FS) copySource(key string) string {
+	elems := strings.Split(f.bucket+"/"+key, "/")
+	for i, elem := range elems {
+		elems[i] = url.PathEscape(elem)
+	}
+	return strings.Join(elems, "/")
+}
+
+// putEmpty creates an empty object.
+func (f *FS) putEmpty(ctx context.Context, key string) error {
+	_, err := f.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        &f.bucket,
+		Key:           &key,
+		Body:          strings.NewReader(""),
+		ContentLength: aws.Int64(0),
+	})
+	return err
+}
+
+// upload uploads size bytes of r to the object key.
+func (f *FS) upload(ctx context.Context, key string, r io.ReaderAt, size int64) error {
+	if size <= partSize {
+		_, err := f.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        &f.bucket,
+			Key:           &key,
+			Body:          io.NewSectionReader(r, 0, size),
+			ContentLength: aws.Int64(size),
+		})
+		return err
+	}
+	out, err := f.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: &f.bucket, Key: &key})
+	if err != nil {
+		return err
+	}
+	var parts []types.CompletedPart
+	for off := int64(0); off < size; off += partSize {
+		n := min(partSize, size-off)
+		number := aws.Int32(int32(len(parts) + 1))
+		part, err := f.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        &f.bucket,
+			Key:           &key,
+			UploadId:      out.UploadId,
+			PartNumber:    number,
+			Body:          io.NewSectionReader(r, off, n),
+			ContentLength: aws.Int64(n),
+		})
+		if err != nil {
+			f.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: &f.bucket, Key: &key, UploadId: out.UploadId})
+			return err
+		}
+		parts = append(parts, types.CompletedPart{ETag: part.ETag, PartNumber: number})
+	}
+	_, err = f.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          &f.bucket,
+		Key:             &key,
+		UploadId:        out.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		f.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: &f.bucket, Key: &key, UploadId: out.UploadId})
+	}
+	return err
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for FS.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.stat(context.Background(), name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// ReadDir implements [fs.ReadDirFS] for FS.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := f.readDir(context.Background(), name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+func (f *FS) OpenFile(name string, flag int, perm fs.FileMode) (wfs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	ctx := context.Background()
+	info, err := f.stat(ctx, name)
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0
+	switch {
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		err = fs.ErrExist
+	case err == nil && info.dir && flag&(os.O_WRONLY|os.O_RDWR) != 0:
+		err = wfs.ErrIsDir
+	case err == nil && !write:
+		return &file{fs: f, name: name, info: info}, nil
+	case err == nil && info.dir:
+		return &file{fs: f, name: name, info: info}, nil
+	case err == nil:
+		var w *writer
+		if w, err = f.openWriter(ctx, name, flag, flag&os.O_TRUNC == 0); err == nil {
+			return w, nil
+		}
+	case errors.Is(err, fs.ErrNotExist) && flag&os.O_CREATE != 0:
+		if err = f.statDir(ctx, path.Dir(name)); err == nil {
+			if err = f.putEmpty(ctx, f.key(name)); err == nil {
+				var w *writer
+				if w, err = f.openWriter(ctx, name, flag, false); err == nil {
+					return w, nil
+				}
+			}
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+}
+
+func (f *FS) Rename(oldpath, newpath string) error {
+	if !fs.ValidPath(oldpath) || !fs.ValidPath(newpath) || oldpath == "." || newpath == "." {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	if err := f.rename(context.Background(), oldpath, newpath); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	return nil
+}
+
+func (f *FS) rename(ctx context.Context, oldpath, newpath string) error {
+	info, err := f.stat(ctx, oldpath)
+	if err != nil {
+		return err
+	}
+	if err := f.statDir(ctx, path.Dir(newpath)); err != nil {
+		return err
+	}
+	if oldpath == newpath {
+		return nil
+	}
+	// a directory cannot be moved into itself
+	if info.dir && strings.HasPrefix(newpath, oldpath+"/") {
+		return fs.ErrInvalid
+	}
+	if target, err := f.stat(ctx, newpath); err == nil {
+		if target.dir {
+			return fs.ErrExist
+		}
+		if info.dir {
+			return wfs.ErrNotDir
+		}
+	}
+	keys := []string{f.key(oldpath)}
+	if info.dir {
+		if keys, err = f.keys(ctx, oldpath); err != nil {
+			return err
+		}
+	}
+	for _, key := range keys {
+		newKey := f.key(newpath) + strings.TrimPrefix(key, f.key(oldpath))
+		_, err := f.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     &f.bucket,
+			Key:        &newKey,
+			CopySource: aws.String(f.copySource(key)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return f.deleteKeys(ctx, keys)
+}
+
+func (f *FS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := f.remove(context.Background(), name); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *FS) remove(ctx context.Context, name string) error {
+	info, err := f.stat(ctx, name)
+	if err != nil {
+		return err
+	}
+	key := f.key(name)
+	if info.dir {
+		out, err := f.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:  &f.bucket,
+			Prefix:  aws.String(f.dirKey(name)),
+			MaxKeys: aws.Int32(2),
+		})
+		if err != nil {
+			return err
+		}
+		for _, obj := range out.Contents {
+			if aws.ToString(obj.Key) != f.dirKey(name) {
+				return wfs.ErrNotEmpty
+			}
+		}
+		key = f.dirKey(name)
+	}
+	_, err = f.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &f.bucket, Key: &key})
+	return err
+}
+
+func (f *FS) RemoveAll(path string) error {
+	if !fs.ValidPath(path) || path == "." {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: fs.ErrInvalid}
+	}
+	ctx := context.Background()
+	keys, err := f.keys(ctx, path)
+	if err == nil {
+		err = f.deleteKeys(ctx, append(keys, f.key(path)))
+	}
+	if err != nil {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: err}
+	}
+	return nil
+}
+
+func (f *FS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	ctx := context.Background()
+	_, err := f.stat(ctx, name)
+	switch {
+	case err == nil:
+		err = fs.ErrExist
+	case errors.Is(err, fs.ErrNotExist):
+		if err = f.statDir(ctx, path.Dir(name)); err == nil {
+			err = f.putEmpty(ctx, f.dirKey(name))
+		}
+	}
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *FS) MkdirAll(path string, perm fs.FileMode) error {
+	if !fs.ValidPath(path) {
+		return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrInvalid}
+	}
+	if path == "." {
+		return nil
+	}
+	ctx := context.Background()
+	elems := strings.Split(path, "/")
+	for i := range elems {
+		name := strings.Join(elems[:i+1], "/")
+		info, err := f.stat(ctx, name)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			err = f.putEmpty(ctx, f.dirKey(name))
+		case err == nil && !info.dir:
+			err = wfs.ErrNotDir
+		}
+		if err != nil {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: err}
+		}
+	}
+	return nil
+}
+
+// Hash implements [wfs.HashFS] for FS. The ETag of an object uploaded with
+// a single request and without KMS or customer-provided encryption is the
+// MD5 digest of its contents, other digests are unsupported.
+func (f *FS) Hash(name string, h func() hash.Hash) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "hash", Path: name, Err: fs.ErrInvalid}
+	}
+	// only MD5 digests empty content like this
+	if sum := md5.Sum(nil); !bytes.Equal(h().Sum(nil), sum[:]) {
+		return nil, &fs.PathError{Op: "hash", Path: name, Err: errors.ErrUnsupported}
+	}
+	ctx := context.Background()
+	out, err := f.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: &f.bucket, Key: aws.String(f.key(name))})
+	if err != nil {
+		if !isNotFound(err) {
+			return nil, &fs.PathError{Op: "hash", Path: name, Err: err}
+		}
+		if err = f.statDir(ctx, name); err == nil {
+			err = wfs.ErrIsDir
+		}
+		return nil, &fs.PathError{Op: "hash", Path: name, Err: err}
+	}
+	switch out.ServerSideEncryption {
+	case types.ServerSideEncryptionAwsKms, types.ServerSideEncryptionAwsKmsDsse:
+		return nil, &fs.PathError{Op: "hash", Path: name, Err: errors.ErrUnsupported}
+	}
+	if out.SSECustomerAlgorithm != nil {
+		return nil, &fs.PathError{Op: "hash", Path: name, Err: errors.ErrUnsupported}
+	}
+	// the ETag of a multipart upload has a suffix with the number of parts
+	sum, err := hex.DecodeString(strings.Trim(aws.ToString(out.ETag), `"`))
+	if err != nil || len(sum) != md5.Size {
+		return nil, &fs.PathError{Op: "hash", Path: name, Err: errors.ErrUnsupported}
+	}
+	return sum, nil
+}
+
+// Usage implements [wfs.UsageFS] for FS by listing the objects in the tree
+// of root, directory marker objects are not counted.
+func (f *FS) Usage(root string) (files int, size int64, err error) {
+	if !fs.ValidPath(root) {
+		return 0, 0, &fs.PathError{Op: "usage", Path: root, Err: fs.ErrInvalid}
+	}
+	ctx := context.Background()
+	info, err := f.stat(ctx, root)
+	if err != nil {
+		return 0, 0, &fs.PathError{Op: "usage", Path: root, Err: err}
+	}
+	if !info.dir {
+		return 1, info.size, nil
+	}
+	err = f.list(ctx, f.dirKey(root), false, func(obj *types.Object, _ string) {
+		if !strings.HasSuffix(aws.ToString(obj.Key), "/") {
+			files++
+			size += aws.ToInt64(obj.Size)
+		}
+	})
+	if err != nil {
+		return 0, 0, &fs.PathError{Op: "usage", Path: root, Err: err}
+	}
+	return files, size, nil
+}
@@ -0,0 +1,448 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/s3"
+	"github.com/eriicafes/wfs/wfsbench"
+	"github.com/eriicafes/wfs/wfstest"
+)
+
+// fakeClient is an in-memory implementation of the S3 API for a single bucket.
+type fakeClient struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	modTimes map[string]time.Time
+	etags    map[string]string
+	uploads  map[string]map[int32][]byte
+	pageSize int
+	gets     int
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{objects: make(map[string][]byte), modTimes: make(map[string]time.Time), etags: make(map[string]string), uploads: make(map[string]map[int32][]byte), pageSize: 2}
+}
+
+var errNoSuchKey = &smithy.GenericAPIError{Code: "NoSuchKey"}
+
+func (c *fakeClient) HeadObject(ctx context.Context, in *awss3.HeadObjectInput, _ ...func(*awss3.Options)) (*awss3.HeadObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.objects[*in.Key]
+	if !ok {
+		return nil, &smithy.GenericAPIError{Code: "NotFound"}
+	}
+	return &awss3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(data))), LastModified: aws.Time(c.modTimes[*in.Key]), ETag: aws.String(c.etags[*in.Key])}, nil
+}
+
+func (c *fakeClient) GetObject(ctx context.Context, in *awss3.GetObjectInput, _ ...func(*awss3.Options)) (*awss3.GetObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	data, ok := c.objects[*in.Key]
+	if !ok {
+		return nil, errNoSuchKey
+	}
+	if in.Range != nil {
+		var start, end int
+		if n, _ := fmt.Sscanf(*in.Range, "bytes=%d-%d", &start, &end); n == 2 {
+			data = data[start:min(end+1, len(data))]
+		} else {
+			data = data[start:]
+		}
+	}
+	return &awss3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (c *fakeClient) PutObject(ctx context.Context, in *awss3.PutObjectInput, _ ...func(*awss3.Options)) (*awss3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.put(*in.Key, data)
+	return &awss3.PutObjectOutput{}, nil
+}
+
+func (c *fakeClient) CopyObject(ctx context.Context, in *awss3.CopyObjectInput, _ ...func(*awss3.Options)) (*awss3.CopyObjectOutput, error) {
+	source, err := url.PathUnescape(*in.CopySource)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.objects[strings.TrimPrefix(source, "bucket/")]
+	if !ok {
+		return nil, errNoSuchKey
+	}
+	c.put(*in.Key, data)
+	return &awss3.CopyObjectOutput{}, nil
+}
+
+func (c *fakeClient) DeleteObject(ctx context.Context, in *awss3.DeleteObjectInput, _ ...func(*awss3.Options)) (*awss3.DeleteObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.objects, *in.Key)
+	delete(c.modTimes, *in.Key)
+	delete(c.etags, *in.Key)
+	return &awss3.DeleteObjectOutput{}, nil
+}
+
+func (c *fakeClient) DeleteObjects(ctx context.Context, in *awss3.DeleteObjectsInput, _ ...func(*awss3.Options)) (*awss3.DeleteObjectsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, obj := range in.Delete.Objects {
+		delete(c.objects, *obj.Key)
+		delete(c.modTimes, *obj.Key)
+		delete(c.etags, *obj.Key)
+	}
+	return &awss3.DeleteObjectsOutput{}, nil
+}
+
+func (c *fakeClient) ListObjectsV2(ctx context.Context, in *awss3.ListObjectsV2Input, _ ...func(*awss3.Options)) (*awss3.ListObjectsV2Output, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prefix := aws.ToString(in.Prefix)
+	var keys []string
+	for key := range c.objects {
+		if strings.HasPrefix(key, prefix) && key > aws.ToString(in.ContinuationToken) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	limit := c.pageSize
+	if in.MaxKeys != nil {
+		limit = min(limit, int(*in.MaxKeys))
+	}
+	out := &awss3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	for _, key := range keys {
+		if len(out.Contents)+len(out.CommonPrefixes) == limit {
+			out.IsTruncated = aws.Bool(true)
+			break
+		}
+		out.NextContinuationToken = aws.String(key)
+		if in.Delimiter != nil {
+			if i := strings.Index(key[len(prefix):], "/"); i >= 0 {
+				dir := key[:len(prefix)+i+1]
+				if len(out.CommonPrefixes) == 0 || *out.CommonPrefixes[len(out.CommonPrefixes)-1].Prefix != dir {
+					out.CommonPrefixes = append(out.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(dir)})
+				}
+				// the continuation skips the rest of the common prefix
+				out.NextContinuationToken = aws.String(dir + "\xff")
+				continue
+			}
+		}
+		out.Contents = append(out.Contents, types.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(int64(len(c.objects[key]))),
+			LastModified: aws.Time(c.modTimes[key]),
+			ETag:         aws.String(c.etags[key]),
+			StorageClass: types.ObjectStorageClassStandard,
+		})
+	}
+	return out, nil
+}
+
+func (c *fakeClient) CreateMultipartUpload(ctx context.Context, in *awss3.CreateMultipartUploadInput, _ ...func(*awss3.Options)) (*awss3.CreateMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := fmt.Sprint(len(c.uploads) + 1)
+	c.uploads[id] = make(map[int32][]byte)
+	return &awss3.CreateMultipartUploadOutput{UploadId: aws.String(id)}, nil
+}
+
+func (c *fakeClient) UploadPart(ctx context.Context, in *awss3.UploadPartInput, _ ...func(*awss3.Options)) (*awss3.UploadPartOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.uploads[*in.UploadId][*in.PartNumber] = data
+	return &awss3.UploadPartOutput{ETag: aws.String(fmt.Sprint(*in.PartNumber))}, nil
+}
+
+func (c *fakeClient) CompleteMultipartUpload(ctx context.Context, in *awss3.CompleteMultipartUploadInput, _ ...func(*awss3.Options)) (*awss3.CompleteMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var data []byte
+	for _, part := range in.MultipartUpload.Parts {
+		data = append(data, c.uploads[*in.UploadId][*part.PartNumber]...)
+	}
+	c.put(*in.Key, data)
+	// like S3, the ETag of a multipart upload is not the digest of the object
+	c.etags[*in.Key] = fmt.Sprintf(`"%x-%d"`, md5.Sum(data), len(in.MultipartUpload.Parts))
+	delete(c.uploads, *in.UploadId)
+	return &awss3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (c *fakeClient) AbortMultipartUpload(ctx context.Context, in *awss3.AbortMultipartUploadInput, _ ...func(*awss3.Options)) (*awss3.AbortMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.uploads, *in.UploadId)
+	return &awss3.AbortMultipartUploadOutput{}, nil
+}
+
+// put stores an object, the caller must hold c.mu.
+func (c *fakeClient) put(key string, data []byte) {
+	c.objects[key] = data
+	c.modTimes[key] = time.Now()
+	c.etags[key] = fmt.Sprintf(`"%x"`, md5.Sum(data))
+}
+
+func (c *fakeClient) keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []string
+	for key := range c.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func assertContent(t *testing.T, fsys fs.FS, name, expected string) {
+	t.Helper()
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil || string(data) != expected {
+		t.Errorf("expected %q to contain %q, got %q err: %v", name, expected, data, err)
+	}
+}
+
+func TestFS(t *testing.T) {
+	client := newFakeClient()
+	fsys := s3.New(client, "bucket", "/app/")
+
+	if err := fsys.MkdirAll("a/b", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "a/b/file.txt", []byte("hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "a/one", []byte("1"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "top", []byte("top"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	// directories also exist implicitly
+	client.put("app/implicit/x", []byte("x"))
+	if err := fstest.TestFS(fsys, "a/b/file.txt", "a/one", "top", "implicit/x"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"app/a/", "app/a/b/", "app/a/b/file.txt", "app/a/one", "app/implicit/x", "app/top"}; !slices.Equal(client.keys(), want) {
+		t.Errorf("expected keys %v, got %v", want, client.keys())
+	}
+
+	if _, err := fsys.OpenFile("top", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+	if _, err := fsys.OpenFile("missing/file", os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if _, err := fsys.OpenFile("top/file", os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("expected ErrNotDir, got %v", err)
+	}
+	if _, err := fsys.OpenFile("a", os.O_WRONLY, 0); !errors.Is(err, wfs.ErrIsDir) {
+		t.Errorf("expected ErrIsDir, got %v", err)
+	}
+	if err := fsys.Remove("a/b"); !errors.Is(err, wfs.ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty, got %v", err)
+	}
+	if err := fsys.Mkdir("a", 0755); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+
+	// appends are buffered until the file is closed
+	f, err := fsys.OpenFile("top", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.Write([]byte(" more"))
+	assertContent(t, fsys, "top", "top")
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	assertContent(t, fsys, "top", "top more")
+
+	if err := fsys.Rename("a", "moved"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := fsys.Rename("moved", "moved/b/inside"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected ErrInvalid moving a directory into itself, got %v", err)
+	}
+	if err := fsys.Rename("top", "moved/one"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	assertContent(t, fsys, "moved/b/file.txt", "hello")
+	assertContent(t, fsys, "moved/one", "top more")
+
+	if err := fsys.Remove("moved/b/file.txt"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := fsys.Remove("moved/b"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := fsys.RemoveAll("moved"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if want := []string{"app/implicit/x"}; !slices.Equal(client.keys(), want) {
+		t.Errorf("expected keys %v, got %v", want, client.keys())
+	}
+}
+
+func TestFile(t *testing.T) {
+	client := newFakeClient()
+	fsys := s3.New(client, "bucket", "")
+
+	// files larger than a part use a multipart upload
+	large := bytes.Repeat([]byte("0123456789abcdef"), 1<<20)
+	if err := wfs.WriteFile(fsys, "large", large, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if !bytes.Equal(client.objects["large"], large) {
+		t.Fatalf("expected uploaded object of size %d, got %d", len(large), len(client.objects["large"]))
+	}
+
+	f, err := fsys.Open("large")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	file := f.(wfs.File)
+	buf := make([]byte, 16)
+	if n, err := file.ReadAt(buf, 1<<20+3); err != nil || !bytes.Equal(buf[:n], large[1<<20+3:1<<20+19]) {
+		t.Errorf("expected %q, got %q err: %v", large[1<<20+3:1<<20+19], buf[:n], err)
+	}
+	// sequential reads share a request
+	client.gets = 0
+	data, err := io.ReadAll(file)
+	if err != nil || !bytes.Equal(data, large) || client.gets != 1 {
+		t.Errorf("expected contents in one request, got size %d in %d requests err: %v", len(data), client.gets, err)
+	}
+	if _, err := file.Write([]byte("x")); err == nil {
+		t.Errorf("expected writing a file opened for reading to fail")
+	}
+
+	// truncating replaces the object even if it is not written
+	f, err = fsys.OpenFile("large", os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.(wfs.File).Close()
+	assertContent(t, fsys, "large", "")
+}
+
+func TestMetadata(t *testing.T) {
+	client := newFakeClient()
+	fsys := s3.New(client, "bucket", "")
+	if err := fsys.MkdirAll("dir/sub", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "dir/small", []byte("hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	large := bytes.Repeat([]byte("0123456789abcdef"), 1<<20)
+	if err := wfs.WriteFile(fsys, "dir/sub/large", large, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	sum := md5.Sum([]byte("hello"))
+	info, err := fsys.Stat("dir/small")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if sys, ok := wfs.SysInfo[*s3.S3Info](info); !ok || sys.ETag != fmt.Sprintf("%x", sum) || sys.StorageClass != "STANDARD" {
+		t.Errorf("expected S3Info with the ETag of the object, got %#v", info.Sys())
+	}
+	entries, err := fsys.ReadDir("dir")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %v err: %v", entries, err)
+	}
+	for _, e := range entries {
+		info, _ := e.Info()
+		if sys, ok := wfs.SysInfo[*s3.S3Info](info); ok != !e.IsDir() || ok && sys.ETag != fmt.Sprintf("%x", sum) {
+			t.Errorf("unexpected metadata of %s: %#v", e.Name(), info.Sys())
+		}
+	}
+
+	if got, err := fsys.Hash("dir/small", md5.New); err != nil || !bytes.Equal(got, sum[:]) {
+		t.Errorf("expected MD5 digest %x, got %x err: %v", sum, got, err)
+	}
+	if _, err := fsys.Hash("dir/small", sha256.New); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected SHA-256 to be unsupported, got %v", err)
+	}
+	if _, err := fsys.Hash("dir/sub/large", md5.New); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected the ETag of a multipart upload to be unsupported, got %v", err)
+	}
+	if _, err := fsys.Hash("dir", md5.New); !errors.Is(err, wfs.ErrIsDir) {
+		t.Errorf("expected ErrIsDir, got %v", err)
+	}
+	if _, err := fsys.Hash("missing", md5.New); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	largeSum := md5.Sum(large)
+	if got, err := wfs.HashFile(fsys, "dir/sub/large", md5.New); err != nil || !bytes.Equal(got, largeSum[:]) {
+		t.Errorf("expected HashFile to read the object, got %x err: %v", got, err)
+	}
+
+	if files, size, err := fsys.Usage("dir"); err != nil || files != 2 || size != int64(len(large)+5) {
+		t.Errorf("expected 2 files of %d bytes, got %d %d err: %v", len(large)+5, files, size, err)
+	}
+	if files, size, err := fsys.Usage("dir/small"); err != nil || files != 1 || size != 5 {
+		t.Errorf("expected 1 file of 5 bytes, got %d %d err: %v", files, size, err)
+	}
+	if _, _, err := fsys.Usage("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestOpenURL(t *testing.T) {
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	fsys, err := wfs.OpenURL(t.Context(), "s3://bucket/app/data?region=eu-west-1&endpoint=http://localhost:9000&path_style=true")
+	if err != nil {
+		t.Fatalf("OpenURL failed: %v", err)
+	}
+	if _, ok := fsys.(*s3.FS); !ok {
+		t.Errorf("expected *s3.FS, got %T", fsys)
+	}
+	if _, err := wfs.OpenURL(t.Context(), "s3://bucket?path_style=maybe"); err == nil {
+		t.Errorf("expected an invalid path_style to fail")
+	}
+}
+
+func TestConformance(t *testing.T) {
+	wfstest.TestFS(t, func() wfs.FS {
+		return s3.New(newFakeClient(), "bucket", "")
+	}, wfstest.Concurrent())
+}
+
+func BenchmarkS3(b *testing.B) {
+	wfsbench.Run(b, func() wfs.FS {
+		return s3.New(newFakeClient(), "bucket", "")
+	})
+}
@@ -0,0 +1,46 @@
+package s3
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/eriicafes/wfs"
+)
+
+func init() {
+	wfs.RegisterScheme("s3", openURL)
+}
+
+// openURL opens URLs of the form "s3://bucket/prefix" for [wfs.OpenURL],
+// with a client created from the default AWS configuration. The query
+// parameters region, endpoint and path_style override the region, the
+// endpoint of an S3 compatible store and whether path-style addressing is
+// used.
+func openURL(ctx context.Context, u *url.URL) (wfs.FS, error) {
+	q := u.Query()
+	var opts []func(*config.LoadOptions) error
+	if region := q.Get("region"); region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	pathStyle := false
+	if v := q.Get("path_style"); v != "" {
+		if pathStyle, err = strconv.ParseBool(v); err != nil {
+			return nil, err
+		}
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint := q.Get("endpoint"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+		o.UsePathStyle = pathStyle
+	})
+	return New(client, u.Host, u.Path), nil
+}
@@ -0,0 +1,170 @@
+package wfs
+
+import (
+	"io/fs"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SnapshotFS is a file system that can take named point-in-time snapshots
+// of itself and revert to them.
+type SnapshotFS interface {
+	FS
+
+	// Snapshot records the current state of the file system as name.
+	Snapshot(name string) error
+
+	// Snapshots returns the names of the snapshots in the order they were taken.
+	Snapshots() []string
+
+	// View returns a read-only view of the named snapshot.
+	View(name string) (fs.FS, error)
+
+	// Revert discards every change made since the named snapshot and the
+	// snapshots taken after it.
+	Revert(name string) error
+}
+
+// Snapshotter returns a SnapshotFS for fsys whose snapshots are copy-on-write
+// layers. Taking a snapshot freezes the current layers and stacks a new
+// in-memory layer over them with [Overlay], so snapshots are cheap to take
+// and only the changes made after a snapshot are stored. Every snapshot adds
+// a layer that reads may have to pass through.
+//
+// Once a snapshot is taken fsys is no longer modified, changes must be made
+// through the returned file system. Names must be valid according to
+// [fs.ValidPath] and names starting with ".wh." are reserved.
+func Snapshotter(fsys FS) SnapshotFS {
+	return &snapshotFs{layers: []FS{fsys}}
+}
+
+type snapshotFs struct {
+	mu     sync.RWMutex
+	layers []FS     // the first is fsys and the last receives changes
+	names  []string // the snapshot freezing layers[:i+1] is names[i]
+}
+
+// stack returns the file system stacking layers.
+func stack(layers []FS) FS {
+	fsys := layers[0]
+	for _, upper := range layers[1:] {
+		fsys = Overlay(upper, fsys)
+	}
+	return fsys
+}
+
+// live returns the file system changes are applied to.
+func (f *snapshotFs) live() FS {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return stack(f.layers)
+}
+
+func (f *snapshotFs) Snapshot(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if slices.Contains(f.names, name) {
+		return &fs.PathError{Op: "snapshot", Path: name, Err: fs.ErrExist}
+	}
+	f.names = append(f.names, name)
+	f.layers = append(f.layers, Mem())
+	return nil
+}
+
+func (f *snapshotFs) Snapshots() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return slices.Clone(f.names)
+}
+
+func (f *snapshotFs) View(name string) (fs.FS, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	i := slices.Index(f.names, name)
+	if i < 0 {
+		return nil, &fs.PathError{Op: "snapshot", Path: name, Err: fs.ErrNotExist}
+	}
+	// frozen layers are never modified
+	return ReadOnly(stack(f.layers[:i+1])), nil
+}
+
+func (f *snapshotFs) Revert(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := slices.Index(f.names, name)
+	if i < 0 {
+		return &fs.PathError{Op: "revert", Path: name, Err: fs.ErrNotExist}
+	}
+	f.names = f.names[:i+1]
+	f.layers = append(f.layers[:i+1:i+1], Mem())
+	return nil
+}
+
+func (f *snapshotFs) Open(name string) (fs.File, error) {
+	return f.live().Open(name)
+}
+
+// Stat implements [fs.StatFS] for snapshotFs.
+func (f *snapshotFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.live(), name)
+}
+
+func (f *snapshotFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return f.live().OpenFile(name, flag, perm)
+}
+
+func (f *snapshotFs) Rename(oldpath, newpath string) error {
+	return f.live().Rename(oldpath, newpath)
+}
+
+func (f *snapshotFs) Remove(name string) error {
+	return f.live().Remove(name)
+}
+
+func (f *snapshotFs) RemoveAll(path string) error {
+	return f.live().RemoveAll(path)
+}
+
+func (f *snapshotFs) Mkdir(name string, perm fs.FileMode) error {
+	return f.live().Mkdir(name, perm)
+}
+
+func (f *snapshotFs) MkdirAll(path string, perm fs.FileMode) error {
+	return f.live().MkdirAll(path, perm)
+}
+
+// Symlink implements [SymlinkFS] for snapshotFs.
+func (f *snapshotFs) Symlink(oldname, newname string) error {
+	return Symlink(f.live(), oldname, newname)
+}
+
+// Readlink implements [SymlinkFS] for snapshotFs.
+func (f *snapshotFs) Readlink(name string) (string, error) {
+	return Readlink(f.live(), name)
+}
+
+// Lstat implements [SymlinkFS] for snapshotFs.
+func (f *snapshotFs) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(f.live(), name)
+}
+
+// Link implements [LinkFS] for snapshotFs.
+func (f *snapshotFs) Link(oldname, newname string) error {
+	return Link(f.live(), oldname, newname)
+}
+
+// Chmod implements [MetaFS] for snapshotFs.
+func (f *snapshotFs) Chmod(name string, mode fs.FileMode) error {
+	return Chmod(f.live(), name, mode)
+}
+
+// Chown implements [MetaFS] for snapshotFs.
+func (f *snapshotFs) Chown(name string, uid, gid int) error {
+	return Chown(f.live(), name, uid, gid)
+}
+
+// Chtimes implements [MetaFS] for snapshotFs.
+func (f *snapshotFs) Chtimes(name string, atime, mtime time.Time) error {
+	return Chtimes(f.live(), name, atime, mtime)
+}
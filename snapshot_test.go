@@ -0,0 +1,83 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestSnapshotter(t *testing.T) {
+	for _, tt := range fileSystems {
+		if tt.name == "OS FS" {
+			// snapshot layers only accept valid paths
+			continue
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"data/a": &fstest.MapFile{Data: []byte("a1")},
+				"data/b": &fstest.MapFile{Data: []byte("b1")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			data := filepath.Join(base, "data")
+
+			sfs := wfs.Snapshotter(fsys)
+			if err := sfs.Snapshot("before"); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+			if err := sfs.Snapshot("before"); !errors.Is(err, fs.ErrExist) {
+				t.Errorf("expected ErrExist for a duplicate snapshot, got %v", err)
+			}
+			wfs.WriteFile(sfs, filepath.Join(data, "a"), []byte("a2"), 0644)
+			sfs.Remove(filepath.Join(data, "b"))
+			wfs.WriteFile(sfs, filepath.Join(data, "c"), []byte("c2"), 0644)
+			if err := sfs.Snapshot("after"); err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+			sfs.RemoveAll(data)
+
+			// the underlying file system is frozen
+			assertEntries(t, fsys, data, "a", "b")
+			assertContent(t, fsys, filepath.Join(data, "a"), "a1")
+
+			before, err := sfs.View("before")
+			if err != nil {
+				t.Fatalf("View failed: %v", err)
+			}
+			assertEntries(t, before, data, "a", "b")
+			assertContent(t, before, filepath.Join(data, "a"), "a1")
+			after, err := sfs.View("after")
+			if err != nil {
+				t.Fatalf("View failed: %v", err)
+			}
+			assertEntries(t, after, data, "a", "c")
+			assertContent(t, after, filepath.Join(data, "a"), "a2")
+			if err := wfs.WriteFile(after.(wfs.FS), filepath.Join(data, "a"), nil, 0644); !errors.Is(err, fs.ErrPermission) {
+				t.Errorf("expected snapshot view to be read-only, got %v", err)
+			}
+
+			if err := sfs.Revert("after"); err != nil {
+				t.Fatalf("Revert failed: %v", err)
+			}
+			assertEntries(t, sfs, data, "a", "c")
+			if err := sfs.Revert("before"); err != nil {
+				t.Fatalf("Revert failed: %v", err)
+			}
+			assertEntries(t, sfs, data, "a", "b")
+			assertContent(t, sfs, filepath.Join(data, "a"), "a1")
+			if names := sfs.Snapshots(); !slices.Equal(names, []string{"before"}) {
+				t.Errorf("expected snapshots [before], got %v", names)
+			}
+			if _, err := sfs.View("after"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected reverted snapshot to be dropped, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package wfs
+
+import (
+	"io/fs"
+	"os"
+	"path"
+)
+
+// Validator inspects a staged file before it is promoted,
+// name is the path of the staged file in fsys.
+// A non-nil error prevents the file from being promoted.
+type Validator func(fsys fs.FS, name string) error
+
+// Staging is a quarantine area where files are written before being
+// promoted into a live tree once they pass validation.
+//
+// Both directories must live on the same file system so that promotion is a
+// single Rename. Names are relative to both directories and must be valid
+// according to [fs.ValidPath], other names fail with [fs.ErrInvalid].
+type Staging struct {
+	fsys       FS
+	stage      string
+	live       string
+	validators []Validator
+}
+
+// NewStaging returns a Staging that writes files under stageDir and promotes
+// them into liveDir after running validators in order.
+func NewStaging(fsys FS, stageDir, liveDir string, validators ...Validator) *Staging {
+	return &Staging{fsys: fsys, stage: stageDir, live: liveDir, validators: validators}
+}
+
+// OpenFile opens the named file in the staging area, creating
+// any missing parent directories when the [os.O_CREATE] flag is passed.
+func (s *Staging) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	staged, err := s.join("open", s.stage, name)
+	if err != nil {
+		return nil, err
+	}
+	if flag&os.O_CREATE != 0 {
+		if err := mkdirParent(s.fsys, staged); err != nil {
+			return nil, err
+		}
+	}
+	return s.fsys.OpenFile(staged, flag, perm)
+}
+
+// Create creates or truncates the named file in the staging area.
+func (s *Staging) Create(name string) (File, error) {
+	return s.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Promote validates the named staged file and moves it into the live tree,
+// replacing any existing file. If a validator fails the file remains staged
+// and the error is returned as a [*fs.PathError].
+func (s *Staging) Promote(name string) error {
+	staged, err := s.join("promote", s.stage, name)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.Stat(s.fsys, staged); err != nil {
+		return err
+	}
+	for _, validate := range s.validators {
+		if err := validate(s.fsys, staged); err != nil {
+			return &fs.PathError{Op: "promote", Path: name, Err: err}
+		}
+	}
+	live, _ := s.join("promote", s.live, name)
+	if err := mkdirParent(s.fsys, live); err != nil {
+		return err
+	}
+	return s.fsys.Rename(staged, live)
+}
+
+// Discard removes the named file from the staging area.
+func (s *Staging) Discard(name string) error {
+	staged, err := s.join("remove", s.stage, name)
+	if err != nil {
+		return err
+	}
+	return s.fsys.Remove(staged)
+}
+
+// join returns the path of name in dir. Names must be valid according to
+// [fs.ValidPath] and cannot be "." so that they stay below dir.
+func (s *Staging) join(op, dir, name string) (string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(dir, name), nil
+}
+
+// mkdirParent creates the parent directories of name.
+func mkdirParent(fsys DirFS, name string) error {
+	dir := path.Dir(name)
+	if dir == "." || dir == "/" {
+		return nil
+	}
+	return fsys.MkdirAll(dir, 0777)
+}
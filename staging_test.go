@@ -0,0 +1,85 @@
+package wfs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestStaging(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"staging": &fstest.MapFile{Mode: fs.ModeDir | 0755},
+				"live":    &fstest.MapFile{Mode: fs.ModeDir | 0755},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			errInfected := errors.New("infected")
+			scan := func(fsys fs.FS, name string) error {
+				b, err := fs.ReadFile(fsys, name)
+				if err != nil {
+					return err
+				}
+				if string(b) == "virus" {
+					return errInfected
+				}
+				return nil
+			}
+			stage := wfs.NewStaging(fsys, filepath.Join(base, "staging"), filepath.Join(base, "live"), scan)
+
+			for name, data := range map[string]string{"uploads/clean": "clean", "uploads/infected": "virus"} {
+				f, err := stage.Create(name)
+				if err != nil {
+					t.Fatalf("Create failed: %v", err)
+				}
+				io.WriteString(f, data)
+				f.Close()
+			}
+
+			if err := stage.Promote("uploads/clean"); err != nil {
+				t.Fatalf("Promote failed: %v", err)
+			}
+			b, err := fs.ReadFile(fsys, filepath.Join(base, "live", "uploads", "clean"))
+			if err != nil || string(b) != "clean" {
+				t.Errorf("expected promoted file 'clean', got %q err: %v", b, err)
+			}
+			if _, err := fs.Stat(fsys, filepath.Join(base, "staging", "uploads", "clean")); err == nil {
+				t.Errorf("promoted file should no longer be staged")
+			}
+
+			if err := stage.Promote("uploads/infected"); !errors.Is(err, errInfected) {
+				t.Fatalf("expected Promote to fail validation, got %v", err)
+			}
+			if _, err := fs.Stat(fsys, filepath.Join(base, "live", "uploads", "infected")); err == nil {
+				t.Errorf("rejected file should not be promoted")
+			}
+			if err := stage.Discard("uploads/infected"); err != nil {
+				t.Errorf("Discard failed: %v", err)
+			}
+
+			for _, name := range []string{"../live/index.html", ".", "/live/index.html"} {
+				if _, err := stage.Create(name); !errors.Is(err, fs.ErrInvalid) {
+					t.Errorf("expected Create(%q) to fail with ErrInvalid, got %v", name, err)
+				}
+				if err := stage.Promote(name); !errors.Is(err, fs.ErrInvalid) {
+					t.Errorf("expected Promote(%q) to fail with ErrInvalid, got %v", name, err)
+				}
+				if err := stage.Discard(name); !errors.Is(err, fs.ErrInvalid) {
+					t.Errorf("expected Discard(%q) to fail with ErrInvalid, got %v", name, err)
+				}
+			}
+			if _, err := fs.Stat(fsys, filepath.Join(base, "live", "index.html")); err == nil {
+				t.Errorf("invalid name should not write into the live tree")
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package wfs
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+type subFs struct {
+	fsys FS
+	dir  string
+}
+
+// Sub returns an FS corresponding to the subtree rooted at fsys's dir.
+//
+// Names passed to the returned file system must be valid according to
+// [fs.ValidPath], so operations cannot escape the subtree. Unlike [fs.Sub],
+// dir may also be an absolute path for file systems such as [OS] that
+// accept them. If dir is ".", Sub returns fsys unchanged.
+func Sub(fsys FS, dir string) (FS, error) {
+	if !fs.ValidPath(dir) && (!path.IsAbs(dir) || path.Clean(dir) != dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return fsys, nil
+	}
+	return &subFs{fsys, dir}, nil
+}
+
+// fullName maps name to the full name in the underlying file system.
+func (f *subFs) fullName(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(f.dir, name), nil
+}
+
+// shorten maps name in the underlying file system to a name in the subtree.
+func (f *subFs) shorten(name string) (string, bool) {
+	if name == f.dir {
+		return ".", true
+	}
+	rel, ok := strings.CutPrefix(name, f.dir+"/")
+	if f.dir == "/" {
+		rel, ok = strings.CutPrefix(name, "/")
+	}
+	return rel, ok && rel != ""
+}
+
+// fixErr shortens any reported names in errors returned by the underlying file system.
+func (f *subFs) fixErr(err error) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		if short, ok := f.shorten(pe.Path); ok {
+			pe.Path = short
+		}
+	}
+	var le *os.LinkError
+	if errors.As(err, &le) {
+		if short, ok := f.shorten(le.Old); ok {
+			le.Old = short
+		}
+		if short, ok := f.shorten(le.New); ok {
+			le.New = short
+		}
+	}
+	return err
+}
+
+func (f *subFs) Open(name string) (fs.File, error) {
+	full, err := f.fullName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := f.fsys.Open(full)
+	return file, f.fixErr(err)
+}
+
+// Stat implements [fs.StatFS] for subFs.
+func (f *subFs) Stat(name string) (fs.FileInfo, error) {
+	full, err := f.fullName("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(f.fsys, full)
+	return info, f.fixErr(err)
+}
+
+// Sub implements [fs.SubFS] for subFs.
+func (f *subFs) Sub(dir string) (fs.FS, error) {
+	if dir == "." {
+		return f, nil
+	}
+	full, err := f.fullName("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	return &subFs{f.fsys, full}, nil
+}
+
+func (f *subFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	full, err := f.fullName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := f.fsys.OpenFile(full, flag, perm)
+	if err != nil {
+		return nil, f.fixErr(err)
+	}
+	return &subFile{file, name}, nil
+}
+
+func (f *subFs) Rename(oldpath, newpath string) error {
+	oldfull, err1 := f.fullName("rename", oldpath)
+	newfull, err2 := f.fullName("rename", newpath)
+	if err1 != nil || err2 != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	return f.fixErr(f.fsys.Rename(oldfull, newfull))
+}
+
+func (f *subFs) Remove(name string) error {
+	full, err := f.fullName("remove", name)
+	if err != nil {
+		return err
+	}
+	if full == f.dir {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.fixErr(f.fsys.Remove(full))
+}
+
+func (f *subFs) RemoveAll(path string) error {
+	full, err := f.fullName("RemoveAll", path)
+	if err != nil {
+		return err
+	}
+	if full == f.dir {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: fs.ErrInvalid}
+	}
+	return f.fixErr(f.fsys.RemoveAll(full))
+}
+
+func (f *subFs) Mkdir(name string, perm fs.FileMode) error {
+	full, err := f.fullName("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return f.fixErr(f.fsys.Mkdir(full, perm))
+}
+
+func (f *subFs) MkdirAll(path string, perm fs.FileMode) error {
+	full, err := f.fullName("mkdir", path)
+	if err != nil {
+		return err
+	}
+	return f.fixErr(f.fsys.MkdirAll(full, perm))
+}
+
+// subFile reports the name it was opened with in the subtree.
+type subFile struct {
+	File
+	name string
+}
+
+func (f *subFile) Name() string {
+	return f.name
+}
+
+func (f *subFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return readDir(f.File, n)
+}
+
+// Symlink implements [SymlinkFS] for subFs.
+// The destination oldname is stored as given, use [Root] to prevent
+// symbolic links from escaping a directory of the OS file system.
+func (f *subFs) Symlink(oldname, newname string) error {
+	full, err := f.fullName("symlink", newname)
+	if err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	return f.fixErr(Symlink(f.fsys, oldname, full))
+}
+
+// Readlink implements [SymlinkFS] for subFs.
+func (f *subFs) Readlink(name string) (string, error) {
+	full, err := f.fullName("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	target, err := Readlink(f.fsys, full)
+	return target, f.fixErr(err)
+}
+
+// Lstat implements [SymlinkFS] for subFs.
+func (f *subFs) Lstat(name string) (fs.FileInfo, error) {
+	full, err := f.fullName("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := Lstat(f.fsys, full)
+	return info, f.fixErr(err)
+}
+
+// Link implements [LinkFS] for subFs.
+func (f *subFs) Link(oldname, newname string) error {
+	oldfull, err1 := f.fullName("link", oldname)
+	newfull, err2 := f.fullName("link", newname)
+	if err1 != nil || err2 != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	return f.fixErr(Link(f.fsys, oldfull, newfull))
+}
+
+// Chmod implements [MetaFS] for subFs.
+func (f *subFs) Chmod(name string, mode fs.FileMode) error {
+	full, err := f.fullName("chmod", name)
+	if err != nil {
+		return err
+	}
+	return f.fixErr(Chmod(f.fsys, full, mode))
+}
+
+// Chown implements [MetaFS] for subFs.
+func (f *subFs) Chown(name string, uid, gid int) error {
+	full, err := f.fullName("chown", name)
+	if err != nil {
+		return err
+	}
+	return f.fixErr(Chown(f.fsys, full, uid, gid))
+}
+
+// Chtimes implements [MetaFS] for subFs.
+func (f *subFs) Chtimes(name string, atime, mtime time.Time) error {
+	full, err := f.fullName("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return f.fixErr(Chtimes(f.fsys, full, atime, mtime))
+}
+
+// Lock implements [LockFS] for subFs.
+func (f *subFs) Lock(name string) (Unlocker, error) {
+	full, err := f.fullName("lock", name)
+	if err != nil {
+		return nil, err
+	}
+	l, err := Lock(f.fsys, full)
+	return l, f.fixErr(err)
+}
+
+// RLock implements [LockFS] for subFs.
+func (f *subFs) RLock(name string) (Unlocker, error) {
+	full, err := f.fullName("rlock", name)
+	if err != nil {
+		return nil, err
+	}
+	l, err := RLock(f.fsys, full)
+	return l, f.fixErr(err)
+}
+
+// TryLock implements [LockFS] for subFs.
+func (f *subFs) TryLock(name string) (Unlocker, error) {
+	full, err := f.fullName("lock", name)
+	if err != nil {
+		return nil, err
+	}
+	l, err := TryLock(f.fsys, full)
+	return l, f.fixErr(err)
+}
+
+// Watch implements [WatchFS] for subFs.
+func (f *subFs) Watch(name string, opts WatchOptions) (<-chan Event, func(), error) {
+	full, err := f.fullName("watch", name)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, stop, err := Watch(f.fsys, full, opts)
+	if err != nil {
+		return nil, nil, f.fixErr(err)
+	}
+	out := make(chan Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+		for e := range events {
+			e, ok := f.shortenEvent(e)
+			if !ok {
+				continue
+			}
+			select {
+			case out <- e:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			close(done)
+			stop()
+		})
+	}, nil
+}
+
+// shortenEvent maps the names of an event in the underlying file system to
+// names in the subtree. Renames across the subtree boundary become creates
+// and removes, and the removal of a parent becomes a remove of the root.
+func (f *subFs) shortenEvent(e Event) (Event, bool) {
+	short, ok := f.shorten(e.Path)
+	old, oldOk := f.shorten(e.OldPath)
+	switch {
+	case ok && e.OldPath == "":
+		e.Path = short
+	case ok && oldOk:
+		e.Path, e.OldPath = short, old
+	case ok:
+		e.Op, e.Path, e.OldPath = OpCreate, short, ""
+	case oldOk:
+		e.Op, e.Path, e.OldPath = OpRemove, old, ""
+	case (e.Op == OpRemove || e.Op == OpRename) && strings.HasPrefix(f.dir, cmp.Or(e.OldPath, e.Path)+"/"):
+		e.Op, e.Path, e.OldPath = OpRemove, ".", ""
+	default:
+		return e, false
+	}
+	return e, true
+}
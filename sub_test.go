@@ -0,0 +1,68 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestSub(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"plugin/file": &fstest.MapFile{Data: []byte("Hello")},
+				"secret":      &fstest.MapFile{Data: []byte("secret")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			sub, err := wfs.Sub(fsys, filepath.Join(base, "plugin"))
+			if err != nil {
+				t.Fatalf("Sub failed: %v", err)
+			}
+			b, err := fs.ReadFile(sub, "file")
+			if err != nil || string(b) != "Hello" {
+				t.Errorf("expected to read 'Hello', got %q err: %v", b, err)
+			}
+
+			if err := sub.Mkdir("dir", 0755); err != nil {
+				t.Fatalf("Mkdir failed: %v", err)
+			}
+			f, err := sub.OpenFile("dir/new", os.O_WRONLY|os.O_CREATE, 0644)
+			if err != nil {
+				t.Fatalf("OpenFile failed: %v", err)
+			}
+			if f.Name() != "dir/new" {
+				t.Errorf("expected name 'dir/new', got %q", f.Name())
+			}
+			f.Close()
+			if err := sub.Rename("dir/new", "renamed"); err != nil {
+				t.Fatalf("Rename failed: %v", err)
+			}
+			if _, err := fs.Stat(fsys, filepath.Join(base, "plugin", "renamed")); err != nil {
+				t.Errorf("expected renamed file in underlying file system: %v", err)
+			}
+
+			for _, name := range []string{"../secret", "/secret", "dir/../../secret"} {
+				if _, err := sub.OpenFile(name, os.O_RDONLY, 0); !errors.Is(err, fs.ErrInvalid) {
+					t.Errorf("OpenFile %q: expected ErrInvalid, got %v", name, err)
+				}
+				if err := sub.Remove(name); !errors.Is(err, fs.ErrInvalid) {
+					t.Errorf("Remove %q: expected ErrInvalid, got %v", name, err)
+				}
+			}
+
+			var pe *fs.PathError
+			if _, err := sub.Open("missing"); !errors.As(err, &pe) || pe.Path != "missing" {
+				t.Errorf("expected error for 'missing', got %v", err)
+			}
+		})
+	}
+}
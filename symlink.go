@@ -0,0 +1,57 @@
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// SymlinkFS is the interface implemented by a file system
+// that supports symbolic links.
+type SymlinkFS interface {
+	FS
+
+	// Symlink creates newname as a symbolic link to oldname.
+	// If there is an error, it will be of type [*os.LinkError].
+	Symlink(oldname, newname string) error
+
+	// Readlink returns the destination of the named symbolic link.
+	// If there is an error, it will be of type [*fs.PathError].
+	Readlink(name string) (string, error)
+
+	// Lstat returns a [fs.FileInfo] describing the named file.
+	// If the file is a symbolic link, the returned FileInfo
+	// describes the symbolic link. Lstat makes no attempt to follow the link.
+	// If there is an error, it will be of type [*fs.PathError].
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// Symlink creates newname as a symbolic link to oldname in fsys.
+// If fsys does not implement [SymlinkFS], Symlink returns an error
+// that wraps [errors.ErrUnsupported].
+func Symlink(fsys FS, oldname, newname string) error {
+	if fsys, ok := fsys.(SymlinkFS); ok {
+		return fsys.Symlink(oldname, newname)
+	}
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: errors.ErrUnsupported}
+}
+
+// Readlink returns the destination of the named symbolic link in fsys.
+// If fsys does not implement [SymlinkFS], Readlink returns an error
+// that wraps [errors.ErrUnsupported].
+func Readlink(fsys fs.FS, name string) (string, error) {
+	if fsys, ok := fsys.(SymlinkFS); ok {
+		return fsys.Readlink(name)
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: errors.ErrUnsupported}
+}
+
+// Lstat returns a [fs.FileInfo] describing the named file in fsys without
+// following a final symbolic link. If fsys does not implement [SymlinkFS]
+// it cannot contain symbolic links and Lstat is equivalent to [fs.Stat].
+func Lstat(fsys fs.FS, name string) (fs.FileInfo, error) {
+	if fsys, ok := fsys.(SymlinkFS); ok {
+		return fsys.Lstat(name)
+	}
+	return fs.Stat(fsys, name)
+}
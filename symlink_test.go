@@ -0,0 +1,70 @@
+package wfs_test
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestSymlink(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dir/target": &fstest.MapFile{Data: []byte("Hello")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			link := filepath.Join(base, "link")
+			if err := wfs.Symlink(fsys, "dir/target", link); err != nil {
+				t.Fatalf("Symlink failed: %v", err)
+			}
+			dirLink := filepath.Join(base, "dirlink")
+			if err := wfs.Symlink(fsys, "dir", dirLink); err != nil {
+				t.Fatalf("Symlink failed: %v", err)
+			}
+
+			target, err := wfs.Readlink(fsys, link)
+			if err != nil || target != "dir/target" {
+				t.Errorf("expected link destination 'dir/target', got %q err: %v", target, err)
+			}
+			info, err := wfs.Lstat(fsys, link)
+			if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+				t.Errorf("expected Lstat to describe the link, got %v err: %v", info, err)
+			}
+			info, err = fs.Stat(fsys, link)
+			if err != nil || !info.Mode().IsRegular() {
+				t.Errorf("expected Stat to follow the link, got %v err: %v", info, err)
+			}
+
+			for _, name := range []string{link, filepath.Join(dirLink, "target")} {
+				b, err := fs.ReadFile(fsys, name)
+				if err != nil || string(b) != "Hello" {
+					t.Errorf("expected to read 'Hello' through %q, got %q err: %v", name, b, err)
+				}
+			}
+
+			// writing through a link writes the destination
+			if err := wfs.WriteFile(fsys, link, []byte("World"), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			b, err := fs.ReadFile(fsys, filepath.Join(base, "dir", "target"))
+			if err != nil || string(b) != "World" {
+				t.Errorf("expected destination to contain 'World', got %q err: %v", b, err)
+			}
+
+			// removing a link keeps the destination
+			if err := fsys.Remove(link); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+			if _, err := fs.Stat(fsys, filepath.Join(base, "dir", "target")); err != nil {
+				t.Errorf("expected destination to remain: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,454 @@
+package wfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"time"
+)
+
+var errBadSyncState = errors.New("invalid sync state")
+
+// ComparePolicy is how [Sync] decides whether a file is up to date.
+type ComparePolicy int
+
+const (
+	// CompareModTime considers files with the same size and modification
+	// time, to the second, up to date. It requires the destination to keep
+	// modification times, see [MetaFS].
+	CompareModTime ComparePolicy = iota
+
+	// CompareHash considers files with the same size and SHA-256 digest up
+	// to date. Digests are computed with [HashFile].
+	CompareHash
+)
+
+// SyncOp is the kind of a [SyncAction].
+type SyncOp int
+
+const (
+	// SyncCreate creates a file, directory or symbolic link missing from
+	// the destination.
+	SyncCreate SyncOp = iota
+
+	// SyncUpdate replaces a file, directory or symbolic link that differs
+	// from the source.
+	SyncUpdate
+
+	// SyncDelete removes a file or directory that is not in the source.
+	SyncDelete
+)
+
+// String returns the name of the operation, such as "create".
+func (op SyncOp) String() string {
+	switch op {
+	case SyncCreate:
+		return "create"
+	case SyncUpdate:
+		return "update"
+	case SyncDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// SyncAction is a change made by [Sync] to the destination.
+type SyncAction struct {
+	Op   SyncOp
+	Path string // slash-separated path relative to the roots
+	Size int64  // bytes copied, zero for directories, links and deletions
+}
+
+// SyncStats summarizes the changes made by [Sync].
+type SyncStats struct {
+	Created   int
+	Updated   int
+	Deleted   int
+	Unchanged int   // files that were already up to date
+	Bytes     int64 // total size of the files copied
+}
+
+// SyncOptions configures [Sync].
+// A nil *SyncOptions is equivalent to the zero value.
+type SyncOptions struct {
+	// Compare is how files present in both trees are compared.
+	Compare ComparePolicy
+
+	// Exclude skips files and directories matching any pattern, as in
+	// [CopyOptions]. Excluded files are neither copied nor deleted.
+	Exclude []string
+
+	// KeepExtra keeps files in the destination that are not in the source.
+	KeepExtra bool
+
+	// DryRun reports the actions without making any changes.
+	DryRun bool
+
+	// State, if set, names a file in the destination recording the source
+	// entries as they were synced. Entries whose size and modification time
+	// match the state are not compared again, and with [CompareHash] files
+	// whose digest matches the state are not transferred, so repeated syncs
+	// only look at the destination for changed files. Deleted files are also
+	// found from the state instead of walking the destination. The state
+	// file is excluded from the sync and replaced after every successful
+	// sync, its directory must exist.
+	State string
+
+	// Progress, if set, is called after each action.
+	Progress func(action SyncAction)
+}
+
+// Sync makes the tree of dst match the tree of src, creating and updating
+// the files, directories and symbolic links that differ and deleting those
+// that are not in src. Modes and modification times are preserved when dst
+// implements [MetaFS]. Symbolic links are skipped unless both src and dst
+// implement [SymlinkFS].
+//
+// Sync is one-way, changes made to dst are overwritten by the next Sync.
+// When a state file is used, changes made to dst by other means are only
+// noticed once the source changes. If Sync fails, the changes made so far
+// are kept and the returned stats describe them.
+func Sync(dst FS, src fs.FS, opts *SyncOptions) (SyncStats, error) {
+	s := &syncer{
+		dst:     dst,
+		src:     src,
+		seen:    make(map[string]bool),
+		created: make(map[string]bool),
+		next:    make(map[string]syncEntry),
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	for _, pattern := range s.opts.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return SyncStats{}, err
+		}
+	}
+	if s.opts.State != "" {
+		if err := s.loadState(); err != nil {
+			return SyncStats{}, err
+		}
+	}
+	if err := fs.WalkDir(src, ".", s.update); err != nil {
+		return s.stats, err
+	}
+	if !s.opts.KeepExtra {
+		var err error
+		if s.prev != nil {
+			err = s.deleteRecorded()
+		} else {
+			err = fs.WalkDir(dst, ".", s.delete)
+		}
+		if err != nil {
+			return s.stats, err
+		}
+	}
+	if s.opts.DryRun {
+		return s.stats, nil
+	}
+	for _, d := range slices.Backward(s.dirs) {
+		if err := copyMeta(dst, d.name, d.info); err != nil {
+			return s.stats, err
+		}
+	}
+	if s.opts.State != "" {
+		return s.stats, s.saveState()
+	}
+	return s.stats, nil
+}
+
+type syncer struct {
+	dst   FS
+	src   fs.FS
+	opts  SyncOptions
+	stats SyncStats
+
+	// paths of the source and whether they are directories
+	seen map[string]bool
+	// directories created or replaced in the destination
+	created map[string]bool
+	// directories whose metadata is applied after the sync
+	dirs []copiedDir
+	// entries of the previous and of this sync, prev is nil without a
+	// previous state
+	prev, next map[string]syncEntry
+}
+
+// do reports the action and performs fn unless this is a dry run. The
+// entry of created and updated paths is recorded in the next state.
+func (s *syncer) do(op SyncOp, name string, entry syncEntry, fn func() error) error {
+	if !s.opts.DryRun {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	size := entry.Size
+	if op == SyncDelete {
+		size = 0
+	} else {
+		s.next[name] = entry
+	}
+	switch op {
+	case SyncCreate:
+		s.stats.Created++
+	case SyncUpdate:
+		s.stats.Updated++
+	case SyncDelete:
+		s.stats.Deleted++
+	}
+	s.stats.Bytes += size
+	if s.opts.Progress != nil {
+		s.opts.Progress(SyncAction{Op: op, Path: name, Size: size})
+	}
+	return nil
+}
+
+// update brings the destination of a source entry up to date.
+func (s *syncer) update(name string, d fs.DirEntry, err error) error {
+	if err != nil {
+		return err
+	}
+	if name != "." && s.excluded(name) {
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	}
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+	var entry syncEntry
+	switch {
+	case d.IsDir():
+		s.seen[name] = true
+		s.dirs = append(s.dirs, copiedDir{name, info})
+		entry.Dir = true
+	case d.Type()&fs.ModeSymlink != 0:
+		dest, err := Readlink(s.src, name)
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := s.dst.(SymlinkFS); !ok {
+			return nil
+		}
+		s.seen[name] = false
+		entry.Link = dest
+	case d.Type().IsRegular():
+		s.seen[name] = false
+		entry.Size, entry.ModTime = info.Size(), info.ModTime().UnixNano()
+	default:
+		return &fs.PathError{Op: "sync", Path: name, Err: fs.ErrInvalid}
+	}
+
+	// entries recorded by the previous sync are trusted without looking at
+	// the destination
+	prev, ok := s.prev[name]
+	if ok && prev.Size == entry.Size && prev.ModTime == entry.ModTime && prev.Dir == entry.Dir && prev.Link == entry.Link {
+		return s.unchanged(name, prev)
+	}
+	regular := d.Type().IsRegular()
+	if regular && s.opts.Compare == CompareHash {
+		sum, err := HashFile(s.src, name, sha256.New)
+		if err != nil {
+			return err
+		}
+		entry.SHA256 = hex.EncodeToString(sum)
+		if ok && prev.Size == entry.Size && prev.SHA256 == entry.SHA256 {
+			return s.unchanged(name, entry)
+		}
+	}
+
+	var existing fs.FileInfo
+	if !s.created[path.Dir(name)] {
+		existing, err = Lstat(s.dst, name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	op := SyncCreate
+	if existing != nil {
+		op = SyncUpdate
+	}
+	// replace removes the existing entry before fn creates the new one
+	replace := func(fn func() error) func() error {
+		return func() error {
+			if existing != nil {
+				if err := s.dst.RemoveAll(name); err != nil {
+					return err
+				}
+			}
+			return fn()
+		}
+	}
+
+	switch {
+	case entry.Dir:
+		if existing != nil && existing.IsDir() {
+			return s.unchanged(name, entry)
+		}
+		s.created[name] = true
+		return s.do(op, name, entry, replace(func() error {
+			return s.dst.MkdirAll(name, 0777)
+		}))
+	case regular:
+		if existing != nil && existing.Mode().IsRegular() {
+			same, err := s.same(name, entry, existing)
+			if err != nil {
+				return err
+			}
+			if same {
+				return s.unchanged(name, entry)
+			}
+			// regular files are truncated rather than removed
+			existing = nil
+		}
+		return s.do(op, name, entry, replace(func() error {
+			if err := copyFile(s.dst, name, s.src, name, info.Mode().Perm()); err != nil {
+				return err
+			}
+			return copyMeta(s.dst, name, info)
+		}))
+	}
+	if existing != nil && existing.Mode()&fs.ModeSymlink != 0 {
+		if current, err := Readlink(s.dst, name); err == nil && current == entry.Link {
+			return s.unchanged(name, entry)
+		}
+	}
+	return s.do(op, name, entry, replace(func() error {
+		return Symlink(s.dst, entry.Link, name)
+	}))
+}
+
+// unchanged records an entry that is already up to date.
+func (s *syncer) unchanged(name string, entry syncEntry) error {
+	if !entry.Dir && entry.Link == "" {
+		s.stats.Unchanged++
+	}
+	s.next[name] = entry
+	return nil
+}
+
+// same reports whether the destination file described by existing is up to
+// date with the source file described by entry.
+func (s *syncer) same(name string, entry syncEntry, existing fs.FileInfo) (bool, error) {
+	if existing.Size() != entry.Size {
+		return false, nil
+	}
+	if s.opts.Compare == CompareHash {
+		sum, err := HashFile(s.dst, name, sha256.New)
+		return hex.EncodeToString(sum) == entry.SHA256, err
+	}
+	mtime := time.Unix(0, entry.ModTime)
+	return existing.ModTime().Truncate(time.Second).Equal(mtime.Truncate(time.Second)), nil
+}
+
+// delete removes a destination entry that is not in the source.
+func (s *syncer) delete(name string, d fs.DirEntry, err error) error {
+	if err != nil || name == "." {
+		return err
+	}
+	if s.excluded(name) {
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	}
+	isDir, ok := s.seen[name]
+	if !ok {
+		if err := s.remove(name); err != nil {
+			return err
+		}
+	}
+	// the contents of directories that were removed or replaced by a file
+	// are not visited
+	if d.IsDir() && (!ok || !isDir) {
+		return fs.SkipDir
+	}
+	return nil
+}
+
+// remove deletes a destination entry that is not in the source.
+func (s *syncer) remove(name string) error {
+	return s.do(SyncDelete, name, syncEntry{}, func() error { return s.dst.RemoveAll(name) })
+}
+
+// deleteRecorded removes the entries recorded by the previous sync that are
+// not in the source, without walking the destination.
+func (s *syncer) deleteRecorded() error {
+	removed := make(map[string]bool)
+	for _, name := range slices.Sorted(maps.Keys(s.prev)) {
+		if _, ok := s.seen[name]; ok || name == "." || s.excluded(name) {
+			continue
+		}
+		parent := path.Dir(name)
+		for parent != "." && !removed[parent] {
+			parent = path.Dir(parent)
+		}
+		if removed[parent] {
+			continue
+		}
+		if err := s.remove(name); err != nil {
+			return err
+		}
+		removed[name] = true
+	}
+	return nil
+}
+
+// excluded reports whether name is skipped by the sync.
+func (s *syncer) excluded(name string) bool {
+	return name == s.opts.State || matchAny(s.opts.Exclude, name)
+}
+
+// syncState is the state file recorded by [Sync].
+type syncState struct {
+	Version int                  `json:"version"`
+	Files   map[string]syncEntry `json:"files"`
+}
+
+// syncEntry describes a source path as it was last synced.
+type syncEntry struct {
+	Size    int64  `json:"size,omitempty"`
+	ModTime int64  `json:"mtime,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+	Dir     bool   `json:"dir,omitempty"`
+	Link    string `json:"link,omitempty"`
+}
+
+// loadState reads the state file of the previous sync, if any.
+func (s *syncer) loadState() error {
+	data, err := fs.ReadFile(s.dst, s.opts.State)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state syncState
+	if err := json.Unmarshal(data, &state); err != nil || state.Version != 1 {
+		return &fs.PathError{Op: "sync", Path: s.opts.State, Err: errBadSyncState}
+	}
+	s.prev = state.Files
+	if s.prev == nil {
+		s.prev = make(map[string]syncEntry)
+	}
+	return nil
+}
+
+// saveState replaces the state file with the entries of this sync.
+func (s *syncer) saveState() error {
+	data, err := json.Marshal(syncState{Version: 1, Files: s.next})
+	if err != nil {
+		return err
+	}
+	return AtomicWriteFile(s.dst, s.opts.State, data, 0644)
+}
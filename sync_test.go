@@ -0,0 +1,162 @@
+package wfs_test
+
+import (
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestSync(t *testing.T) {
+	src, dst := wfs.Mem(), wfs.Mem()
+	for name, content := range map[string]string{
+		"index.html":        "<h1>home</h1>",
+		"assets/app.js":     "console.log(1)",
+		"assets/app.js.map": "{}",
+		"docs/guide.txt":    "guide",
+	} {
+		if err := src.MkdirAll(path.Dir(name), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := wfs.WriteFile(src, name, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	for name, content := range map[string]string{
+		"index.html":   "<h1>old</h1>",
+		"stale.txt":    "stale",
+		"docs":         "a file in place of a directory",
+		"old/file.txt": "old",
+		"keep.map":     "excluded",
+	} {
+		if err := dst.MkdirAll(path.Dir(name), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := wfs.WriteFile(dst, name, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	var actions []string
+	opts := &wfs.SyncOptions{
+		Exclude: []string{"*.map"},
+		Progress: func(a wfs.SyncAction) {
+			actions = append(actions, a.Op.String()+" "+a.Path)
+		},
+	}
+	stats, err := wfs.Sync(dst, src, opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	expected := []string{
+		"create assets",
+		"create assets/app.js",
+		"update docs",
+		"create docs/guide.txt",
+		"update index.html",
+		"delete old",
+		"delete stale.txt",
+	}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected actions %v, got %v", expected, actions)
+	}
+	if stats.Created != 3 || stats.Updated != 2 || stats.Deleted != 2 || stats.Bytes != 32 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	assertEntries(t, dst, ".", "assets", "docs", "index.html", "keep.map")
+	assertEntries(t, dst, "assets", "app.js")
+	assertContent(t, dst, "index.html", "<h1>home</h1>")
+	assertContent(t, dst, "docs/guide.txt", "guide")
+
+	// a second sync has nothing to do
+	actions = nil
+	stats, err = wfs.Sync(dst, src, opts)
+	if err != nil || len(actions) != 0 || stats.Unchanged != 3 {
+		t.Errorf("expected no actions, got %v %+v err: %v", actions, stats, err)
+	}
+}
+
+func TestSyncDryRun(t *testing.T) {
+	src, dst := wfs.Mem(), wfs.Mem()
+	wfs.WriteFile(src, "new.txt", []byte("new"), 0644)
+	wfs.WriteFile(dst, "extra.txt", []byte("extra"), 0644)
+
+	stats, err := wfs.Sync(dst, src, &wfs.SyncOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if stats.Created != 1 || stats.Deleted != 1 || stats.Bytes != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	assertEntries(t, dst, ".", "extra.txt")
+
+	stats, err = wfs.Sync(dst, src, &wfs.SyncOptions{KeepExtra: true})
+	if err != nil || stats.Created != 1 || stats.Deleted != 0 {
+		t.Errorf("unexpected stats %+v err: %v", stats, err)
+	}
+	assertEntries(t, dst, ".", "extra.txt", "new.txt")
+}
+
+func TestSyncCompare(t *testing.T) {
+	src, dst := wfs.Mem(), wfs.Mem()
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	wfs.WriteFile(src, "a.txt", []byte("new"), 0644)
+	wfs.WriteFile(dst, "a.txt", []byte("old"), 0644)
+	wfs.Chtimes(src, "a.txt", mtime, mtime)
+	wfs.Chtimes(dst, "a.txt", mtime, mtime)
+
+	// the same size and modification time hide the change
+	if stats, err := wfs.Sync(dst, src, nil); err != nil || stats.Unchanged != 1 {
+		t.Errorf("unexpected stats %+v err: %v", stats, err)
+	}
+	assertContent(t, dst, "a.txt", "old")
+
+	if stats, err := wfs.Sync(dst, src, &wfs.SyncOptions{Compare: wfs.CompareHash}); err != nil || stats.Updated != 1 {
+		t.Errorf("unexpected stats %+v err: %v", stats, err)
+	}
+	assertContent(t, dst, "a.txt", "new")
+}
+
+func TestSyncState(t *testing.T) {
+	src, dst := wfs.Mem(), wfs.Mem()
+	src.MkdirAll("docs", 0755)
+	wfs.WriteFile(src, "docs/a.txt", []byte("a"), 0644)
+	wfs.WriteFile(src, "docs/b.txt", []byte("b"), 0644)
+	opts := &wfs.SyncOptions{Compare: wfs.CompareHash, State: ".sync-state"}
+	if stats, err := wfs.Sync(dst, src, opts); err != nil || stats.Created != 3 {
+		t.Fatalf("unexpected stats %+v err: %v", stats, err)
+	}
+	assertEntries(t, dst, ".", ".sync-state", "docs")
+
+	// the destination is trusted to hold what was synced
+	wfs.WriteFile(dst, "docs/a.txt", []byte("x"), 0644)
+	// touched files with the same content are not transferred
+	now := time.Now().Add(time.Hour)
+	wfs.Chtimes(src, "docs/b.txt", now, now)
+	src.Remove("docs/a.txt")
+	wfs.WriteFile(src, "c.txt", []byte("c"), 0644)
+
+	var actions []string
+	opts.Progress = func(a wfs.SyncAction) {
+		actions = append(actions, a.Op.String()+" "+a.Path)
+	}
+	stats, err := wfs.Sync(dst, src, opts)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if expected := []string{"create c.txt", "delete docs/a.txt"}; !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected actions %v, got %v", expected, actions)
+	}
+	if stats.Unchanged != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	assertEntries(t, dst, ".", ".sync-state", "c.txt", "docs")
+	assertEntries(t, dst, "docs", "b.txt")
+
+	wfs.WriteFile(dst, ".sync-state", []byte("{"), 0644)
+	if _, err := wfs.Sync(dst, src, opts); err == nil {
+		t.Error("expected an invalid state to fail")
+	}
+}
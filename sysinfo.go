@@ -0,0 +1,31 @@
+package wfs
+
+import "io/fs"
+
+// SysInfo returns the backend-specific metadata of fi as a value of type T.
+// It reports false if fi carries no metadata of type T.
+//
+// Backends return a typed struct from [fs.FileInfo.Sys] (for example
+// [*syscall.Stat_t] on the OS backend) so that applications can reach
+// backend metadata without type-asserting internal types. Wrappers that
+// replace the Sys value of an underlying file system should return a value
+// with an Unwrap() any method reporting the original value, SysInfo follows
+// these until a value of type T is found.
+func SysInfo[T any](fi fs.FileInfo) (T, bool) {
+	var zero T
+	if fi == nil {
+		return zero, false
+	}
+	sys := fi.Sys()
+	for sys != nil {
+		if v, ok := sys.(T); ok {
+			return v, true
+		}
+		u, ok := sys.(interface{ Unwrap() any })
+		if !ok {
+			break
+		}
+		sys = u.Unwrap()
+	}
+	return zero, false
+}
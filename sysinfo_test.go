@@ -0,0 +1,46 @@
+package wfs_test
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+type testSys struct{ ID int }
+
+type wrappedSys struct{ sys any }
+
+func (w wrappedSys) Unwrap() any { return w.sys }
+
+func TestSysInfo(t *testing.T) {
+	fsys := wfs.Map(fstest.MapFS{
+		"testfile":    &fstest.MapFile{Sys: &testSys{ID: 1}},
+		"wrappedfile": &fstest.MapFile{Sys: wrappedSys{&testSys{ID: 2}}},
+		"plainfile":   &fstest.MapFile{},
+	})
+
+	tests := []struct {
+		name string
+		id   int
+		ok   bool
+	}{
+		{"testfile", 1, true},
+		{"wrappedfile", 2, true},
+		{"plainfile", 0, false},
+	}
+	for _, tc := range tests {
+		info, err := fs.Stat(fsys, tc.name)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		sys, ok := wfs.SysInfo[*testSys](info)
+		if ok != tc.ok {
+			t.Fatalf("SysInfo %q: expected ok %v, got %v", tc.name, tc.ok, ok)
+		}
+		if ok && sys.ID != tc.id {
+			t.Errorf("SysInfo %q: expected id %d, got %d", tc.name, tc.id, sys.ID)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package wfs
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// WriteTar writes the tree rooted at root in fsys to w as a tar archive.
+// Entries are named relative to root, and a root naming a single file is
+// archived under its base name. Directories, regular files and symbolic links
+// are archived with their modes and modification times, other files are
+// skipped. The archive is terminated but w is not closed.
+func WriteTar(w io.Writer, fsys fs.FS, root string) error {
+	tw := tar.NewWriter(w)
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == root && d.IsDir() {
+			return nil
+		}
+		var link string
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			if link, err = Readlink(fsys, name); err != nil {
+				return err
+			}
+		case !d.IsDir() && !d.Type().IsRegular():
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = relPath(root, name)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// ExtractTar extracts the tar archive read from r into the directory root of
+// fsys, creating root if necessary. Directories, regular files and links are
+// extracted and other entries are skipped. Existing files are replaced.
+// Modes and modification times are restored when fsys implements [MetaFS],
+// and symbolic and hard links require fsys to implement [SymlinkFS] and
+// [LinkFS].
+//
+// Entries whose names are absolute or escape root, and entries that would be
+// written through a symbolic link, fail with an error wrapping
+// [fs.ErrInvalid].
+func ExtractTar(fsys FS, root string, r io.Reader) error {
+	if err := fsys.MkdirAll(root, 0777); err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	var dirs []copiedDir
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := tarEntryName(fsys, root, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			// the root is already created
+			continue
+		}
+		target := path.Join(root, rel)
+		info := hdr.FileInfo()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := fsys.MkdirAll(target, 0777); err != nil {
+				return err
+			}
+			dirs = append(dirs, copiedDir{target, info})
+			continue
+		case tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
+		default:
+			continue
+		}
+		if err := mkdirParent(fsys, target); err != nil {
+			return err
+		}
+		if err := removeExisting(fsys, target); err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			if err := Symlink(fsys, hdr.Linkname, target); err != nil {
+				return err
+			}
+			continue
+		case tar.TypeLink:
+			old, err := tarEntryName(fsys, root, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := Link(fsys, path.Join(root, old), target); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := WriteReader(fsys, target, tr, info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err := copyMeta(fsys, target, info); err != nil {
+			return err
+		}
+	}
+	// directories are modified by their entries, so their metadata is
+	// restored last
+	for _, d := range slices.Backward(dirs) {
+		if err := copyMeta(fsys, d.name, d.info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tarEntryName returns the path of an entry named name relative to root,
+// checking that it stays inside root without passing through a symbolic link.
+func tarEntryName(fsys FS, root, name string) (string, error) {
+	rel := path.Clean(strings.TrimSuffix(name, "/"))
+	if !fs.ValidPath(rel) {
+		return "", &fs.PathError{Op: "extract", Path: name, Err: fs.ErrInvalid}
+	}
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		info, err := Lstat(fsys, path.Join(root, dir))
+		if err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return "", &fs.PathError{Op: "extract", Path: name, Err: fs.ErrInvalid}
+		}
+	}
+	return rel, nil
+}
+
+// removeExisting removes the named file if it exists and is not a directory.
+func removeExisting(fsys FS, name string) error {
+	info, err := Lstat(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return &fs.PathError{Op: "extract", Path: name, Err: ErrIsDir}
+	}
+	return fsys.Remove(name)
+}
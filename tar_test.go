@@ -0,0 +1,78 @@
+package wfs_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestTar(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"src/a.txt":     &fstest.MapFile{Data: []byte("a"), Mode: 0600, ModTime: mtime},
+				"src/bin/run":   &fstest.MapFile{Data: []byte("#!/bin/sh"), Mode: 0755},
+				"src/empty":     &fstest.MapFile{Mode: fs.ModeDir | 0755},
+				"dst/stale.txt": &fstest.MapFile{Data: []byte("stale")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			var buf bytes.Buffer
+			if err := wfs.WriteTar(&buf, fsys, filepath.Join(base, "src")); err != nil {
+				t.Fatalf("WriteTar failed: %v", err)
+			}
+			dst := filepath.Join(base, "dst")
+			if err := wfs.ExtractTar(fsys, dst, bytes.NewReader(buf.Bytes())); err != nil {
+				t.Fatalf("ExtractTar failed: %v", err)
+			}
+			assertEntries(t, fsys, dst, "a.txt", "bin", "empty", "stale.txt")
+			assertContent(t, fsys, filepath.Join(dst, "a.txt"), "a")
+			assertContent(t, fsys, filepath.Join(dst, "bin/run"), "#!/bin/sh")
+			info, err := fs.Stat(fsys, filepath.Join(dst, "a.txt"))
+			if err != nil || info.Mode().Perm() != 0600 || !info.ModTime().Equal(mtime) {
+				t.Errorf("expected mode 0600 and mtime %v, got %v %v err: %v", mtime, info.Mode(), info.ModTime(), err)
+			}
+			if info, err := fs.Stat(fsys, filepath.Join(dst, "bin/run")); err != nil || info.Mode().Perm() != 0755 {
+				t.Errorf("expected mode 0755, got %v err: %v", info.Mode(), err)
+			}
+
+			// a single file is archived under its base name
+			buf.Reset()
+			if err := wfs.WriteTar(&buf, fsys, filepath.Join(base, "src/a.txt")); err != nil {
+				t.Fatalf("WriteTar failed: %v", err)
+			}
+			if hdr, err := tar.NewReader(&buf).Next(); err != nil || hdr.Name != "a.txt" {
+				t.Errorf("expected a.txt entry, got %v err: %v", hdr, err)
+			}
+		})
+	}
+}
+
+func TestExtractTarInvalid(t *testing.T) {
+	for _, name := range []string{"../escape", "/abs", "a/../../escape"} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+		tw.Write([]byte("x"))
+		tw.Close()
+
+		m := fstest.MapFS{}
+		if err := wfs.ExtractTar(wfs.Map(m), "out", &buf); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("expected ErrInvalid extracting %q, got %v", name, err)
+		}
+		if len(m) > 1 {
+			t.Errorf("expected nothing extracted for %q, got %v", name, m)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var errPatternHasSeparator = errors.New("pattern contains path separator")
+
+// CreateTemp creates a new temporary file in the directory dir of fsys,
+// opens it for reading and writing, and returns the resulting file.
+// The filename is generated by taking pattern and adding a random string to
+// the end. If pattern includes a "*", the random string replaces the last "*".
+// The file is created with mode 0600 (before umask).
+// If dir is the empty string, CreateTemp uses [os.TempDir] for the OS file
+// system and the root directory otherwise.
+// Multiple programs or goroutines calling CreateTemp simultaneously will not
+// choose the same file. It is the caller's responsibility to remove the file
+// when it is no longer needed.
+func CreateTemp(fsys FS, dir, pattern string) (File, error) {
+	prefix, suffix, err := prefixAndSuffix(pattern)
+	if err != nil {
+		return nil, &fs.PathError{Op: "createtemp", Path: pattern, Err: err}
+	}
+	dir = tempDir(fsys, dir)
+	for range 10000 {
+		f, err := fsys.OpenFile(tempName(dir, prefix, suffix), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if !errors.Is(err, fs.ErrExist) {
+			return f, err
+		}
+	}
+	return nil, &fs.PathError{Op: "createtemp", Path: path.Join(dir, prefix+"*"+suffix), Err: fs.ErrExist}
+}
+
+// MkdirTemp creates a new temporary directory in the directory dir of fsys
+// and returns the pathname of the new directory.
+// The new directory's name is generated by adding a random string to the end
+// of pattern. If pattern includes a "*", the random string replaces the last
+// "*" instead. The directory is created with mode 0700 (before umask).
+// If dir is the empty string, MkdirTemp uses [os.TempDir] for the OS file
+// system and the root directory otherwise.
+// Multiple programs or goroutines calling MkdirTemp simultaneously will not
+// choose the same directory. It is the caller's responsibility to remove the
+// directory when it is no longer needed.
+func MkdirTemp(fsys FS, dir, pattern string) (string, error) {
+	prefix, suffix, err := prefixAndSuffix(pattern)
+	if err != nil {
+		return "", &fs.PathError{Op: "mkdirtemp", Path: pattern, Err: err}
+	}
+	dir = tempDir(fsys, dir)
+	for range 10000 {
+		name := tempName(dir, prefix, suffix)
+		err := fsys.Mkdir(name, 0700)
+		if err == nil {
+			return name, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+	}
+	return "", &fs.PathError{Op: "mkdirtemp", Path: path.Join(dir, prefix+"*"+suffix), Err: fs.ErrExist}
+}
+
+// prefixAndSuffix splits pattern by the last wildcard "*".
+func prefixAndSuffix(pattern string) (prefix, suffix string, err error) {
+	if strings.ContainsAny(pattern, `/\`) {
+		return "", "", errPatternHasSeparator
+	}
+	if i := strings.LastIndexByte(pattern, '*'); i != -1 {
+		return pattern[:i], pattern[i+1:], nil
+	}
+	return pattern, "", nil
+}
+
+// tempDir returns the directory temporary files are created in when dir is empty.
+func tempDir(fsys FS, dir string) string {
+	if dir != "" {
+		return dir
+	}
+	if _, ok := fsys.(osFs); ok {
+		return os.TempDir()
+	}
+	return "."
+}
+
+// tempName returns a random name in dir between prefix and suffix.
+func tempName(dir, prefix, suffix string) string {
+	return path.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix)
+}
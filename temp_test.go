@@ -0,0 +1,99 @@
+package wfs_test
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestCreateTemp(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"tmp": &fstest.MapFile{Mode: fs.ModeDir | 0755},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			dir := filepath.Join(base, "tmp")
+			seen := make(map[string]bool)
+			for range 10 {
+				f, err := wfs.CreateTemp(fsys, dir, "upload-*.bin")
+				if err != nil {
+					t.Fatalf("CreateTemp failed: %v", err)
+				}
+				name := f.Name()
+				if _, err := io.WriteString(f, "data"); err != nil {
+					t.Errorf("failed to write temporary file: %v", err)
+				}
+				f.Close()
+				if path.Dir(filepath.ToSlash(name)) != filepath.ToSlash(dir) {
+					t.Errorf("expected %q to be in %q", name, dir)
+				}
+				base := path.Base(filepath.ToSlash(name))
+				if !strings.HasPrefix(base, "upload-") || !strings.HasSuffix(base, ".bin") || seen[base] {
+					t.Errorf("unexpected temporary file name %q", base)
+				}
+				seen[base] = true
+				if info, err := fs.Stat(fsys, name); err != nil || info.Mode().Perm() != 0600 {
+					t.Errorf("expected temporary file with mode 0600, got %v err: %v", info, err)
+				}
+			}
+
+			if _, err := wfs.CreateTemp(fsys, dir, "bad/pattern"); err == nil {
+				t.Errorf("expected CreateTemp to fail for a pattern with a separator")
+			}
+			if _, err := wfs.CreateTemp(fsys, filepath.Join(base, "missing"), ""); err == nil {
+				t.Errorf("expected CreateTemp to fail in a missing directory")
+			}
+		})
+	}
+}
+
+func TestMkdirTemp(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"tmp": &fstest.MapFile{Mode: fs.ModeDir | 0755},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			dir := filepath.Join(base, "tmp")
+			a, err := wfs.MkdirTemp(fsys, dir, "work")
+			if err != nil {
+				t.Fatalf("MkdirTemp failed: %v", err)
+			}
+			b, err := wfs.MkdirTemp(fsys, dir, "work")
+			if err != nil {
+				t.Fatalf("MkdirTemp failed: %v", err)
+			}
+			if a == b || !strings.HasPrefix(path.Base(filepath.ToSlash(a)), "work") {
+				t.Errorf("expected distinct temporary directories, got %q and %q", a, b)
+			}
+			info, err := fs.Stat(fsys, a)
+			if err != nil || !info.IsDir() || info.Mode().Perm() != 0700 {
+				t.Errorf("expected temporary directory with mode 0700, got %v err: %v", info, err)
+			}
+			if err := wfs.WriteFile(fsys, path.Join(a, "file"), []byte("data"), 0644); err != nil {
+				t.Errorf("failed to write into temporary directory: %v", err)
+			}
+		})
+	}
+
+	fsys := wfs.Mem()
+	dir, err := wfs.MkdirTemp(fsys, "", "*.d")
+	if err != nil || strings.Contains(dir, "/") || !strings.HasSuffix(dir, ".d") {
+		t.Errorf("expected temporary directory in the root, got %q err: %v", dir, err)
+	}
+}
@@ -0,0 +1,294 @@
+package wfs
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+// WithTimeout returns a file system that bounds every operation on fsys and on
+// the files it opens to the duration d. An operation that does not complete in
+// time fails with an error wrapping [os.ErrDeadlineExceeded].
+//
+// Reads and writes of files that support deadlines, such as pipes and network
+// connections, are cancelled using SetDeadline. Other operations cannot be
+// cancelled, they continue in the background after the timeout and their
+// result is discarded, files opened too late are closed. Data written by such
+// operations is copied so the caller may reuse its buffers.
+//
+// After an operation on a file times out, further operations on the file fail
+// with [os.ErrDeadlineExceeded] and Close releases the file once the pending
+// operation completes.
+func WithTimeout(fsys FS, d time.Duration) FS {
+	return &timeoutFs{fsys, d}
+}
+
+type timeoutFs struct {
+	fsys FS
+	d    time.Duration
+}
+
+// timeout calls fn and waits up to d for it to return. If fn does not return
+// in time, timeout returns terr and, once fn returns, passes its result to
+// abandon if it is not nil.
+func timeout[T any](d time.Duration, terr error, fn func() (T, error), abandon func(T, error)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		ch <- result{v, err}
+	}()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		return r.v, r.err
+	case <-timer.C:
+		if abandon != nil {
+			go func() {
+				r := <-ch
+				abandon(r.v, r.err)
+			}()
+		}
+		var zero T
+		return zero, terr
+	}
+}
+
+// timeoutErr is like timeout for functions that only return an error.
+func timeoutErr(d time.Duration, terr error, fn func() error) error {
+	_, err := timeout(d, terr, func() (struct{}, error) { return struct{}{}, fn() }, nil)
+	return err
+}
+
+func deadlinePathErr(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: os.ErrDeadlineExceeded}
+}
+
+func deadlineLinkErr(op, oldname, newname string) error {
+	return &os.LinkError{Op: op, Old: oldname, New: newname, Err: os.ErrDeadlineExceeded}
+}
+
+func (f *timeoutFs) Open(name string) (fs.File, error) {
+	return f.openFile("open", name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for timeoutFs.
+func (f *timeoutFs) Stat(name string) (fs.FileInfo, error) {
+	return timeout(f.d, deadlinePathErr("stat", name), func() (fs.FileInfo, error) {
+		return fs.Stat(f.fsys, name)
+	}, nil)
+}
+
+func (f *timeoutFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return f.openFile("open", name, flag, perm)
+}
+
+func (f *timeoutFs) openFile(op, name string, flag int, perm fs.FileMode) (File, error) {
+	file, err := timeout(f.d, deadlinePathErr(op, name), func() (File, error) {
+		return f.fsys.OpenFile(name, flag, perm)
+	}, func(file File, err error) {
+		if err == nil {
+			file.Close()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newTimeoutFile(file, f.d), nil
+}
+
+func (f *timeoutFs) Rename(oldpath, newpath string) error {
+	return timeoutErr(f.d, deadlineLinkErr("rename", oldpath, newpath), func() error {
+		return f.fsys.Rename(oldpath, newpath)
+	})
+}
+
+func (f *timeoutFs) Remove(name string) error {
+	return timeoutErr(f.d, deadlinePathErr("remove", name), func() error {
+		return f.fsys.Remove(name)
+	})
+}
+
+func (f *timeoutFs) RemoveAll(path string) error {
+	return timeoutErr(f.d, deadlinePathErr("RemoveAll", path), func() error {
+		return f.fsys.RemoveAll(path)
+	})
+}
+
+func (f *timeoutFs) Mkdir(name string, perm fs.FileMode) error {
+	return timeoutErr(f.d, deadlinePathErr("mkdir", name), func() error {
+		return f.fsys.Mkdir(name, perm)
+	})
+}
+
+func (f *timeoutFs) MkdirAll(path string, perm fs.FileMode) error {
+	return timeoutErr(f.d, deadlinePathErr("mkdir", path), func() error {
+		return f.fsys.MkdirAll(path, perm)
+	})
+}
+
+// Symlink implements [SymlinkFS] for timeoutFs.
+func (f *timeoutFs) Symlink(oldname, newname string) error {
+	return timeoutErr(f.d, deadlineLinkErr("symlink", oldname, newname), func() error {
+		return Symlink(f.fsys, oldname, newname)
+	})
+}
+
+// Readlink implements [SymlinkFS] for timeoutFs.
+func (f *timeoutFs) Readlink(name string) (string, error) {
+	return timeout(f.d, deadlinePathErr("readlink", name), func() (string, error) {
+		return Readlink(f.fsys, name)
+	}, nil)
+}
+
+// Lstat implements [SymlinkFS] for timeoutFs.
+func (f *timeoutFs) Lstat(name string) (fs.FileInfo, error) {
+	return timeout(f.d, deadlinePathErr("lstat", name), func() (fs.FileInfo, error) {
+		return Lstat(f.fsys, name)
+	}, nil)
+}
+
+// Link implements [LinkFS] for timeoutFs.
+func (f *timeoutFs) Link(oldname, newname string) error {
+	return timeoutErr(f.d, deadlineLinkErr("link", oldname, newname), func() error {
+		return Link(f.fsys, oldname, newname)
+	})
+}
+
+// Chmod implements [MetaFS] for timeoutFs.
+func (f *timeoutFs) Chmod(name string, mode fs.FileMode) error {
+	return timeoutErr(f.d, deadlinePathErr("chmod", name), func() error {
+		return Chmod(f.fsys, name, mode)
+	})
+}
+
+// Chown implements [MetaFS] for timeoutFs.
+func (f *timeoutFs) Chown(name string, uid, gid int) error {
+	return timeoutErr(f.d, deadlinePathErr("chown", name), func() error {
+		return Chown(f.fsys, name, uid, gid)
+	})
+}
+
+// Chtimes implements [MetaFS] for timeoutFs.
+func (f *timeoutFs) Chtimes(name string, atime, mtime time.Time) error {
+	return timeoutErr(f.d, deadlinePathErr("chtimes", name), func() error {
+		return Chtimes(f.fsys, name, atime, mtime)
+	})
+}
+
+// deadliner is implemented by files that support I/O deadlines, such as [*os.File].
+type deadliner interface {
+	SetDeadline(t time.Time) error
+}
+
+type timeoutFile struct {
+	File
+	d time.Duration
+
+	// deadline is set if the file supports deadlines.
+	deadline deadliner
+
+	// pending is closed when an operation that timed out returns,
+	// it is nil if no operation timed out.
+	pending chan struct{}
+}
+
+func newTimeoutFile(file File, d time.Duration) *timeoutFile {
+	f := &timeoutFile{File: file, d: d}
+	if dl, ok := file.(deadliner); ok && dl.SetDeadline(time.Time{}) == nil {
+		f.deadline = dl
+	}
+	return f
+}
+
+// fileTimeout calls fn on f like timeout, marking the file as pending if fn
+// does not return in time.
+func fileTimeout[T any](f *timeoutFile, op string, fn func() (T, error)) (T, error) {
+	var zero T
+	if f.pending != nil {
+		return zero, deadlinePathErr(op, f.Name())
+	}
+	pending := make(chan struct{})
+	terr := deadlinePathErr(op, f.Name())
+	v, err := timeout(f.d, terr, fn, func(T, error) { close(pending) })
+	if err == terr {
+		f.pending = pending
+	}
+	return v, err
+}
+
+func (f *timeoutFile) Read(b []byte) (int, error) {
+	if f.deadline != nil {
+		f.deadline.SetDeadline(time.Now().Add(f.d))
+		return f.File.Read(b)
+	}
+	// the read may complete after the timeout, so it reads into its own buffer
+	buf := make([]byte, len(b))
+	n, err := fileTimeout(f, "read", func() (int, error) { return f.File.Read(buf) })
+	copy(b, buf[:n])
+	return n, err
+}
+
+func (f *timeoutFile) ReadAt(b []byte, off int64) (int, error) {
+	buf := make([]byte, len(b))
+	n, err := fileTimeout(f, "read", func() (int, error) { return f.File.ReadAt(buf, off) })
+	copy(b, buf[:n])
+	return n, err
+}
+
+func (f *timeoutFile) Write(b []byte) (int, error) {
+	if f.deadline != nil {
+		f.deadline.SetDeadline(time.Now().Add(f.d))
+		return f.File.Write(b)
+	}
+	// the write may complete after the timeout, so it writes a copy of b
+	buf := append([]byte(nil), b...)
+	return fileTimeout(f, "write", func() (int, error) { return f.File.Write(buf) })
+}
+
+func (f *timeoutFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *timeoutFile) WriteAt(b []byte, off int64) (int, error) {
+	buf := append([]byte(nil), b...)
+	return fileTimeout(f, "write", func() (int, error) { return f.File.WriteAt(buf, off) })
+}
+
+func (f *timeoutFile) Seek(offset int64, whence int) (int64, error) {
+	return fileTimeout(f, "seek", func() (int64, error) { return f.File.Seek(offset, whence) })
+}
+
+func (f *timeoutFile) Truncate(size int64) error {
+	_, err := fileTimeout(f, "truncate", func() (struct{}, error) { return struct{}{}, f.File.Truncate(size) })
+	return err
+}
+
+func (f *timeoutFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return fileTimeout(f, "readdir", func() ([]fs.DirEntry, error) { return readDir(f.File, n) })
+}
+
+func (f *timeoutFile) Stat() (fs.FileInfo, error) {
+	return fileTimeout(f, "stat", f.File.Stat)
+}
+
+func (f *timeoutFile) Sync() error {
+	_, err := fileTimeout(f, "sync", func() (struct{}, error) { return struct{}{}, f.File.Sync() })
+	return err
+}
+
+func (f *timeoutFile) Close() error {
+	if f.pending != nil {
+		go func() {
+			<-f.pending
+			f.File.Close()
+		}()
+		return nil
+	}
+	_, err := fileTimeout(f, "close", func() (struct{}, error) { return struct{}{}, f.File.Close() })
+	return err
+}
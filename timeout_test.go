@@ -0,0 +1,122 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+// slowFS blocks operations on the file named "slow" until release is closed.
+type slowFS struct {
+	wfs.FS
+	release chan struct{}
+}
+
+func (f slowFS) OpenFile(name string, flag int, perm fs.FileMode) (wfs.File, error) {
+	file, err := f.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if name == "slow" {
+		return slowFile{file, f.release}, nil
+	}
+	return file, nil
+}
+
+func (f slowFS) Mkdir(name string, perm fs.FileMode) error {
+	if name == "slow" {
+		<-f.release
+	}
+	return f.FS.Mkdir(name, perm)
+}
+
+type slowFile struct {
+	wfs.File
+	release chan struct{}
+}
+
+func (f slowFile) Read(b []byte) (int, error) {
+	<-f.release
+	return f.File.Read(b)
+}
+
+func TestWithTimeout(t *testing.T) {
+	mem := wfs.Mem()
+	wfs.WriteFile(mem, "slow", []byte("Hello"), 0644)
+	wfs.WriteFile(mem, "fast", []byte("Hello"), 0644)
+	release := make(chan struct{})
+	defer close(release)
+	fsys := wfs.WithTimeout(slowFS{mem, release}, 20*time.Millisecond)
+
+	if b, err := fs.ReadFile(fsys, "fast"); err != nil || string(b) != "Hello" {
+		t.Errorf("expected 'Hello', got %q err: %v", b, err)
+	}
+	if err := fsys.Mkdir("dir", 0755); err != nil {
+		t.Errorf("Mkdir failed: %v", err)
+	}
+
+	err := fsys.Mkdir("slow", 0755)
+	var pathErr *fs.PathError
+	if !errors.Is(err, os.ErrDeadlineExceeded) || !errors.As(err, &pathErr) || pathErr.Op != "mkdir" {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	f, err := fsys.Open("slow")
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	b := make([]byte, 5)
+	if _, err := f.Read(b); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	// the file is unusable once an operation timed out
+	if _, err := f.Stat(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestWithTimeoutDeadline(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("pipes do not support deadlines on windows")
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	fsys := wfs.WithTimeout(pipeFS{wfs.Mem(), r}, 20*time.Millisecond)
+	f, err := fsys.OpenFile("pipe", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open pipe: %v", err)
+	}
+	if _, err := f.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	// reads are cancelled so the file remains usable
+	w.Write([]byte("x"))
+	if n, err := f.Read(make([]byte, 1)); err != nil || n != 1 {
+		t.Errorf("expected read to succeed, got %d err: %v", n, err)
+	}
+}
+
+// pipeFS opens the read end of a pipe for the name "pipe".
+type pipeFS struct {
+	wfs.FS
+	r *os.File
+}
+
+func (f pipeFS) OpenFile(name string, flag int, perm fs.FileMode) (wfs.File, error) {
+	if name == "pipe" {
+		return f.r, nil
+	}
+	return f.FS.OpenFile(name, flag, perm)
+}
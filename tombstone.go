@@ -0,0 +1,164 @@
+package wfs
+
+import (
+	"cmp"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tombstone records the deletion of a file or directory.
+type Tombstone struct {
+	Path    string
+	Deleted time.Time
+}
+
+// TombstoneFS is a file system that remembers deleted paths, so that sync
+// tools can distinguish a path that was deleted from one that never existed.
+type TombstoneFS interface {
+	FS
+
+	// Tombstones returns the recorded tombstones sorted by path.
+	Tombstones() []Tombstone
+
+	// Purge forgets tombstones recorded before t.
+	Purge(t time.Time)
+}
+
+// WithTombstones returns a TombstoneFS that records a tombstone for every
+// path removed or renamed away through it. Removing a directory with
+// RemoveAll records a tombstone for each of its descendants.
+// A tombstone is cleared when its path is created again.
+//
+// Tombstones are kept in memory and are only recorded for changes made
+// through the returned file system.
+func WithTombstones(fsys FS) TombstoneFS {
+	return &tombstoneFs{fsys: fsys, tombstones: make(map[string]time.Time)}
+}
+
+type tombstoneFs struct {
+	fsys       FS
+	mu         sync.Mutex
+	tombstones map[string]time.Time
+}
+
+func (f *tombstoneFs) Tombstones() []Tombstone {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tombstones := make([]Tombstone, 0, len(f.tombstones))
+	for name, t := range f.tombstones {
+		tombstones = append(tombstones, Tombstone{Path: name, Deleted: t})
+	}
+	slices.SortFunc(tombstones, func(a, b Tombstone) int {
+		return cmp.Compare(a.Path, b.Path)
+	})
+	return tombstones
+}
+
+func (f *tombstoneFs) Purge(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for name, deleted := range f.tombstones {
+		if deleted.Before(t) {
+			delete(f.tombstones, name)
+		}
+	}
+}
+
+// bury records tombstones for names.
+func (f *tombstoneFs) bury(names ...string) {
+	now := time.Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, name := range names {
+		f.tombstones[name] = now
+	}
+}
+
+// revive clears the tombstones of name and, if prefix is true, its descendants.
+func (f *tombstoneFs) revive(name string, prefix bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.tombstones, name)
+	if prefix {
+		for p := range f.tombstones {
+			if strings.HasPrefix(p, name+"/") {
+				delete(f.tombstones, p)
+			}
+		}
+	}
+}
+
+// tree returns name and all of its descendants.
+func (f *tombstoneFs) tree(name string) []string {
+	var names []string
+	fs.WalkDir(f.fsys, name, func(p string, d fs.DirEntry, err error) error {
+		if err == nil {
+			names = append(names, p)
+		}
+		return nil
+	})
+	return names
+}
+
+func (f *tombstoneFs) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+func (f *tombstoneFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+func (f *tombstoneFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	file, err := f.fsys.OpenFile(name, flag, perm)
+	if err == nil && flag&os.O_CREATE != 0 {
+		f.revive(name, false)
+	}
+	return file, err
+}
+
+func (f *tombstoneFs) Rename(oldpath, newpath string) error {
+	names := f.tree(oldpath)
+	if err := f.fsys.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	f.revive(newpath, true)
+	f.bury(names...)
+	return nil
+}
+
+func (f *tombstoneFs) Remove(name string) error {
+	if err := f.fsys.Remove(name); err != nil {
+		return err
+	}
+	f.bury(name)
+	return nil
+}
+
+func (f *tombstoneFs) RemoveAll(path string) error {
+	names := f.tree(path)
+	if err := f.fsys.RemoveAll(path); err != nil {
+		return err
+	}
+	f.bury(names...)
+	return nil
+}
+
+func (f *tombstoneFs) Mkdir(name string, perm fs.FileMode) error {
+	err := f.fsys.Mkdir(name, perm)
+	if err == nil {
+		f.revive(name, false)
+	}
+	return err
+}
+
+func (f *tombstoneFs) MkdirAll(path string, perm fs.FileMode) error {
+	err := f.fsys.MkdirAll(path, perm)
+	if err == nil {
+		f.revive(path, false)
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package wfs_test
+
+import (
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestWithTombstones(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"file":     &fstest.MapFile{},
+				"dir/file": &fstest.MapFile{},
+				"old":      &fstest.MapFile{},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			tfs := wfs.WithTombstones(fsys)
+			file := filepath.Join(base, "file")
+			dir := filepath.Join(base, "dir")
+			old := filepath.Join(base, "old")
+			if err := tfs.Remove(file); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+			if err := tfs.RemoveAll(dir); err != nil {
+				t.Fatalf("RemoveAll failed: %v", err)
+			}
+			if err := tfs.Rename(old, filepath.Join(base, "new")); err != nil {
+				t.Fatalf("Rename failed: %v", err)
+			}
+
+			expected := []string{dir, filepath.Join(dir, "file"), file, old}
+			tombstones := tfs.Tombstones()
+			if len(tombstones) != len(expected) {
+				t.Fatalf("expected %d tombstones, got %+v", len(expected), tombstones)
+			}
+			for i, name := range expected {
+				if tombstones[i].Path != name {
+					t.Errorf("expected tombstone %q, got %q", name, tombstones[i].Path)
+				}
+			}
+
+			// recreating a path clears its tombstone
+			if err := wfs.WriteFile(tfs, file, nil, 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			if len(tfs.Tombstones()) != len(expected)-1 {
+				t.Errorf("expected recreated file tombstone to be cleared")
+			}
+
+			tfs.Purge(time.Now().Add(time.Second))
+			if len(tfs.Tombstones()) != 0 {
+				t.Errorf("expected all tombstones to be purged")
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package wfs
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trashInfoSuffix and trashDateFormat follow the FreeDesktop.org trash
+// specification, so that trash directories can be inspected with common tools.
+const (
+	trashInfoSuffix = ".trashinfo"
+	trashDateFormat = "2006-01-02T15:04:05"
+)
+
+// TrashEntry is a file or directory moved to the trash.
+type TrashEntry struct {
+	// ID is the name of the entry in the trash.
+	ID string
+
+	// Path is the name the entry was removed from.
+	Path string
+
+	// Deleted is when the entry was moved to the trash,
+	// with a precision of one second.
+	Deleted time.Time
+}
+
+// TrashFS is a file system whose removed files are moved to a trash directory.
+type TrashFS interface {
+	FS
+
+	// Trash returns the entries in the trash ordered from the oldest.
+	Trash() ([]TrashEntry, error)
+
+	// Restore moves the most recently removed entry of the named path back
+	// from the trash, creating its parent directories if necessary. It fails
+	// with an error wrapping [fs.ErrExist] if the path exists.
+	Restore(name string) error
+
+	// EmptyTrash permanently removes the entries moved to the trash before t.
+	EmptyTrash(t time.Time) error
+}
+
+// WithTrash returns a TrashFS that moves files and directories removed with
+// Remove or RemoveAll to trashDir instead of deleting them. Removed entries
+// are stored in trashDir/files with their original path and deletion time
+// recorded in trashDir/info as described by the FreeDesktop.org trash
+// specification. Entries within trashDir are removed permanently.
+//
+// trashDir is not hidden: it is listed in its parent directory and can be
+// opened like any other directory of fsys, so a dot name such as ".trash"
+// is usually preferred.
+//
+// trashDir must be on the same file system as the removed files, as entries
+// are moved to the trash with Rename.
+func WithTrash(fsys FS, trashDir string) TrashFS {
+	return &trashFs{fsys: fsys, dir: trashDir}
+}
+
+type trashFs struct {
+	fsys FS
+	dir  string
+}
+
+// inTrash reports whether name is the trash directory or is inside it.
+func (f *trashFs) inTrash(name string) bool {
+	return name == f.dir || strings.HasPrefix(name, f.dir+"/")
+}
+
+// trash moves the named file to the trash.
+func (f *trashFs) trash(op, name string) error {
+	infoDir, filesDir := path.Join(f.dir, "info"), path.Join(f.dir, "files")
+	if err := f.fsys.MkdirAll(infoDir, 0700); err != nil {
+		return err
+	}
+	if err := f.fsys.MkdirAll(filesDir, 0700); err != nil {
+		return err
+	}
+	info := "[Trash Info]\nPath=" + (&url.URL{Path: name}).EscapedPath() +
+		"\nDeletionDate=" + time.Now().Format(trashDateFormat) + "\n"
+	// reserve a unique id by creating its info file exclusively
+	base := path.Base(name)
+	for i := 1; ; i++ {
+		id := base
+		if i > 1 {
+			id += "." + strconv.Itoa(i)
+		}
+		infoName := path.Join(infoDir, id+trashInfoSuffix)
+		file, err := f.fsys.OpenFile(infoName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(file, info)
+		if err1 := file.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+		if err == nil {
+			err = f.fsys.Rename(name, path.Join(filesDir, id))
+		}
+		if err != nil {
+			f.fsys.Remove(infoName)
+			if le, ok := err.(*os.LinkError); ok {
+				return &fs.PathError{Op: op, Path: name, Err: le.Err}
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+// parseTrashInfo parses the contents of an info file.
+func parseTrashInfo(id string, data []byte) (TrashEntry, bool) {
+	entry := TrashEntry{ID: id}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			p, err := url.PathUnescape(value)
+			if err != nil {
+				return entry, false
+			}
+			entry.Path = p
+		case "DeletionDate":
+			t, err := time.ParseInLocation(trashDateFormat, value, time.Local)
+			if err != nil {
+				return entry, false
+			}
+			entry.Deleted = t
+		}
+	}
+	return entry, entry.Path != "" && !entry.Deleted.IsZero()
+}
+
+func (f *trashFs) Trash() ([]TrashEntry, error) {
+	infoDir := path.Join(f.dir, "info")
+	infos, err := fs.ReadDir(f.fsys, infoDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []TrashEntry
+	for _, info := range infos {
+		id, ok := strings.CutSuffix(info.Name(), trashInfoSuffix)
+		if !ok || info.IsDir() {
+			continue
+		}
+		data, err := fs.ReadFile(f.fsys, path.Join(infoDir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		// entries with invalid info are left alone
+		if entry, ok := parseTrashInfo(id, data); ok {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b TrashEntry) int {
+		if c := a.Deleted.Compare(b.Deleted); c != 0 || a.Path != b.Path {
+			return c
+		}
+		// removals of a path within a second are ordered by their id
+		return cmp.Compare(trashIndex(a), trashIndex(b))
+	})
+	return entries, nil
+}
+
+// trashIndex returns the number distinguishing the id of e from the ids of
+// other entries of the same name.
+func trashIndex(e TrashEntry) int {
+	suffix, ok := strings.CutPrefix(e.ID, path.Base(e.Path)+".")
+	if !ok {
+		return 1
+	}
+	i, err := strconv.Atoi(suffix)
+	if err != nil {
+		return 1
+	}
+	return i
+}
+
+func (f *trashFs) Restore(name string) error {
+	entries, err := f.Trash()
+	if err != nil {
+		return err
+	}
+	i := len(entries) - 1
+	for i >= 0 && entries[i].Path != name {
+		i--
+	}
+	if i < 0 {
+		return &fs.PathError{Op: "restore", Path: name, Err: fs.ErrNotExist}
+	}
+	entry := entries[i]
+	if _, err := Lstat(f.fsys, name); err == nil {
+		return &fs.PathError{Op: "restore", Path: name, Err: fs.ErrExist}
+	}
+	if err := mkdirParent(f.fsys, name); err != nil {
+		return err
+	}
+	if err := f.fsys.Rename(path.Join(f.dir, "files", entry.ID), name); err != nil {
+		return err
+	}
+	return f.fsys.Remove(path.Join(f.dir, "info", entry.ID+trashInfoSuffix))
+}
+
+func (f *trashFs) EmptyTrash(t time.Time) error {
+	entries, err := f.Trash()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		if !entry.Deleted.Before(t) {
+			continue
+		}
+		if err := f.fsys.RemoveAll(path.Join(f.dir, "files", entry.ID)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, f.fsys.Remove(path.Join(f.dir, "info", entry.ID+trashInfoSuffix)))
+	}
+	return errors.Join(errs...)
+}
+
+func (f *trashFs) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+// Stat implements [fs.StatFS] for trashFs.
+func (f *trashFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+func (f *trashFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return f.fsys.OpenFile(name, flag, perm)
+}
+
+func (f *trashFs) Rename(oldpath, newpath string) error {
+	return f.fsys.Rename(oldpath, newpath)
+}
+
+func (f *trashFs) Remove(name string) error {
+	if name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if f.inTrash(name) {
+		return f.fsys.Remove(name)
+	}
+	info, err := Lstat(f.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := fs.ReadDir(f.fsys, name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: ErrNotEmpty}
+		}
+	}
+	return f.trash("remove", name)
+}
+
+func (f *trashFs) RemoveAll(path string) error {
+	if path == "." {
+		return &fs.PathError{Op: "RemoveAll", Path: path, Err: fs.ErrInvalid}
+	}
+	if f.inTrash(path) {
+		return f.fsys.RemoveAll(path)
+	}
+	if _, err := Lstat(f.fsys, path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return f.trash("RemoveAll", path)
+}
+
+func (f *trashFs) Mkdir(name string, perm fs.FileMode) error {
+	return f.fsys.Mkdir(name, perm)
+}
+
+func (f *trashFs) MkdirAll(path string, perm fs.FileMode) error {
+	return f.fsys.MkdirAll(path, perm)
+}
+
+// Symlink implements [SymlinkFS] for trashFs.
+func (f *trashFs) Symlink(oldname, newname string) error {
+	return Symlink(f.fsys, oldname, newname)
+}
+
+// Readlink implements [SymlinkFS] for trashFs.
+func (f *trashFs) Readlink(name string) (string, error) {
+	return Readlink(f.fsys, name)
+}
+
+// Lstat implements [SymlinkFS] for trashFs.
+func (f *trashFs) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(f.fsys, name)
+}
+
+// Link implements [LinkFS] for trashFs.
+func (f *trashFs) Link(oldname, newname string) error {
+	return Link(f.fsys, oldname, newname)
+}
+
+// Chmod implements [MetaFS] for trashFs.
+func (f *trashFs) Chmod(name string, mode fs.FileMode) error {
+	return Chmod(f.fsys, name, mode)
+}
+
+// Chown implements [MetaFS] for trashFs.
+func (f *trashFs) Chown(name string, uid, gid int) error {
+	return Chown(f.fsys, name, uid, gid)
+}
+
+// Chtimes implements [MetaFS] for trashFs.
+func (f *trashFs) Chtimes(name string, atime, mtime time.Time) error {
+	return Chtimes(f.fsys, name, atime, mtime)
+}
@@ -0,0 +1,94 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestTrash(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"docs/report": &fstest.MapFile{Data: []byte("v1")},
+				"docs/sub/a":  &fstest.MapFile{Data: []byte("a")},
+				"dir":         &fstest.MapFile{Mode: fs.ModeDir | 0755},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			trashDir := filepath.Join(base, ".trash")
+			tfs := wfs.WithTrash(fsys, trashDir)
+			report := filepath.Join(base, "docs/report")
+
+			if base == "" {
+				if err := tfs.Remove("."); !errors.Is(err, fs.ErrInvalid) {
+					t.Errorf("Remove of the root: expected ErrInvalid, got %v", err)
+				}
+				if err := tfs.RemoveAll("."); !errors.Is(err, fs.ErrInvalid) {
+					t.Errorf("RemoveAll of the root: expected ErrInvalid, got %v", err)
+				}
+			}
+			if err := tfs.Remove(report); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+			if err := wfs.WriteFile(tfs, report, []byte("v2"), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			if err := tfs.Remove(report); err != nil {
+				t.Fatalf("Remove failed: %v", err)
+			}
+			if err := tfs.Remove(filepath.Join(base, "docs")); !errors.Is(err, wfs.ErrNotEmpty) {
+				t.Errorf("expected ErrNotEmpty, got %v", err)
+			}
+			if err := tfs.RemoveAll(filepath.Join(base, "docs")); err != nil {
+				t.Fatalf("RemoveAll failed: %v", err)
+			}
+			if err := tfs.RemoveAll(filepath.Join(base, "missing")); err != nil {
+				t.Errorf("expected RemoveAll of a missing path to succeed, got %v", err)
+			}
+			// the trash directory is listed like any other directory
+			assertEntries(t, tfs, filepath.Join(base, "."), ".trash", "dir")
+			assertEntries(t, fsys, filepath.Join(trashDir, "files"), "docs", "report", "report.2")
+
+			entries, err := tfs.Trash()
+			if err != nil || len(entries) != 3 {
+				t.Fatalf("expected 3 trash entries, got %d err: %v", len(entries), err)
+			}
+
+			// the most recent removal is restored first
+			if err := tfs.Restore(report); err != nil {
+				t.Fatalf("Restore failed: %v", err)
+			}
+			assertContent(t, fsys, report, "v2")
+			if err := tfs.Restore(report); !errors.Is(err, fs.ErrExist) {
+				t.Errorf("expected ErrExist restoring over a file, got %v", err)
+			}
+			if err := tfs.Restore(filepath.Join(base, "docs")); !errors.Is(err, fs.ErrExist) {
+				t.Errorf("expected ErrExist restoring over a directory, got %v", err)
+			}
+			fsys.RemoveAll(filepath.Join(base, "docs"))
+			if err := tfs.Restore(filepath.Join(base, "docs")); err != nil {
+				t.Fatalf("Restore failed: %v", err)
+			}
+			assertContent(t, fsys, filepath.Join(base, "docs/sub/a"), "a")
+
+			if err := tfs.EmptyTrash(time.Now().Add(time.Second)); err != nil {
+				t.Fatalf("EmptyTrash failed: %v", err)
+			}
+			if entries, err := tfs.Trash(); err != nil || len(entries) != 0 {
+				t.Errorf("expected empty trash, got %v err: %v", entries, err)
+			}
+			if err := tfs.Restore(report); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected ErrNotExist restoring an emptied entry, got %v", err)
+			}
+			assertEntries(t, fsys, filepath.Join(trashDir, "files"))
+		})
+	}
+}
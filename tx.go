@@ -0,0 +1,354 @@
+package wfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrTxDone is returned by operations on a [Tx] that has already been
+// committed or rolled back.
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// Tx is a batch of mutations that are applied together on [Tx.Commit].
+//
+// Contents written through a Tx are buffered in memory until Commit, so
+// nothing is visible in the file system before the transaction commits.
+// Files opened for writing start with the contents the file has after the
+// earlier operations of the transaction, while files opened for reading are
+// opened in the file system and do not observe uncommitted changes.
+// Files opened for writing must be closed before Commit.
+type Tx struct {
+	fsys  FS
+	stage FS // buffered contents, named by the index of their op
+
+	mu   sync.Mutex
+	ops  []txOp
+	done bool
+}
+
+// txOp is a buffered mutation of a Tx.
+type txOp struct {
+	op      string // "write", "rename", "remove" or "mkdir"
+	name    string
+	oldname string
+	perm    fs.FileMode
+	staged  string
+}
+
+// Begin starts a transaction on fsys. It fails if the root directory of fsys
+// cannot be accessed.
+func Begin(fsys FS) (*Tx, error) {
+	info, err := fs.Stat(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "begin", Path: ".", Err: ErrNotDir}
+	}
+	return &Tx{fsys: fsys, stage: Mem()}, nil
+}
+
+// add records op, failing if the transaction is done.
+func (tx *Tx) add(op txOp) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.ops = append(tx.ops, op)
+	return nil
+}
+
+// OpenFile opens the named file like [FS.OpenFile]. Files opened for writing
+// are buffered, starting with the current contents of the file unless it is
+// truncated, and replace the file atomically on commit. Files opened for
+// reading are opened in the file system.
+func (tx *Tx) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		return tx.fsys.OpenFile(name, flag, perm)
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	staged := strconv.Itoa(len(tx.ops))
+	src, srcName, isDir, err := tx.resolve(name, len(tx.ops))
+	var info fs.FileInfo
+	if err == nil && !isDir {
+		info, err = fs.Stat(src, srcName)
+		isDir = err == nil && info.IsDir()
+	}
+	switch {
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case err == nil && isDir:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrIsDir}
+	case err == nil:
+		perm = info.Mode().Perm()
+		if flag&os.O_TRUNC == 0 {
+			if err := copyFile(tx.stage, staged, src, srcName, perm); err != nil {
+				return nil, err
+			}
+		}
+	case !errors.Is(err, fs.ErrNotExist) || flag&os.O_CREATE == 0:
+		return nil, err
+	}
+	f, err := tx.stage.OpenFile(staged, flag&^os.O_EXCL|os.O_CREATE, perm)
+	if err != nil {
+		return nil, err
+	}
+	tx.ops = append(tx.ops, txOp{op: "write", name: name, perm: perm, staged: staged})
+	return &txFile{f, name}, nil
+}
+
+// resolve returns the file system and name holding the file name is after
+// the first i ops, or an error wrapping [fs.ErrNotExist] if they remove it.
+// isDir reports a directory created by the ops, which has no contents.
+func (tx *Tx) resolve(name string, i int) (src fs.FS, srcName string, isDir bool, err error) {
+	notExist := &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	for i--; i >= 0; i-- {
+		op := tx.ops[i]
+		switch {
+		case op.op == "write" && op.name == name:
+			return tx.stage, op.staged, false, nil
+		case op.op == "mkdir" && op.name == name:
+			return nil, "", true, nil
+		case op.op == "remove" && op.name == name:
+			return nil, "", false, notExist
+		case op.op == "rename" && (op.name == name || strings.HasPrefix(name, op.name+"/")):
+			// continue with the name the file had before it was renamed
+			name = op.oldname + strings.TrimPrefix(name, op.name)
+		case op.op == "rename" && (op.oldname == name || strings.HasPrefix(name, op.oldname+"/")):
+			return nil, "", false, notExist
+		}
+	}
+	return tx.fsys, name, false, nil
+}
+
+// Create creates or truncates the named file in the transaction.
+func (tx *Tx) Create(name string) (File, error) {
+	return tx.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// WriteFile writes data to the named file in the transaction, creating it
+// with permissions perm (before umask) if necessary.
+func (tx *Tx) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := tx.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// Rename renames oldpath to newpath on commit, replacing newpath if it exists.
+func (tx *Tx) Rename(oldpath, newpath string) error {
+	return tx.add(txOp{op: "rename", name: newpath, oldname: oldpath})
+}
+
+// Remove removes the named file or empty directory on commit.
+func (tx *Tx) Remove(name string) error {
+	return tx.add(txOp{op: "remove", name: name})
+}
+
+// Mkdir creates the named directory with permissions perm on commit.
+func (tx *Tx) Mkdir(name string, perm fs.FileMode) error {
+	return tx.add(txOp{op: "mkdir", name: name, perm: perm})
+}
+
+// Rollback discards the transaction.
+func (tx *Tx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	tx.ops = nil
+	return nil
+}
+
+// Commit applies the mutations of the transaction in order.
+//
+// Buffered files are written to temporary files next to their targets and
+// renamed over them, and files that are replaced or removed are first renamed
+// aside, so that if a mutation fails every mutation already applied is
+// undone and the error is returned. The renamed aside files are removed once
+// all mutations have been applied. Concurrent changes to the file system are
+// not isolated from the transaction and may be observed partially applied.
+func (tx *Tx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	c := txCommit{fsys: tx.fsys, stage: tx.stage}
+	for _, op := range tx.ops {
+		if err := c.apply(op); err != nil {
+			return errors.Join(append([]error{err}, c.rollback()...)...)
+		}
+	}
+	for _, name := range c.aside {
+		tx.fsys.RemoveAll(name)
+	}
+	return nil
+}
+
+// txCommit applies the ops of a Tx, recording how to undo them.
+type txCommit struct {
+	fsys  FS
+	stage FS
+	undo  []func() error
+	aside []string
+}
+
+func (c *txCommit) apply(op txOp) error {
+	switch op.op {
+	case "mkdir":
+		if err := c.fsys.Mkdir(op.name, op.perm); err != nil {
+			return err
+		}
+		c.undo = append(c.undo, func() error { return c.fsys.Remove(op.name) })
+	case "write":
+		if info, err := Lstat(c.fsys, op.name); err == nil && info.IsDir() {
+			return &fs.PathError{Op: "write", Path: op.name, Err: ErrIsDir}
+		}
+		tmp, err := c.writeTemp(op)
+		if err != nil {
+			return err
+		}
+		if err := c.setAside(op.name); err != nil {
+			c.fsys.Remove(tmp)
+			return err
+		}
+		if err := c.fsys.Rename(tmp, op.name); err != nil {
+			c.fsys.Remove(tmp)
+			return err
+		}
+		c.undo = append(c.undo, func() error { return c.fsys.Remove(op.name) })
+	case "remove":
+		info, err := Lstat(c.fsys, op.name)
+		if err != nil {
+			return &fs.PathError{Op: "remove", Path: op.name, Err: fs.ErrNotExist}
+		}
+		if info.IsDir() {
+			entries, err := fs.ReadDir(c.fsys, op.name)
+			if err != nil {
+				return err
+			}
+			if len(entries) > 0 {
+				return &fs.PathError{Op: "remove", Path: op.name, Err: ErrNotEmpty}
+			}
+		}
+		return c.setAside(op.name)
+	case "rename":
+		if _, err := Lstat(c.fsys, op.oldname); err != nil {
+			return &os.LinkError{Op: "rename", Old: op.oldname, New: op.name, Err: fs.ErrNotExist}
+		}
+		if op.oldname == op.name {
+			return nil
+		}
+		// like os.Rename, a directory is not replaced
+		if info, err := Lstat(c.fsys, op.name); err == nil && info.IsDir() {
+			return &os.LinkError{Op: "rename", Old: op.oldname, New: op.name, Err: fs.ErrExist}
+		}
+		if err := c.setAside(op.name); err != nil {
+			return err
+		}
+		if err := c.fsys.Rename(op.oldname, op.name); err != nil {
+			return err
+		}
+		c.undo = append(c.undo, func() error { return c.fsys.Rename(op.name, op.oldname) })
+	}
+	return nil
+}
+
+// writeTemp writes the staged contents of op to a temporary file next to its
+// target and returns the name of the temporary file.
+func (c *txCommit) writeTemp(op txOp) (tmp string, err error) {
+	r, err := c.stage.Open(op.staged)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	dir, base := path.Split(op.name)
+	var f File
+	for range 10000 {
+		tmp = tempName(dir, "."+base+".tmp", "")
+		f, err = c.fsys.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, op.perm)
+		if !errors.Is(err, fs.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		c.fsys.Remove(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+// setAside renames the named file, if it exists, to a hidden name in the
+// same directory so that it can be restored on rollback. Callers check that
+// a file they replace is not a directory.
+func (c *txCommit) setAside(name string) error {
+	if _, err := Lstat(c.fsys, name); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	dir, base := path.Split(name)
+	for range 10000 {
+		aside := tempName(dir, "."+base+".old", "")
+		if _, err := Lstat(c.fsys, aside); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := c.fsys.Rename(name, aside); err != nil {
+			return err
+		}
+		c.aside = append(c.aside, aside)
+		c.undo = append(c.undo, func() error { return c.fsys.Rename(aside, name) })
+		return nil
+	}
+	return &fs.PathError{Op: "rename", Path: name, Err: fs.ErrExist}
+}
+
+// rollback undoes the applied mutations in reverse order.
+func (c *txCommit) rollback() []error {
+	var errs []error
+	for i := len(c.undo) - 1; i >= 0; i-- {
+		if err := c.undo[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// txFile is a buffered file of a Tx reporting the name of its target.
+type txFile struct {
+	File
+	name string
+}
+
+func (f *txFile) Name() string {
+	return f.name
+}
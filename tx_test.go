@@ -0,0 +1,185 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestTx(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"app/config":  &fstest.MapFile{Data: []byte("old config")},
+				"app/version": &fstest.MapFile{Data: []byte("1")},
+				"app/legacy":  &fstest.MapFile{Data: []byte("legacy")},
+				"app/old":     &fstest.MapFile{Data: []byte("old")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			app := filepath.Join(base, "app")
+
+			tx, err := wfs.Begin(fsys)
+			if err != nil {
+				t.Fatalf("Begin failed: %v", err)
+			}
+			tx.Mkdir(filepath.Join(app, "plugins"), 0755)
+			if err := tx.WriteFile(filepath.Join(app, "plugins/a"), []byte("plugin"), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			if err := tx.WriteFile(filepath.Join(app, "config"), []byte("new config"), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			f, err := tx.OpenFile(filepath.Join(app, "version"), os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatalf("OpenFile failed: %v", err)
+			}
+			f.Write([]byte(".1"))
+			f.Close()
+			tx.Rename(filepath.Join(app, "legacy"), filepath.Join(app, "archived"))
+			tx.Remove(filepath.Join(app, "missing"))
+
+			// nothing is applied before commit, and a failed commit is rolled back
+			assertEntries(t, fsys, app, "config", "legacy", "old", "version")
+			if err := tx.Commit(); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected commit to fail with ErrNotExist, got %v", err)
+			}
+			assertEntries(t, fsys, app, "config", "legacy", "old", "version")
+			assertContent(t, fsys, filepath.Join(app, "config"), "old config")
+			assertContent(t, fsys, filepath.Join(app, "version"), "1")
+			if err := tx.Commit(); !errors.Is(err, wfs.ErrTxDone) {
+				t.Errorf("expected ErrTxDone, got %v", err)
+			}
+
+			tx, err = wfs.Begin(fsys)
+			if err != nil {
+				t.Fatalf("Begin failed: %v", err)
+			}
+			tx.Mkdir(filepath.Join(app, "plugins"), 0755)
+			tx.WriteFile(filepath.Join(app, "plugins/a"), []byte("plugin"), 0644)
+			tx.WriteFile(filepath.Join(app, "config"), []byte("new config"), 0644)
+			tx.Rename(filepath.Join(app, "legacy"), filepath.Join(app, "archived"))
+			f, err = tx.OpenFile(filepath.Join(app, "version"), os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatalf("OpenFile failed: %v", err)
+			}
+			f.Write([]byte(".1"))
+			f.Close()
+			tx.Remove(filepath.Join(app, "old"))
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+			assertEntries(t, fsys, app, "archived", "config", "plugins", "version")
+			assertContent(t, fsys, filepath.Join(app, "version"), "1.1")
+			assertContent(t, fsys, filepath.Join(app, "config"), "new config")
+			assertContent(t, fsys, filepath.Join(app, "plugins/a"), "plugin")
+			assertContent(t, fsys, filepath.Join(app, "archived"), "legacy")
+		})
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	fsys := wfs.Mem()
+	tx, err := wfs.Begin(fsys)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := tx.WriteFile("file", []byte("Hello"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if err := tx.Commit(); !errors.Is(err, wfs.ErrTxDone) {
+		t.Errorf("expected ErrTxDone, got %v", err)
+	}
+	if _, err := tx.Create("other"); !errors.Is(err, wfs.ErrTxDone) {
+		t.Errorf("expected ErrTxDone, got %v", err)
+	}
+	assertEntries(t, fsys, ".")
+}
+
+func TestTxStaged(t *testing.T) {
+	fsys := wfs.Mem()
+	if err := wfs.WriteFile(fsys, "log", []byte("a"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	tx, err := wfs.Begin(fsys)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	// writes to the same file in a transaction build on each other
+	for _, data := range []string{"b", "c"} {
+		f, err := tx.OpenFile("log", os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			t.Fatalf("OpenFile failed: %v", err)
+		}
+		f.Write([]byte(data))
+		f.Close()
+	}
+	tx.Rename("log", "moved")
+	f, err := tx.OpenFile("moved", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile of a renamed file failed: %v", err)
+	}
+	f.Write([]byte("d"))
+	f.Close()
+	if _, err := tx.OpenFile("log", os.O_WRONLY, 0); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist opening the old name of a renamed file, got %v", err)
+	}
+	tx.Mkdir("dir", 0755)
+	if _, err := tx.OpenFile("dir", os.O_WRONLY, 0); !errors.Is(err, wfs.ErrIsDir) {
+		t.Errorf("expected ErrIsDir opening a created directory, got %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	assertEntries(t, fsys, ".", "dir", "moved")
+	assertContent(t, fsys, "moved", "abcd")
+}
+
+func TestTxReplaceDir(t *testing.T) {
+	fsys := wfs.Mem()
+	if err := fsys.MkdirAll("dir/sub", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "file", []byte("file"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	tx, err := wfs.Begin(fsys)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	tx.Rename("file", "dir")
+	if err := tx.Commit(); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected renaming onto a directory to fail with ErrExist, got %v", err)
+	}
+
+	tx, err = wfs.Begin(fsys)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := tx.WriteFile("new", []byte("new"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	tx.Mkdir("new2", 0755)
+	tx.Rename("new2", "new3")
+	if err := tx.WriteFile("other", nil, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	tx.Rename("other", "new3")
+	if err := tx.Commit(); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected renaming onto a created directory to fail with ErrExist, got %v", err)
+	}
+	assertEntries(t, fsys, ".", "dir", "file")
+	assertEntries(t, fsys, "dir", "sub")
+	assertContent(t, fsys, "file", "file")
+}
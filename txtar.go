@@ -0,0 +1,118 @@
+package wfs
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"strings"
+	"testing/fstest"
+	"time"
+)
+
+// FromTxtar returns an in-memory file system holding the files of a txtar
+// archive, as used by golden-file and script tests.
+//
+// Each file starts with a line "-- name --" and holds the lines up to the next
+// such line, text before the first file is a comment and is ignored. A name
+// ending in a slash is an empty directory. Files are created with mode 0644
+// and directories with mode 0755.
+func FromTxtar(data []byte) FS {
+	now := time.Now()
+	tree := make(fstest.MapFS)
+	_, name, data := nextTxtarFile(data)
+	for name != "" {
+		var content []byte
+		file := name
+		content, name, data = nextTxtarFile(data)
+		dir, isDir := strings.CutSuffix(file, "/")
+		if isDir {
+			tree[dir] = &fstest.MapFile{Mode: fs.ModeDir | 0755, ModTime: now}
+		} else {
+			tree[file] = &fstest.MapFile{Data: content, Mode: 0644, ModTime: now}
+			dir = path.Dir(file)
+		}
+		// parent directories exist explicitly so that they are writable
+		for ; dir != "." && tree[dir] == nil; dir = path.Dir(dir) {
+			tree[dir] = &fstest.MapFile{Mode: fs.ModeDir | 0755, ModTime: now}
+		}
+	}
+	return Map(tree)
+}
+
+// ToTxtar returns the tree rooted at root in fsys as a txtar archive, with
+// files named relative to root in lexical order, and a root naming a single
+// file is archived under its base name. Regular files and empty directories
+// are archived, other files are skipped. Like txtar, a newline is appended to
+// a file that does not end with one, and a file with a line that looks like a
+// file marker does not round-trip through [FromTxtar].
+func ToTxtar(fsys fs.FS, root string) ([]byte, error) {
+	var b bytes.Buffer
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == root && d.IsDir() {
+			return nil
+		}
+		rel := relPath(root, name)
+		switch {
+		case d.IsDir():
+			entries, err := fs.ReadDir(fsys, name)
+			if err != nil || len(entries) > 0 {
+				return err
+			}
+			b.WriteString("-- " + rel + "/ --\n")
+			return nil
+		case !d.Type().IsRegular():
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		b.WriteString("-- " + rel + " --\n")
+		b.Write(data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			b.WriteByte('\n')
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// nextTxtarFile returns the content before the next file marker in data,
+// the name of the marker and the data after it. The name is empty if data
+// has no more markers.
+func nextTxtarFile(data []byte) (before []byte, name string, after []byte) {
+	var i int
+	for {
+		if name, after, ok := txtarMarker(data[i:]); ok {
+			return data[:i], name, after
+		}
+		j := bytes.IndexByte(data[i:], '\n')
+		if j < 0 {
+			// like txtar, the content of the last file ends with a newline
+			if len(data) > 0 && data[len(data)-1] != '\n' {
+				data = append(data[:len(data):len(data)], '\n')
+			}
+			return data, "", nil
+		}
+		i += j + 1
+	}
+}
+
+// txtarMarker reports whether data starts with a file marker line and
+// returns its name and the data after the line.
+func txtarMarker(data []byte) (name string, after []byte, ok bool) {
+	line, after, _ := bytes.Cut(data, []byte("\n"))
+	name, ok = strings.CutPrefix(strings.TrimSuffix(string(line), "\r"), "-- ")
+	if !ok {
+		return "", nil, false
+	}
+	name, ok = strings.CutSuffix(name, " --")
+	name = strings.TrimSpace(name)
+	return name, after, ok && name != ""
+}
@@ -0,0 +1,69 @@
+package wfs_test
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestFromTxtar(t *testing.T) {
+	fsys := wfs.FromTxtar([]byte(`comment is ignored
+-- a.txt --
+hello
+-- dir/sub/b.txt --
+-- empty/ --
+-- c.txt --
+no newline`))
+	assertEntries(t, fsys, ".", "a.txt", "c.txt", "dir", "empty")
+	assertContent(t, fsys, "a.txt", "hello\n")
+	assertContent(t, fsys, "dir/sub/b.txt", "")
+	assertContent(t, fsys, "c.txt", "no newline\n")
+	assertEntries(t, fsys, "empty")
+	if info, err := fs.Stat(fsys, "dir/sub"); err != nil || info.Mode() != fs.ModeDir|0755 {
+		t.Errorf("expected directory with mode 0755, got %v err: %v", info.Mode(), err)
+	}
+	// the file system is writable
+	if err := wfs.WriteFile(fsys, "dir/new.txt", []byte("new"), 0644); err != nil {
+		t.Errorf("expected write to succeed, got %v", err)
+	}
+}
+
+func TestToTxtar(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"src/a.txt":     &fstest.MapFile{Data: []byte("a")},
+				"src/dir/b.txt": &fstest.MapFile{Data: []byte("b\n")},
+				"src/empty":     &fstest.MapFile{Mode: fs.ModeDir | 0755},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+
+			data, err := wfs.ToTxtar(fsys, filepath.Join(base, "src"))
+			if err != nil {
+				t.Fatalf("ToTxtar failed: %v", err)
+			}
+			expected := "-- a.txt --\na\n-- dir/b.txt --\nb\n-- empty/ --\n"
+			if string(data) != expected {
+				t.Errorf("expected archive %q, got %q", expected, data)
+			}
+
+			// the archive round-trips through FromTxtar
+			again, err := wfs.ToTxtar(wfs.FromTxtar(data), ".")
+			if err != nil || string(again) != expected {
+				t.Errorf("expected archive %q after round trip, got %q err: %v", expected, again, err)
+			}
+
+			// a single file is archived under its base name
+			data, err = wfs.ToTxtar(fsys, filepath.Join(base, "src/dir/b.txt"))
+			if err != nil || string(data) != "-- b.txt --\nb\n" {
+				t.Errorf("expected b.txt entry, got %q err: %v", data, err)
+			}
+		})
+	}
+}
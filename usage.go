@@ -0,0 +1,50 @@
+package wfs
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// UsageFS is the interface implemented by a file system that can report
+// the disk usage of a tree without walking it, such as an object store
+// that lists objects with their sizes.
+type UsageFS interface {
+	fs.FS
+
+	// Usage returns the number of regular files in the tree rooted at root
+	// and their total size in bytes. If the usage cannot be computed natively,
+	// it returns an error that wraps [errors.ErrUnsupported].
+	Usage(root string) (files int, bytes int64, err error)
+}
+
+// DiskUsage returns the number of regular files in the tree rooted at root
+// and their total size in bytes. Directories and symbolic links are not
+// counted and symbolic links are not followed.
+//
+// If fsys implements [UsageFS] its usage is returned, otherwise the tree
+// is walked.
+func DiskUsage(fsys fs.FS, root string) (files int, bytes int64, err error) {
+	if fsys, ok := fsys.(UsageFS); ok {
+		files, bytes, err := fsys.Usage(root)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return files, bytes, err
+		}
+	}
+
+	err = fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files++
+		bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return files, bytes, nil
+}
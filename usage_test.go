@@ -0,0 +1,67 @@
+package wfs_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+type usageFS struct {
+	fstest.MapFS
+}
+
+func (f usageFS) Usage(root string) (int, int64, error) {
+	if root == "native" {
+		return 42, 1 << 20, nil
+	}
+	return 0, 0, errors.ErrUnsupported
+}
+
+func TestDiskUsage(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"data/a":       &fstest.MapFile{Data: []byte("Hello")},
+				"data/sub/b":   &fstest.MapFile{Data: []byte(", World")},
+				"data/sub/c/d": &fstest.MapFile{Data: []byte("!")},
+				"other":        &fstest.MapFile{Data: []byte("not counted")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			if err := wfs.Symlink(fsys, "../other", filepath.Join(base, "data/link")); err != nil {
+				t.Fatalf("failed to create symlink: %v", err)
+			}
+
+			files, bytes, err := wfs.DiskUsage(fsys, filepath.Join(base, "data"))
+			if err != nil || files != 3 || bytes != 13 {
+				t.Errorf("expected 3 files and 13 bytes, got %d files and %d bytes err: %v", files, bytes, err)
+			}
+			files, bytes, err = wfs.DiskUsage(fsys, filepath.Join(base, "data/a"))
+			if err != nil || files != 1 || bytes != 5 {
+				t.Errorf("expected 1 file and 5 bytes, got %d files and %d bytes err: %v", files, bytes, err)
+			}
+			if _, _, err := wfs.DiskUsage(fsys, filepath.Join(base, "missing")); err == nil {
+				t.Errorf("expected DiskUsage to fail for a missing root")
+			}
+		})
+	}
+}
+
+func TestDiskUsageNative(t *testing.T) {
+	fsys := usageFS{fstest.MapFS{
+		"walked/file": &fstest.MapFile{Data: []byte("Hello")},
+	}}
+	files, bytes, err := wfs.DiskUsage(fsys, "native")
+	if err != nil || files != 42 || bytes != 1<<20 {
+		t.Errorf("expected native usage, got %d files and %d bytes err: %v", files, bytes, err)
+	}
+	files, bytes, err = wfs.DiskUsage(fsys, "walked")
+	if err != nil || files != 1 || bytes != 5 {
+		t.Errorf("expected walked usage, got %d files and %d bytes err: %v", files, bytes, err)
+	}
+}
@@ -0,0 +1,300 @@
+package wfs
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// versionsSuffix is appended to the name of a file to name the directory of
+// its revisions in the store, so that revisions never collide with the
+// revisions of the files of a directory of the same name.
+const versionsSuffix = ".versions"
+
+// Revision is a preserved version of a file.
+type Revision struct {
+	// Number identifies the revision, revisions of a file are numbered
+	// from 1 in the order they were preserved.
+	Number int
+
+	// Time is when the version was preserved, that is when it was
+	// overwritten or removed.
+	Time time.Time
+
+	// Size is the size of the version.
+	Size int64
+}
+
+// VersionedFS is a file system that keeps the previous versions of its files.
+type VersionedFS interface {
+	FS
+
+	// Revisions returns the preserved versions of the named file ordered from
+	// the oldest, or an error wrapping [fs.ErrNotExist] if it has none.
+	Revisions(name string) ([]Revision, error)
+
+	// OpenRevision opens a preserved version of the named file for reading.
+	OpenRevision(name string, number int) (fs.File, error)
+
+	// Prune removes the versions preserved before t.
+	Prune(t time.Time) error
+}
+
+// Versioned returns a VersionedFS that preserves a copy of a file in store
+// before the file is overwritten, truncated, removed or replaced by a rename.
+// A file opened for writing is preserved on its first write, or when it is
+// opened if it is truncated. Revisions of the file "a/b" are stored in the
+// directory "a/b.versions" of store.
+//
+// History is kept per path: renaming a file does not move its history and
+// only preserves the file that is replaced. Only regular files are versioned.
+func Versioned(fsys FS, store FS) VersionedFS {
+	return &versionedFs{fsys: fsys, store: store}
+}
+
+type versionedFs struct {
+	fsys  FS
+	store FS
+	mu    sync.Mutex // serializes the numbering of revisions
+}
+
+// versionsDir returns the directory of the revisions of name in the store.
+func versionsDir(name string) string {
+	return strings.TrimPrefix(path.Clean(name), "/") + versionsSuffix
+}
+
+// preserve copies the named file to a new revision if it is a regular file.
+func (f *versionedFs) preserve(name string) error {
+	info, err := Lstat(f.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && !info.Mode().IsRegular() {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	dir := versionsDir(name)
+	if err := f.store.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	revisions, err := f.revisions(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	number := 1
+	if len(revisions) > 0 {
+		number = revisions[len(revisions)-1].Number + 1
+	}
+	return copyFile(f.store, path.Join(dir, strconv.Itoa(number)), f.fsys, name, info.Mode().Perm())
+}
+
+// preserveTree preserves every regular file in the tree rooted at name.
+func (f *versionedFs) preserveTree(name string) error {
+	var errs []error
+	fs.WalkDir(f.fsys, name, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			errs = append(errs, f.preserve(p))
+		}
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+func (f *versionedFs) revisions(name string) ([]Revision, error) {
+	entries, err := fs.ReadDir(f.store, versionsDir(name))
+	if err != nil {
+		return nil, err
+	}
+	var revisions []Revision
+	for _, e := range entries {
+		number, err := strconv.Atoi(e.Name())
+		if err != nil || number < 1 {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, Revision{Number: number, Time: info.ModTime(), Size: info.Size()})
+	}
+	slices.SortFunc(revisions, func(a, b Revision) int { return cmp.Compare(a.Number, b.Number) })
+	return revisions, nil
+}
+
+func (f *versionedFs) Revisions(name string) ([]Revision, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	revisions, err := f.revisions(name)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && len(revisions) == 0 {
+		return nil, &fs.PathError{Op: "revisions", Path: name, Err: fs.ErrNotExist}
+	}
+	return revisions, err
+}
+
+func (f *versionedFs) OpenRevision(name string, number int) (fs.File, error) {
+	file, err := f.store.Open(path.Join(versionsDir(name), strconv.Itoa(number)))
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name + "@" + strconv.Itoa(number), Err: fs.ErrNotExist}
+	}
+	return file, nil
+}
+
+func (f *versionedFs) Prune(t time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	err := fs.WalkDir(f.store, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path.Dir(p), versionsSuffix) {
+			return err
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().Before(t) {
+			errs = append(errs, f.store.Remove(p))
+		}
+		return nil
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+func (f *versionedFs) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+// Stat implements [fs.StatFS] for versionedFs.
+func (f *versionedFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+func (f *versionedFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		return f.fsys.OpenFile(name, flag, perm)
+	}
+	preserved := false
+	if _, err := Lstat(f.fsys, name); errors.Is(err, fs.ErrNotExist) {
+		// a created file has no previous version
+		preserved = true
+	} else if flag&os.O_TRUNC != 0 {
+		// the contents are gone once the file is opened
+		if err := f.preserve(name); err != nil {
+			return nil, err
+		}
+		preserved = true
+	}
+	file, err := f.fsys.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &versionedFile{File: file, fs: f, name: name, preserved: preserved}, nil
+}
+
+func (f *versionedFs) Rename(oldpath, newpath string) error {
+	if err := f.preserve(newpath); err != nil {
+		return err
+	}
+	return f.fsys.Rename(oldpath, newpath)
+}
+
+func (f *versionedFs) Remove(name string) error {
+	if err := f.preserve(name); err != nil {
+		return err
+	}
+	return f.fsys.Remove(name)
+}
+
+func (f *versionedFs) RemoveAll(path string) error {
+	if err := f.preserveTree(path); err != nil {
+		return err
+	}
+	return f.fsys.RemoveAll(path)
+}
+
+func (f *versionedFs) Mkdir(name string, perm fs.FileMode) error {
+	return f.fsys.Mkdir(name, perm)
+}
+
+func (f *versionedFs) MkdirAll(path string, perm fs.FileMode) error {
+	return f.fsys.MkdirAll(path, perm)
+}
+
+// Symlink implements [SymlinkFS] for versionedFs.
+func (f *versionedFs) Symlink(oldname, newname string) error {
+	return Symlink(f.fsys, oldname, newname)
+}
+
+// Readlink implements [SymlinkFS] for versionedFs.
+func (f *versionedFs) Readlink(name string) (string, error) {
+	return Readlink(f.fsys, name)
+}
+
+// Lstat implements [SymlinkFS] for versionedFs.
+func (f *versionedFs) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(f.fsys, name)
+}
+
+// Link implements [LinkFS] for versionedFs.
+func (f *versionedFs) Link(oldname, newname string) error {
+	return Link(f.fsys, oldname, newname)
+}
+
+// Chmod implements [MetaFS] for versionedFs.
+func (f *versionedFs) Chmod(name string, mode fs.FileMode) error {
+	return Chmod(f.fsys, name, mode)
+}
+
+// Chown implements [MetaFS] for versionedFs.
+func (f *versionedFs) Chown(name string, uid, gid int) error {
+	return Chown(f.fsys, name, uid, gid)
+}
+
+// Chtimes implements [MetaFS] for versionedFs.
+func (f *versionedFs) Chtimes(name string, atime, mtime time.Time) error {
+	return Chtimes(f.fsys, name, atime, mtime)
+}
+
+// versionedFile preserves the file before its first modification.
+type versionedFile struct {
+	File
+	fs        *versionedFs
+	name      string
+	preserved bool
+}
+
+func (f *versionedFile) preserve() error {
+	if f.preserved {
+		return nil
+	}
+	f.preserved = true
+	return f.fs.preserve(f.name)
+}
+
+func (f *versionedFile) Write(b []byte) (int, error) {
+	if err := f.preserve(); err != nil {
+		return 0, err
+	}
+	return f.File.Write(b)
+}
+
+func (f *versionedFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *versionedFile) WriteAt(b []byte, off int64) (int, error) {
+	if err := f.preserve(); err != nil {
+		return 0, err
+	}
+	return f.File.WriteAt(b, off)
+}
+
+func (f *versionedFile) Truncate(size int64) error {
+	if err := f.preserve(); err != nil {
+		return err
+	}
+	return f.File.Truncate(size)
+}
@@ -0,0 +1,92 @@
+package wfs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestVersioned(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"doc":       &fstest.MapFile{Data: []byte("v1")},
+				"dir/a":     &fstest.MapFile{Data: []byte("a")},
+				"dir/sub/b": &fstest.MapFile{Data: []byte("b")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			vfs := wfs.Versioned(fsys, wfs.Mem())
+			doc := filepath.Join(base, "doc")
+
+			if err := wfs.WriteFile(vfs, doc, []byte("v2"), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			if err := wfs.AppendFile(vfs, doc, []byte("+"), 0644); err != nil {
+				t.Fatalf("failed to append file: %v", err)
+			}
+			if err := wfs.AtomicWriteFile(vfs, doc, []byte("v3"), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			if err := vfs.Remove(doc); err != nil {
+				t.Fatalf("failed to remove file: %v", err)
+			}
+			if err := wfs.WriteFile(vfs, filepath.Join(base, "new"), []byte("new"), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			revisions, err := vfs.Revisions(doc)
+			if err != nil {
+				t.Fatalf("Revisions failed: %v", err)
+			}
+			expected := []string{"v1", "v2", "v2+", "v3"}
+			if len(revisions) != len(expected) {
+				t.Fatalf("expected %d revisions, got %d", len(expected), len(revisions))
+			}
+			for i, rev := range revisions {
+				if rev.Number != i+1 || rev.Size != int64(len(expected[i])) {
+					t.Errorf("unexpected revision %+v", rev)
+				}
+				f, err := vfs.OpenRevision(doc, rev.Number)
+				if err != nil {
+					t.Fatalf("OpenRevision failed: %v", err)
+				}
+				b, _ := io.ReadAll(f)
+				f.Close()
+				if string(b) != expected[i] {
+					t.Errorf("expected revision %d to contain %q, got %q", rev.Number, expected[i], b)
+				}
+			}
+			if _, err := vfs.Revisions(filepath.Join(base, "new")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected a created file to have no revisions, got %v", err)
+			}
+			if _, err := vfs.OpenRevision(doc, 9); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected ErrNotExist for a missing revision, got %v", err)
+			}
+
+			if err := vfs.RemoveAll(filepath.Join(base, "dir")); err != nil {
+				t.Fatalf("failed to remove directory: %v", err)
+			}
+			for _, name := range []string{"dir/a", "dir/sub/b"} {
+				if revisions, err := vfs.Revisions(filepath.Join(base, name)); err != nil || len(revisions) != 1 {
+					t.Errorf("expected 1 revision of %s, got %d err: %v", name, len(revisions), err)
+				}
+			}
+
+			if err := vfs.Prune(time.Now().Add(time.Second)); err != nil {
+				t.Fatalf("Prune failed: %v", err)
+			}
+			if _, err := vfs.Revisions(doc); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected revisions to be pruned, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package wfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// walRecord is an intended mutation logged by [WriteAhead].
+type walRecord struct {
+	Op      string `json:"op"` // "write", "rename" or "removeall"
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+	Data    string `json:"data,omitempty"` // buffered contents of a write in the log directory
+}
+
+// WriteAhead returns an FS that logs renames, recursive removals and writes to
+// files in logDir before applying them, so that operations interrupted by a
+// crash can be completed. Operations left in logDir by a previous crash are
+// replayed, and incomplete writes rolled back, before WriteAhead returns.
+//
+// Files opened for writing are buffered in logDir, starting with the current
+// contents of the file unless it is truncated. When the file is closed, the
+// write is logged and the buffered file is renamed over the target, so the
+// file is either fully written or left untouched. Concurrent writers of the
+// same file do not observe each other's changes and the last to close wins.
+//
+// logDir must be on the same file system as the files it logs, and must not
+// be modified by other callers.
+func WriteAhead(fsys FS, logDir string) (FS, error) {
+	if err := fsys.MkdirAll(logDir, 0700); err != nil {
+		return nil, err
+	}
+	f := &walFs{fsys: fsys, dir: logDir}
+	if err := f.replay(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type walFs struct {
+	fsys FS
+	dir  string
+	seq  atomic.Uint64
+}
+
+// next returns a new name for the files of an operation in the log directory.
+func (f *walFs) next() string {
+	return path.Join(f.dir, fmt.Sprintf("%016x", f.seq.Add(1)))
+}
+
+// log writes r to the log before its operation is applied, and returns the
+// name of the record.
+func (f *walFs) log(r walRecord) (string, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	name := f.next() + ".wal"
+	if err := AtomicWriteFile(f.fsys, name, data, 0600); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// commit removes the record of an applied operation once the changes to dir
+// are durable.
+func (f *walFs) commit(record, dir string) error {
+	SyncFS(f.fsys, dir)
+	return f.fsys.Remove(record)
+}
+
+// apply applies a logged operation, it is safe to apply an operation again
+// after it has been applied.
+func (f *walFs) apply(r walRecord) error {
+	switch r.Op {
+	case "write":
+		err := f.fsys.Rename(path.Join(f.dir, r.Data), r.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			if _, serr := Lstat(f.fsys, path.Join(f.dir, r.Data)); errors.Is(serr, fs.ErrNotExist) {
+				// the buffered file was already renamed
+				return nil
+			}
+		}
+		return err
+	case "rename":
+		if _, err := Lstat(f.fsys, r.OldPath); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return f.fsys.Rename(r.OldPath, r.Path)
+	case "removeall":
+		return f.fsys.RemoveAll(r.Path)
+	}
+	return fmt.Errorf("wfs: unknown write-ahead log op %q", r.Op)
+}
+
+// replay completes the operations logged in the log directory and removes
+// the buffered files of writes that were not logged.
+func (f *walFs) replay() error {
+	entries, err := fs.ReadDir(f.fsys, f.dir)
+	if err != nil {
+		return err
+	}
+	logged := make(map[string]bool)
+	var records []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".wal") {
+			records = append(records, e.Name())
+		}
+	}
+	slices.Sort(records)
+	for _, name := range records {
+		data, err := fs.ReadFile(f.fsys, path.Join(f.dir, name))
+		if err != nil {
+			return err
+		}
+		var r walRecord
+		if err := json.Unmarshal(data, &r); err != nil {
+			return fmt.Errorf("wfs: invalid write-ahead log record %s: %w", name, err)
+		}
+		if err := f.apply(r); err != nil {
+			return err
+		}
+		if err := f.commit(path.Join(f.dir, name), path.Dir(r.Path)); err != nil {
+			return err
+		}
+		logged[r.Data] = true
+	}
+	var errs []error
+	for _, e := range entries {
+		if name := e.Name(); !strings.HasSuffix(name, ".wal") && !logged[name] {
+			// an incomplete write, or the temporary file of a record
+			errs = append(errs, f.fsys.RemoveAll(path.Join(f.dir, name)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f *walFs) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+// Stat implements [fs.StatFS] for walFs.
+func (f *walFs) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+func (f *walFs) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		return f.fsys.OpenFile(name, flag, perm)
+	}
+	data := f.next() + ".data"
+	info, err := fs.Stat(f.fsys, name)
+	switch {
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case err == nil && info.IsDir():
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrIsDir}
+	case err == nil:
+		perm = info.Mode().Perm()
+		if flag&os.O_TRUNC == 0 {
+			if err := copyFile(f.fsys, data, f.fsys, name, perm); err != nil {
+				f.fsys.Remove(data)
+				return nil, err
+			}
+		}
+	case !errors.Is(err, fs.ErrNotExist) || flag&os.O_CREATE == 0:
+		return nil, err
+	default:
+		// the parent directory must exist for the write to be applied
+		if info, err := fs.Stat(f.fsys, path.Dir(name)); err != nil || !info.IsDir() {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	file, err := f.fsys.OpenFile(data, flag&^os.O_EXCL|os.O_CREATE, perm)
+	if err != nil {
+		f.fsys.Remove(data)
+		return nil, err
+	}
+	return &walFile{File: file, fs: f, name: name, data: data}, nil
+}
+
+func (f *walFs) Rename(oldpath, newpath string) error {
+	if _, err := Lstat(f.fsys, oldpath); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	record, err := f.log(walRecord{Op: "rename", Path: newpath, OldPath: oldpath})
+	if err != nil {
+		return err
+	}
+	if err := f.fsys.Rename(oldpath, newpath); err != nil {
+		f.fsys.Remove(record)
+		return err
+	}
+	return f.commit(record, path.Dir(newpath))
+}
+
+func (f *walFs) Remove(name string) error {
+	return f.fsys.Remove(name)
+}
+
+func (f *walFs) RemoveAll(name string) error {
+	if _, err := Lstat(f.fsys, name); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	record, err := f.log(walRecord{Op: "removeall", Path: name})
+	if err != nil {
+		return err
+	}
+	if err := f.fsys.RemoveAll(name); err != nil {
+		f.fsys.Remove(record)
+		return err
+	}
+	return f.commit(record, path.Dir(name))
+}
+
+func (f *walFs) Mkdir(name string, perm fs.FileMode) error {
+	return f.fsys.Mkdir(name, perm)
+}
+
+func (f *walFs) MkdirAll(path string, perm fs.FileMode) error {
+	return f.fsys.MkdirAll(path, perm)
+}
+
+// Symlink implements [SymlinkFS] for walFs.
+func (f *walFs) Symlink(oldname, newname string) error {
+	return Symlink(f.fsys, oldname, newname)
+}
+
+// Readlink implements [SymlinkFS] for walFs.
+func (f *walFs) Readlink(name string) (string, error) {
+	return Readlink(f.fsys, name)
+}
+
+// Lstat implements [SymlinkFS] for walFs.
+func (f *walFs) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(f.fsys, name)
+}
+
+// Link implements [LinkFS] for walFs.
+func (f *walFs) Link(oldname, newname string) error {
+	return Link(f.fsys, oldname, newname)
+}
+
+// Chmod implements [MetaFS] for walFs.
+func (f *walFs) Chmod(name string, mode fs.FileMode) error {
+	return Chmod(f.fsys, name, mode)
+}
+
+// Chown implements [MetaFS] for walFs.
+func (f *walFs) Chown(name string, uid, gid int) error {
+	return Chown(f.fsys, name, uid, gid)
+}
+
+// Chtimes implements [MetaFS] for walFs.
+func (f *walFs) Chtimes(name string, atime, mtime time.Time) error {
+	return Chtimes(f.fsys, name, atime, mtime)
+}
+
+// walFile is a file buffered in the log directory that replaces its target
+// when it is closed.
+type walFile struct {
+	File
+	fs     *walFs
+	name   string
+	data   string
+	closed bool
+}
+
+func (f *walFile) Name() string {
+	return f.name
+}
+
+func (f *walFile) Close() error {
+	if f.closed {
+		return f.File.Close()
+	}
+	f.closed = true
+	err := f.File.Sync()
+	if err1 := f.File.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	var record string
+	if err == nil {
+		record, err = f.fs.log(walRecord{Op: "write", Path: f.name, Data: path.Base(f.data)})
+	}
+	if err == nil {
+		if err = f.fs.fsys.Rename(f.data, f.name); err != nil {
+			f.fs.fsys.Remove(record)
+		}
+	}
+	if err != nil {
+		f.fs.fsys.Remove(f.data)
+		return err
+	}
+	return f.fs.commit(record, path.Dir(f.name))
+}
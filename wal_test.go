@@ -0,0 +1,100 @@
+package wfs_test
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestWriteAhead(t *testing.T) {
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"data/log":      &fstest.MapFile{Data: []byte("a")},
+				"data/state":    &fstest.MapFile{Data: []byte("old")},
+				"data/cache/x":  &fstest.MapFile{Data: []byte("x")},
+				"data/pending":  &fstest.MapFile{Data: []byte("pending")},
+				"data/obsolete": &fstest.MapFile{Data: []byte("obsolete")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			logDir := filepath.Join(base, ".wal")
+			data := filepath.Join(base, "data")
+
+			wal, err := wfs.WriteAhead(fsys, logDir)
+			if err != nil {
+				t.Fatalf("WriteAhead failed: %v", err)
+			}
+			if err := wfs.WriteFile(wal, filepath.Join(data, "state"), []byte("new"), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			f, err := wal.OpenFile(filepath.Join(data, "log"), os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatalf("OpenFile failed: %v", err)
+			}
+			f.Write([]byte("b"))
+			// nothing is applied before the file is closed
+			assertContent(t, fsys, filepath.Join(data, "log"), "a")
+			if err := f.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			assertContent(t, fsys, filepath.Join(data, "log"), "ab")
+			assertContent(t, fsys, filepath.Join(data, "state"), "new")
+			if _, err := wal.OpenFile(filepath.Join(data, "missing/file"), os.O_WRONLY|os.O_CREATE, 0644); err == nil {
+				t.Errorf("expected creating a file in a missing directory to fail")
+			}
+
+			if err := wal.Rename(filepath.Join(data, "state"), filepath.Join(data, "state.bak")); err != nil {
+				t.Fatalf("Rename failed: %v", err)
+			}
+			if err := wal.RemoveAll(filepath.Join(data, "cache")); err != nil {
+				t.Fatalf("RemoveAll failed: %v", err)
+			}
+			assertEntries(t, fsys, data, "log", "obsolete", "pending", "state.bak")
+			assertEntries(t, fsys, logDir)
+
+			// simulate a crash with a logged write, a logged removal and an
+			// unlogged write left in the log
+			writeRecord := func(name string, record map[string]string) {
+				b, _ := json.Marshal(record)
+				if err := wfs.WriteFile(fsys, filepath.Join(logDir, name), b, 0600); err != nil {
+					t.Fatalf("failed to write record: %v", err)
+				}
+			}
+			wfs.WriteFile(fsys, filepath.Join(logDir, "0000000000000001.data"), []byte("committed"), 0644)
+			writeRecord("0000000000000002.wal", map[string]string{
+				"op": "write", "path": filepath.Join(data, "pending"), "data": "0000000000000001.data",
+			})
+			writeRecord("0000000000000003.wal", map[string]string{
+				"op": "removeall", "path": filepath.Join(data, "obsolete"),
+			})
+			wfs.WriteFile(fsys, filepath.Join(logDir, "0000000000000004.data"), []byte("partial"), 0644)
+
+			if _, err := wfs.WriteAhead(fsys, logDir); err != nil {
+				t.Fatalf("WriteAhead recovery failed: %v", err)
+			}
+			assertContent(t, fsys, filepath.Join(data, "pending"), "committed")
+			assertEntries(t, fsys, data, "log", "pending", "state.bak")
+			assertEntries(t, fsys, logDir)
+
+			// replaying an applied write succeeds
+			writeRecord("0000000000000001.wal", map[string]string{
+				"op": "write", "path": filepath.Join(data, "pending"), "data": "0000000000000001.data",
+			})
+			if _, err := wfs.WriteAhead(fsys, logDir); err != nil {
+				t.Fatalf("WriteAhead recovery failed: %v", err)
+			}
+			assertContent(t, fsys, filepath.Join(data, "pending"), "committed")
+			if _, err := fs.Stat(fsys, filepath.Join(logDir, "0000000000000001.wal")); err == nil {
+				t.Errorf("expected replayed record to be removed")
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package wfs
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"path"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// WalkDirParallel walks the file tree rooted at root like [fs.WalkDir],
+// calling fn for each file or directory in the tree, including root, but
+// reads up to workers directories concurrently. If workers is less than 1,
+// [runtime.GOMAXPROCS] workers are used. It is intended for large trees on
+// file systems where the latency of reading a directory dominates.
+//
+// fn may be called concurrently from multiple goroutines and must be safe
+// for concurrent use. It is called for a directory before the entries of the
+// directory, but there is no ordering between different directories.
+// [fs.SkipDir] and [fs.SkipAll] behave as for [fs.WalkDir], except that
+// fs.SkipAll may not stop calls already in progress.
+//
+// An error returned by fn for a directory skips the directory and an error
+// returned for a file is recorded, but in both cases the rest of the tree is
+// still walked so that the result does not depend on scheduling. The errors
+// are returned joined with [errors.Join] in lexical order of their paths.
+func WalkDirParallel(fsys fs.FS, root string, workers int, fn fs.WalkDirFunc) error {
+	info, err := fs.Stat(fsys, root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else if !info.IsDir() {
+		err = fn(root, fs.FileInfoToDirEntry(info), nil)
+	} else {
+		if workers < 1 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+		w := &parallelWalker{fsys: fsys, fn: fn}
+		w.cond.L = &w.mu
+		w.push(walkTask{root, fs.FileInfoToDirEntry(info)})
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w.work()
+			}()
+		}
+		wg.Wait()
+		return w.err()
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+type walkTask struct {
+	name string
+	d    fs.DirEntry
+}
+
+type walkError struct {
+	name string
+	err  error
+}
+
+type parallelWalker struct {
+	fsys fs.FS
+	fn   fs.WalkDirFunc
+
+	mu      sync.Mutex
+	cond    sync.Cond
+	queue   []walkTask
+	pending int
+	stopped bool
+	errs    []walkError
+}
+
+// push queues a directory to be walked.
+func (w *parallelWalker) push(t walkTask) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+	w.queue = append(w.queue, t)
+	w.pending++
+	w.cond.Signal()
+}
+
+// work walks queued directories until every queued directory has been walked.
+func (w *parallelWalker) work() {
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && w.pending > 0 {
+			w.cond.Wait()
+		}
+		if w.pending == 0 {
+			w.mu.Unlock()
+			return
+		}
+		t := w.queue[len(w.queue)-1]
+		w.queue = w.queue[:len(w.queue)-1]
+		w.mu.Unlock()
+
+		w.walk(t)
+
+		w.mu.Lock()
+		if w.pending--; w.pending == 0 {
+			w.cond.Broadcast()
+		}
+		w.mu.Unlock()
+	}
+}
+
+// walk calls fn for the directory of t and its files and queues its subdirectories.
+func (w *parallelWalker) walk(t walkTask) {
+	if !w.handle(t.name, w.fn(t.name, t.d, nil)) {
+		return
+	}
+	entries, err := fs.ReadDir(w.fsys, t.name)
+	if err != nil && !w.handle(t.name, w.fn(t.name, t.d, err)) {
+		return
+	}
+	for _, e := range entries {
+		if w.isStopped() {
+			return
+		}
+		name := path.Join(t.name, e.Name())
+		if e.IsDir() {
+			w.push(walkTask{name, e})
+			continue
+		}
+		err := w.fn(name, e, nil)
+		if err == fs.SkipDir {
+			// skip the remaining files in the directory
+			return
+		}
+		w.handle(name, err)
+	}
+}
+
+// handle records the error returned by fn for name
+// and reports whether the walk of name should continue.
+func (w *parallelWalker) handle(name string, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case fs.SkipDir:
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err == fs.SkipAll {
+		w.stopped = true
+		w.pending -= len(w.queue)
+		w.queue = nil
+		return false
+	}
+	w.errs = append(w.errs, walkError{name, err})
+	return false
+}
+
+func (w *parallelWalker) isStopped() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stopped
+}
+
+// err returns the recorded errors in lexical order of their paths.
+func (w *parallelWalker) err() error {
+	slices.SortStableFunc(w.errs, func(a, b walkError) int {
+		return cmp.Compare(a.name, b.name)
+	})
+	errs := make([]error, len(w.errs))
+	for i, e := range w.errs {
+		errs[i] = e.err
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,110 @@
+package wfs_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestWalkDirParallel(t *testing.T) {
+	files := fstest.MapFS{}
+	for i := range 5 {
+		for j := range 5 {
+			files[fmt.Sprintf("d%d/e%d/file", i, j)] = &fstest.MapFile{Data: []byte("data")}
+		}
+		files[fmt.Sprintf("d%d/file", i)] = &fstest.MapFile{}
+	}
+	for _, tt := range fileSystems {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(files)
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			root := filepath.ToSlash(base)
+			if root == "" {
+				root = "."
+			}
+
+			var expected []string
+			fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+				expected = append(expected, name)
+				return err
+			})
+
+			var mu sync.Mutex
+			var visited []string
+			err = wfs.WalkDirParallel(fsys, root, 4, func(name string, d fs.DirEntry, err error) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if d.IsDir() && name != root && !slices.Contains(visited, path.Dir(name)) {
+					t.Errorf("expected %q to be visited after its parent", name)
+				}
+				visited = append(visited, name)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("WalkDirParallel failed: %v", err)
+			}
+			slices.Sort(visited)
+			if !slices.Equal(visited, expected) {
+				t.Errorf("expected %v, got %v", expected, visited)
+			}
+
+			// errors are joined in path order and skip directories
+			visited = nil
+			err = wfs.WalkDirParallel(fsys, root, 4, func(name string, d fs.DirEntry, err error) error {
+				mu.Lock()
+				defer mu.Unlock()
+				visited = append(visited, name)
+				rel := relName(root, name)
+				switch rel {
+				case "d3", "d1/e2/file", "d0/e4":
+					return errors.New(rel)
+				case "d2":
+					return fs.SkipDir
+				}
+				return err
+			})
+			if err == nil || err.Error() != "d0/e4\nd1/e2/file\nd3" {
+				t.Errorf("expected joined errors in path order, got %v", err)
+			}
+			for _, name := range visited {
+				if rel := relName(root, name); path.Dir(rel) == "d3" || path.Dir(rel) == "d2" || path.Dir(rel) == "d0/e4" {
+					t.Errorf("expected %q to be skipped", rel)
+				}
+			}
+
+			// SkipAll stops the walk
+			err = wfs.WalkDirParallel(fsys, root, 4, func(name string, d fs.DirEntry, err error) error {
+				return fs.SkipAll
+			})
+			if err != nil {
+				t.Errorf("expected nil error, got %v", err)
+			}
+		})
+	}
+
+	err := wfs.WalkDirParallel(wfs.Mem(), "missing", 0, func(name string, d fs.DirEntry, err error) error {
+		return err
+	})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+}
+
+func relName(root, name string) string {
+	if root == "." {
+		return name
+	}
+	rel, _ := filepath.Rel(root, name)
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,275 @@
+package wfs
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatchOptions configures [Watch].
+type WatchOptions struct {
+	// Recursive reports changes in the whole tree below a watched directory
+	// instead of only the directory and its direct entries.
+	Recursive bool
+
+	// Interval is how often file systems that do not implement [WatchFS]
+	// are polled for changes. The default is one second.
+	Interval time.Duration
+}
+
+// WatchFS is the interface implemented by a file system that generates
+// change events natively.
+type WatchFS interface {
+	FS
+
+	// Watch starts watching the named file or directory as described by
+	// [Watch]. If changes cannot be watched natively, it returns an error
+	// that wraps [errors.ErrUnsupported].
+	Watch(name string, opts WatchOptions) (<-chan Event, func(), error)
+}
+
+// Watch returns a channel of the changes made to the named file or
+// directory, and a function that stops watching and closes the channel.
+// Watching a directory reports changes of the directory and its entries,
+// or of its whole tree if opts.Recursive is set.
+//
+// Events report creates, writes, renames, removes and [OpChmod] changes of
+// the mode, owner or times of files, with Size set to the change in size.
+// Events are queued without bound until they are received.
+//
+// On Linux, the file systems returned by [OS] and [Dir] watch changes with
+// inotify, falling back to polling if no inotify instance or watch can be
+// created. Files are inspected when their inotify events are read, so a
+// file changed again in the meantime may be reported with the size of its
+// later state.
+//
+// If fsys does not implement [WatchFS], the tree is polled every
+// opts.Interval and changes are found by comparing the mode, size and
+// modification time of files. A polled rename is reported as a remove of
+// the old name and a create of the new name. The poller reads fsys
+// concurrently with other callers, so fsys must be safe for concurrent use.
+func Watch(fsys FS, name string, opts WatchOptions) (<-chan Event, func(), error) {
+	if wfs, ok := fsys.(WatchFS); ok {
+		events, stop, err := wfs.Watch(name, opts)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return events, stop, err
+		}
+	}
+	return pollWatch(fsys, name, opts)
+}
+
+// watcher queues the events of a watched name and delivers them in order.
+type watcher struct {
+	name      string
+	recursive bool
+
+	mu    sync.Mutex
+	queue []Event
+	ready chan struct{}
+
+	done chan struct{}
+	once sync.Once
+}
+
+// newWatcher returns a watcher of name and the channel its events are
+// delivered on until the watcher is stopped.
+func newWatcher(name string, recursive bool) (*watcher, <-chan Event) {
+	w := &watcher{name: name, recursive: recursive, ready: make(chan struct{}, 1), done: make(chan struct{})}
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		for {
+			w.mu.Lock()
+			queue := w.queue
+			w.queue = nil
+			w.mu.Unlock()
+			for _, e := range queue {
+				select {
+				case out <- e:
+				case <-w.done:
+					return
+				}
+			}
+			select {
+			case <-w.ready:
+			case <-w.done:
+				return
+			}
+		}
+	}()
+	return w, out
+}
+
+// contains reports whether name is the watched name or is in its scope.
+func (w *watcher) contains(name string) bool {
+	if name == w.name {
+		return true
+	}
+	rel, ok := name, w.name == "." && !path.IsAbs(name)
+	if !ok {
+		prefix := w.name
+		if !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		rel, ok = strings.CutPrefix(name, prefix)
+	}
+	return ok && (w.recursive || !strings.Contains(rel, "/"))
+}
+
+// matches reports whether e is a change of the watched name, its scope,
+// or one of its parents.
+func (w *watcher) matches(e Event) bool {
+	if w.contains(e.Path) || e.OldPath != "" && w.contains(e.OldPath) {
+		return true
+	}
+	if e.Op == OpRemove || e.Op == OpRename {
+		// the watched name goes away with a parent
+		old := cmp.Or(e.OldPath, e.Path)
+		return strings.HasPrefix(w.name, old+"/")
+	}
+	return false
+}
+
+// send queues e without blocking.
+func (w *watcher) send(e Event) {
+	w.mu.Lock()
+	w.queue = append(w.queue, e)
+	w.mu.Unlock()
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) stop() {
+	w.once.Do(func() { close(w.done) })
+}
+
+// pollState is the state of a polled file that changes are detected from.
+type pollState struct {
+	mode    fs.FileMode
+	size    int64
+	modTime time.Time
+}
+
+// pollSnapshot returns the state of the files in the scope of a watch of name.
+func pollSnapshot(fsys FS, name string, recursive bool) (map[string]pollState, error) {
+	snapshot := make(map[string]pollState)
+	add := func(name string, info fs.FileInfo) {
+		snapshot[name] = pollState{info.Mode(), info.Size(), info.ModTime()}
+	}
+	info, err := Lstat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	add(name, info)
+	if !info.IsDir() {
+		return snapshot, nil
+	}
+	if !recursive {
+		entries, err := fs.ReadDir(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil {
+				add(path.Join(name, e.Name()), info)
+			}
+		}
+		return snapshot, nil
+	}
+	err = fs.WalkDir(fsys, name, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == name {
+				return err
+			}
+			// the entry changed while walking, it is found on the next poll
+			return nil
+		}
+		if p != name {
+			if info, err := d.Info(); err == nil {
+				add(p, info)
+			}
+		}
+		return nil
+	})
+	return snapshot, err
+}
+
+// pollChanges returns the events that change old into current.
+func pollChanges(old, current map[string]pollState) []Event {
+	var events []Event
+	now := time.Now()
+	// remove entries before their parents
+	for _, name := range slices.Backward(slices.Sorted(maps.Keys(old))) {
+		if _, ok := current[name]; !ok {
+			events = append(events, Event{Op: OpRemove, Path: name, Size: -fileSize(old[name]), Time: now})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(current)) {
+		s := current[name]
+		prev, ok := old[name]
+		switch {
+		case !ok:
+			events = append(events, Event{Op: OpCreate, Path: name, Size: fileSize(s), Time: now})
+		case prev.mode.Type() != s.mode.Type():
+			// replaced by a file of another type
+			events = append(events,
+				Event{Op: OpRemove, Path: name, Size: -fileSize(prev), Time: now},
+				Event{Op: OpCreate, Path: name, Size: fileSize(s), Time: now})
+		default:
+			if !s.mode.IsDir() && (prev.size != s.size || !prev.modTime.Equal(s.modTime)) {
+				events = append(events, Event{Op: OpWrite, Path: name, Size: s.size - prev.size, Time: now})
+			}
+			if prev.mode != s.mode {
+				events = append(events, Event{Op: OpChmod, Path: name, Time: now})
+			}
+		}
+	}
+	return events
+}
+
+// fileSize returns the size of a polled regular file, or 0 otherwise.
+func fileSize(s pollState) int64 {
+	if !s.mode.IsRegular() {
+		return 0
+	}
+	return s.size
+}
+
+// pollWatch watches name by polling fsys.
+func pollWatch(fsys FS, name string, opts WatchOptions) (<-chan Event, func(), error) {
+	snapshot, err := pollSnapshot(fsys, name, opts.Recursive)
+	if err != nil {
+		return nil, nil, err
+	}
+	w, events := newWatcher(name, opts.Recursive)
+	go func() {
+		ticker := time.NewTicker(cmp.Or(opts.Interval, time.Second))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-w.done:
+				return
+			}
+			current, err := pollSnapshot(fsys, name, opts.Recursive)
+			if errors.Is(err, fs.ErrNotExist) {
+				current = nil
+			} else if err != nil {
+				continue
+			}
+			for _, e := range pollChanges(snapshot, current) {
+				w.send(e)
+			}
+			snapshot = current
+		}
+	}()
+	return events, w.stop, nil
+}
@@ -0,0 +1,323 @@
+//go:build linux
+
+package wfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Watch implements [WatchFS] for osFs with inotify.
+func (fsys osFs) Watch(name string, opts WatchOptions) (<-chan Event, func(), error) {
+	return inotifyWatch(fsys, name, name, opts)
+}
+
+// Watch implements [WatchFS] for dirFs with inotify.
+func (dir dirFs) Watch(name string, opts WatchOptions) (<-chan Event, func(), error) {
+	full, err := dir.join("watch", name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inotifyWatch(dir, name, full, opts)
+}
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF |
+	syscall.IN_DONT_FOLLOW
+
+// inotify generates the events of a watch of an OS file system from the
+// events of an inotify instance.
+type inotify struct {
+	fsys      FS
+	name      string
+	full      string // OS path of name
+	recursive bool
+
+	file *os.File
+	w    *watcher
+
+	// state and wds are only used by the goroutine reading events once
+	// the watch has started.
+	state map[string]pollState // the files in scope, for the sizes of events
+	wds   map[int32]string     // the names of the watched directories
+
+	// moved is the name of a file moved by the last event, waiting for the
+	// event with the same cookie that moves it to its new name.
+	moved  string
+	cookie uint32
+}
+
+// inotifyWatch watches name of fsys at the OS path full. If inotify cannot
+// be used, it returns an error wrapping [errors.ErrUnsupported] so that the
+// file system is polled instead.
+func inotifyWatch(fsys FS, name, full string, opts WatchOptions) (<-chan Event, func(), error) {
+	state, err := pollSnapshot(fsys, name, opts.Recursive)
+	if err != nil {
+		return nil, nil, err
+	}
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, nil, &fs.PathError{Op: "watch", Path: name, Err: &portableError{err, errors.ErrUnsupported}}
+	}
+	in := &inotify{
+		fsys:      fsys,
+		name:      name,
+		full:      full,
+		recursive: opts.Recursive,
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		state:     state,
+		wds:       make(map[int32]string),
+	}
+	if err := in.add(name); err != nil {
+		in.file.Close()
+		return nil, nil, &fs.PathError{Op: "watch", Path: name, Err: &portableError{err, errors.ErrUnsupported}}
+	}
+	if in.recursive {
+		for p, s := range state {
+			if p != name && s.mode.IsDir() {
+				in.add(p)
+			}
+		}
+	}
+	w, events := newWatcher(name, opts.Recursive)
+	in.w = w
+	go in.run()
+	stop := func() {
+		w.stop()
+		in.file.Close()
+	}
+	return events, stop, nil
+}
+
+// osPath returns the OS path of a name in scope.
+func (in *inotify) osPath(name string) string {
+	if name == in.name {
+		return in.full
+	}
+	rel := strings.TrimPrefix(name, in.name+"/")
+	if in.name == "." {
+		rel = name
+	}
+	return path.Join(in.full, rel)
+}
+
+// add starts watching the named file or directory.
+func (in *inotify) add(name string) error {
+	var wd int
+	var err error
+	cerr := in.control(func(fd int) {
+		wd, err = syscall.InotifyAddWatch(fd, in.osPath(name), inotifyMask)
+	})
+	if cerr != nil {
+		return cerr
+	}
+	if err != nil {
+		return err
+	}
+	in.wds[int32(wd)] = name
+	return nil
+}
+
+// unwatch stops watching the named directory and the directories below it.
+func (in *inotify) unwatch(name string) {
+	for wd, p := range in.wds {
+		if p == name || within(p, name) {
+			delete(in.wds, wd)
+			in.control(func(fd int) {
+				syscall.InotifyRmWatch(fd, uint32(wd))
+			})
+		}
+	}
+}
+
+// control runs fn with the inotify descriptor, which cannot be closed by
+// a concurrent stop while fn runs.
+func (in *inotify) control(fn func(fd int)) error {
+	conn, err := in.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	return conn.Control(func(fd uintptr) { fn(int(fd)) })
+}
+
+func (in *inotify) run() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := in.file.Read(buf)
+		if err != nil {
+			// the watch was stopped
+			return
+		}
+		in.handle(buf[:n])
+	}
+}
+
+// handle generates the events of the inotify events in buf.
+func (in *inotify) handle(buf []byte) {
+	for len(buf) >= syscall.SizeofInotifyEvent {
+		wd := int32(binary.NativeEndian.Uint32(buf[0:]))
+		mask := binary.NativeEndian.Uint32(buf[4:])
+		cookie := binary.NativeEndian.Uint32(buf[8:])
+		size := int(binary.NativeEndian.Uint32(buf[12:]))
+		child := string(bytes.TrimRight(buf[syscall.SizeofInotifyEvent:syscall.SizeofInotifyEvent+size], "\x00"))
+		buf = buf[syscall.SizeofInotifyEvent+size:]
+
+		if in.moved != "" && (mask&syscall.IN_MOVED_TO == 0 || cookie != in.cookie) {
+			// moved out of scope
+			in.removed(in.moved)
+			in.moved = ""
+		}
+		if mask&syscall.IN_Q_OVERFLOW != 0 {
+			in.resync()
+			continue
+		}
+		dir, ok := in.wds[wd]
+		if !ok {
+			continue
+		}
+		if mask&syscall.IN_IGNORED != 0 {
+			delete(in.wds, wd)
+			continue
+		}
+		if child == "" && dir != in.name {
+			// changes of watched directories are reported by their parents
+			continue
+		}
+		name := dir
+		if child != "" {
+			name = path.Join(dir, child)
+		}
+		switch {
+		case mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0:
+			in.removed(name)
+		case mask&syscall.IN_MOVED_FROM != 0:
+			in.moved, in.cookie = name, cookie
+		case mask&syscall.IN_MOVED_TO != 0:
+			if in.moved != "" {
+				in.renamed(in.moved, name)
+				in.moved = ""
+			} else {
+				in.created(name)
+			}
+		case mask&syscall.IN_CREATE != 0:
+			in.created(name)
+		case mask&syscall.IN_MODIFY != 0:
+			in.modified(name)
+		case mask&syscall.IN_ATTRIB != 0:
+			// the link count of a removed file changes before it is removed
+			if _, err := Lstat(in.fsys, name); err == nil {
+				in.send(Event{Op: OpChmod, Path: name})
+			}
+		case mask&syscall.IN_DELETE != 0:
+			in.removed(name)
+		}
+	}
+	if in.moved != "" {
+		in.removed(in.moved)
+		in.moved = ""
+	}
+}
+
+func (in *inotify) send(e Event) {
+	e.Time = time.Now()
+	in.w.send(e)
+}
+
+// created reports the creation of name, and of the entries of a directory
+// created in the scope of a recursive watch, which may be made before the
+// directory is watched.
+func (in *inotify) created(name string) {
+	if _, ok := in.state[name]; ok {
+		// reported when its parent was created
+		return
+	}
+	var s pollState
+	if info, err := Lstat(in.fsys, name); err == nil {
+		s = pollState{info.Mode(), info.Size(), info.ModTime()}
+	}
+	in.state[name] = s
+	in.send(Event{Op: OpCreate, Path: name, Size: fileSize(s)})
+	if !in.recursive || !s.mode.IsDir() {
+		return
+	}
+	if err := in.add(name); err != nil {
+		return
+	}
+	entries, _ := fs.ReadDir(in.fsys, name)
+	for _, e := range entries {
+		in.created(path.Join(name, e.Name()))
+	}
+}
+
+func (in *inotify) modified(name string) {
+	info, err := Lstat(in.fsys, name)
+	if err != nil {
+		// removed since, which is reported by its own event
+		return
+	}
+	prev := in.state[name]
+	s := pollState{info.Mode(), info.Size(), info.ModTime()}
+	in.state[name] = s
+	in.send(Event{Op: OpWrite, Path: name, Size: fileSize(s) - fileSize(prev)})
+}
+
+func (in *inotify) removed(name string) {
+	prev := in.state[name]
+	for p := range in.state {
+		if p == name || within(p, name) {
+			delete(in.state, p)
+		}
+	}
+	in.unwatch(name)
+	in.send(Event{Op: OpRemove, Path: name, Size: -fileSize(prev)})
+}
+
+func (in *inotify) renamed(oldpath, newpath string) {
+	moved := make(map[string]pollState)
+	for p, s := range in.state {
+		if p == oldpath || within(p, oldpath) {
+			delete(in.state, p)
+			moved[newpath+strings.TrimPrefix(p, oldpath)] = s
+		}
+	}
+	maps.Copy(in.state, moved)
+	for wd, p := range in.wds {
+		if p == oldpath || within(p, oldpath) {
+			in.wds[wd] = newpath + strings.TrimPrefix(p, oldpath)
+		}
+	}
+	in.send(Event{Op: OpRename, Path: newpath, OldPath: oldpath})
+}
+
+// resync reports the changes missed when the inotify queue overflowed
+// by comparing the files in scope with their last known state.
+func (in *inotify) resync() {
+	current, err := pollSnapshot(in.fsys, in.name, in.recursive)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	for _, e := range pollChanges(in.state, current) {
+		in.send(e)
+	}
+	in.state = current
+	if in.recursive {
+		for p, s := range current {
+			if p != in.name && s.mode.IsDir() {
+				in.add(p)
+			}
+		}
+	}
+}
+
+// within reports whether name is below dir.
+func within(name, dir string) bool {
+	return dir == "." && name != "." || strings.HasPrefix(name, dir+"/")
+}
@@ -0,0 +1,219 @@
+package wfs_test
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+// waitEvent receives events until one with op and name arrives.
+func waitEvent(t *testing.T, events <-chan wfs.Event, op wfs.Op, name string) wfs.Event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatalf("events closed waiting for %s %s", op, name)
+			}
+			if e.Op == op && e.Path == name {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s %s", op, name)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	for _, tt := range fileSystems {
+		if tt.name == "Map FS" {
+			// the Map file system is not safe for concurrent use by the poller
+			continue
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, base, cleanup, err := tt.fsys(fstest.MapFS{
+				"dir/sub/file": &fstest.MapFile{Data: []byte("Hello")},
+			})
+			if err != nil {
+				t.Fatalf("failed to create file system: %v", err)
+			}
+			defer cleanup()
+			dir := filepath.Join(base, "dir")
+			events, stop, err := wfs.Watch(fsys, dir, wfs.WatchOptions{Recursive: true, Interval: 10 * time.Millisecond})
+			if err != nil {
+				t.Fatalf("failed to watch: %v", err)
+			}
+			defer stop()
+
+			name := filepath.Join(dir, "sub/new")
+			if err := wfs.WriteFile(fsys, name, nil, 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			waitEvent(t, events, wfs.OpCreate, name)
+			if err := wfs.AppendFile(fsys, name, []byte("Hello"), 0644); err != nil {
+				t.Fatalf("failed to append file: %v", err)
+			}
+			if e := waitEvent(t, events, wfs.OpWrite, name); e.Size != 5 {
+				t.Errorf("expected write of 5 bytes, got %d", e.Size)
+			}
+			if err := wfs.Chmod(fsys, name, 0600); err != nil {
+				t.Fatalf("failed to chmod file: %v", err)
+			}
+			waitEvent(t, events, wfs.OpChmod, name)
+			if err := fsys.Remove(name); err != nil {
+				t.Fatalf("failed to remove file: %v", err)
+			}
+			if e := waitEvent(t, events, wfs.OpRemove, name); e.Size != -5 {
+				t.Errorf("expected remove of 5 bytes, got %d", e.Size)
+			}
+
+			stop()
+			for range events {
+			}
+			if _, _, err := wfs.Watch(fsys, filepath.Join(base, "missing"), wfs.WatchOptions{}); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected watching a missing file to fail with ErrNotExist, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWatchMem(t *testing.T) {
+	fsys := wfs.Mem()
+	if err := fsys.MkdirAll("dir/sub", 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	events, stop, err := wfs.Watch(fsys, "dir", wfs.WatchOptions{})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	defer stop()
+
+	// changes below direct entries are not reported without Recursive
+	wfs.WriteFile(fsys, "dir/sub/deep", []byte("Hello"), 0644)
+	wfs.WriteFile(fsys, "other", []byte("Hello"), 0644)
+	wfs.WriteFile(fsys, "dir/file", []byte("Hello"), 0644)
+	fsys.Rename("dir/file", "dir/renamed")
+	fsys.RemoveAll("dir")
+
+	expected := []wfs.Event{
+		{Op: wfs.OpCreate, Path: "dir/file"},
+		{Op: wfs.OpWrite, Path: "dir/file", Size: 5},
+		{Op: wfs.OpRename, Path: "dir/renamed", OldPath: "dir/file"},
+		{Op: wfs.OpRemove, Path: "dir"},
+	}
+	for _, want := range expected {
+		select {
+		case e := <-events:
+			if e.Op != want.Op || e.Path != want.Path || e.OldPath != want.OldPath || e.Size != want.Size {
+				t.Errorf("expected event %+v, got %+v", want, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %+v", want)
+		}
+	}
+}
+
+func TestWatchSub(t *testing.T) {
+	fsys := wfs.Mem()
+	if err := fsys.MkdirAll("root/dir", 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	sub, err := wfs.Sub(fsys, "root")
+	if err != nil {
+		t.Fatalf("failed to create sub: %v", err)
+	}
+	events, stop, err := wfs.Watch(sub, ".", wfs.WatchOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	defer stop()
+
+	wfs.WriteFile(fsys, "outside", nil, 0644)
+	fsys.Rename("outside", "root/dir/inside")
+	fsys.Rename("root/dir/inside", "gone")
+	fsys.RemoveAll("root")
+
+	expected := []wfs.Event{
+		{Op: wfs.OpCreate, Path: "dir/inside"},
+		{Op: wfs.OpRemove, Path: "dir/inside"},
+		{Op: wfs.OpRemove, Path: "."},
+	}
+	for _, want := range expected {
+		select {
+		case e := <-events:
+			if e.Op != want.Op || e.Path != want.Path || e.OldPath != want.OldPath {
+				t.Errorf("expected event %+v, got %+v", want, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %+v", want)
+		}
+	}
+}
+
+func TestWatchNative(t *testing.T) {
+	fsys := wfs.Dir(t.TempDir())
+	if _, ok := fsys.(wfs.WatchFS); !ok {
+		t.Skip("file system is polled")
+	}
+	if err := fsys.MkdirAll("dir", 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	events, stop, err := wfs.Watch(fsys, "dir", wfs.WatchOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	defer stop()
+
+	// the entries of a new directory are reported even if they are
+	// created before the directory is watched
+	if err := fsys.MkdirAll("dir/a/b", 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	waitEvent(t, events, wfs.OpCreate, "dir/a")
+	waitEvent(t, events, wfs.OpCreate, "dir/a/b")
+	if err := wfs.WriteFile(fsys, "dir/a/b/file", []byte("Hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	waitEvent(t, events, wfs.OpCreate, "dir/a/b/file")
+	waitEvent(t, events, wfs.OpWrite, "dir/a/b/file")
+
+	// renames are reported as renames instead of a remove and a create
+	if err := fsys.Rename("dir/a", "dir/c"); err != nil {
+		t.Fatalf("failed to rename directory: %v", err)
+	}
+	if e := waitEvent(t, events, wfs.OpRename, "dir/c"); e.OldPath != "dir/a" {
+		t.Errorf("expected rename from dir/a, got %q", e.OldPath)
+	}
+	if err := fsys.Remove("dir/c/b/file"); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	if e := waitEvent(t, events, wfs.OpRemove, "dir/c/b/file"); e.Size != -5 {
+		t.Errorf("expected remove of 5 bytes, got %d", e.Size)
+	}
+
+	// moving out of the watched directory is a remove
+	if err := fsys.Rename("dir/c", "gone"); err != nil {
+		t.Fatalf("failed to rename directory: %v", err)
+	}
+	waitEvent(t, events, wfs.OpRemove, "dir/c")
+	if err := wfs.WriteFile(fsys, "gone/b/file", nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := fsys.RemoveAll("dir"); err != nil {
+		t.Fatalf("failed to remove directory: %v", err)
+	}
+	for e := range events {
+		if e.Op == wfs.OpRemove && e.Path == "dir" {
+			break
+		}
+		if e.Path != "dir" {
+			t.Errorf("expected no events after moving out, got %+v", e)
+		}
+	}
+}
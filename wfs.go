@@ -1,12 +1,17 @@
 package wfs
 
 import (
+	"errors"
 	"io"
 	"io/fs"
 	"os"
+	"path"
+	"time"
 )
 
 // File is the minimum implementation of a file in a writable file system.
+// Files of directories should also implement [fs.ReadDirFile], as the files
+// of the backends and wrappers of this package do.
 type File interface {
 	fs.File
 	io.WriteSeeker
@@ -18,12 +23,26 @@ type File interface {
 	// If there is an error, it will be of type [*fs.PathError].
 	Truncate(size int64) error
 
+	// Sync commits the current contents of the file to stable storage.
+	// Backends without stable storage return nil.
+	// If there is an error, it will be of type [*fs.PathError].
+	Sync() error
+
 	// Name returns the name of the file as presented to Open.
 	//
 	// It is safe to call Name after [Close].
 	Name() string
 }
 
+// readDir reads the entries of the directory f, as described by
+// [fs.ReadDirFile], failing if f does not implement it.
+func readDir(f File, n int) ([]fs.DirEntry, error) {
+	if d, ok := f.(fs.ReadDirFile); ok {
+		return d.ReadDir(n)
+	}
+	return nil, &fs.PathError{Op: "readdir", Path: f.Name(), Err: errors.ErrUnsupported}
+}
+
 // FS provides access to a writable file system.
 //
 // The FS implements [fs.FS] and as well as FileFS and DirFS to provide
@@ -94,6 +113,16 @@ func Create(fs FileFS, name string) (File, error) {
 	return fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 }
 
+// CreateAll creates or truncates the named file like [Create], creating any
+// missing parent directories with mode 0o777 (before umask) first.
+// If the file does not exist, it is created with permissions perm (before umask).
+func CreateAll(fsys FS, name string, perm fs.FileMode) (File, error) {
+	if err := fsys.MkdirAll(path.Dir(name), 0777); err != nil {
+		return nil, err
+	}
+	return fsys.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+}
+
 // WriteFile writes data to the named file, creating it if necessary.
 // If the file does not exist, WriteFile creates it with permissions perm (before umask);
 // otherwise WriteFile truncates it before writing, without changing permissions.
@@ -110,3 +139,94 @@ func WriteFile(fs FileFS, name string, data []byte, perm fs.FileMode) error {
 	}
 	return err
 }
+
+// WriteReader writes the contents of r to the named file, creating it if necessary,
+// and returns the number of bytes written. Like [WriteFile] it truncates an
+// existing file without changing permissions, but the contents are streamed
+// with buffered copies instead of being held in memory.
+func WriteReader(fs FileFS, name string, r io.Reader, perm fs.FileMode) (int64, error) {
+	f, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return n, err
+}
+
+// AppendFile appends data to the named file, creating it if necessary.
+// If the file does not exist, AppendFile creates it with permissions perm (before umask).
+func AppendFile(fs FileFS, name string, data []byte, perm fs.FileMode) error {
+	f, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// SyncFS commits the directory dir of fsys to stable storage, so that
+// entries created, renamed or removed in it survive a crash.
+// It should be called on the parent directory after a Rename
+// that must be durable.
+func SyncFS(fsys FS, dir string) error {
+	f, err := fsys.OpenFile(dir, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+// Exists reports whether the named file or directory exists in fsys.
+// A missing file is not an error, other errors such as a permission
+// error are returned.
+func Exists(fsys fs.FS, name string) (bool, error) {
+	_, err := fs.Stat(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// IsDir reports whether the named file exists in fsys and is a directory.
+// A missing file is not an error.
+func IsDir(fsys fs.FS, name string) (bool, error) {
+	info, err := fs.Stat(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+// Touch creates the named file with mode 0o666 (before umask) if it does not
+// exist, and otherwise sets its access and modification times to the current
+// time without changing its contents. Times of existing files are only
+// updated where fsys implements [MetaFS].
+func Touch(fsys FS, name string) error {
+	now := time.Now()
+	err := Chtimes(fsys, name, now, now)
+	if errors.Is(err, errors.ErrUnsupported) {
+		// the times cannot be changed, only create the file if it is missing
+		_, err = fs.Stat(fsys, name)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
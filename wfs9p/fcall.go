@@ -0,0 +1,215 @@
+package wfs9p
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io/fs"
+	"time"
+)
+
+// Message types of 9P2000.
+const (
+	msgTversion = 100 + iota
+	msgRversion
+	msgTauth
+	msgRauth
+	msgTattach
+	msgRattach
+	msgTerror // not used
+	msgRerror
+	msgTflush
+	msgRflush
+	msgTwalk
+	msgRwalk
+	msgTopen
+	msgRopen
+	msgTcreate
+	msgRcreate
+	msgTread
+	msgRread
+	msgTwrite
+	msgRwrite
+	msgTclunk
+	msgRclunk
+	msgTremove
+	msgRremove
+	msgTstat
+	msgRstat
+	msgTwstat
+	msgRwstat
+)
+
+const (
+	noFid   = ^uint32(0)
+	noTag   = ^uint16(0)
+	maxWalk = 16
+	// ioHeaderSize is the size of the header of Rread and Twrite messages.
+	ioHeaderSize = 24
+)
+
+// Open modes.
+const (
+	modeRead   = 0
+	modeWrite  = 1
+	modeRdwr   = 2
+	modeExec   = 3
+	modeTrunc  = 0x10
+	modeRclose = 0x40
+)
+
+// Bits of qid types and file modes.
+const (
+	qidDir    = 0x80
+	qidFile   = 0x00
+	dmDir     = 0x80000000
+	dmPermBit = 0777
+)
+
+var errMessage = errors.New("malformed message")
+
+// qid is the server's unique identification of a file.
+type qid struct {
+	typ     uint8
+	version uint32
+	path    uint64
+}
+
+// newQid returns the qid of the file name with info.
+func newQid(name string, info fs.FileInfo) qid {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	q := qid{typ: qidFile, version: uint32(info.ModTime().UnixNano()) ^ uint32(info.Size()), path: h.Sum64()}
+	if info.IsDir() {
+		q.typ = qidDir
+	}
+	return q
+}
+
+// stat is the machine-independent directory entry of a file.
+type stat struct {
+	typ    uint16
+	dev    uint32
+	qid    qid
+	mode   uint32
+	atime  uint32
+	mtime  uint32
+	length uint64
+	name   string
+	uid    string
+	gid    string
+	muid   string
+}
+
+// newStat returns the stat of the file name with info, owned by user.
+func newStat(name string, info fs.FileInfo, user string) stat {
+	s := stat{
+		qid:    newQid(name, info),
+		mode:   uint32(info.Mode().Perm()),
+		atime:  uint32(info.ModTime().Unix()),
+		mtime:  uint32(info.ModTime().Unix()),
+		length: uint64(info.Size()),
+		name:   info.Name(),
+		uid:    user,
+		gid:    user,
+	}
+	if info.IsDir() {
+		s.mode |= dmDir
+		s.length = 0
+	}
+	if name == "." {
+		s.name = "/"
+	}
+	return s
+}
+
+// modTime returns the modification time of s.
+func (s stat) modTime() time.Time {
+	return time.Unix(int64(s.mtime), 0)
+}
+
+// encoder appends the fields of a message to buf.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) u8(v uint8)   { e.buf = append(e.buf, v) }
+func (e *encoder) u16(v uint16) { e.buf = binary.LittleEndian.AppendUint16(e.buf, v) }
+func (e *encoder) u32(v uint32) { e.buf = binary.LittleEndian.AppendUint32(e.buf, v) }
+func (e *encoder) u64(v uint64) { e.buf = binary.LittleEndian.AppendUint64(e.buf, v) }
+
+func (e *encoder) str(s string) {
+	e.u16(uint16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) qid(q qid) {
+	e.u8(q.typ)
+	e.u32(q.version)
+	e.u64(q.path)
+}
+
+func (e *encoder) stat(s stat) {
+	start := len(e.buf)
+	e.u16(0)
+	e.u16(s.typ)
+	e.u32(s.dev)
+	e.qid(s.qid)
+	e.u32(s.mode)
+	e.u32(s.atime)
+	e.u32(s.mtime)
+	e.u64(s.length)
+	e.str(s.name)
+	e.str(s.uid)
+	e.str(s.gid)
+	e.str(s.muid)
+	binary.LittleEndian.PutUint16(e.buf[start:], uint16(len(e.buf)-start-2))
+}
+
+// decoder reads the fields of a message from buf, recording whether the
+// message was too short.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || len(d.buf) < n {
+		d.err = errMessage
+		return make([]byte, n)
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) u8() uint8   { return d.next(1)[0] }
+func (d *decoder) u16() uint16 { return binary.LittleEndian.Uint16(d.next(2)) }
+func (d *decoder) u32() uint32 { return binary.LittleEndian.Uint32(d.next(4)) }
+func (d *decoder) u64() uint64 { return binary.LittleEndian.Uint64(d.next(8)) }
+func (d *decoder) str() string { return string(d.next(int(d.u16()))) }
+
+func (d *decoder) qid() qid {
+	return qid{typ: d.u8(), version: d.u32(), path: d.u64()}
+}
+
+func (d *decoder) stat() stat {
+	sd := &decoder{buf: d.next(int(d.u16()))}
+	s := stat{
+		typ:    sd.u16(),
+		dev:    sd.u32(),
+		qid:    sd.qid(),
+		mode:   sd.u32(),
+		atime:  sd.u32(),
+		mtime:  sd.u32(),
+		length: sd.u64(),
+		name:   sd.str(),
+		uid:    sd.str(),
+		gid:    sd.str(),
+		muid:   sd.str(),
+	}
+	if sd.err != nil && d.err == nil {
+		d.err = sd.err
+	}
+	return s
+}
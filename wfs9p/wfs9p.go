@@ -0,0 +1,622 @@
+// Package wfs9p exports a file system over the 9P2000 protocol, so that it
+// can be mounted by the Linux v9fs client, WSL or Plan 9 tools without FUSE.
+//
+// On Linux a served file system can be mounted with:
+//
+//	mount -t 9p -o trans=tcp,port=5640,version=9p2000 127.0.0.1 /mnt
+//
+// Clients asking for a 9P2000 variant such as 9P2000.L are answered with
+// plain 9P2000, which they fall back to. Authentication is not supported
+// and the user names of attach requests are reported as the owners of
+// every file.
+package wfs9p
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+// maxMsize is the largest message size negotiated with clients.
+const maxMsize = 1 << 20
+
+var (
+	errBadFid   = errors.New("fid unknown or out of range")
+	errDupFid   = errors.New("fid already in use")
+	errOpen     = errors.New("file already open")
+	errNotOpen  = errors.New("file not open for I/O")
+	errAuth     = errors.New("authentication not required")
+	errDirRead  = errors.New("bad offset in directory read")
+	errFirstMsg = errors.New("first message must be Tversion")
+)
+
+// errStrings maps errors to the strings they are reported with, the
+// strings of Linux errno values which the v9fs client maps back to them.
+// The errors of wfs come first as platform errors such as ENOTEMPTY also
+// match errors of [io/fs].
+var errStrings = []struct {
+	err error
+	str string
+}{
+	{wfs.ErrNotEmpty, "Directory not empty"},
+	{wfs.ErrIsDir, "Is a directory"},
+	{wfs.ErrNotDir, "Not a directory"},
+	{fs.ErrNotExist, "No such file or directory"},
+	{fs.ErrExist, "File exists"},
+	{fs.ErrPermission, "Permission denied"},
+	{fs.ErrInvalid, "Invalid argument"},
+	{errors.ErrUnsupported, "Operation not supported"},
+}
+
+// errString returns the string err is reported with.
+func errString(err error) string {
+	for _, e := range errStrings {
+		if errors.Is(err, e.err) {
+			return e.str
+		}
+	}
+	// paths are omitted as they are known to the client
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case errors.As(err, &pathErr):
+		err = pathErr.Err
+	case errors.As(err, &linkErr):
+		err = linkErr.Err
+	}
+	return err.Error()
+}
+
+// Serve accepts connections on l and serves fsys on each of them in a new
+// goroutine. It returns the error of Accept, such as when l is closed.
+func Serve(l net.Listener, fsys wfs.FS) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go ServeConn(conn, fsys)
+	}
+}
+
+// ServeConn serves fsys on a single connection until the client closes it
+// or sends a malformed message, then closes the connection.
+func ServeConn(conn io.ReadWriteCloser, fsys wfs.FS) error {
+	s := &session{fsys: fsys, conn: conn, msize: maxMsize, fids: make(map[uint32]*fid)}
+	defer s.close()
+	for {
+		typ, tag, d, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !s.versioned && typ != msgTversion {
+			err = errFirstMsg
+		}
+		// the header is filled in once the size is known
+		e := &encoder{buf: make([]byte, 7, 64)}
+		if err == nil {
+			err = s.handle(typ, d, e)
+		}
+		if err == nil && d.err != nil {
+			err = d.err
+		}
+		rtyp := typ + 1
+		if err != nil {
+			e.buf = e.buf[:7]
+			e.str(errString(err))
+			rtyp = msgRerror
+		}
+		binary.LittleEndian.PutUint32(e.buf, uint32(len(e.buf)))
+		e.buf[4] = rtyp
+		binary.LittleEndian.PutUint16(e.buf[5:], tag)
+		if _, err := conn.Write(e.buf); err != nil {
+			return err
+		}
+	}
+}
+
+// fid is a file of a session, which may be opened for I/O.
+type fid struct {
+	name string
+	user string
+	file wfs.File
+	mode uint8
+
+	dir    []stat // remaining entries of a directory being read
+	dirOff uint64 // offset of the next directory read
+}
+
+// readable reports whether f is open for reading.
+func (f *fid) readable() bool {
+	return f.file != nil && f.mode&3 != modeWrite
+}
+
+// writable reports whether f is open for writing.
+func (f *fid) writable() bool {
+	return f.file != nil && (f.mode&3 == modeWrite || f.mode&3 == modeRdwr)
+}
+
+// session is the state of a connection.
+type session struct {
+	fsys      wfs.FS
+	conn      io.ReadWriteCloser
+	msize     uint32
+	versioned bool
+	fids      map[uint32]*fid
+}
+
+// read reads the next message.
+func (s *session) read() (typ uint8, tag uint16, d *decoder, err error) {
+	var size [4]byte
+	if _, err := io.ReadFull(s.conn, size[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	n := binary.LittleEndian.Uint32(size[:])
+	if n < 7 || n > s.msize {
+		return 0, 0, nil, errMessage
+	}
+	buf := make([]byte, n-4)
+	if _, err := io.ReadFull(s.conn, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, 0, nil, err
+	}
+	d = &decoder{buf: buf}
+	return d.u8(), d.u16(), d, nil
+}
+
+// reset clunks every fid of the session.
+func (s *session) reset() {
+	for n, f := range s.fids {
+		if f.file != nil {
+			f.file.Close()
+		}
+		delete(s.fids, n)
+	}
+}
+
+func (s *session) close() {
+	s.reset()
+	s.conn.Close()
+}
+
+// fid returns the fid n.
+func (s *session) fid(n uint32) (*fid, error) {
+	f, ok := s.fids[n]
+	if !ok {
+		return nil, errBadFid
+	}
+	return f, nil
+}
+
+// handle handles a message of type typ, encoding its response into e.
+func (s *session) handle(typ uint8, d *decoder, e *encoder) error {
+	switch typ {
+	case msgTversion:
+		return s.version(d, e)
+	case msgTauth:
+		return errAuth
+	case msgTattach:
+		return s.attach(d, e)
+	case msgTflush:
+		// messages are handled in order, so there is nothing to flush
+		d.u16()
+		return nil
+	case msgTwalk:
+		return s.walk(d, e)
+	case msgTopen:
+		return s.open(d, e)
+	case msgTcreate:
+		return s.create(d, e)
+	case msgTread:
+		return s.readMsg(d, e)
+	case msgTwrite:
+		return s.write(d, e)
+	case msgTclunk:
+		return s.clunk(d)
+	case msgTremove:
+		return s.remove(d)
+	case msgTstat:
+		return s.stat(d, e)
+	case msgTwstat:
+		return s.wstat(d)
+	}
+	return errors.ErrUnsupported
+}
+
+func (s *session) version(d *decoder, e *encoder) error {
+	msize, version := d.u32(), d.str()
+	s.reset()
+	s.msize = min(msize, maxMsize)
+	s.versioned = true
+	if strings.HasPrefix(version, "9P2000") {
+		version = "9P2000"
+	} else {
+		version = "unknown"
+	}
+	e.u32(s.msize)
+	e.str(version)
+	return nil
+}
+
+func (s *session) attach(d *decoder, e *encoder) error {
+	n, _, user, _ := d.u32(), d.u32(), d.str(), d.str()
+	if _, ok := s.fids[n]; ok {
+		return errDupFid
+	}
+	info, err := fs.Stat(s.fsys, ".")
+	if err != nil {
+		return err
+	}
+	if user == "" {
+		user = "none"
+	}
+	s.fids[n] = &fid{name: ".", user: user}
+	e.qid(newQid(".", info))
+	return nil
+}
+
+func (s *session) walk(d *decoder, e *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	newfid := d.u32()
+	names := make([]string, d.u16())
+	for i := range names {
+		names[i] = d.str()
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if f.file != nil {
+		return errOpen
+	}
+	if len(names) > maxWalk {
+		return fs.ErrInvalid
+	}
+	if _, ok := s.fids[newfid]; ok && f != s.fids[newfid] {
+		return errDupFid
+	}
+	name := f.name
+	var qids []qid
+	for i, elem := range names {
+		switch {
+		case elem == "..":
+			name = path.Dir(name)
+		case elem == "" || elem == "." || strings.Contains(elem, "/"):
+			err = fs.ErrInvalid
+		default:
+			name = path.Join(name, elem)
+		}
+		var info fs.FileInfo
+		if err == nil {
+			info, err = fs.Stat(s.fsys, name)
+		}
+		if err != nil {
+			// a partial walk succeeds without creating newfid
+			if i == 0 {
+				return err
+			}
+			break
+		}
+		qids = append(qids, newQid(name, info))
+	}
+	if len(qids) == len(names) {
+		s.fids[newfid] = &fid{name: name, user: f.user}
+	}
+	e.u16(uint16(len(qids)))
+	for _, q := range qids {
+		e.qid(q)
+	}
+	return nil
+}
+
+// openFlag returns the flag of [wfs.FS.OpenFile] for a 9P open mode.
+func openFlag(mode uint8) int {
+	var flag int
+	switch mode & 3 {
+	case modeWrite:
+		flag = os.O_WRONLY
+	case modeRdwr:
+		flag = os.O_RDWR
+	}
+	if mode&modeTrunc != 0 {
+		flag |= os.O_TRUNC
+	}
+	return flag
+}
+
+func (s *session) open(d *decoder, e *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	mode := d.u8()
+	if f.file != nil {
+		return errOpen
+	}
+	info, err := fs.Stat(s.fsys, f.name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() && (openFlag(mode) != os.O_RDONLY || mode&modeRclose != 0) {
+		return wfs.ErrIsDir
+	}
+	file, err := s.fsys.OpenFile(f.name, openFlag(mode), 0)
+	if err != nil {
+		return err
+	}
+	f.file, f.mode, f.dir, f.dirOff = file, mode, nil, 0
+	e.qid(newQid(f.name, info))
+	e.u32(s.msize - ioHeaderSize)
+	return nil
+}
+
+func (s *session) create(d *decoder, e *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	elem, perm, mode := d.str(), d.u32(), d.u8()
+	if d.err != nil {
+		return d.err
+	}
+	if f.file != nil {
+		return errOpen
+	}
+	if elem == "" || elem == "." || elem == ".." || strings.Contains(elem, "/") {
+		return fs.ErrInvalid
+	}
+	name := path.Join(f.name, elem)
+	var file wfs.File
+	if perm&dmDir != 0 {
+		if err := s.fsys.Mkdir(name, fs.FileMode(perm&dmPermBit)); err != nil {
+			return err
+		}
+		file, err = s.fsys.OpenFile(name, os.O_RDONLY, 0)
+	} else {
+		file, err = s.fsys.OpenFile(name, openFlag(mode)|os.O_CREATE|os.O_EXCL, fs.FileMode(perm&dmPermBit))
+	}
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.name, f.file, f.mode, f.dir, f.dirOff = name, file, mode, nil, 0
+	e.qid(newQid(name, info))
+	e.u32(s.msize - ioHeaderSize)
+	return nil
+}
+
+func (s *session) readMsg(d *decoder, e *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	offset, count := d.u64(), d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	if !f.readable() {
+		return errNotOpen
+	}
+	count = min(count, s.msize-ioHeaderSize)
+	info, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		data, err := s.readDir(f, offset, count)
+		if err != nil {
+			return err
+		}
+		e.u32(uint32(len(data)))
+		e.buf = append(e.buf, data...)
+		return nil
+	}
+	start := len(e.buf)
+	e.u32(0)
+	e.buf = append(e.buf, make([]byte, count)...)
+	n, err := f.file.ReadAt(e.buf[start+4:], int64(offset))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	e.buf = e.buf[:start+4+n]
+	binary.LittleEndian.PutUint32(e.buf[start:], uint32(n))
+	return nil
+}
+
+// readDir returns up to count bytes of the entries of the directory f from
+// offset, which must be zero to start reading or continue the last read.
+func (s *session) readDir(f *fid, offset uint64, count uint32) ([]byte, error) {
+	if offset == 0 {
+		entries, err := fs.ReadDir(s.fsys, f.name)
+		if err != nil {
+			return nil, err
+		}
+		f.dir = f.dir[:0]
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			f.dir = append(f.dir, newStat(path.Join(f.name, entry.Name()), info, f.user))
+		}
+		f.dirOff = 0
+	} else if offset != f.dirOff {
+		return nil, errDirRead
+	}
+	// entries are never split between reads
+	e := &encoder{}
+	for len(f.dir) > 0 {
+		n := len(e.buf)
+		e.stat(f.dir[0])
+		if len(e.buf) > int(count) {
+			e.buf = e.buf[:n]
+			break
+		}
+		f.dir = f.dir[1:]
+	}
+	if len(e.buf) == 0 && len(f.dir) > 0 {
+		return nil, fs.ErrInvalid
+	}
+	f.dirOff += uint64(len(e.buf))
+	return e.buf, nil
+}
+
+func (s *session) write(d *decoder, e *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	offset := d.u64()
+	data := d.next(int(d.u32()))
+	if d.err != nil {
+		return d.err
+	}
+	if !f.writable() {
+		return errNotOpen
+	}
+	n, err := f.file.WriteAt(data, int64(offset))
+	if err != nil {
+		return err
+	}
+	e.u32(uint32(n))
+	return nil
+}
+
+func (s *session) clunk(d *decoder) error {
+	n := d.u32()
+	f, err := s.fid(n)
+	if err != nil {
+		return err
+	}
+	delete(s.fids, n)
+	if f.file == nil {
+		return nil
+	}
+	err = f.file.Close()
+	if f.mode&modeRclose != 0 {
+		err = s.fsys.Remove(f.name)
+	}
+	return err
+}
+
+func (s *session) remove(d *decoder) error {
+	n := d.u32()
+	f, err := s.fid(n)
+	if err != nil {
+		return err
+	}
+	// the fid is clunked even if the file cannot be removed
+	delete(s.fids, n)
+	if f.file != nil {
+		f.file.Close()
+	}
+	return s.fsys.Remove(f.name)
+}
+
+// info returns the info of the file of f.
+func (s *session) info(f *fid) (fs.FileInfo, error) {
+	if f.file != nil {
+		return f.file.Stat()
+	}
+	return fs.Stat(s.fsys, f.name)
+}
+
+func (s *session) stat(d *decoder, e *encoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	info, err := s.info(f)
+	if err != nil {
+		return err
+	}
+	st := &encoder{}
+	st.stat(newStat(f.name, info, f.user))
+	e.u16(uint16(len(st.buf)))
+	e.buf = append(e.buf, st.buf...)
+	return nil
+}
+
+// wstat changes the fields of the stat of a file that are not set to
+// "don't touch" values, which are the maximum integers and empty strings.
+// The owners of files cannot be changed.
+func (s *session) wstat(d *decoder) error {
+	f, err := s.fid(d.u32())
+	if err != nil {
+		return err
+	}
+	d.u16()
+	st := d.stat()
+	if d.err != nil {
+		return d.err
+	}
+	info, err := s.info(f)
+	if err != nil {
+		return err
+	}
+	if st.length != ^uint64(0) && st.length != uint64(info.Size()) {
+		if err := s.truncate(f, int64(st.length)); err != nil {
+			return err
+		}
+	}
+	if st.mode != ^uint32(0) && fs.FileMode(st.mode&dmPermBit) != info.Mode().Perm() {
+		if err := wfs.Chmod(s.fsys, f.name, fs.FileMode(st.mode&dmPermBit)); err != nil {
+			return err
+		}
+	}
+	if st.mtime != ^uint32(0) && st.mtime != uint32(info.ModTime().Unix()) {
+		if err := wfs.Chtimes(s.fsys, f.name, time.Time{}, st.modTime()); err != nil {
+			return err
+		}
+	}
+	// names are changed within the same directory
+	if st.name != "" && st.name != info.Name() {
+		if f.name == "." || strings.Contains(st.name, "/") {
+			return fs.ErrInvalid
+		}
+		name := path.Join(path.Dir(f.name), st.name)
+		if err := s.fsys.Rename(f.name, name); err != nil {
+			return err
+		}
+		f.name = name
+	}
+	return nil
+}
+
+// truncate changes the size of the file of f.
+func (s *session) truncate(f *fid, size int64) error {
+	if f.writable() {
+		return f.file.Truncate(size)
+	}
+	file, err := s.fsys.OpenFile(f.name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	err = file.Truncate(size)
+	if err1 := file.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
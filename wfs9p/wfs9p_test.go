@@ -0,0 +1,201 @@
+package wfs9p
+
+import (
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+// client sends messages to a served file system.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func serve(t *testing.T, fsys wfs.FS) *client {
+	t.Helper()
+	c1, c2 := net.Pipe()
+	go ServeConn(c2, fsys)
+	t.Cleanup(func() { c1.Close() })
+	return &client{t: t, conn: c1}
+}
+
+// rpc sends a message of type typ with the fields encoded by fn and returns
+// the decoder of the response, or the error string of an Rerror.
+func (c *client) rpc(typ uint8, fn func(e *encoder)) (*decoder, string) {
+	c.t.Helper()
+	e := &encoder{buf: make([]byte, 7)}
+	fn(e)
+	binary.LittleEndian.PutUint32(e.buf, uint32(len(e.buf)))
+	e.buf[4] = typ
+	binary.LittleEndian.PutUint16(e.buf[5:], 1)
+	if _, err := c.conn.Write(e.buf); err != nil {
+		c.t.Fatalf("write failed: %v", err)
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(c.conn, size[:]); err != nil {
+		c.t.Fatalf("read failed: %v", err)
+	}
+	buf := make([]byte, binary.LittleEndian.Uint32(size[:])-4)
+	if _, err := io.ReadFull(c.conn, buf); err != nil {
+		c.t.Fatalf("read failed: %v", err)
+	}
+	d := &decoder{buf: buf}
+	rtyp, tag := d.u8(), d.u16()
+	if tag != 1 {
+		c.t.Fatalf("expected tag 1, got %d", tag)
+	}
+	if rtyp == msgRerror {
+		return nil, d.str()
+	}
+	if rtyp != typ+1 {
+		c.t.Fatalf("expected response %d, got %d", typ+1, rtyp)
+	}
+	return d, ""
+}
+
+// must is rpc failing the test on errors.
+func (c *client) must(typ uint8, fn func(e *encoder)) *decoder {
+	c.t.Helper()
+	d, errStr := c.rpc(typ, fn)
+	if errStr != "" {
+		c.t.Fatalf("message %d failed: %s", typ, errStr)
+	}
+	return d
+}
+
+func (c *client) walk(fid, newfid uint32, names ...string) (*decoder, string) {
+	c.t.Helper()
+	return c.rpc(msgTwalk, func(e *encoder) {
+		e.u32(fid)
+		e.u32(newfid)
+		e.u16(uint16(len(names)))
+		for _, name := range names {
+			e.str(name)
+		}
+	})
+}
+
+func (c *client) read(fid uint32, offset uint64, count uint32) []byte {
+	c.t.Helper()
+	d := c.must(msgTread, func(e *encoder) { e.u32(fid); e.u64(offset); e.u32(count) })
+	return d.next(int(d.u32()))
+}
+
+func (c *client) clunk(fid uint32) {
+	c.t.Helper()
+	c.must(msgTclunk, func(e *encoder) { e.u32(fid) })
+}
+
+func TestServe(t *testing.T) {
+	fsys := wfs.Mem()
+	if err := wfs.WriteFile(fsys, "hello.txt", []byte("hello world"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := fsys.Mkdir("dir", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	c := serve(t, fsys)
+
+	if _, errStr := c.rpc(msgTattach, func(e *encoder) { e.u32(0); e.u32(noFid); e.str("u"); e.str("") }); errStr == "" {
+		t.Errorf("expected messages before Tversion to fail")
+	}
+	d := c.must(msgTversion, func(e *encoder) { e.u32(8192); e.str("9P2000.L") })
+	if msize, version := d.u32(), d.str(); msize != 8192 || version != "9P2000" {
+		t.Errorf("expected msize 8192 and version 9P2000, got %d %q", msize, version)
+	}
+	d = c.must(msgTattach, func(e *encoder) { e.u32(0); e.u32(noFid); e.str("glenda"); e.str("") })
+	if q := d.qid(); q.typ != qidDir {
+		t.Errorf("expected root to be a directory, got %v", q)
+	}
+
+	// reading a file
+	if _, errStr := c.walk(0, 1, "missing"); errStr != "No such file or directory" {
+		t.Errorf("expected missing file error, got %q", errStr)
+	}
+	d, _ = c.walk(0, 1, "dir", "..", "hello.txt")
+	if n := d.u16(); n != 3 {
+		t.Fatalf("expected 3 qids, got %d", n)
+	}
+	c.must(msgTopen, func(e *encoder) { e.u32(1); e.u8(modeRead) })
+	if data := c.read(1, 6, 100); string(data) != "world" {
+		t.Errorf("expected %q, got %q", "world", data)
+	}
+	if _, errStr := c.rpc(msgTwrite, func(e *encoder) { e.u32(1); e.u64(0); e.u32(1); e.u8('x') }); errStr == "" {
+		t.Errorf("expected writing a file opened for reading to fail")
+	}
+	d = c.must(msgTstat, func(e *encoder) { e.u32(1) })
+	d.u16()
+	if st := d.stat(); st.name != "hello.txt" || st.length != 11 || st.mode != 0644 || st.uid != "glenda" {
+		t.Errorf("unexpected stat %+v", st)
+	}
+	c.clunk(1)
+
+	// creating and writing a file
+	c.walk(0, 2, "dir")
+	c.must(msgTcreate, func(e *encoder) { e.u32(2); e.str("new.txt"); e.u32(0600); e.u8(modeRdwr) })
+	c.must(msgTwrite, func(e *encoder) { e.u32(2); e.u64(0); e.u32(5); e.buf = append(e.buf, "01234"...) })
+	c.must(msgTwrite, func(e *encoder) { e.u32(2); e.u64(3); e.u32(2); e.buf = append(e.buf, "ab"...) })
+	c.clunk(2)
+	data, err := fs.ReadFile(fsys, "dir/new.txt")
+	if err != nil || string(data) != "012ab" {
+		t.Errorf("expected %q, got %q err: %v", "012ab", data, err)
+	}
+	c.walk(0, 3, "dir")
+	c.must(msgTcreate, func(e *encoder) { e.u32(3); e.str("sub"); e.u32(dmDir | 0755); e.u8(modeRead) })
+	c.clunk(3)
+
+	// listing a directory in reads that fit whole entries
+	c.walk(0, 4, "dir")
+	c.must(msgTopen, func(e *encoder) { e.u32(4); e.u8(modeRead) })
+	var names []string
+	var offset uint64
+	for {
+		data := c.read(4, offset, 80)
+		if len(data) == 0 {
+			break
+		}
+		offset += uint64(len(data))
+		d := &decoder{buf: data}
+		for len(d.buf) > 0 {
+			names = append(names, d.stat().name)
+		}
+	}
+	if !slices.Equal(names, []string{"new.txt", "sub"}) {
+		t.Errorf("expected entries [new.txt sub], got %v", names)
+	}
+	c.clunk(4)
+
+	// renaming and truncating with wstat, leaving other fields untouched
+	c.walk(0, 5, "dir", "new.txt")
+	c.must(msgTwstat, func(e *encoder) {
+		st := &encoder{}
+		st.stat(stat{typ: ^uint16(0), dev: ^uint32(0), qid: qid{^uint8(0), ^uint32(0), ^uint64(0)},
+			mode: ^uint32(0), atime: ^uint32(0), mtime: ^uint32(0), length: 2, name: "renamed.txt"})
+		e.u32(5)
+		e.u16(uint16(len(st.buf)))
+		e.buf = append(e.buf, st.buf...)
+	})
+	data, err = fs.ReadFile(fsys, "dir/renamed.txt")
+	if err != nil || string(data) != "01" {
+		t.Errorf("expected %q, got %q err: %v", "01", data, err)
+	}
+
+	if _, errStr := c.rpc(msgTremove, func(e *encoder) { e.u32(0) }); errStr != "Invalid argument" {
+		t.Errorf("expected removing the root to fail, got %q", errStr)
+	}
+	c.must(msgTattach, func(e *encoder) { e.u32(0); e.u32(noFid); e.str("glenda"); e.str("") })
+	c.walk(0, 6, "dir")
+	if _, errStr := c.rpc(msgTremove, func(e *encoder) { e.u32(6) }); errStr != "Directory not empty" {
+		t.Errorf("expected removing a directory with files to fail, got %q", errStr)
+	}
+	c.must(msgTremove, func(e *encoder) { e.u32(5) })
+	if _, err := fs.Stat(fsys, "dir/renamed.txt"); err == nil {
+		t.Errorf("expected file to be removed")
+	}
+}
@@ -0,0 +1,155 @@
+// Package wfsbench implements standard benchmark workloads for writable file
+// systems, so that backends can be compared with each other and performance
+// regressions caught.
+//
+// [Run] runs every workload against a backend from a benchmark function:
+//
+//	func BenchmarkBackend(b *testing.B) {
+//		wfsbench.Run(b, func() wfs.FS { return mybackend.New(b.TempDir()) })
+//	}
+//
+// Results of two revisions can be compared with benchstat:
+//
+//	go test -run '^$' -bench . -count 10 > new.txt
+//	benchstat old.txt new.txt
+package wfsbench
+
+import (
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+const (
+	fileSize  = 1 << 20  // size of the files written and read
+	chunkSize = 32 << 10 // size of sequential writes
+	readSize  = 4 << 10  // size of random reads
+	smallSize = 1 << 10  // size of small files
+	liveFiles = 128      // small files kept while churning
+	treeDepth = 16       // depth of removed trees
+	treeWidth = 4        // files per directory of removed trees
+)
+
+// Run runs every workload against a file system, each in its own
+// sub-benchmark. newFS must return a new, empty file system every time it is
+// called.
+func Run(b *testing.B, newFS func() wfs.FS) {
+	b.Run("SequentialWrite", func(b *testing.B) { SequentialWrite(b, newFS()) })
+	b.Run("RandomReadAt", func(b *testing.B) { RandomReadAt(b, newFS()) })
+	b.Run("SmallFileChurn", func(b *testing.B) { SmallFileChurn(b, newFS()) })
+	b.Run("RemoveAllDeep", func(b *testing.B) { RemoveAllDeep(b, newFS()) })
+}
+
+// chunk returns pseudo-random data of size n, so that backends that compress
+// or deduplicate are not favored.
+func chunk(n int) []byte {
+	r := rand.New(rand.NewPCG(1, 2))
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(r.Uint32())
+	}
+	return data
+}
+
+// SequentialWrite measures writing a 1 MiB file in 32 KiB chunks, truncating
+// and syncing it in every iteration.
+func SequentialWrite(b *testing.B, fsys wfs.FS) {
+	data := chunk(chunkSize)
+	b.SetBytes(fileSize)
+	b.ResetTimer()
+	for range b.N {
+		f, err := fsys.OpenFile("seq", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for written := 0; written < fileSize; written += len(data) {
+			if _, err := f.Write(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := f.Sync(); err != nil {
+			b.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// RandomReadAt measures 4 KiB reads at random offsets of a 1 MiB file.
+func RandomReadAt(b *testing.B, fsys wfs.FS) {
+	if err := wfs.WriteFile(fsys, "random", chunk(fileSize), 0644); err != nil {
+		b.Fatal(err)
+	}
+	f, err := fsys.OpenFile("random", os.O_RDONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	r := rand.New(rand.NewPCG(3, 4))
+	buf := make([]byte, readSize)
+	b.SetBytes(readSize)
+	b.ResetTimer()
+	for range b.N {
+		off := r.Int64N(fileSize - readSize)
+		if _, err := f.ReadAt(buf, off); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// SmallFileChurn measures creating, writing, statting and removing 1 KiB
+// files in a directory holding 128 files. Every iteration creates a file and
+// removes the oldest one.
+func SmallFileChurn(b *testing.B, fsys wfs.FS) {
+	if err := fsys.Mkdir("churn", 0755); err != nil {
+		b.Fatal(err)
+	}
+	data := chunk(smallSize)
+	name := func(i int) string { return fmt.Sprintf("churn/%08d", i) }
+	b.SetBytes(smallSize)
+	b.ResetTimer()
+	for i := range b.N {
+		if err := wfs.WriteFile(fsys, name(i), data, 0644); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := fs.Stat(fsys, name(i)); err != nil {
+			b.Fatal(err)
+		}
+		if i >= liveFiles {
+			if err := fsys.Remove(name(i - liveFiles)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// RemoveAllDeep measures RemoveAll of a tree 16 directories deep with 4 small
+// files in each directory. Building the tree is not measured.
+func RemoveAllDeep(b *testing.B, fsys wfs.FS) {
+	data := chunk(smallSize)
+	for range b.N {
+		b.StopTimer()
+		dir := "deep"
+		for range treeDepth {
+			dir = path.Join(dir, "d")
+			if err := fsys.MkdirAll(dir, 0755); err != nil {
+				b.Fatal(err)
+			}
+			for j := range treeWidth {
+				if err := wfs.WriteFile(fsys, path.Join(dir, fmt.Sprint(j)), data, 0644); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		b.StartTimer()
+		if err := fsys.RemoveAll("deep"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package wfsbench_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/wfsbench"
+)
+
+func BenchmarkMem(b *testing.B) {
+	wfsbench.Run(b, func() wfs.FS { return wfs.Mem() })
+}
+
+func BenchmarkDir(b *testing.B) {
+	wfsbench.Run(b, func() wfs.FS { return wfs.Dir(b.TempDir()) })
+}
+
+func BenchmarkMap(b *testing.B) {
+	wfsbench.Run(b, func() wfs.FS { return wfs.Map(fstest.MapFS{}) })
+}
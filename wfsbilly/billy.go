@@ -0,0 +1,241 @@
+package wfsbilly
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/eriicafes/wfs"
+	"github.com/go-git/go-billy/v5"
+)
+
+// filesystem adapts a [wfs.FS] to [billy.Filesystem].
+type filesystem struct {
+	fsys wfs.FS
+	root string
+}
+
+var (
+	_ billy.Filesystem = (*filesystem)(nil)
+	_ billy.Change     = (*filesystem)(nil)
+	_ billy.Capable    = (*filesystem)(nil)
+)
+
+// Filesystem returns a go-billy file system backed by fsys.
+//
+// As go-billy expects, missing parent directories are created with mode 0755
+// when files are created, renamed or linked, and names are cleaned so that
+// they are relative to the root and cannot escape it. Chroot returns the
+// file system of a subtree using [wfs.Sub], and files are locked with
+// [wfs.Lock] if fsys implements [wfs.LockFS] and not at all otherwise.
+func Filesystem(fsys wfs.FS) billy.Filesystem {
+	return &filesystem{fsys: fsys, root: "/"}
+}
+
+// name returns the name of filename in the file system.
+func name(filename string) string {
+	p := path.Clean("/" + filepath.ToSlash(filename))
+	if p == "/" {
+		return "."
+	}
+	return p[1:]
+}
+
+// osErr returns err with the errors of [io/fs] it matches wrapped directly,
+// as go-billy users test errors with [os.IsNotExist] and [os.IsExist], which
+// do not unwrap errors.
+func osErr(err error) error {
+	var pe *fs.PathError
+	if !errors.As(err, &pe) {
+		return err
+	}
+	for _, target := range []error{fs.ErrNotExist, fs.ErrExist} {
+		if pe.Err != target && errors.Is(pe.Err, target) {
+			return &fs.PathError{Op: pe.Op, Path: pe.Path, Err: target}
+		}
+	}
+	return err
+}
+
+// mkdirAll creates the parent directory of name.
+func (b *filesystem) mkdirAll(name string) error {
+	return b.fsys.MkdirAll(path.Dir(name), 0755)
+}
+
+func (b *filesystem) Create(filename string) (billy.File, error) {
+	return b.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (b *filesystem) Open(filename string) (billy.File, error) {
+	return b.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (b *filesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	n := name(filename)
+	if flag&os.O_CREATE != 0 {
+		if err := b.mkdirAll(n); err != nil {
+			return nil, osErr(err)
+		}
+	}
+	f, err := b.fsys.OpenFile(n, flag, perm)
+	if err != nil {
+		return nil, osErr(err)
+	}
+	return &billyFile{File: f, fs: b, name: filename, path: n}, nil
+}
+
+func (b *filesystem) Stat(filename string) (os.FileInfo, error) {
+	info, err := fs.Stat(b.fsys, name(filename))
+	return info, osErr(err)
+}
+
+func (b *filesystem) Rename(oldpath, newpath string) error {
+	n := name(newpath)
+	if err := b.mkdirAll(n); err != nil {
+		return osErr(err)
+	}
+	return osErr(b.fsys.Rename(name(oldpath), n))
+}
+
+func (b *filesystem) Remove(filename string) error {
+	return osErr(b.fsys.Remove(name(filename)))
+}
+
+func (b *filesystem) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (b *filesystem) TempFile(dir, prefix string) (billy.File, error) {
+	n := name(dir)
+	if err := b.fsys.MkdirAll(n, 0755); err != nil {
+		return nil, osErr(err)
+	}
+	f, err := wfs.CreateTemp(b.fsys, n, prefix+"*")
+	if err != nil {
+		return nil, osErr(err)
+	}
+	return &billyFile{File: f, fs: b, name: f.Name(), path: f.Name()}, nil
+}
+
+func (b *filesystem) ReadDir(dirname string) ([]os.FileInfo, error) {
+	entries, err := fs.ReadDir(b.fsys, name(dirname))
+	if err != nil {
+		return nil, osErr(err)
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			// removed since the directory was read
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (b *filesystem) MkdirAll(filename string, perm os.FileMode) error {
+	return osErr(b.fsys.MkdirAll(name(filename), perm))
+}
+
+func (b *filesystem) Lstat(filename string) (os.FileInfo, error) {
+	info, err := wfs.Lstat(b.fsys, name(filename))
+	return info, osErr(err)
+}
+
+func (b *filesystem) Symlink(target, link string) error {
+	n := name(link)
+	if err := b.mkdirAll(n); err != nil {
+		return osErr(err)
+	}
+	return osErr(wfs.Symlink(b.fsys, target, n))
+}
+
+func (b *filesystem) Readlink(link string) (string, error) {
+	target, err := wfs.Readlink(b.fsys, name(link))
+	return target, osErr(err)
+}
+
+func (b *filesystem) Chmod(filename string, mode os.FileMode) error {
+	return osErr(wfs.Chmod(b.fsys, name(filename), mode))
+}
+
+func (b *filesystem) Chown(filename string, uid, gid int) error {
+	return osErr(wfs.Chown(b.fsys, name(filename), uid, gid))
+}
+
+// Lchown is not supported as [wfs.MetaFS] follows symbolic links.
+func (b *filesystem) Lchown(filename string, uid, gid int) error {
+	return &fs.PathError{Op: "lchown", Path: filename, Err: errors.ErrUnsupported}
+}
+
+func (b *filesystem) Chtimes(filename string, atime, mtime time.Time) error {
+	return osErr(wfs.Chtimes(b.fsys, name(filename), atime, mtime))
+}
+
+func (b *filesystem) Chroot(dir string) (billy.Filesystem, error) {
+	n := name(dir)
+	sub, err := wfs.Sub(b.fsys, n)
+	if err != nil {
+		return nil, err
+	}
+	return &filesystem{fsys: sub, root: path.Join(b.root, n)}, nil
+}
+
+// Root returns the root of the file system as an absolute slash-separated
+// path from the root of the file system passed to [Filesystem].
+func (b *filesystem) Root() string {
+	return b.root
+}
+
+// Capabilities implements [billy.Capable], reporting LockCapability only
+// if the file system implements [wfs.LockFS].
+func (b *filesystem) Capabilities() billy.Capability {
+	caps := billy.DefaultCapabilities
+	if _, ok := b.fsys.(wfs.LockFS); !ok {
+		caps &^= billy.LockCapability
+	}
+	return caps
+}
+
+// billyFile adapts a [wfs.File] to [billy.File].
+type billyFile struct {
+	wfs.File
+	fs   *filesystem
+	name string // as presented to Open
+	path string // in the file system
+
+	lock wfs.Unlocker
+}
+
+func (f *billyFile) Name() string {
+	return f.name
+}
+
+func (f *billyFile) Lock() error {
+	if _, ok := f.fs.fsys.(wfs.LockFS); !ok || f.lock != nil {
+		return nil
+	}
+	l, err := wfs.Lock(f.fs.fsys, f.path)
+	if err != nil {
+		return err
+	}
+	f.lock = l
+	return nil
+}
+
+func (f *billyFile) Unlock() error {
+	if f.lock == nil {
+		return nil
+	}
+	l := f.lock
+	f.lock = nil
+	return l.Unlock()
+}
+
+func (f *billyFile) Close() error {
+	return errors.Join(f.File.Close(), f.Unlock())
+}
@@ -0,0 +1,181 @@
+package wfsbilly
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+
+	"github.com/eriicafes/wfs"
+	"github.com/go-git/go-billy/v5"
+)
+
+var errBadFile = errors.New("bad file descriptor")
+
+// file adapts a file of a go-billy file system to [wfs.File].
+type file struct {
+	billy.File
+	fs   *FS
+	name string
+
+	mu sync.Mutex // serializes writes at offsets emulated with Seek
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	if s, ok := f.File.(interface{ Stat() (fs.FileInfo, error) }); ok {
+		return s.Stat()
+	}
+	info, err := f.fs.fs.Stat(f.name)
+	if err != nil {
+		return nil, pathErr("stat", f.name, err)
+	}
+	return info, nil
+}
+
+func (f *file) ReadDir(n int) ([]fs.DirEntry, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: wfs.ErrNotDir}
+}
+
+func (f *file) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.File.Write(b)
+}
+
+func (f *file) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+// WriteAt writes with the WriteAt method of the file of go-billy if it has
+// one, or by seeking to off and back otherwise.
+func (f *file) WriteAt(b []byte, off int64) (int, error) {
+	if w, ok := f.File.(io.WriterAt); ok {
+		return w.WriteAt(b, off)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cur, err := f.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.File.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := f.File.Write(b)
+	if _, err1 := f.File.Seek(cur, io.SeekStart); err1 != nil && err == nil {
+		err = err1
+	}
+	return n, err
+}
+
+func (f *file) Sync() error {
+	if s, ok := f.File.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// dir is a directory of a go-billy file system, which go-billy does not
+// open as files.
+type dir struct {
+	fs   *FS
+	name string
+	info fs.FileInfo
+
+	mu      sync.Mutex
+	entries []fs.DirEntry // directory listing captured on the first ReadDir
+	offset  int
+	closed  bool
+}
+
+func (d *dir) Name() string {
+	return d.name
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, &fs.PathError{Op: "stat", Path: d.name, Err: fs.ErrClosed}
+	}
+	return d.info, nil
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrClosed}
+	}
+	if d.entries == nil {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+	}
+	entries := d.entries[d.offset:]
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
+
+func (d *dir) Seek(offset int64, whence int) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return 0, &fs.PathError{Op: "seek", Path: d.name, Err: fs.ErrClosed}
+	}
+	if offset != 0 || whence != io.SeekStart {
+		return 0, &fs.PathError{Op: "seek", Path: d.name, Err: fs.ErrInvalid}
+	}
+	d.entries, d.offset = nil, 0
+	return 0, nil
+}
+
+func (d *dir) Read(b []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: wfs.ErrIsDir}
+}
+
+func (d *dir) ReadAt(b []byte, off int64) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: wfs.ErrIsDir}
+}
+
+func (d *dir) Write(b []byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: d.name, Err: errBadFile}
+}
+
+func (d *dir) WriteString(s string) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: d.name, Err: errBadFile}
+}
+
+func (d *dir) WriteAt(b []byte, off int64) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: d.name, Err: errBadFile}
+}
+
+func (d *dir) Truncate(size int64) error {
+	return &fs.PathError{Op: "truncate", Path: d.name, Err: errBadFile}
+}
+
+func (d *dir) Sync() error {
+	return nil
+}
+
+func (d *dir) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return &fs.PathError{Op: "close", Path: d.name, Err: fs.ErrClosed}
+	}
+	d.closed = true
+	return nil
+}
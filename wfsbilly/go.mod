@@ -0,0 +1,15 @@
+module github.com/eriicafes/wfs/wfsbilly
+
+go 1.24.0
+
+replace github.com/eriicafes/wfs => ../
+
+require (
+	github.com/eriicafes/wfs v1.0.0
+	github.com/go-git/go-billy/v5 v5.8.0
+)
+
+require (
+	github.com/cyphar/filepath-securejoin v0.3.6 // indirect
+	golang.org/x/sys v0.40.0 // indirect
+)
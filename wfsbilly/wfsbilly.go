@@ -0,0 +1,331 @@
+// Package wfsbilly adapts file systems between wfs and go-billy, the file
+// system abstraction of go-git.
+//
+// [Filesystem] lets go-git clone into and operate on any wfs backend, such as
+// the in-memory file system or an S3 bucket, and [New] exposes a go-billy
+// file system, such as a go-git worktree, as a [wfs.FS].
+package wfsbilly
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eriicafes/wfs"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// FS is a writable file system backed by a go-billy file system.
+//
+// The semantics of wfs are enforced on top of go-billy, which creates
+// missing parent directories when files are created or renamed: the
+// containing directory of a new file must exist, Remove fails on non-empty
+// directories and Rename does not replace directories.
+type FS struct {
+	fs billy.Filesystem
+}
+
+// New returns a file system backed by bfs.
+func New(bfs billy.Filesystem) *FS {
+	return &FS{fs: bfs}
+}
+
+// pathErr wraps the error err of go-billy in a [*fs.PathError], replacing
+// the error of go-billy if it is already one, as the file systems of
+// go-billy may return plain errors.
+func pathErr(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// linkErr is like pathErr for the errors of operations on two files.
+func linkErr(op, oldname, newname string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return &os.LinkError{Op: op, Old: oldname, New: newname, Err: err}
+}
+
+// checkDir returns an error if the parent directory of name is not an
+// existing directory.
+func (f *FS) checkDir(name string) error {
+	dir := path.Dir(name)
+	if dir == "." {
+		return nil
+	}
+	info, err := f.fs.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return wfs.ErrNotDir
+	}
+	return nil
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for FS.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.fs.Stat(name)
+	if err != nil {
+		return nil, pathErr("stat", name, err)
+	}
+	return info, nil
+}
+
+// ReadDir implements [fs.ReadDirFS] for FS.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.fs.Stat(name)
+	if err == nil && !info.IsDir() {
+		err = wfs.ErrNotDir
+	}
+	if err != nil {
+		return nil, pathErr("readdir", name, err)
+	}
+	infos, err := f.fs.ReadDir(name)
+	if err != nil {
+		return nil, pathErr("readdir", name, err)
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (f *FS) OpenFile(name string, flag int, perm fs.FileMode) (wfs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.fs.Stat(name)
+	if err != nil && flag&os.O_CREATE != 0 {
+		// a missing parent is reported instead of being created
+		if err := f.checkDir(name); err != nil {
+			return nil, pathErr("open", name, err)
+		}
+	}
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && flag&os.O_CREATE != 0:
+	case err != nil:
+		return nil, pathErr("open", name, err)
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case info.IsDir() && flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: wfs.ErrIsDir}
+	case info.IsDir():
+		return &dir{fs: f, name: name, info: info}, nil
+	}
+	bf, err := f.fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, pathErr("open", name, err)
+	}
+	return &file{File: bf, fs: f, name: name}, nil
+}
+
+func (f *FS) Rename(oldpath, newpath string) error {
+	if !fs.ValidPath(oldpath) || !fs.ValidPath(newpath) || oldpath == "." || newpath == "." {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	return linkErr("rename", oldpath, newpath, f.rename(oldpath, newpath))
+}
+
+func (f *FS) rename(oldpath, newpath string) error {
+	info, err := f.fs.Lstat(oldpath)
+	if err != nil {
+		return err
+	}
+	if err := f.checkDir(newpath); err != nil {
+		return err
+	}
+	if oldpath == newpath {
+		return nil
+	}
+	// a directory cannot be moved into itself
+	if info.IsDir() && strings.HasPrefix(newpath, oldpath+"/") {
+		return fs.ErrInvalid
+	}
+	if target, err := f.fs.Lstat(newpath); err == nil {
+		if target.IsDir() {
+			return fs.ErrExist
+		}
+		if info.IsDir() {
+			return wfs.ErrNotDir
+		}
+		if err := f.fs.Remove(newpath); err != nil {
+			return err
+		}
+	}
+	return f.fs.Rename(oldpath, newpath)
+}
+
+func (f *FS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.fs.Lstat(name)
+	if err == nil && info.IsDir() {
+		var infos []fs.FileInfo
+		infos, err = f.fs.ReadDir(name)
+		if err == nil && len(infos) > 0 {
+			err = wfs.ErrNotEmpty
+		}
+	}
+	if err == nil {
+		err = f.fs.Remove(name)
+	}
+	return pathErr("remove", name, err)
+}
+
+func (f *FS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "RemoveAll", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, err := f.fs.Lstat(name); errors.Is(err, fs.ErrNotExist) {
+		// nothing to remove
+		return nil
+	}
+	return pathErr("RemoveAll", name, util.RemoveAll(f.fs, name))
+}
+
+func (f *FS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	_, err := f.fs.Lstat(name)
+	switch {
+	case err == nil:
+		err = fs.ErrExist
+	case errors.Is(err, fs.ErrNotExist):
+		err = f.checkDir(name)
+		if err == nil {
+			err = f.fs.MkdirAll(name, perm&fs.ModePerm)
+		}
+	}
+	return pathErr("mkdir", name, err)
+}
+
+func (f *FS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return nil
+	}
+	// go-billy may replace a file in the way with a directory
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if info, err := f.fs.Stat(dir); err == nil {
+			if !info.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: name, Err: wfs.ErrNotDir}
+			}
+			break
+		}
+	}
+	return pathErr("mkdir", name, f.fs.MkdirAll(name, perm&fs.ModePerm))
+}
+
+// Symlink implements [wfs.SymlinkFS] for FS.
+func (f *FS) Symlink(oldname, newname string) error {
+	if !fs.ValidPath(newname) || newname == "." {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	err := f.checkDir(newname)
+	if err == nil {
+		if _, err = f.fs.Lstat(newname); err == nil {
+			err = fs.ErrExist
+		} else if errors.Is(err, fs.ErrNotExist) {
+			err = f.fs.Symlink(oldname, newname)
+		}
+	}
+	return linkErr("symlink", oldname, newname, err)
+}
+
+// Readlink implements [wfs.SymlinkFS] for FS.
+func (f *FS) Readlink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	target, err := f.fs.Readlink(name)
+	if err != nil {
+		return "", pathErr("readlink", name, err)
+	}
+	return target, nil
+}
+
+// Lstat implements [wfs.SymlinkFS] for FS.
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.fs.Lstat(name)
+	if err != nil {
+		return nil, pathErr("lstat", name, err)
+	}
+	return info, nil
+}
+
+// Chmod implements [wfs.MetaFS] for FS. It returns an error wrapping
+// [errors.ErrUnsupported] if the go-billy file system does not implement
+// [billy.Chmod].
+func (f *FS) Chmod(name string, mode fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrInvalid}
+	}
+	c, ok := f.fs.(billy.Chmod)
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: errors.ErrUnsupported}
+	}
+	return pathErr("chmod", name, c.Chmod(name, mode))
+}
+
+// Chown implements [wfs.MetaFS] for FS. It returns an error wrapping
+// [errors.ErrUnsupported] if the go-billy file system does not implement
+// [billy.Change].
+func (f *FS) Chown(name string, uid, gid int) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "chown", Path: name, Err: fs.ErrInvalid}
+	}
+	c, ok := f.fs.(billy.Change)
+	if !ok {
+		return &fs.PathError{Op: "chown", Path: name, Err: errors.ErrUnsupported}
+	}
+	return pathErr("chown", name, c.Chown(name, uid, gid))
+}
+
+// Chtimes implements [wfs.MetaFS] for FS. It returns an error wrapping
+// [errors.ErrUnsupported] if the go-billy file system does not implement
+// [billy.Change].
+func (f *FS) Chtimes(name string, atime, mtime time.Time) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrInvalid}
+	}
+	c, ok := f.fs.(billy.Change)
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: errors.ErrUnsupported}
+	}
+	return pathErr("chtimes", name, c.Chtimes(name, atime, mtime))
+}
@@ -0,0 +1,200 @@
+package wfsbilly_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/wfsbilly"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func assertContent(t *testing.T, fsys fs.FS, name, expected string) {
+	t.Helper()
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil || string(data) != expected {
+		t.Errorf("expected %q to contain %q, got %q err: %v", name, expected, data, err)
+	}
+}
+
+func assertEntries(t *testing.T, fsys fs.FS, dir string, expected ...string) {
+	t.Helper()
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		t.Fatalf("failed to read %q: %v", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %q to contain %v, got %v", dir, expected, names)
+	}
+}
+
+func TestFS(t *testing.T) {
+	backend := osfs.New(t.TempDir())
+	fsys := wfsbilly.New(backend)
+
+	if err := fsys.MkdirAll("a/b/c", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "a/b/file.txt", []byte("hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "top.txt", []byte("top"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := fstest.TestFS(fsys, "a/b/file.txt", "a/b/c", "top.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := util.ReadFile(backend, "a/b/file.txt"); err != nil || string(data) != "hello" {
+		t.Errorf("expected %q in the go-billy file system, got %q err: %v", "hello", data, err)
+	}
+
+	if _, err := fsys.OpenFile("top.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+	if _, err := fsys.OpenFile("missing/file", os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if _, err := fsys.OpenFile("top.txt/file", os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("expected ErrNotDir, got %v", err)
+	}
+	if _, err := fsys.OpenFile("a", os.O_WRONLY, 0); !errors.Is(err, wfs.ErrIsDir) {
+		t.Errorf("expected ErrIsDir, got %v", err)
+	}
+	if err := fsys.MkdirAll("top.txt/dir", 0755); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("expected ErrNotDir, got %v", err)
+	}
+	if err := fsys.Remove("a/b"); !errors.Is(err, wfs.ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty, got %v", err)
+	}
+	if err := fsys.Mkdir("a", 0755); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+	if err := fsys.Rename("top.txt", "a/b/c"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+
+	f, err := fsys.OpenFile("top.txt", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if _, err := f.WriteAt([]byte("T"), 0); err != nil {
+		t.Fatalf("WriteAt failed: %v", err)
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	io.WriteString(f, "!")
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	assertContent(t, fsys, "top.txt", "Top!")
+
+	if err := fsys.Rename("a/b", "moved"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := fsys.Rename("top.txt", "moved/file.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	assertEntries(t, fsys, ".", "a", "moved")
+	assertContent(t, fsys, "moved/file.txt", "Top!")
+
+	if err := fsys.RemoveAll("moved"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if err := fsys.Remove("a"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	assertEntries(t, fsys, ".")
+}
+
+func TestFilesystem(t *testing.T) {
+	backend := wfs.Mem()
+	bfs := wfsbilly.Filesystem(backend)
+
+	// parent directories are created as go-billy expects
+	if err := util.WriteFile(bfs, "/a/b/file.txt", []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	assertContent(t, backend, "a/b/file.txt", "hello")
+	if _, err := bfs.Stat(bfs.Join("a", "missing")); !os.IsNotExist(err) {
+		t.Errorf("expected an error matching os.IsNotExist, got %v", err)
+	}
+	if _, err := bfs.OpenFile("a/b/file.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); !os.IsExist(err) {
+		t.Errorf("expected an error matching os.IsExist, got %v", err)
+	}
+
+	// go-git writes objects to temporary files and renames them
+	tmp, err := bfs.TempFile("objects/pack", "tmp_")
+	if err != nil {
+		t.Fatalf("TempFile failed: %v", err)
+	}
+	tmp.Write([]byte("object"))
+	if err := tmp.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := bfs.Rename(tmp.Name(), "objects/ab/cdef"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	assertContent(t, backend, "objects/ab/cdef", "object")
+	assertEntries(t, backend, "objects/pack")
+
+	// chroots share the underlying file system and cannot escape it
+	git, err := bfs.Chroot(".git")
+	if err != nil {
+		t.Fatalf("Chroot failed: %v", err)
+	}
+	if root := git.Root(); root != "/.git" {
+		t.Errorf("expected root %q, got %q", "/.git", root)
+	}
+	if err := util.WriteFile(git, "../HEAD", []byte("ref: refs/heads/main\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	assertContent(t, backend, ".git/HEAD", "ref: refs/heads/main\n")
+	infos, err := bfs.ReadDir(".git")
+	if err != nil || len(infos) != 1 || infos[0].Name() != "HEAD" {
+		t.Errorf("expected .git to contain HEAD, got %v err: %v", infos, err)
+	}
+
+	f, err := git.OpenFile("index", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if !billy.CapabilityCheck(git, billy.LockCapability) {
+		t.Errorf("expected the lock capability of an in-memory file system")
+	}
+	if err := f.Lock(); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if _, err := wfs.TryLock(backend, ".git/index"); !errors.Is(err, wfs.ErrLocked) {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	l, err := wfs.TryLock(backend, ".git/index")
+	if err != nil {
+		t.Fatalf("expected the lock to be released on close: %v", err)
+	}
+	l.Unlock()
+
+	if err := git.Symlink("HEAD", "link"); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	if target, err := git.Readlink("link"); err != nil || target != "HEAD" {
+		t.Errorf("expected target %q, got %q err: %v", "HEAD", target, err)
+	}
+	if info, err := git.Lstat("link"); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("expected a symbolic link, got %v err: %v", info, err)
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/eriicafes/wfs/wfsfuse
+
+go 1.24.0
+
+replace github.com/eriicafes/wfs => ../
+
+require (
+	github.com/eriicafes/wfs v1.0.0
+	github.com/hanwen/go-fuse/v2 v2.9.0
+)
+
+require golang.org/x/sys v0.40.0 // indirect
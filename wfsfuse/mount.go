@@ -0,0 +1,373 @@
+//go:build linux || darwin || freebsd
+
+package wfsfuse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+
+	"github.com/eriicafes/wfs"
+	fusefs "github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// Mount mounts fsys at mountpoint and serves it in the background until it
+// is unmounted.
+//
+// Attributes and directory entries are not cached by the kernel, so changes
+// made to fsys directly are visible immediately. Files are opened without
+// [os.O_APPEND] as the kernel passes the offsets of appends, and symbolic
+// links are supported if fsys implements [wfs.SymlinkFS].
+func Mount(mountpoint string, fsys wfs.FS) (*Server, error) {
+	root := &node{fsys: fsys}
+	server, err := fusefs.Mount(mountpoint, root, &fusefs.Options{
+		MountOptions: fuse.MountOptions{
+			FsName:      "wfs",
+			Name:        "wfs",
+			DirectMount: true,
+		},
+		UID: uint32(os.Getuid()),
+		GID: uint32(os.Getgid()),
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: "mount", Path: mountpoint, Err: err}
+	}
+	return &Server{wait: server.Wait, unmount: server.Unmount}, nil
+}
+
+// errnos maps errors to the errnos they are reported with. The errors of
+// wfs come first as platform errors such as ENOTEMPTY also match errors of
+// [io/fs].
+var errnos = []struct {
+	err   error
+	errno syscall.Errno
+}{
+	{wfs.ErrNotEmpty, syscall.ENOTEMPTY},
+	{wfs.ErrIsDir, syscall.EISDIR},
+	{wfs.ErrNotDir, syscall.ENOTDIR},
+	{fs.ErrNotExist, syscall.ENOENT},
+	{fs.ErrExist, syscall.EEXIST},
+	{fs.ErrPermission, syscall.EACCES},
+	{fs.ErrInvalid, syscall.EINVAL},
+	{errors.ErrUnsupported, syscall.ENOTSUP},
+}
+
+// errno returns the errno err is reported with.
+func errno(err error) syscall.Errno {
+	if err == nil {
+		return 0
+	}
+	var e syscall.Errno
+	if errors.As(err, &e) {
+		return e
+	}
+	for _, m := range errnos {
+		if errors.Is(err, m.err) {
+			return m.errno
+		}
+	}
+	return syscall.EIO
+}
+
+// fileType returns the file type bits of the mode of info.
+func fileType(info fs.FileInfo) uint32 {
+	switch {
+	case info.IsDir():
+		return syscall.S_IFDIR
+	case info.Mode()&fs.ModeSymlink != 0:
+		return syscall.S_IFLNK
+	}
+	return syscall.S_IFREG
+}
+
+// setAttr sets the attributes of out from info.
+func setAttr(out *fuse.Attr, info fs.FileInfo) {
+	out.Mode = fileType(info) | uint32(info.Mode().Perm())
+	out.Size = uint64(info.Size())
+	out.Blocks = (out.Size + 511) / 512
+	out.Nlink = 1
+	mtime := info.ModTime()
+	out.SetTimes(nil, &mtime, nil)
+}
+
+// node is a file of the mounted file system.
+type node struct {
+	fusefs.Inode
+	fsys wfs.FS
+}
+
+var (
+	_ fusefs.NodeGetattrer  = (*node)(nil)
+	_ fusefs.NodeSetattrer  = (*node)(nil)
+	_ fusefs.NodeLookuper   = (*node)(nil)
+	_ fusefs.NodeReaddirer  = (*node)(nil)
+	_ fusefs.NodeOpener     = (*node)(nil)
+	_ fusefs.NodeCreater    = (*node)(nil)
+	_ fusefs.NodeMkdirer    = (*node)(nil)
+	_ fusefs.NodeUnlinker   = (*node)(nil)
+	_ fusefs.NodeRmdirer    = (*node)(nil)
+	_ fusefs.NodeRenamer    = (*node)(nil)
+	_ fusefs.NodeSymlinker  = (*node)(nil)
+	_ fusefs.NodeReadlinker = (*node)(nil)
+)
+
+// name returns the name of n in the file system.
+func (n *node) name() string {
+	if p := n.Path(n.Root()); p != "" {
+		return p
+	}
+	return "."
+}
+
+// child returns a new inode for a file of n with info, setting the
+// attributes of out.
+func (n *node) child(ctx context.Context, info fs.FileInfo, out *fuse.EntryOut) *fusefs.Inode {
+	setAttr(&out.Attr, info)
+	return n.NewInode(ctx, &node{fsys: n.fsys}, fusefs.StableAttr{Mode: fileType(info)})
+}
+
+func (n *node) Getattr(ctx context.Context, fh fusefs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	var info fs.FileInfo
+	var err error
+	if h, ok := fh.(*handle); ok {
+		info, err = h.file.Stat()
+	} else {
+		info, err = wfs.Lstat(n.fsys, n.name())
+	}
+	if err != nil {
+		return errno(err)
+	}
+	setAttr(&out.Attr, info)
+	return 0
+}
+
+// Setattr changes the size, permissions and modification time of the file.
+// Changes of owners are ignored.
+func (n *node) Setattr(ctx context.Context, fh fusefs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	name := n.name()
+	if size, ok := in.GetSize(); ok {
+		var err error
+		if h, ok := fh.(*handle); ok && h.writable {
+			err = h.file.Truncate(int64(size))
+		} else {
+			err = truncate(n.fsys, name, int64(size))
+		}
+		if err != nil {
+			return errno(err)
+		}
+	}
+	if mode, ok := in.GetMode(); ok {
+		if err := wfs.Chmod(n.fsys, name, fs.FileMode(mode).Perm()); err != nil {
+			return errno(err)
+		}
+	}
+	if mtime, ok := in.GetMTime(); ok {
+		atime, _ := in.GetATime()
+		if err := wfs.Chtimes(n.fsys, name, atime, mtime); err != nil {
+			return errno(err)
+		}
+	}
+	return n.Getattr(ctx, fh, out)
+}
+
+// truncate changes the size of the file name.
+func truncate(fsys wfs.FS, name string, size int64) error {
+	f, err := fsys.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	err = f.Truncate(size)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
+func (n *node) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fusefs.Inode, syscall.Errno) {
+	info, err := wfs.Lstat(n.fsys, path.Join(n.name(), name))
+	if err != nil {
+		return nil, errno(err)
+	}
+	return n.child(ctx, info, out), 0
+}
+
+func (n *node) Readdir(ctx context.Context) (fusefs.DirStream, syscall.Errno) {
+	entries, err := fs.ReadDir(n.fsys, n.name())
+	if err != nil {
+		return nil, errno(err)
+	}
+	list := make([]fuse.DirEntry, len(entries))
+	for i, e := range entries {
+		mode := uint32(syscall.S_IFREG)
+		switch {
+		case e.IsDir():
+			mode = syscall.S_IFDIR
+		case e.Type()&fs.ModeSymlink != 0:
+			mode = syscall.S_IFLNK
+		}
+		list[i] = fuse.DirEntry{Name: e.Name(), Mode: mode}
+	}
+	return fusefs.NewListDirStream(list), 0
+}
+
+// openFlag returns the flag files are opened with for the flags of an
+// open request.
+func openFlag(flags uint32) int {
+	return int(flags) & (os.O_WRONLY | os.O_RDWR | os.O_CREATE | os.O_EXCL | os.O_TRUNC)
+}
+
+func (n *node) Open(ctx context.Context, flags uint32) (fusefs.FileHandle, uint32, syscall.Errno) {
+	f, err := n.fsys.OpenFile(n.name(), openFlag(flags), 0)
+	if err != nil {
+		return nil, 0, errno(err)
+	}
+	return newHandle(f, openFlag(flags)), 0, 0
+}
+
+func (n *node) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fusefs.Inode, fusefs.FileHandle, uint32, syscall.Errno) {
+	flag := openFlag(flags) | os.O_CREATE
+	f, err := n.fsys.OpenFile(path.Join(n.name(), name), flag, fs.FileMode(mode).Perm())
+	if err != nil {
+		return nil, nil, 0, errno(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, 0, errno(err)
+	}
+	return n.child(ctx, info, out), newHandle(f, flag), 0, 0
+}
+
+func (n *node) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fusefs.Inode, syscall.Errno) {
+	name = path.Join(n.name(), name)
+	if err := n.fsys.Mkdir(name, fs.FileMode(mode).Perm()); err != nil {
+		return nil, errno(err)
+	}
+	info, err := wfs.Lstat(n.fsys, name)
+	if err != nil {
+		return nil, errno(err)
+	}
+	return n.child(ctx, info, out), 0
+}
+
+func (n *node) Unlink(ctx context.Context, name string) syscall.Errno {
+	name = path.Join(n.name(), name)
+	info, err := wfs.Lstat(n.fsys, name)
+	if err == nil && info.IsDir() {
+		return syscall.EISDIR
+	}
+	return errno(n.fsys.Remove(name))
+}
+
+func (n *node) Rmdir(ctx context.Context, name string) syscall.Errno {
+	name = path.Join(n.name(), name)
+	info, err := wfs.Lstat(n.fsys, name)
+	if err == nil && !info.IsDir() {
+		return syscall.ENOTDIR
+	}
+	return errno(n.fsys.Remove(name))
+}
+
+// renameNoReplace is the RENAME_NOREPLACE flag of renameat2.
+const renameNoReplace = 0x1
+
+func (n *node) Rename(ctx context.Context, name string, newParent fusefs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	oldpath := path.Join(n.name(), name)
+	newpath := path.Join(newParent.(*node).name(), newName)
+	if flags&^renameNoReplace != 0 {
+		return syscall.ENOTSUP
+	}
+	if flags&renameNoReplace != 0 {
+		if _, err := wfs.Lstat(n.fsys, newpath); err == nil {
+			return syscall.EEXIST
+		}
+	}
+	return errno(n.fsys.Rename(oldpath, newpath))
+}
+
+func (n *node) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (*fusefs.Inode, syscall.Errno) {
+	name = path.Join(n.name(), name)
+	if err := wfs.Symlink(n.fsys, target, name); err != nil {
+		return nil, errno(err)
+	}
+	info, err := wfs.Lstat(n.fsys, name)
+	if err != nil {
+		return nil, errno(err)
+	}
+	return n.child(ctx, info, out), 0
+}
+
+func (n *node) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	target, err := wfs.Readlink(n.fsys, n.name())
+	if err != nil {
+		return nil, errno(err)
+	}
+	return []byte(target), 0
+}
+
+// handle is an open file of the mounted file system.
+type handle struct {
+	file     wfs.File
+	writable bool
+
+	mu     sync.Mutex
+	closed bool
+}
+
+var (
+	_ fusefs.FileReader   = (*handle)(nil)
+	_ fusefs.FileWriter   = (*handle)(nil)
+	_ fusefs.FileFlusher  = (*handle)(nil)
+	_ fusefs.FileFsyncer  = (*handle)(nil)
+	_ fusefs.FileReleaser = (*handle)(nil)
+)
+
+func newHandle(f wfs.File, flag int) *handle {
+	return &handle{file: f, writable: flag&(os.O_WRONLY|os.O_RDWR) != 0}
+}
+
+func (h *handle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	n, err := h.file.ReadAt(dest, off)
+	if err != nil && err != io.EOF {
+		return nil, errno(err)
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+func (h *handle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	n, err := h.file.WriteAt(data, off)
+	if err != nil {
+		return uint32(n), errno(err)
+	}
+	return uint32(n), 0
+}
+
+// Flush syncs files opened for writing when a descriptor of them is closed,
+// so that errors of file systems that upload files when they are synced are
+// reported by close.
+func (h *handle) Flush(ctx context.Context) syscall.Errno {
+	if !h.writable {
+		return 0
+	}
+	return errno(h.file.Sync())
+}
+
+func (h *handle) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	return errno(h.file.Sync())
+}
+
+func (h *handle) Release(ctx context.Context) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return 0
+	}
+	h.closed = true
+	return errno(h.file.Close())
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd
+
+package wfsfuse
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/eriicafes/wfs"
+)
+
+// Mount mounts fsys at mountpoint. FUSE is not supported on this platform,
+// so it returns an error that wraps [errors.ErrUnsupported].
+func Mount(mountpoint string, fsys wfs.FS) (*Server, error) {
+	return nil, &fs.PathError{Op: "mount", Path: mountpoint, Err: errors.ErrUnsupported}
+}
@@ -0,0 +1,23 @@
+// Package wfsfuse mounts a file system with FUSE, so that its contents can
+// be browsed and edited with ordinary tools during development.
+//
+// Mounting is supported on Linux, macOS and FreeBSD, and requires the FUSE
+// kernel module and either the fusermount helper or the privileges to
+// mount file systems.
+package wfsfuse
+
+// Server serves a mounted file system until it is unmounted.
+type Server struct {
+	wait    func()
+	unmount func() error
+}
+
+// Wait blocks until the file system is unmounted.
+func (s *Server) Wait() {
+	s.wait()
+}
+
+// Unmount unmounts the file system, which fails if it is in use.
+func (s *Server) Unmount() error {
+	return s.unmount()
+}
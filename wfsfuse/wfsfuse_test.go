@@ -0,0 +1,114 @@
+//go:build linux
+
+package wfsfuse_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/wfsfuse"
+)
+
+func assertContent(t *testing.T, fsys fs.FS, name, expected string) {
+	t.Helper()
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil || string(data) != expected {
+		t.Errorf("expected %q to contain %q, got %q err: %v", name, expected, data, err)
+	}
+}
+
+func assertEntries(t *testing.T, fsys fs.FS, dir string, expected ...string) {
+	t.Helper()
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		t.Fatalf("failed to read %q: %v", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %q to contain %v, got %v", dir, expected, names)
+	}
+}
+
+// mount mounts fsys in a temporary directory, skipping the test if FUSE is
+// not available.
+func mount(t *testing.T, fsys wfs.FS) string {
+	t.Helper()
+	dir := t.TempDir()
+	server, err := wfsfuse.Mount(dir, fsys)
+	if err != nil {
+		t.Skipf("FUSE is not available: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := server.Unmount(); err != nil {
+			t.Errorf("Unmount failed: %v", err)
+		}
+	})
+	return dir
+}
+
+func TestMount(t *testing.T) {
+	backend := wfs.Mem()
+	if err := wfs.WriteFile(backend, "hello.txt", []byte("hello world"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	dir := mount(t, backend)
+
+	data, err := os.ReadFile(filepath.Join(dir, "hello.txt"))
+	if err != nil || string(data) != "hello world" {
+		t.Errorf("expected %q, got %q err: %v", "hello world", data, err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "dir"), 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dir", "new.txt"), []byte("new"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	assertContent(t, backend, "dir/new.txt", "new")
+	if info, err := fs.Stat(backend, "dir/new.txt"); err != nil || info.Mode() != 0600 {
+		t.Errorf("expected mode 0600, got %v err: %v", info.Mode(), err)
+	}
+
+	// appends and truncation go through offsets and sizes
+	f, err := os.OpenFile(filepath.Join(dir, "hello.txt"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.Write([]byte("!"))
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	assertContent(t, backend, "hello.txt", "hello world!")
+	if err := os.Truncate(filepath.Join(dir, "hello.txt"), 5); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	assertContent(t, backend, "hello.txt", "hello")
+
+	if err := os.Rename(filepath.Join(dir, "hello.txt"), filepath.Join(dir, "dir", "moved.txt")); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	assertEntries(t, backend, "dir", "moved.txt", "new.txt")
+	entries, err := os.ReadDir(filepath.Join(dir, "dir"))
+	if err != nil || len(entries) != 2 {
+		t.Errorf("expected 2 entries, got %v err: %v", entries, err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "dir")); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Errorf("expected ENOTEMPTY, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "dir")); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	assertEntries(t, backend, ".")
+}
@@ -0,0 +1,241 @@
+package wfsgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eriicafes/wfs"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FS is a writable file system backed by a FileSystem service, such as
+// a [Server].
+//
+// Files opened for reading are streamed from the service. Files opened for
+// writing are buffered in memory, starting with the contents of the file
+// unless it is truncated, and streamed to the service when they are synced
+// or closed, replacing the file. Created files are created on the service
+// when they are opened so that they exist.
+type FS struct {
+	client FileSystemClient
+}
+
+// Client returns an FS using the FileSystem service of conn.
+func Client(conn grpc.ClientConnInterface) *FS {
+	return &FS{client: NewFileSystemClient(conn)}
+}
+
+// fileInfo describes a remote file.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *fileInfo) Sys() any           { return nil }
+
+func (x *FileInfo) info() *fileInfo {
+	return &fileInfo{name: x.GetName(), size: x.GetSize(), mode: fs.FileMode(x.GetMode()), modTime: x.GetModTime().AsTime()}
+}
+
+// clientError returns the error named by the ErrorInfo detail or code of
+// the status of err.
+func clientError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == errorDomain {
+			for _, e := range errorCodes {
+				if info.GetReason() == e.reason {
+					return e.err
+				}
+			}
+		}
+	}
+	switch st.Code() {
+	case codes.NotFound:
+		return fs.ErrNotExist
+	case codes.AlreadyExists:
+		return fs.ErrExist
+	case codes.PermissionDenied:
+		return fs.ErrPermission
+	case codes.InvalidArgument:
+		return fs.ErrInvalid
+	}
+	return err
+}
+
+// stat returns the info of name without wrapping errors.
+func (f *FS) stat(ctx context.Context, name string) (*fileInfo, error) {
+	resp, err := f.client.Stat(ctx, &StatRequest{Name: name})
+	if err != nil {
+		return nil, clientError(err)
+	}
+	return resp.info(), nil
+}
+
+// readDir returns the entries of the directory name without wrapping errors.
+func (f *FS) readDir(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	resp, err := f.client.ReadDir(ctx, &ReadDirRequest{Name: name})
+	if err != nil {
+		return nil, clientError(err)
+	}
+	entries := make([]fs.DirEntry, len(resp.GetEntries()))
+	for i, e := range resp.GetEntries() {
+		entries[i] = fs.FileInfoToDirEntry(e.info())
+	}
+	return entries, nil
+}
+
+// write streams the contents of r to the file opened with header h.
+func (f *FS) write(ctx context.Context, h *WriteHeader, r io.Reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := f.client.Write(ctx)
+	if err != nil {
+		return clientError(err)
+	}
+	req := &WriteRequest{Header: h}
+	buf := make([]byte, chunkSize)
+	for {
+		n, rerr := io.ReadFull(r, buf)
+		if rerr != nil && rerr != io.EOF && rerr != io.ErrUnexpectedEOF {
+			return rerr
+		}
+		req.Data = buf[:n]
+		// a failed send is reported by CloseAndRecv
+		if err := stream.Send(req); err != nil || rerr != nil {
+			break
+		}
+		req = &WriteRequest{}
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return clientError(err)
+	}
+	return nil
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for FS.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := f.stat(context.Background(), name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// ReadDir implements [fs.ReadDirFS] for FS.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := f.readDir(context.Background(), name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+func (f *FS) OpenFile(name string, flag int, perm fs.FileMode) (wfs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	ctx := context.Background()
+	info, err := f.stat(ctx, name)
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0
+	switch {
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		err = fs.ErrExist
+	case err == nil && info.IsDir() && flag&(os.O_WRONLY|os.O_RDWR) != 0:
+		err = wfs.ErrIsDir
+	case err == nil && (!write || info.IsDir()):
+		return &file{fs: f, name: name, info: info}, nil
+	case err == nil:
+		var w *writer
+		if w, err = f.openWriter(ctx, name, info.mode, flag, flag&os.O_TRUNC == 0); err == nil {
+			return w, nil
+		}
+	case errors.Is(err, fs.ErrNotExist) && flag&os.O_CREATE != 0:
+		h := &WriteHeader{Name: name, Create: true, Exclusive: flag&os.O_EXCL != 0, Perm: uint32(perm.Perm())}
+		if err = f.write(ctx, h, strings.NewReader("")); err == nil {
+			var w *writer
+			if w, err = f.openWriter(ctx, name, perm.Perm(), flag, false); err == nil {
+				return w, nil
+			}
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+}
+
+func (f *FS) Rename(oldpath, newpath string) error {
+	if !fs.ValidPath(oldpath) || !fs.ValidPath(newpath) || oldpath == "." || newpath == "." {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	if _, err := f.client.Rename(context.Background(), &RenameRequest{OldName: oldpath, NewName: newpath}); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: clientError(err)}
+	}
+	return nil
+}
+
+func (f *FS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, err := f.client.Remove(context.Background(), &RemoveRequest{Name: name}); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: clientError(err)}
+	}
+	return nil
+}
+
+func (f *FS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "RemoveAll", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, err := f.client.Remove(context.Background(), &RemoveRequest{Name: name, All: true}); err != nil {
+		return &fs.PathError{Op: "RemoveAll", Path: name, Err: clientError(err)}
+	}
+	return nil
+}
+
+func (f *FS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, err := f.client.Mkdir(context.Background(), &MkdirRequest{Name: name, Perm: uint32(perm.Perm())}); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: clientError(err)}
+	}
+	return nil
+}
+
+func (f *FS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, err := f.client.Mkdir(context.Background(), &MkdirRequest{Name: name, Perm: uint32(perm.Perm()), All: true}); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: clientError(err)}
+	}
+	return nil
+}
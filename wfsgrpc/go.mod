@@ -0,0 +1,18 @@
+module github.com/eriicafes/wfs/wfsgrpc
+
+go 1.24.0
+
+replace github.com/eriicafes/wfs => ../
+
+require (
+	github.com/eriicafes/wfs v1.0.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260120221211-b8f7ae30c516
+	google.golang.org/grpc v1.80.0
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	golang.org/x/net v0.49.0 // indirect
+	golang.org/x/sys v0.40.0 // indirect
+	golang.org/x/text v0.33.0 // indirect
+)
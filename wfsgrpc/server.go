@@ -0,0 +1,231 @@
+package wfsgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/eriicafes/wfs"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type server struct {
+	UnimplementedFileSystemServer
+	fsys wfs.FS
+}
+
+// Server returns a FileSystemServer serving fsys, to be registered on a
+// gRPC server with [RegisterFileSystemServer].
+func Server(fsys wfs.FS) FileSystemServer {
+	return &server{fsys: fsys}
+}
+
+func newFileInfo(info fs.FileInfo) *FileInfo {
+	return &FileInfo{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		Mode:    uint32(info.Mode()),
+		ModTime: timestamppb.New(info.ModTime()),
+	}
+}
+
+// statusError returns the status of err, naming it in an ErrorInfo detail
+// if it is one of errorCodes.
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	// paths are omitted as they are known to the client
+	msg := err.Error()
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case errors.As(err, &pathErr):
+		msg = pathErr.Err.Error()
+	case errors.As(err, &linkErr):
+		msg = linkErr.Err.Error()
+	}
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			st, derr := status.New(e.code, msg).WithDetails(&errdetails.ErrorInfo{Reason: e.reason, Domain: errorDomain})
+			if derr != nil {
+				return status.Error(e.code, msg)
+			}
+			return st.Err()
+		}
+	}
+	return status.Error(codes.Unknown, msg)
+}
+
+func (s *server) Stat(ctx context.Context, req *StatRequest) (*FileInfo, error) {
+	info, err := fs.Stat(s.fsys, req.GetName())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return newFileInfo(info), nil
+}
+
+func (s *server) ReadDir(ctx context.Context, req *ReadDirRequest) (*ReadDirResponse, error) {
+	entries, err := fs.ReadDir(s.fsys, req.GetName())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &ReadDirResponse{Entries: make([]*FileInfo, 0, len(entries))}
+	for _, e := range entries {
+		info, err := e.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, statusError(err)
+		}
+		resp.Entries = append(resp.Entries, newFileInfo(info))
+	}
+	return resp, nil
+}
+
+func (s *server) Read(req *ReadRequest, stream FileSystem_ReadServer) error {
+	f, err := s.fsys.Open(req.GetName())
+	if err != nil {
+		return statusError(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return statusError(err)
+	}
+	if info.IsDir() {
+		return statusError(wfs.ErrIsDir)
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok || req.GetOffset() < 0 {
+		return statusError(fs.ErrInvalid)
+	}
+	if _, err := rs.Seek(req.GetOffset(), io.SeekStart); err != nil {
+		return statusError(err)
+	}
+	var r io.Reader = rs
+	if req.GetLength() > 0 {
+		r = io.LimitReader(rs, req.GetLength())
+	}
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := stream.Send(&ReadResponse{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return statusError(err)
+		}
+	}
+}
+
+func (s *server) Write(stream FileSystem_WriteServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	h := req.GetHeader()
+	if h == nil {
+		return status.Error(codes.InvalidArgument, "missing write header")
+	}
+	flag := os.O_WRONLY
+	if h.GetCreate() {
+		flag |= os.O_CREATE
+	}
+	if h.GetExclusive() {
+		flag |= os.O_EXCL
+	}
+	if h.GetTruncate() {
+		flag |= os.O_TRUNC
+	}
+	if h.GetAppend() {
+		flag |= os.O_APPEND
+	}
+	f, err := s.fsys.OpenFile(h.GetName(), flag, fs.FileMode(h.GetPerm()).Perm())
+	if err != nil {
+		return statusError(err)
+	}
+	info, err := writeStream(f, h, req, stream)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		return statusError(err)
+	}
+	return stream.SendAndClose(newFileInfo(info))
+}
+
+// writeStream writes the data of req and of the remaining messages of
+// stream to f.
+func writeStream(f wfs.File, h *WriteHeader, req *WriteRequest, stream FileSystem_WriteServer) (fs.FileInfo, error) {
+	if !h.GetAppend() && h.GetOffset() != 0 {
+		if _, err := f.Seek(h.GetOffset(), io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		if _, err := f.Write(req.GetData()); err != nil {
+			return nil, err
+		}
+		var err error
+		if req, err = stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	return f.Stat()
+}
+
+func (s *server) Mkdir(ctx context.Context, req *MkdirRequest) (*MkdirResponse, error) {
+	var err error
+	if req.GetAll() {
+		err = s.fsys.MkdirAll(req.GetName(), fs.FileMode(req.GetPerm()).Perm())
+	} else {
+		err = s.fsys.Mkdir(req.GetName(), fs.FileMode(req.GetPerm()).Perm())
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &MkdirResponse{}, nil
+}
+
+func (s *server) Remove(ctx context.Context, req *RemoveRequest) (*RemoveResponse, error) {
+	var err error
+	if req.GetAll() {
+		err = s.fsys.RemoveAll(req.GetName())
+	} else {
+		err = s.fsys.Remove(req.GetName())
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &RemoveResponse{}, nil
+}
+
+func (s *server) Rename(ctx context.Context, req *RenameRequest) (*RenameResponse, error) {
+	if err := s.fsys.Rename(req.GetOldName(), req.GetNewName()); err != nil {
+		return nil, statusError(err)
+	}
+	return &RenameResponse{}, nil
+}
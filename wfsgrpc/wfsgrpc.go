@@ -0,0 +1,49 @@
+// Package wfsgrpc exposes a file system as a gRPC service and provides a
+// file system backed by such a service, so that services can share storage
+// with a typed protocol.
+//
+// The FileSystem service is defined in wfs.proto. File contents are
+// streamed in chunks in both directions, so large files are transferred
+// with the flow control of gRPC rather than buffered in a single message.
+// Authentication and other cross-cutting concerns are left to the
+// interceptors and credentials of the gRPC server and connection.
+//
+// Errors are returned with a status code and an ErrorInfo detail naming
+// the error, which [Client] returns as the matching error of [io/fs] or wfs.
+package wfsgrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative wfs.proto
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/eriicafes/wfs"
+	"google.golang.org/grpc/codes"
+)
+
+// chunkSize is the size of the data of the messages of streamed contents.
+const chunkSize = 32 << 10
+
+// errorDomain is the domain of the ErrorInfo details of errors.
+const errorDomain = "wfs"
+
+var errBadFile = errors.New("bad file descriptor")
+
+// errorCodes maps errors to the ErrorInfo reasons and status codes they are
+// sent with. The errors of wfs come first as platform errors such as
+// ENOTEMPTY also match errors of [io/fs].
+var errorCodes = []struct {
+	err    error
+	reason string
+	code   codes.Code
+}{
+	{wfs.ErrNotEmpty, "NOT_EMPTY", codes.FailedPrecondition},
+	{wfs.ErrIsDir, "IS_DIR", codes.FailedPrecondition},
+	{wfs.ErrNotDir, "NOT_DIR", codes.FailedPrecondition},
+	{wfs.ErrLocked, "LOCKED", codes.Unavailable},
+	{fs.ErrNotExist, "NOT_EXIST", codes.NotFound},
+	{fs.ErrExist, "EXIST", codes.AlreadyExists},
+	{fs.ErrPermission, "PERMISSION", codes.PermissionDenied},
+	{fs.ErrInvalid, "INVALID", codes.InvalidArgument},
+}
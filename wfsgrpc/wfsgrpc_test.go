@@ -0,0 +1,180 @@
+package wfsgrpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/wfsgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serve serves fsys with opts and returns a client of it.
+func serve(t *testing.T, fsys wfs.FS, opts ...grpc.ServerOption) *wfsgrpc.FS {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(opts...)
+	wfsgrpc.RegisterFileSystemServer(srv, wfsgrpc.Server(fsys))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	dial := func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(dial), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return wfsgrpc.Client(conn)
+}
+
+func assertContent(t *testing.T, fsys fs.FS, name, expected string) {
+	t.Helper()
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil || string(data) != expected {
+		t.Errorf("expected %q to contain %q, got %q err: %v", name, expected, data, err)
+	}
+}
+
+func assertEntries(t *testing.T, fsys fs.FS, dir string, expected ...string) {
+	t.Helper()
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		t.Fatalf("failed to read %q: %v", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %q to contain %v, got %v", dir, expected, names)
+	}
+}
+
+func TestFS(t *testing.T) {
+	backend := wfs.Mem()
+	fsys := serve(t, backend)
+
+	if err := fsys.MkdirAll("a/b/c", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "a/b/file.txt", []byte("hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "top.txt", []byte("top"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := fstest.TestFS(fsys, "a/b/file.txt", "a/b/c", "top.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fsys.OpenFile("top.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+	if _, err := fsys.OpenFile("missing/file", os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if _, err := fsys.OpenFile("top.txt/file", os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("expected ErrNotDir, got %v", err)
+	}
+	if _, err := fsys.OpenFile("a", os.O_WRONLY, 0); !errors.Is(err, wfs.ErrIsDir) {
+		t.Errorf("expected ErrIsDir, got %v", err)
+	}
+	if err := fsys.Remove("a/b"); !errors.Is(err, wfs.ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty, got %v", err)
+	}
+	if err := fsys.Mkdir("a", 0755); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+
+	// appends are buffered until the file is closed
+	f, err := fsys.OpenFile("top.txt", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.Write([]byte(" more"))
+	assertContent(t, backend, "top.txt", "top")
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	assertContent(t, backend, "top.txt", "top more")
+	if info, err := fs.Stat(backend, "top.txt"); err != nil || info.Mode() != 0600 {
+		t.Errorf("expected mode 0600 to be kept, got %v err: %v", info.Mode(), err)
+	}
+
+	if err := fsys.Rename("a/b", "moved"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := fsys.Rename("top.txt", "moved/file.txt"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	assertEntries(t, backend, ".", "a", "moved")
+	assertContent(t, backend, "moved/file.txt", "top more")
+
+	if err := fsys.RemoveAll("moved"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if err := fsys.Remove("a"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	assertEntries(t, backend, ".")
+}
+
+func TestStream(t *testing.T) {
+	backend := wfs.Mem()
+	fsys := serve(t, backend)
+
+	// contents span many messages in both directions
+	large := bytes.Repeat([]byte("0123456789abcdef"), 100000)
+	if err := wfs.WriteFile(fsys, "large", large, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	data, err := fs.ReadFile(backend, "large")
+	if err != nil || !bytes.Equal(data, large) {
+		t.Fatalf("expected uploaded file of size %d, got %d err: %v", len(large), len(data), err)
+	}
+
+	f, err := fsys.Open("large")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	file := f.(wfs.File)
+	buf := make([]byte, 100)
+	if n, err := file.ReadAt(buf, 70000); err != nil || !bytes.Equal(buf[:n], large[70000:70100]) {
+		t.Errorf("expected %q, got %q err: %v", large[70000:70100], buf[:n], err)
+	}
+	if _, err := file.Seek(1000, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	data, err = io.ReadAll(file)
+	if err != nil || !bytes.Equal(data, large[1000:]) {
+		t.Errorf("expected contents from offset 1000, got size %d err: %v", len(data), err)
+	}
+	if _, err := file.Write([]byte("x")); err == nil {
+		t.Errorf("expected writing a file opened for reading to fail")
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	deny := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return nil, status.Error(codes.Unauthenticated, "missing token")
+	}
+	fsys := serve(t, wfs.Mem(), grpc.UnaryInterceptor(deny))
+
+	_, err := fsys.Stat(".")
+	if status.Code(errors.Unwrap(err)) != codes.Unauthenticated {
+		t.Errorf("expected the status of the interceptor, got %v", err)
+	}
+}
@@ -0,0 +1,284 @@
+package wfshttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+// FS is a writable file system backed by a server of the protocol described
+// in the package documentation, such as a [Handler].
+//
+// Files opened for reading are read with ranged GET requests, which fail
+// with [ErrModified] if the file is replaced after it is opened. Files
+// opened for writing are buffered in memory, starting with the contents of
+// the file unless it is truncated, and uploaded when they are synced or
+// closed. Uploads replace the file only if it was not modified since it was
+// opened, otherwise they fail with [ErrModified]. Created files are uploaded
+// empty when they are opened so that they exist.
+type FS struct {
+	// HTTPClient makes the requests of the file system.
+	// If nil, [http.DefaultClient] is used.
+	HTTPClient *http.Client
+
+	base string
+}
+
+// Client returns an FS for the server at baseURL.
+func Client(baseURL string) *FS {
+	return &FS{base: strings.TrimSuffix(baseURL, "/")}
+}
+
+// fileInfo describes a remote file.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *fileInfo) Sys() any           { return nil }
+
+func (e entry) info() *fileInfo {
+	return &fileInfo{name: e.Name, size: e.Size, mode: e.Mode, modTime: e.ModTime}
+}
+
+// url returns the URL of the file name with the query q.
+func (f *FS) url(name, q string) string {
+	p := "/"
+	if name != "." {
+		p += name
+	}
+	u := f.base + (&url.URL{Path: p}).EscapedPath()
+	if q != "" {
+		u += "?" + q
+	}
+	return u
+}
+
+// do sends a request for the file name and returns the response if it
+// succeeds, otherwise the error of the response.
+func (f *FS) do(method, name, q string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, f.url(name, q), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	client := f.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// responseError returns the error of a failed response.
+func responseError(resp *http.Response) error {
+	code := resp.Header.Get("X-Error")
+	for _, e := range errorCodes {
+		if code == e.code {
+			return e.err
+		}
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fs.ErrNotExist
+	case http.StatusForbidden:
+		return fs.ErrPermission
+	case http.StatusPreconditionFailed:
+		return ErrModified
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if s := strings.TrimSpace(string(msg)); s != "" {
+		return fmt.Errorf("%s: %s", resp.Status, s)
+	}
+	return errors.New(resp.Status)
+}
+
+// getJSON decodes the response of a GET of name into v and returns its ETag.
+func (f *FS) getJSON(name, q string, v any) (string, error) {
+	resp, err := f.do(http.MethodGet, name, q, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", err
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// stat returns the info and ETag of name without wrapping errors.
+func (f *FS) stat(name string) (*fileInfo, string, error) {
+	var e entry
+	tag, err := f.getJSON(name, "stat", &e)
+	if err != nil {
+		return nil, "", err
+	}
+	return e.info(), tag, nil
+}
+
+// readDir returns the entries of the directory name without wrapping errors.
+func (f *FS) readDir(name string) ([]fs.DirEntry, error) {
+	var list []entry
+	if _, err := f.getJSON(name, "list", &list); err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, len(list))
+	for i, e := range list {
+		entries[i] = fs.FileInfoToDirEntry(e.info())
+	}
+	return entries, nil
+}
+
+// put replaces the contents of name with body if the precondition header
+// is met, and returns the new ETag of the file.
+func (f *FS) put(name, q string, body io.Reader, precondition, tag string) (string, error) {
+	resp, err := f.do(http.MethodPut, name, q, body, http.Header{precondition: {tag}})
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// send sends a request that has no response body.
+func (f *FS) send(method, name, q string, header http.Header) error {
+	resp, err := f.do(method, name, q, nil, header)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	return f.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Stat implements [fs.StatFS] for FS.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, _, err := f.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// ReadDir implements [fs.ReadDirFS] for FS.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := f.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+func (f *FS) OpenFile(name string, flag int, perm fs.FileMode) (wfs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, tag, err := f.stat(name)
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0
+	switch {
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		err = fs.ErrExist
+	case err == nil && info.IsDir() && flag&(os.O_WRONLY|os.O_RDWR) != 0:
+		err = wfs.ErrIsDir
+	case err == nil && (!write || info.IsDir()):
+		return &file{fs: f, name: name, info: info, etag: tag}, nil
+	case err == nil:
+		var w *writer
+		if w, err = f.openWriter(name, info.mode, flag, tag, flag&os.O_TRUNC == 0); err == nil {
+			return w, nil
+		}
+	case errors.Is(err, fs.ErrNotExist) && flag&os.O_CREATE != 0:
+		q := fmt.Sprintf("mode=%o", perm.Perm())
+		if tag, err = f.put(name, q, nil, "If-None-Match", "*"); err == nil {
+			var w *writer
+			if w, err = f.openWriter(name, perm.Perm(), flag, tag, false); err == nil {
+				return w, nil
+			}
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+}
+
+func (f *FS) Rename(oldpath, newpath string) error {
+	if !fs.ValidPath(oldpath) || !fs.ValidPath(newpath) || oldpath == "." || newpath == "." {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrInvalid}
+	}
+	dst := (&url.URL{Path: newpath}).EscapedPath()
+	if err := f.send("MOVE", oldpath, "", http.Header{"Destination": {dst}}); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	return nil
+}
+
+func (f *FS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := f.send(http.MethodDelete, name, "", nil); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *FS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "RemoveAll", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := f.send(http.MethodDelete, name, "all", nil); err != nil {
+		return &fs.PathError{Op: "RemoveAll", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *FS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := f.send("MKCOL", name, fmt.Sprintf("mode=%o", perm.Perm()), nil); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *FS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := f.send("MKCOL", name, fmt.Sprintf("all&mode=%o", perm.Perm()), nil); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package wfshttp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eriicafes/wfs"
+)
+
+type handler struct {
+	fsys wfs.FS
+	mu   sync.Mutex // serializes modifications so conditional writes are atomic
+}
+
+// Handler returns an HTTP handler serving fsys with the protocol described
+// in the package documentation. Paths are relative to the root of the
+// handler, use [http.StripPrefix] to serve it below a prefix.
+//
+// Modifications made through the handler are serialized, so a conditional
+// write cannot race with another request of the handler. Modifications made
+// to fsys directly are not serialized with them.
+func Handler(fsys wfs.FS) http.Handler {
+	return &handler{fsys: fsys}
+}
+
+// pathName returns the name of the file at the URL path p.
+func pathName(p string) (string, bool) {
+	name := strings.Trim(p, "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+// parseMode returns the permission bits of the mode query parameter, or def
+// if it is not set.
+func parseMode(q url.Values, def fs.FileMode) (fs.FileMode, error) {
+	if !q.Has("mode") {
+		return def, nil
+	}
+	mode, err := strconv.ParseUint(q.Get("mode"), 8, 32)
+	if err != nil {
+		return 0, fs.ErrInvalid
+	}
+	return fs.FileMode(mode).Perm(), nil
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := pathName(r.URL.Path)
+	if !ok {
+		writeError(w, fs.ErrInvalid)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+	}
+	q := r.URL.Query()
+	var err error
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		err = h.get(w, r, name)
+	case http.MethodPut:
+		err = h.put(w, r, name)
+	case http.MethodDelete:
+		if q.Has("all") {
+			err = h.fsys.RemoveAll(name)
+		} else {
+			err = h.fsys.Remove(name)
+		}
+		if err == nil {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case "MKCOL":
+		var perm fs.FileMode
+		if perm, err = parseMode(q, 0755); err == nil {
+			if q.Has("all") {
+				err = h.fsys.MkdirAll(name, perm)
+			} else {
+				err = h.fsys.Mkdir(name, perm)
+			}
+		}
+		if err == nil {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case "MOVE":
+		err = fs.ErrInvalid
+		if dst, uerr := url.PathUnescape(r.Header.Get("Destination")); uerr == nil {
+			if newname, ok := pathName(dst); ok {
+				err = h.fsys.Rename(name, newname)
+			}
+		}
+		if err == nil {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE, MKCOL, MOVE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request, name string) error {
+	q := r.URL.Query()
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	switch {
+	case q.Has("stat"):
+		w.Header().Set("ETag", etag(info))
+		return writeJSON(w, newEntry(info))
+	case q.Has("list") && !info.IsDir():
+		return wfs.ErrNotDir
+	case info.IsDir():
+		dirEntries, err := fs.ReadDir(h.fsys, name)
+		if err != nil {
+			return err
+		}
+		entries := make([]entry, 0, len(dirEntries))
+		for _, e := range dirEntries {
+			info, err := e.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			entries = append(entries, newEntry(info))
+		}
+		return writeJSON(w, entries)
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		return fs.ErrInvalid
+	}
+	w.Header().Set("ETag", etag(info))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", info.ModTime(), rs)
+	return nil
+}
+
+func (h *handler) put(w http.ResponseWriter, r *http.Request, name string) error {
+	perm, err := parseMode(r.URL.Query(), 0644)
+	if err != nil {
+		return err
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	_, err = fs.Stat(h.fsys, name)
+	created := errors.Is(err, fs.ErrNotExist)
+	if r.Header.Get("If-None-Match") == "*" {
+		flag |= os.O_EXCL
+	}
+	if match := r.Header.Get("If-Match"); match != "" {
+		info, err := fs.Stat(h.fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrModified
+		}
+		if err != nil {
+			return err
+		}
+		if match != "*" && match != etag(info) {
+			return ErrModified
+		}
+	}
+	f, err := h.fsys.OpenFile(name, flag, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r.Body)
+	if err == nil {
+		err = f.Sync()
+	}
+	var info fs.FileInfo
+	if err == nil {
+		info, err = f.Stat()
+	}
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", etag(info))
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+	return nil
+}
+
+// writeError writes the response of err, naming it in the X-Error header
+// if it is one of errorCodes.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			status = e.status
+			w.Header().Set("X-Error", e.code)
+			break
+		}
+	}
+	// paths are omitted as they are known to the client
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case errors.As(err, &pathErr):
+		err = pathErr.Err
+	case errors.As(err, &linkErr):
+		err = linkErr.Err
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,76 @@
+// Package wfshttp exposes a file system over HTTP and provides a file system
+// backed by such a server, so that one process can serve its storage to
+// another.
+//
+// Each file is addressed by its path below the root of the [Handler], and
+// the operations of the file system map to requests on its URL:
+//
+//	GET          reads a file, honoring Range and conditional headers, or lists a directory
+//	GET ?stat    returns the info of a file
+//	GET ?list    lists a directory
+//	PUT          replaces the contents of a file, creating it with ?mode if it does not exist
+//	DELETE       removes a file or an empty directory, or a whole tree with ?all
+//	MKCOL        creates a directory with ?mode, or also its parents with ?all
+//	MOVE         renames a file to the path in the Destination header
+//
+// File info and directory listings are JSON objects with the name, size,
+// mode and modTime of each file. File responses carry an ETag derived from
+// the modification time and size of the file. A PUT with "If-None-Match: *"
+// only creates a file, and a PUT with "If-Match" only replaces a file with
+// that ETag, failing with 412 Precondition Failed otherwise.
+//
+// Errors are returned with a status code and an X-Error header naming the
+// error, which [Client] returns as the matching error of [io/fs] or wfs.
+package wfshttp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+// ErrModified is returned when a conditional request fails because the file
+// was modified by someone else since it was opened.
+var ErrModified = errors.New("file was modified")
+
+var errBadFile = errors.New("bad file descriptor")
+
+// errorCodes maps errors to the X-Error codes and status codes they are
+// sent with. The errors of wfs come first as platform errors such as
+// ENOTEMPTY also match errors of [io/fs].
+var errorCodes = []struct {
+	err    error
+	code   string
+	status int
+}{
+	{wfs.ErrNotEmpty, "not-empty", http.StatusConflict},
+	{wfs.ErrIsDir, "is-dir", http.StatusConflict},
+	{wfs.ErrNotDir, "not-dir", http.StatusConflict},
+	{wfs.ErrLocked, "locked", http.StatusLocked},
+	{ErrModified, "modified", http.StatusPreconditionFailed},
+	{fs.ErrNotExist, "not-exist", http.StatusNotFound},
+	{fs.ErrExist, "exist", http.StatusConflict},
+	{fs.ErrPermission, "permission", http.StatusForbidden},
+	{fs.ErrInvalid, "invalid", http.StatusBadRequest},
+}
+
+// entry is the JSON encoding of the info of a file.
+type entry struct {
+	Name    string      `json:"name"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"modTime"`
+}
+
+func newEntry(info fs.FileInfo) entry {
+	return entry{Name: info.Name(), Size: info.Size(), Mode: info.Mode(), ModTime: info.ModTime()}
+}
+
+// etag returns the entity tag of a file.
+func etag(info fs.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
@@ -0,0 +1,210 @@
+package wfshttp_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/wfshttp"
+)
+
+// serve serves fsys below a prefix and returns a client of it.
+func serve(t *testing.T, fsys wfs.FS) (*wfshttp.FS, string) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("/fs/", http.StripPrefix("/fs", wfshttp.Handler(fsys)))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return wfshttp.Client(srv.URL + "/fs/"), srv.URL + "/fs"
+}
+
+func assertContent(t *testing.T, fsys fs.FS, name, expected string) {
+	t.Helper()
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil || string(data) != expected {
+		t.Errorf("expected %q to contain %q, got %q err: %v", name, expected, data, err)
+	}
+}
+
+func assertEntries(t *testing.T, fsys fs.FS, dir string, expected ...string) {
+	t.Helper()
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		t.Fatalf("failed to read %q: %v", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %q to contain %v, got %v", dir, expected, names)
+	}
+}
+
+func TestFS(t *testing.T) {
+	backend := wfs.Mem()
+	fsys, _ := serve(t, backend)
+
+	if err := fsys.MkdirAll("a/b/c", 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "a/b/file.txt", []byte("hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := wfs.WriteFile(fsys, "odd name?#%.txt", []byte("odd"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := fstest.TestFS(fsys, "a/b/file.txt", "a/b/c", "odd name?#%.txt"); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, backend, "odd name?#%.txt", "odd")
+
+	if _, err := fsys.OpenFile("odd name?#%.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+	if _, err := fsys.OpenFile("missing/file", os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if _, err := fsys.OpenFile("a/b/file.txt/x", os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("expected ErrNotDir, got %v", err)
+	}
+	if _, err := fsys.OpenFile("a", os.O_WRONLY, 0); !errors.Is(err, wfs.ErrIsDir) {
+		t.Errorf("expected ErrIsDir, got %v", err)
+	}
+	if err := fsys.Remove("a/b"); !errors.Is(err, wfs.ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty, got %v", err)
+	}
+	if err := fsys.Mkdir("a", 0755); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+	if _, err := fsys.ReadDir("a/b/file.txt"); !errors.Is(err, wfs.ErrNotDir) {
+		t.Errorf("expected ErrNotDir, got %v", err)
+	}
+
+	// appends are buffered until the file is closed
+	f, err := fsys.OpenFile("a/b/file.txt", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.Write([]byte(" world"))
+	assertContent(t, fsys, "a/b/file.txt", "hello")
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	assertContent(t, fsys, "a/b/file.txt", "hello world")
+
+	if err := fsys.Rename("a/b", "moved"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := fsys.Rename("moved", "moved/c/inside"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected ErrInvalid moving a directory into itself, got %v", err)
+	}
+	if err := fsys.Rename("odd name?#%.txt", "moved/odd"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	assertEntries(t, backend, ".", "a", "moved")
+	assertEntries(t, backend, "moved", "c", "file.txt", "odd")
+
+	if err := fsys.Remove("moved/c"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := fsys.RemoveAll("moved"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	assertEntries(t, backend, ".", "a")
+}
+
+func TestConditional(t *testing.T) {
+	backend := wfs.Mem()
+	fsys, _ := serve(t, backend)
+	if err := wfs.WriteFile(backend, "file", []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	r, err := fsys.Open("file")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+	buf := make([]byte, 4)
+	if n, err := r.(wfs.File).ReadAt(buf, 3); err != nil || string(buf[:n]) != "3456" {
+		t.Errorf("expected %q, got %q err: %v", "3456", buf[:n], err)
+	}
+	w, err := fsys.OpenFile("file", os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	w.Write([]byte("abc"))
+
+	// the file is replaced behind the back of both handles
+	if err := wfs.WriteFile(backend, "file", []byte("replaced"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := r.(wfs.File).ReadAt(buf, 0); !errors.Is(err, wfshttp.ErrModified) {
+		t.Errorf("expected ErrModified reading, got %v", err)
+	}
+	if err := w.Close(); !errors.Is(err, wfshttp.ErrModified) {
+		t.Errorf("expected ErrModified writing, got %v", err)
+	}
+	assertContent(t, backend, "file", "replaced")
+
+	// each upload updates the ETag of the handle
+	w, _ = fsys.OpenFile("file", os.O_WRONLY|os.O_TRUNC, 0)
+	w.Write([]byte("one"))
+	if err := w.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	w.Write([]byte(" two"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	assertContent(t, backend, "file", "one two")
+}
+
+func TestHandler(t *testing.T) {
+	backend := wfs.Mem()
+	if err := wfs.WriteFile(backend, "file", []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	_, base := serve(t, backend)
+
+	req, _ := http.NewRequest(http.MethodGet, base+"/file", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "234" || resp.Header.Get("ETag") == "" {
+		t.Errorf("expected partial content %q with an ETag, got %s %q", "234", resp.Status, body)
+	}
+
+	req, _ = http.NewRequest(http.MethodPut, base+"/file", nil)
+	req.Header.Set("If-None-Match", "*")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict || resp.Header.Get("X-Error") != "exist" {
+		t.Errorf("expected conflict, got %s %q", resp.Status, resp.Header.Get("X-Error"))
+	}
+
+	req, _ = http.NewRequest("PATCH", base+"/file", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected method not allowed, got %s", resp.Status)
+	}
+}
@@ -0,0 +1,315 @@
+package wfstest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/eriicafes/wfs"
+)
+
+// paths are the names operations of Differential apply to, a few names in
+// a shallow tree are enough for operations to interact.
+var paths = []string{"a", "b", "a/b", "a/c", "b/a", "a/b/c", ".", "/a", "a/../b"}
+
+// flags are the access modes and flags files are opened with.
+var flags = []int{os.O_RDONLY, os.O_WRONLY, os.O_RDWR}
+
+const (
+	numSlots = 4  // files open at the same time
+	maxOps   = 64 // operations decoded from one input
+)
+
+// errClasses are the errors results are compared by, as the messages of
+// equivalent errors differ between backends. The errors of wfs come first as
+// the platform errors they annotate, such as ENOTEMPTY, also match errors of
+// [io/fs].
+var errClasses = []error{
+	wfs.ErrNotEmpty,
+	wfs.ErrIsDir,
+	wfs.ErrNotDir,
+	fs.ErrNotExist,
+	fs.ErrExist,
+	fs.ErrInvalid,
+	fs.ErrClosed,
+	fs.ErrPermission,
+	io.EOF,
+}
+
+// errClass returns a description of err that is equal for equivalent errors.
+func errClass(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	for _, class := range errClasses {
+		if errors.Is(err, class) {
+			return class.Error()
+		}
+	}
+	return "error"
+}
+
+// Differential decodes a sequence of operations from data and applies it to
+// both want and got, reporting the first operation whose results differ and
+// the differences between the resulting trees. want and got must be empty.
+//
+// Differential is meant to be called from a fuzz target with a reference
+// backend, such as the OS file system in a temporary directory, to find the
+// behaviors in which a backend diverges from it. Errors are compared by the
+// errors of [io/fs] and wfs they match.
+func Differential(t testing.TB, data []byte, want, got wfs.FS) {
+	t.Helper()
+	sides := [2]*diffSide{{fsys: want}, {fsys: got}}
+	defer func() {
+		for _, s := range sides {
+			s.closeAll()
+		}
+	}()
+	var log []string
+	for i := 0; len(data) > 0 && i < maxOps; i++ {
+		var op diffOp
+		op, data = decodeOp(data)
+		log = append(log, op.String())
+		w, g := sides[0].apply(op, i), sides[1].apply(op, i)
+		if w != g {
+			t.Errorf("operation %d differs:\n%s\nwant: %s\ngot:  %s", i, strings.Join(log, "\n"), w, g)
+			return
+		}
+	}
+	for _, s := range sides {
+		s.closeAll()
+	}
+	w, err := treeOf(want)
+	if err != nil {
+		t.Fatalf("walk want: %v", err)
+	}
+	g, err := treeOf(got)
+	if err != nil {
+		t.Fatalf("walk got: %v", err)
+	}
+	if w != g {
+		t.Errorf("trees differ after:\n%s\nwant:\n%s\ngot:\n%s", strings.Join(log, "\n"), w, g)
+	}
+}
+
+// diffOp is an operation decoded by decodeOp.
+type diffOp struct {
+	kind   int
+	slot   int
+	path   string
+	path2  string
+	flag   int
+	n      int
+	offset int64
+	whence int
+}
+
+const (
+	opOpen = iota
+	opWrite
+	opWriteAt
+	opRead
+	opReadAt
+	opSeek
+	opTruncate
+	opClose
+	opFileStat
+	opMkdir
+	opMkdirAll
+	opRemove
+	opRemoveAll
+	opRename
+	opStat
+	opReadDir
+	opReadFile
+	numOps
+)
+
+var opNames = [numOps]string{
+	"open", "write", "writeat", "read", "readat", "seek", "truncate", "close", "fstat",
+	"mkdir", "MkdirAll", "remove", "RemoveAll", "rename", "stat", "readdir", "readfile",
+}
+
+// decodeOp decodes an operation from the first bytes of data and returns
+// the remaining bytes. Missing bytes are zero.
+func decodeOp(data []byte) (diffOp, []byte) {
+	var b [5]byte
+	n := copy(b[:], data)
+	data = data[n:]
+	op := diffOp{
+		kind:   int(b[0]) % numOps,
+		slot:   int(b[1]) % numSlots,
+		path:   paths[int(b[1])%len(paths)],
+		path2:  paths[int(b[2])%len(paths)],
+		n:      1 + int(b[3]%32), // os.File skips empty reads and writes
+		offset: int64(b[4] % 32),
+		whence: int(b[2] % 3),
+	}
+	if op.kind == opSeek {
+		// seek backwards from the end and the current offset too
+		op.offset -= 8
+	}
+	op.flag = flags[int(b[2])%len(flags)]
+	for i, flag := range []int{os.O_CREATE, os.O_EXCL, os.O_TRUNC, os.O_APPEND} {
+		if b[3]&(1<<i) != 0 {
+			op.flag |= flag
+		}
+	}
+	return op, data
+}
+
+// String returns a description of the operation.
+func (op diffOp) String() string {
+	switch op.kind {
+	case opOpen:
+		return fmt.Sprintf("open %q %#x -> %d", op.path, op.flag, op.slot)
+	case opWrite, opRead:
+		return fmt.Sprintf("%s %d %d bytes", opNames[op.kind], op.slot, op.n)
+	case opWriteAt, opReadAt:
+		return fmt.Sprintf("%s %d %d bytes at %d", opNames[op.kind], op.slot, op.n, op.offset)
+	case opSeek:
+		return fmt.Sprintf("seek %d %d whence %d", op.slot, op.offset, op.whence)
+	case opTruncate:
+		return fmt.Sprintf("truncate %d %d", op.slot, op.offset)
+	case opClose, opFileStat:
+		return fmt.Sprintf("%s %d", opNames[op.kind], op.slot)
+	case opRename:
+		return fmt.Sprintf("rename %q %q", op.path, op.path2)
+	}
+	return fmt.Sprintf("%s %q", opNames[op.kind], op.path)
+}
+
+// diffSide is a file system operations are applied to, with its open files.
+type diffSide struct {
+	fsys  wfs.FS
+	files [numSlots]wfs.File
+}
+
+func (s *diffSide) closeAll() {
+	for i, f := range s.files {
+		if f != nil {
+			f.Close()
+			s.files[i] = nil
+		}
+	}
+}
+
+// apply applies op and returns a description of its results.
+func (s *diffSide) apply(op diffOp, seq int) string {
+	// content written by the operation
+	content := bytes.Repeat([]byte{byte('a' + seq%26)}, op.n)
+	switch op.kind {
+	case opOpen:
+		if f := s.files[op.slot]; f != nil {
+			f.Close()
+			s.files[op.slot] = nil
+		}
+		f, err := s.fsys.OpenFile(op.path, op.flag, 0644)
+		if err != nil {
+			return errClass(err)
+		}
+		s.files[op.slot] = f
+		return "ok"
+	case opMkdir:
+		return errClass(s.fsys.Mkdir(op.path, 0755))
+	case opMkdirAll:
+		return errClass(s.fsys.MkdirAll(op.path, 0755))
+	case opRemove:
+		return errClass(s.fsys.Remove(op.path))
+	case opRemoveAll:
+		return errClass(s.fsys.RemoveAll(op.path))
+	case opRename:
+		return errClass(s.fsys.Rename(op.path, op.path2))
+	case opStat:
+		info, err := fs.Stat(s.fsys, op.path)
+		return infoString(info, err)
+	case opReadDir:
+		entries, err := fs.ReadDir(s.fsys, op.path)
+		var names []string
+		for _, e := range entries {
+			names = append(names, fmt.Sprintf("%s dir=%t", e.Name(), e.IsDir()))
+		}
+		return fmt.Sprintf("%v %s", names, errClass(err))
+	case opReadFile:
+		data, err := fs.ReadFile(s.fsys, op.path)
+		return fmt.Sprintf("%q %s", data, errClass(err))
+	}
+
+	f := s.files[op.slot]
+	if f == nil {
+		return "no file"
+	}
+	switch op.kind {
+	case opWrite:
+		n, err := f.Write(content)
+		return fmt.Sprintf("%d %s", n, errClass(err))
+	case opWriteAt:
+		n, err := f.WriteAt(content, op.offset)
+		return fmt.Sprintf("%d %s", n, errClass(err))
+	case opRead:
+		b := make([]byte, op.n)
+		n, err := f.Read(b)
+		return fmt.Sprintf("%q %s", b[:n], errClass(err))
+	case opReadAt:
+		b := make([]byte, op.n)
+		n, err := f.ReadAt(b, op.offset)
+		return fmt.Sprintf("%q %s", b[:n], errClass(err))
+	case opSeek:
+		if info, err := f.Stat(); err == nil && info.IsDir() {
+			// offsets in directories depend on the backend
+			return "dir"
+		}
+		off, err := f.Seek(op.offset, op.whence)
+		if err != nil {
+			return errClass(err)
+		}
+		return fmt.Sprint(off)
+	case opTruncate:
+		return errClass(f.Truncate(op.offset))
+	case opClose:
+		s.files[op.slot] = nil
+		return errClass(f.Close())
+	case opFileStat:
+		info, err := f.Stat()
+		return infoString(info, err)
+	}
+	panic("unreachable")
+}
+
+// infoString describes the type and size of a file, the sizes of
+// directories differ between backends.
+func infoString(info fs.FileInfo, err error) string {
+	switch {
+	case err != nil:
+		return errClass(err)
+	case info.IsDir():
+		return "dir"
+	}
+	return fmt.Sprintf("file %d", info.Size())
+}
+
+// treeOf describes every file of fsys with its content.
+func treeOf(fsys fs.FS) (string, error) {
+	var b strings.Builder
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			fmt.Fprintf(&b, "%s/\n", name)
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s %q\n", name, data)
+		return nil
+	})
+	return b.String(), err
+}
@@ -0,0 +1,86 @@
+package wfstest
+
+import (
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+// Write creates the files of tree in fsys, so that the same fixture can seed
+// any backend. Missing parent directories are created with mode 0755, entries
+// without permission bits are created with [fs.ModePerm], and the Data of a
+// [fs.ModeSymlink] entry is the destination of the link. Modification times
+// are set once every entry is written, and are not set on symbolic links.
+func Write(fsys wfs.FS, tree map[string]*fstest.MapFile) error {
+	names := slices.Sorted(maps.Keys(tree))
+	for _, name := range names {
+		file := tree[name]
+		mode := file.Mode
+		if mode.Perm() == 0 {
+			mode |= fs.ModePerm
+		}
+		if mode.IsDir() {
+			if err := fsys.MkdirAll(name, mode.Perm()); err != nil {
+				return err
+			}
+			continue
+		}
+		if dir := path.Dir(name); dir != "." {
+			if err := fsys.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+		var err error
+		if mode&fs.ModeSymlink != 0 {
+			err = wfs.Symlink(fsys, string(file.Data), name)
+		} else {
+			err = wfs.WriteFile(fsys, name, file.Data, mode.Perm())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// set children first as writing them changes the time of their parent
+	for _, name := range slices.Backward(names) {
+		file := tree[name]
+		if file.ModTime.IsZero() || file.Mode&fs.ModeSymlink != 0 {
+			continue
+		}
+		if err := wfs.Chtimes(fsys, name, file.ModTime, file.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTxtar creates the files of a txtar archive, as read by [wfs.FromTxtar],
+// in fsys with [Write].
+func WriteTxtar(fsys wfs.FS, archive string) error {
+	src := wfs.FromTxtar([]byte(archive))
+	tree := make(map[string]*fstest.MapFile)
+	err := fs.WalkDir(src, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		file := &fstest.MapFile{Mode: info.Mode()}
+		if !d.IsDir() {
+			if file.Data, err = fs.ReadFile(src, name); err != nil {
+				return err
+			}
+		}
+		tree[name] = file
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return Write(fsys, tree)
+}
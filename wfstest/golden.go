@@ -0,0 +1,204 @@
+package wfstest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"unicode/utf8"
+
+	"github.com/eriicafes/wfs"
+)
+
+var update = flag.Bool("update", false, "rewrite golden trees compared by wfstest.AssertEqualFS")
+
+// maxDiffLines is the number of lines of the files above which contents are
+// reported as differing without a line diff.
+const maxDiffLines = 2000
+
+// AssertEqualFS reports an error for every file that differs between the
+// golden tree want and got, with a line diff of text files. Directories,
+// regular files and symbolic links are compared by type and content,
+// permissions and modification times are ignored.
+//
+// When the test binary runs with the -update flag, AssertEqualFS rewrites want
+// with the files of got instead, want must then be a [wfs.FS] such as a
+// [wfs.Dir] of the golden directory.
+func AssertEqualFS(t testing.TB, want, got fs.FS) {
+	t.Helper()
+	if *update {
+		if err := rewriteGolden(want, got); err != nil {
+			t.Fatalf("update golden tree: %v", err)
+		}
+		return
+	}
+	changes, err := wfs.Diff(want, got, ".", &wfs.DiffOptions{Hash: true})
+	if err != nil {
+		t.Fatalf("compare trees: %v", err)
+	}
+	var msgs []string
+	for _, c := range changes {
+		switch c.Kind {
+		case wfs.ChangeAdded:
+			msgs = append(msgs, fmt.Sprintf("unexpected %s %s", fileType(c.B), c.Path))
+		case wfs.ChangeRemoved:
+			msgs = append(msgs, fmt.Sprintf("missing %s %s", fileType(c.A), c.Path))
+		default:
+			msg, err := compareFile(want, got, c)
+			if err != nil {
+				t.Fatalf("compare %s: %v", c.Path, err)
+			}
+			if msg != "" {
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+	if len(msgs) > 0 {
+		t.Errorf("trees differ, run with -update to rewrite the golden tree:\n%s", strings.Join(msgs, "\n"))
+	}
+}
+
+// fileType returns the type of the file described by info.
+func fileType(info fs.FileInfo) string {
+	switch {
+	case info.IsDir():
+		return "directory"
+	case info.Mode()&fs.ModeSymlink != 0:
+		return "symbolic link"
+	}
+	return "file"
+}
+
+// compareFile describes the differences of a modified entry, ignoring
+// permissions, or returns an empty string if it is equal.
+func compareFile(want, got fs.FS, c wfs.Change) (string, error) {
+	if tw, tg := fileType(c.A), fileType(c.B); tw != tg {
+		return fmt.Sprintf("%s is a %s, expected a %s", c.Path, tg, tw), nil
+	}
+	switch {
+	case c.A.IsDir():
+		return "", nil
+	case c.A.Mode()&fs.ModeSymlink != 0:
+		dw, err := wfs.Readlink(want, c.Path)
+		if err != nil {
+			return "", err
+		}
+		dg, err := wfs.Readlink(got, c.Path)
+		if err != nil || dw == dg {
+			return "", err
+		}
+		return fmt.Sprintf("%s links to %q, expected %q", c.Path, dg, dw), nil
+	}
+	bw, err := fs.ReadFile(want, c.Path)
+	if err != nil {
+		return "", err
+	}
+	bg, err := fs.ReadFile(got, c.Path)
+	if err != nil || bytes.Equal(bw, bg) {
+		return "", err
+	}
+	return fmt.Sprintf("%s differs:\n%s", c.Path, lineDiff(bw, bg)), nil
+}
+
+// lineDiff returns the lines removed from want and added in got, prefixed
+// with - and + and their line numbers.
+func lineDiff(want, got []byte) string {
+	if !isText(want) || !isText(got) {
+		return fmt.Sprintf("\tbinary contents differ (%d and %d bytes)", len(want), len(got))
+	}
+	a, b := strings.SplitAfter(string(want), "\n"), strings.SplitAfter(string(got), "\n")
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		return fmt.Sprintf("\tcontents differ (%d and %d lines)", len(a), len(b))
+	}
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var sb strings.Builder
+	line := func(prefix byte, n int, s string) {
+		fmt.Fprintf(&sb, "\t%c%4d | %s", prefix, n, s)
+		if !strings.HasSuffix(s, "\n") {
+			sb.WriteString("\n\t\\ no newline at end of file\n")
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i, j = i+1, j+1
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			line('-', i+1, a[i])
+			i++
+		default:
+			line('+', j+1, b[j])
+			j++
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// isText reports whether data looks like text.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+}
+
+// rewriteGolden replaces the files of golden with the files of got.
+func rewriteGolden(golden, got fs.FS) error {
+	dst, ok := golden.(wfs.FS)
+	if !ok {
+		return fmt.Errorf("golden file system %T is not writable", golden)
+	}
+	entries, err := fs.ReadDir(dst, ".")
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := dst.RemoveAll(e.Name()); err != nil {
+			return err
+		}
+	}
+	tree := make(map[string]*fstest.MapFile)
+	err = fs.WalkDir(got, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		file := &fstest.MapFile{Mode: info.Mode()}
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := wfs.Readlink(got, name)
+			if err != nil {
+				return err
+			}
+			file.Data = []byte(target)
+		case d.Type().IsRegular():
+			if file.Data, err = fs.ReadFile(got, name); err != nil {
+				return err
+			}
+		case !d.IsDir():
+			return nil
+		}
+		tree[name] = file
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return Write(dst, tree)
+}
@@ -0,0 +1,22 @@
+//go:build go1.25
+
+package wfstest_test
+
+import (
+	"testing"
+
+	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/wfstest"
+)
+
+// Root supports every operation tested by TestFS only from Go 1.25.
+func TestRoot(t *testing.T) {
+	wfstest.TestFS(t, func() wfs.FS {
+		root, err := wfs.OpenRoot(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { root.Close() })
+		return root
+	}, wfstest.Concurrent())
+}
@@ -0,0 +1,488 @@
+// Package wfstest implements support for testing implementations and users
+// of writable file systems.
+//
+// [TestFS] complements [testing/fstest.TestFS], which only checks reads, with
+// the write semantics that every [wfs.FS] backend is expected to follow, so
+// that third-party backends can verify they behave like the backends of wfs.
+// [Differential] compares a backend with a reference under fuzzing, [Write]
+// seeds any backend with a fixture and [AssertEqualFS] compares a tree with a
+// golden tree.
+package wfstest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eriicafes/wfs"
+)
+
+// TestFS tests a writable file system implementation. newFS must return a
+// new, empty file system every time it is called, each subtest runs on its
+// own file system.
+//
+// TestFS checks the [os.OpenFile] flags, the offset semantics of Read, Write,
+// Seek, ReadAt, WriteAt and Truncate, Mkdir, Remove and Rename including
+// their edge cases, and that errors match the errors of [io/fs] and wfs with
+// [errors.Is]. The resulting trees are also checked with [fstest.TestFS].
+// Writes are only expected to be visible to other handles once the file is
+// synced or closed. Concurrent use is only checked with the [Concurrent]
+// option.
+func TestFS(t *testing.T, newFS func() wfs.FS, opts ...Option) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	t.Run("OpenFile", func(t *testing.T) { testOpenFile(t, newFS()) })
+	t.Run("ReadWrite", func(t *testing.T) { testReadWrite(t, newFS()) })
+	t.Run("Truncate", func(t *testing.T) { testTruncate(t, newFS()) })
+	t.Run("Closed", func(t *testing.T) { testClosed(t, newFS()) })
+	t.Run("Mkdir", func(t *testing.T) { testMkdir(t, newFS()) })
+	t.Run("Remove", func(t *testing.T) { testRemove(t, newFS()) })
+	t.Run("Rename", func(t *testing.T) { testRename(t, newFS()) })
+	t.Run("InvalidPath", func(t *testing.T) { testInvalidPath(t, newFS()) })
+	t.Run("Concurrent", func(t *testing.T) {
+		if !o.concurrent {
+			t.Skip("concurrent use is not checked without the Concurrent option")
+		}
+		testConcurrent(t, newFS())
+	})
+}
+
+// Option configures [TestFS].
+type Option func(*options)
+
+type options struct {
+	concurrent bool
+}
+
+// Concurrent checks that the file system and its open files can be used by
+// multiple goroutines at once, which file systems that are not safe for
+// concurrent use such as [wfs.Map] cannot pass.
+func Concurrent() Option {
+	return func(o *options) { o.concurrent = true }
+}
+
+// checkErr reports an error if err does not match target.
+func checkErr(t *testing.T, what string, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Errorf("%s: expected error matching %q, got %v", what, target, err)
+	}
+}
+
+// writeFile writes a file and fails the test on error.
+func writeFile(t *testing.T, fsys wfs.FS, name, content string) {
+	t.Helper()
+	if err := wfs.WriteFile(fsys, name, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile %s: %v", name, err)
+	}
+}
+
+// checkContent reports an error if the named file does not hold content.
+func checkContent(t *testing.T, fsys wfs.FS, name, content string) {
+	t.Helper()
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		t.Errorf("ReadFile %s: %v", name, err)
+	} else if string(data) != content {
+		t.Errorf("ReadFile %s: expected %q, got %q", name, content, data)
+	}
+}
+
+// checkEntries reports an error if the directory does not list exactly names.
+func checkEntries(t *testing.T, fsys wfs.FS, dir string, names ...string) {
+	t.Helper()
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		t.Errorf("ReadDir %s: %v", dir, err)
+		return
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	if !slices.Equal(got, names) {
+		t.Errorf("ReadDir %s: expected %v, got %v", dir, names, got)
+	}
+}
+
+// checkTree runs fstest.TestFS over the expected files of fsys.
+func checkTree(t *testing.T, fsys wfs.FS, expected ...string) {
+	t.Helper()
+	if err := fstest.TestFS(fsys, expected...); err != nil {
+		t.Error(err)
+	}
+}
+
+func testOpenFile(t *testing.T, fsys wfs.FS) {
+	_, err := fsys.OpenFile("missing", os.O_RDONLY, 0)
+	checkErr(t, "open missing file", err, fs.ErrNotExist)
+	_, err = fsys.OpenFile("missing/file", os.O_WRONLY|os.O_CREATE, 0644)
+	checkErr(t, "create in missing directory", err, fs.ErrNotExist)
+
+	f, err := fsys.OpenFile("file", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	if _, err := io.WriteString(f, "hello"); err != nil {
+		t.Errorf("write: %v", err)
+	}
+	if _, err := f.Read(make([]byte, 1)); err == nil {
+		t.Error("read of a file opened with O_WRONLY: expected an error")
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+	checkContent(t, fsys, "file", "hello")
+
+	_, err = fsys.OpenFile("file", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	checkErr(t, "create existing file with O_EXCL", err, fs.ErrExist)
+
+	// O_CREATE without O_EXCL opens the existing file
+	f, err = fsys.OpenFile("file", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("open existing file with O_CREATE: %v", err)
+	}
+	if _, err := io.WriteString(f, "J"); err != nil {
+		t.Errorf("write: %v", err)
+	}
+	f.Close()
+	checkContent(t, fsys, "file", "Jello")
+
+	f, err = fsys.OpenFile("file", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := f.Write([]byte("x")); err == nil {
+		t.Error("write to a file opened with O_RDONLY: expected an error")
+	}
+	f.Close()
+	checkContent(t, fsys, "file", "Jello")
+
+	f, err = fsys.OpenFile("file", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open with O_APPEND: %v", err)
+	}
+	f.Seek(0, io.SeekStart)
+	if _, err := io.WriteString(f, " world"); err != nil {
+		t.Errorf("append: %v", err)
+	}
+	if _, err := f.WriteAt([]byte("x"), 0); err == nil {
+		t.Error("WriteAt on a file opened with O_APPEND: expected an error")
+	}
+	f.Close()
+	checkContent(t, fsys, "file", "Jello world")
+
+	f, err = fsys.OpenFile("file", os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatalf("open with O_TRUNC: %v", err)
+	}
+	f.Close()
+	checkContent(t, fsys, "file", "")
+
+	if err := fsys.Mkdir("dir", 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	_, err = fsys.OpenFile("dir", os.O_WRONLY, 0)
+	checkErr(t, "open directory for writing", err, wfs.ErrIsDir)
+	_, err = fsys.OpenFile("file/child", os.O_WRONLY|os.O_CREATE, 0644)
+	if err == nil {
+		t.Error("create below a file: expected an error")
+	}
+	checkTree(t, fsys, "file", "dir")
+}
+
+func testReadWrite(t *testing.T, fsys wfs.FS) {
+	f, err := fsys.OpenFile("file", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	defer f.Close()
+	if n, err := io.WriteString(f, "0123456789"); n != 10 || err != nil {
+		t.Errorf("write: %d %v", n, err)
+	}
+	if off, err := f.Seek(0, io.SeekCurrent); off != 10 || err != nil {
+		t.Errorf("seek current after write: expected 10, got %d %v", off, err)
+	}
+	if off, err := f.Seek(-4, io.SeekEnd); off != 6 || err != nil {
+		t.Errorf("seek from end: expected 6, got %d %v", off, err)
+	}
+	b := make([]byte, 10)
+	if n, err := f.Read(b); n != 4 || err != nil || string(b[:n]) != "6789" {
+		t.Errorf("read to the end: got %q %v", b[:n], err)
+	}
+	if n, err := f.Read(b); n != 0 || err != io.EOF {
+		t.Errorf("read at the end: expected io.EOF, got %d %v", n, err)
+	}
+	if _, err := f.Seek(-1, io.SeekStart); err == nil {
+		t.Error("seek to a negative offset: expected an error")
+	}
+
+	// ReadAt and WriteAt do not move the offset
+	if _, err := f.Seek(2, io.SeekStart); err != nil {
+		t.Fatalf("seek: %v", err)
+	}
+	if n, err := f.ReadAt(b[:3], 5); n != 3 || err != nil || string(b[:3]) != "567" {
+		t.Errorf("ReadAt: got %q %v", b[:n], err)
+	}
+	if n, err := f.ReadAt(b, 5); n != 5 || err != io.EOF {
+		t.Errorf("ReadAt past the end: expected 5 and io.EOF, got %d %v", n, err)
+	}
+	if n, err := f.WriteAt([]byte("ab"), 4); n != 2 || err != nil {
+		t.Errorf("WriteAt: %d %v", n, err)
+	}
+	if n, err := f.Read(b[:4]); n != 4 || err != nil || string(b[:4]) != "23ab" {
+		t.Errorf("read after ReadAt and WriteAt: got %q %v", b[:n], err)
+	}
+
+	// writing past the end fills the gap with zeros
+	if _, err := f.WriteAt([]byte("z"), 12); err != nil {
+		t.Errorf("WriteAt past the end: %v", err)
+	}
+	if _, err := f.Seek(15, io.SeekStart); err != nil {
+		t.Errorf("seek past the end: %v", err)
+	}
+	if _, err := io.WriteString(f, "!"); err != nil {
+		t.Errorf("write past the end: %v", err)
+	}
+	expected := "0123ab6789\x00\x00z\x00\x00!"
+	if info, err := f.Stat(); err != nil || info.Size() != int64(len(expected)) || info.Name() != "file" || !info.Mode().IsRegular() {
+		t.Errorf("stat: unexpected info %v %v", info, err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Errorf("sync: %v", err)
+	}
+	checkContent(t, fsys, "file", expected)
+}
+
+func testTruncate(t *testing.T, fsys wfs.FS) {
+	f, err := fsys.OpenFile("file", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	defer f.Close()
+	io.WriteString(f, "0123456789")
+	if err := f.Truncate(4); err != nil {
+		t.Errorf("truncate: %v", err)
+	}
+	// the offset is not changed
+	if off, err := f.Seek(0, io.SeekCurrent); off != 10 || err != nil {
+		t.Errorf("seek after truncate: expected 10, got %d %v", off, err)
+	}
+	if err := f.Truncate(6); err != nil {
+		t.Errorf("extend: %v", err)
+	}
+	if err := f.Truncate(-1); err == nil {
+		t.Error("truncate to a negative size: expected an error")
+	}
+	if info, err := f.Stat(); err != nil || info.Size() != 6 {
+		t.Errorf("stat: unexpected info %v %v", info, err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Errorf("sync: %v", err)
+	}
+	checkContent(t, fsys, "file", "0123\x00\x00")
+
+	r, err := fsys.OpenFile("file", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer r.Close()
+	if err := r.Truncate(0); err == nil {
+		t.Error("truncate of a file opened with O_RDONLY: expected an error")
+	}
+}
+
+func testClosed(t *testing.T, fsys wfs.FS) {
+	f, err := fsys.OpenFile("file", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	_, err = f.Read(make([]byte, 1))
+	checkErr(t, "read after close", err, fs.ErrClosed)
+	_, err = f.Write([]byte("x"))
+	checkErr(t, "write after close", err, fs.ErrClosed)
+	_, err = f.Seek(0, io.SeekStart)
+	checkErr(t, "seek after close", err, fs.ErrClosed)
+	checkErr(t, "truncate after close", f.Truncate(0), fs.ErrClosed)
+	checkErr(t, "close after close", f.Close(), fs.ErrClosed)
+}
+
+func testMkdir(t *testing.T, fsys wfs.FS) {
+	if err := fsys.Mkdir("dir", 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	checkErr(t, "mkdir existing directory", fsys.Mkdir("dir", 0755), fs.ErrExist)
+	checkErr(t, "mkdir in missing directory", fsys.Mkdir("missing/dir", 0755), fs.ErrNotExist)
+	if err := fsys.MkdirAll("dir/a/b", 0755); err != nil {
+		t.Errorf("MkdirAll: %v", err)
+	}
+	if err := fsys.MkdirAll("dir/a/b", 0755); err != nil {
+		t.Errorf("MkdirAll existing directory: %v", err)
+	}
+	if info, err := fs.Stat(fsys, "dir/a/b"); err != nil || !info.IsDir() || info.Name() != "b" {
+		t.Errorf("stat: unexpected info %v %v", info, err)
+	}
+	writeFile(t, fsys, "dir/file", "")
+	checkErr(t, "mkdir existing file", fsys.Mkdir("dir/file", 0755), fs.ErrExist)
+	if err := fsys.MkdirAll("dir/file/sub", 0755); err == nil {
+		t.Error("MkdirAll below a file: expected an error")
+	}
+	checkEntries(t, fsys, "dir", "a", "file")
+	checkTree(t, fsys, "dir/file", "dir/a/b")
+}
+
+func testRemove(t *testing.T, fsys wfs.FS) {
+	fsys.MkdirAll("dir/sub", 0755)
+	writeFile(t, fsys, "dir/sub/file", "content")
+	writeFile(t, fsys, "file", "content")
+
+	checkErr(t, "remove missing file", fsys.Remove("missing"), fs.ErrNotExist)
+	checkErr(t, "remove non-empty directory", fsys.Remove("dir"), wfs.ErrNotEmpty)
+	if err := fsys.Remove("file"); err != nil {
+		t.Errorf("remove file: %v", err)
+	}
+	_, err := fs.Stat(fsys, "file")
+	checkErr(t, "stat removed file", err, fs.ErrNotExist)
+
+	if err := fsys.RemoveAll("missing"); err != nil {
+		t.Errorf("RemoveAll missing path: %v", err)
+	}
+	if err := fsys.RemoveAll("dir/sub"); err != nil {
+		t.Errorf("RemoveAll: %v", err)
+	}
+	checkEntries(t, fsys, "dir")
+	if err := fsys.Remove("dir"); err != nil {
+		t.Errorf("remove empty directory: %v", err)
+	}
+	checkEntries(t, fsys, ".")
+}
+
+func testRename(t *testing.T, fsys wfs.FS) {
+	fsys.MkdirAll("dir/sub", 0755)
+	writeFile(t, fsys, "dir/sub/file", "nested")
+	writeFile(t, fsys, "a", "a")
+	writeFile(t, fsys, "b", "b")
+
+	if err := fsys.Rename("a", "c"); err != nil {
+		t.Errorf("rename file: %v", err)
+	}
+	checkContent(t, fsys, "c", "a")
+	if err := fsys.Rename("c", "b"); err != nil {
+		t.Errorf("rename over existing file: %v", err)
+	}
+	checkContent(t, fsys, "b", "a")
+	checkErr(t, "rename missing file", fsys.Rename("missing", "x"), fs.ErrNotExist)
+	checkErr(t, "rename into missing directory", fsys.Rename("b", "missing/b"), fs.ErrNotExist)
+
+	if err := fsys.Rename("dir", "moved"); err != nil {
+		t.Errorf("rename directory: %v", err)
+	}
+	checkContent(t, fsys, "moved/sub/file", "nested")
+	if err := fsys.Rename("moved", "moved/sub/inside"); err == nil {
+		t.Error("rename directory into itself: expected an error")
+	}
+	if err := fsys.Rename("b", "moved"); err == nil {
+		t.Error("rename file over directory: expected an error")
+	}
+
+	if err := fsys.Rename("b", "moved/b"); err != nil {
+		t.Errorf("rename into directory: %v", err)
+	}
+	checkEntries(t, fsys, ".", "moved")
+	checkTree(t, fsys, "moved/b", "moved/sub/file")
+}
+
+func testInvalidPath(t *testing.T, fsys wfs.FS) {
+	for _, name := range []string{"/abs", "../up", "a/../b", "a//b", ""} {
+		_, err := fsys.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+		checkErr(t, fmt.Sprintf("create %q", name), err, fs.ErrInvalid)
+		checkErr(t, fmt.Sprintf("mkdir %q", name), fsys.Mkdir(name, 0755), fs.ErrInvalid)
+		checkErr(t, fmt.Sprintf("MkdirAll %q", name), fsys.MkdirAll(name, 0755), fs.ErrInvalid)
+		checkErr(t, fmt.Sprintf("remove %q", name), fsys.Remove(name), fs.ErrInvalid)
+		checkErr(t, fmt.Sprintf("RemoveAll %q", name), fsys.RemoveAll(name), fs.ErrInvalid)
+		checkErr(t, fmt.Sprintf("rename to %q", name), fsys.Rename("missing", name), fs.ErrInvalid)
+	}
+	checkErr(t, `remove "."`, fsys.Remove("."), fs.ErrInvalid)
+	checkErr(t, `RemoveAll "."`, fsys.RemoveAll("."), fs.ErrInvalid)
+}
+
+func testConcurrent(t *testing.T, fsys wfs.FS) {
+	const n = 16
+	if err := fsys.Mkdir("dir", 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("dir/%02d", i)
+			content := bytes.Repeat([]byte{byte('a' + i)}, 1000)
+			if err := wfs.WriteFile(fsys, name, content, 0644); err != nil {
+				t.Errorf("WriteFile %s: %v", name, err)
+				return
+			}
+			if data, err := fs.ReadFile(fsys, name); err != nil || !bytes.Equal(data, content) {
+				t.Errorf("ReadFile %s: unexpected content %v", name, err)
+			}
+			// only one exclusive create of the same file succeeds
+			f, err := fsys.OpenFile("dir/exclusive", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+			if err == nil {
+				f.Close()
+				mu.Lock()
+				created++
+				mu.Unlock()
+			} else if !errors.Is(err, fs.ErrExist) {
+				t.Errorf("exclusive create: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if created != 1 {
+		t.Errorf("exclusive create: expected 1 success, got %d", created)
+	}
+	entries, err := fs.ReadDir(fsys, "dir")
+	if err != nil || len(entries) != n+1 {
+		t.Errorf("ReadDir: expected %d entries, got %d %v", n+1, len(entries), err)
+	}
+
+	// writes at distinct offsets of a shared file do not interfere
+	f, err := fsys.OpenFile("shared", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	defer f.Close()
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := f.WriteAt(bytes.Repeat([]byte{byte('a' + i)}, 100), int64(i*100)); err != nil {
+				t.Errorf("WriteAt: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	b := make([]byte, n*100)
+	if _, err := f.ReadAt(b, 0); err != nil {
+		t.Fatalf("ReadAt: %v", err)
+	}
+	for i := range n {
+		if !bytes.Equal(b[i*100:(i+1)*100], bytes.Repeat([]byte{byte('a' + i)}, 100)) {
+			t.Errorf("WriteAt at %d was overwritten", i*100)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package wfstest_test
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/eriicafes/wfs"
+	"github.com/eriicafes/wfs/wfstest"
+)
+
+func TestMem(t *testing.T) {
+	wfstest.TestFS(t, func() wfs.FS { return wfs.Mem() }, wfstest.Concurrent())
+}
+
+func TestDir(t *testing.T) {
+	wfstest.TestFS(t, func() wfs.FS { return wfs.Dir(t.TempDir()) }, wfstest.Concurrent())
+}
+
+func TestMap(t *testing.T) {
+	wfstest.TestFS(t, func() wfs.FS { return wfs.Map(fstest.MapFS{}) })
+}
+
+func TestCAS(t *testing.T) {
+	wfstest.TestFS(t, func() wfs.FS {
+		fsys, err := wfs.CAS(wfs.Mem())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fsys
+	}, wfstest.Concurrent())
+}
+
+func TestDedup(t *testing.T) {
+	wfstest.TestFS(t, func() wfs.FS {
+		fsys, err := wfs.Dedup(wfs.Mem(), wfs.Mem())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fsys
+	}, wfstest.Concurrent())
+}
+
+func TestEncrypted(t *testing.T) {
+	wfstest.TestFS(t, func() wfs.FS {
+		return wfs.Encrypted(wfs.Mem(), wfs.StaticKey(make([]byte, 32)), wfs.EncryptNames(""))
+	}, wfstest.Concurrent())
+}
+
+// addSeeds adds operation sequences decoded by [wfstest.Differential].
+func addSeeds(f *testing.F) {
+	// open, write and read back a file
+	f.Add([]byte{0, 0, 2, 0x01, 0, 1, 0, 0, 8, 0, 16, 0, 0, 0, 0})
+	// create a tree, rename and list it
+	f.Add([]byte{10, 5, 0, 0, 0, 13, 0, 1, 0, 0, 15, 1, 0, 0, 0})
+	// write at an offset, truncate, seek from the end and read
+	f.Add([]byte{0, 4, 2, 0x05, 0, 2, 0, 0, 4, 9, 6, 0, 0, 0, 3, 5, 0, 2, 0, 0, 3, 0, 0, 16, 0})
+}
+
+func FuzzMem(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		wfstest.Differential(t, data, wfs.Dir(t.TempDir()), wfs.Mem())
+	})
+}
+
+func FuzzMap(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		wfstest.Differential(t, data, wfs.Dir(t.TempDir()), wfs.Map(fstest.MapFS{}))
+	})
+}
+
+func TestWrite(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tree := fstest.MapFS{
+		"a.txt":     {Data: []byte("a"), Mode: 0600},
+		"dir":       {Mode: fs.ModeDir | 0755, ModTime: modTime},
+		"dir/b.txt": {Data: []byte("b"), ModTime: modTime},
+		"dir/sub/c": {Data: []byte("c")},
+		"empty":     {Mode: fs.ModeDir},
+		"dir/link":  {Data: []byte("b.txt"), Mode: fs.ModeSymlink},
+	}
+	for name, fsys := range map[string]wfs.FS{"Mem": wfs.Mem(), "Dir": wfs.Dir(t.TempDir())} {
+		t.Run(name, func(t *testing.T) {
+			if err := wfstest.Write(fsys, tree); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range map[string]string{"a.txt": "a", "dir/b.txt": "b", "dir/sub/c": "c", "dir/link": "b"} {
+				if b, err := fs.ReadFile(fsys, name); err != nil || string(b) != want {
+					t.Errorf("ReadFile %s: expected %q, got %q err: %v", name, want, b, err)
+				}
+			}
+			for name, want := range map[string]fs.FileMode{"a.txt": 0600, "dir": fs.ModeDir | 0755} {
+				if info, err := fs.Stat(fsys, name); err != nil || info.Mode() != want {
+					t.Errorf("Stat %s: expected mode %v, got %v err: %v", name, want, info.Mode(), err)
+				}
+			}
+			for _, name := range []string{"dir", "dir/b.txt"} {
+				if info, err := fs.Stat(fsys, name); err != nil || !info.ModTime().Equal(modTime) {
+					t.Errorf("Stat %s: expected modification time %v, got %v err: %v", name, modTime, info.ModTime(), err)
+				}
+			}
+			if info, err := fs.Stat(fsys, "empty"); err != nil || !info.IsDir() {
+				t.Errorf("expected empty directory, got %v err: %v", info, err)
+			}
+		})
+	}
+}
+
+func TestWriteTxtar(t *testing.T) {
+	fsys := wfs.Mem()
+	err := wfstest.WriteTxtar(fsys, `comment is ignored
+-- a.txt --
+hello
+-- dir/b.txt --
+-- empty/ --
+-- dir/sub/c.txt --
+line 1
+line 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"a.txt": "hello\n", "dir/b.txt": "", "dir/sub/c.txt": "line 1\nline 2\n"} {
+		if b, err := fs.ReadFile(fsys, name); err != nil || string(b) != want {
+			t.Errorf("ReadFile %s: expected %q, got %q err: %v", name, want, b, err)
+		}
+	}
+	if info, err := fs.Stat(fsys, "empty"); err != nil || !info.IsDir() {
+		t.Errorf("expected empty directory, got %v err: %v", info, err)
+	}
+}
+
+// recorder records the errors reported through it instead of failing the test.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestAssertEqualFS(t *testing.T) {
+	golden := wfs.FromTxtar([]byte(`
+-- a.txt --
+one
+two
+three
+-- b.txt --
+b
+-- dir/c.txt --
+c
+`))
+	got := wfs.Mem()
+	if err := wfstest.WriteTxtar(got, `
+-- a.txt --
+one
+TWO
+three
+-- dir/c.txt --
+c
+-- d.txt --
+d
+`); err != nil {
+		t.Fatal(err)
+	}
+	// permissions are ignored
+	if err := wfs.Chmod(got, "dir/c.txt", 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &recorder{TB: t}
+	wfstest.AssertEqualFS(r, golden, got)
+	if len(r.errs) != 1 {
+		t.Fatalf("expected one error, got %q", r.errs)
+	}
+	for _, want := range []string{"a.txt differs", "-   2 | two", "+   2 | TWO", "missing file b.txt", "unexpected file d.txt"} {
+		if !strings.Contains(r.errs[0], want) {
+			t.Errorf("expected error to contain %q, got:\n%s", want, r.errs[0])
+		}
+	}
+	if strings.Contains(r.errs[0], "c.txt") {
+		t.Errorf("expected dir/c.txt to be equal, got:\n%s", r.errs[0])
+	}
+
+	// -update rewrites the golden tree
+	flag.Set("update", "true")
+	wfstest.AssertEqualFS(t, golden, got)
+	flag.Set("update", "false")
+	r = &recorder{TB: t}
+	wfstest.AssertEqualFS(r, golden, got)
+	if len(r.errs) != 0 {
+		t.Errorf("expected trees to be equal after update, got %q", r.errs)
+	}
+	if _, err := fs.Stat(golden, "b.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected b.txt to be removed from the golden tree, got %v", err)
+	}
+}
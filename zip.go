@@ -0,0 +1,193 @@
+package wfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ZipFS is a file system backed by a zip archive.
+type ZipFS interface {
+	FS
+
+	// Flush rewrites the archive with the current contents of the file system.
+	Flush() error
+
+	// Close flushes the archive and closes it if it implements [io.Closer].
+	Close() error
+}
+
+// Zip returns a ZipFS exposing the zip archive in rw as a writable file
+// system. An empty rw is a new archive.
+//
+// The archive is loaded into memory, and modifications are buffered until
+// the archive is rewritten from the beginning of rw by Flush or Close.
+// If the rewritten archive is smaller than the original, rw must implement
+// Truncate(int64) error, like [os.File], so that the rest is discarded.
+//
+// Directories, regular files and symbolic links are supported, with their
+// modes and modification times. Hard links are archived as separate files.
+func Zip(rw io.ReadWriteSeeker) (ZipFS, error) {
+	f := &zipFs{memFs: Mem().(*memFs), rw: rw}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type zipFs struct {
+	*memFs
+	rw io.ReadWriteSeeker
+	mu sync.Mutex // serializes flushes
+}
+
+// load reads the archive into memory.
+func (f *zipFs) load() error {
+	if _, err := f.rw.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(f.rw)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	var dirs []copiedDir
+	for _, zf := range zr.File {
+		name := path.Clean(strings.TrimSuffix(zf.Name, "/"))
+		if !fs.ValidPath(name) {
+			return &fs.PathError{Op: "zip", Path: zf.Name, Err: fs.ErrInvalid}
+		}
+		info := zf.FileInfo()
+		if name == "." {
+			continue
+		}
+		if info.IsDir() {
+			if err := f.MkdirAll(name, 0777); err != nil {
+				return err
+			}
+			dirs = append(dirs, copiedDir{name, info})
+			continue
+		}
+		if err := mkdirParent(f, name); err != nil {
+			return err
+		}
+		if err := f.loadFile(zf, name, info); err != nil {
+			return err
+		}
+	}
+	for _, d := range slices.Backward(dirs) {
+		if err := copyMeta(f, d.name, d.info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadFile loads a file of the archive.
+func (f *zipFs) loadFile(zf *zip.File, name string, info fs.FileInfo) error {
+	r, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if info.Mode()&fs.ModeSymlink != 0 {
+		// symbolic links store their destination as contents
+		dest, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return f.Symlink(string(dest), name)
+	}
+	if _, err := WriteReader(f, name, r, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return copyMeta(f, name, info)
+}
+
+func (f *zipFs) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// the archive is built in memory so that a failure leaves rw untouched
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err := fs.WalkDir(f, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		switch {
+		case d.IsDir():
+			hdr.Name += "/"
+			_, err = zw.CreateHeader(hdr)
+			return err
+		case d.Type()&fs.ModeSymlink != 0:
+			dest, err := f.Readlink(name)
+			if err != nil {
+				return err
+			}
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, dest)
+			return err
+		case !d.Type().IsRegular():
+			return nil
+		}
+		hdr.Method = zip.Deflate
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		r, err := f.Open(name)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		_, err = io.Copy(w, r)
+		return err
+	})
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.rw.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := f.rw.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if t, ok := f.rw.(interface{ Truncate(int64) error }); ok {
+		return t.Truncate(int64(buf.Len()))
+	}
+	return nil
+}
+
+func (f *zipFs) Close() error {
+	err := f.Flush()
+	if c, ok := f.rw.(io.Closer); ok {
+		if err1 := c.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package wfs_test
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eriicafes/wfs"
+)
+
+func TestZip(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	name := filepath.Join(t.TempDir(), "bundle.zip")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	zw := zip.NewWriter(file)
+	for _, e := range []struct {
+		name string
+		mode fs.FileMode
+		data string
+	}{
+		{"META-INF/", fs.ModeDir | 0755, ""},
+		{"META-INF/MANIFEST.MF", 0644, "Manifest-Version: 1.0"},
+		{"lib/large.bin", 0600, string(make([]byte, 4096))},
+		{"run.sh", 0755, "#!/bin/sh"},
+	} {
+		hdr := &zip.FileHeader{Name: e.name, Modified: mtime}
+		hdr.SetMode(e.mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatalf("failed to write archive: %v", err)
+		}
+		io.WriteString(w, e.data)
+	}
+	zw.Close()
+
+	zfs, err := wfs.Zip(file)
+	if err != nil {
+		t.Fatalf("Zip failed: %v", err)
+	}
+	assertEntries(t, zfs, ".", "META-INF", "lib", "run.sh")
+	assertContent(t, zfs, "META-INF/MANIFEST.MF", "Manifest-Version: 1.0")
+	info, err := fs.Stat(zfs, "run.sh")
+	if err != nil || info.Mode() != 0755 || !info.ModTime().Equal(mtime) {
+		t.Errorf("expected mode 0755 and mtime %v, got %v %v err: %v", mtime, info.Mode(), info.ModTime(), err)
+	}
+
+	if err := wfs.WriteFile(zfs, "META-INF/MANIFEST.MF", []byte("Manifest-Version: 2.0"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := zfs.RemoveAll("lib"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if err := wfs.Symlink(zfs, "run.sh", "start"); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	if err := zfs.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// the archive is rewritten, and shrinks without trailing data
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	assertContent(t, zr, "META-INF/MANIFEST.MF", "Manifest-Version: 2.0")
+	zr.Close()
+
+	file, err = os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+	zfs, err = wfs.Zip(file)
+	if err != nil {
+		t.Fatalf("Zip failed: %v", err)
+	}
+	assertEntries(t, zfs, ".", "META-INF", "run.sh", "start")
+	if dest, err := wfs.Readlink(zfs, "start"); err != nil || dest != "run.sh" {
+		t.Errorf("expected link to run.sh, got %q err: %v", dest, err)
+	}
+	if info, err := fs.Stat(zfs, "run.sh"); err != nil || info.Mode() != 0755 {
+		t.Errorf("expected mode 0755, got %v err: %v", info.Mode(), err)
+	}
+}
+
+func TestZipEmpty(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "new.zip"))
+	if err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	defer file.Close()
+	zfs, err := wfs.Zip(file)
+	if err != nil {
+		t.Fatalf("Zip failed: %v", err)
+	}
+	if err := wfs.WriteFile(zfs, "a.txt", []byte("a"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := zfs.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	info, _ := file.Stat()
+	zr, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	assertContent(t, zr, "a.txt", "a")
+}